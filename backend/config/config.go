@@ -9,6 +9,10 @@ type Config struct {
 	JWT      JWT
 	Strava   Strava
 	Summit   Summit
+	DEM      DEM
+	Export   Export
+	Email    Email
+	Live     Live
 }
 
 func NewConfig() *Config {
@@ -31,6 +35,28 @@ func NewConfig() *Config {
 		},
 		Summit: Summit{
 			SummitThresholdMeters: os.Getenv("SUMMIT_THRESHOLD_METERS"),
+			UseActivityStreams:    os.Getenv("SUMMIT_USE_STREAMS"),
+			DuplicatePeakMeters:   os.Getenv("DUPLICATE_PEAK_METERS"),
+			ClaimToleranceMeters:  os.Getenv("SUMMIT_CLAIM_TOLERANCE_METERS"),
+		},
+		DEM: DEM{
+			TileDir: os.Getenv("DEM_TILE_DIR"),
+		},
+		Export: Export{
+			Dir:       os.Getenv("DATA_EXPORT_DIR"),
+			MaxSizeMB: os.Getenv("DATA_EXPORT_MAX_SIZE_MB"),
+			LinkHours: os.Getenv("DATA_EXPORT_LINK_HOURS"),
+		},
+		Email: Email{
+			SMTPHost:     os.Getenv("SMTP_HOST"),
+			SMTPPort:     os.Getenv("SMTP_PORT"),
+			SMTPUsername: os.Getenv("SMTP_USERNAME"),
+			SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+			From:         os.Getenv("EMAIL_FROM"),
+			BaseURL:      os.Getenv("APP_BASE_URL"),
+		},
+		Live: Live{
+			Broker: os.Getenv("LIVE_EVENTS_BROKER"),
 		},
 	}
 }
@@ -56,4 +82,30 @@ type Strava struct {
 
 type Summit struct {
 	SummitThresholdMeters string // = "0.0007"
+	UseActivityStreams    string // "false" to estimate summit times from the polyline only
+	DuplicatePeakMeters   string // Peaks closer than this are proposed as duplicates, default 50
+	ClaimToleranceMeters  string // Claimed summits this close to the route are approved without review, default 250
+}
+
+type DEM struct {
+	TileDir string // Directory of .hgt or GeoTIFF tiles, empty to disable
+}
+
+type Export struct {
+	Dir       string // Where export ZIPs are written, default a directory under the OS temp dir
+	MaxSizeMB string // Exports larger than this fail, default 200
+	LinkHours string // How long a download link works, default 24
+}
+
+type Email struct {
+	SMTPHost     string // Empty to disable email
+	SMTPPort     string // Default 587
+	SMTPUsername string // Empty to send without authenticating, e.g. to a local catcher
+	SMTPPassword string
+	From         string // e.g. "Run Goals <noreply@example.com>"
+	BaseURL      string // Public URL of the backend for links in emails, e.g. "https://api.example.com"
+}
+
+type Live struct {
+	Broker string // "memory" (default) or "postgres" to fan out over LISTEN/NOTIFY when running several replicas
 }
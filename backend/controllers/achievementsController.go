@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"run-goals/dto"
+	"run-goals/meta"
+	"run-goals/services"
+	"strconv"
+)
+
+type AchievementsControllerInterface interface {
+	GetAchievements(rw http.ResponseWriter, r *http.Request)
+	GetGroupAchievements(rw http.ResponseWriter, r *http.Request)
+	BackfillAchievements(rw http.ResponseWriter, r *http.Request)
+}
+
+type AchievementsController struct {
+	l                  *log.Logger
+	achievementService *services.AchievementService
+}
+
+func NewAchievementsController(
+	l *log.Logger,
+	achievementService *services.AchievementService,
+) *AchievementsController {
+	return &AchievementsController{
+		l:                  l,
+		achievementService: achievementService,
+	}
+}
+
+// GetAchievements returns a user's achievements for their profile.
+// Defaults to the current user; pass userId to view someone else's.
+func (c *AchievementsController) GetAchievements(rw http.ResponseWriter, r *http.Request) {
+	c.l.Printf("Handle GET achievements")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+	if idStr := r.URL.Query().Get("userId"); idStr != "" {
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			http.Error(rw, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+		userID = id
+	}
+
+	achievements, err := c.achievementService.GetUserAchievements(userID)
+	if err != nil {
+		c.l.Printf("Error getting achievements: %v", err)
+		http.Error(rw, "Failed to get achievements", http.StatusInternalServerError)
+		return
+	}
+
+	definitions, err := c.achievementService.GetDefinitions()
+	if err != nil {
+		c.l.Printf("Error getting achievement definitions: %v", err)
+		http.Error(rw, "Failed to get achievements", http.StatusInternalServerError)
+		return
+	}
+
+	response := dto.UserAchievementsResponse{
+		Achievements: achievements,
+		Available:    definitions,
+		Total:        len(achievements),
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(response)
+}
+
+// GetGroupAchievements returns the latest achievements earned by group members
+func (c *AchievementsController) GetGroupAchievements(rw http.ResponseWriter, r *http.Request) {
+	c.l.Printf("Handle GET group-achievements")
+
+	groupID, err := strconv.ParseInt(r.URL.Query().Get("groupID"), 10, 64)
+	if err != nil {
+		http.Error(rw, "Invalid group ID", http.StatusBadRequest)
+		return
+	}
+
+	limit := 50
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+		}
+	}
+
+	achievements, err := c.achievementService.GetGroupAchievements(groupID, limit)
+	if err != nil {
+		c.l.Printf("Error getting group achievements: %v", err)
+		http.Error(rw, "Failed to get group achievements", http.StatusInternalServerError)
+		return
+	}
+
+	response := dto.GroupAchievementsResponse{Achievements: achievements}
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(response)
+}
+
+// BackfillAchievements evaluates every user's existing history.
+// POST /admin/backfill-achievements?admin_key=xxx
+func (c *AchievementsController) BackfillAchievements(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !checkAdminKey(c.l, rw, r, "backfill-achievements") {
+		return
+	}
+
+	c.l.Printf("Starting achievements backfill...")
+	awarded, err := c.achievementService.EvaluateAllUsers()
+	if err != nil {
+		c.l.Printf("Error backfilling achievements: %v", err)
+		http.Error(rw, "Failed to backfill achievements", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(dto.BackfillAchievementsResponse{Awarded: awarded})
+}
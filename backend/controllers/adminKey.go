@@ -0,0 +1,25 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"os"
+)
+
+// checkAdminKey checks the admin_key query param of the unauthenticated admin
+// endpoints against ADMIN_KEY, writing a 401 if it's wrong. endpoint names
+// the endpoint in the log.
+func checkAdminKey(l *log.Logger, rw http.ResponseWriter, r *http.Request, endpoint string) bool {
+	adminKey := r.URL.Query().Get("admin_key")
+	expectedKey := os.Getenv("ADMIN_KEY")
+	if expectedKey == "" {
+		expectedKey = "dev-admin-key" // Default for local development
+	}
+	if subtle.ConstantTimeCompare([]byte(adminKey), []byte(expectedKey)) != 1 {
+		l.Printf("Unauthorized %s attempt", endpoint)
+		http.Error(rw, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
@@ -2,13 +2,16 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"run-goals/meta"
 	"run-goals/models"
 	"run-goals/services"
 	"strconv"
+	"strings"
 	"time"
+	"unicode"
 )
 
 type ApiControllerInterface interface {
@@ -30,6 +33,8 @@ type ApiController struct {
 	userService             *services.UserService
 	personalGoalsService    *services.PersonalGoalsService
 	summitFavouritesService *services.SummitFavouritesService
+	streakService           *services.StreakService
+	elevationService        *services.ElevationService
 }
 
 func NewApiController(
@@ -41,6 +46,8 @@ func NewApiController(
 	userService *services.UserService,
 	personalGoalsService *services.PersonalGoalsService,
 	summitFavouritesService *services.SummitFavouritesService,
+	streakService *services.StreakService,
+	elevationService *services.ElevationService,
 ) *ApiController {
 	return &ApiController{
 		l:                       l,
@@ -51,29 +58,171 @@ func NewApiController(
 		userService:             userService,
 		personalGoalsService:    personalGoalsService,
 		summitFavouritesService: summitFavouritesService,
+		streakService:           streakService,
+		elevationService:        elevationService,
 	}
 }
 
+// ListActivities returns a page of the user's activities matching filters
+// and a full-text search on name and description. Pass next_cursor from one
+// page as cursor to get the next. Both dates are inclusive.
+// GET /api/activities?q=&start_date=&end_date=&type=Run,Hike&sport_type=
+//
+//	&has_summit=true&peak_id=&min_distance=&min_elevation=&tag=hg
+//	&sort=date|distance|elevation|moving_time&order=desc|asc&limit=50&cursor=
 func (c *ApiController) ListActivities(rw http.ResponseWriter, r *http.Request) {
 	c.l.Println("Handle GET ListActivities")
 
 	userID, _ := meta.GetUserIDFromContext(r.Context())
+	query := r.URL.Query()
 
-	// Call activityService to return list of activities by userId
-	response, err := c.activityService.GetActivitiesByUserID(userID)
+	filter := models.ActivityListFilter{
+		UserID:     userID,
+		Query:      strings.TrimSpace(query.Get("q")),
+		Types:      splitList(query.Get("type")),
+		SportTypes: splitList(query.Get("sport_type")),
+		Sort:       models.ActivityListSort(query.Get("sort")),
+		Descending: query.Get("order") != "asc",
+	}
+
+	for param, target := range map[string]**time.Time{"start_date": &filter.StartDate, "end_date": &filter.EndDate} {
+		v := query.Get(param)
+		if v == "" {
+			continue
+		}
+		date, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			http.Error(rw, "Invalid "+param+", expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		// The filter's end is exclusive, so take in the whole end day
+		if param == "end_date" {
+			date = date.AddDate(0, 0, 1)
+		}
+		*target = &date
+	}
+	for param, target := range map[string]**float64{"min_distance": &filter.MinDistance, "min_elevation": &filter.MinElevation} {
+		v := query.Get(param)
+		if v == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			http.Error(rw, "Invalid "+param, http.StatusBadRequest)
+			return
+		}
+		*target = &f
+	}
+	if v := query.Get("has_summit"); v != "" {
+		hasSummit, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(rw, "Invalid has_summit", http.StatusBadRequest)
+			return
+		}
+		filter.HasSummit = &hasSummit
+	}
+	if v := query.Get("peak_id"); v != "" {
+		peakID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(rw, "Invalid peak_id", http.StatusBadRequest)
+			return
+		}
+		filter.PeakID = &peakID
+	}
+	for _, tag := range splitList(query.Get("tag")) {
+		tag = strings.TrimPrefix(tag, "#")
+		if !isHashtag(tag) {
+			http.Error(rw, "Invalid tag, expected letters, digits or underscores", http.StatusBadRequest)
+			return
+		}
+		filter.Tags = append(filter.Tags, tag)
+	}
+	if v, err := strconv.Atoi(query.Get("limit")); err == nil && v > 0 {
+		filter.Limit = v
+	}
+	if v := query.Get("cursor"); v != "" {
+		cursor, err := services.ParseActivityCursor(v)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter.Cursor = cursor
+	}
+
+	page, err := c.activityService.ListActivities(filter)
 	if err != nil {
-		c.l.Println("Error fetching activities", err)
-		http.Error(rw, "Failed to fetch activities", http.StatusInternalServerError)
+		switch {
+		case errors.Is(err, services.ErrActivityListSortInvalid),
+			errors.Is(err, services.ErrActivityCursorInvalid),
+			errors.Is(err, services.ErrActivityDateRangeInvalid):
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+		default:
+			c.l.Printf("Error listing activities: %v", err)
+			http.Error(rw, "Failed to list activities", http.StatusInternalServerError)
+		}
 		return
 	}
 
-	// Return the array of activities as JSON
 	rw.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(rw).Encode(response); err != nil {
-		log.Println("Error encoding activities:", err)
+	if err := json.NewEncoder(rw).Encode(page); err != nil {
+		log.Println("Error encoding activity list response:", err)
 	}
 }
 
+// GetActivityDetail returns one of the user's activities with the peaks
+// summited on it and its decoded route
+// GET /api/activity?id=123
+func (c *ApiController) GetActivityDetail(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle GET ActivityDetail")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	activityID, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		http.Error(rw, "Invalid id", http.StatusBadRequest)
+		return
+	}
+
+	detail, err := c.activityService.GetActivityDetail(activityID, userID)
+	if err != nil {
+		if errors.Is(err, services.ErrActivityNotFound) {
+			http.Error(rw, err.Error(), http.StatusNotFound)
+			return
+		}
+		c.l.Printf("Error fetching activity detail: %v", err)
+		http.Error(rw, "Failed to fetch activity", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(detail); err != nil {
+		log.Println("Error encoding activity detail:", err)
+	}
+}
+
+// splitList reads a comma-separated query value, dropping empty entries
+func splitList(value string) []string {
+	values := []string{}
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func isHashtag(tag string) bool {
+	if tag == "" {
+		return false
+	}
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
 func (c *ApiController) ListPeaks(rw http.ResponseWriter, r *http.Request) {
 	c.l.Println("Handle GET ListPeaks")
 
@@ -150,7 +299,13 @@ func (c *ApiController) UpdateUserProfile(rw http.ResponseWriter, r *http.Reques
 	userID, _ := meta.GetUserIDFromContext(r.Context())
 
 	var req struct {
-		Username string `json:"username"`
+		Username  string  `json:"username"`
+		Timezone  *string `json:"timezone"`   // Optional, IANA name
+		WeekStart *int    `json:"week_start"` // Optional, 0 = Sunday ... 6 = Saturday
+
+		ShowInLeaderboards   *bool `json:"show_in_leaderboards"`    // Optional, privacy
+		ShowInGroupFeeds     *bool `json:"show_in_group_feeds"`     // Optional, privacy
+		ShowInChallengeFeeds *bool `json:"show_in_challenge_feeds"` // Optional, privacy
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -159,17 +314,80 @@ func (c *ApiController) UpdateUserProfile(rw http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Validate username (3-50 characters, alphanumeric + underscores)
-	if len(req.Username) < 3 || len(req.Username) > 50 {
-		http.Error(rw, "Username must be between 3 and 50 characters", http.StatusBadRequest)
-		return
+	updatingPreferences := req.Timezone != nil || req.WeekStart != nil
+	updatingFeeds := req.ShowInGroupFeeds != nil || req.ShowInChallengeFeeds != nil
+	if req.Username != "" || (!updatingPreferences && !updatingFeeds && req.ShowInLeaderboards == nil) {
+		// Validate username (3-50 characters, alphanumeric + underscores)
+		if len(req.Username) < 3 || len(req.Username) > 50 {
+			http.Error(rw, "Username must be between 3 and 50 characters", http.StatusBadRequest)
+			return
+		}
+
+		err := c.userService.UpdateUsername(userID, req.Username)
+		if err != nil {
+			c.l.Println("Error updating username", err)
+			http.Error(rw, "Failed to update username", http.StatusInternalServerError)
+			return
+		}
 	}
 
-	err := c.userService.UpdateUsername(userID, req.Username)
-	if err != nil {
-		c.l.Println("Error updating username", err)
-		http.Error(rw, "Failed to update username", http.StatusInternalServerError)
-		return
+	if updatingPreferences {
+		current, err := c.userService.GetUserByID(userID)
+		if err != nil {
+			c.l.Println("Error fetching user for preferences", err)
+			http.Error(rw, "Failed to update preferences", http.StatusInternalServerError)
+			return
+		}
+		timezone, weekStart := current.Timezone, current.WeekStart
+		if req.Timezone != nil {
+			timezone = *req.Timezone
+		}
+		if req.WeekStart != nil {
+			weekStart = *req.WeekStart
+		}
+
+		err = c.streakService.UpdatePreferences(userID, timezone, weekStart)
+		if err != nil {
+			if errors.Is(err, services.ErrTimezoneInvalid) || errors.Is(err, services.ErrWeekStartInvalid) {
+				http.Error(rw, err.Error(), http.StatusBadRequest)
+				return
+			}
+			c.l.Println("Error updating preferences", err)
+			http.Error(rw, "Failed to update preferences", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.ShowInLeaderboards != nil {
+		err := c.userService.UpdateShowInLeaderboards(userID, *req.ShowInLeaderboards)
+		if err != nil {
+			c.l.Println("Error updating privacy settings", err)
+			http.Error(rw, "Failed to update privacy settings", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if updatingFeeds {
+		current, err := c.userService.GetUserByID(userID)
+		if err != nil {
+			c.l.Println("Error fetching user for privacy settings", err)
+			http.Error(rw, "Failed to update privacy settings", http.StatusInternalServerError)
+			return
+		}
+		groupFeeds, challengeFeeds := current.ShowInGroupFeeds, current.ShowInChallengeFeeds
+		if req.ShowInGroupFeeds != nil {
+			groupFeeds = *req.ShowInGroupFeeds
+		}
+		if req.ShowInChallengeFeeds != nil {
+			challengeFeeds = *req.ShowInChallengeFeeds
+		}
+
+		err = c.userService.UpdateFeedVisibility(userID, groupFeeds, challengeFeeds)
+		if err != nil {
+			c.l.Println("Error updating privacy settings", err)
+			http.Error(rw, "Failed to update privacy settings", http.StatusInternalServerError)
+			return
+		}
 	}
 
 	// Return updated profile
@@ -249,6 +467,191 @@ func (c *ApiController) SavePersonalGoals(rw http.ResponseWriter, r *http.Reques
 	}
 }
 
+// GetPersonalGoalProgress returns pacing and projections for the user's yearly goal
+// GET /api/personal-goals/progress?year=2025 (defaults to current year if not specified)
+func (c *ApiController) GetPersonalGoalProgress(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle GET PersonalGoalProgress")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	// Get year from query params, default to current year
+	yearStr := r.URL.Query().Get("year")
+	year := time.Now().Year()
+	if yearStr != "" {
+		if parsedYear, err := strconv.Atoi(yearStr); err == nil {
+			year = parsedYear
+		}
+	}
+
+	progress, err := c.personalGoalsService.GetGoalProgress(userID, year)
+	if err != nil {
+		c.l.Printf("Error fetching personal goal progress: %v", err)
+		http.Error(rw, "Failed to fetch personal goal progress", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(progress); err != nil {
+		log.Println("Error encoding personal goal progress response:", err)
+	}
+}
+
+// GetAllPersonalGoalProgress returns progress for every yearly goal (for history view)
+// GET /api/personal-goals/progress/all
+func (c *ApiController) GetAllPersonalGoalProgress(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle GET AllPersonalGoalProgress")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	progress, err := c.personalGoalsService.GetAllGoalProgress(userID)
+	if err != nil {
+		c.l.Printf("Error fetching personal goal progress history: %v", err)
+		http.Error(rw, "Failed to fetch personal goal progress", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(progress); err != nil {
+		log.Println("Error encoding personal goal progress history response:", err)
+	}
+}
+
+// ListGoals returns the user's personal goals with progress, or a single goal
+// GET /api/goals
+// GET /api/goals?goal_id=123
+func (c *ApiController) ListGoals(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle GET Goals")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	var result interface{}
+	if goalIDStr := r.URL.Query().Get("goal_id"); goalIDStr != "" {
+		goalID, err := strconv.ParseInt(goalIDStr, 10, 64)
+		if err != nil {
+			http.Error(rw, "Invalid goal_id", http.StatusBadRequest)
+			return
+		}
+		goal, err := c.personalGoalsService.GetPersonalGoal(goalID, userID)
+		if err != nil {
+			c.writeGoalError(rw, err, "Failed to fetch goal")
+			return
+		}
+		result = goal
+	} else {
+		goals, err := c.personalGoalsService.ListPersonalGoals(userID)
+		if err != nil {
+			c.writeGoalError(rw, err, "Failed to fetch goals")
+			return
+		}
+		result = goals
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(result); err != nil {
+		log.Println("Error encoding goals response:", err)
+	}
+}
+
+// CreateGoal creates a personal goal for the user
+// POST /api/goals
+func (c *ApiController) CreateGoal(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle POST Goals")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	var goal models.PersonalGoal
+	if err := json.NewDecoder(r.Body).Decode(&goal); err != nil {
+		c.l.Printf("Error decoding request body: %v", err)
+		http.Error(rw, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Ensure the goal belongs to this user
+	goal.UserID = userID
+
+	if err := c.personalGoalsService.CreatePersonalGoal(&goal); err != nil {
+		c.writeGoalError(rw, err, "Failed to create goal")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(goal); err != nil {
+		log.Println("Error encoding goal response:", err)
+	}
+}
+
+// UpdateGoal updates one of the user's personal goals
+// PUT /api/goals?goal_id=123
+func (c *ApiController) UpdateGoal(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle PUT Goals")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	goalID, err := strconv.ParseInt(r.URL.Query().Get("goal_id"), 10, 64)
+	if err != nil {
+		http.Error(rw, "Invalid goal_id", http.StatusBadRequest)
+		return
+	}
+
+	var goal models.PersonalGoal
+	if err := json.NewDecoder(r.Body).Decode(&goal); err != nil {
+		c.l.Printf("Error decoding request body: %v", err)
+		http.Error(rw, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	goal.ID = goalID
+
+	if err := c.personalGoalsService.UpdatePersonalGoal(&goal, userID); err != nil {
+		c.writeGoalError(rw, err, "Failed to update goal")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(goal); err != nil {
+		log.Println("Error encoding goal response:", err)
+	}
+}
+
+// DeleteGoal removes one of the user's personal goals
+// DELETE /api/goals?goal_id=123
+func (c *ApiController) DeleteGoal(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle DELETE Goals")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	goalID, err := strconv.ParseInt(r.URL.Query().Get("goal_id"), 10, 64)
+	if err != nil {
+		http.Error(rw, "Invalid goal_id", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.personalGoalsService.DeletePersonalGoal(goalID, userID); err != nil {
+		c.writeGoalError(rw, err, "Failed to delete goal")
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// writeGoalError maps personal goal errors to a status code
+func (c *ApiController) writeGoalError(rw http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrPersonalGoalNotFound):
+		http.Error(rw, "Goal not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrPersonalGoalNameRequired),
+		errors.Is(err, services.ErrPersonalGoalMetricInvalid),
+		errors.Is(err, services.ErrPersonalGoalPeriodInvalid),
+		errors.Is(err, services.ErrPersonalGoalTargetInvalid),
+		errors.Is(err, services.ErrPersonalGoalRegionRequired),
+		errors.Is(err, services.ErrPersonalGoalPeaksRequired),
+		errors.Is(err, services.ErrYearlyGoalFixed):
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+	default:
+		c.l.Printf("%s: %v", message, err)
+		http.Error(rw, message, http.StatusInternalServerError)
+	}
+}
+
 // GetAllPersonalGoals returns all yearly goals for the user (for history view)
 // GET /api/personal-goals/all
 func (c *ApiController) GetAllPersonalGoals(rw http.ResponseWriter, r *http.Request) {
@@ -360,3 +763,431 @@ func (c *ApiController) RemoveSummitFavourite(rw http.ResponseWriter, r *http.Re
 		log.Println("Error encoding summit favourites response:", err)
 	}
 }
+
+// GetWishlist returns the user's wishlist with peaks, plans and bagged status
+// GET /api/wishlist
+func (c *ApiController) GetWishlist(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle GET Wishlist")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	items, err := c.summitFavouritesService.GetWishlist(userID)
+	if err != nil {
+		c.l.Printf("Error fetching wishlist: %v", err)
+		http.Error(rw, "Failed to fetch wishlist", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(items); err != nil {
+		log.Println("Error encoding wishlist response:", err)
+	}
+}
+
+// UpdateWishlistPlan sets the notes and target date for a wishlist peak
+// PUT /api/wishlist?peak_id=123
+func (c *ApiController) UpdateWishlistPlan(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle PUT Wishlist")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	peakID, err := strconv.ParseInt(r.URL.Query().Get("peak_id"), 10, 64)
+	if err != nil {
+		http.Error(rw, "Invalid peak_id", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Notes      *string    `json:"notes"`
+		TargetDate *time.Time `json:"target_date"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		c.l.Printf("Error decoding request body: %v", err)
+		http.Error(rw, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.summitFavouritesService.UpdatePlan(userID, peakID, req.Notes, req.TargetDate); err != nil {
+		if errors.Is(err, services.ErrFavouriteNotFound) {
+			http.Error(rw, err.Error(), http.StatusNotFound)
+			return
+		}
+		c.l.Printf("Error updating wishlist plan: %v", err)
+		http.Error(rw, "Failed to update wishlist", http.StatusInternalServerError)
+		return
+	}
+
+	c.GetWishlist(rw, r)
+}
+
+// ReorderWishlist sets the order of the user's wishlist
+// PUT /api/wishlist/order
+func (c *ApiController) ReorderWishlist(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle PUT WishlistOrder")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	var req struct {
+		PeakIDs []int64 `json:"peak_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		c.l.Printf("Error decoding request body: %v", err)
+		http.Error(rw, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.summitFavouritesService.Reorder(userID, req.PeakIDs); err != nil {
+		if errors.Is(err, services.ErrWishlistOrderInvalid) {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.l.Printf("Error reordering wishlist: %v", err)
+		http.Error(rw, "Failed to reorder wishlist", http.StatusInternalServerError)
+		return
+	}
+
+	c.GetWishlist(rw, r)
+}
+
+// GetWishlistSuggestions returns the nearest unclimbed peaks to a location
+// GET /api/wishlist/suggestions?lat=-33.9&lon=18.4&radius_km=25&limit=10
+func (c *ApiController) GetWishlistSuggestions(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle GET WishlistSuggestions")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	lat, errLat := strconv.ParseFloat(r.URL.Query().Get("lat"), 64)
+	lon, errLon := strconv.ParseFloat(r.URL.Query().Get("lon"), 64)
+	if errLat != nil || errLon != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		http.Error(rw, "Invalid lat/lon", http.StatusBadRequest)
+		return
+	}
+
+	radiusKm := 25.0
+	if v, err := strconv.ParseFloat(r.URL.Query().Get("radius_km"), 64); err == nil && v > 0 && v <= 200 {
+		radiusKm = v
+	}
+	limit := 10
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 && v <= 100 {
+		limit = v
+	}
+
+	suggestions, err := c.summitFavouritesService.GetSuggestions(userID, lat, lon, radiusKm*1000, limit)
+	if err != nil {
+		c.l.Printf("Error fetching wishlist suggestions: %v", err)
+		http.Error(rw, "Failed to fetch suggestions", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(suggestions); err != nil {
+		log.Println("Error encoding wishlist suggestions response:", err)
+	}
+}
+
+// GetWishlistClusters groups still-to-do wishlist peaks into single outings
+// GET /api/wishlist/clusters?max_km=5
+func (c *ApiController) GetWishlistClusters(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle GET WishlistClusters")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	maxKm := 5.0
+	if v, err := strconv.ParseFloat(r.URL.Query().Get("max_km"), 64); err == nil && v > 0 && v <= 50 {
+		maxKm = v
+	}
+
+	clusters, err := c.summitFavouritesService.GetClusters(userID, maxKm*1000)
+	if err != nil {
+		c.l.Printf("Error fetching wishlist clusters: %v", err)
+		http.Error(rw, "Failed to fetch clusters", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(clusters); err != nil {
+		log.Println("Error encoding wishlist clusters response:", err)
+	}
+}
+
+// GetPeakDetail returns a peak with community stats, the user's summits of it
+// and the challenges that include it
+// GET /api/peak?id=123
+func (c *ApiController) GetPeakDetail(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle GET PeakDetail")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	peakID, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		http.Error(rw, "Invalid id", http.StatusBadRequest)
+		return
+	}
+
+	detail, err := c.peakService.GetPeakDetail(peakID, userID)
+	if err != nil {
+		if errors.Is(err, services.ErrPeakNotFound) {
+			http.Error(rw, "Peak not found", http.StatusNotFound)
+			return
+		}
+		c.l.Printf("Error fetching peak detail: %v", err)
+		http.Error(rw, "Failed to fetch peak", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(detail); err != nil {
+		log.Println("Error encoding peak detail response:", err)
+	}
+}
+
+// SearchPeaks returns a page of peaks matching a fuzzy name search and filters
+// GET /api/peaks/search?q=table&region=&min_elevation=&max_elevation=&min_prominence=
+//
+//	&summited=false&summited_by=me|group&group_id=&lat=&lon=&radius_km=25
+//	&sort=relevance|name|elevation|prominence|distance&order=asc|desc&limit=50&offset=0
+func (c *ApiController) SearchPeaks(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle GET SearchPeaks")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+	query := r.URL.Query()
+
+	filter := models.PeakSearchFilter{
+		UserID:       userID,
+		Query:        strings.TrimSpace(query.Get("q")),
+		SummitedBy:   models.PeakSummitedBy(query.Get("summited_by")),
+		Sort:         models.PeakSearchSort(query.Get("sort")),
+		Descending:   query.Get("order") == "desc",
+		RadiusMeters: 25000,
+		Limit:        50,
+	}
+
+	floatParam := func(name string) (*float64, bool) {
+		v := query.Get(name)
+		if v == "" {
+			return nil, true
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			http.Error(rw, "Invalid "+name, http.StatusBadRequest)
+			return nil, false
+		}
+		return &f, true
+	}
+
+	var ok bool
+	if filter.MinElevation, ok = floatParam("min_elevation"); !ok {
+		return
+	}
+	if filter.MaxElevation, ok = floatParam("max_elevation"); !ok {
+		return
+	}
+	if filter.MinProminence, ok = floatParam("min_prominence"); !ok {
+		return
+	}
+	if filter.NearLat, ok = floatParam("lat"); !ok {
+		return
+	}
+	if filter.NearLon, ok = floatParam("lon"); !ok {
+		return
+	}
+	if (filter.NearLat == nil) != (filter.NearLon == nil) {
+		http.Error(rw, "lat and lon must be given together", http.StatusBadRequest)
+		return
+	}
+	radiusKm, ok := floatParam("radius_km")
+	if !ok {
+		return
+	}
+	if radiusKm != nil {
+		if *radiusKm <= 0 || *radiusKm > 500 {
+			http.Error(rw, "radius_km must be between 0 and 500", http.StatusBadRequest)
+			return
+		}
+		filter.RadiusMeters = *radiusKm * 1000
+	}
+
+	if region := query.Get("region"); region != "" {
+		filter.Region = &region
+	}
+	if summited := query.Get("summited"); summited != "" {
+		v, err := strconv.ParseBool(summited)
+		if err != nil {
+			http.Error(rw, "Invalid summited", http.StatusBadRequest)
+			return
+		}
+		filter.Summited = &v
+	}
+	if groupIDStr := query.Get("group_id"); groupIDStr != "" {
+		groupID, err := strconv.ParseInt(groupIDStr, 10, 64)
+		if err != nil {
+			http.Error(rw, "Invalid group_id", http.StatusBadRequest)
+			return
+		}
+		filter.GroupID = &groupID
+	}
+	if v, err := strconv.Atoi(query.Get("limit")); err == nil && v > 0 && v <= 200 {
+		filter.Limit = v
+	}
+	if v, err := strconv.Atoi(query.Get("offset")); err == nil && v >= 0 {
+		filter.Offset = v
+	}
+
+	page, err := c.peakService.SearchPeaks(filter)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrNotGroupMember):
+			http.Error(rw, err.Error(), http.StatusForbidden)
+		case errors.Is(err, services.ErrPeakSearchSortInvalid),
+			errors.Is(err, services.ErrPeakSearchNeedsGroup),
+			errors.Is(err, services.ErrPeakSearchNeedsNear):
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+		default:
+			c.l.Printf("Error searching peaks: %v", err)
+			http.Error(rw, "Failed to search peaks", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(page); err != nil {
+		log.Println("Error encoding peak search response:", err)
+	}
+}
+
+// GetFastestAscents returns the quickest times from activity start to a summit
+// GET /api/peak-fastest-ascents?peak_id=123&limit=50
+func (c *ApiController) GetFastestAscents(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle GET FastestAscents")
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	peakID, err := strconv.ParseInt(r.URL.Query().Get("peak_id"), 10, 64)
+	if err != nil {
+		http.Error(rw, "Invalid peak_id", http.StatusBadRequest)
+		return
+	}
+
+	entries, err := c.peakService.GetFastestAscents(peakID, userID, leaderboardLimit(r))
+	if err != nil {
+		c.l.Printf("Error fetching fastest ascents: %v", err)
+		http.Error(rw, "Failed to fetch fastest ascents", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(entries); err != nil {
+		log.Println("Error encoding fastest ascents response:", err)
+	}
+}
+
+// GetFastestTraverses returns the quickest times between two summits in one activity
+// GET /api/fastest-traverses?from_peak_id=123&to_peak_id=456&limit=50
+func (c *ApiController) GetFastestTraverses(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle GET FastestTraverses")
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	fromPeakID, err := strconv.ParseInt(r.URL.Query().Get("from_peak_id"), 10, 64)
+	if err != nil {
+		http.Error(rw, "Invalid from_peak_id", http.StatusBadRequest)
+		return
+	}
+	toPeakID, err := strconv.ParseInt(r.URL.Query().Get("to_peak_id"), 10, 64)
+	if err != nil {
+		http.Error(rw, "Invalid to_peak_id", http.StatusBadRequest)
+		return
+	}
+
+	entries, err := c.peakService.GetFastestTraverses(fromPeakID, toPeakID, userID, leaderboardLimit(r))
+	if err != nil {
+		c.l.Printf("Error fetching fastest traverses: %v", err)
+		http.Error(rw, "Failed to fetch fastest traverses", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(entries); err != nil {
+		log.Println("Error encoding fastest traverses response:", err)
+	}
+}
+
+const (
+	defaultLeaderboardLimit = 50
+	maxLeaderboardLimit     = 200
+)
+
+// leaderboardLimit reads the optional limit query param, defaulting to 50 and
+// capped at maxLeaderboardLimit
+func leaderboardLimit(r *http.Request) int {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		return defaultLeaderboardLimit
+	}
+	if limit > maxLeaderboardLimit {
+		return maxLeaderboardLimit
+	}
+	return limit
+}
+
+// GetStreaks returns the user's activity and summit streaks for their profile
+// GET /api/streaks?user_id=123 (defaults to the current user)
+func (c *ApiController) GetStreaks(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle GET Streaks")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+	if idStr := r.URL.Query().Get("user_id"); idStr != "" {
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			http.Error(rw, "Invalid user_id", http.StatusBadRequest)
+			return
+		}
+		userID = id
+	}
+
+	streaks, err := c.streakService.GetUserStreaks(userID)
+	if err != nil {
+		c.l.Printf("Error fetching streaks: %v", err)
+		http.Error(rw, "Failed to fetch streaks", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(streaks); err != nil {
+		log.Println("Error encoding streaks response:", err)
+	}
+}
+
+// GetActivityElevationProfile returns an elevation profile for one of the
+// user's activities, computed from its polyline and the local DEM tiles
+// GET /api/activities/elevation-profile?activity_id=123
+func (c *ApiController) GetActivityElevationProfile(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle GET ActivityElevationProfile")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	activityID, err := strconv.ParseInt(r.URL.Query().Get("activity_id"), 10, 64)
+	if err != nil {
+		http.Error(rw, "Invalid activity_id", http.StatusBadRequest)
+		return
+	}
+
+	profile, err := c.elevationService.GetActivityElevationProfile(activityID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrActivityNotFound):
+			http.Error(rw, err.Error(), http.StatusNotFound)
+		case errors.Is(err, services.ErrActivityHasNoRoute):
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, services.ErrElevationUnavailable):
+			http.Error(rw, err.Error(), http.StatusServiceUnavailable)
+		default:
+			c.l.Printf("Error computing elevation profile: %v", err)
+			http.Error(rw, "Failed to compute elevation profile", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(profile); err != nil {
+		log.Println("Error encoding elevation profile response:", err)
+	}
+}
@@ -0,0 +1,287 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"run-goals/dto"
+	"run-goals/meta"
+	"run-goals/models"
+	"run-goals/services"
+	"strconv"
+)
+
+type ChallengeSeriesControllerInterface interface {
+	CreateSeries(rw http.ResponseWriter, r *http.Request)
+	GetSeries(rw http.ResponseWriter, r *http.Request)
+	UpdateSeries(rw http.ResponseWriter, r *http.Request)
+	DeleteSeries(rw http.ResponseWriter, r *http.Request)
+	GetSeriesHistory(rw http.ResponseWriter, r *http.Request)
+	GetSeriesLeaderboard(rw http.ResponseWriter, r *http.Request)
+}
+
+type ChallengeSeriesController struct {
+	l                      *log.Logger
+	challengeSeriesService *services.ChallengeSeriesService
+}
+
+func NewChallengeSeriesController(
+	l *log.Logger,
+	challengeSeriesService *services.ChallengeSeriesService,
+) *ChallengeSeriesController {
+	return &ChallengeSeriesController{
+		l:                      l,
+		challengeSeriesService: challengeSeriesService,
+	}
+}
+
+func (c *ChallengeSeriesController) CreateSeries(rw http.ResponseWriter, r *http.Request) {
+	c.l.Printf("Handle POST challenge-series - creating new series")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	var request dto.CreateChallengeSeriesRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		c.l.Printf("Error unmarshalling data: %v", err)
+		http.Error(rw, "Error unmarshalling data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	series := models.ChallengeSeries{
+		Name:                  request.Name,
+		Description:           request.Description,
+		CreatedByGroupID:      request.CreatedByGroupID,
+		GoalType:              request.GoalType,
+		CompetitionMode:       request.CompetitionMode,
+		Visibility:            request.Visibility,
+		TargetValue:           request.TargetValue,
+		TargetSummitCount:     request.TargetSummitCount,
+		PeakIDs:               request.PeakIDs,
+		Region:                request.Region,
+		Difficulty:            request.Difficulty,
+		CompletionRule:        request.CompletionRule,
+		MaxWindowHours:        request.MaxWindowHours,
+		StreakType:            request.StreakType,
+		Recurrence:            request.Recurrence,
+		IntervalDays:          request.IntervalDays,
+		StartsAt:              request.StartsAt,
+		EndsAt:                request.EndsAt,
+		CarryOverParticipants: request.CarryOverParticipants,
+	}
+
+	created, err := c.challengeSeriesService.CreateSeries(userID, series)
+	if err != nil {
+		if errors.Is(err, services.ErrNotSeriesGroupAdmin) {
+			http.Error(rw, err.Error(), http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, services.ErrRecurrenceInvalid) || errors.Is(err, services.ErrSeriesStartRequired) ||
+			errors.Is(err, services.ErrCompletionRuleInvalid) || errors.Is(err, services.ErrStreakGoalInvalid) {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.l.Printf("Error creating challenge series: %v", err)
+		http.Error(rw, "Failed to create challenge series", http.StatusInternalServerError)
+		return
+	}
+
+	response := dto.CreateChallengeSeriesResponse{ID: created.ID}
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(response)
+}
+
+// GetSeries returns a single series when seriesId is given, otherwise every
+// series visible to the user.
+func (c *ChallengeSeriesController) GetSeries(rw http.ResponseWriter, r *http.Request) {
+	c.l.Printf("Handle GET challenge-series")
+
+	if r.URL.Query().Get("seriesId") != "" {
+		seriesID, err := c.getSeriesIDFromURL(r)
+		if err != nil {
+			http.Error(rw, "Invalid series ID", http.StatusBadRequest)
+			return
+		}
+
+		series, err := c.challengeSeriesService.GetSeries(seriesID)
+		if err != nil {
+			if errors.Is(err, services.ErrSeriesNotFound) {
+				http.Error(rw, "Series not found", http.StatusNotFound)
+				return
+			}
+			c.l.Printf("Error getting challenge series: %v", err)
+			http.Error(rw, "Failed to get challenge series", http.StatusInternalServerError)
+			return
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(series)
+		return
+	}
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	series, err := c.challengeSeriesService.GetSeriesForUser(userID)
+	if err != nil {
+		c.l.Printf("Error getting challenge series for user: %v", err)
+		http.Error(rw, "Failed to get challenge series", http.StatusInternalServerError)
+		return
+	}
+
+	response := dto.ChallengeSeriesListResponse{
+		Series: series,
+		Total:  len(series),
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(response)
+}
+
+func (c *ChallengeSeriesController) UpdateSeries(rw http.ResponseWriter, r *http.Request) {
+	c.l.Printf("Handle PUT challenge-series - updating series")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	var request dto.UpdateChallengeSeriesRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		c.l.Printf("Error unmarshalling data: %v", err)
+		http.Error(rw, "Error unmarshalling data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	series := models.ChallengeSeries{
+		Name:                  request.Name,
+		Description:           request.Description,
+		GoalType:              request.GoalType,
+		CompetitionMode:       request.CompetitionMode,
+		Visibility:            request.Visibility,
+		TargetValue:           request.TargetValue,
+		TargetSummitCount:     request.TargetSummitCount,
+		PeakIDs:               request.PeakIDs,
+		Region:                request.Region,
+		Difficulty:            request.Difficulty,
+		CompletionRule:        request.CompletionRule,
+		MaxWindowHours:        request.MaxWindowHours,
+		StreakType:            request.StreakType,
+		EndsAt:                request.EndsAt,
+		CarryOverParticipants: request.CarryOverParticipants,
+	}
+
+	err := c.challengeSeriesService.UpdateSeries(request.ID, userID, series, request.IsActive)
+	if err != nil {
+		if errors.Is(err, services.ErrSeriesNotFound) {
+			http.Error(rw, "Series not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, services.ErrNotSeriesOwner) {
+			http.Error(rw, "Not authorized", http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, services.ErrCompletionRuleInvalid) {
+			http.Error(rw, "Invalid completion rule", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, services.ErrStreakGoalInvalid) {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.l.Printf("Error updating challenge series: %v", err)
+		http.Error(rw, "Failed to update challenge series", http.StatusInternalServerError)
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+}
+
+func (c *ChallengeSeriesController) DeleteSeries(rw http.ResponseWriter, r *http.Request) {
+	c.l.Printf("Handle DELETE challenge-series - deleting series")
+
+	seriesID, err := c.getSeriesIDFromURL(r)
+	if err != nil {
+		http.Error(rw, "Invalid series ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	err = c.challengeSeriesService.DeleteSeries(seriesID, userID)
+	if err != nil {
+		if errors.Is(err, services.ErrSeriesNotFound) {
+			http.Error(rw, "Series not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, services.ErrNotSeriesOwner) {
+			http.Error(rw, "Not authorized", http.StatusForbidden)
+			return
+		}
+		c.l.Printf("Error deleting challenge series: %v", err)
+		http.Error(rw, "Failed to delete challenge series", http.StatusInternalServerError)
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (c *ChallengeSeriesController) GetSeriesHistory(rw http.ResponseWriter, r *http.Request) {
+	c.l.Printf("Handle GET challenge-series-history")
+
+	seriesID, err := c.getSeriesIDFromURL(r)
+	if err != nil {
+		http.Error(rw, "Invalid series ID", http.StatusBadRequest)
+		return
+	}
+
+	history, err := c.challengeSeriesService.GetSeriesHistory(seriesID)
+	if err != nil {
+		if errors.Is(err, services.ErrSeriesNotFound) {
+			http.Error(rw, "Series not found", http.StatusNotFound)
+			return
+		}
+		c.l.Printf("Error getting challenge series history: %v", err)
+		http.Error(rw, "Failed to get series history", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(history)
+}
+
+func (c *ChallengeSeriesController) GetSeriesLeaderboard(rw http.ResponseWriter, r *http.Request) {
+	c.l.Printf("Handle GET challenge-series-leaderboard")
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	seriesID, err := c.getSeriesIDFromURL(r)
+	if err != nil {
+		http.Error(rw, "Invalid series ID", http.StatusBadRequest)
+		return
+	}
+
+	leaderboard, err := c.challengeSeriesService.GetSeriesLeaderboard(seriesID, userID)
+	if err != nil {
+		if errors.Is(err, services.ErrSeriesNotFound) {
+			http.Error(rw, "Series not found", http.StatusNotFound)
+			return
+		}
+		c.l.Printf("Error getting challenge series leaderboard: %v", err)
+		http.Error(rw, "Failed to get series leaderboard", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(leaderboard)
+}
+
+// ==================== Helpers ====================
+
+func (c *ChallengeSeriesController) getSeriesIDFromURL(r *http.Request) (int64, error) {
+	idStr := r.URL.Query().Get("seriesId")
+	if idStr == "" {
+		idStr = r.URL.Query().Get("id")
+	}
+	if idStr == "" {
+		return 0, errors.New("missing series ID")
+	}
+	return strconv.ParseInt(idStr, 10, 64)
+}
@@ -94,10 +94,21 @@ func (c *ChallengesController) CreateChallenge(rw http.ResponseWriter, r *http.R
 		TargetSummitCount: request.TargetSummitCount,
 		Region:            request.Region,
 		Difficulty:        request.Difficulty,
+		CompletionRule:    request.CompletionRule,
+		MaxWindowHours:    request.MaxWindowHours,
+		StreakType:        request.StreakType,
 	}
 
 	created, err := c.challengeService.CreateChallenge(userID, challenge, request.PeakIDs)
 	if err != nil {
+		if errors.Is(err, services.ErrCompletionRuleInvalid) {
+			http.Error(rw, "Invalid completion rule", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, services.ErrStreakGoalInvalid) {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
 		c.l.Printf("Error creating challenge: %v", err)
 		http.Error(rw, "Failed to create challenge", http.StatusInternalServerError)
 		return
@@ -177,6 +188,9 @@ func (c *ChallengesController) UpdateChallenge(rw http.ResponseWriter, r *http.R
 		TargetSummitCount: request.TargetSummitCount,
 		Region:            request.Region,
 		Difficulty:        request.Difficulty,
+		CompletionRule:    request.CompletionRule,
+		MaxWindowHours:    request.MaxWindowHours,
+		StreakType:        request.StreakType,
 	}
 
 	err := c.challengeService.UpdateChallenge(request.ID, userID, challenge)
@@ -189,6 +203,14 @@ func (c *ChallengesController) UpdateChallenge(rw http.ResponseWriter, r *http.R
 			http.Error(rw, "Not authorized", http.StatusForbidden)
 			return
 		}
+		if errors.Is(err, services.ErrCompletionRuleInvalid) {
+			http.Error(rw, "Invalid completion rule", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, services.ErrStreakGoalInvalid) {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
 		c.l.Printf("Error updating challenge: %v", err)
 		http.Error(rw, "Failed to update challenge", http.StatusInternalServerError)
 		return
@@ -559,7 +581,8 @@ func (c *ChallengesController) GetLeaderboard(rw http.ResponseWriter, r *http.Re
 		return
 	}
 
-	leaderboard, err := c.challengeService.GetLeaderboard(challengeID)
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+	leaderboard, err := c.challengeService.GetLeaderboard(challengeID, userID)
 	if err != nil {
 		c.l.Printf("Error getting leaderboard: %v", err)
 		http.Error(rw, "Failed to get leaderboard", http.StatusInternalServerError)
@@ -601,7 +624,7 @@ func (c *ChallengesController) GetSummitLog(rw http.ResponseWriter, r *http.Requ
 }
 
 func (c *ChallengesController) RecordSummit(rw http.ResponseWriter, r *http.Request) {
-	c.l.Printf("Handle POST challenge-summit - manually recording summit")
+	c.l.Printf("Handle POST challenge-summit - crediting a recorded summit")
 
 	challengeID, err := c.getChallengeIDFromURL(r)
 	if err != nil {
@@ -619,12 +642,20 @@ func (c *ChallengesController) RecordSummit(rw http.ResponseWriter, r *http.Requ
 	}
 	defer r.Body.Close()
 
-	err = c.challengeService.RecordSummit(challengeID, userID, request.PeakID, request.ActivityID, request.SummitedAt)
+	err = c.challengeService.CreditRecordedSummit(challengeID, userID, request.PeakID, request.ActivityID)
 	if err != nil {
 		if errors.Is(err, services.ErrNotParticipant) {
 			http.Error(rw, "Not a participant", http.StatusForbidden)
 			return
 		}
+		if errors.Is(err, services.ErrChallengeNotFound) {
+			http.Error(rw, err.Error(), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, services.ErrSummitNotRecorded) {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
 		c.l.Printf("Error recording summit: %v", err)
 		http.Error(rw, "Failed to record summit", http.StatusInternalServerError)
 		return
@@ -739,7 +770,8 @@ func (c *ChallengesController) GetChallengeActivities(rw http.ResponseWriter, r
 		return
 	}
 
-	activities, err := c.challengeService.GetChallengeActivities(challengeID)
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+	activities, err := c.challengeService.GetChallengeActivities(challengeID, userID)
 	if err != nil {
 		c.l.Printf("Error getting challenge activities: %v", err)
 		http.Error(rw, "Failed to get activities", http.StatusInternalServerError)
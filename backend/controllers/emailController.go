@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"run-goals/daos"
+	"run-goals/meta"
+	"run-goals/models"
+	"run-goals/services"
+)
+
+type EmailController struct {
+	l            *log.Logger
+	emailService *services.EmailService
+}
+
+func NewEmailController(
+	l *log.Logger,
+	emailService *services.EmailService,
+) *EmailController {
+	return &EmailController{
+		l:            l,
+		emailService: emailService,
+	}
+}
+
+// GetEmailSettings returns the user's address, whether it's verified and
+// which emails they get.
+// GET /api/email
+func (c *EmailController) GetEmailSettings(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle GET EmailSettings")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	settings, err := c.emailService.GetSettings(userID)
+	if err != nil {
+		c.writeError(rw, err, "Failed to get email settings")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(settings)
+}
+
+// UpdateEmail sets the user's address and sends it a verification link.
+// PUT /api/email
+// Body: {"email": "someone@example.com"}
+func (c *EmailController) UpdateEmail(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle PUT Email")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	var request struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		c.l.Printf("Error unmarshalling data: %v", err)
+		http.Error(rw, "Error unmarshalling data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	settings, err := c.emailService.SetEmail(userID, request.Email)
+	if err != nil {
+		c.writeError(rw, err, "Failed to update email")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(settings)
+}
+
+// DeleteEmail forgets the user's address. No more emails are sent.
+// DELETE /api/email
+func (c *EmailController) DeleteEmail(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle DELETE Email")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	if err := c.emailService.RemoveEmail(userID); err != nil {
+		c.writeError(rw, err, "Failed to delete email")
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// UpdateEmailPreferences opts in to or out of kinds of email. Kinds left out
+// of the body are unchanged.
+// PUT /api/email/preferences
+// Body: {"weekly_digest": true, "challenge_ending": false}
+func (c *EmailController) UpdateEmailPreferences(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle PUT EmailPreferences")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	var request models.EmailPreferences
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		c.l.Printf("Error unmarshalling data: %v", err)
+		http.Error(rw, "Error unmarshalling data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	settings, err := c.emailService.UpdatePreferences(userID, request)
+	if err != nil {
+		c.writeError(rw, err, "Failed to update email preferences")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(settings)
+}
+
+// GetEmailHistory lists the emails most recently sent to the user.
+// GET /api/email/history
+func (c *EmailController) GetEmailHistory(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle GET EmailHistory")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	history, err := c.emailService.GetHistory(userID)
+	if err != nil {
+		c.writeError(rw, err, "Failed to get email history")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(history)
+}
+
+// VerifyEmail is the link in the verification email. No JWT, the token is
+// the credential.
+// GET /email/verify?token=...
+func (c *EmailController) VerifyEmail(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle GET VerifyEmail")
+
+	if r.Method != http.MethodGet {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := c.emailService.VerifyEmail(r.URL.Query().Get("token")); err != nil {
+		c.writeError(rw, err, "Failed to verify email")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(rw, "Your email address is confirmed. You can choose which emails you get in your settings.")
+}
+
+// Unsubscribe is the link at the bottom of every email. Without kind it
+// turns off every email. POST is accepted for mail clients' one-click
+// unsubscribe. No JWT, the token is the credential.
+// GET /email/unsubscribe?token=...&kind=weekly_digest
+func (c *EmailController) Unsubscribe(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle Unsubscribe")
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	kind := models.EmailKind(query.Get("kind"))
+	if err := c.emailService.Unsubscribe(query.Get("token"), kind); err != nil {
+		c.writeError(rw, err, "Failed to unsubscribe")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if kind == "" {
+		fmt.Fprintln(rw, "You've been unsubscribed from all Run Goals emails.")
+		return
+	}
+	fmt.Fprintln(rw, "You've been unsubscribed from these emails. You can turn them back on in your settings.")
+}
+
+func (c *EmailController) writeError(rw http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, daos.ErrUserNotFound):
+		http.Error(rw, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrEmailTokenInvalid):
+		http.Error(rw, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrEmailInvalid),
+		errors.Is(err, services.ErrEmailKindInvalid):
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrEmailDisabled):
+		http.Error(rw, err.Error(), http.StatusServiceUnavailable)
+	default:
+		c.l.Printf("%s: %v", message, err)
+		http.Error(rw, message, http.StatusInternalServerError)
+	}
+}
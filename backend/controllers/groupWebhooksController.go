@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"run-goals/meta"
+	"run-goals/models"
+	"run-goals/services"
+	"strconv"
+)
+
+type GroupWebhooksController struct {
+	l                   *log.Logger
+	groupWebhookService *services.GroupWebhookService
+}
+
+func NewGroupWebhooksController(
+	l *log.Logger,
+	groupWebhookService *services.GroupWebhookService,
+) *GroupWebhooksController {
+	return &GroupWebhooksController{
+		l:                   l,
+		groupWebhookService: groupWebhookService,
+	}
+}
+
+// GetGroupWebhooks lists the group's webhooks. Group admins only.
+// GET /api/group-webhooks?groupID=1
+func (c *GroupWebhooksController) GetGroupWebhooks(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle GET GroupWebhooks")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	groupID, err := strconv.ParseInt(r.URL.Query().Get("groupID"), 10, 64)
+	if err != nil {
+		http.Error(rw, "Invalid group ID", http.StatusBadRequest)
+		return
+	}
+
+	webhooks, err := c.groupWebhookService.GetWebhooks(userID, groupID)
+	if err != nil {
+		c.writeError(rw, err, "Failed to get group webhooks")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(webhooks)
+}
+
+// CreateGroupWebhook registers a webhook. The response includes the secret
+// requests are signed with. Group admins only.
+// POST /api/group-webhook
+// Body: {"group_id": 1, "url": "https://...", "format": "slack", "events": ["summit_detected"]}
+func (c *GroupWebhooksController) CreateGroupWebhook(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle POST GroupWebhook")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	var request models.GroupWebhook
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		c.l.Printf("Error unmarshalling data: %v", err)
+		http.Error(rw, "Error unmarshalling data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	webhook, err := c.groupWebhookService.CreateWebhook(userID, request)
+	if err != nil {
+		c.writeError(rw, err, "Failed to create group webhook")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusCreated)
+	json.NewEncoder(rw).Encode(webhook)
+}
+
+// UpdateGroupWebhook replaces the webhook's URL, format, events and whether
+// it's enabled. Group admins only.
+// PUT /api/group-webhook
+// Body: {"id": 1, "url": "https://...", "format": "discord", "events": ["challenge_completed"], "enabled": true}
+func (c *GroupWebhooksController) UpdateGroupWebhook(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle PUT GroupWebhook")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	var request models.GroupWebhook
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		c.l.Printf("Error unmarshalling data: %v", err)
+		http.Error(rw, "Error unmarshalling data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	webhook, err := c.groupWebhookService.UpdateWebhook(userID, request)
+	if err != nil {
+		c.writeError(rw, err, "Failed to update group webhook")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(webhook)
+}
+
+// DeleteGroupWebhook removes the webhook and its delivery log. Group admins
+// only.
+// DELETE /api/group-webhook?webhookID=1
+func (c *GroupWebhooksController) DeleteGroupWebhook(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle DELETE GroupWebhook")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	webhookID, err := strconv.ParseInt(r.URL.Query().Get("webhookID"), 10, 64)
+	if err != nil {
+		http.Error(rw, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.groupWebhookService.DeleteWebhook(userID, webhookID); err != nil {
+		c.writeError(rw, err, "Failed to delete group webhook")
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// GetGroupWebhookDeliveries returns the webhook's delivery log, newest
+// first. Group admins only.
+// GET /api/group-webhook-deliveries?webhookID=1&limit=50
+func (c *GroupWebhooksController) GetGroupWebhookDeliveries(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle GET GroupWebhookDeliveries")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+	query := r.URL.Query()
+
+	webhookID, err := strconv.ParseInt(query.Get("webhookID"), 10, 64)
+	if err != nil {
+		http.Error(rw, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+	limit, _ := strconv.Atoi(query.Get("limit"))
+
+	deliveries, err := c.groupWebhookService.GetDeliveries(userID, webhookID, limit)
+	if err != nil {
+		c.writeError(rw, err, "Failed to get group webhook deliveries")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(deliveries)
+}
+
+func (c *GroupWebhooksController) writeError(rw http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrGroupWebhookNotFound):
+		http.Error(rw, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrNotGroupAdmin):
+		http.Error(rw, err.Error(), http.StatusForbidden)
+	case errors.Is(err, services.ErrGroupWebhookURLInvalid),
+		errors.Is(err, services.ErrGroupWebhookFormatInvalid),
+		errors.Is(err, services.ErrGroupWebhookEventInvalid),
+		errors.Is(err, services.ErrGroupWebhookEventsRequired):
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrGroupWebhookLimitReached):
+		http.Error(rw, err.Error(), http.StatusConflict)
+	default:
+		c.l.Printf("%s: %v", message, err)
+		http.Error(rw, message, http.StatusInternalServerError)
+	}
+}
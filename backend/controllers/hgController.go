@@ -20,6 +20,7 @@ type HgController struct {
 	activityService *services.ActivityService
 	userDao         *daos.UserDao
 	activityFetcher *workflows.StravaActivityFetcher
+	privacyService  *services.PrivacyService
 }
 
 func NewHgController(
@@ -27,12 +28,14 @@ func NewHgController(
 	activityService *services.ActivityService,
 	userDao *daos.UserDao,
 	activityFetcher *workflows.StravaActivityFetcher,
+	privacyService *services.PrivacyService,
 ) *HgController {
 	return &HgController{
 		l:               l,
 		activityService: activityService,
 		userDao:         userDao,
 		activityFetcher: activityFetcher,
+		privacyService:  privacyService,
 	}
 }
 
@@ -55,6 +58,15 @@ func (c *HgController) ListHikeGangActivities(rw http.ResponseWriter, r *http.Re
 		return
 	}
 
+	// The feed is public, so only activities the owner shares with everyone
+	// are listed, with routes trimmed to their privacy zones
+	activities, err = c.privacyService.PublicFeedActivities(u, activities)
+	if err != nil {
+		c.l.Println("Error applying privacy settings", err)
+		http.Error(rw, "Failed to fetch activities", http.StatusInternalServerError)
+		return
+	}
+
 	// Filter out activities that don't have #hg in the title
 	var hgActivities []models.Activity
 	for _, activity := range activities {
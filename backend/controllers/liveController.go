@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"run-goals/meta"
+	"run-goals/models"
+	"run-goals/services"
+	"strconv"
+	"time"
+)
+
+const (
+	// liveCoalesceWindow batches bursts of events, e.g. a sync refreshing
+	// every participant, into one update of each type
+	liveCoalesceWindow = time.Second
+	// liveHeartbeatInterval keeps idle streams from being closed by proxies
+	liveHeartbeatInterval = 25 * time.Second
+	liveRetryMillis       = 5000
+)
+
+type LiveController struct {
+	l           *log.Logger
+	liveService *services.LiveService
+}
+
+func NewLiveController(
+	l *log.Logger,
+	liveService *services.LiveService,
+) *LiveController {
+	return &LiveController{
+		l:           l,
+		liveService: liveService,
+	}
+}
+
+// Stream sends a challenge's or group's live updates as Server-Sent Events.
+// Each event is named after its type. An update of each type is sent on
+// connect so nothing between loading the page and subscribing is missed.
+// GET /live?challengeID=1 or /live?groupID=1
+func (c *LiveController) Stream(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle GET LiveStream")
+
+	if r.Method != http.MethodGet {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+	query := r.URL.Query()
+
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		http.Error(rw, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	var initial models.LiveEvent
+	var events <-chan models.LiveEvent
+	var unsubscribe func()
+	var err error
+	switch {
+	case query.Get("challengeID") != "":
+		challengeID, parseErr := strconv.ParseInt(query.Get("challengeID"), 10, 64)
+		if parseErr != nil {
+			http.Error(rw, "Invalid challenge ID", http.StatusBadRequest)
+			return
+		}
+		initial.ChallengeID = &challengeID
+		events, unsubscribe, err = c.liveService.SubscribeChallenge(challengeID)
+	case query.Get("groupID") != "":
+		groupID, parseErr := strconv.ParseInt(query.Get("groupID"), 10, 64)
+		if parseErr != nil {
+			http.Error(rw, "Invalid group ID", http.StatusBadRequest)
+			return
+		}
+		initial.GroupID = &groupID
+		events, unsubscribe, err = c.liveService.SubscribeGroup(userID, groupID)
+	default:
+		http.Error(rw, "challengeID or groupID is required", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		c.writeError(rw, err, "Failed to subscribe to live updates")
+		return
+	}
+	defer unsubscribe()
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.Header().Set("Connection", "keep-alive")
+	rw.Header().Set("X-Accel-Buffering", "no")
+	rw.WriteHeader(http.StatusOK)
+	fmt.Fprintf(rw, "retry: %d\n\n", liveRetryMillis)
+
+	pending := map[models.LiveEventType]bool{}
+	for _, t := range models.LiveEventTypes {
+		pending[t] = true
+	}
+	if !c.send(rw, userID, initial, pending) {
+		return
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(liveHeartbeatInterval)
+	defer heartbeat.Stop()
+	var flush <-chan time.Time
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				// Broker closed, e.g. on shutdown. The client reconnects.
+				return
+			}
+			pending[event.Type] = true
+			if flush == nil {
+				flush = time.After(liveCoalesceWindow)
+			}
+		case <-flush:
+			flush = nil
+			if !c.send(rw, userID, initial, pending) {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(rw, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// send writes an update for each pending type and clears them. It returns
+// false once the client has gone.
+func (c *LiveController) send(rw http.ResponseWriter, userID int64, topic models.LiveEvent, pending map[models.LiveEventType]bool) bool {
+	for _, t := range models.LiveEventTypes {
+		if !pending[t] {
+			continue
+		}
+		delete(pending, t)
+
+		event := topic
+		event.Type = t
+		update, err := c.liveService.Update(userID, event)
+		if err != nil {
+			c.l.Printf("Failed to build live %s update for %s: %v", t, event.Topic(), err)
+			continue
+		}
+		data, err := json.Marshal(update)
+		if err != nil {
+			c.l.Printf("Error encoding live update: %v", err)
+			continue
+		}
+		if _, err := fmt.Fprintf(rw, "event: %s\ndata: %s\n\n", t, data); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *LiveController) writeError(rw http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrChallengeNotFound):
+		http.Error(rw, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrNotGroupMember):
+		http.Error(rw, err.Error(), http.StatusForbidden)
+	default:
+		c.l.Printf("%s: %v", message, err)
+		http.Error(rw, message, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,256 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"run-goals/meta"
+	"run-goals/models"
+	"run-goals/services"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Map responses are per user, so only the browser may cache them. Revalidation
+// after that is cheap because of the ETag.
+const mapCacheControl = "private, max-age=300"
+
+type MapController struct {
+	l              *log.Logger
+	mapService     *services.MapService
+	heatmapService *services.HeatmapService
+}
+
+func NewMapController(
+	l *log.Logger,
+	mapService *services.MapService,
+	heatmapService *services.HeatmapService,
+) *MapController {
+	return &MapController{
+		l:              l,
+		mapService:     mapService,
+		heatmapService: heatmapService,
+	}
+}
+
+// GetPeaksGeoJSON returns peaks in a bbox as GeoJSON, marked summited or not.
+// GET /api/geojson/peaks?bbox=minLon,minLat,maxLon,maxLat&zoom=
+func (c *MapController) GetPeaksGeoJSON(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle GET PeaksGeoJSON")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+	bounds, zoom, ok := c.parseView(rw, r)
+	if !ok {
+		return
+	}
+
+	collection, err := c.mapService.PeaksGeoJSON(userID, bounds, zoom)
+	if err != nil {
+		c.l.Printf("Error building peaks GeoJSON: %v", err)
+		http.Error(rw, "Failed to fetch peaks", http.StatusInternalServerError)
+		return
+	}
+	c.writeGeoJSON(rw, r, collection)
+}
+
+// GetSummitsGeoJSON returns the user's summited peaks in a bbox as GeoJSON.
+// GET /api/geojson/summits?bbox=minLon,minLat,maxLon,maxLat
+func (c *MapController) GetSummitsGeoJSON(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle GET SummitsGeoJSON")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+	bounds, _, ok := c.parseView(rw, r)
+	if !ok {
+		return
+	}
+
+	collection, err := c.mapService.SummitsGeoJSON(userID, bounds)
+	if err != nil {
+		c.l.Printf("Error building summits GeoJSON: %v", err)
+		http.Error(rw, "Failed to fetch summits", http.StatusInternalServerError)
+		return
+	}
+	c.writeGeoJSON(rw, r, collection)
+}
+
+// GetRoutesGeoJSON returns the user's activity routes crossing a bbox as
+// GeoJSON, simplified for the zoom.
+// GET /api/geojson/routes?bbox=minLon,minLat,maxLon,maxLat&zoom=
+func (c *MapController) GetRoutesGeoJSON(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle GET RoutesGeoJSON")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+	bounds, zoom, ok := c.parseView(rw, r)
+	if !ok {
+		return
+	}
+
+	collection, err := c.mapService.RoutesGeoJSON(userID, bounds, zoom)
+	if err != nil {
+		c.l.Printf("Error building routes GeoJSON: %v", err)
+		http.Error(rw, "Failed to fetch routes", http.StatusInternalServerError)
+		return
+	}
+	c.writeGeoJSON(rw, r, collection)
+}
+
+// GetTile returns the user's map as a Mapbox Vector Tile.
+// GET /tiles/{z}/{x}/{y}, with an optional .mvt or .pbf extension
+func (c *MapController) GetTile(rw http.ResponseWriter, r *http.Request) {
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	path := strings.TrimPrefix(r.URL.Path, "/tiles/")
+	path = strings.TrimSuffix(strings.TrimSuffix(path, ".mvt"), ".pbf")
+	coords, ok := parseTilePath(path)
+	if !ok {
+		http.Error(rw, "Tile path must be /tiles/{z}/{x}/{y}", http.StatusNotFound)
+		return
+	}
+
+	tile, err := c.mapService.Tile(userID, coords[0], coords[1], coords[2])
+	if err != nil {
+		if errors.Is(err, services.ErrMapTileInvalid) {
+			http.Error(rw, err.Error(), http.StatusNotFound)
+			return
+		}
+		c.l.Printf("Error rendering tile %s: %v", path, err)
+		http.Error(rw, "Failed to render tile", http.StatusInternalServerError)
+		return
+	}
+	c.writeCached(rw, r, "application/vnd.mapbox-vector-tile", tile)
+}
+
+// GetHeatmapTile returns a PNG heatmap of the user's routes, or of a group's
+// when group_id is given. Optional filters: start_date and end_date
+// (YYYY-MM-DD, end exclusive) and type, a comma-separated list of activity types.
+// GET /tiles/heatmap/{z}/{x}/{y}.png
+func (c *MapController) GetHeatmapTile(rw http.ResponseWriter, r *http.Request) {
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	path := strings.TrimPrefix(r.URL.Path, "/tiles/heatmap/")
+	coords, ok := parseTilePath(strings.TrimSuffix(path, ".png"))
+	if !ok {
+		http.Error(rw, "Tile path must be /tiles/heatmap/{z}/{x}/{y}.png", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := models.HeatmapFilter{UserID: userID}
+	if v := query.Get("group_id"); v != "" {
+		groupID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(rw, "Invalid group_id", http.StatusBadRequest)
+			return
+		}
+		filter.GroupID = &groupID
+	}
+	for param, target := range map[string]**time.Time{"start_date": &filter.StartDate, "end_date": &filter.EndDate} {
+		v := query.Get(param)
+		if v == "" {
+			continue
+		}
+		date, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			http.Error(rw, "Invalid "+param+", expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		*target = &date
+	}
+	if v := query.Get("type"); v != "" {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				filter.Types = append(filter.Types, t)
+			}
+		}
+	}
+
+	tile, err := c.heatmapService.Tile(filter, coords[0], coords[1], coords[2])
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrMapTileInvalid):
+			http.Error(rw, err.Error(), http.StatusNotFound)
+		case errors.Is(err, services.ErrHeatmapDateRangeInvalid):
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, services.ErrNotGroupMember):
+			http.Error(rw, err.Error(), http.StatusForbidden)
+		default:
+			c.l.Printf("Error rendering heatmap tile %s: %v", path, err)
+			http.Error(rw, "Failed to render heatmap tile", http.StatusInternalServerError)
+		}
+		return
+	}
+	c.writeCached(rw, r, "image/png", tile)
+}
+
+// parseTilePath reads "{z}/{x}/{y}"
+func parseTilePath(path string) ([3]int, bool) {
+	var coords [3]int
+	parts := strings.Split(path, "/")
+	if len(parts) != 3 {
+		return coords, false
+	}
+	for i, part := range parts {
+		v, err := strconv.Atoi(part)
+		if err != nil {
+			return coords, false
+		}
+		coords[i] = v
+	}
+	return coords, true
+}
+
+func (c *MapController) parseView(rw http.ResponseWriter, r *http.Request) (bounds models.BoundingBox, zoom int, ok bool) {
+	query := r.URL.Query()
+	bounds, err := services.ParseBoundingBox(query.Get("bbox"))
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return bounds, 0, false
+	}
+	zoom, err = services.ParseZoom(query.Get("zoom"))
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return bounds, 0, false
+	}
+	return bounds, zoom, true
+}
+
+func (c *MapController) writeGeoJSON(rw http.ResponseWriter, r *http.Request, collection interface{}) {
+	body, err := json.Marshal(collection)
+	if err != nil {
+		c.l.Printf("Error encoding GeoJSON: %v", err)
+		http.Error(rw, "Failed to encode GeoJSON", http.StatusInternalServerError)
+		return
+	}
+	c.writeCached(rw, r, "application/geo+json", body)
+}
+
+// writeCached writes a per-user map response with an ETag of its content,
+// answering 304 when the client already has it
+func (c *MapController) writeCached(rw http.ResponseWriter, r *http.Request, contentType string, body []byte) {
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	rw.Header().Set("Cache-Control", mapCacheControl)
+	rw.Header().Set("Vary", "Authorization")
+	rw.Header().Set("ETag", etag)
+
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == etag || candidate == "*" {
+				rw.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+	}
+
+	rw.Header().Set("Content-Type", contentType)
+	rw.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	if _, err := rw.Write(body); err != nil {
+		c.l.Printf("Error writing map response: %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"run-goals/meta"
+	"run-goals/models"
+	"run-goals/services"
+	"strconv"
+)
+
+type NotificationsController struct {
+	l                   *log.Logger
+	notificationService *services.NotificationService
+}
+
+func NewNotificationsController(
+	l *log.Logger,
+	notificationService *services.NotificationService,
+) *NotificationsController {
+	return &NotificationsController{
+		l:                   l,
+		notificationService: notificationService,
+	}
+}
+
+// GetNotifications returns a page of the user's inbox, newest first, with
+// their unread count.
+// GET /api/notifications?unread=true&limit=50&before=123
+func (c *NotificationsController) GetNotifications(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle GET Notifications")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+	query := r.URL.Query()
+
+	unreadOnly := query.Get("unread") == "true"
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	var before *int64
+	if v := query.Get("before"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(rw, "Invalid before", http.StatusBadRequest)
+			return
+		}
+		before = &id
+	}
+
+	page, err := c.notificationService.GetNotifications(userID, unreadOnly, before, limit)
+	if err != nil {
+		c.writeError(rw, err, "Failed to get notifications")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(page)
+}
+
+// MarkNotificationsRead marks some of the user's notifications as read.
+// POST /api/notifications/read
+// Body: {"ids": [1, 2, 3]}
+func (c *NotificationsController) MarkNotificationsRead(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle POST MarkNotificationsRead")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	var request struct {
+		IDs []int64 `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		c.l.Printf("Error unmarshalling data: %v", err)
+		http.Error(rw, "Error unmarshalling data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	updated, err := c.notificationService.MarkRead(userID, request.IDs)
+	if err != nil {
+		c.writeError(rw, err, "Failed to mark notifications read")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(map[string]int64{"updated": updated})
+}
+
+// MarkAllNotificationsRead marks every notification in the user's inbox as
+// read.
+// POST /api/notifications/read-all
+func (c *NotificationsController) MarkAllNotificationsRead(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle POST MarkAllNotificationsRead")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	updated, err := c.notificationService.MarkAllRead(userID)
+	if err != nil {
+		c.writeError(rw, err, "Failed to mark notifications read")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(map[string]int64{"updated": updated})
+}
+
+// GetNotificationPreferences returns whether each notification type is on.
+// GET /api/notifications/preferences
+func (c *NotificationsController) GetNotificationPreferences(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle GET NotificationPreferences")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	preferences, err := c.notificationService.GetPreferences(userID)
+	if err != nil {
+		c.writeError(rw, err, "Failed to get notification preferences")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(preferences)
+}
+
+// UpdateNotificationPreferences turns notification types on or off. Types
+// left out of the body are unchanged.
+// PUT /api/notifications/preferences
+// Body: {"challenge_overtaken": false}
+func (c *NotificationsController) UpdateNotificationPreferences(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle PUT NotificationPreferences")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	var request models.NotificationPreferences
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		c.l.Printf("Error unmarshalling data: %v", err)
+		http.Error(rw, "Error unmarshalling data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	preferences, err := c.notificationService.UpdatePreferences(userID, request)
+	if err != nil {
+		c.writeError(rw, err, "Failed to update notification preferences")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(preferences)
+}
+
+func (c *NotificationsController) writeError(rw http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrNotificationIDsRequired),
+		errors.Is(err, services.ErrNotificationTypeInvalid):
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+	default:
+		c.l.Printf("%s: %v", message, err)
+		http.Error(rw, message, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,270 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"run-goals/dto"
+	"run-goals/meta"
+	"run-goals/models"
+	"run-goals/services"
+	"strconv"
+)
+
+type PeakListsControllerInterface interface {
+	GetPeakLists(rw http.ResponseWriter, r *http.Request)
+	CreatePeakList(rw http.ResponseWriter, r *http.Request)
+	GetPeakList(rw http.ResponseWriter, r *http.Request)
+	UpdatePeakList(rw http.ResponseWriter, r *http.Request)
+	DeletePeakList(rw http.ResponseWriter, r *http.Request)
+	SetPeakListPeaks(rw http.ResponseWriter, r *http.Request)
+	CreateChallengeFromList(rw http.ResponseWriter, r *http.Request)
+}
+
+type PeakListsController struct {
+	l               *log.Logger
+	peakListService *services.PeakListService
+}
+
+func NewPeakListsController(
+	l *log.Logger,
+	peakListService *services.PeakListService,
+) *PeakListsController {
+	return &PeakListsController{
+		l:               l,
+		peakListService: peakListService,
+	}
+}
+
+// GetPeakLists returns the lists visible to the user with their progress.
+// scope can be curated, public or mine.
+func (c *PeakListsController) GetPeakLists(rw http.ResponseWriter, r *http.Request) {
+	c.l.Printf("Handle GET peak-lists")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	lists, err := c.peakListService.GetLists(userID, r.URL.Query().Get("scope"))
+	if err != nil {
+		if errors.Is(err, services.ErrPeakListScopeInvalid) {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.l.Printf("Error getting peak lists: %v", err)
+		http.Error(rw, "Failed to get peak lists", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(lists)
+}
+
+func (c *PeakListsController) CreatePeakList(rw http.ResponseWriter, r *http.Request) {
+	c.l.Printf("Handle POST peak-lists - creating new list")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	var request dto.CreatePeakListRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		c.l.Printf("Error unmarshalling data: %v", err)
+		http.Error(rw, "Error unmarshalling data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	list := models.PeakList{
+		Name:        request.Name,
+		Description: request.Description,
+		Region:      request.Region,
+		Visibility:  request.Visibility,
+	}
+
+	created, err := c.peakListService.CreateList(userID, list, request.PeakIDs)
+	if err != nil {
+		c.writeError(rw, err, "Failed to create peak list")
+		return
+	}
+
+	response := dto.CreatePeakListResponse{ID: created.ID}
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(response)
+}
+
+// GetPeakList returns a list with its peaks and the user's progress
+func (c *PeakListsController) GetPeakList(rw http.ResponseWriter, r *http.Request) {
+	c.l.Printf("Handle GET peak-list")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	listID, err := c.getListIDFromURL(r)
+	if err != nil {
+		http.Error(rw, "Invalid list ID", http.StatusBadRequest)
+		return
+	}
+
+	detail, err := c.peakListService.GetList(listID, userID)
+	if err != nil {
+		c.writeError(rw, err, "Failed to get peak list")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(detail)
+}
+
+func (c *PeakListsController) UpdatePeakList(rw http.ResponseWriter, r *http.Request) {
+	c.l.Printf("Handle PUT peak-list - updating list")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	listID, err := c.getListIDFromURL(r)
+	if err != nil {
+		http.Error(rw, "Invalid list ID", http.StatusBadRequest)
+		return
+	}
+
+	var request dto.UpdatePeakListRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		c.l.Printf("Error unmarshalling data: %v", err)
+		http.Error(rw, "Error unmarshalling data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	updates := models.PeakList{
+		Name:        request.Name,
+		Description: request.Description,
+		Region:      request.Region,
+		Visibility:  request.Visibility,
+	}
+
+	if err := c.peakListService.UpdateList(listID, userID, updates); err != nil {
+		c.writeError(rw, err, "Failed to update peak list")
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (c *PeakListsController) DeletePeakList(rw http.ResponseWriter, r *http.Request) {
+	c.l.Printf("Handle DELETE peak-list")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	listID, err := c.getListIDFromURL(r)
+	if err != nil {
+		http.Error(rw, "Invalid list ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.peakListService.DeleteList(listID, userID); err != nil {
+		c.writeError(rw, err, "Failed to delete peak list")
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (c *PeakListsController) SetPeakListPeaks(rw http.ResponseWriter, r *http.Request) {
+	c.l.Printf("Handle PUT peak-list-peaks")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	listID, err := c.getListIDFromURL(r)
+	if err != nil {
+		http.Error(rw, "Invalid list ID", http.StatusBadRequest)
+		return
+	}
+
+	var request dto.SetPeakListPeaksRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		c.l.Printf("Error unmarshalling data: %v", err)
+		http.Error(rw, "Error unmarshalling data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	if err := c.peakListService.SetListPeaks(listID, userID, request.PeakIDs); err != nil {
+		c.writeError(rw, err, "Failed to set peak list peaks")
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// CreateChallengeFromList creates a specific_summits challenge from a list's
+// peaks in one call
+func (c *PeakListsController) CreateChallengeFromList(rw http.ResponseWriter, r *http.Request) {
+	c.l.Printf("Handle POST peak-list-challenge")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	listID, err := c.getListIDFromURL(r)
+	if err != nil {
+		http.Error(rw, "Invalid list ID", http.StatusBadRequest)
+		return
+	}
+
+	var request dto.CreateChallengeFromListRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		c.l.Printf("Error unmarshalling data: %v", err)
+		http.Error(rw, "Error unmarshalling data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	challenge := models.Challenge{
+		Description:     request.Description,
+		CompetitionMode: request.CompetitionMode,
+		Visibility:      request.Visibility,
+		StartDate:       request.StartDate,
+		Deadline:        request.Deadline,
+		Difficulty:      request.Difficulty,
+		CompletionRule:  request.CompletionRule,
+		MaxWindowHours:  request.MaxWindowHours,
+	}
+	if request.Name != nil {
+		challenge.Name = *request.Name
+	}
+
+	created, err := c.peakListService.CreateChallengeFromList(listID, userID, challenge)
+	if err != nil {
+		if errors.Is(err, services.ErrCompletionRuleInvalid) {
+			http.Error(rw, "Invalid completion rule", http.StatusBadRequest)
+			return
+		}
+		c.writeError(rw, err, "Failed to create challenge from peak list")
+		return
+	}
+
+	response := dto.CreateChallengeResponse{ID: created.ID}
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(response)
+}
+
+func (c *PeakListsController) writeError(rw http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrPeakListNotFound):
+		http.Error(rw, "Peak list not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrNotPeakListOwner):
+		http.Error(rw, "Not authorized", http.StatusForbidden)
+	case errors.Is(err, services.ErrPeakListNameRequired),
+		errors.Is(err, services.ErrPeakListVisibilityInvalid),
+		errors.Is(err, services.ErrPeakListEmpty):
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+	default:
+		c.l.Printf("%s: %v", message, err)
+		http.Error(rw, message, http.StatusInternalServerError)
+	}
+}
+
+func (c *PeakListsController) getListIDFromURL(r *http.Request) (int64, error) {
+	idStr := r.URL.Query().Get("listId")
+	if idStr == "" {
+		idStr = r.URL.Query().Get("id")
+	}
+	if idStr == "" {
+		return 0, errors.New("missing list ID")
+	}
+	return strconv.ParseInt(idStr, 10, 64)
+}
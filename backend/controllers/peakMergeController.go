@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"run-goals/models"
+	"run-goals/services"
+	"strconv"
+)
+
+// PeakMergeController serves the admin review of duplicate peaks. Every
+// endpoint is unauthenticated but requires the admin_key query param.
+type PeakMergeController struct {
+	l                *log.Logger
+	peakMergeService *services.PeakMergeService
+}
+
+func NewPeakMergeController(
+	l *log.Logger,
+	peakMergeService *services.PeakMergeService,
+) *PeakMergeController {
+	return &PeakMergeController{
+		l:                l,
+		peakMergeService: peakMergeService,
+	}
+}
+
+// ScanDuplicates clusters nearby peaks into merge proposals.
+// POST /admin/peak-merges/scan?admin_key=xxx&distance_meters=50
+func (c *PeakMergeController) ScanDuplicates(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !checkAdminKey(c.l, rw, r, "peak merge") {
+		return
+	}
+
+	distance := c.peakMergeService.DuplicateDistance()
+	if distanceStr := r.URL.Query().Get("distance_meters"); distanceStr != "" {
+		parsed, err := strconv.ParseFloat(distanceStr, 64)
+		if err != nil {
+			http.Error(rw, "Invalid distance_meters", http.StatusBadRequest)
+			return
+		}
+		distance = parsed
+	}
+
+	result, err := c.peakMergeService.Scan(distance)
+	if err != nil {
+		c.writeError(rw, err, "Failed to scan for duplicate peaks")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(result)
+}
+
+// GetProposals lists merge proposals with their peaks.
+// GET /admin/peak-merges?admin_key=xxx&status=pending
+func (c *PeakMergeController) GetProposals(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !checkAdminKey(c.l, rw, r, "peak merge") {
+		return
+	}
+
+	status := models.PeakMergeStatus(r.URL.Query().Get("status"))
+	if status == "" {
+		status = models.PeakMergeStatusPending
+	}
+
+	proposals, err := c.peakMergeService.GetProposals(status)
+	if err != nil {
+		c.writeError(rw, err, "Failed to get peak merge proposals")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(proposals)
+}
+
+// ConfirmProposal merges a proposal's peaks. canonical_peak_id overrides the
+// proposed canonical peak.
+// POST /admin/peak-merges/confirm?admin_key=xxx&id=1&canonical_peak_id=2
+func (c *PeakMergeController) ConfirmProposal(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !checkAdminKey(c.l, rw, r, "peak merge") {
+		return
+	}
+
+	proposalID, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		http.Error(rw, "Invalid proposal ID", http.StatusBadRequest)
+		return
+	}
+
+	var canonicalPeakID *int64
+	if canonicalStr := r.URL.Query().Get("canonical_peak_id"); canonicalStr != "" {
+		parsed, err := strconv.ParseInt(canonicalStr, 10, 64)
+		if err != nil {
+			http.Error(rw, "Invalid canonical_peak_id", http.StatusBadRequest)
+			return
+		}
+		canonicalPeakID = &parsed
+	}
+
+	result, err := c.peakMergeService.ConfirmProposal(proposalID, canonicalPeakID)
+	if err != nil {
+		c.writeError(rw, err, "Failed to merge peaks")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(result)
+}
+
+// RejectProposal marks a proposal as not duplicates so later scans skip it.
+// POST /admin/peak-merges/reject?admin_key=xxx&id=1
+func (c *PeakMergeController) RejectProposal(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !checkAdminKey(c.l, rw, r, "peak merge") {
+		return
+	}
+
+	proposalID, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		http.Error(rw, "Invalid proposal ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.peakMergeService.RejectProposal(proposalID); err != nil {
+		c.writeError(rw, err, "Failed to reject peak merge proposal")
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (c *PeakMergeController) writeError(rw http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrPeakMergeProposalNotFound):
+		http.Error(rw, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrPeakMergeProposalResolved),
+		errors.Is(err, services.ErrPeakMergeProposalStale):
+		http.Error(rw, err.Error(), http.StatusConflict)
+	case errors.Is(err, services.ErrPeakMergeCanonicalInvalid),
+		errors.Is(err, services.ErrPeakMergeStatusInvalid),
+		errors.Is(err, services.ErrPeakMergeDistanceInvalid):
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+	default:
+		c.l.Printf("%s: %v", message, err)
+		http.Error(rw, message, http.StatusInternalServerError)
+	}
+}
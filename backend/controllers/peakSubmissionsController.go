@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"run-goals/daos"
+	"run-goals/dto"
+	"run-goals/meta"
+	"run-goals/models"
+	"run-goals/services"
+	"strconv"
+)
+
+type PeakSubmissionsController struct {
+	l                     *log.Logger
+	peakSubmissionService *services.PeakSubmissionService
+}
+
+func NewPeakSubmissionsController(
+	l *log.Logger,
+	peakSubmissionService *services.PeakSubmissionService,
+) *PeakSubmissionsController {
+	return &PeakSubmissionsController{
+		l:                     l,
+		peakSubmissionService: peakSubmissionService,
+	}
+}
+
+// SubmitPeak queues a new peak or a correction to an existing one.
+// POST /api/peak-submissions
+func (c *PeakSubmissionsController) SubmitPeak(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle POST PeakSubmission")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	var request dto.CreatePeakSubmissionRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		c.l.Printf("Error unmarshalling data: %v", err)
+		http.Error(rw, "Error unmarshalling data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	submission := models.PeakSubmission{
+		Kind:            request.Kind,
+		PeakID:          request.PeakID,
+		Name:            request.Name,
+		Latitude:        request.Latitude,
+		Longitude:       request.Longitude,
+		ElevationMeters: request.ElevationMeters,
+		Note:            request.Note,
+	}
+
+	id, err := c.peakSubmissionService.Submit(userID, submission)
+	if err != nil {
+		c.writeError(rw, err, "Failed to submit peak")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(dto.CreatePeakSubmissionResponse{ID: *id})
+}
+
+// GetMySubmissions lists the user's submissions and their review status.
+// GET /api/peak-submissions
+func (c *PeakSubmissionsController) GetMySubmissions(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle GET PeakSubmissions")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	submissions, err := c.peakSubmissionService.GetMySubmissions(userID)
+	if err != nil {
+		c.writeError(rw, err, "Failed to get peak submissions")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(submissions)
+}
+
+// GetQueue lists submissions for moderation. Admins only.
+// GET /api/peak-submissions/queue?status=pending
+func (c *PeakSubmissionsController) GetQueue(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle GET PeakSubmissions queue")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	status := models.PeakSubmissionStatus(r.URL.Query().Get("status"))
+	if status == "" {
+		status = models.PeakSubmissionStatusPending
+	}
+
+	queue, err := c.peakSubmissionService.GetQueue(userID, status)
+	if err != nil {
+		c.writeError(rw, err, "Failed to get peak submission queue")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(queue)
+}
+
+// ApproveSubmission applies a submission. Admins only.
+// POST /api/peak-submissions/approve?id=123
+func (c *PeakSubmissionsController) ApproveSubmission(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle POST PeakSubmission approve")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	submissionID, request, ok := c.readReview(rw, r)
+	if !ok {
+		return
+	}
+
+	peakID, err := c.peakSubmissionService.Approve(submissionID, userID, request.ReviewerNote)
+	if err != nil {
+		c.writeError(rw, err, "Failed to approve peak submission")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(dto.ApprovePeakSubmissionResponse{PeakID: peakID})
+}
+
+// RejectSubmission closes a submission without applying it. Admins only.
+// POST /api/peak-submissions/reject?id=123
+func (c *PeakSubmissionsController) RejectSubmission(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle POST PeakSubmission reject")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	submissionID, request, ok := c.readReview(rw, r)
+	if !ok {
+		return
+	}
+
+	if err := c.peakSubmissionService.Reject(submissionID, userID, request.ReviewerNote); err != nil {
+		c.writeError(rw, err, "Failed to reject peak submission")
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// readReview reads the submission ID and the optional reviewer note
+func (c *PeakSubmissionsController) readReview(rw http.ResponseWriter, r *http.Request) (int64, dto.ReviewPeakSubmissionRequest, bool) {
+	var request dto.ReviewPeakSubmissionRequest
+
+	submissionID, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		http.Error(rw, "Invalid submission ID", http.StatusBadRequest)
+		return 0, request, false
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		c.l.Printf("Error unmarshalling data: %v", err)
+		http.Error(rw, "Error unmarshalling data", http.StatusBadRequest)
+		return 0, request, false
+	}
+	defer r.Body.Close()
+
+	return submissionID, request, true
+}
+
+func (c *PeakSubmissionsController) writeError(rw http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrPeakSubmissionNotFound),
+		errors.Is(err, services.ErrPeakNotFound):
+		http.Error(rw, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrNotAdmin),
+		errors.Is(err, daos.ErrUserNotFound):
+		http.Error(rw, "Not authorized", http.StatusForbidden)
+	case errors.Is(err, services.ErrPeakSubmissionReviewed):
+		http.Error(rw, err.Error(), http.StatusConflict)
+	case errors.Is(err, services.ErrPeakSubmissionKindInvalid),
+		errors.Is(err, services.ErrPeakSubmissionNameRequired),
+		errors.Is(err, services.ErrPeakSubmissionPositionInvalid),
+		errors.Is(err, services.ErrPeakSubmissionElevationInvalid),
+		errors.Is(err, services.ErrPeakSubmissionNoChanges),
+		errors.Is(err, services.ErrPeakSubmissionStatusInvalid):
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+	default:
+		c.l.Printf("%s: %v", message, err)
+		http.Error(rw, message, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"run-goals/meta"
+	"run-goals/models"
+	"run-goals/services"
+	"strconv"
+)
+
+type PrivacyController struct {
+	l              *log.Logger
+	privacyService *services.PrivacyService
+}
+
+func NewPrivacyController(
+	l *log.Logger,
+	privacyService *services.PrivacyService,
+) *PrivacyController {
+	return &PrivacyController{
+		l:              l,
+		privacyService: privacyService,
+	}
+}
+
+// GetPrivacyZones lists the user's privacy zones.
+// GET /api/privacy-zones
+func (c *PrivacyController) GetPrivacyZones(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle GET PrivacyZones")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	zones, err := c.privacyService.GetZones(userID)
+	if err != nil {
+		c.writeError(rw, err, "Failed to get privacy zones")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(zones)
+}
+
+// CreatePrivacyZone adds a circle other users won't see the user's routes in.
+// POST /api/privacy-zones
+func (c *PrivacyController) CreatePrivacyZone(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle POST PrivacyZone")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	var request struct {
+		Name         string  `json:"name"`
+		Latitude     float64 `json:"latitude"`
+		Longitude    float64 `json:"longitude"`
+		RadiusMeters float64 `json:"radius_meters"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		c.l.Printf("Error unmarshalling data: %v", err)
+		http.Error(rw, "Error unmarshalling data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	id, err := c.privacyService.CreateZone(userID, models.PrivacyZone{
+		Name:         request.Name,
+		Latitude:     request.Latitude,
+		Longitude:    request.Longitude,
+		RadiusMeters: request.RadiusMeters,
+	})
+	if err != nil {
+		c.writeError(rw, err, "Failed to create privacy zone")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusCreated)
+	json.NewEncoder(rw).Encode(map[string]int64{"id": *id})
+}
+
+// DeletePrivacyZone removes one of the user's privacy zones.
+// DELETE /api/privacy-zone?id=123
+func (c *PrivacyController) DeletePrivacyZone(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle DELETE PrivacyZone")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	zoneID, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		http.Error(rw, "Invalid privacy zone ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.privacyService.DeleteZone(userID, zoneID); err != nil {
+		c.writeError(rw, err, "Failed to delete privacy zone")
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (c *PrivacyController) writeError(rw http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrPrivacyZoneNotFound):
+		http.Error(rw, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrPrivacyZonePositionInvalid),
+		errors.Is(err, services.ErrPrivacyZoneRadiusInvalid),
+		errors.Is(err, services.ErrPrivacyZoneLimitReached):
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+	default:
+		c.l.Printf("%s: %v", message, err)
+		http.Error(rw, message, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"run-goals/daos"
+	"run-goals/dto"
+	"run-goals/meta"
+	"run-goals/services"
+	"strconv"
+)
+
+type SummitCorrectionsController struct {
+	l                       *log.Logger
+	summitCorrectionService *services.SummitCorrectionService
+}
+
+func NewSummitCorrectionsController(
+	l *log.Logger,
+	summitCorrectionService *services.SummitCorrectionService,
+) *SummitCorrectionsController {
+	return &SummitCorrectionsController{
+		l:                       l,
+		summitCorrectionService: summitCorrectionService,
+	}
+}
+
+// DismissSummit removes a detected summit as a false positive.
+// POST /api/summit-dismissals
+func (c *SummitCorrectionsController) DismissSummit(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle POST SummitDismissal")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	var request dto.DismissSummitRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		c.l.Printf("Error unmarshalling data: %v", err)
+		http.Error(rw, "Error unmarshalling data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	if err := c.summitCorrectionService.DismissSummit(userID, request.PeakID, request.ActivityID, request.Reason); err != nil {
+		c.writeError(rw, err, "Failed to dismiss summit")
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// GetDismissals lists the user's dismissed summits.
+// GET /api/summit-dismissals
+func (c *SummitCorrectionsController) GetDismissals(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle GET SummitDismissals")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	dismissals, err := c.summitCorrectionService.GetDismissals(userID)
+	if err != nil {
+		c.writeError(rw, err, "Failed to get summit dismissals")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(dismissals)
+}
+
+// ClaimSummit claims a summit detection missed on one of the user's
+// activities. Claims near the route are approved straight away.
+// POST /api/summit-claims
+func (c *SummitCorrectionsController) ClaimSummit(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle POST SummitClaim")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	var request dto.CreateSummitClaimRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		c.l.Printf("Error unmarshalling data: %v", err)
+		http.Error(rw, "Error unmarshalling data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	claim, err := c.summitCorrectionService.Claim(userID, request.PeakID, request.ActivityID, request.Note)
+	if err != nil {
+		c.writeError(rw, err, "Failed to claim summit")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusCreated)
+	json.NewEncoder(rw).Encode(dto.CreateSummitClaimResponse{ID: claim.ID, Status: string(claim.Status)})
+}
+
+// GetMyClaims lists the user's summit claims.
+// GET /api/summit-claims
+func (c *SummitCorrectionsController) GetMyClaims(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle GET SummitClaims")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	claims, err := c.summitCorrectionService.GetMyClaims(userID)
+	if err != nil {
+		c.writeError(rw, err, "Failed to get summit claims")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(claims)
+}
+
+// GetQueue lists the pending claims the user may review.
+// GET /api/summit-claims/queue
+func (c *SummitCorrectionsController) GetQueue(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle GET SummitClaims queue")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	queue, err := c.summitCorrectionService.GetQueue(userID)
+	if err != nil {
+		c.writeError(rw, err, "Failed to get summit claim queue")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(queue)
+}
+
+// ApproveClaim adds a claimed summit.
+// POST /api/summit-claims/approve?id=123
+func (c *SummitCorrectionsController) ApproveClaim(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle POST SummitClaim approve")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	claimID, request, ok := c.readReview(rw, r)
+	if !ok {
+		return
+	}
+
+	if err := c.summitCorrectionService.ApproveClaim(claimID, userID, request.ReviewerNote); err != nil {
+		c.writeError(rw, err, "Failed to approve summit claim")
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// RejectClaim closes a claim without adding the summit.
+// POST /api/summit-claims/reject?id=123
+func (c *SummitCorrectionsController) RejectClaim(rw http.ResponseWriter, r *http.Request) {
+	c.l.Println("Handle POST SummitClaim reject")
+
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	claimID, request, ok := c.readReview(rw, r)
+	if !ok {
+		return
+	}
+
+	if err := c.summitCorrectionService.RejectClaim(claimID, userID, request.ReviewerNote); err != nil {
+		c.writeError(rw, err, "Failed to reject summit claim")
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// readReview reads the claim ID and the optional reviewer note
+func (c *SummitCorrectionsController) readReview(rw http.ResponseWriter, r *http.Request) (int64, dto.ReviewSummitClaimRequest, bool) {
+	var request dto.ReviewSummitClaimRequest
+
+	claimID, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		http.Error(rw, "Invalid claim ID", http.StatusBadRequest)
+		return 0, request, false
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		c.l.Printf("Error unmarshalling data: %v", err)
+		http.Error(rw, "Error unmarshalling data", http.StatusBadRequest)
+		return 0, request, false
+	}
+	defer r.Body.Close()
+
+	return claimID, request, true
+}
+
+func (c *SummitCorrectionsController) writeError(rw http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrSummitNotFound),
+		errors.Is(err, services.ErrSummitClaimNotFound),
+		errors.Is(err, services.ErrActivityNotFound),
+		errors.Is(err, services.ErrPeakNotFound):
+		http.Error(rw, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrNotClaimReviewer),
+		errors.Is(err, daos.ErrUserNotFound):
+		http.Error(rw, "Not authorized", http.StatusForbidden)
+	case errors.Is(err, services.ErrSummitAlreadyRecorded),
+		errors.Is(err, services.ErrSummitClaimPending),
+		errors.Is(err, services.ErrSummitClaimReviewed):
+		http.Error(rw, err.Error(), http.StatusConflict)
+	default:
+		c.l.Printf("%s: %v", message, err)
+		http.Error(rw, message, http.StatusInternalServerError)
+	}
+}
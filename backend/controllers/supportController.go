@@ -2,9 +2,10 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"run-goals/daos"
 	"run-goals/meta"
 	"run-goals/services"
@@ -13,12 +14,15 @@ import (
 )
 
 type SupportController struct {
-	l               *log.Logger
-	userService     *services.UserService
-	peakService     *services.PeakService
-	overpassService *services.OverpassService
-	activityDao     *daos.ActivityDao
-	userPeaksDao    *daos.UserPeaksDao
+	l                      *log.Logger
+	userService            *services.UserService
+	peakService            *services.PeakService
+	overpassService        *services.OverpassService
+	elevationService       *services.ElevationService
+	activityDao            *daos.ActivityDao
+	userPeaksDao           *daos.UserPeaksDao
+	dataExportService      *services.DataExportService
+	accountDeletionService *services.AccountDeletionService
 }
 
 func NewSupportController(
@@ -26,16 +30,22 @@ func NewSupportController(
 	userService *services.UserService,
 	peakService *services.PeakService,
 	overpassService *services.OverpassService,
+	elevationService *services.ElevationService,
 	activityDao *daos.ActivityDao,
 	userPeaksDao *daos.UserPeaksDao,
+	dataExportService *services.DataExportService,
+	accountDeletionService *services.AccountDeletionService,
 ) *SupportController {
 	return &SupportController{
-		l:               l,
-		userService:     userService,
-		peakService:     peakService,
-		overpassService: overpassService,
-		activityDao:     activityDao,
-		userPeaksDao:    userPeaksDao,
+		l:                      l,
+		userService:            userService,
+		peakService:            peakService,
+		overpassService:        overpassService,
+		elevationService:       elevationService,
+		activityDao:            activityDao,
+		userPeaksDao:           userPeaksDao,
+		dataExportService:      dataExportService,
+		accountDeletionService: accountDeletionService,
 	}
 }
 
@@ -78,21 +88,20 @@ func (c *SupportController) DeleteUserAccount(w http.ResponseWriter, r *http.Req
 
 	c.l.Printf("Processing account deletion request for strava_athlete_id: %d", stravaAthleteID)
 
-	// Delete the user account
-	err = c.userService.DeleteUserAccount(stravaAthleteID)
+	// Deletion runs in the background so each step can be tracked and retried
+	deletion, err := c.accountDeletionService.RequestDeletion(userID)
 	if err != nil {
-		if err == daos.ErrUserNotFound {
-			c.l.Printf("User not found for deletion: strava_athlete_id=%d", stravaAthleteID)
+		if errors.Is(err, services.ErrAccountDeletionInProgress) {
 			response := map[string]string{
-				"message": "No account found with that Strava Athlete ID",
+				"message": "Your account is already being deleted",
 			}
 			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusNotFound)
+			w.WriteHeader(http.StatusConflict)
 			json.NewEncoder(w).Encode(response)
 			return
 		}
 
-		c.l.Printf("Error deleting user account: %v", err)
+		c.l.Printf("Error requesting account deletion: %v", err)
 		response := map[string]string{
 			"message": "Failed to delete account. Please try again later.",
 		}
@@ -102,15 +111,212 @@ func (c *SupportController) DeleteUserAccount(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	// Success response
-	response := map[string]string{
-		"message": "Account successfully deleted",
+	// Accepted response. The receipt code is the only way to check on the
+	// deletion once the account is gone.
+	response := map[string]interface{}{
+		"message":      "Account deletion started",
+		"deletion_id":  deletion.ID,
+		"status":       deletion.Status,
+		"receipt_code": deletion.ReceiptCode,
+		"receipt_url":  c.accountDeletionService.ReceiptURL(deletion),
 	}
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(http.StatusAccepted)
 	json.NewEncoder(w).Encode(response)
 
-	c.l.Printf("Successfully processed account deletion for strava_athlete_id: %d", stravaAthleteID)
+	c.l.Printf("Queued account deletion %d for strava_athlete_id: %d", deletion.ID, stravaAthleteID)
+}
+
+// DataExport starts an export of all the user's data (POST) or reports the
+// status of their latest one (GET). Exports are built in the background;
+// once ready the status includes a download link that expires.
+func (c *SupportController) DataExport(w http.ResponseWriter, r *http.Request) {
+	userID, _ := meta.GetUserIDFromContext(r.Context())
+
+	switch r.Method {
+	case http.MethodPost:
+		c.l.Println("Handle POST DataExport")
+		export, err := c.dataExportService.RequestExport(userID)
+		if errors.Is(err, services.ErrDataExportInProgress) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			c.l.Printf("Error requesting data export: %v", err)
+			http.Error(w, "Failed to start data export", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(export)
+	case http.MethodGet:
+		c.l.Println("Handle GET DataExport")
+		export, err := c.dataExportService.GetLatestExport(userID)
+		if errors.Is(err, services.ErrDataExportNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			c.l.Printf("Error getting data export: %v", err)
+			http.Error(w, "Failed to get data export", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(export)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// DownloadDataExport serves a ready export's ZIP.
+//
+// Note: This endpoint is unauthenticated so the link works from a browser,
+// the token query param is the credential
+func (c *SupportController) DownloadDataExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	export, file, err := c.dataExportService.OpenDownload(r.URL.Query().Get("token"))
+	if errors.Is(err, services.ErrDataExportNotFound) {
+		http.Error(w, "Download link is invalid or has expired", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		c.l.Printf("Error opening data export: %v", err)
+		http.Error(w, "Failed to download data export", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	c.l.Printf("Serving data export %d for user %d", export.ID, export.UserID)
+
+	modified := export.CreatedAt
+	if export.CompletedAt != nil {
+		modified = *export.CompletedAt
+	}
+	filename := fmt.Sprintf("run-goals-export-%s.zip", modified.Format("2006-01-02"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Cache-Control", "no-store")
+	http.ServeContent(w, r, filename, modified, file)
+}
+
+// GetDeletionReceipt shows the status and receipt of an account deletion.
+//
+// Note: This endpoint is unauthenticated since the account no longer
+// exists, the code query param given when deletion was requested is the
+// credential
+func (c *SupportController) GetDeletionReceipt(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	deletion, err := c.accountDeletionService.GetReceipt(r.URL.Query().Get("code"))
+	if errors.Is(err, services.ErrAccountDeletionNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		c.l.Printf("Error getting deletion receipt: %v", err)
+		http.Error(w, "Failed to get deletion receipt", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deletion)
+}
+
+// GetAccountDeletions lists account deletions that are pending, running or
+// failed.
+//
+// Note: This endpoint is unauthenticated but requires admin_key query param
+func (c *SupportController) GetAccountDeletions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !checkAdminKey(c.l, w, r, "account-deletions") {
+		return
+	}
+
+	deletions, err := c.accountDeletionService.GetOutstanding()
+	if err != nil {
+		c.l.Printf("Error getting account deletions: %v", err)
+		http.Error(w, "Failed to get account deletions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deletions)
+}
+
+// RetryAccountDeletion queues a failed account deletion to run again.
+// Query params:
+//   - id: the deletion to retry
+//
+// Note: This endpoint is unauthenticated but requires admin_key query param
+func (c *SupportController) RetryAccountDeletion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !checkAdminKey(c.l, w, r, "account-deletions/retry") {
+		return
+	}
+
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid deletion ID", http.StatusBadRequest)
+		return
+	}
+
+	err = c.accountDeletionService.RetryDeletion(id)
+	switch {
+	case errors.Is(err, services.ErrAccountDeletionNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, services.ErrAccountDeletionNotFailed):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		c.l.Printf("Error retrying account deletion %d: %v", id, err)
+		http.Error(w, "Failed to retry account deletion", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// FillPeakElevations fills missing or implausible peak elevations from the
+// local DEM tiles.
+//
+// Note: This endpoint is unauthenticated but requires admin_key query param
+func (c *SupportController) FillPeakElevations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !checkAdminKey(c.l, w, r, "fill-peak-elevations") {
+		return
+	}
+
+	result, err := c.elevationService.FillPeakElevations()
+	if err != nil {
+		if errors.Is(err, services.ErrElevationUnavailable) {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		c.l.Printf("Error filling peak elevations: %v", err)
+		http.Error(w, "Failed to fill peak elevations", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
 }
 
 // RefreshPeaks fetches fresh peak data from OpenStreetMap and optionally
@@ -125,15 +331,7 @@ func (c *SupportController) RefreshPeaks(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Simple admin key check (set ADMIN_KEY env var)
-	adminKey := r.URL.Query().Get("admin_key")
-	expectedKey := os.Getenv("ADMIN_KEY")
-	if expectedKey == "" {
-		expectedKey = "dev-admin-key" // Default for local development
-	}
-	if adminKey != expectedKey {
-		c.l.Printf("Unauthorized refresh-peaks attempt")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	if !checkAdminKey(c.l, w, r, "refresh-peaks") {
 		return
 	}
 
@@ -0,0 +1,542 @@
+package daos
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"run-goals/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type AccountDeletionDaoInterface interface {
+	CreateDeletion(userID int64, receiptCode string) (*int64, error)
+	GetDeletionByID(id int64) (*models.AccountDeletion, error)
+	GetDeletionByReceiptCode(code string) (*models.AccountDeletion, error)
+	GetDeletionsByStatus(statuses []models.AccountDeletionStatus) ([]models.AccountDeletion, error)
+	StartNextDeletion() (*models.AccountDeletion, error)
+	HeartbeatDeletion(id int64) error
+	RequeueStaleDeletions(staleAfter time.Duration) (int64, error)
+	CompleteDeletion(id int64, receipt models.AccountDeletionReceipt) error
+	FailDeletion(id int64, message string) error
+	RetryDeletion(id int64) (bool, error)
+	TransferOwnership(userID int64) (*models.AccountOwnershipTransfer, error)
+	AnonymiseLeaderboardHistory(userID int64, deletionID int64) (int, error)
+	PurgeUser(userID int64) (map[string]int64, error)
+}
+
+type AccountDeletionDao struct {
+	l  *log.Logger
+	db *sql.DB
+}
+
+func NewAccountDeletionDao(logger *log.Logger, db *sql.DB) *AccountDeletionDao {
+	return &AccountDeletionDao{
+		l:  logger,
+		db: db,
+	}
+}
+
+// personalRows are the rows deleted with a user, by table and the column
+// holding the user's ID
+var personalRows = []struct{ table, column string }{
+	{"activity", "user_id"},
+	{"user_peaks", "user_id"},
+	{"summit_dismissals", "user_id"},
+	{"summit_claims", "user_id"},
+	{"group_members", "user_id"},
+	{"challenge_participants", "user_id"},
+	{"challenge_summit_log", "user_id"},
+	{"challenge_proposals", "proposed_by_user_id"},
+	{"challenge_series", "created_by_user_id"},
+	{"personal_yearly_goals", "user_id"},
+	{"personal_goals", "user_id"},
+	{"summit_favourites", "user_id"},
+	{"user_achievements", "user_id"},
+	{"privacy_zones", "user_id"},
+	{"data_exports", "user_id"},
+	{"notifications", "user_id"},
+	{"email_sends", "user_id"},
+}
+
+// userReferences are the columns that keep a row when its user is deleted.
+// They're cleared rather than deleted, and checked along with personalRows.
+var userReferences = []struct{ table, column string }{
+	{"groups", "created_by"},
+	{"challenges", "created_by_user_id"},
+	{"challenge_proposals", "reviewed_by_user_id"},
+	{"peak_lists", "created_by_user_id"},
+	{"peak_submissions", "user_id"},
+	{"peak_submissions", "reviewed_by_user_id"},
+	{"summit_claims", "reviewed_by_user_id"},
+	{"group_webhooks", "created_by"},
+}
+
+const accountDeletionSelect = `
+	SELECT
+		id, user_id, status, receipt_code, receipt, error, attempts,
+		requested_at, started_at, completed_at
+	FROM account_deletions
+`
+
+// ==================== Jobs ====================
+
+// CreateDeletion queues the user's account for deletion. It returns
+// sql.ErrNoRows if a deletion is already pending or running.
+func (dao *AccountDeletionDao) CreateDeletion(userID int64, receiptCode string) (*int64, error) {
+	var id int64
+	query := `
+		INSERT INTO account_deletions (user_id, receipt_code)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) WHERE status IN ('pending', 'running') DO NOTHING
+		RETURNING id;
+	`
+	err := dao.db.QueryRow(query, userID, receiptCode).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		dao.l.Printf("Error creating account deletion: %v", err)
+		return nil, err
+	}
+	return &id, nil
+}
+
+func (dao *AccountDeletionDao) GetDeletionByID(id int64) (*models.AccountDeletion, error) {
+	return dao.getDeletion(accountDeletionSelect+`WHERE id = $1`, id)
+}
+
+func (dao *AccountDeletionDao) GetDeletionByReceiptCode(code string) (*models.AccountDeletion, error) {
+	return dao.getDeletion(accountDeletionSelect+`WHERE receipt_code = $1`, code)
+}
+
+// GetDeletionsByStatus lists deletions in any of the statuses, oldest first
+func (dao *AccountDeletionDao) GetDeletionsByStatus(statuses []models.AccountDeletionStatus) ([]models.AccountDeletion, error) {
+	values := make([]string, len(statuses))
+	for i, status := range statuses {
+		values[i] = string(status)
+	}
+	rows, err := dao.db.Query(accountDeletionSelect+`WHERE status = ANY($1) ORDER BY requested_at, id`, pq.Array(values))
+	if err != nil {
+		dao.l.Printf("Error getting account deletions: %v", err)
+		return nil, err
+	}
+	return dao.scanDeletions(rows)
+}
+
+// StartNextDeletion marks the oldest pending deletion as running and
+// returns it, or nil when there's nothing to do
+func (dao *AccountDeletionDao) StartNextDeletion() (*models.AccountDeletion, error) {
+	query := `
+		UPDATE account_deletions
+		SET status = 'running', started_at = NOW(), heartbeat_at = NOW(), attempts = attempts + 1, error = NULL
+		WHERE id = (
+			SELECT id FROM account_deletions
+			WHERE status = 'pending'
+			ORDER BY requested_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, user_id, status, receipt_code, receipt, error, attempts,
+			requested_at, started_at, completed_at;
+	`
+	return dao.getDeletion(query)
+}
+
+// HeartbeatDeletion records that the worker running the deletion is still
+// alive
+func (dao *AccountDeletionDao) HeartbeatDeletion(id int64) error {
+	_, err := dao.db.Exec(`UPDATE account_deletions SET heartbeat_at = NOW() WHERE id = $1 AND status = 'running'`, id)
+	if err != nil {
+		dao.l.Printf("Error updating account deletion %d heartbeat: %v", id, err)
+	}
+	return err
+}
+
+// RequeueStaleDeletions puts running deletions whose worker hasn't sent a
+// heartbeat within staleAfter back in the queue. Every step can be run
+// again.
+func (dao *AccountDeletionDao) RequeueStaleDeletions(staleAfter time.Duration) (int64, error) {
+	query := `
+		UPDATE account_deletions
+		SET status = 'pending', heartbeat_at = NULL
+		WHERE status = 'running'
+			AND COALESCE(heartbeat_at, started_at) < NOW() - $1 * INTERVAL '1 second'
+	`
+	result, err := dao.db.Exec(query, staleAfter.Seconds())
+	if err != nil {
+		dao.l.Printf("Error requeueing account deletions: %v", err)
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (dao *AccountDeletionDao) CompleteDeletion(id int64, receipt models.AccountDeletionReceipt) error {
+	data, err := json.Marshal(receipt)
+	if err != nil {
+		return err
+	}
+	query := `
+		UPDATE account_deletions
+		SET status = 'completed', receipt = $2, error = NULL, completed_at = $3
+		WHERE id = $1;
+	`
+	_, err = dao.db.Exec(query, id, data, receipt.CompletedAt)
+	if err != nil {
+		dao.l.Printf("Error completing account deletion %d: %v", id, err)
+		return err
+	}
+	return nil
+}
+
+func (dao *AccountDeletionDao) FailDeletion(id int64, message string) error {
+	_, err := dao.db.Exec(`UPDATE account_deletions SET status = 'failed', error = $2 WHERE id = $1`, id, message)
+	if err != nil {
+		dao.l.Printf("Error failing account deletion %d: %v", id, err)
+		return err
+	}
+	return nil
+}
+
+// RetryDeletion puts a failed deletion back in the queue, returning false if
+// it hasn't failed
+func (dao *AccountDeletionDao) RetryDeletion(id int64) (bool, error) {
+	result, err := dao.db.Exec(`UPDATE account_deletions SET status = 'pending' WHERE id = $1 AND status = 'failed'`, id)
+	if err != nil {
+		dao.l.Printf("Error retrying account deletion %d: %v", id, err)
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// ==================== Steps ====================
+
+// TransferOwnership hands the user's groups to another member, preferring
+// admins, and their challenges to the longest standing other participant.
+// Groups and challenges nobody else is in are deleted. Series follow their
+// group to its new owner; the rest go with the user.
+func (dao *AccountDeletionDao) TransferOwnership(userID int64) (*models.AccountOwnershipTransfer, error) {
+	tx, err := dao.db.Begin()
+	if err != nil {
+		dao.l.Printf("Error starting ownership transfer: %v", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &models.AccountOwnershipTransfer{}
+
+	groupIDs, err := queryIDs(tx, `SELECT id FROM groups WHERE created_by = $1 ORDER BY id`, userID)
+	if err != nil {
+		dao.l.Printf("Error getting owned groups: %v", err)
+		return nil, err
+	}
+	for _, groupID := range groupIDs {
+		var successorID int64
+		err := tx.QueryRow(`
+			SELECT user_id FROM group_members
+			WHERE group_id = $1 AND user_id <> $2
+			ORDER BY (role = 'admin') DESC, joined_at NULLS LAST, id
+			LIMIT 1
+		`, groupID, userID).Scan(&successorID)
+		if err == sql.ErrNoRows {
+			if _, err := tx.Exec(`DELETE FROM groups WHERE id = $1`, groupID); err != nil {
+				dao.l.Printf("Error closing group %d: %v", groupID, err)
+				return nil, err
+			}
+			result.GroupsClosed++
+			continue
+		}
+		if err != nil {
+			dao.l.Printf("Error finding successor for group %d: %v", groupID, err)
+			return nil, err
+		}
+		if _, err := tx.Exec(`UPDATE groups SET created_by = $2 WHERE id = $1`, groupID, successorID); err != nil {
+			dao.l.Printf("Error transferring group %d: %v", groupID, err)
+			return nil, err
+		}
+		if _, err := tx.Exec(`UPDATE group_members SET role = 'admin' WHERE group_id = $1 AND user_id = $2`, groupID, successorID); err != nil {
+			dao.l.Printf("Error promoting group %d successor: %v", groupID, err)
+			return nil, err
+		}
+		result.GroupsTransferred++
+	}
+
+	challengeIDs, err := queryIDs(tx, `SELECT id FROM challenges WHERE created_by_user_id = $1 ORDER BY id`, userID)
+	if err != nil {
+		dao.l.Printf("Error getting owned challenges: %v", err)
+		return nil, err
+	}
+	for _, challengeID := range challengeIDs {
+		var successorID int64
+		err := tx.QueryRow(`
+			SELECT cp.user_id FROM challenge_participants cp
+			JOIN users u ON u.id = cp.user_id
+			WHERE cp.challenge_id = $1 AND cp.user_id <> $2 AND u.deleted_at IS NULL
+			ORDER BY cp.joined_at NULLS LAST, cp.id
+			LIMIT 1
+		`, challengeID, userID).Scan(&successorID)
+		if err == sql.ErrNoRows {
+			if _, err := tx.Exec(`DELETE FROM challenges WHERE id = $1`, challengeID); err != nil {
+				dao.l.Printf("Error closing challenge %d: %v", challengeID, err)
+				return nil, err
+			}
+			result.ChallengesClosed++
+			continue
+		}
+		if err != nil {
+			dao.l.Printf("Error finding successor for challenge %d: %v", challengeID, err)
+			return nil, err
+		}
+		_, err = tx.Exec(`UPDATE challenges SET created_by_user_id = $2, updated_at = NOW() WHERE id = $1`, challengeID, successorID)
+		if err != nil {
+			dao.l.Printf("Error transferring challenge %d: %v", challengeID, err)
+			return nil, err
+		}
+		result.ChallengesTransferred++
+	}
+
+	series, err := tx.Exec(`
+		UPDATE challenge_series cs
+		SET created_by_user_id = g.created_by, updated_at = NOW()
+		FROM groups g
+		WHERE cs.created_by_user_id = $1
+		  AND cs.created_by_group_id = g.id
+		  AND g.created_by IS NOT NULL
+		  AND g.created_by <> $1
+	`, userID)
+	if err != nil {
+		dao.l.Printf("Error transferring challenge series: %v", err)
+		return nil, err
+	}
+	n, err := series.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	result.SeriesTransferred = int(n)
+
+	if err := tx.Commit(); err != nil {
+		dao.l.Printf("Error committing ownership transfer: %v", err)
+		return nil, err
+	}
+	return result, nil
+}
+
+// AnonymiseLeaderboardHistory moves the user's results in finished
+// challenges that others took part in to a placeholder account, so those
+// leaderboards stay as they ended. Returns how many results were moved.
+func (dao *AccountDeletionDao) AnonymiseLeaderboardHistory(userID int64, deletionID int64) (int, error) {
+	tx, err := dao.db.Begin()
+	if err != nil {
+		dao.l.Printf("Error starting leaderboard anonymisation: %v", err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	challengeIDs, err := queryIDs(tx, `
+		SELECT cp.challenge_id
+		FROM challenge_participants cp
+		JOIN challenges c ON c.id = cp.challenge_id
+		WHERE cp.user_id = $1
+		  AND c.deadline IS NOT NULL AND c.deadline < CURRENT_DATE
+		  AND EXISTS (
+			SELECT 1 FROM challenge_participants o
+			WHERE o.challenge_id = cp.challenge_id AND o.user_id <> $1
+		  )
+	`, userID)
+	if err != nil {
+		dao.l.Printf("Error getting finished challenges: %v", err)
+		return 0, err
+	}
+	if len(challengeIDs) == 0 {
+		return 0, nil
+	}
+
+	// One placeholder per deletion keeps (challenge, user) unique when two
+	// deleted users were in the same challenge. The negative athlete ID
+	// can't match a Strava login.
+	var placeholderID int64
+	err = tx.QueryRow(`
+		INSERT INTO users (
+			strava_athlete_id, username, access_token, refresh_token, expires_at,
+			last_distance, last_updated, created_at, updated_at,
+			show_in_leaderboards, show_in_group_feeds, show_in_challenge_feeds, deleted_at
+		) VALUES (
+			$1, 'Deleted athlete', '', '', NOW(),
+			0, NOW(), NOW(), NOW(),
+			FALSE, FALSE, FALSE, NOW()
+		)
+		ON CONFLICT (strava_athlete_id) DO UPDATE SET updated_at = NOW()
+		RETURNING id;
+	`, -deletionID).Scan(&placeholderID)
+	if err != nil {
+		dao.l.Printf("Error creating placeholder user: %v", err)
+		return 0, err
+	}
+
+	moved, err := tx.Exec(`
+		UPDATE challenge_participants
+		SET user_id = $2, satisfied_by_activity_id = NULL
+		WHERE user_id = $1 AND challenge_id = ANY($3)
+	`, userID, placeholderID, pq.Int64Array(challengeIDs))
+	if err != nil {
+		dao.l.Printf("Error anonymising challenge participants: %v", err)
+		return 0, err
+	}
+	_, err = tx.Exec(`
+		UPDATE challenge_summit_log
+		SET user_id = $2, activity_id = NULL
+		WHERE user_id = $1 AND challenge_id = ANY($3)
+	`, userID, placeholderID, pq.Int64Array(challengeIDs))
+	if err != nil {
+		dao.l.Printf("Error anonymising challenge summit log: %v", err)
+		return 0, err
+	}
+
+	n, err := moved.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		dao.l.Printf("Error committing leaderboard anonymisation: %v", err)
+		return 0, err
+	}
+	return int(n), nil
+}
+
+// PurgeUser deletes the user and everything of theirs, then checks nothing
+// still refers to them. Nothing is deleted if the check fails. Returns the
+// rows deleted by table.
+func (dao *AccountDeletionDao) PurgeUser(userID int64) (map[string]int64, error) {
+	tx, err := dao.db.Begin()
+	if err != nil {
+		dao.l.Printf("Error starting user purge: %v", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	deleted := map[string]int64{}
+	for _, ref := range personalRows {
+		var count int64
+		query := fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE %s = $1`, ref.table, ref.column)
+		if err := tx.QueryRow(query, userID).Scan(&count); err != nil {
+			dao.l.Printf("Error counting %s rows: %v", ref.table, err)
+			return nil, err
+		}
+		if count > 0 {
+			deleted[ref.table] += count
+		}
+	}
+
+	// Everything else goes by FK cascade, or has its reference cleared
+	result, err := tx.Exec(`DELETE FROM users WHERE id = $1`, userID)
+	if err != nil {
+		dao.l.Printf("Error deleting user %d: %v", userID, err)
+		return nil, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrUserNotFound
+	}
+	deleted["users"] = n
+
+	for _, refs := range [][]struct{ table, column string }{personalRows, userReferences} {
+		for _, ref := range refs {
+			var remaining int64
+			query := fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE %s = $1`, ref.table, ref.column)
+			if err := tx.QueryRow(query, userID).Scan(&remaining); err != nil {
+				dao.l.Printf("Error checking %s rows: %v", ref.table, err)
+				return nil, err
+			}
+			if remaining > 0 {
+				return nil, fmt.Errorf("%d rows in %s.%s still refer to user %d", remaining, ref.table, ref.column, userID)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		dao.l.Printf("Error committing user purge: %v", err)
+		return nil, err
+	}
+	return deleted, nil
+}
+
+// ==================== Helpers ====================
+
+func (dao *AccountDeletionDao) getDeletion(query string, args ...interface{}) (*models.AccountDeletion, error) {
+	rows, err := dao.db.Query(query, args...)
+	if err != nil {
+		dao.l.Printf("Error getting account deletion: %v", err)
+		return nil, err
+	}
+	deletions, err := dao.scanDeletions(rows)
+	if err != nil || len(deletions) == 0 {
+		return nil, err
+	}
+	return &deletions[0], nil
+}
+
+func (dao *AccountDeletionDao) scanDeletions(rows *sql.Rows) ([]models.AccountDeletion, error) {
+	defer rows.Close()
+
+	deletions := []models.AccountDeletion{}
+	for rows.Next() {
+		d := models.AccountDeletion{}
+		var receipt []byte
+		var message sql.NullString
+		var startedAt, completedAt sql.NullTime
+		err := rows.Scan(
+			&d.ID, &d.UserID, &d.Status, &d.ReceiptCode, &receipt, &message, &d.Attempts,
+			&d.RequestedAt, &startedAt, &completedAt,
+		)
+		if err != nil {
+			dao.l.Printf("Error scanning account deletion: %v", err)
+			return nil, err
+		}
+		if receipt != nil {
+			d.Receipt = &models.AccountDeletionReceipt{}
+			if err := json.Unmarshal(receipt, d.Receipt); err != nil {
+				dao.l.Printf("Error parsing account deletion receipt: %v", err)
+				return nil, err
+			}
+		}
+		if message.Valid {
+			d.Error = &message.String
+		}
+		if startedAt.Valid {
+			d.StartedAt = &startedAt.Time
+		}
+		if completedAt.Valid {
+			d.CompletedAt = &completedAt.Time
+		}
+		deletions = append(deletions, d)
+	}
+	return deletions, rows.Err()
+}
+
+// queryIDs runs a query returning a single ID column
+func queryIDs(tx *sql.Tx, query string, args ...interface{}) ([]int64, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,259 @@
+package daos
+
+import (
+	"database/sql"
+	"log"
+	"run-goals/models"
+)
+
+type AchievementDaoInterface interface {
+	GetActiveDefinitions() ([]models.AchievementDefinition, error)
+	GetUserAchievements(userID int64) ([]models.UserAchievementWithDetails, error)
+	GetGroupAchievements(groupID int64, limit int) ([]models.UserAchievementWithDetails, error)
+	AwardAchievement(award models.UserAchievement) (bool, error)
+	GetSummitHistory(userID int64) ([]models.SummitWithPeak, error)
+	GetRegionPeakStats() ([]models.RegionPeakStats, error)
+	GetChallengeWins(userID int64) ([]models.ChallengeParticipant, error)
+}
+
+type AchievementDao struct {
+	l  *log.Logger
+	db *sql.DB
+}
+
+func NewAchievementDao(logger *log.Logger, db *sql.DB) *AchievementDao {
+	return &AchievementDao{
+		l:  logger,
+		db: db,
+	}
+}
+
+// ==================== Definitions ====================
+
+func (dao *AchievementDao) GetActiveDefinitions() ([]models.AchievementDefinition, error) {
+	query := `
+		SELECT id, key, name, description, category, rule_type, threshold, min_count, region, sort_order
+		FROM achievement_definitions
+		WHERE is_active = TRUE
+		ORDER BY sort_order, id;
+	`
+	rows, err := dao.db.Query(query)
+	if err != nil {
+		dao.l.Printf("Error getting achievement definitions: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var definitions []models.AchievementDefinition
+	for rows.Next() {
+		var d models.AchievementDefinition
+		err := rows.Scan(
+			&d.ID, &d.Key, &d.Name, &d.Description, &d.Category, &d.RuleType,
+			&d.Threshold, &d.MinCount, &d.Region, &d.SortOrder,
+		)
+		if err != nil {
+			dao.l.Printf("Error scanning achievement definition: %v", err)
+			return nil, err
+		}
+		definitions = append(definitions, d)
+	}
+	return definitions, nil
+}
+
+// ==================== Awards ====================
+
+const userAchievementColumns = `
+	ua.id, ua.user_id, ua.achievement_id, ua.scope, ua.activity_id, ua.peak_id, ua.challenge_id, ua.awarded_at,
+	ad.key, ad.name, ad.description, ad.category,
+	COALESCE(u.username, '') as user_name,
+	a.name as activity_name,
+	p.name as peak_name
+`
+
+const userAchievementJoins = `
+	FROM user_achievements ua
+	JOIN achievement_definitions ad ON ua.achievement_id = ad.id
+	JOIN users u ON ua.user_id = u.id
+	LEFT JOIN activity a ON ua.activity_id = a.id
+	LEFT JOIN peaks p ON ua.peak_id = p.id
+`
+
+func (dao *AchievementDao) GetUserAchievements(userID int64) ([]models.UserAchievementWithDetails, error) {
+	query := `SELECT ` + userAchievementColumns + userAchievementJoins + `
+		WHERE ua.user_id = $1
+		ORDER BY ua.awarded_at DESC, ad.sort_order;
+	`
+	rows, err := dao.db.Query(query, userID)
+	if err != nil {
+		dao.l.Printf("Error getting user achievements: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+	return dao.scanUserAchievements(rows)
+}
+
+// GetGroupAchievements returns the most recent achievements of a group's members
+func (dao *AchievementDao) GetGroupAchievements(groupID int64, limit int) ([]models.UserAchievementWithDetails, error) {
+	query := `SELECT ` + userAchievementColumns + userAchievementJoins + `
+		JOIN group_members gm ON gm.user_id = ua.user_id
+		WHERE gm.group_id = $1
+		ORDER BY ua.awarded_at DESC, ad.sort_order
+		LIMIT $2;
+	`
+	rows, err := dao.db.Query(query, groupID, limit)
+	if err != nil {
+		dao.l.Printf("Error getting group achievements: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+	return dao.scanUserAchievements(rows)
+}
+
+func (dao *AchievementDao) scanUserAchievements(rows *sql.Rows) ([]models.UserAchievementWithDetails, error) {
+	var achievements []models.UserAchievementWithDetails
+	for rows.Next() {
+		var a models.UserAchievementWithDetails
+		err := rows.Scan(
+			&a.ID, &a.UserID, &a.AchievementID, &a.Scope, &a.ActivityID, &a.PeakID, &a.ChallengeID, &a.AwardedAt,
+			&a.Key, &a.Name, &a.Description, &a.Category,
+			&a.UserName, &a.ActivityName, &a.PeakName,
+		)
+		if err != nil {
+			dao.l.Printf("Error scanning user achievement: %v", err)
+			return nil, err
+		}
+		achievements = append(achievements, a)
+	}
+	return achievements, nil
+}
+
+// AwardAchievement stores an award, returning false if the user already had it
+func (dao *AchievementDao) AwardAchievement(award models.UserAchievement) (bool, error) {
+	query := `
+		INSERT INTO user_achievements (user_id, achievement_id, scope, activity_id, peak_id, challenge_id, awarded_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (user_id, achievement_id, scope) DO NOTHING;
+	`
+	result, err := dao.db.Exec(query,
+		award.UserID, award.AchievementID, award.Scope,
+		award.ActivityID, award.PeakID, award.ChallengeID, award.AwardedAt,
+	)
+	if err != nil {
+		dao.l.Printf("Error awarding achievement: %v", err)
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+// ==================== History ====================
+
+// GetSummitHistory returns every summit of the user with peak elevation and region, oldest first
+func (dao *AchievementDao) GetSummitHistory(userID int64) ([]models.SummitWithPeak, error) {
+	query := `
+		SELECT
+			up.id, up.user_id, up.peak_id, up.activity_id, up.summited_at,
+			up.summit_time, up.elapsed_seconds,
+			COALESCE(p.elevation_meters, 0), COALESCE(p.region, '')
+		FROM user_peaks up
+		JOIN peaks p ON up.peak_id = p.id
+		WHERE up.user_id = $1
+		ORDER BY COALESCE(up.summit_time, up.summited_at), up.id;
+	`
+	rows, err := dao.db.Query(query, userID)
+	if err != nil {
+		dao.l.Printf("Error getting summit history: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summits []models.SummitWithPeak
+	for rows.Next() {
+		var s models.SummitWithPeak
+		err := rows.Scan(
+			&s.ID, &s.UserID, &s.PeakID, &s.ActivityID, &s.SummitedAt,
+			&s.SummitTime, &s.ElapsedSeconds,
+			&s.Elevation, &s.Region,
+		)
+		if err != nil {
+			dao.l.Printf("Error scanning summit history: %v", err)
+			return nil, err
+		}
+		summits = append(summits, s)
+	}
+	return summits, nil
+}
+
+// GetRegionPeakStats returns the peak count and highest peak of every region
+func (dao *AchievementDao) GetRegionPeakStats() ([]models.RegionPeakStats, error) {
+	query := `
+		SELECT DISTINCT ON (region)
+			region,
+			COUNT(*) OVER (PARTITION BY region) as peak_count,
+			id as highest_peak_id
+		FROM peaks
+		WHERE region IS NOT NULL AND region <> '' AND merged_into_id IS NULL
+		ORDER BY region, elevation_meters DESC NULLS LAST, id;
+	`
+	rows, err := dao.db.Query(query)
+	if err != nil {
+		dao.l.Printf("Error getting region peak stats: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []models.RegionPeakStats
+	for rows.Next() {
+		var s models.RegionPeakStats
+		if err := rows.Scan(&s.Region, &s.PeakCount, &s.HighestPeakID); err != nil {
+			dao.l.Printf("Error scanning region peak stats: %v", err)
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, nil
+}
+
+// GetChallengeWins returns the competitive challenges the user has won, oldest first.
+// A win is finishing first, or for fastest_time the best time once the deadline has passed.
+func (dao *AchievementDao) GetChallengeWins(userID int64) ([]models.ChallengeParticipant, error) {
+	query := `
+		SELECT cp.challenge_id, cp.user_id, cp.completed_at, cp.satisfied_by_activity_id
+		FROM challenge_participants cp
+		JOIN challenges c ON cp.challenge_id = c.id
+		WHERE cp.user_id = $1
+		  AND c.competition_mode = 'competitive'
+		  AND cp.completed_at IS NOT NULL
+		  AND (c.goal_type <> 'fastest_time' OR c.deadline < CURRENT_DATE)
+		  AND NOT EXISTS (
+			SELECT 1 FROM challenge_participants other
+			WHERE other.challenge_id = cp.challenge_id
+			  AND other.user_id <> cp.user_id
+			  AND CASE
+				WHEN c.goal_type = 'fastest_time' THEN other.best_time_seconds < cp.best_time_seconds
+				ELSE other.completed_at < cp.completed_at
+			  END
+		  )
+		ORDER BY cp.completed_at;
+	`
+	rows, err := dao.db.Query(query, userID)
+	if err != nil {
+		dao.l.Printf("Error getting challenge wins: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var wins []models.ChallengeParticipant
+	for rows.Next() {
+		var p models.ChallengeParticipant
+		if err := rows.Scan(&p.ChallengeID, &p.UserID, &p.CompletedAt, &p.SatisfiedByActivityID); err != nil {
+			dao.l.Printf("Error scanning challenge win: %v", err)
+			return nil, err
+		}
+		wins = append(wins, p)
+	}
+	return wins, nil
+}
@@ -2,9 +2,14 @@ package daos
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
+	"regexp"
 	"run-goals/models"
+	"strings"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 type ActivityDaoInterface interface {
@@ -46,9 +51,12 @@ func (dao *ActivityDao) UpsertActivity(activity *models.Activity) error {
             updated_at,
             has_summit,
             summits_calculated,
-            photo_url
+            photo_url,
+            private,
+            visibility
         ) VALUES (
-            $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17
+            $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17,
+            $18, COALESCE(NULLIF($19, ''), 'everyone')
         ) ON CONFLICT (
             strava_activity_id
         ) DO UPDATE
@@ -66,7 +74,11 @@ func (dao *ActivityDao) UpsertActivity(activity *models.Activity) error {
                 updated_at = EXCLUDED.updated_at,
                 has_summit = EXCLUDED.has_summit,
                 summits_calculated = EXCLUDED.summits_calculated,
-                photo_url = EXCLUDED.photo_url;
+                photo_url = EXCLUDED.photo_url,
+                -- Activities re-saved without visibility (e.g. after summit
+                -- detection) keep what was imported from Strava
+                private = CASE WHEN $19 = '' THEN activity.private ELSE EXCLUDED.private END,
+                visibility = CASE WHEN $19 = '' THEN activity.visibility ELSE EXCLUDED.visibility END;
     `
 	_, err := dao.db.Exec(
 		sql,
@@ -87,6 +99,8 @@ func (dao *ActivityDao) UpsertActivity(activity *models.Activity) error {
 		activity.HasSummit,
 		activity.SummitsCalculated,
 		activity.PhotoURL,
+		activity.Private,
+		activity.Visibility,
 	)
 	if err != nil {
 		dao.l.Printf("Error upserting activity: %v", err)
@@ -110,7 +124,9 @@ func (dao *ActivityDao) GetActivitiesByUserID(userID int64) ([]models.Activity,
             moving_time,
             start_date,
             map_polyline,
-            photo_url
+            photo_url,
+            private,
+            visibility
         FROM activity
         WHERE
             user_id = $1;
@@ -138,6 +154,8 @@ func (dao *ActivityDao) GetActivitiesByUserID(userID int64) ([]models.Activity,
 			&activity.StartDate,
 			&activity.MapPolyline,
 			&activity.PhotoURL,
+			&activity.Private,
+			&activity.Visibility,
 		)
 		if err != nil {
 			dao.l.Println("Error parsing query result", err)
@@ -184,7 +202,12 @@ func (dao *ActivityDao) GetActivityByID(id int64) (models.Activity, error) {
             moving_time,
             start_date,
             map_polyline,
-            photo_url
+            photo_url,
+            COALESCE(activity_type, ''),
+            COALESCE(sport_type, ''),
+            COALESCE(has_summit, false),
+            private,
+            visibility
         FROM activity
         WHERE
             id = $1;
@@ -205,6 +228,11 @@ func (dao *ActivityDao) GetActivityByID(id int64) (models.Activity, error) {
 		&activity.StartDate,
 		&activity.MapPolyline,
 		&activity.PhotoURL,
+		&activity.Type,
+		&activity.SportType,
+		&activity.HasSummit,
+		&activity.Private,
+		&activity.Visibility,
 	)
 	if err != nil {
 		dao.l.Println("Error querying activity table", err)
@@ -476,6 +504,8 @@ func (dao *ActivityDao) GetActivitiesByUserIDAndDateRange(userID int64, startDat
             strava_athlete_id,
             user_id,
             name,
+            COALESCE(activity_type, ''),
+            COALESCE(sport_type, ''),
             description,
             distance,
             elevation,
@@ -505,6 +535,8 @@ func (dao *ActivityDao) GetActivitiesByUserIDAndDateRange(userID int64, startDat
 			&activity.StravaAthleteId,
 			&activity.UserID,
 			&activity.Name,
+			&activity.Type,
+			&activity.SportType,
 			&activity.Description,
 			&activity.Distance,
 			&elevation,
@@ -542,3 +574,211 @@ func (dao *ActivityDao) GetActivitiesByUserIDAndDateRange(userID int64, startDat
 
 	return activities, nil
 }
+
+// GetUserRoutes returns the user's activities that have a route, with only
+// the fields needed to draw them on a map
+func (dao *ActivityDao) GetUserRoutes(userID int64) ([]models.Activity, error) {
+	query := `
+		SELECT
+			id,
+			user_id,
+			name,
+			COALESCE(activity_type, ''),
+			COALESCE(sport_type, ''),
+			COALESCE(distance, 0),
+			start_date,
+			map_polyline,
+			COALESCE(has_summit, false),
+			private,
+			visibility
+		FROM activity
+		WHERE user_id = $1 AND COALESCE(map_polyline, '') <> ''
+		ORDER BY start_date DESC
+	`
+	rows, err := dao.db.Query(query, userID)
+	if err != nil {
+		dao.l.Printf("Error querying user routes: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	activities := []models.Activity{}
+	for rows.Next() {
+		a := models.Activity{}
+		err := rows.Scan(&a.ID, &a.UserID, &a.Name, &a.Type, &a.SportType, &a.Distance, &a.StartDate, &a.MapPolyline, &a.HasSummit, &a.Private, &a.Visibility)
+		if err != nil {
+			dao.l.Printf("Error scanning user route: %v", err)
+			return nil, err
+		}
+		activities = append(activities, a)
+	}
+	return activities, rows.Err()
+}
+
+// GetRouteFingerprints returns a value per user that changes whenever one of
+// their routes is added, removed or replaced, or its visibility changes.
+// Users without routes are left out. A nil userIDs covers every user.
+func (dao *ActivityDao) GetRouteFingerprints(userIDs []int64) (map[int64]string, error) {
+	query := `
+		SELECT user_id, MD5(STRING_AGG(
+			id || ':' || LENGTH(map_polyline) || ':' || visibility || ':' || private,
+			',' ORDER BY id
+		))
+		FROM activity
+		WHERE COALESCE(map_polyline, '') <> ''
+			AND ($1::bigint[] IS NULL OR user_id = ANY($1))
+		GROUP BY user_id
+	`
+	var ids interface{}
+	if userIDs != nil {
+		ids = pq.Int64Array(userIDs)
+	}
+	rows, err := dao.db.Query(query, ids)
+	if err != nil {
+		dao.l.Printf("Error querying route fingerprints: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	fingerprints := map[int64]string{}
+	for rows.Next() {
+		var userID int64
+		var fingerprint string
+		if err := rows.Scan(&userID, &fingerprint); err != nil {
+			dao.l.Printf("Error scanning route fingerprint: %v", err)
+			return nil, err
+		}
+		fingerprints[userID] = fingerprint
+	}
+	return fingerprints, rows.Err()
+}
+
+// ListActivities returns one page of the user's activities matching the
+// filter, starting after the filter's cursor. Up to Limit+1 rows come back so
+// the caller can tell whether there's another page.
+func (dao *ActivityDao) ListActivities(filter models.ActivityListFilter) ([]models.Activity, error) {
+	args := []interface{}{filter.UserID}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions := []string{"a.user_id = $1"}
+	if filter.StartDate != nil {
+		conditions = append(conditions, "a.start_date >= "+arg(*filter.StartDate))
+	}
+	if filter.EndDate != nil {
+		conditions = append(conditions, "a.start_date < "+arg(*filter.EndDate))
+	}
+	if len(filter.Types) > 0 {
+		conditions = append(conditions, "a.activity_type = ANY("+arg(pq.Array(filter.Types))+")")
+	}
+	if len(filter.SportTypes) > 0 {
+		conditions = append(conditions, "a.sport_type = ANY("+arg(pq.Array(filter.SportTypes))+")")
+	}
+	if filter.HasSummit != nil {
+		conditions = append(conditions, "COALESCE(a.has_summit, false) = "+arg(*filter.HasSummit))
+	}
+	if filter.PeakID != nil {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM user_peaks up WHERE up.activity_id = a.id AND up.peak_id = "+arg(*filter.PeakID)+")")
+	}
+	if filter.MinDistance != nil {
+		conditions = append(conditions, "COALESCE(a.distance, 0) >= "+arg(*filter.MinDistance))
+	}
+	if filter.MinElevation != nil {
+		conditions = append(conditions, "COALESCE(a.elevation, 0) >= "+arg(*filter.MinElevation))
+	}
+	for _, tag := range filter.Tags {
+		// The tag must end at a word boundary, so #hg doesn't match #hgx
+		pattern := arg("#" + regexp.QuoteMeta(tag) + "([^[:alnum:]_]|$)")
+		conditions = append(conditions, fmt.Sprintf("(a.name ~* %[1]s OR COALESCE(a.description, '') ~* %[1]s)", pattern))
+	}
+	if filter.Query != "" {
+		conditions = append(conditions, "a.search_vector @@ websearch_to_tsquery('simple', "+arg(filter.Query)+")")
+	}
+
+	var sortExpr string
+	switch filter.Sort {
+	case models.ActivityListSortDistance:
+		sortExpr = "COALESCE(a.distance, 0)"
+	case models.ActivityListSortElevation:
+		sortExpr = "COALESCE(a.elevation, 0)"
+	case models.ActivityListSortMovingTime:
+		sortExpr = "COALESCE(a.moving_time, 0)"
+	default:
+		sortExpr = "a.start_date"
+	}
+	direction, after := "ASC", ">"
+	if filter.Descending {
+		direction, after = "DESC", "<"
+	}
+	if filter.Cursor != nil {
+		var value interface{} = filter.Cursor.Value
+		if filter.Sort == models.ActivityListSortDate || filter.Sort == "" {
+			value = filter.Cursor.Date
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s, a.id) %s (%s, %s)", sortExpr, after, arg(value), arg(filter.Cursor.ID)))
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			a.id,
+			a.strava_activity_id,
+			a.strava_athlete_id,
+			a.user_id,
+			a.name,
+			COALESCE(a.activity_type, ''),
+			COALESCE(a.sport_type, ''),
+			COALESCE(a.description, ''),
+			COALESCE(a.distance, 0),
+			COALESCE(a.elevation, 0),
+			COALESCE(a.moving_time, 0),
+			a.start_date,
+			COALESCE(a.map_polyline, ''),
+			COALESCE(a.photo_url, ''),
+			COALESCE(a.has_summit, false),
+			a.private,
+			a.visibility
+		FROM activity a
+		WHERE %s
+		ORDER BY %s %s, a.id %s
+		LIMIT %s
+	`, strings.Join(conditions, "\n\t\t\tAND "), sortExpr, direction, direction, arg(filter.Limit+1))
+
+	rows, err := dao.db.Query(query, args...)
+	if err != nil {
+		dao.l.Printf("Error listing activities: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	activities := []models.Activity{}
+	for rows.Next() {
+		a := models.Activity{}
+		err := rows.Scan(
+			&a.ID,
+			&a.StravaActivityId,
+			&a.StravaAthleteId,
+			&a.UserID,
+			&a.Name,
+			&a.Type,
+			&a.SportType,
+			&a.Description,
+			&a.Distance,
+			&a.Elevation,
+			&a.MovingTime,
+			&a.StartDate,
+			&a.MapPolyline,
+			&a.PhotoURL,
+			&a.HasSummit,
+			&a.Private,
+			&a.Visibility,
+		)
+		if err != nil {
+			dao.l.Printf("Error scanning activity: %v", err)
+			return nil, err
+		}
+		activities = append(activities, a)
+	}
+	return activities, rows.Err()
+}
@@ -25,15 +25,17 @@ type ChallengeDaoInterface interface {
 	RemoveChallengePeak(challengeID int64, peakID int64) error
 	GetChallengePeaks(challengeID int64) ([]models.ChallengePeakWithDetails, error)
 	SetChallengePeaks(challengeID int64, peakIDs []int64) error
+	GetChallengesForPeak(peakID int64, userID int64) ([]models.PeakChallengeRef, error)
 
 	// Participants
 	JoinChallenge(challengeID int64, userID int64) error
 	LeaveChallenge(challengeID int64, userID int64) error
 	GetChallengeParticipants(challengeID int64) ([]models.ChallengeParticipantWithUser, error)
 	GetChallengeParticipantByUserID(challengeID int64, userID int64) (*models.ChallengeParticipant, error)
-	GetChallengeLeaderboard(challengeID int64) ([]models.LeaderboardEntry, error)
+	GetChallengeLeaderboard(challengeID int64, viewerID int64) ([]models.LeaderboardEntry, error)
+	GetChallengeStandings(challengeID int64) ([]models.ChallengeStanding, error)
 	UpdateParticipantProgress(challengeID int64, userID int64, peaksCompleted int, totalPeaks int) error
-	MarkParticipantCompleted(challengeID int64, userID int64) error
+	MarkParticipantCompleted(challengeID int64, userID int64) (bool, error)
 	IsUserParticipant(challengeID int64, userID int64) (bool, error)
 
 	// Groups
@@ -46,9 +48,10 @@ type ChallengeDaoInterface interface {
 	LogSummit(log models.ChallengeSummitLog) error
 	GetChallengeSummitLog(challengeID int64, userID *int64) ([]models.ChallengeSummitLogWithDetails, error)
 	HasUserSummitedPeakForChallenge(challengeID int64, userID int64, peakID int64) (bool, error)
+	DeleteSummitLogsForActivity(userID int64, peakID int64, activityID int64) ([]int64, error)
 
 	// Activities
-	GetChallengeActivities(challengeID int64) ([]models.ActivityWithUser, error)
+	GetChallengeActivities(challengeID int64, viewerID int64) ([]models.ActivityWithUser, error)
 }
 
 type ChallengeDao struct {
@@ -67,23 +70,7 @@ func NewChallengeDao(logger *log.Logger, db *sql.DB) *ChallengeDao {
 
 func (dao *ChallengeDao) CreateChallenge(challenge models.Challenge) (*int64, error) {
 	var id int64
-	query := `
-		INSERT INTO challenges (
-			name, description, challenge_type, goal_type, competition_mode, visibility,
-			start_date, deadline, created_by_user_id, created_by_group_id,
-			target_value, target_summit_count, region, difficulty, is_featured,
-			join_code, is_locked
-		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17
-		)
-		RETURNING id;
-	`
-	err := dao.db.QueryRow(query,
-		challenge.Name, challenge.Description, challenge.ChallengeType, challenge.GoalType, challenge.CompetitionMode, challenge.Visibility,
-		challenge.StartDate, challenge.Deadline, challenge.CreatedByUserID, challenge.CreatedByGroupID,
-		challenge.TargetValue, challenge.TargetSummitCount, challenge.Region, challenge.Difficulty, challenge.IsFeatured,
-		challenge.JoinCode, challenge.IsLocked,
-	).Scan(&id)
+	err := dao.db.QueryRow(challengeInsert, challengeInsertArgs(challenge)...).Scan(&id)
 	if err != nil {
 		dao.l.Printf("Error creating challenge: %v", err)
 		return nil, err
@@ -91,13 +78,36 @@ func (dao *ChallengeDao) CreateChallenge(challenge models.Challenge) (*int64, er
 	return &id, nil
 }
 
+// challengeInsert creates a challenge from challengeInsertArgs, returning its
+// id. It's shared with DAOs that create challenges in their own transactions.
+const challengeInsert = `
+	INSERT INTO challenges (
+		name, description, challenge_type, goal_type, competition_mode, visibility,
+		start_date, deadline, created_by_user_id, created_by_group_id,
+		target_value, target_summit_count, region, difficulty, is_featured,
+		join_code, is_locked, completion_rule, max_window_hours, streak_type
+	) VALUES (
+		$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20
+	)
+	RETURNING id;
+`
+
+func challengeInsertArgs(challenge models.Challenge) []interface{} {
+	return []interface{}{
+		challenge.Name, challenge.Description, challenge.ChallengeType, challenge.GoalType, challenge.CompetitionMode, challenge.Visibility,
+		challenge.StartDate, challenge.Deadline, challenge.CreatedByUserID, challenge.CreatedByGroupID,
+		challenge.TargetValue, challenge.TargetSummitCount, challenge.Region, challenge.Difficulty, challenge.IsFeatured,
+		challenge.JoinCode, challenge.IsLocked, challenge.CompletionRule, challenge.MaxWindowHours, challenge.StreakType,
+	}
+}
+
 func (dao *ChallengeDao) GetChallengeByID(id int64) (*models.Challenge, error) {
 	query := `
 		SELECT
 			id, name, description, challenge_type, goal_type, competition_mode, visibility,
 			start_date, deadline, created_by_user_id, created_by_group_id,
 			target_value, target_summit_count, region, difficulty, is_featured,
-			join_code, is_locked, created_at, updated_at
+			join_code, is_locked, completion_rule, max_window_hours, streak_type, created_at, updated_at
 		FROM challenges
 		WHERE id = $1;
 	`
@@ -106,7 +116,7 @@ func (dao *ChallengeDao) GetChallengeByID(id int64) (*models.Challenge, error) {
 		&c.ID, &c.Name, &c.Description, &c.ChallengeType, &c.GoalType, &c.CompetitionMode, &c.Visibility,
 		&c.StartDate, &c.Deadline, &c.CreatedByUserID, &c.CreatedByGroupID,
 		&c.TargetValue, &c.TargetSummitCount, &c.Region, &c.Difficulty, &c.IsFeatured,
-		&c.JoinCode, &c.IsLocked, &c.CreatedAt, &c.UpdatedAt,
+		&c.JoinCode, &c.IsLocked, &c.CompletionRule, &c.MaxWindowHours, &c.StreakType, &c.CreatedAt, &c.UpdatedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -134,13 +144,17 @@ func (dao *ChallengeDao) UpdateChallenge(challenge models.Challenge) error {
 			region = $12,
 			difficulty = $13,
 			is_featured = $14,
+			completion_rule = $15,
+			max_window_hours = $16,
+			streak_type = $17,
 			updated_at = NOW()
 		WHERE id = $1 AND is_locked = FALSE;
 	`
 	_, err := dao.db.Exec(query,
 		challenge.ID, challenge.Name, challenge.Description, challenge.ChallengeType, challenge.GoalType, challenge.CompetitionMode,
 		challenge.Visibility, challenge.StartDate, challenge.Deadline, challenge.TargetValue, challenge.TargetSummitCount,
-		challenge.Region, challenge.Difficulty, challenge.IsFeatured,
+		challenge.Region, challenge.Difficulty, challenge.IsFeatured, challenge.CompletionRule, challenge.MaxWindowHours,
+		challenge.StreakType,
 	)
 	if err != nil {
 		dao.l.Printf("Error updating challenge: %v", err)
@@ -167,12 +181,13 @@ func (dao *ChallengeDao) GetChallengesByUser(userID int64) ([]models.ChallengeWi
 			c.id, c.name, c.description, c.challenge_type, c.goal_type, c.competition_mode, c.visibility,
 			c.start_date, c.deadline, c.created_by_user_id, c.created_by_group_id,
 			c.target_value, c.target_summit_count, c.region, c.difficulty, c.is_featured,
-			c.join_code, c.is_locked, c.created_at, c.updated_at,
+			c.join_code, c.is_locked, c.completion_rule, c.max_window_hours, c.streak_type, c.created_at, c.updated_at,
 			COALESCE(cp.peaks_completed, 0) as peaks_completed,
 			COALESCE(cp.total_peaks, (SELECT COUNT(*) FROM challenge_peaks WHERE challenge_id = c.id)) as total_peaks,
 			COALESCE(cp.total_distance, 0) as total_distance,
 			COALESCE(cp.total_elevation, 0) as total_elevation,
 			COALESCE(cp.total_summit_count, 0) as total_summit_count,
+			cp.best_time_seconds,
 			cp.completed_at IS NOT NULL as is_completed
 		FROM challenges c
 		LEFT JOIN challenge_participants cp ON c.id = cp.challenge_id AND cp.user_id = $1
@@ -194,8 +209,8 @@ func (dao *ChallengeDao) GetChallengesByUser(userID int64) ([]models.ChallengeWi
 			&c.ID, &c.Name, &c.Description, &c.ChallengeType, &c.GoalType, &c.CompetitionMode, &c.Visibility,
 			&c.StartDate, &c.Deadline, &c.CreatedByUserID, &c.CreatedByGroupID,
 			&c.TargetValue, &c.TargetSummitCount, &c.Region, &c.Difficulty, &c.IsFeatured,
-			&c.JoinCode, &c.IsLocked, &c.CreatedAt, &c.UpdatedAt,
-			&c.CompletedPeaks, &c.TotalPeaks, &c.CurrentDistance, &c.CurrentElevation, &c.CurrentSummitCount, &c.IsCompleted,
+			&c.JoinCode, &c.IsLocked, &c.CompletionRule, &c.MaxWindowHours, &c.StreakType, &c.CreatedAt, &c.UpdatedAt,
+			&c.CompletedPeaks, &c.TotalPeaks, &c.CurrentDistance, &c.CurrentElevation, &c.CurrentSummitCount, &c.BestTimeSeconds, &c.IsCompleted,
 		)
 		if err != nil {
 			dao.l.Printf("Error scanning challenge: %v", err)
@@ -213,7 +228,7 @@ func (dao *ChallengeDao) GetFeaturedChallenges() ([]models.Challenge, error) {
 			id, name, description, challenge_type, goal_type, competition_mode, visibility,
 			start_date, deadline, created_by_user_id, created_by_group_id,
 			target_value, target_summit_count, region, difficulty, is_featured,
-			join_code, is_locked, created_at, updated_at
+			join_code, is_locked, completion_rule, max_window_hours, streak_type, created_at, updated_at
 		FROM challenges
 		WHERE is_featured = TRUE AND visibility = 'public'
 		ORDER BY name;
@@ -234,7 +249,7 @@ func (dao *ChallengeDao) GetPublicChallenges(region *string, limit int, offset i
 			c.id, c.name, c.description, c.challenge_type, c.goal_type, c.competition_mode, c.visibility,
 			c.start_date, c.deadline, c.created_by_user_id, c.created_by_group_id,
 			c.target_value, c.target_summit_count, c.region, c.difficulty, c.is_featured,
-			c.join_code, c.is_locked, c.created_at, c.updated_at
+			c.join_code, c.is_locked, c.completion_rule, c.max_window_hours, c.streak_type, c.created_at, c.updated_at
 		FROM challenges c
 		INNER JOIN users u ON c.created_by_user_id = u.id
 		WHERE c.visibility = 'public'
@@ -259,7 +274,7 @@ func (dao *ChallengeDao) SearchChallenges(queryStr string, limit int) ([]models.
 			id, name, description, challenge_type, goal_type, competition_mode, visibility,
 			start_date, deadline, created_by_user_id, created_by_group_id,
 			target_value, target_summit_count, region, difficulty, is_featured,
-			join_code, is_locked, created_at, updated_at
+			join_code, is_locked, completion_rule, max_window_hours, streak_type, created_at, updated_at
 		FROM challenges
 		WHERE visibility = 'public'
 		AND (name ILIKE '%' || $1 || '%' OR region ILIKE '%' || $1 || '%')
@@ -284,7 +299,7 @@ func (dao *ChallengeDao) scanChallenges(rows *sql.Rows) ([]models.Challenge, err
 			&c.ID, &c.Name, &c.Description, &c.ChallengeType, &c.GoalType, &c.CompetitionMode, &c.Visibility,
 			&c.StartDate, &c.Deadline, &c.CreatedByUserID, &c.CreatedByGroupID,
 			&c.TargetValue, &c.TargetSummitCount, &c.Region, &c.Difficulty, &c.IsFeatured,
-			&c.JoinCode, &c.IsLocked, &c.CreatedAt, &c.UpdatedAt,
+			&c.JoinCode, &c.IsLocked, &c.CompletionRule, &c.MaxWindowHours, &c.StreakType, &c.CreatedAt, &c.UpdatedAt,
 		)
 		if err != nil {
 			dao.l.Printf("Error scanning challenge: %v", err)
@@ -424,6 +439,43 @@ func (dao *ChallengeDao) SetChallengePeaks(challengeID int64, peakIDs []int64) e
 	return tx.Commit()
 }
 
+// GetChallengesForPeak returns the challenges that include a peak and that the
+// user can see: public ones, ones they created and ones they have joined
+func (dao *ChallengeDao) GetChallengesForPeak(peakID int64, userID int64) ([]models.PeakChallengeRef, error) {
+	query := `
+		SELECT c.id, c.name, c.goal_type, c.visibility, c.start_date, c.deadline
+		FROM challenges c
+		JOIN challenge_peaks chp ON chp.challenge_id = c.id
+		WHERE chp.peak_id = $1
+		AND (
+			c.visibility = 'public'
+			OR c.created_by_user_id = $2
+			OR EXISTS (
+				SELECT 1 FROM challenge_participants cp
+				WHERE cp.challenge_id = c.id AND cp.user_id = $2
+			)
+		)
+		ORDER BY c.start_date DESC, c.id;
+	`
+	rows, err := dao.db.Query(query, peakID, userID)
+	if err != nil {
+		dao.l.Printf("Error getting challenges for peak: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	challenges := []models.PeakChallengeRef{}
+	for rows.Next() {
+		var c models.PeakChallengeRef
+		if err := rows.Scan(&c.ID, &c.Name, &c.GoalType, &c.Visibility, &c.StartDate, &c.Deadline); err != nil {
+			dao.l.Printf("Error scanning challenge for peak: %v", err)
+			return nil, err
+		}
+		challenges = append(challenges, c)
+	}
+	return challenges, nil
+}
+
 // ==================== Participants ====================
 
 func (dao *ChallengeDao) JoinChallenge(challengeID int64, userID int64) error {
@@ -456,6 +508,8 @@ func (dao *ChallengeDao) GetChallengeParticipants(challengeID int64) ([]models.C
 			cp.id, cp.challenge_id, cp.user_id, cp.joined_at, cp.completed_at,
 			cp.peaks_completed, cp.total_peaks,
 			cp.total_distance, cp.total_elevation, cp.total_summit_count,
+			cp.satisfied_by_activity_id, cp.satisfied_window_start, cp.satisfied_window_end,
+			cp.best_time_seconds, cp.current_streak, cp.longest_streak,
 			COALESCE(u.username, '') as user_name,
 			u.strava_athlete_id
 		FROM challenge_participants cp
@@ -477,6 +531,8 @@ func (dao *ChallengeDao) GetChallengeParticipants(challengeID int64) ([]models.C
 			&p.ID, &p.ChallengeID, &p.UserID, &p.JoinedAt, &p.CompletedAt,
 			&p.PeaksCompleted, &p.TotalPeaks,
 			&p.TotalDistance, &p.TotalElevation, &p.TotalSummitCount,
+			&p.SatisfiedByActivityID, &p.SatisfiedWindowStart, &p.SatisfiedWindowEnd,
+			&p.BestTimeSeconds, &p.CurrentStreak, &p.LongestStreak,
 			&p.UserName,
 			&p.StravaAthleteID,
 		)
@@ -494,7 +550,9 @@ func (dao *ChallengeDao) GetChallengeParticipantByUserID(challengeID int64, user
 		SELECT
 			id, challenge_id, user_id, joined_at, completed_at,
 			peaks_completed, total_peaks,
-			total_distance, total_elevation, total_summit_count
+			total_distance, total_elevation, total_summit_count,
+			satisfied_by_activity_id, satisfied_window_start, satisfied_window_end,
+			best_time_seconds, current_streak, longest_streak
 		FROM challenge_participants
 		WHERE challenge_id = $1 AND user_id = $2;
 	`
@@ -503,6 +561,8 @@ func (dao *ChallengeDao) GetChallengeParticipantByUserID(challengeID int64, user
 		&p.ID, &p.ChallengeID, &p.UserID, &p.JoinedAt, &p.CompletedAt,
 		&p.PeaksCompleted, &p.TotalPeaks,
 		&p.TotalDistance, &p.TotalElevation, &p.TotalSummitCount,
+		&p.SatisfiedByActivityID, &p.SatisfiedWindowStart, &p.SatisfiedWindowEnd,
+		&p.BestTimeSeconds, &p.CurrentStreak, &p.LongestStreak,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -514,19 +574,33 @@ func (dao *ChallengeDao) GetChallengeParticipantByUserID(challengeID int64, user
 	return &p, nil
 }
 
-func (dao *ChallengeDao) GetChallengeLeaderboard(challengeID int64) ([]models.LeaderboardEntry, error) {
+// leaderboardOrder sorts challenge_participants cp joined to challenges c
+// into leaderboard order
+const leaderboardOrder = `
+		ORDER BY
+			-- Fastest time challenges rank by best time, quickest first
+			CASE WHEN c.goal_type = 'fastest_time' THEN cp.best_time_seconds END ASC NULLS LAST,
+			-- Streak challenges rank by longest streak, then the streak still running
+			CASE WHEN c.goal_type = 'streak' THEN cp.longest_streak END DESC NULLS LAST,
+			CASE WHEN c.goal_type = 'streak' THEN cp.current_streak END DESC NULLS LAST,
+			cp.peaks_completed DESC, cp.total_distance DESC, cp.total_elevation DESC, cp.total_summit_count DESC, cp.completed_at ASC NULLS LAST, cp.joined_at ASC;
+`
+
+func (dao *ChallengeDao) GetChallengeLeaderboard(challengeID int64, viewerID int64) ([]models.LeaderboardEntry, error) {
 	query := `
 		SELECT
 			cp.user_id, COALESCE(u.username, '') as user_name, u.strava_athlete_id,
 			cp.peaks_completed, cp.total_peaks,
 			cp.total_distance, cp.total_elevation, cp.total_summit_count,
-			cp.joined_at, cp.completed_at
+			cp.best_time_seconds, cp.current_streak, cp.longest_streak, cp.joined_at, cp.completed_at,
+			c.goal_type,
+			(u.show_in_leaderboards OR cp.user_id = $2) AS visible
 		FROM challenge_participants cp
 		JOIN users u ON cp.user_id = u.id
+		JOIN challenges c ON cp.challenge_id = c.id
 		WHERE cp.challenge_id = $1
-		ORDER BY cp.peaks_completed DESC, cp.total_distance DESC, cp.total_elevation DESC, cp.total_summit_count DESC, cp.completed_at ASC NULLS LAST, cp.joined_at ASC;
-	`
-	rows, err := dao.db.Query(query, challengeID)
+	` + leaderboardOrder
+	rows, err := dao.db.Query(query, challengeID, viewerID)
 	if err != nil {
 		dao.l.Printf("Error getting challenge leaderboard: %v", err)
 		return nil, err
@@ -536,24 +610,50 @@ func (dao *ChallengeDao) GetChallengeLeaderboard(challengeID int64) ([]models.Le
 	var leaderboard []models.LeaderboardEntry
 	rank := 0
 	prevPeaks := -1
+	prevBestTime := -1
+	prevStreak := -1
 	actualRank := 0
 
 	for rows.Next() {
 		var entry models.LeaderboardEntry
+		var goalType models.GoalType
+		var visible bool
 		err := rows.Scan(
 			&entry.UserID, &entry.UserName, &entry.StravaAthleteID,
 			&entry.PeaksCompleted, &entry.TotalPeaks,
 			&entry.TotalDistance, &entry.TotalElevation, &entry.TotalSummitCount,
-			&entry.JoinedAt, &entry.CompletedAt,
+			&entry.BestTimeSeconds, &entry.CurrentStreak, &entry.LongestStreak, &entry.JoinedAt, &entry.CompletedAt,
+			&goalType, &visible,
 		)
 		if err != nil {
 			dao.l.Printf("Error scanning leaderboard entry: %v", err)
 			return nil, err
 		}
+		if !visible {
+			entry.UserID = 0
+			entry.UserName = ""
+			entry.StravaAthleteID = 0
+		}
 
 		actualRank++
-		// Handle tied rankings - same peaks_completed = same rank
-		if entry.PeaksCompleted != prevPeaks {
+		if goalType == models.GoalTypeFastestTime {
+			// Handle tied rankings - same best time = same rank (no time sorts last)
+			bestTime := 0
+			if entry.BestTimeSeconds != nil {
+				bestTime = *entry.BestTimeSeconds
+			}
+			if bestTime != prevBestTime {
+				rank = actualRank
+				prevBestTime = bestTime
+			}
+		} else if goalType == models.GoalTypeStreak {
+			// Handle tied rankings - same longest streak = same rank
+			if entry.LongestStreak != prevStreak {
+				rank = actualRank
+				prevStreak = entry.LongestStreak
+			}
+		} else if entry.PeaksCompleted != prevPeaks {
+			// Handle tied rankings - same peaks_completed = same rank
 			rank = actualRank
 			prevPeaks = entry.PeaksCompleted
 		}
@@ -569,6 +669,35 @@ func (dao *ChallengeDao) GetChallengeLeaderboard(challengeID int64) ([]models.Le
 	return leaderboard, nil
 }
 
+// GetChallengeStandings returns every participant in leaderboard order.
+// Names are left empty for participants hidden from leaderboards.
+func (dao *ChallengeDao) GetChallengeStandings(challengeID int64) ([]models.ChallengeStanding, error) {
+	query := `
+		SELECT cp.user_id, CASE WHEN u.show_in_leaderboards THEN COALESCE(u.username, '') ELSE '' END
+		FROM challenge_participants cp
+		JOIN users u ON cp.user_id = u.id
+		JOIN challenges c ON cp.challenge_id = c.id
+		WHERE cp.challenge_id = $1
+	` + leaderboardOrder
+	rows, err := dao.db.Query(query, challengeID)
+	if err != nil {
+		dao.l.Printf("Error getting challenge standings: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	standings := []models.ChallengeStanding{}
+	for rows.Next() {
+		standing := models.ChallengeStanding{Position: len(standings) + 1}
+		if err := rows.Scan(&standing.UserID, &standing.UserName); err != nil {
+			dao.l.Printf("Error scanning challenge standing: %v", err)
+			return nil, err
+		}
+		standings = append(standings, standing)
+	}
+	return standings, rows.Err()
+}
+
 func (dao *ChallengeDao) UpdateParticipantProgress(challengeID int64, userID int64, peaksCompleted int, totalPeaks int) error {
 	query := `
 		UPDATE challenge_participants
@@ -602,15 +731,69 @@ func (dao *ChallengeDao) UpdateParticipantProgressFull(challengeID int64, userID
 	return nil
 }
 
-func (dao *ChallengeDao) MarkParticipantCompleted(challengeID int64, userID int64) error {
+// MarkParticipantCompleted sets when the participant completed the
+// challenge. It returns false if they already had.
+func (dao *ChallengeDao) MarkParticipantCompleted(challengeID int64, userID int64) (bool, error) {
 	query := `
 		UPDATE challenge_participants
 		SET completed_at = NOW()
 		WHERE challenge_id = $1 AND user_id = $2 AND completed_at IS NULL;
 	`
-	_, err := dao.db.Exec(query, challengeID, userID)
+	result, err := dao.db.Exec(query, challengeID, userID)
 	if err != nil {
 		dao.l.Printf("Error marking participant completed: %v", err)
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// SetParticipantSatisfaction records which activity or time window satisfied the challenge
+func (dao *ChallengeDao) SetParticipantSatisfaction(challengeID int64, userID int64, activityID *int64, windowStart *time.Time, windowEnd *time.Time) error {
+	query := `
+		UPDATE challenge_participants
+		SET satisfied_by_activity_id = $3,
+		    satisfied_window_start = $4,
+		    satisfied_window_end = $5
+		WHERE challenge_id = $1 AND user_id = $2;
+	`
+	_, err := dao.db.Exec(query, challengeID, userID, activityID, windowStart, windowEnd)
+	if err != nil {
+		dao.l.Printf("Error setting participant satisfaction: %v", err)
+		return err
+	}
+	return nil
+}
+
+// UpdateParticipantStreak records the participant's streaks within the challenge
+func (dao *ChallengeDao) UpdateParticipantStreak(challengeID int64, userID int64, currentStreak int, longestStreak int) error {
+	query := `
+		UPDATE challenge_participants
+		SET current_streak = $3,
+		    longest_streak = $4
+		WHERE challenge_id = $1 AND user_id = $2;
+	`
+	_, err := dao.db.Exec(query, challengeID, userID, currentStreak, longestStreak)
+	if err != nil {
+		dao.l.Printf("Error updating participant streak: %v", err)
+		return err
+	}
+	return nil
+}
+
+// SetParticipantBestTime records the participant's fastest qualifying time
+func (dao *ChallengeDao) SetParticipantBestTime(challengeID int64, userID int64, bestTimeSeconds *int) error {
+	query := `
+		UPDATE challenge_participants
+		SET best_time_seconds = $3
+		WHERE challenge_id = $1 AND user_id = $2;
+	`
+	_, err := dao.db.Exec(query, challengeID, userID, bestTimeSeconds)
+	if err != nil {
+		dao.l.Printf("Error setting participant best time: %v", err)
 		return err
 	}
 	return nil
@@ -659,6 +842,29 @@ func (dao *ChallengeDao) GetAllActiveParticipants() ([]models.ChallengeParticipa
 	return participants, nil
 }
 
+// GetChallengesEndingWithin returns challenges whose deadline falls between
+// now and the end of the window
+func (dao *ChallengeDao) GetChallengesEndingWithin(window time.Duration) ([]models.Challenge, error) {
+	query := `
+		SELECT
+			id, name, description, challenge_type, goal_type, competition_mode, visibility,
+			start_date, deadline, created_by_user_id, created_by_group_id,
+			target_value, target_summit_count, region, difficulty, is_featured,
+			join_code, is_locked, completion_rule, max_window_hours, streak_type, created_at, updated_at
+		FROM challenges
+		WHERE deadline > NOW() AND deadline <= $1
+		ORDER BY deadline;
+	`
+	rows, err := dao.db.Query(query, time.Now().Add(window))
+	if err != nil {
+		dao.l.Printf("Error getting challenges ending soon: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	return dao.scanChallenges(rows)
+}
+
 // ==================== Groups ====================
 
 func (dao *ChallengeDao) AddGroupToChallenge(challengeID int64, groupID int64, deadlineOverride *time.Time) error {
@@ -725,7 +931,7 @@ func (dao *ChallengeDao) GetGroupChallenges(groupID int64) ([]models.Challenge,
 			c.id, c.name, c.description, c.challenge_type, c.goal_type, c.competition_mode, c.visibility,
 			c.start_date, c.deadline, c.created_by_user_id, c.created_by_group_id,
 			c.target_value, c.target_summit_count, c.region, c.difficulty, c.is_featured,
-			c.join_code, c.is_locked, c.created_at, c.updated_at
+			c.join_code, c.is_locked, c.completion_rule, c.max_window_hours, c.streak_type, c.created_at, c.updated_at
 		FROM challenges c
 		JOIN challenge_groups cg ON c.id = cg.challenge_id
 		WHERE cg.group_id = $1
@@ -747,7 +953,7 @@ func (dao *ChallengeDao) GetChallengeByJoinCode(joinCode string) (*models.Challe
 			id, name, description, challenge_type, goal_type, competition_mode, visibility,
 			start_date, deadline, created_by_user_id, created_by_group_id,
 			target_value, target_summit_count, region, difficulty, is_featured,
-			join_code, is_locked, created_at, updated_at
+			join_code, is_locked, completion_rule, max_window_hours, streak_type, created_at, updated_at
 		FROM challenges
 		WHERE join_code = $1;
 	`
@@ -756,7 +962,7 @@ func (dao *ChallengeDao) GetChallengeByJoinCode(joinCode string) (*models.Challe
 		&c.ID, &c.Name, &c.Description, &c.ChallengeType, &c.GoalType, &c.CompetitionMode, &c.Visibility,
 		&c.StartDate, &c.Deadline, &c.CreatedByUserID, &c.CreatedByGroupID,
 		&c.TargetValue, &c.TargetSummitCount, &c.Region, &c.Difficulty, &c.IsFeatured,
-		&c.JoinCode, &c.IsLocked, &c.CreatedAt, &c.UpdatedAt,
+		&c.JoinCode, &c.IsLocked, &c.CompletionRule, &c.MaxWindowHours, &c.StreakType, &c.CreatedAt, &c.UpdatedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -852,9 +1058,36 @@ func (dao *ChallengeDao) HasUserSummitedPeakForChallenge(challengeID int64, user
 	return exists, nil
 }
 
+// DeleteSummitLogsForActivity removes the credit a summit on one activity
+// earned in any challenge, returning the challenges affected
+func (dao *ChallengeDao) DeleteSummitLogsForActivity(userID int64, peakID int64, activityID int64) ([]int64, error) {
+	query := `
+		DELETE FROM challenge_summit_log
+		WHERE user_id = $1 AND peak_id = $2 AND activity_id = $3
+		RETURNING challenge_id;
+	`
+	rows, err := dao.db.Query(query, userID, peakID, activityID)
+	if err != nil {
+		dao.l.Printf("Error deleting summit logs: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	challengeIDs := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			dao.l.Printf("Error scanning deleted summit log: %v", err)
+			return nil, err
+		}
+		challengeIDs = append(challengeIDs, id)
+	}
+	return challengeIDs, rows.Err()
+}
+
 // ==================== Activities ====================
 
-func (dao *ChallengeDao) GetChallengeActivities(challengeID int64) ([]models.ActivityWithUser, error) {
+func (dao *ChallengeDao) GetChallengeActivities(challengeID int64, viewerID int64) ([]models.ActivityWithUser, error) {
 	// First get the challenge to determine goal type
 	challenge, err := dao.GetChallengeByID(challengeID)
 	if err != nil {
@@ -899,6 +1132,7 @@ func (dao *ChallengeDao) GetChallengeActivities(challengeID int64) ([]models.Act
 			WHERE cp.challenge_id = $1
 				AND (c.start_date IS NULL OR a.start_date >= c.start_date)
 				AND (c.deadline IS NULL OR a.start_date <= c.deadline)
+				AND (a.user_id = $2 OR (NOT a.private AND a.visibility <> 'only_me' AND u.show_in_challenge_feeds))
 			GROUP BY a.id, a.strava_activity_id, a.strava_athlete_id, a.user_id,
 			         a.name, a.description, a.distance, a.elevation, a.moving_time,
 			         a.start_date, a.map_polyline, a.photo_url, u.username, u.strava_athlete_id
@@ -929,10 +1163,11 @@ func (dao *ChallengeDao) GetChallengeActivities(challengeID int64) ([]models.Act
 			WHERE cp.challenge_id = $1
 				AND (c.start_date IS NULL OR a.start_date >= c.start_date)
 				AND (c.deadline IS NULL OR a.start_date <= c.deadline)
+				AND (a.user_id = $2 OR (NOT a.private AND a.visibility <> 'only_me' AND u.show_in_challenge_feeds))
 			ORDER BY a.start_date DESC;
 		`
 	}
-	rows, err := dao.db.Query(query, challengeID)
+	rows, err := dao.db.Query(query, challengeID, viewerID)
 	if err != nil {
 		dao.l.Printf("Error querying activities for challenge: %v", err)
 		return nil, err
@@ -0,0 +1,438 @@
+package daos
+
+import (
+	"database/sql"
+	"log"
+	"run-goals/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type ChallengeSeriesDaoInterface interface {
+	// Series CRUD
+	CreateSeries(series models.ChallengeSeries) (*int64, error)
+	GetSeriesByID(id int64) (*models.ChallengeSeries, error)
+	UpdateSeries(series models.ChallengeSeries) error
+	DeleteSeries(id int64) error
+	GetSeriesForUser(userID int64) ([]models.ChallengeSeries, error)
+
+	// Scheduling
+	GetDueSeries(now time.Time) ([]models.ChallengeSeries, error)
+	AdvanceSeries(id int64, nextPeriodStart time.Time, isActive bool) error
+
+	// Instances
+	CreateInstanceChallenge(challenge models.Challenge, peakIDs []int64, instance models.ChallengeSeriesInstance, expectedPeriodStart time.Time, isActive bool) (*int64, error)
+	GetLatestInstance(seriesID int64) (*models.ChallengeSeriesInstance, error)
+	GetSeriesHistory(seriesID int64) ([]models.ChallengeSeriesInstanceWithStats, error)
+	GetSeriesLeaderboard(seriesID int64, viewerID int64) ([]models.SeriesLeaderboardEntry, error)
+}
+
+type ChallengeSeriesDao struct {
+	l  *log.Logger
+	db *sql.DB
+}
+
+func NewChallengeSeriesDao(logger *log.Logger, db *sql.DB) *ChallengeSeriesDao {
+	return &ChallengeSeriesDao{
+		l:  logger,
+		db: db,
+	}
+}
+
+// ==================== Series CRUD ====================
+
+func (dao *ChallengeSeriesDao) CreateSeries(series models.ChallengeSeries) (*int64, error) {
+	var id int64
+	query := `
+		INSERT INTO challenge_series (
+			name, description, created_by_user_id, created_by_group_id,
+			goal_type, competition_mode, visibility,
+			target_value, target_summit_count, peak_ids, region, difficulty, completion_rule, max_window_hours, streak_type,
+			recurrence, interval_days, starts_at, ends_at, next_period_start,
+			carry_over_participants, is_active
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22
+		)
+		RETURNING id;
+	`
+	err := dao.db.QueryRow(query,
+		series.Name, series.Description, series.CreatedByUserID, series.CreatedByGroupID,
+		series.GoalType, series.CompetitionMode, series.Visibility,
+		series.TargetValue, series.TargetSummitCount, pq.Array(series.PeakIDs), series.Region, series.Difficulty,
+		series.CompletionRule, series.MaxWindowHours, series.StreakType,
+		series.Recurrence, series.IntervalDays, series.StartsAt, series.EndsAt, series.NextPeriodStart,
+		series.CarryOverParticipants, series.IsActive,
+	).Scan(&id)
+	if err != nil {
+		dao.l.Printf("Error creating challenge series: %v", err)
+		return nil, err
+	}
+	return &id, nil
+}
+
+func (dao *ChallengeSeriesDao) GetSeriesByID(id int64) (*models.ChallengeSeries, error) {
+	query := `
+		SELECT
+			id, name, description, created_by_user_id, created_by_group_id,
+			goal_type, competition_mode, visibility,
+			target_value, target_summit_count, peak_ids, region, difficulty, completion_rule, max_window_hours, streak_type,
+			recurrence, interval_days, starts_at, ends_at, next_period_start,
+			carry_over_participants, is_active, created_at, updated_at
+		FROM challenge_series
+		WHERE id = $1;
+	`
+	rows, err := dao.db.Query(query, id)
+	if err != nil {
+		dao.l.Printf("Error getting challenge series by ID: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	series, err := dao.scanSeries(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(series) == 0 {
+		return nil, nil
+	}
+	return &series[0], nil
+}
+
+func (dao *ChallengeSeriesDao) UpdateSeries(series models.ChallengeSeries) error {
+	query := `
+		UPDATE challenge_series SET
+			name = $2,
+			description = $3,
+			goal_type = $4,
+			competition_mode = $5,
+			visibility = $6,
+			target_value = $7,
+			target_summit_count = $8,
+			peak_ids = $9,
+			region = $10,
+			difficulty = $11,
+			ends_at = $12,
+			carry_over_participants = $13,
+			is_active = $14,
+			completion_rule = $15,
+			max_window_hours = $16,
+			streak_type = $17,
+			updated_at = NOW()
+		WHERE id = $1;
+	`
+	_, err := dao.db.Exec(query,
+		series.ID, series.Name, series.Description,
+		series.GoalType, series.CompetitionMode, series.Visibility,
+		series.TargetValue, series.TargetSummitCount, pq.Array(series.PeakIDs), series.Region, series.Difficulty,
+		series.EndsAt, series.CarryOverParticipants, series.IsActive,
+		series.CompletionRule, series.MaxWindowHours, series.StreakType,
+	)
+	if err != nil {
+		dao.l.Printf("Error updating challenge series: %v", err)
+		return err
+	}
+	return nil
+}
+
+// DeleteSeries removes the series and its instance links. Challenges that were
+// already created are kept as standalone challenges.
+func (dao *ChallengeSeriesDao) DeleteSeries(id int64) error {
+	query := `DELETE FROM challenge_series WHERE id = $1;`
+	_, err := dao.db.Exec(query, id)
+	if err != nil {
+		dao.l.Printf("Error deleting challenge series: %v", err)
+		return err
+	}
+	return nil
+}
+
+// GetSeriesForUser returns series the user created, can see publicly, or has taken part in
+func (dao *ChallengeSeriesDao) GetSeriesForUser(userID int64) ([]models.ChallengeSeries, error) {
+	query := `
+		SELECT
+			s.id, s.name, s.description, s.created_by_user_id, s.created_by_group_id,
+			s.goal_type, s.competition_mode, s.visibility,
+			s.target_value, s.target_summit_count, s.peak_ids, s.region, s.difficulty, s.completion_rule, s.max_window_hours, s.streak_type,
+			s.recurrence, s.interval_days, s.starts_at, s.ends_at, s.next_period_start,
+			s.carry_over_participants, s.is_active, s.created_at, s.updated_at
+		FROM challenge_series s
+		WHERE s.created_by_user_id = $1
+		   OR s.visibility = 'public'
+		   OR EXISTS (
+				SELECT 1
+				FROM challenge_series_instances csi
+				JOIN challenge_participants cp ON cp.challenge_id = csi.challenge_id
+				WHERE csi.series_id = s.id AND cp.user_id = $1
+		   )
+		ORDER BY s.created_at DESC;
+	`
+	rows, err := dao.db.Query(query, userID)
+	if err != nil {
+		dao.l.Printf("Error getting challenge series for user: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	return dao.scanSeries(rows)
+}
+
+func (dao *ChallengeSeriesDao) scanSeries(rows *sql.Rows) ([]models.ChallengeSeries, error) {
+	var series []models.ChallengeSeries
+	for rows.Next() {
+		var s models.ChallengeSeries
+		var peakIDs pq.Int64Array
+		err := rows.Scan(
+			&s.ID, &s.Name, &s.Description, &s.CreatedByUserID, &s.CreatedByGroupID,
+			&s.GoalType, &s.CompetitionMode, &s.Visibility,
+			&s.TargetValue, &s.TargetSummitCount, &peakIDs, &s.Region, &s.Difficulty, &s.CompletionRule, &s.MaxWindowHours, &s.StreakType,
+			&s.Recurrence, &s.IntervalDays, &s.StartsAt, &s.EndsAt, &s.NextPeriodStart,
+			&s.CarryOverParticipants, &s.IsActive, &s.CreatedAt, &s.UpdatedAt,
+		)
+		if err != nil {
+			dao.l.Printf("Error scanning challenge series: %v", err)
+			return nil, err
+		}
+		s.PeakIDs = []int64(peakIDs)
+		series = append(series, s)
+	}
+	return series, nil
+}
+
+// ==================== Scheduling ====================
+
+// GetDueSeries returns active series whose next period has started
+func (dao *ChallengeSeriesDao) GetDueSeries(now time.Time) ([]models.ChallengeSeries, error) {
+	query := `
+		SELECT
+			id, name, description, created_by_user_id, created_by_group_id,
+			goal_type, competition_mode, visibility,
+			target_value, target_summit_count, peak_ids, region, difficulty, completion_rule, max_window_hours, streak_type,
+			recurrence, interval_days, starts_at, ends_at, next_period_start,
+			carry_over_participants, is_active, created_at, updated_at
+		FROM challenge_series
+		WHERE is_active = TRUE
+		AND next_period_start <= $1
+		ORDER BY next_period_start;
+	`
+	rows, err := dao.db.Query(query, now)
+	if err != nil {
+		dao.l.Printf("Error getting due challenge series: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	return dao.scanSeries(rows)
+}
+
+// AdvanceSeries moves the series on to its next period
+func (dao *ChallengeSeriesDao) AdvanceSeries(id int64, nextPeriodStart time.Time, isActive bool) error {
+	query := `
+		UPDATE challenge_series
+		SET next_period_start = $2, is_active = $3, updated_at = NOW()
+		WHERE id = $1;
+	`
+	_, err := dao.db.Exec(query, id, nextPeriodStart, isActive)
+	if err != nil {
+		dao.l.Printf("Error advancing challenge series: %v", err)
+		return err
+	}
+	return nil
+}
+
+// ==================== Instances ====================
+
+// CreateInstanceChallenge creates an instance's challenge with its peaks,
+// links it to the series and moves the series on to the period after it,
+// all in one transaction. The series is only advanced from
+// expectedPeriodStart, so if another run already created the instance
+// nothing is written and nil is returned.
+func (dao *ChallengeSeriesDao) CreateInstanceChallenge(
+	challenge models.Challenge,
+	peakIDs []int64,
+	instance models.ChallengeSeriesInstance,
+	expectedPeriodStart time.Time,
+	isActive bool,
+) (*int64, error) {
+	tx, err := dao.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE challenge_series
+		SET next_period_start = $2, is_active = $3, updated_at = NOW()
+		WHERE id = $1 AND next_period_start = $4;
+	`, instance.SeriesID, instance.PeriodEnd, isActive, expectedPeriodStart)
+	if err != nil {
+		dao.l.Printf("Error advancing challenge series: %v", err)
+		return nil, err
+	}
+	advanced, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if advanced == 0 {
+		return nil, nil
+	}
+
+	var challengeID int64
+	if err := tx.QueryRow(challengeInsert, challengeInsertArgs(challenge)...).Scan(&challengeID); err != nil {
+		dao.l.Printf("Error creating series instance challenge: %v", err)
+		return nil, err
+	}
+	for i, peakID := range peakIDs {
+		_, err = tx.Exec(
+			`INSERT INTO challenge_peaks (challenge_id, peak_id, sort_order) VALUES ($1, $2, $3)`,
+			challengeID, peakID, i,
+		)
+		if err != nil {
+			dao.l.Printf("Error inserting series instance challenge peak: %v", err)
+			return nil, err
+		}
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO challenge_series_instances (series_id, challenge_id, instance_number, period_start, period_end)
+		VALUES ($1, $2, $3, $4, $5);
+	`, instance.SeriesID, challengeID, instance.InstanceNumber, instance.PeriodStart, instance.PeriodEnd)
+	if err != nil {
+		dao.l.Printf("Error creating challenge series instance: %v", err)
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		dao.l.Printf("Error committing challenge series instance: %v", err)
+		return nil, err
+	}
+	return &challengeID, nil
+}
+
+func (dao *ChallengeSeriesDao) GetLatestInstance(seriesID int64) (*models.ChallengeSeriesInstance, error) {
+	query := `
+		SELECT id, series_id, challenge_id, instance_number, period_start, period_end, created_at
+		FROM challenge_series_instances
+		WHERE series_id = $1
+		ORDER BY instance_number DESC
+		LIMIT 1;
+	`
+	var i models.ChallengeSeriesInstance
+	err := dao.db.QueryRow(query, seriesID).Scan(
+		&i.ID, &i.SeriesID, &i.ChallengeID, &i.InstanceNumber, &i.PeriodStart, &i.PeriodEnd, &i.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		dao.l.Printf("Error getting latest challenge series instance: %v", err)
+		return nil, err
+	}
+	return &i, nil
+}
+
+// GetSeriesHistory returns every instance of a series, newest first
+func (dao *ChallengeSeriesDao) GetSeriesHistory(seriesID int64) ([]models.ChallengeSeriesInstanceWithStats, error) {
+	query := `
+		SELECT
+			csi.id, csi.series_id, csi.challenge_id, csi.instance_number,
+			csi.period_start, csi.period_end, csi.created_at,
+			c.name AS challenge_name,
+			(SELECT COUNT(*) FROM challenge_participants WHERE challenge_id = c.id) AS participant_count,
+			(SELECT COUNT(*) FROM challenge_participants WHERE challenge_id = c.id AND completed_at IS NOT NULL) AS completed_count
+		FROM challenge_series_instances csi
+		JOIN challenges c ON c.id = csi.challenge_id
+		WHERE csi.series_id = $1
+		ORDER BY csi.instance_number DESC;
+	`
+	rows, err := dao.db.Query(query, seriesID)
+	if err != nil {
+		dao.l.Printf("Error getting challenge series history: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []models.ChallengeSeriesInstanceWithStats
+	for rows.Next() {
+		var i models.ChallengeSeriesInstanceWithStats
+		err := rows.Scan(
+			&i.ID, &i.SeriesID, &i.ChallengeID, &i.InstanceNumber,
+			&i.PeriodStart, &i.PeriodEnd, &i.CreatedAt,
+			&i.ChallengeName, &i.ParticipantCount, &i.CompletedCount,
+		)
+		if err != nil {
+			dao.l.Printf("Error scanning challenge series instance: %v", err)
+			return nil, err
+		}
+		history = append(history, i)
+	}
+	return history, nil
+}
+
+// GetSeriesLeaderboard aggregates participant results across all instances of a series.
+// Users hidden from leaderboards keep their place but not their name, unless
+// they're the viewer.
+func (dao *ChallengeSeriesDao) GetSeriesLeaderboard(seriesID int64, viewerID int64) ([]models.SeriesLeaderboardEntry, error) {
+	query := `
+		SELECT
+			cp.user_id, COALESCE(u.username, '') AS user_name, u.strava_athlete_id,
+			COUNT(*) AS instances_joined,
+			COUNT(cp.completed_at) AS instances_completed,
+			COALESCE(SUM(cp.peaks_completed), 0) AS peaks_completed,
+			COALESCE(SUM(cp.total_distance), 0) AS total_distance,
+			COALESCE(SUM(cp.total_elevation), 0) AS total_elevation,
+			COALESCE(SUM(cp.total_summit_count), 0) AS total_summit_count,
+			(u.show_in_leaderboards OR cp.user_id = $2) AS visible
+		FROM challenge_series_instances csi
+		JOIN challenge_participants cp ON cp.challenge_id = csi.challenge_id
+		JOIN users u ON u.id = cp.user_id
+		WHERE csi.series_id = $1
+		GROUP BY cp.user_id, u.username, u.strava_athlete_id, u.show_in_leaderboards
+		ORDER BY instances_completed DESC, peaks_completed DESC, total_summit_count DESC,
+		         total_distance DESC, total_elevation DESC, instances_joined DESC;
+	`
+	rows, err := dao.db.Query(query, seriesID, viewerID)
+	if err != nil {
+		dao.l.Printf("Error getting challenge series leaderboard: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var leaderboard []models.SeriesLeaderboardEntry
+	rank := 0
+	prevCompleted := -1
+	actualRank := 0
+
+	for rows.Next() {
+		var entry models.SeriesLeaderboardEntry
+		var stravaAthleteID sql.NullInt64
+		var visible bool
+		err := rows.Scan(
+			&entry.UserID, &entry.UserName, &stravaAthleteID,
+			&entry.InstancesJoined, &entry.InstancesCompleted,
+			&entry.PeaksCompleted, &entry.TotalDistance, &entry.TotalElevation, &entry.TotalSummitCount,
+			&visible,
+		)
+		if err != nil {
+			dao.l.Printf("Error scanning series leaderboard entry: %v", err)
+			return nil, err
+		}
+		entry.StravaAthleteID = stravaAthleteID.Int64
+		if !visible {
+			entry.UserID = 0
+			entry.UserName = ""
+			entry.StravaAthleteID = 0
+		}
+
+		actualRank++
+		// Same number of completed instances = same rank
+		if entry.InstancesCompleted != prevCompleted {
+			rank = actualRank
+			prevCompleted = entry.InstancesCompleted
+		}
+		entry.Rank = rank
+
+		leaderboard = append(leaderboard, entry)
+	}
+	return leaderboard, nil
+}
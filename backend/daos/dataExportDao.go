@@ -0,0 +1,235 @@
+package daos
+
+import (
+	"database/sql"
+	"log"
+	"run-goals/models"
+	"time"
+)
+
+type DataExportDaoInterface interface {
+	CreateExport(userID int64) (*int64, error)
+	GetExportByID(id int64) (*models.DataExport, error)
+	GetLatestExport(userID int64) (*models.DataExport, error)
+	GetExportsByUser(userID int64) ([]models.DataExport, error)
+	GetReadyExportByToken(token string) (*models.DataExport, error)
+	StartNextExport() (*models.DataExport, error)
+	HeartbeatExport(id int64) error
+	RequeueStaleExports(staleAfter time.Duration) (int64, error)
+	CompleteExport(id int64, token string, filePath string, sizeBytes int64, expiresAt time.Time) error
+	FailExport(id int64, message string) error
+	GetExpiredExports() ([]models.DataExport, error)
+	MarkExportExpired(id int64) error
+}
+
+type DataExportDao struct {
+	l  *log.Logger
+	db *sql.DB
+}
+
+func NewDataExportDao(logger *log.Logger, db *sql.DB) *DataExportDao {
+	return &DataExportDao{
+		l:  logger,
+		db: db,
+	}
+}
+
+const dataExportSelect = `
+	SELECT
+		id, user_id, status, COALESCE(token, ''), COALESCE(file_path, ''), size_bytes, error,
+		created_at, started_at, completed_at, expires_at
+	FROM data_exports
+`
+
+// CreateExport queues an export for the user. It returns sql.ErrNoRows if
+// they already have one pending or running.
+func (dao *DataExportDao) CreateExport(userID int64) (*int64, error) {
+	var id int64
+	query := `
+		INSERT INTO data_exports (user_id)
+		VALUES ($1)
+		ON CONFLICT (user_id) WHERE status IN ('pending', 'running') DO NOTHING
+		RETURNING id;
+	`
+	err := dao.db.QueryRow(query, userID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		dao.l.Printf("Error creating data export: %v", err)
+		return nil, err
+	}
+	return &id, nil
+}
+
+func (dao *DataExportDao) GetExportByID(id int64) (*models.DataExport, error) {
+	return dao.getExport(dataExportSelect+`WHERE id = $1`, id)
+}
+
+// GetLatestExport returns the user's most recent export, or nil if they've
+// never asked for one
+func (dao *DataExportDao) GetLatestExport(userID int64) (*models.DataExport, error) {
+	return dao.getExport(dataExportSelect+`WHERE user_id = $1 ORDER BY created_at DESC, id DESC LIMIT 1`, userID)
+}
+
+func (dao *DataExportDao) GetExportsByUser(userID int64) ([]models.DataExport, error) {
+	rows, err := dao.db.Query(dataExportSelect+`WHERE user_id = $1 ORDER BY created_at DESC, id DESC`, userID)
+	if err != nil {
+		dao.l.Printf("Error getting user data exports: %v", err)
+		return nil, err
+	}
+	return dao.scanExports(rows)
+}
+
+// GetReadyExportByToken returns the ready, unexpired export with the
+// download token
+func (dao *DataExportDao) GetReadyExportByToken(token string) (*models.DataExport, error) {
+	return dao.getExport(dataExportSelect+`WHERE token = $1 AND status = 'ready' AND expires_at > NOW()`, token)
+}
+
+// StartNextExport marks the oldest pending export as running and returns
+// it, or nil when there's nothing to do
+func (dao *DataExportDao) StartNextExport() (*models.DataExport, error) {
+	query := `
+		UPDATE data_exports
+		SET status = 'running', started_at = NOW(), heartbeat_at = NOW()
+		WHERE id = (
+			SELECT id FROM data_exports
+			WHERE status = 'pending'
+			ORDER BY created_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, user_id, status, COALESCE(token, ''), COALESCE(file_path, ''), size_bytes, error,
+			created_at, started_at, completed_at, expires_at;
+	`
+	return dao.getExport(query)
+}
+
+// HeartbeatExport records that the worker building the export is still alive
+func (dao *DataExportDao) HeartbeatExport(id int64) error {
+	_, err := dao.db.Exec(`UPDATE data_exports SET heartbeat_at = NOW() WHERE id = $1 AND status = 'running'`, id)
+	if err != nil {
+		dao.l.Printf("Error updating data export %d heartbeat: %v", id, err)
+	}
+	return err
+}
+
+// RequeueStaleExports puts running exports whose worker hasn't sent a
+// heartbeat within staleAfter back in the queue, e.g. after their replica
+// was restarted
+func (dao *DataExportDao) RequeueStaleExports(staleAfter time.Duration) (int64, error) {
+	query := `
+		UPDATE data_exports
+		SET status = 'pending', started_at = NULL, heartbeat_at = NULL
+		WHERE status = 'running'
+			AND COALESCE(heartbeat_at, started_at) < NOW() - $1 * INTERVAL '1 second'
+	`
+	result, err := dao.db.Exec(query, staleAfter.Seconds())
+	if err != nil {
+		dao.l.Printf("Error requeueing data exports: %v", err)
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (dao *DataExportDao) CompleteExport(id int64, token string, filePath string, sizeBytes int64, expiresAt time.Time) error {
+	query := `
+		UPDATE data_exports
+		SET status = 'ready', token = $2, file_path = $3, size_bytes = $4,
+			completed_at = NOW(), expires_at = $5
+		WHERE id = $1;
+	`
+	_, err := dao.db.Exec(query, id, token, filePath, sizeBytes, expiresAt)
+	if err != nil {
+		dao.l.Printf("Error completing data export %d: %v", id, err)
+		return err
+	}
+	return nil
+}
+
+func (dao *DataExportDao) FailExport(id int64, message string) error {
+	query := `
+		UPDATE data_exports
+		SET status = 'failed', error = $2, completed_at = NOW()
+		WHERE id = $1;
+	`
+	_, err := dao.db.Exec(query, id, message)
+	if err != nil {
+		dao.l.Printf("Error failing data export %d: %v", id, err)
+		return err
+	}
+	return nil
+}
+
+// GetExpiredExports returns ready exports past their expiry whose files
+// still need deleting
+func (dao *DataExportDao) GetExpiredExports() ([]models.DataExport, error) {
+	rows, err := dao.db.Query(dataExportSelect + `WHERE status = 'ready' AND expires_at <= NOW()`)
+	if err != nil {
+		dao.l.Printf("Error getting expired data exports: %v", err)
+		return nil, err
+	}
+	return dao.scanExports(rows)
+}
+
+// MarkExportExpired records that an export's file is gone. The token is
+// cleared so it can't be reused.
+func (dao *DataExportDao) MarkExportExpired(id int64) error {
+	_, err := dao.db.Exec(`UPDATE data_exports SET status = 'expired', token = NULL WHERE id = $1`, id)
+	if err != nil {
+		dao.l.Printf("Error expiring data export %d: %v", id, err)
+		return err
+	}
+	return nil
+}
+
+func (dao *DataExportDao) getExport(query string, args ...interface{}) (*models.DataExport, error) {
+	rows, err := dao.db.Query(query, args...)
+	if err != nil {
+		dao.l.Printf("Error getting data export: %v", err)
+		return nil, err
+	}
+	exports, err := dao.scanExports(rows)
+	if err != nil || len(exports) == 0 {
+		return nil, err
+	}
+	return &exports[0], nil
+}
+
+func (dao *DataExportDao) scanExports(rows *sql.Rows) ([]models.DataExport, error) {
+	defer rows.Close()
+
+	exports := []models.DataExport{}
+	for rows.Next() {
+		e := models.DataExport{}
+		var size sql.NullInt64
+		var message sql.NullString
+		var startedAt, completedAt, expiresAt sql.NullTime
+		err := rows.Scan(
+			&e.ID, &e.UserID, &e.Status, &e.Token, &e.FilePath, &size, &message,
+			&e.CreatedAt, &startedAt, &completedAt, &expiresAt,
+		)
+		if err != nil {
+			dao.l.Printf("Error scanning data export: %v", err)
+			return nil, err
+		}
+		if size.Valid {
+			e.SizeBytes = &size.Int64
+		}
+		if message.Valid {
+			e.Error = &message.String
+		}
+		if startedAt.Valid {
+			e.StartedAt = &startedAt.Time
+		}
+		if completedAt.Valid {
+			e.CompletedAt = &completedAt.Time
+		}
+		if expiresAt.Valid {
+			e.ExpiresAt = &expiresAt.Time
+		}
+		exports = append(exports, e)
+	}
+	return exports, rows.Err()
+}
@@ -0,0 +1,169 @@
+package daos
+
+import (
+	"database/sql"
+	"log"
+	"run-goals/models"
+	"time"
+)
+
+type EmailDaoInterface interface {
+	QueueEmail(email models.EmailSend) (bool, error)
+	GetEmailsByUser(userID int64, limit int) ([]models.EmailSend, error)
+	HasEmail(userID int64, dedupeKey string) (bool, error)
+	StartNextEmail() (*models.EmailSend, error)
+	RequeueSendingEmails() (int64, error)
+	MarkEmailSent(id int64) error
+	MarkEmailFailed(id int64, message string, retryAt *time.Time) error
+}
+
+type EmailDao struct {
+	l  *log.Logger
+	db *sql.DB
+}
+
+func NewEmailDao(logger *log.Logger, db *sql.DB) *EmailDao {
+	return &EmailDao{
+		l:  logger,
+		db: db,
+	}
+}
+
+const emailSendColumns = `
+	id, user_id, kind, recipient, subject, text_body, html_body, status, error, attempts,
+	COALESCE(dedupe_key, ''), created_at, sent_at
+`
+
+// QueueEmail adds an email for the worker to send. It returns false if one
+// with the same dedupe key was already queued for the user.
+func (dao *EmailDao) QueueEmail(email models.EmailSend) (bool, error) {
+	query := `
+		INSERT INTO email_sends (user_id, kind, recipient, subject, text_body, html_body, dedupe_key)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''))
+		ON CONFLICT (user_id, dedupe_key) DO NOTHING;
+	`
+	result, err := dao.db.Exec(query,
+		email.UserID, email.Kind, email.Recipient, email.Subject, email.TextBody, email.HTMLBody, email.DedupeKey,
+	)
+	if err != nil {
+		dao.l.Printf("Error queueing email: %v", err)
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// GetEmailsByUser returns the user's send history, newest first
+func (dao *EmailDao) GetEmailsByUser(userID int64, limit int) ([]models.EmailSend, error) {
+	rows, err := dao.db.Query(`SELECT `+emailSendColumns+` FROM email_sends WHERE user_id = $1 ORDER BY id DESC LIMIT $2`, userID, limit)
+	if err != nil {
+		dao.l.Printf("Error getting user emails: %v", err)
+		return nil, err
+	}
+	return dao.scanEmails(rows)
+}
+
+// HasEmail is whether an email with the dedupe key was already queued for
+// the user
+func (dao *EmailDao) HasEmail(userID int64, dedupeKey string) (bool, error) {
+	var exists bool
+	err := dao.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM email_sends WHERE user_id = $1 AND dedupe_key = $2)`, userID, dedupeKey).Scan(&exists)
+	if err != nil {
+		dao.l.Printf("Error checking for email: %v", err)
+		return false, err
+	}
+	return exists, nil
+}
+
+// StartNextEmail marks the oldest email due to be sent as sending and
+// returns it, or nil when there's nothing to do
+func (dao *EmailDao) StartNextEmail() (*models.EmailSend, error) {
+	query := `
+		UPDATE email_sends
+		SET status = 'sending', attempts = attempts + 1
+		WHERE id = (
+			SELECT id FROM email_sends
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + emailSendColumns
+	rows, err := dao.db.Query(query)
+	if err != nil {
+		dao.l.Printf("Error starting email: %v", err)
+		return nil, err
+	}
+	emails, err := dao.scanEmails(rows)
+	if err != nil || len(emails) == 0 {
+		return nil, err
+	}
+	return &emails[0], nil
+}
+
+// RequeueSendingEmails puts emails interrupted by a restart back in the
+// queue. They may have gone out, but a duplicate beats a lost email.
+func (dao *EmailDao) RequeueSendingEmails() (int64, error) {
+	result, err := dao.db.Exec(`UPDATE email_sends SET status = 'pending' WHERE status = 'sending'`)
+	if err != nil {
+		dao.l.Printf("Error requeueing emails: %v", err)
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (dao *EmailDao) MarkEmailSent(id int64) error {
+	_, err := dao.db.Exec(`UPDATE email_sends SET status = 'sent', error = NULL, sent_at = NOW() WHERE id = $1`, id)
+	if err != nil {
+		dao.l.Printf("Error marking email %d sent: %v", id, err)
+		return err
+	}
+	return nil
+}
+
+// MarkEmailFailed records a failed send. With a retry time the email goes
+// back in the queue, otherwise it's given up on.
+func (dao *EmailDao) MarkEmailFailed(id int64, message string, retryAt *time.Time) error {
+	query := `
+		UPDATE email_sends
+		SET status = CASE WHEN $3::TIMESTAMPTZ IS NULL THEN 'failed' ELSE 'pending' END,
+			error = $2, next_attempt_at = COALESCE($3, next_attempt_at)
+		WHERE id = $1;
+	`
+	_, err := dao.db.Exec(query, id, message, retryAt)
+	if err != nil {
+		dao.l.Printf("Error marking email %d failed: %v", id, err)
+		return err
+	}
+	return nil
+}
+
+func (dao *EmailDao) scanEmails(rows *sql.Rows) ([]models.EmailSend, error) {
+	defer rows.Close()
+
+	emails := []models.EmailSend{}
+	for rows.Next() {
+		e := models.EmailSend{}
+		var message sql.NullString
+		var sentAt sql.NullTime
+		err := rows.Scan(
+			&e.ID, &e.UserID, &e.Kind, &e.Recipient, &e.Subject, &e.TextBody, &e.HTMLBody, &e.Status, &message, &e.Attempts,
+			&e.DedupeKey, &e.CreatedAt, &sentAt,
+		)
+		if err != nil {
+			dao.l.Printf("Error scanning email: %v", err)
+			return nil, err
+		}
+		if message.Valid {
+			e.Error = &message.String
+		}
+		if sentAt.Valid {
+			e.SentAt = &sentAt.Time
+		}
+		emails = append(emails, e)
+	}
+	return emails, rows.Err()
+}
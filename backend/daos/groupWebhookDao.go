@@ -0,0 +1,338 @@
+package daos
+
+import (
+	"database/sql"
+	"log"
+	"run-goals/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type GroupWebhookDaoInterface interface {
+	CreateWebhook(webhook models.GroupWebhook) (*models.GroupWebhook, error)
+	GetWebhook(id int64) (*models.GroupWebhook, error)
+	GetWebhooksByGroup(groupID int64) ([]models.GroupWebhook, error)
+	UpdateWebhook(webhook models.GroupWebhook) error
+	DeleteWebhook(id int64) error
+	GetWebhooksForEvent(userID int64, event models.GroupWebhookEvent, challengeID *int64) ([]models.GroupWebhook, error)
+
+	QueueDelivery(delivery models.GroupWebhookDelivery) (bool, error)
+	GetDeliveries(webhookID int64, limit int) ([]models.GroupWebhookDelivery, error)
+	StartNextDelivery() (*models.GroupWebhookDelivery, error)
+	RequeueSendingDeliveries() (int64, error)
+	MarkDeliveryDelivered(id int64, responseStatus int) error
+	MarkDeliveryFailed(id int64, responseStatus *int, message string, retryAt *time.Time) error
+}
+
+type GroupWebhookDao struct {
+	l  *log.Logger
+	db *sql.DB
+}
+
+func NewGroupWebhookDao(logger *log.Logger, db *sql.DB) *GroupWebhookDao {
+	return &GroupWebhookDao{
+		l:  logger,
+		db: db,
+	}
+}
+
+const groupWebhookColumns = `
+	w.id, w.group_id, g.name, w.url, w.format, w.events, w.secret, w.enabled, w.created_by, w.created_at, w.updated_at
+`
+
+const groupWebhookDeliveryColumns = `
+	id, webhook_id, event, payload, status, attempts, response_status, error,
+	COALESCE(dedupe_key, ''), next_attempt_at, created_at, delivered_at
+`
+
+// ==================== Webhooks ====================
+
+func (dao *GroupWebhookDao) CreateWebhook(webhook models.GroupWebhook) (*models.GroupWebhook, error) {
+	var id int64
+	query := `
+		INSERT INTO group_webhooks (group_id, url, format, events, secret, enabled, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id;
+	`
+	err := dao.db.QueryRow(query,
+		webhook.GroupID, webhook.URL, webhook.Format, pq.Array(eventStrings(webhook.Events)), webhook.Secret, webhook.Enabled, webhook.CreatedBy,
+	).Scan(&id)
+	if err != nil {
+		dao.l.Printf("Error creating group webhook: %v", err)
+		return nil, err
+	}
+	return dao.GetWebhook(id)
+}
+
+// GetWebhook returns the webhook, or nil if it doesn't exist
+func (dao *GroupWebhookDao) GetWebhook(id int64) (*models.GroupWebhook, error) {
+	rows, err := dao.db.Query(`
+		SELECT `+groupWebhookColumns+`
+		FROM group_webhooks w
+		JOIN groups g ON g.id = w.group_id
+		WHERE w.id = $1
+	`, id)
+	if err != nil {
+		dao.l.Printf("Error getting group webhook: %v", err)
+		return nil, err
+	}
+	webhooks, err := dao.scanWebhooks(rows)
+	if err != nil || len(webhooks) == 0 {
+		return nil, err
+	}
+	return &webhooks[0], nil
+}
+
+func (dao *GroupWebhookDao) GetWebhooksByGroup(groupID int64) ([]models.GroupWebhook, error) {
+	rows, err := dao.db.Query(`
+		SELECT `+groupWebhookColumns+`
+		FROM group_webhooks w
+		JOIN groups g ON g.id = w.group_id
+		WHERE w.group_id = $1
+		ORDER BY w.id
+	`, groupID)
+	if err != nil {
+		dao.l.Printf("Error getting group webhooks: %v", err)
+		return nil, err
+	}
+	return dao.scanWebhooks(rows)
+}
+
+// UpdateWebhook changes the webhook's URL, format, events and whether it's
+// enabled. The secret is left as it is.
+func (dao *GroupWebhookDao) UpdateWebhook(webhook models.GroupWebhook) error {
+	query := `
+		UPDATE group_webhooks
+		SET url = $2, format = $3, events = $4, enabled = $5, updated_at = NOW()
+		WHERE id = $1;
+	`
+	_, err := dao.db.Exec(query, webhook.ID, webhook.URL, webhook.Format, pq.Array(eventStrings(webhook.Events)), webhook.Enabled)
+	if err != nil {
+		dao.l.Printf("Error updating group webhook %d: %v", webhook.ID, err)
+		return err
+	}
+	return nil
+}
+
+func (dao *GroupWebhookDao) DeleteWebhook(id int64) error {
+	_, err := dao.db.Exec(`DELETE FROM group_webhooks WHERE id = $1`, id)
+	if err != nil {
+		dao.l.Printf("Error deleting group webhook %d: %v", id, err)
+		return err
+	}
+	return nil
+}
+
+// GetWebhooksForEvent returns the enabled webhooks subscribed to the event in
+// every group the user is a member of. Users who keep their activities out
+// of group feeds aren't posted about. Events about a challenge only go to
+// groups that can see it: public challenges, and challenges the group made
+// or was added to.
+func (dao *GroupWebhookDao) GetWebhooksForEvent(userID int64, event models.GroupWebhookEvent, challengeID *int64) ([]models.GroupWebhook, error) {
+	query := `
+		SELECT ` + groupWebhookColumns + `
+		FROM group_webhooks w
+		JOIN groups g ON g.id = w.group_id
+		JOIN group_members gm ON gm.group_id = w.group_id AND gm.user_id = $1
+		JOIN users u ON u.id = gm.user_id AND u.show_in_group_feeds
+		WHERE w.enabled
+		AND $2 = ANY(w.events)
+		AND (
+			$3::BIGINT IS NULL
+			OR EXISTS (
+				SELECT 1 FROM challenges c
+				WHERE c.id = $3
+				AND (
+					c.visibility = 'public'
+					OR c.created_by_group_id = w.group_id
+					OR EXISTS (SELECT 1 FROM challenge_groups cg WHERE cg.challenge_id = c.id AND cg.group_id = w.group_id)
+				)
+			)
+		)
+		ORDER BY w.id
+	`
+	rows, err := dao.db.Query(query, userID, event, challengeID)
+	if err != nil {
+		dao.l.Printf("Error getting webhooks for event: %v", err)
+		return nil, err
+	}
+	return dao.scanWebhooks(rows)
+}
+
+func (dao *GroupWebhookDao) scanWebhooks(rows *sql.Rows) ([]models.GroupWebhook, error) {
+	defer rows.Close()
+
+	webhooks := []models.GroupWebhook{}
+	for rows.Next() {
+		w := models.GroupWebhook{}
+		var events pq.StringArray
+		var createdBy sql.NullInt64
+		err := rows.Scan(
+			&w.ID, &w.GroupID, &w.GroupName, &w.URL, &w.Format, &events, &w.Secret, &w.Enabled, &createdBy, &w.CreatedAt, &w.UpdatedAt,
+		)
+		if err != nil {
+			dao.l.Printf("Error scanning group webhook: %v", err)
+			return nil, err
+		}
+		w.Events = make([]models.GroupWebhookEvent, 0, len(events))
+		for _, event := range events {
+			w.Events = append(w.Events, models.GroupWebhookEvent(event))
+		}
+		if createdBy.Valid {
+			w.CreatedBy = &createdBy.Int64
+		}
+		webhooks = append(webhooks, w)
+	}
+	return webhooks, rows.Err()
+}
+
+func eventStrings(events []models.GroupWebhookEvent) []string {
+	strs := make([]string, 0, len(events))
+	for _, event := range events {
+		strs = append(strs, string(event))
+	}
+	return strs
+}
+
+// ==================== Deliveries ====================
+
+// QueueDelivery adds a request for the worker to send. It returns false if
+// one with the same dedupe key was already queued for the webhook.
+func (dao *GroupWebhookDao) QueueDelivery(delivery models.GroupWebhookDelivery) (bool, error) {
+	query := `
+		INSERT INTO group_webhook_deliveries (webhook_id, event, payload, dedupe_key)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		ON CONFLICT (webhook_id, dedupe_key) DO NOTHING;
+	`
+	result, err := dao.db.Exec(query, delivery.WebhookID, delivery.Event, delivery.Payload, delivery.DedupeKey)
+	if err != nil {
+		dao.l.Printf("Error queueing webhook delivery: %v", err)
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// GetDeliveries returns the webhook's delivery log, newest first
+func (dao *GroupWebhookDao) GetDeliveries(webhookID int64, limit int) ([]models.GroupWebhookDelivery, error) {
+	rows, err := dao.db.Query(`
+		SELECT `+groupWebhookDeliveryColumns+`
+		FROM group_webhook_deliveries
+		WHERE webhook_id = $1
+		ORDER BY id DESC
+		LIMIT $2
+	`, webhookID, limit)
+	if err != nil {
+		dao.l.Printf("Error getting webhook deliveries: %v", err)
+		return nil, err
+	}
+	return dao.scanDeliveries(rows)
+}
+
+// StartNextDelivery marks the oldest delivery due to be sent as sending and
+// returns it, or nil when there's nothing to do
+func (dao *GroupWebhookDao) StartNextDelivery() (*models.GroupWebhookDelivery, error) {
+	query := `
+		UPDATE group_webhook_deliveries
+		SET status = 'sending', attempts = attempts + 1
+		WHERE id = (
+			SELECT id FROM group_webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + groupWebhookDeliveryColumns
+	rows, err := dao.db.Query(query)
+	if err != nil {
+		dao.l.Printf("Error starting webhook delivery: %v", err)
+		return nil, err
+	}
+	deliveries, err := dao.scanDeliveries(rows)
+	if err != nil || len(deliveries) == 0 {
+		return nil, err
+	}
+	return &deliveries[0], nil
+}
+
+// RequeueSendingDeliveries puts deliveries interrupted by a restart back in
+// the queue. Receivers may see a request twice, but a duplicate beats a
+// lost post.
+func (dao *GroupWebhookDao) RequeueSendingDeliveries() (int64, error) {
+	result, err := dao.db.Exec(`UPDATE group_webhook_deliveries SET status = 'pending' WHERE status = 'sending'`)
+	if err != nil {
+		dao.l.Printf("Error requeueing webhook deliveries: %v", err)
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (dao *GroupWebhookDao) MarkDeliveryDelivered(id int64, responseStatus int) error {
+	query := `
+		UPDATE group_webhook_deliveries
+		SET status = 'delivered', response_status = $2, error = NULL, delivered_at = NOW()
+		WHERE id = $1;
+	`
+	_, err := dao.db.Exec(query, id, responseStatus)
+	if err != nil {
+		dao.l.Printf("Error marking webhook delivery %d delivered: %v", id, err)
+		return err
+	}
+	return nil
+}
+
+// MarkDeliveryFailed records a failed attempt. With a retry time the
+// delivery goes back in the queue, otherwise it's given up on.
+func (dao *GroupWebhookDao) MarkDeliveryFailed(id int64, responseStatus *int, message string, retryAt *time.Time) error {
+	query := `
+		UPDATE group_webhook_deliveries
+		SET status = CASE WHEN $4::TIMESTAMPTZ IS NULL THEN 'failed' ELSE 'pending' END,
+			response_status = $2, error = $3, next_attempt_at = COALESCE($4, next_attempt_at)
+		WHERE id = $1;
+	`
+	_, err := dao.db.Exec(query, id, responseStatus, message, retryAt)
+	if err != nil {
+		dao.l.Printf("Error marking webhook delivery %d failed: %v", id, err)
+		return err
+	}
+	return nil
+}
+
+func (dao *GroupWebhookDao) scanDeliveries(rows *sql.Rows) ([]models.GroupWebhookDelivery, error) {
+	defer rows.Close()
+
+	deliveries := []models.GroupWebhookDelivery{}
+	for rows.Next() {
+		d := models.GroupWebhookDelivery{}
+		var responseStatus sql.NullInt64
+		var message sql.NullString
+		var nextAttemptAt, deliveredAt sql.NullTime
+		err := rows.Scan(
+			&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &responseStatus, &message,
+			&d.DedupeKey, &nextAttemptAt, &d.CreatedAt, &deliveredAt,
+		)
+		if err != nil {
+			dao.l.Printf("Error scanning webhook delivery: %v", err)
+			return nil, err
+		}
+		if responseStatus.Valid {
+			status := int(responseStatus.Int64)
+			d.ResponseStatus = &status
+		}
+		if message.Valid {
+			d.Error = &message.String
+		}
+		if nextAttemptAt.Valid && d.Status == models.GroupWebhookDeliveryStatusPending && d.Attempts > 0 {
+			d.NextAttemptAt = &nextAttemptAt.Time
+		}
+		if deliveredAt.Valid {
+			d.DeliveredAt = &deliveredAt.Time
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
@@ -18,6 +18,7 @@ type GroupsDaoInterface interface {
 	CreateGroupMember(member models.GroupMember) error
 	UpdateGroupMember(member models.GroupMember) error
 	DeleteGroupMember(userID int64) error
+	GetGroupMemberRole(groupID int64, userID int64) (*string, error)
 	GetGroupMembersGoalContribution(groupID int64, startDate time.Time, endDate time.Time) ([]models.GroupMemberGoalContribution, error)
 
 	CreateGroupGoal(goal models.GroupGoal) (int64, error)
@@ -25,6 +26,7 @@ type GroupsDaoInterface interface {
 	DeleteGroupGoal(goalID int64) error
 
 	GetUserGroups(userID int64) ([]models.Group, error)
+	GetGroupByID(groupID int64) (*models.Group, error)
 	GetGroupMembers(groupID int64) ([]models.GroupMember, error)
 	GetGroupGoals(groupID int64) ([]models.GroupGoal, error)
 	GetGroupGoalByID(goalID int64) (*models.GroupGoal, error)
@@ -111,6 +113,34 @@ func (dao *GroupsDao) GetGroupIDFromCode(code string) (*int64, error) {
 	return &id, nil
 }
 
+func (dao *GroupsDao) GetGroupByID(groupID int64) (*models.Group, error) {
+	group := models.Group{}
+	sql := `
+		SELECT
+			id,
+			name,
+			code,
+			created_by,
+			created_at
+		FROM groups
+		WHERE id = $1;
+	`
+	row := dao.db.QueryRow(sql, groupID)
+	err := row.Scan(
+		&group.ID,
+		&group.Name,
+		&group.Code,
+		&group.CreatedBy,
+		&group.CreatedAt,
+	)
+	if err != nil {
+		dao.l.Printf("Error getting group: %v", err)
+		return nil, err
+	}
+
+	return &group, nil
+}
+
 func (dao *GroupsDao) CheckGroupCodeExists(code string) (*int64, error) {
 	var count int64
 	sql := `
@@ -183,6 +213,30 @@ func (dao *GroupsDao) DeleteGroupMember(userID int64, groupID int64) error {
 	return nil
 }
 
+// GetGroupMemberRole returns the user's role in the group, or nil if they
+// aren't a member
+func (dao *GroupsDao) GetGroupMemberRole(groupID int64, userID int64) (*string, error) {
+	var role sql.NullString
+	query := `
+		SELECT
+			role
+		FROM
+			group_members
+		WHERE
+			group_id = $1
+			AND user_id = $2;
+	`
+	err := dao.db.QueryRow(query, groupID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		dao.l.Printf("Error getting group member role: %v", err)
+		return nil, err
+	}
+	return &role.String, nil
+}
+
 func (dao *GroupsDao) CreateGroupGoal(goal models.GroupGoal) (*int64, error) {
 	var id int64
 	sql := `
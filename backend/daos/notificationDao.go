@@ -0,0 +1,144 @@
+package daos
+
+import (
+	"database/sql"
+	"log"
+	"run-goals/models"
+
+	"github.com/lib/pq"
+)
+
+type NotificationDaoInterface interface {
+	CreateNotification(notification models.Notification) (bool, error)
+	GetNotifications(userID int64, unreadOnly bool, before *int64, limit int) ([]models.Notification, error)
+	CountUnread(userID int64) (int, error)
+	MarkRead(userID int64, ids []int64) (int64, error)
+	MarkAllRead(userID int64) (int64, error)
+}
+
+type NotificationDao struct {
+	l  *log.Logger
+	db *sql.DB
+}
+
+func NewNotificationDao(logger *log.Logger, db *sql.DB) *NotificationDao {
+	return &NotificationDao{
+		l:  logger,
+		db: db,
+	}
+}
+
+// CreateNotification adds a notification to the user's inbox. It returns
+// false if one with the same dedupe key is already there.
+func (dao *NotificationDao) CreateNotification(notification models.Notification) (bool, error) {
+	query := `
+		INSERT INTO notifications (
+			user_id, type, title, body, peak_id, activity_id, challenge_id, group_id, dedupe_key
+		) VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, NULLIF($9, ''))
+		ON CONFLICT (user_id, dedupe_key) DO NOTHING;
+	`
+	result, err := dao.db.Exec(query,
+		notification.UserID, notification.Type, notification.Title, notification.Body,
+		notification.PeakID, notification.ActivityID, notification.ChallengeID, notification.GroupID,
+		notification.DedupeKey,
+	)
+	if err != nil {
+		dao.l.Printf("Error creating notification: %v", err)
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// GetNotifications returns the user's newest notifications, older than the
+// before id if given
+func (dao *NotificationDao) GetNotifications(userID int64, unreadOnly bool, before *int64, limit int) ([]models.Notification, error) {
+	query := `
+		SELECT
+			id, user_id, type, title, COALESCE(body, ''),
+			peak_id, activity_id, challenge_id, group_id, read_at, created_at
+		FROM notifications
+		WHERE user_id = $1
+		AND ($2 = FALSE OR read_at IS NULL)
+		AND ($3::BIGINT IS NULL OR id < $3)
+		ORDER BY id DESC
+		LIMIT $4;
+	`
+	rows, err := dao.db.Query(query, userID, unreadOnly, before, limit)
+	if err != nil {
+		dao.l.Printf("Error getting notifications: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	for rows.Next() {
+		n := models.Notification{}
+		var peakID, activityID, challengeID, groupID sql.NullInt64
+		var readAt sql.NullTime
+		err := rows.Scan(
+			&n.ID, &n.UserID, &n.Type, &n.Title, &n.Body,
+			&peakID, &activityID, &challengeID, &groupID, &readAt, &n.CreatedAt,
+		)
+		if err != nil {
+			dao.l.Printf("Error scanning notification: %v", err)
+			return nil, err
+		}
+		if peakID.Valid {
+			n.PeakID = &peakID.Int64
+		}
+		if activityID.Valid {
+			n.ActivityID = &activityID.Int64
+		}
+		if challengeID.Valid {
+			n.ChallengeID = &challengeID.Int64
+		}
+		if groupID.Valid {
+			n.GroupID = &groupID.Int64
+		}
+		if readAt.Valid {
+			n.Read = true
+			n.ReadAt = &readAt.Time
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+func (dao *NotificationDao) CountUnread(userID int64) (int, error) {
+	var count int
+	err := dao.db.QueryRow(`SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL`, userID).Scan(&count)
+	if err != nil {
+		dao.l.Printf("Error counting unread notifications: %v", err)
+		return 0, err
+	}
+	return count, nil
+}
+
+// MarkRead marks the user's notifications with the ids as read. Ids that
+// aren't theirs are ignored.
+func (dao *NotificationDao) MarkRead(userID int64, ids []int64) (int64, error) {
+	query := `
+		UPDATE notifications
+		SET read_at = NOW()
+		WHERE user_id = $1 AND id = ANY($2) AND read_at IS NULL;
+	`
+	result, err := dao.db.Exec(query, userID, pq.Array(ids))
+	if err != nil {
+		dao.l.Printf("Error marking notifications read: %v", err)
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (dao *NotificationDao) MarkAllRead(userID int64) (int64, error) {
+	result, err := dao.db.Exec(`UPDATE notifications SET read_at = NOW() WHERE user_id = $1 AND read_at IS NULL`, userID)
+	if err != nil {
+		dao.l.Printf("Error marking all notifications read: %v", err)
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,248 @@
+package daos
+
+import (
+	"database/sql"
+	"log"
+	"run-goals/models"
+)
+
+type PeakListDaoInterface interface {
+	CreateList(list models.PeakList) (*int64, error)
+	UpdateList(list models.PeakList) error
+	DeleteList(id int64) error
+	GetListByID(id int64) (*models.PeakList, error)
+	GetListsWithProgress(userID int64, scope string) ([]models.PeakListWithProgress, error)
+	SetListPeaks(listID int64, peakIDs []int64) error
+	GetListPeaks(listID int64, userID int64) ([]models.PeakListPeak, error)
+}
+
+type PeakListDao struct {
+	l  *log.Logger
+	db *sql.DB
+}
+
+func NewPeakListDao(logger *log.Logger, db *sql.DB) *PeakListDao {
+	return &PeakListDao{
+		l:  logger,
+		db: db,
+	}
+}
+
+// ==================== List CRUD ====================
+
+func (dao *PeakListDao) CreateList(list models.PeakList) (*int64, error) {
+	var id int64
+	query := `
+		INSERT INTO peak_lists (name, description, region, visibility, is_curated, created_by_user_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id;
+	`
+	err := dao.db.QueryRow(query,
+		list.Name, list.Description, list.Region, list.Visibility, list.IsCurated, list.CreatedByUserID,
+	).Scan(&id)
+	if err != nil {
+		dao.l.Printf("Error creating peak list: %v", err)
+		return nil, err
+	}
+	return &id, nil
+}
+
+func (dao *PeakListDao) UpdateList(list models.PeakList) error {
+	query := `
+		UPDATE peak_lists SET
+			name = $2,
+			description = $3,
+			region = $4,
+			visibility = $5,
+			updated_at = NOW()
+		WHERE id = $1;
+	`
+	_, err := dao.db.Exec(query, list.ID, list.Name, list.Description, list.Region, list.Visibility)
+	if err != nil {
+		dao.l.Printf("Error updating peak list: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (dao *PeakListDao) DeleteList(id int64) error {
+	query := `DELETE FROM peak_lists WHERE id = $1;`
+	_, err := dao.db.Exec(query, id)
+	if err != nil {
+		dao.l.Printf("Error deleting peak list: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (dao *PeakListDao) GetListByID(id int64) (*models.PeakList, error) {
+	query := `
+		SELECT
+			pl.id, pl.name, pl.description, pl.region, pl.visibility, pl.is_curated,
+			pl.created_by_user_id, pl.created_at, pl.updated_at,
+			(SELECT COUNT(*) FROM peak_list_peaks WHERE list_id = pl.id)
+		FROM peak_lists pl
+		WHERE pl.id = $1;
+	`
+	var list models.PeakList
+	err := dao.db.QueryRow(query, id).Scan(
+		&list.ID, &list.Name, &list.Description, &list.Region, &list.Visibility, &list.IsCurated,
+		&list.CreatedByUserID, &list.CreatedAt, &list.UpdatedAt,
+		&list.PeakCount,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		dao.l.Printf("Error getting peak list: %v", err)
+		return nil, err
+	}
+	return &list, nil
+}
+
+// ==================== List Queries ====================
+
+// GetListsWithProgress returns the lists the user can see along with how many
+// of each list's peaks they have summited. scope narrows the lists to
+// "curated", "public" or "mine"; empty returns all of them. CompletedAt is
+// the latest first summit of any of the list's peaks.
+func (dao *PeakListDao) GetListsWithProgress(userID int64, scope string) ([]models.PeakListWithProgress, error) {
+	query := `
+		SELECT
+			pl.id, pl.name, pl.description, pl.region, pl.visibility, pl.is_curated,
+			pl.created_by_user_id, pl.created_at, pl.updated_at,
+			COUNT(plp.peak_id) AS total_peaks,
+			COUNT(fs.peak_id) AS peaks_completed,
+			MAX(fs.first_summited_at)
+		FROM peak_lists pl
+		LEFT JOIN peak_list_peaks plp ON plp.list_id = pl.id
+		LEFT JOIN (
+			SELECT peak_id, MIN(summited_at) AS first_summited_at
+			FROM user_peaks
+			WHERE user_id = $1
+			GROUP BY peak_id
+		) fs ON fs.peak_id = plp.peak_id
+		WHERE (pl.is_curated OR pl.visibility = 'public' OR pl.created_by_user_id = $1)
+		AND (
+			$2 = ''
+			OR ($2 = 'curated' AND pl.is_curated)
+			OR ($2 = 'public' AND pl.visibility = 'public')
+			OR ($2 = 'mine' AND pl.created_by_user_id = $1)
+		)
+		GROUP BY pl.id
+		ORDER BY pl.is_curated DESC, pl.name, pl.id;
+	`
+	rows, err := dao.db.Query(query, userID, scope)
+	if err != nil {
+		dao.l.Printf("Error getting peak lists: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := []models.PeakListWithProgress{}
+	for rows.Next() {
+		var list models.PeakListWithProgress
+		err := rows.Scan(
+			&list.ID, &list.Name, &list.Description, &list.Region, &list.Visibility, &list.IsCurated,
+			&list.CreatedByUserID, &list.CreatedAt, &list.UpdatedAt,
+			&list.Progress.TotalPeaks,
+			&list.Progress.PeaksCompleted,
+			&list.Progress.CompletedAt,
+		)
+		if err != nil {
+			dao.l.Printf("Error scanning peak list: %v", err)
+			return nil, err
+		}
+		list.PeakCount = list.Progress.TotalPeaks
+		lists = append(lists, list)
+	}
+	return lists, nil
+}
+
+// ==================== List Peaks ====================
+
+// SetListPeaks replaces a list's peaks, keeping the given order
+func (dao *PeakListDao) SetListPeaks(listID int64, peakIDs []int64) error {
+	tx, err := dao.db.Begin()
+	if err != nil {
+		dao.l.Printf("Error starting transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM peak_list_peaks WHERE list_id = $1`, listID)
+	if err != nil {
+		dao.l.Printf("Error clearing peak list peaks: %v", err)
+		return err
+	}
+
+	for i, peakID := range peakIDs {
+		_, err = tx.Exec(`
+			INSERT INTO peak_list_peaks (list_id, peak_id, sort_order)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (list_id, peak_id) DO NOTHING
+		`, listID, peakID, i)
+		if err != nil {
+			dao.l.Printf("Error adding peak to list: %v", err)
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE peak_lists SET updated_at = NOW() WHERE id = $1`, listID)
+	if err != nil {
+		dao.l.Printf("Error touching peak list: %v", err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetListPeaks returns a list's peaks in order with the user's all-time
+// summits of each
+func (dao *PeakListDao) GetListPeaks(listID int64, userID int64) ([]models.PeakListPeak, error) {
+	query := `
+		SELECT
+			p.id, COALESCE(p.osm_id, 0), p.latitude, p.longitude,
+			COALESCE(p.name, ''), COALESCE(p.elevation_meters, 0),
+			COALESCE(p.alt_name, ''), COALESCE(p.name_en, ''), COALESCE(p.region, ''),
+			COALESCE(p.wikipedia, ''), COALESCE(p.wikidata, ''), COALESCE(p.description, ''),
+			COALESCE(p.prominence, 0),
+			plp.sort_order,
+			MIN(up.summited_at),
+			COUNT(up.id)
+		FROM peak_list_peaks plp
+		JOIN peaks p ON p.id = plp.peak_id
+		LEFT JOIN user_peaks up ON up.peak_id = plp.peak_id AND up.user_id = $2
+		WHERE plp.list_id = $1
+		GROUP BY p.id, plp.sort_order
+		ORDER BY plp.sort_order, p.id;
+	`
+	rows, err := dao.db.Query(query, listID, userID)
+	if err != nil {
+		dao.l.Printf("Error getting peak list peaks: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	peaks := []models.PeakListPeak{}
+	for rows.Next() {
+		var peak models.PeakListPeak
+		err := rows.Scan(
+			&peak.ID, &peak.OsmID, &peak.Latitude, &peak.Longitude,
+			&peak.Name, &peak.ElevationMeters,
+			&peak.AltName, &peak.NameEN, &peak.Region,
+			&peak.Wikipedia, &peak.Wikidata, &peak.Description,
+			&peak.Prominence,
+			&peak.SortOrder,
+			&peak.FirstSummitedAt,
+			&peak.SummitCount,
+		)
+		if err != nil {
+			dao.l.Printf("Error scanning peak list peak: %v", err)
+			return nil, err
+		}
+		peak.IsSummited = peak.SummitCount > 0
+		peaks = append(peaks, peak)
+	}
+	return peaks, nil
+}
@@ -0,0 +1,300 @@
+package daos
+
+import (
+	"database/sql"
+	"log"
+	"run-goals/models"
+
+	"github.com/lib/pq"
+)
+
+type PeakMergeDaoInterface interface {
+	ReplacePendingProposals(proposals []models.PeakMergeProposal) (int, error)
+	GetProposals(status models.PeakMergeStatus) ([]models.PeakMergeProposal, error)
+	GetProposalByID(id int64) (*models.PeakMergeProposal, error)
+	RejectProposal(id int64) error
+	MergePeaks(proposalID int64, canonicalID int64, duplicateIDs []int64) ([]int64, error)
+}
+
+type PeakMergeDao struct {
+	l  *log.Logger
+	db *sql.DB
+}
+
+func NewPeakMergeDao(logger *log.Logger, db *sql.DB) *PeakMergeDao {
+	return &PeakMergeDao{
+		l:  logger,
+		db: db,
+	}
+}
+
+// ==================== Proposals ====================
+
+// ReplacePendingProposals swaps the pending proposals for a fresh scan.
+// Clusters an admin already rejected aren't proposed again.
+func (dao *PeakMergeDao) ReplacePendingProposals(proposals []models.PeakMergeProposal) (int, error) {
+	tx, err := dao.db.Begin()
+	if err != nil {
+		dao.l.Printf("Error starting transaction: %v", err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM peak_merge_proposals WHERE status = 'pending'`)
+	if err != nil {
+		dao.l.Printf("Error clearing pending peak merge proposals: %v", err)
+		return 0, err
+	}
+
+	inserted := 0
+	for _, p := range proposals {
+		result, err := tx.Exec(`
+			INSERT INTO peak_merge_proposals (canonical_peak_id, peak_ids, span_meters)
+			SELECT $1, $2, $3
+			WHERE NOT EXISTS (
+				SELECT 1 FROM peak_merge_proposals
+				WHERE status = 'rejected' AND peak_ids = $2
+			)
+		`, p.CanonicalPeakID, pq.Array(p.PeakIDs), p.SpanMeters)
+		if err != nil {
+			dao.l.Printf("Error inserting peak merge proposal: %v", err)
+			return 0, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		inserted += int(n)
+	}
+
+	return inserted, tx.Commit()
+}
+
+func (dao *PeakMergeDao) GetProposals(status models.PeakMergeStatus) ([]models.PeakMergeProposal, error) {
+	query := `
+		SELECT id, canonical_peak_id, peak_ids, span_meters, status, created_at, resolved_at
+		FROM peak_merge_proposals
+		WHERE status = $1
+		ORDER BY span_meters, id;
+	`
+	rows, err := dao.db.Query(query, status)
+	if err != nil {
+		dao.l.Printf("Error getting peak merge proposals: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	proposals := []models.PeakMergeProposal{}
+	for rows.Next() {
+		p, err := scanPeakMergeProposal(rows)
+		if err != nil {
+			dao.l.Printf("Error scanning peak merge proposal: %v", err)
+			return nil, err
+		}
+		proposals = append(proposals, *p)
+	}
+	return proposals, rows.Err()
+}
+
+func (dao *PeakMergeDao) GetProposalByID(id int64) (*models.PeakMergeProposal, error) {
+	query := `
+		SELECT id, canonical_peak_id, peak_ids, span_meters, status, created_at, resolved_at
+		FROM peak_merge_proposals
+		WHERE id = $1;
+	`
+	p, err := scanPeakMergeProposal(dao.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		dao.l.Printf("Error getting peak merge proposal: %v", err)
+		return nil, err
+	}
+	return p, nil
+}
+
+func (dao *PeakMergeDao) RejectProposal(id int64) error {
+	query := `
+		UPDATE peak_merge_proposals
+		SET status = 'rejected', resolved_at = NOW()
+		WHERE id = $1;
+	`
+	_, err := dao.db.Exec(query, id)
+	if err != nil {
+		dao.l.Printf("Error rejecting peak merge proposal: %v", err)
+		return err
+	}
+	return nil
+}
+
+func scanPeakMergeProposal(row interface{ Scan(...interface{}) error }) (*models.PeakMergeProposal, error) {
+	p := models.PeakMergeProposal{}
+	var peakIDs pq.Int64Array
+	err := row.Scan(&p.ID, &p.CanonicalPeakID, &peakIDs, &p.SpanMeters, &p.Status, &p.CreatedAt, &p.ResolvedAt)
+	if err != nil {
+		return nil, err
+	}
+	p.PeakIDs = []int64(peakIDs)
+	return &p, nil
+}
+
+// ==================== Merging ====================
+
+// peakMergeUniqueTables are tables with one row per peak per owner. Rows on
+// a duplicate that already exist on the canonical peak are dropped, the rest
+// are re-pointed.
+var peakMergeUniqueTables = []struct {
+	table string
+	keys  []string
+}{
+	{"user_peaks", []string{"user_id", "activity_id"}},
+	{"challenge_peaks", []string{"challenge_id"}},
+	{"summit_favourites", []string{"user_id"}},
+	{"challenge_summit_log", []string{"challenge_id", "user_id"}},
+	{"peak_list_peaks", []string{"list_id"}},
+	{"summit_dismissals", []string{"user_id", "activity_id"}},
+}
+
+// peakMergeArrayTables hold peaks in a peak_ids array
+var peakMergeArrayTables = []string{"personal_goals", "challenge_series", "challenge_proposals"}
+
+// MergePeaks re-points everything that references the duplicates at the
+// canonical peak, marks the duplicates as merged and closes the proposal, all
+// in one transaction. It returns the challenges whose peaks or summit log
+// changed, so their progress can be refreshed.
+func (dao *PeakMergeDao) MergePeaks(proposalID int64, canonicalID int64, duplicateIDs []int64) ([]int64, error) {
+	tx, err := dao.db.Begin()
+	if err != nil {
+		dao.l.Printf("Error starting transaction: %v", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	challengeIDs := []int64{}
+	rows, err := tx.Query(`
+		SELECT challenge_id FROM challenge_peaks WHERE peak_id = ANY($1)
+		UNION
+		SELECT challenge_id FROM challenge_summit_log WHERE peak_id = ANY($1)
+	`, pq.Array(duplicateIDs))
+	if err != nil {
+		dao.l.Printf("Error getting challenges for merged peaks: %v", err)
+		return nil, err
+	}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			dao.l.Printf("Error scanning challenge ID: %v", err)
+			return nil, err
+		}
+		challengeIDs = append(challengeIDs, id)
+	}
+	rows.Close()
+
+	// One duplicate at a time, so two duplicates on the same row owner can't
+	// collide with each other
+	for _, dupID := range duplicateIDs {
+		if err := mergePeakInto(tx, dupID, canonicalID); err != nil {
+			dao.l.Printf("Error merging peak %d into %d: %v", dupID, canonicalID, err)
+			return nil, err
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE peak_merge_proposals
+		SET status = 'merged', canonical_peak_id = $2, resolved_at = NOW()
+		WHERE id = $1
+	`, proposalID, canonicalID)
+	if err != nil {
+		dao.l.Printf("Error closing peak merge proposal: %v", err)
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		dao.l.Printf("Error committing peak merge: %v", err)
+		return nil, err
+	}
+	return challengeIDs, nil
+}
+
+func mergePeakInto(tx *sql.Tx, dupID int64, canonicalID int64) error {
+	// The challenge log keeps whichever credit came first
+	_, err := tx.Exec(`
+		UPDATE challenge_summit_log c
+		SET summited_at = d.summited_at, activity_id = d.activity_id
+		FROM challenge_summit_log d
+		WHERE d.peak_id = $1 AND c.peak_id = $2
+			AND d.challenge_id = c.challenge_id AND d.user_id = c.user_id
+			AND d.summited_at < c.summited_at
+	`, dupID, canonicalID)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range peakMergeUniqueTables {
+		match := ""
+		for _, key := range t.keys {
+			match += " AND c." + key + " IS NOT DISTINCT FROM d." + key
+		}
+		_, err = tx.Exec(`
+			DELETE FROM `+t.table+` d
+			USING `+t.table+` c
+			WHERE d.peak_id = $1 AND c.peak_id = $2`+match, dupID, canonicalID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`UPDATE `+t.table+` SET peak_id = $2 WHERE peak_id = $1`, dupID, canonicalID)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Only pending claims are unique, so a pending claim on the duplicate is
+	// dropped if the canonical peak has the same one. Reviewed claims are kept
+	// as history.
+	_, err = tx.Exec(`
+		DELETE FROM summit_claims d
+		USING summit_claims c
+		WHERE d.peak_id = $1 AND c.peak_id = $2
+			AND d.status = 'pending' AND c.status = 'pending'
+			AND c.user_id = d.user_id AND c.activity_id = d.activity_id
+	`, dupID, canonicalID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE summit_claims SET peak_id = $2 WHERE peak_id = $1`, dupID, canonicalID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE user_peaks SET previous_peak_id = $2 WHERE previous_peak_id = $1`, dupID, canonicalID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE user_achievements SET peak_id = $2 WHERE peak_id = $1`, dupID, canonicalID)
+	if err != nil {
+		return err
+	}
+
+	// Swap the ID in place and drop repeats, keeping the first position
+	for _, table := range peakMergeArrayTables {
+		_, err = tx.Exec(`
+			UPDATE `+table+` SET peak_ids = ARRAY(
+				SELECT x.peak_id
+				FROM unnest(array_replace(peak_ids, $1, $2)) WITH ORDINALITY AS x(peak_id, ord)
+				GROUP BY x.peak_id
+				ORDER BY MIN(x.ord)
+			)
+			WHERE $1 = ANY(peak_ids)
+		`, dupID, canonicalID)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE peaks SET merged_into_id = $2
+		WHERE id = $1 OR merged_into_id = $1
+	`, dupID, canonicalID)
+	return err
+}
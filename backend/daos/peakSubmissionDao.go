@@ -0,0 +1,229 @@
+package daos
+
+import (
+	"database/sql"
+	"log"
+	"run-goals/models"
+
+	"github.com/lib/pq"
+)
+
+type PeakSubmissionDaoInterface interface {
+	CreateSubmission(submission models.PeakSubmission) (*int64, error)
+	GetSubmissionByID(id int64) (*models.PeakSubmission, error)
+	GetSubmissionsByUser(userID int64) ([]models.PeakSubmission, error)
+	GetSubmissionsByStatus(status models.PeakSubmissionStatus) ([]models.PeakSubmission, error)
+	ApproveNewPeak(submissionID int64, reviewerID int64, reviewerNote *string, peak models.Peak) (int64, error)
+	ApproveCorrection(submissionID int64, reviewerID int64, reviewerNote *string, peakID int64, submission models.PeakSubmission, lockedFields []string) error
+	RejectSubmission(submissionID int64, reviewerID int64, reviewerNote *string) error
+}
+
+type PeakSubmissionDao struct {
+	l  *log.Logger
+	db *sql.DB
+}
+
+func NewPeakSubmissionDao(logger *log.Logger, db *sql.DB) *PeakSubmissionDao {
+	return &PeakSubmissionDao{
+		l:  logger,
+		db: db,
+	}
+}
+
+const peakSubmissionSelect = `
+	SELECT
+		ps.id, ps.user_id, COALESCE(u.username, ''), ps.kind, ps.peak_id,
+		ps.name, ps.latitude, ps.longitude, ps.elevation_meters, ps.note,
+		ps.status, ps.reviewer_note, ps.reviewed_by_user_id, ps.created_at, ps.reviewed_at
+	FROM peak_submissions ps
+	LEFT JOIN users u ON u.id = ps.user_id
+`
+
+func (dao *PeakSubmissionDao) CreateSubmission(submission models.PeakSubmission) (*int64, error) {
+	var id int64
+	query := `
+		INSERT INTO peak_submissions (user_id, kind, peak_id, name, latitude, longitude, elevation_meters, note)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id;
+	`
+	err := dao.db.QueryRow(query,
+		submission.UserID, submission.Kind, submission.PeakID, submission.Name,
+		submission.Latitude, submission.Longitude, submission.ElevationMeters, submission.Note,
+	).Scan(&id)
+	if err != nil {
+		dao.l.Printf("Error creating peak submission: %v", err)
+		return nil, err
+	}
+	return &id, nil
+}
+
+func (dao *PeakSubmissionDao) GetSubmissionByID(id int64) (*models.PeakSubmission, error) {
+	rows, err := dao.db.Query(peakSubmissionSelect+`WHERE ps.id = $1`, id)
+	if err != nil {
+		dao.l.Printf("Error getting peak submission: %v", err)
+		return nil, err
+	}
+	submissions, err := dao.scanSubmissions(rows)
+	if err != nil || len(submissions) == 0 {
+		return nil, err
+	}
+	return &submissions[0], nil
+}
+
+func (dao *PeakSubmissionDao) GetSubmissionsByUser(userID int64) ([]models.PeakSubmission, error) {
+	rows, err := dao.db.Query(peakSubmissionSelect+`WHERE ps.user_id = $1 ORDER BY ps.created_at DESC`, userID)
+	if err != nil {
+		dao.l.Printf("Error getting user peak submissions: %v", err)
+		return nil, err
+	}
+	return dao.scanSubmissions(rows)
+}
+
+// GetSubmissionsByStatus returns submissions oldest first, so the queue is
+// worked in order
+func (dao *PeakSubmissionDao) GetSubmissionsByStatus(status models.PeakSubmissionStatus) ([]models.PeakSubmission, error) {
+	rows, err := dao.db.Query(peakSubmissionSelect+`WHERE ps.status = $1 ORDER BY ps.created_at, ps.id`, status)
+	if err != nil {
+		dao.l.Printf("Error getting peak submissions: %v", err)
+		return nil, err
+	}
+	return dao.scanSubmissions(rows)
+}
+
+func (dao *PeakSubmissionDao) scanSubmissions(rows *sql.Rows) ([]models.PeakSubmission, error) {
+	defer rows.Close()
+
+	submissions := []models.PeakSubmission{}
+	for rows.Next() {
+		s := models.PeakSubmission{}
+		err := rows.Scan(
+			&s.ID, &s.UserID, &s.SubmitterName, &s.Kind, &s.PeakID,
+			&s.Name, &s.Latitude, &s.Longitude, &s.ElevationMeters, &s.Note,
+			&s.Status, &s.ReviewerNote, &s.ReviewedByUserID, &s.CreatedAt, &s.ReviewedAt,
+		)
+		if err != nil {
+			dao.l.Printf("Error scanning peak submission: %v", err)
+			return nil, err
+		}
+		submissions = append(submissions, s)
+	}
+	return submissions, rows.Err()
+}
+
+// ==================== Review ====================
+
+// ApproveNewPeak adds the peak as a local peak and closes the submission in
+// one transaction
+func (dao *PeakSubmissionDao) ApproveNewPeak(submissionID int64, reviewerID int64, reviewerNote *string, peak models.Peak) (int64, error) {
+	tx, err := dao.db.Begin()
+	if err != nil {
+		dao.l.Printf("Error starting transaction: %v", err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var peakID int64
+	err = tx.QueryRow(`
+		INSERT INTO peaks (latitude, longitude, name, elevation_meters, elevation_source, source)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), 'local')
+		RETURNING id
+	`, peak.Latitude, peak.Longitude, peak.Name, peak.ElevationMeters, peak.ElevationSource).Scan(&peakID)
+	if err != nil {
+		dao.l.Printf("Error inserting submitted peak: %v", err)
+		return 0, err
+	}
+
+	err = closeSubmission(tx, submissionID, models.PeakSubmissionStatusApproved, reviewerID, reviewerNote, &peakID)
+	if err != nil {
+		dao.l.Printf("Error approving peak submission: %v", err)
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		dao.l.Printf("Error committing peak submission approval: %v", err)
+		return 0, err
+	}
+	return peakID, nil
+}
+
+// ApproveCorrection applies the submission's non-nil fields to the peak, locks
+// them against the Overpass refresh and closes the submission
+func (dao *PeakSubmissionDao) ApproveCorrection(
+	submissionID int64,
+	reviewerID int64,
+	reviewerNote *string,
+	peakID int64,
+	submission models.PeakSubmission,
+	lockedFields []string,
+) error {
+	tx, err := dao.db.Begin()
+	if err != nil {
+		dao.l.Printf("Error starting transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE peaks SET
+			name = COALESCE($2, name),
+			latitude = COALESCE($3, latitude),
+			longitude = COALESCE($4, longitude),
+			elevation_meters = COALESCE($5, elevation_meters),
+			elevation_source = CASE WHEN $5::numeric IS NULL THEN elevation_source ELSE 'user' END,
+			locked_fields = ARRAY(SELECT DISTINCT unnest(locked_fields || $6::text[]))
+		WHERE id = $1
+	`, peakID, submission.Name, submission.Latitude, submission.Longitude, submission.ElevationMeters, pq.Array(lockedFields))
+	if err != nil {
+		dao.l.Printf("Error applying peak correction: %v", err)
+		return err
+	}
+
+	err = closeSubmission(tx, submissionID, models.PeakSubmissionStatusApproved, reviewerID, reviewerNote, &peakID)
+	if err != nil {
+		dao.l.Printf("Error approving peak submission: %v", err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (dao *PeakSubmissionDao) RejectSubmission(submissionID int64, reviewerID int64, reviewerNote *string) error {
+	tx, err := dao.db.Begin()
+	if err != nil {
+		dao.l.Printf("Error starting transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	err = closeSubmission(tx, submissionID, models.PeakSubmissionStatusRejected, reviewerID, reviewerNote, nil)
+	if err != nil {
+		dao.l.Printf("Error rejecting peak submission: %v", err)
+		return err
+	}
+	return tx.Commit()
+}
+
+// closeSubmission only closes pending submissions, so two moderators can't
+// both act on the same one
+func closeSubmission(tx *sql.Tx, submissionID int64, status models.PeakSubmissionStatus, reviewerID int64, reviewerNote *string, peakID *int64) error {
+	result, err := tx.Exec(`
+		UPDATE peak_submissions SET
+			status = $2,
+			reviewed_by_user_id = $3,
+			reviewer_note = $4,
+			peak_id = COALESCE($5, peak_id),
+			reviewed_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+	`, submissionID, status, reviewerID, reviewerNote, peakID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -2,14 +2,20 @@ package daos
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
+	"run-goals/geo"
 	"run-goals/models"
+	"strings"
+
+	"github.com/lib/pq"
 )
 
 type PeaksDaoInterface interface {
 	GetPeaks() ([]models.Peak, error)
 	UpsertPeak(models.Peak) error
 	GetPeaksBetweenLatLon(minLat float64, maxLat float64, minLon float64, maxLon float64) ([]models.Peak, error)
+	SearchPeaks(filter models.PeakSearchFilter) ([]models.PeakSearchResult, int, error)
 }
 
 type PeaksDao struct {
@@ -29,7 +35,7 @@ func (dao *PeaksDao) GetPeaks() ([]models.Peak, error) {
 	sql := `
 		SELECT
 			id,
-			osm_id,
+			COALESCE(osm_id, 0),
 			latitude,
 			longitude,
 			COALESCE(name, ''),
@@ -40,8 +46,11 @@ func (dao *PeaksDao) GetPeaks() ([]models.Peak, error) {
 			COALESCE(wikipedia, ''),
 			COALESCE(wikidata, ''),
 			COALESCE(description, ''),
-			COALESCE(prominence, 0)
+			COALESCE(prominence, 0),
+			COALESCE(elevation_source, ''),
+			source
 		FROM peaks
+		WHERE merged_into_id IS NULL
 	`
 	rows, err := dao.db.Query(sql)
 	if err != nil {
@@ -65,6 +74,8 @@ func (dao *PeaksDao) GetPeaks() ([]models.Peak, error) {
 			&peak.Wikidata,
 			&peak.Description,
 			&peak.Prominence,
+			&peak.ElevationSource,
+			&peak.Source,
 		)
 		if err != nil {
 			dao.l.Println("Error parsing query result", err)
@@ -95,17 +106,28 @@ func (dao *PeaksDao) UpsertPeak(peak *models.Peak) error {
 			wikipedia,
 			wikidata,
 			description,
-			prominence
+			prominence,
+			elevation_source
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NULLIF($13, '')
 		) ON CONFLICT (
 			osm_id
 		) DO UPDATE
 			SET
-				latitude = EXCLUDED.latitude,
-				longitude = EXCLUDED.longitude,
-				name = EXCLUDED.name,
-				elevation_meters = EXCLUDED.elevation_meters,
+				-- Fields corrected through peak submissions are locked
+				latitude = CASE WHEN 'position' = ANY(peaks.locked_fields) THEN peaks.latitude ELSE EXCLUDED.latitude END,
+				longitude = CASE WHEN 'position' = ANY(peaks.locked_fields) THEN peaks.longitude ELSE EXCLUDED.longitude END,
+				name = CASE WHEN 'name' = ANY(peaks.locked_fields) THEN peaks.name ELSE EXCLUDED.name END,
+				-- Keep a DEM fill when the refresh still has no elevation
+				elevation_meters = CASE
+					WHEN 'elevation' = ANY(peaks.locked_fields) THEN peaks.elevation_meters
+					WHEN EXCLUDED.elevation_source IS NULL AND peaks.elevation_source = 'dem' THEN peaks.elevation_meters
+					ELSE EXCLUDED.elevation_meters
+				END,
+				elevation_source = CASE
+					WHEN 'elevation' = ANY(peaks.locked_fields) THEN peaks.elevation_source
+					ELSE COALESCE(EXCLUDED.elevation_source, peaks.elevation_source)
+				END,
 				alt_name = EXCLUDED.alt_name,
 				name_en = EXCLUDED.name_en,
 				region = EXCLUDED.region,
@@ -128,6 +150,7 @@ func (dao *PeaksDao) UpsertPeak(peak *models.Peak) error {
 		peak.Wikidata,
 		peak.Description,
 		peak.Prominence,
+		peak.ElevationSource,
 	)
 	if err != nil {
 		dao.l.Printf("Error upserting peak: %v", err)
@@ -141,7 +164,7 @@ func (dao *PeaksDao) GetPeaksBetweenLatLon(minLat float64, maxLat float64, minLo
 	sql := `
 		SELECT
 			id,
-			osm_id,
+			COALESCE(osm_id, 0),
 			latitude,
 			longitude,
 			COALESCE(name, ''),
@@ -152,11 +175,14 @@ func (dao *PeaksDao) GetPeaksBetweenLatLon(minLat float64, maxLat float64, minLo
 			COALESCE(wikipedia, ''),
 			COALESCE(wikidata, ''),
 			COALESCE(description, ''),
-			COALESCE(prominence, 0)
+			COALESCE(prominence, 0),
+			COALESCE(elevation_source, ''),
+			source
 		FROM peaks
 		WHERE
 			latitude BETWEEN $1 AND $2
 			AND longitude BETWEEN $3 AND $4
+			AND merged_into_id IS NULL
 	`
 	rows, err := dao.db.Query(sql, minLat, maxLat, minLon, maxLon)
 	if err != nil {
@@ -180,6 +206,8 @@ func (dao *PeaksDao) GetPeaksBetweenLatLon(minLat float64, maxLat float64, minLo
 			&peak.Wikidata,
 			&peak.Description,
 			&peak.Prominence,
+			&peak.ElevationSource,
+			&peak.Source,
 		)
 		if err != nil {
 			dao.l.Println("Error parsing query result", err)
@@ -195,3 +223,330 @@ func (dao *PeaksDao) GetPeaksBetweenLatLon(minLat float64, maxLat float64, minLo
 
 	return peaks, nil
 }
+
+// GetPeakByID returns a single peak, or nil if it doesn't exist
+func (dao *PeaksDao) GetPeakByID(id int64) (*models.Peak, error) {
+	peak := models.Peak{}
+	query := `
+		SELECT
+			id,
+			COALESCE(osm_id, 0),
+			latitude,
+			longitude,
+			COALESCE(name, ''),
+			COALESCE(elevation_meters, 0),
+			COALESCE(alt_name, ''),
+			COALESCE(name_en, ''),
+			COALESCE(region, ''),
+			COALESCE(wikipedia, ''),
+			COALESCE(wikidata, ''),
+			COALESCE(description, ''),
+			COALESCE(prominence, 0),
+			COALESCE(elevation_source, ''),
+			source,
+			merged_into_id
+		FROM peaks
+		WHERE id = $1
+	`
+	err := dao.db.QueryRow(query, id).Scan(
+		&peak.ID,
+		&peak.OsmID,
+		&peak.Latitude,
+		&peak.Longitude,
+		&peak.Name,
+		&peak.ElevationMeters,
+		&peak.AltName,
+		&peak.NameEN,
+		&peak.Region,
+		&peak.Wikipedia,
+		&peak.Wikidata,
+		&peak.Description,
+		&peak.Prominence,
+		&peak.ElevationSource,
+		&peak.Source,
+		&peak.MergedIntoID,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		dao.l.Printf("Error getting peak %d: %v", id, err)
+		return nil, err
+	}
+	return &peak, nil
+}
+
+// UpdatePeakElevation sets a peak's elevation and where it came from
+func (dao *PeaksDao) UpdatePeakElevation(id int64, elevation float64, source string) error {
+	query := `
+		UPDATE peaks
+		SET elevation_meters = $2, elevation_source = $3
+		WHERE id = $1;
+	`
+	_, err := dao.db.Exec(query, id, elevation, source)
+	if err != nil {
+		dao.l.Printf("Error updating peak %d elevation: %v", id, err)
+		return err
+	}
+	return nil
+}
+
+// GetPeaksByIDs returns the given peaks, merged ones included
+func (dao *PeaksDao) GetPeaksByIDs(ids []int64) ([]models.Peak, error) {
+	peaks := []models.Peak{}
+	query := `
+		SELECT
+			id,
+			COALESCE(osm_id, 0),
+			latitude,
+			longitude,
+			COALESCE(name, ''),
+			COALESCE(elevation_meters, 0),
+			COALESCE(alt_name, ''),
+			COALESCE(name_en, ''),
+			COALESCE(region, ''),
+			COALESCE(wikipedia, ''),
+			COALESCE(wikidata, ''),
+			COALESCE(description, ''),
+			COALESCE(prominence, 0),
+			COALESCE(elevation_source, ''),
+			source,
+			merged_into_id
+		FROM peaks
+		WHERE id = ANY($1)
+		ORDER BY id
+	`
+	rows, err := dao.db.Query(query, pq.Array(ids))
+	if err != nil {
+		dao.l.Printf("Error getting peaks by ID: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		peak := models.Peak{}
+		err = rows.Scan(
+			&peak.ID,
+			&peak.OsmID,
+			&peak.Latitude,
+			&peak.Longitude,
+			&peak.Name,
+			&peak.ElevationMeters,
+			&peak.AltName,
+			&peak.NameEN,
+			&peak.Region,
+			&peak.Wikipedia,
+			&peak.Wikidata,
+			&peak.Description,
+			&peak.Prominence,
+			&peak.ElevationSource,
+			&peak.Source,
+			&peak.MergedIntoID,
+		)
+		if err != nil {
+			dao.l.Printf("Error scanning peak: %v", err)
+			return nil, err
+		}
+		peaks = append(peaks, peak)
+	}
+	return peaks, rows.Err()
+}
+
+// peakSearchMinScore is the lowest trigram word similarity that counts as a
+// name match. Substring matches always count.
+const peakSearchMinScore = 0.3
+
+// SearchPeaks returns one page of peaks matching the filter and the total
+// number of matches
+func (dao *PeaksDao) SearchPeaks(filter models.PeakSearchFilter) ([]models.PeakSearchResult, int, error) {
+	args := []interface{}{filter.UserID}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	score := "0::float8"
+	distance := "NULL::float8"
+	conditions := []string{"p.merged_into_id IS NULL"}
+
+	if filter.Query != "" {
+		q := arg(filter.Query)
+		score = fmt.Sprintf(`GREATEST(
+				word_similarity(%[1]s, COALESCE(p.name, '')),
+				word_similarity(%[1]s, COALESCE(p.alt_name, '')),
+				word_similarity(%[1]s, COALESCE(p.name_en, ''))
+			)::float8`, q)
+		conditions = append(conditions, fmt.Sprintf(`(
+				%[1]s >= %[2]s
+				OR p.name ILIKE '%%' || %[3]s || '%%'
+				OR p.alt_name ILIKE '%%' || %[3]s || '%%'
+				OR p.name_en ILIKE '%%' || %[3]s || '%%'
+			)`, score, arg(peakSearchMinScore), q))
+	}
+	if filter.Region != nil {
+		conditions = append(conditions, "p.region ILIKE "+arg(*filter.Region))
+	}
+	if filter.MinElevation != nil {
+		conditions = append(conditions, "p.elevation_meters >= "+arg(*filter.MinElevation))
+	}
+	if filter.MaxElevation != nil {
+		conditions = append(conditions, "p.elevation_meters <= "+arg(*filter.MaxElevation))
+	}
+	if filter.MinProminence != nil {
+		conditions = append(conditions, "p.prominence >= "+arg(*filter.MinProminence))
+	}
+	if filter.Summited != nil {
+		summitedBy := "up.user_id = $1"
+		if filter.SummitedBy == models.PeakSummitedByGroup && filter.GroupID != nil {
+			summitedBy = "up.user_id IN (SELECT gm.user_id FROM group_members gm WHERE gm.group_id = " + arg(*filter.GroupID) + ")"
+		}
+		exists := "EXISTS (SELECT 1 FROM user_peaks up WHERE up.peak_id = p.id AND " + summitedBy + ")"
+		if !*filter.Summited {
+			exists = "NOT " + exists
+		}
+		conditions = append(conditions, exists)
+	}
+	if filter.NearLat != nil && filter.NearLon != nil {
+		lat, lon := *filter.NearLat, *filter.NearLon
+		minLat, maxLat, minLon, maxLon := geo.BoundingBox(lat, lon, filter.RadiusMeters)
+		latArg, lonArg := arg(lat), arg(lon)
+		distance = fmt.Sprintf(`(2 * 6371000 * ASIN(SQRT(
+				POWER(SIN(RADIANS(p.latitude - %[1]s) / 2), 2) +
+				COS(RADIANS(%[1]s)) * COS(RADIANS(p.latitude)) * POWER(SIN(RADIANS(p.longitude - %[2]s) / 2), 2)
+			)))::float8`, latArg, lonArg)
+		conditions = append(conditions,
+			fmt.Sprintf("p.latitude BETWEEN %s AND %s", arg(minLat), arg(maxLat)),
+			fmt.Sprintf("p.longitude BETWEEN %s AND %s", arg(minLon), arg(maxLon)),
+			fmt.Sprintf("%s <= %s", distance, arg(filter.RadiusMeters)),
+		)
+	}
+
+	direction := "ASC"
+	if filter.Descending {
+		direction = "DESC"
+	}
+	var orderBy string
+	switch filter.Sort {
+	case models.PeakSearchSortRelevance:
+		// Best match first unless asked otherwise
+		if filter.Descending {
+			orderBy = "score ASC"
+		} else {
+			orderBy = "score DESC"
+		}
+	case models.PeakSearchSortElevation:
+		orderBy = "COALESCE(p.elevation_meters, 0) " + direction
+	case models.PeakSearchSortProminence:
+		orderBy = "COALESCE(p.prominence, 0) " + direction
+	case models.PeakSearchSortDistance:
+		orderBy = "distance " + direction + " NULLS LAST"
+	default:
+		orderBy = "COALESCE(p.name, '') " + direction
+	}
+
+	sql := fmt.Sprintf(`
+		SELECT
+			p.id,
+			COALESCE(p.osm_id, 0),
+			p.latitude,
+			p.longitude,
+			COALESCE(p.name, ''),
+			COALESCE(p.elevation_meters, 0),
+			COALESCE(p.alt_name, ''),
+			COALESCE(p.name_en, ''),
+			COALESCE(p.region, ''),
+			COALESCE(p.wikipedia, ''),
+			COALESCE(p.wikidata, ''),
+			COALESCE(p.description, ''),
+			COALESCE(p.prominence, 0),
+			EXISTS (SELECT 1 FROM user_peaks up WHERE up.peak_id = p.id AND up.user_id = $1),
+			%s AS score,
+			%s AS distance,
+			COUNT(*) OVER () AS total
+		FROM peaks p
+		WHERE %s
+		ORDER BY %s, p.id
+		LIMIT %s OFFSET %s
+	`, score, distance, strings.Join(conditions, "\n\t\t\tAND "), orderBy, arg(filter.Limit), arg(filter.Offset))
+
+	rows, err := dao.db.Query(sql, args...)
+	if err != nil {
+		dao.l.Printf("Error searching peaks: %v", err)
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	results := []models.PeakSearchResult{}
+	total := 0
+	for rows.Next() {
+		result := models.PeakSearchResult{}
+		err = rows.Scan(
+			&result.ID,
+			&result.OsmID,
+			&result.Latitude,
+			&result.Longitude,
+			&result.Name,
+			&result.ElevationMeters,
+			&result.AltName,
+			&result.NameEN,
+			&result.Region,
+			&result.Wikipedia,
+			&result.Wikidata,
+			&result.Description,
+			&result.Prominence,
+			&result.IsSummited,
+			&result.Score,
+			&result.DistanceMeters,
+			&total,
+		)
+		if err != nil {
+			dao.l.Printf("Error parsing peak search result: %v", err)
+			return nil, 0, err
+		}
+		results = append(results, result)
+	}
+	err = rows.Err()
+	if err != nil {
+		dao.l.Printf("Error during peak search iteration: %v", err)
+		return nil, 0, err
+	}
+
+	return results, total, nil
+}
+
+// GetMapPeaks returns active peaks inside the box, highest first, flagged with
+// whether the user has summited them
+func (dao *PeaksDao) GetMapPeaks(userID int64, bounds models.BoundingBox, limit int) ([]models.MapPeak, error) {
+	query := `
+		SELECT
+			p.id,
+			COALESCE(p.name, ''),
+			p.latitude,
+			p.longitude,
+			COALESCE(p.elevation_meters, 0),
+			EXISTS (SELECT 1 FROM user_peaks up WHERE up.peak_id = p.id AND up.user_id = $1)
+		FROM peaks p
+		WHERE
+			p.latitude BETWEEN $2 AND $3
+			AND p.longitude BETWEEN $4 AND $5
+			AND p.merged_into_id IS NULL
+		ORDER BY p.elevation_meters DESC NULLS LAST, p.id
+		LIMIT $6
+	`
+	rows, err := dao.db.Query(query, userID, bounds.MinLat, bounds.MaxLat, bounds.MinLon, bounds.MaxLon, limit)
+	if err != nil {
+		dao.l.Printf("Error querying map peaks: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	peaks := []models.MapPeak{}
+	for rows.Next() {
+		p := models.MapPeak{}
+		if err := rows.Scan(&p.ID, &p.Name, &p.Latitude, &p.Longitude, &p.ElevationMeters, &p.Summited); err != nil {
+			dao.l.Printf("Error scanning map peak: %v", err)
+			return nil, err
+		}
+		peaks = append(peaks, p)
+	}
+	return peaks, rows.Err()
+}
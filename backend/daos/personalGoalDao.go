@@ -0,0 +1,148 @@
+package daos
+
+import (
+	"database/sql"
+	"log"
+	"run-goals/models"
+
+	"github.com/lib/pq"
+)
+
+type PersonalGoalDao struct {
+	l  *log.Logger
+	db *sql.DB
+}
+
+func NewPersonalGoalDao(logger *log.Logger, db *sql.DB) *PersonalGoalDao {
+	return &PersonalGoalDao{
+		l:  logger,
+		db: db,
+	}
+}
+
+// Create inserts a new personal goal and sets its ID
+func (dao *PersonalGoalDao) Create(goal *models.PersonalGoal) error {
+	query := `
+		INSERT INTO personal_goals (
+			user_id, name, metric, period_type, start_date, end_date,
+			target_value, activity_types, region, peak_ids, is_yearly_goal
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, created_at, updated_at
+	`
+	err := dao.db.QueryRow(query,
+		goal.UserID, goal.Name, goal.Metric, goal.PeriodType, goal.StartDate, goal.EndDate,
+		goal.TargetValue, pq.Array(goal.ActivityTypes), goal.Region, pq.Array(goal.PeakIDs), goal.IsYearlyGoal,
+	).Scan(&goal.ID, &goal.CreatedAt, &goal.UpdatedAt)
+	if err != nil {
+		dao.l.Printf("Error creating personal goal: %v", err)
+		return err
+	}
+	return nil
+}
+
+// Update saves changes to a personal goal. is_yearly_goal is never changed.
+func (dao *PersonalGoalDao) Update(goal *models.PersonalGoal) error {
+	query := `
+		UPDATE personal_goals SET
+			name = $2, metric = $3, period_type = $4, start_date = $5, end_date = $6,
+			target_value = $7, activity_types = $8, region = $9, peak_ids = $10,
+			updated_at = NOW()
+		WHERE id = $1
+		RETURNING updated_at
+	`
+	err := dao.db.QueryRow(query,
+		goal.ID, goal.Name, goal.Metric, goal.PeriodType, goal.StartDate, goal.EndDate,
+		goal.TargetValue, pq.Array(goal.ActivityTypes), goal.Region, pq.Array(goal.PeakIDs),
+	).Scan(&goal.UpdatedAt)
+	if err != nil {
+		dao.l.Printf("Error updating personal goal: %v", err)
+		return err
+	}
+	return nil
+}
+
+// Delete removes a personal goal
+func (dao *PersonalGoalDao) Delete(id int64) error {
+	query := `DELETE FROM personal_goals WHERE id = $1`
+	_, err := dao.db.Exec(query, id)
+	if err != nil {
+		dao.l.Printf("Error deleting personal goal: %v", err)
+		return err
+	}
+	return nil
+}
+
+// GetByID returns a personal goal, or nil if it doesn't exist
+func (dao *PersonalGoalDao) GetByID(id int64) (*models.PersonalGoal, error) {
+	query := `
+		SELECT
+			id, user_id, name, metric, period_type, start_date, end_date,
+			target_value, activity_types, region, peak_ids, is_yearly_goal,
+			created_at, updated_at
+		FROM personal_goals
+		WHERE id = $1
+	`
+	rows, err := dao.db.Query(query, id)
+	if err != nil {
+		dao.l.Printf("Error getting personal goal: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	goals, err := dao.scanGoals(rows)
+	if err != nil || len(goals) == 0 {
+		return nil, err
+	}
+	return &goals[0], nil
+}
+
+// GetByUser returns all of a user's personal goals, most recent period first
+func (dao *PersonalGoalDao) GetByUser(userID int64) ([]models.PersonalGoal, error) {
+	query := `
+		SELECT
+			id, user_id, name, metric, period_type, start_date, end_date,
+			target_value, activity_types, region, peak_ids, is_yearly_goal,
+			created_at, updated_at
+		FROM personal_goals
+		WHERE user_id = $1
+		ORDER BY start_date DESC, end_date DESC, id
+	`
+	rows, err := dao.db.Query(query, userID)
+	if err != nil {
+		dao.l.Printf("Error getting personal goals: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	return dao.scanGoals(rows)
+}
+
+func (dao *PersonalGoalDao) scanGoals(rows *sql.Rows) ([]models.PersonalGoal, error) {
+	goals := []models.PersonalGoal{}
+	for rows.Next() {
+		var goal models.PersonalGoal
+		var activityTypes pq.StringArray
+		var peakIDs pq.Int64Array
+		err := rows.Scan(
+			&goal.ID, &goal.UserID, &goal.Name, &goal.Metric, &goal.PeriodType, &goal.StartDate, &goal.EndDate,
+			&goal.TargetValue, &activityTypes, &goal.Region, &peakIDs, &goal.IsYearlyGoal,
+			&goal.CreatedAt, &goal.UpdatedAt,
+		)
+		if err != nil {
+			dao.l.Printf("Error scanning personal goal: %v", err)
+			return nil, err
+		}
+		goal.ActivityTypes = []string(activityTypes)
+		if goal.ActivityTypes == nil {
+			goal.ActivityTypes = []string{}
+		}
+		goal.PeakIDs = []int64(peakIDs)
+		goals = append(goals, goal)
+	}
+	err := rows.Err()
+	if err != nil {
+		dao.l.Printf("Error during personal goals iteration: %v", err)
+		return nil, err
+	}
+	return goals, nil
+}
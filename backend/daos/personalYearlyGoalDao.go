@@ -2,6 +2,7 @@ package daos
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
 	"run-goals/models"
 	"time"
@@ -19,14 +20,27 @@ func NewPersonalYearlyGoalDao(logger *log.Logger, db *sql.DB) *PersonalYearlyGoa
 	}
 }
 
+// Yearly goals are stored in personal_goals as one row per metric with
+// is_yearly_goal set. These queries fold them back into a PersonalYearlyGoal.
+const yearlyGoalSelect = `
+		SELECT
+			MIN(id),
+			user_id,
+			EXTRACT(YEAR FROM start_date)::INT AS year,
+			COALESCE(MAX(target_value) FILTER (WHERE metric = 'distance'), 0),
+			COALESCE(MAX(target_value) FILTER (WHERE metric = 'elevation'), 0),
+			COALESCE(MAX(target_value) FILTER (WHERE metric = 'summit_count'), 0)::INT,
+			MIN(created_at),
+			MAX(updated_at)
+		FROM personal_goals
+`
+
 // GetByUserAndYear retrieves a user's goal for a specific year
 func (dao *PersonalYearlyGoalDao) GetByUserAndYear(userID int64, year int) (*models.PersonalYearlyGoal, error) {
 	goal := &models.PersonalYearlyGoal{}
-	query := `
-		SELECT id, user_id, year, distance_goal, elevation_goal, summit_goal,
-			   created_at, updated_at
-		FROM personal_yearly_goals
-		WHERE user_id = $1 AND year = $2
+	query := yearlyGoalSelect + `
+		WHERE user_id = $1 AND is_yearly_goal AND start_date = make_date($2, 1, 1)
+		GROUP BY user_id, start_date
 	`
 	err := dao.db.QueryRow(query, userID, year).Scan(
 		&goal.ID,
@@ -51,12 +65,10 @@ func (dao *PersonalYearlyGoalDao) GetByUserAndYear(userID int64, year int) (*mod
 // GetByUser retrieves all goals for a user (for history view)
 func (dao *PersonalYearlyGoalDao) GetByUser(userID int64) ([]models.PersonalYearlyGoal, error) {
 	goals := []models.PersonalYearlyGoal{}
-	query := `
-		SELECT id, user_id, year, distance_goal, elevation_goal, summit_goal,
-			   created_at, updated_at
-		FROM personal_yearly_goals
-		WHERE user_id = $1
-		ORDER BY year DESC
+	query := yearlyGoalSelect + `
+		WHERE user_id = $1 AND is_yearly_goal
+		GROUP BY user_id, start_date
+		ORDER BY start_date DESC
 	`
 	rows, err := dao.db.Query(query, userID)
 	if err != nil {
@@ -90,15 +102,14 @@ func (dao *PersonalYearlyGoalDao) GetByUser(userID int64) ([]models.PersonalYear
 // Upsert creates or updates a user's goal for a specific year
 func (dao *PersonalYearlyGoalDao) Upsert(goal *models.PersonalYearlyGoal) error {
 	query := `
-		INSERT INTO personal_yearly_goals (
-			user_id, year, distance_goal, elevation_goal, summit_goal, created_at, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7)
-		ON CONFLICT (user_id, year) DO UPDATE SET
-			distance_goal = EXCLUDED.distance_goal,
-			elevation_goal = EXCLUDED.elevation_goal,
-			summit_goal = EXCLUDED.summit_goal,
+		INSERT INTO personal_goals (
+			user_id, name, metric, period_type, start_date, end_date,
+			target_value, is_yearly_goal, created_at, updated_at
+		) VALUES ($1, $2, $3, 'year', make_date($4, 1, 1), make_date($4, 12, 31), $5, TRUE, $6, $7)
+		ON CONFLICT (user_id, metric, start_date) WHERE is_yearly_goal DO UPDATE SET
+			target_value = EXCLUDED.target_value,
 			updated_at = EXCLUDED.updated_at
-		RETURNING id
+		RETURNING id, created_at
 	`
 	now := time.Now()
 	if goal.CreatedAt.IsZero() {
@@ -106,19 +117,48 @@ func (dao *PersonalYearlyGoalDao) Upsert(goal *models.PersonalYearlyGoal) error
 	}
 	goal.UpdatedAt = now
 
-	err := dao.db.QueryRow(
-		query,
-		goal.UserID,
-		goal.Year,
-		goal.DistanceGoal,
-		goal.ElevationGoal,
-		goal.SummitGoal,
-		goal.CreatedAt,
-		goal.UpdatedAt,
-	).Scan(&goal.ID)
+	metrics := []struct {
+		metric models.PersonalGoalMetric
+		label  string
+		target float64
+	}{
+		{models.PersonalGoalMetricDistance, "distance goal", goal.DistanceGoal},
+		{models.PersonalGoalMetricElevation, "elevation goal", goal.ElevationGoal},
+		{models.PersonalGoalMetricSummitCount, "summit goal", float64(goal.SummitGoal)},
+	}
 
+	tx, err := dao.db.Begin()
 	if err != nil {
-		dao.l.Printf("Error upserting personal yearly goal: %v", err)
+		dao.l.Printf("Error starting personal yearly goal transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	for i, m := range metrics {
+		var id int64
+		var createdAt time.Time
+		err := tx.QueryRow(
+			query,
+			goal.UserID,
+			fmt.Sprintf("%d %s", goal.Year, m.label),
+			m.metric,
+			goal.Year,
+			m.target,
+			goal.CreatedAt,
+			goal.UpdatedAt,
+		).Scan(&id, &createdAt)
+		if err != nil {
+			dao.l.Printf("Error upserting personal yearly goal: %v", err)
+			return err
+		}
+		if i == 0 {
+			goal.ID = id
+			goal.CreatedAt = createdAt
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		dao.l.Printf("Error committing personal yearly goal: %v", err)
 		return err
 	}
 	return nil
@@ -126,7 +166,7 @@ func (dao *PersonalYearlyGoalDao) Upsert(goal *models.PersonalYearlyGoal) error
 
 // Delete removes a user's goal for a specific year
 func (dao *PersonalYearlyGoalDao) Delete(userID int64, year int) error {
-	query := `DELETE FROM personal_yearly_goals WHERE user_id = $1 AND year = $2`
+	query := `DELETE FROM personal_goals WHERE user_id = $1 AND is_yearly_goal AND start_date = make_date($2, 1, 1)`
 	_, err := dao.db.Exec(query, userID, year)
 	if err != nil {
 		dao.l.Printf("Error deleting personal yearly goal: %v", err)
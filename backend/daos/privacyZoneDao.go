@@ -0,0 +1,101 @@
+package daos
+
+import (
+	"database/sql"
+	"log"
+	"run-goals/models"
+
+	"github.com/lib/pq"
+)
+
+type PrivacyZoneDaoInterface interface {
+	CreateZone(zone models.PrivacyZone) (*int64, error)
+	GetZonesByUser(userID int64) ([]models.PrivacyZone, error)
+	GetZonesByUsers(userIDs []int64) ([]models.PrivacyZone, error)
+	DeleteZone(zoneID int64, userID int64) (bool, error)
+}
+
+type PrivacyZoneDao struct {
+	l  *log.Logger
+	db *sql.DB
+}
+
+func NewPrivacyZoneDao(logger *log.Logger, db *sql.DB) *PrivacyZoneDao {
+	return &PrivacyZoneDao{
+		l:  logger,
+		db: db,
+	}
+}
+
+func (dao *PrivacyZoneDao) CreateZone(zone models.PrivacyZone) (*int64, error) {
+	var id int64
+	query := `
+		INSERT INTO privacy_zones (
+			user_id, name, latitude, longitude, radius_meters,
+			trim_latitude, trim_longitude, trim_radius_meters
+		)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6, $7, $8)
+		RETURNING id;
+	`
+	err := dao.db.QueryRow(
+		query,
+		zone.UserID, zone.Name, zone.Latitude, zone.Longitude, zone.RadiusMeters,
+		zone.TrimLatitude, zone.TrimLongitude, zone.TrimRadiusMeters,
+	).Scan(&id)
+	if err != nil {
+		dao.l.Printf("Error creating privacy zone: %v", err)
+		return nil, err
+	}
+	return &id, nil
+}
+
+func (dao *PrivacyZoneDao) GetZonesByUser(userID int64) ([]models.PrivacyZone, error) {
+	return dao.GetZonesByUsers([]int64{userID})
+}
+
+// GetZonesByUsers returns the zones of all the given users, so feeds can load
+// them in one query
+func (dao *PrivacyZoneDao) GetZonesByUsers(userIDs []int64) ([]models.PrivacyZone, error) {
+	query := `
+		SELECT id, user_id, COALESCE(name, ''), latitude, longitude, radius_meters,
+			trim_latitude, trim_longitude, trim_radius_meters, created_at
+		FROM privacy_zones
+		WHERE user_id = ANY($1)
+		ORDER BY user_id, id
+	`
+	rows, err := dao.db.Query(query, pq.Int64Array(userIDs))
+	if err != nil {
+		dao.l.Printf("Error querying privacy zones: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	zones := []models.PrivacyZone{}
+	for rows.Next() {
+		z := models.PrivacyZone{}
+		if err := rows.Scan(
+			&z.ID, &z.UserID, &z.Name, &z.Latitude, &z.Longitude, &z.RadiusMeters,
+			&z.TrimLatitude, &z.TrimLongitude, &z.TrimRadiusMeters, &z.CreatedAt,
+		); err != nil {
+			dao.l.Printf("Error scanning privacy zone: %v", err)
+			return nil, err
+		}
+		zones = append(zones, z)
+	}
+	return zones, rows.Err()
+}
+
+// DeleteZone deletes one of the user's zones, returning false if they have no
+// zone with that ID
+func (dao *PrivacyZoneDao) DeleteZone(zoneID int64, userID int64) (bool, error) {
+	result, err := dao.db.Exec(`DELETE FROM privacy_zones WHERE id = $1 AND user_id = $2`, zoneID, userID)
+	if err != nil {
+		dao.l.Printf("Error deleting privacy zone %d: %v", zoneID, err)
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
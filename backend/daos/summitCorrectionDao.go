@@ -0,0 +1,384 @@
+package daos
+
+import (
+	"database/sql"
+	"log"
+	"run-goals/models"
+)
+
+type SummitCorrectionDaoInterface interface {
+	DismissSummit(userID int64, peakID int64, activityID int64, reason *string) (bool, error)
+	GetDismissalsByUser(userID int64) ([]models.SummitDismissal, error)
+	GetDismissedPeakIDs(activityID int64) (map[int64]bool, error)
+	CreateClaim(claim models.SummitClaim) (*int64, error)
+	GetClaimByID(id int64) (*models.SummitClaim, error)
+	GetClaimsByUser(userID int64) ([]models.SummitClaim, error)
+	GetPendingClaimsForReviewer(reviewerID int64, isAdmin bool) ([]models.SummitClaimForReview, error)
+	CanReviewClaims(reviewerID int64, claimantID int64) (bool, error)
+	ApproveClaim(claimID int64, reviewerID *int64, reviewerNote *string, userPeak models.UserPeak) error
+	RejectClaim(claimID int64, reviewerID int64, reviewerNote *string) error
+}
+
+type SummitCorrectionDao struct {
+	l  *log.Logger
+	db *sql.DB
+}
+
+func NewSummitCorrectionDao(logger *log.Logger, db *sql.DB) *SummitCorrectionDao {
+	return &SummitCorrectionDao{
+		l:  logger,
+		db: db,
+	}
+}
+
+// ==================== Dismissals ====================
+
+// DismissSummit removes a summit from user_peaks and records the tombstone in
+// one transaction. Returns false if the user had no such summit.
+func (dao *SummitCorrectionDao) DismissSummit(userID int64, peakID int64, activityID int64, reason *string) (bool, error) {
+	tx, err := dao.db.Begin()
+	if err != nil {
+		dao.l.Printf("Error starting transaction: %v", err)
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		DELETE FROM user_peaks
+		WHERE user_id = $1 AND peak_id = $2 AND activity_id = $3
+	`, userID, peakID, activityID)
+	if err != nil {
+		dao.l.Printf("Error deleting dismissed summit: %v", err)
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if n == 0 {
+		return false, nil
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO summit_dismissals (user_id, peak_id, activity_id, reason)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, peak_id, activity_id) DO UPDATE SET reason = EXCLUDED.reason
+	`, userID, peakID, activityID, reason)
+	if err != nil {
+		dao.l.Printf("Error recording summit dismissal: %v", err)
+		return false, err
+	}
+
+	// The activity may have no summits left
+	_, err = tx.Exec(`
+		UPDATE activity SET has_summit = EXISTS (SELECT 1 FROM user_peaks WHERE activity_id = $1)
+		WHERE id = $1
+	`, activityID)
+	if err != nil {
+		dao.l.Printf("Error updating activity has_summit: %v", err)
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		dao.l.Printf("Error committing summit dismissal: %v", err)
+		return false, err
+	}
+	return true, nil
+}
+
+func (dao *SummitCorrectionDao) GetDismissalsByUser(userID int64) ([]models.SummitDismissal, error) {
+	query := `
+		SELECT
+			sd.id, sd.user_id, sd.peak_id, COALESCE(p.name, ''),
+			sd.activity_id, COALESCE(a.name, ''), sd.reason, sd.created_at
+		FROM summit_dismissals sd
+		LEFT JOIN peaks p ON p.id = sd.peak_id
+		LEFT JOIN activity a ON a.id = sd.activity_id
+		WHERE sd.user_id = $1
+		ORDER BY sd.created_at DESC
+	`
+	rows, err := dao.db.Query(query, userID)
+	if err != nil {
+		dao.l.Printf("Error getting summit dismissals: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	dismissals := []models.SummitDismissal{}
+	for rows.Next() {
+		d := models.SummitDismissal{}
+		err := rows.Scan(&d.ID, &d.UserID, &d.PeakID, &d.PeakName, &d.ActivityID, &d.ActivityName, &d.Reason, &d.CreatedAt)
+		if err != nil {
+			dao.l.Printf("Error scanning summit dismissal: %v", err)
+			return nil, err
+		}
+		dismissals = append(dismissals, d)
+	}
+	return dismissals, rows.Err()
+}
+
+// GetDismissedPeakIDs returns the peaks dismissed on an activity, which
+// detection must not add back
+func (dao *SummitCorrectionDao) GetDismissedPeakIDs(activityID int64) (map[int64]bool, error) {
+	rows, err := dao.db.Query(`SELECT peak_id FROM summit_dismissals WHERE activity_id = $1`, activityID)
+	if err != nil {
+		dao.l.Printf("Error getting dismissed peaks: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	dismissed := map[int64]bool{}
+	for rows.Next() {
+		var peakID int64
+		if err := rows.Scan(&peakID); err != nil {
+			dao.l.Printf("Error scanning dismissed peak: %v", err)
+			return nil, err
+		}
+		dismissed[peakID] = true
+	}
+	return dismissed, rows.Err()
+}
+
+// ==================== Claims ====================
+
+const summitClaimSelect = `
+	SELECT
+		sc.id, sc.user_id, sc.peak_id, COALESCE(p.name, ''), sc.activity_id, COALESCE(a.name, ''),
+		sc.note, sc.distance_meters, sc.status, sc.auto_approved,
+		sc.reviewer_note, sc.reviewed_by_user_id, sc.created_at, sc.reviewed_at
+	FROM summit_claims sc
+	LEFT JOIN peaks p ON p.id = sc.peak_id
+	LEFT JOIN activity a ON a.id = sc.activity_id
+`
+
+// CreateClaim stores a pending claim. Returns sql.ErrNoRows if the user
+// already has a pending claim for the same summit.
+func (dao *SummitCorrectionDao) CreateClaim(claim models.SummitClaim) (*int64, error) {
+	var id int64
+	query := `
+		INSERT INTO summit_claims (user_id, peak_id, activity_id, note, distance_meters)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, peak_id, activity_id) WHERE status = 'pending' DO NOTHING
+		RETURNING id;
+	`
+	err := dao.db.QueryRow(query, claim.UserID, claim.PeakID, claim.ActivityID, claim.Note, claim.DistanceMeters).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		dao.l.Printf("Error creating summit claim: %v", err)
+		return nil, err
+	}
+	return &id, nil
+}
+
+func (dao *SummitCorrectionDao) GetClaimByID(id int64) (*models.SummitClaim, error) {
+	rows, err := dao.db.Query(summitClaimSelect+`WHERE sc.id = $1`, id)
+	if err != nil {
+		dao.l.Printf("Error getting summit claim: %v", err)
+		return nil, err
+	}
+	claims, err := dao.scanClaims(rows)
+	if err != nil || len(claims) == 0 {
+		return nil, err
+	}
+	return &claims[0], nil
+}
+
+func (dao *SummitCorrectionDao) GetClaimsByUser(userID int64) ([]models.SummitClaim, error) {
+	rows, err := dao.db.Query(summitClaimSelect+`WHERE sc.user_id = $1 ORDER BY sc.created_at DESC`, userID)
+	if err != nil {
+		dao.l.Printf("Error getting user summit claims: %v", err)
+		return nil, err
+	}
+	return dao.scanClaims(rows)
+}
+
+// claimReviewableBy limits claims to users the reviewer ($1) may review:
+// members of groups they're an admin of and participants in challenges they
+// created. Nobody reviews their own claims.
+const claimReviewableBy = `
+	sc.user_id <> $1 AND (
+		$2::boolean
+		OR sc.user_id IN (
+			SELECT gm.user_id FROM group_members gm
+			JOIN group_members me ON me.group_id = gm.group_id
+			WHERE me.user_id = $1 AND me.role = 'admin'
+		)
+		OR sc.user_id IN (
+			SELECT cp.user_id FROM challenge_participants cp
+			JOIN challenges c ON c.id = cp.challenge_id
+			WHERE c.created_by_user_id = $1
+		)
+	)
+`
+
+// GetPendingClaimsForReviewer returns the pending claims the reviewer may
+// act on, oldest first. Site admins see every claim.
+func (dao *SummitCorrectionDao) GetPendingClaimsForReviewer(reviewerID int64, isAdmin bool) ([]models.SummitClaimForReview, error) {
+	query := `
+		SELECT
+			sc.id, sc.user_id, sc.peak_id, COALESCE(p.name, ''), sc.activity_id, COALESCE(a.name, ''),
+			sc.note, sc.distance_meters, sc.status, sc.auto_approved,
+			sc.reviewer_note, sc.reviewed_by_user_id, sc.created_at, sc.reviewed_at,
+			COALESCE(u.username, ''), p.latitude, p.longitude, COALESCE(a.map_polyline, '')
+		FROM summit_claims sc
+		JOIN peaks p ON p.id = sc.peak_id
+		JOIN activity a ON a.id = sc.activity_id
+		JOIN users u ON u.id = sc.user_id
+		WHERE sc.status = 'pending' AND ` + claimReviewableBy + `
+		ORDER BY sc.created_at, sc.id
+	`
+	rows, err := dao.db.Query(query, reviewerID, isAdmin)
+	if err != nil {
+		dao.l.Printf("Error getting summit claim queue: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	claims := []models.SummitClaimForReview{}
+	for rows.Next() {
+		c := models.SummitClaimForReview{}
+		err := rows.Scan(
+			&c.ID, &c.UserID, &c.PeakID, &c.PeakName, &c.ActivityID, &c.ActivityName,
+			&c.Note, &c.DistanceMeters, &c.Status, &c.AutoApproved,
+			&c.ReviewerNote, &c.ReviewedByUserID, &c.CreatedAt, &c.ReviewedAt,
+			&c.UserName, &c.PeakLatitude, &c.PeakLongitude, &c.MapPolyline,
+		)
+		if err != nil {
+			dao.l.Printf("Error scanning summit claim for review: %v", err)
+			return nil, err
+		}
+		claims = append(claims, c)
+	}
+	return claims, rows.Err()
+}
+
+// CanReviewClaims reports whether the reviewer is a group admin or challenge
+// owner over the claimant. Site admins are checked by the caller.
+func (dao *SummitCorrectionDao) CanReviewClaims(reviewerID int64, claimantID int64) (bool, error) {
+	var ok bool
+	query := `SELECT EXISTS (SELECT 1 FROM (SELECT $3::bigint AS user_id) sc WHERE ` + claimReviewableBy + `)`
+	err := dao.db.QueryRow(query, reviewerID, false, claimantID).Scan(&ok)
+	if err != nil {
+		dao.l.Printf("Error checking summit claim reviewer: %v", err)
+		return false, err
+	}
+	return ok, nil
+}
+
+func (dao *SummitCorrectionDao) scanClaims(rows *sql.Rows) ([]models.SummitClaim, error) {
+	defer rows.Close()
+
+	claims := []models.SummitClaim{}
+	for rows.Next() {
+		c := models.SummitClaim{}
+		err := rows.Scan(
+			&c.ID, &c.UserID, &c.PeakID, &c.PeakName, &c.ActivityID, &c.ActivityName,
+			&c.Note, &c.DistanceMeters, &c.Status, &c.AutoApproved,
+			&c.ReviewerNote, &c.ReviewedByUserID, &c.CreatedAt, &c.ReviewedAt,
+		)
+		if err != nil {
+			dao.l.Printf("Error scanning summit claim: %v", err)
+			return nil, err
+		}
+		claims = append(claims, c)
+	}
+	return claims, rows.Err()
+}
+
+// ==================== Review ====================
+
+// ApproveClaim closes the claim, adds the summit to user_peaks as claimed and
+// clears any dismissal of it, in one transaction. A nil reviewer means the
+// claim was approved automatically.
+func (dao *SummitCorrectionDao) ApproveClaim(claimID int64, reviewerID *int64, reviewerNote *string, userPeak models.UserPeak) error {
+	tx, err := dao.db.Begin()
+	if err != nil {
+		dao.l.Printf("Error starting transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	err = closeClaim(tx, claimID, models.SummitClaimStatusApproved, reviewerID, reviewerNote)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			dao.l.Printf("Error approving summit claim: %v", err)
+		}
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO user_peaks (
+			user_id, peak_id, activity_id, summited_at, summit_time, elapsed_seconds, timing_source, source
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, 'claimed')
+		ON CONFLICT (user_id, peak_id, activity_id) DO NOTHING
+	`, userPeak.UserID, userPeak.PeakID, userPeak.ActivityID, userPeak.SummitedAt,
+		userPeak.SummitTime, userPeak.ElapsedSeconds, userPeak.TimingSource)
+	if err != nil {
+		dao.l.Printf("Error adding claimed summit: %v", err)
+		return err
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM summit_dismissals WHERE user_id = $1 AND peak_id = $2 AND activity_id = $3
+	`, userPeak.UserID, userPeak.PeakID, userPeak.ActivityID)
+	if err != nil {
+		dao.l.Printf("Error clearing summit dismissal: %v", err)
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE activity SET has_summit = TRUE WHERE id = $1`, userPeak.ActivityID)
+	if err != nil {
+		dao.l.Printf("Error updating activity has_summit: %v", err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		dao.l.Printf("Error committing summit claim approval: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (dao *SummitCorrectionDao) RejectClaim(claimID int64, reviewerID int64, reviewerNote *string) error {
+	tx, err := dao.db.Begin()
+	if err != nil {
+		dao.l.Printf("Error starting transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := closeClaim(tx, claimID, models.SummitClaimStatusRejected, &reviewerID, reviewerNote); err != nil {
+		if err != sql.ErrNoRows {
+			dao.l.Printf("Error rejecting summit claim: %v", err)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// closeClaim sets a pending claim's outcome. Returns sql.ErrNoRows if the
+// claim was already reviewed.
+func closeClaim(tx *sql.Tx, claimID int64, status models.SummitClaimStatus, reviewerID *int64, reviewerNote *string) error {
+	result, err := tx.Exec(`
+		UPDATE summit_claims SET
+			status = $2,
+			auto_approved = $3::bigint IS NULL,
+			reviewed_by_user_id = $3,
+			reviewer_note = $4,
+			reviewed_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+	`, claimID, status, reviewerID, reviewerNote)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
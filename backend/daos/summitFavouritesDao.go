@@ -3,6 +3,8 @@ package daos
 import (
 	"database/sql"
 	"log"
+	"run-goals/models"
+	"time"
 )
 
 type SummitFavouritesDao struct {
@@ -23,7 +25,7 @@ func (dao *SummitFavouritesDao) GetAllByUser(userID int64) ([]int64, error) {
 		SELECT peak_id
 		FROM summit_favourites
 		WHERE user_id = $1
-		ORDER BY created_at DESC
+		ORDER BY sort_order, created_at DESC
 	`
 	rows, err := dao.db.Query(query, userID)
 	if err != nil {
@@ -49,11 +51,15 @@ func (dao *SummitFavouritesDao) GetAllByUser(userID int64) ([]int64, error) {
 	return peakIDs, nil
 }
 
-// Add adds a peak to user's favourites
+// Add adds a peak to the top of the user's favourites
 func (dao *SummitFavouritesDao) Add(userID int64, peakID int64) error {
 	query := `
-		INSERT INTO summit_favourites (user_id, peak_id)
-		VALUES ($1, $2)
+		INSERT INTO summit_favourites (user_id, peak_id, sort_order)
+		VALUES ($1, $2, (
+			SELECT COALESCE(MIN(sort_order), 1) - 1
+			FROM summit_favourites
+			WHERE user_id = $1
+		))
 		ON CONFLICT (user_id, peak_id) DO NOTHING
 	`
 	_, err := dao.db.Exec(query, userID, peakID)
@@ -95,3 +101,121 @@ func (dao *SummitFavouritesDao) IsFavourite(userID int64, peakID int64) (bool, e
 	}
 	return exists, nil
 }
+
+// GetWishlist returns the user's favourites in order, with each peak and the
+// first summit of it since it was favourited
+func (dao *SummitFavouritesDao) GetWishlist(userID int64) ([]models.WishlistItem, error) {
+	query := `
+		SELECT
+			sf.id, sf.user_id, sf.peak_id, sf.notes, sf.target_date, sf.sort_order,
+			sf.created_at, COALESCE(sf.updated_at, sf.created_at),
+			p.id, COALESCE(p.osm_id, 0), p.latitude, p.longitude,
+			COALESCE(p.name, ''), COALESCE(p.elevation_meters, 0),
+			COALESCE(p.alt_name, ''), COALESCE(p.name_en, ''), COALESCE(p.region, ''),
+			COALESCE(p.wikipedia, ''), COALESCE(p.wikidata, ''), COALESCE(p.description, ''),
+			COALESCE(p.prominence, 0),
+			bag.summited_at, bag.activity_id,
+			EXISTS(
+				SELECT 1 FROM user_peaks up
+				WHERE up.user_id = sf.user_id AND up.peak_id = sf.peak_id
+				  AND up.summited_at < sf.created_at
+			)
+		FROM summit_favourites sf
+		JOIN peaks p ON p.id = sf.peak_id
+		LEFT JOIN LATERAL (
+			SELECT up.summited_at, up.activity_id
+			FROM user_peaks up
+			WHERE up.user_id = sf.user_id AND up.peak_id = sf.peak_id
+			  AND up.summited_at >= sf.created_at
+			ORDER BY up.summited_at
+			LIMIT 1
+		) bag ON TRUE
+		WHERE sf.user_id = $1
+		ORDER BY sf.sort_order, sf.created_at DESC
+	`
+	rows, err := dao.db.Query(query, userID)
+	if err != nil {
+		dao.l.Printf("Error getting wishlist: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.WishlistItem{}
+	for rows.Next() {
+		var item models.WishlistItem
+		err := rows.Scan(
+			&item.ID, &item.UserID, &item.PeakID, &item.Notes, &item.TargetDate, &item.SortOrder,
+			&item.CreatedAt, &item.UpdatedAt,
+			&item.Peak.ID, &item.Peak.OsmID, &item.Peak.Latitude, &item.Peak.Longitude,
+			&item.Peak.Name, &item.Peak.ElevationMeters,
+			&item.Peak.AltName, &item.Peak.NameEN, &item.Peak.Region,
+			&item.Peak.Wikipedia, &item.Peak.Wikidata, &item.Peak.Description,
+			&item.Peak.Prominence,
+			&item.BaggedAt, &item.BaggedActivityID,
+			&item.ClimbedBeforehand,
+		)
+		if err != nil {
+			dao.l.Printf("Error scanning wishlist item: %v", err)
+			return nil, err
+		}
+		item.Status = models.WishlistStatusToDo
+		if item.BaggedAt != nil {
+			item.Status = models.WishlistStatusBagged
+		}
+		items = append(items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		dao.l.Printf("Error during wishlist iteration: %v", err)
+		return nil, err
+	}
+	return items, nil
+}
+
+// UpdatePlan sets the notes and target date of a favourite. Returns false if
+// the peak isn't in the user's favourites.
+func (dao *SummitFavouritesDao) UpdatePlan(userID int64, peakID int64, notes *string, targetDate *time.Time) (bool, error) {
+	query := `
+		UPDATE summit_favourites
+		SET notes = $3, target_date = $4, updated_at = NOW()
+		WHERE user_id = $1 AND peak_id = $2
+	`
+	result, err := dao.db.Exec(query, userID, peakID, notes, targetDate)
+	if err != nil {
+		dao.l.Printf("Error updating summit favourite plan: %v", err)
+		return false, err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// Reorder sets the position of each favourite to its index in peakIDs
+func (dao *SummitFavouritesDao) Reorder(userID int64, peakIDs []int64) error {
+	tx, err := dao.db.Begin()
+	if err != nil {
+		dao.l.Printf("Error starting reorder transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE summit_favourites
+		SET sort_order = $3, updated_at = NOW()
+		WHERE user_id = $1 AND peak_id = $2
+	`
+	for i, peakID := range peakIDs {
+		if _, err := tx.Exec(query, userID, peakID, i+1); err != nil {
+			dao.l.Printf("Error reordering summit favourites: %v", err)
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		dao.l.Printf("Error committing reorder: %v", err)
+		return err
+	}
+	return nil
+}
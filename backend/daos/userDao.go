@@ -2,9 +2,12 @@ package daos
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"log"
 	"run-goals/models"
+
+	"github.com/lib/pq"
 )
 
 var ErrUserNotFound = errors.New("user not found")
@@ -84,8 +87,14 @@ func (dao *UserDao) GetUsers() ([]models.User, error) {
 			last_distance,
 			last_updated,
 			created_at,
-			updated_at
-		FROM users;
+			updated_at,
+			timezone,
+			week_start,
+			show_in_leaderboards,
+			show_in_group_feeds,
+			show_in_challenge_feeds
+		FROM users
+		WHERE deleted_at IS NULL;
 	`
 	rows, err := dao.db.Query(sql)
 	if err != nil {
@@ -107,6 +116,11 @@ func (dao *UserDao) GetUsers() ([]models.User, error) {
 			&user.LastUpdated,
 			&user.CreatedAt,
 			&user.UpdatedAt,
+			&user.Timezone,
+			&user.WeekStart,
+			&user.ShowInLeaderboards,
+			&user.ShowInGroupFeeds,
+			&user.ShowInChallengeFeeds,
 		)
 		if err != nil {
 			dao.l.Println("Error parsing query result", err)
@@ -137,7 +151,12 @@ func (dao *UserDao) GetUserByID(id int64) (*models.User, error) {
 			last_distance,
 			last_updated,
 			created_at,
-			updated_at
+			updated_at,
+			timezone,
+			week_start,
+			show_in_leaderboards,
+			show_in_group_feeds,
+			show_in_challenge_feeds
 		FROM users
 		WHERE
 			id = $1;
@@ -155,6 +174,11 @@ func (dao *UserDao) GetUserByID(id int64) (*models.User, error) {
 		&user.LastUpdated,
 		&user.CreatedAt,
 		&user.UpdatedAt,
+		&user.Timezone,
+		&user.WeekStart,
+		&user.ShowInLeaderboards,
+		&user.ShowInGroupFeeds,
+		&user.ShowInChallengeFeeds,
 	)
 	if errors.Is(err, sql.ErrNoRows) {
 		dao.l.Printf("No user found with id=%d", id)
@@ -181,7 +205,12 @@ func (dao *UserDao) GetUserByStravaAthleteID(id int64) (*models.User, error) {
 			last_distance,
 			last_updated,
 			created_at,
-			updated_at
+			updated_at,
+			timezone,
+			week_start,
+			show_in_leaderboards,
+			show_in_group_feeds,
+			show_in_challenge_feeds
 		FROM users
 		WHERE
 			strava_athlete_id = $1;
@@ -199,6 +228,11 @@ func (dao *UserDao) GetUserByStravaAthleteID(id int64) (*models.User, error) {
 		&user.LastUpdated,
 		&user.CreatedAt,
 		&user.UpdatedAt,
+		&user.Timezone,
+		&user.WeekStart,
+		&user.ShowInLeaderboards,
+		&user.ShowInGroupFeeds,
+		&user.ShowInChallengeFeeds,
 	)
 	if errors.Is(err, sql.ErrNoRows) {
 		dao.l.Printf("No user found with strava_athlete_id=%d", id)
@@ -234,15 +268,12 @@ func (dao *UserDao) UpdateUsername(userID int64, username string) error {
 	return nil
 }
 
-func (dao *UserDao) DeleteUserByStravaAthleteID(stravaAthleteID int64) error {
-	// Due to CASCADE DELETE constraints, this will automatically delete:
-	// - activities (via strava_athlete_id FK)
-	// - user_peaks (via user_id FK)
-	// - group_members (via user_id FK)
-	query := `DELETE FROM users WHERE strava_athlete_id = $1`
-	result, err := dao.db.Exec(query, stravaAthleteID)
+// UpdatePreferences updates the timezone and week start used for streaks
+func (dao *UserDao) UpdatePreferences(userID int64, timezone string, weekStart int) error {
+	query := `UPDATE users SET timezone = $1, week_start = $2, updated_at = NOW() WHERE id = $3`
+	result, err := dao.db.Exec(query, timezone, weekStart, userID)
 	if err != nil {
-		dao.l.Printf("Error deleting user with strava_athlete_id=%d: %v", stravaAthleteID, err)
+		dao.l.Printf("Error updating preferences for user_id=%d: %v", userID, err)
 		return err
 	}
 
@@ -253,10 +284,283 @@ func (dao *UserDao) DeleteUserByStravaAthleteID(stravaAthleteID int64) error {
 	}
 
 	if rowsAffected == 0 {
-		dao.l.Printf("No user found with strava_athlete_id=%d", stravaAthleteID)
+		dao.l.Printf("No user found with id=%d", userID)
 		return ErrUserNotFound
 	}
 
-	dao.l.Printf("Successfully deleted user with strava_athlete_id=%d", stravaAthleteID)
 	return nil
 }
+
+// UpdateShowInLeaderboards sets whether the user's name appears in community stats
+func (dao *UserDao) UpdateShowInLeaderboards(userID int64, show bool) error {
+	query := `UPDATE users SET show_in_leaderboards = $1, updated_at = NOW() WHERE id = $2`
+	result, err := dao.db.Exec(query, show, userID)
+	if err != nil {
+		dao.l.Printf("Error updating show_in_leaderboards for user_id=%d: %v", userID, err)
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		dao.l.Printf("Error getting rows affected: %v", err)
+		return err
+	}
+
+	if rowsAffected == 0 {
+		dao.l.Printf("No user found with id=%d", userID)
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// UpdateFeedVisibility sets whether the user's activities appear to others in
+// group and challenge feeds
+func (dao *UserDao) UpdateFeedVisibility(userID int64, showInGroupFeeds bool, showInChallengeFeeds bool) error {
+	query := `
+		UPDATE users
+		SET show_in_group_feeds = $1, show_in_challenge_feeds = $2, updated_at = NOW()
+		WHERE id = $3
+	`
+	result, err := dao.db.Exec(query, showInGroupFeeds, showInChallengeFeeds, userID)
+	if err != nil {
+		dao.l.Printf("Error updating feed visibility for user_id=%d: %v", userID, err)
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		dao.l.Printf("Error getting rows affected: %v", err)
+		return err
+	}
+
+	if rowsAffected == 0 {
+		dao.l.Printf("No user found with id=%d", userID)
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// GetNotificationPreferences returns the notification types the user has
+// turned on or off
+func (dao *UserDao) GetNotificationPreferences(userID int64) (models.NotificationPreferences, error) {
+	var raw []byte
+	err := dao.db.QueryRow(`SELECT notification_preferences FROM users WHERE id = $1`, userID).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		dao.l.Printf("Error getting notification preferences for user_id=%d: %v", userID, err)
+		return nil, err
+	}
+
+	preferences := models.NotificationPreferences{}
+	if err := json.Unmarshal(raw, &preferences); err != nil {
+		dao.l.Printf("Error parsing notification preferences for user_id=%d: %v", userID, err)
+		return nil, err
+	}
+	return preferences, nil
+}
+
+func (dao *UserDao) UpdateNotificationPreferences(userID int64, preferences models.NotificationPreferences) error {
+	raw, err := json.Marshal(preferences)
+	if err != nil {
+		return err
+	}
+	result, err := dao.db.Exec(`UPDATE users SET notification_preferences = $1, updated_at = NOW() WHERE id = $2`, raw, userID)
+	if err != nil {
+		dao.l.Printf("Error updating notification preferences for user_id=%d: %v", userID, err)
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		dao.l.Printf("Error getting rows affected: %v", err)
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// ==================== Email ====================
+
+const emailSettingsSelect = `
+	SELECT
+		id, COALESCE(username, ''), COALESCE(email, ''), email_verified_at, email_preferences,
+		COALESCE(email_unsubscribe_token, ''), COALESCE(timezone, 'UTC'), COALESCE(week_start, 1)
+	FROM users
+`
+
+// GetEmailSettings returns the user's address and email preferences. Email
+// is empty if they haven't given one.
+func (dao *UserDao) GetEmailSettings(userID int64) (*models.EmailSettings, error) {
+	settings, err := dao.queryEmailSettings(emailSettingsSelect+`WHERE id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(settings) == 0 {
+		return nil, ErrUserNotFound
+	}
+	return &settings[0], nil
+}
+
+// GetEmailSettingsByUnsubscribeToken returns nil if no user has the token
+func (dao *UserDao) GetEmailSettingsByUnsubscribeToken(token string) (*models.EmailSettings, error) {
+	settings, err := dao.queryEmailSettings(emailSettingsSelect+`WHERE email_unsubscribe_token = $1`, token)
+	if err != nil || len(settings) == 0 {
+		return nil, err
+	}
+	return &settings[0], nil
+}
+
+// GetEmailRecipients returns every user with a verified address who has
+// opted in to the kind of email
+func (dao *UserDao) GetEmailRecipients(kind models.EmailKind) ([]models.EmailSettings, error) {
+	query := emailSettingsSelect + `
+		WHERE email IS NOT NULL AND email_verified_at IS NOT NULL AND deleted_at IS NULL
+		AND COALESCE((email_preferences->>$1)::BOOLEAN, FALSE)
+	`
+	return dao.queryEmailSettings(query, string(kind))
+}
+
+// SetEmail changes the user's address. It needs verifying again before
+// anything else is sent to it. The unsubscribe token is only set the first
+// time, so links in earlier emails keep working.
+func (dao *UserDao) SetEmail(userID int64, email string, verificationToken string, unsubscribeToken string) error {
+	query := `
+		UPDATE users
+		SET email = $2, email_verified_at = NULL, email_verification_token = $3,
+			email_unsubscribe_token = COALESCE(email_unsubscribe_token, $4), updated_at = NOW()
+		WHERE id = $1
+	`
+	result, err := dao.db.Exec(query, userID, email, verificationToken, unsubscribeToken)
+	if err != nil {
+		dao.l.Printf("Error setting email for user_id=%d: %v", userID, err)
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		dao.l.Printf("Error getting rows affected: %v", err)
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// VerifyEmail marks the address with the verification token as verified.
+// It returns false if the token doesn't match any user.
+func (dao *UserDao) VerifyEmail(token string) (bool, error) {
+	query := `
+		UPDATE users
+		SET email_verified_at = NOW(), email_verification_token = NULL, updated_at = NOW()
+		WHERE email_verification_token = $1
+	`
+	result, err := dao.db.Exec(query, token)
+	if err != nil {
+		dao.l.Printf("Error verifying email: %v", err)
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// ClearEmail forgets the user's address
+func (dao *UserDao) ClearEmail(userID int64) error {
+	query := `
+		UPDATE users
+		SET email = NULL, email_verified_at = NULL, email_verification_token = NULL, updated_at = NOW()
+		WHERE id = $1
+	`
+	_, err := dao.db.Exec(query, userID)
+	if err != nil {
+		dao.l.Printf("Error clearing email for user_id=%d: %v", userID, err)
+		return err
+	}
+	return nil
+}
+
+func (dao *UserDao) UpdateEmailPreferences(userID int64, preferences models.EmailPreferences) error {
+	raw, err := json.Marshal(preferences)
+	if err != nil {
+		return err
+	}
+	_, err = dao.db.Exec(`UPDATE users SET email_preferences = $1, updated_at = NOW() WHERE id = $2`, raw, userID)
+	if err != nil {
+		dao.l.Printf("Error updating email preferences for user_id=%d: %v", userID, err)
+		return err
+	}
+	return nil
+}
+
+func (dao *UserDao) queryEmailSettings(query string, args ...interface{}) ([]models.EmailSettings, error) {
+	rows, err := dao.db.Query(query, args...)
+	if err != nil {
+		dao.l.Printf("Error getting email settings: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	settings := []models.EmailSettings{}
+	for rows.Next() {
+		s := models.EmailSettings{Preferences: models.EmailPreferences{}}
+		var verifiedAt sql.NullTime
+		var preferences []byte
+		err := rows.Scan(&s.UserID, &s.Name, &s.Email, &verifiedAt, &preferences, &s.UnsubscribeToken, &s.Timezone, &s.WeekStart)
+		if err != nil {
+			dao.l.Printf("Error scanning email settings: %v", err)
+			return nil, err
+		}
+		if verifiedAt.Valid {
+			s.Verified = true
+			s.VerifiedAt = &verifiedAt.Time
+		}
+		if err := json.Unmarshal(preferences, &s.Preferences); err != nil {
+			dao.l.Printf("Error parsing email preferences for user_id=%d: %v", s.UserID, err)
+			return nil, err
+		}
+		settings = append(settings, s)
+	}
+	return settings, rows.Err()
+}
+
+// ClearStravaTokens forgets the user's Strava tokens once access has been
+// revoked
+func (dao *UserDao) ClearStravaTokens(userID int64) error {
+	query := `UPDATE users SET access_token = '', refresh_token = '', updated_at = NOW() WHERE id = $1`
+	_, err := dao.db.Exec(query, userID)
+	if err != nil {
+		dao.l.Printf("Error clearing Strava tokens for user %d: %v", userID, err)
+		return err
+	}
+	return nil
+}
+
+// GetUserIDsShowingInGroupFeeds returns which of the users allow their
+// activities to appear in group feeds
+func (dao *UserDao) GetUserIDsShowingInGroupFeeds(userIDs []int64) ([]int64, error) {
+	rows, err := dao.db.Query(`SELECT id FROM users WHERE id = ANY($1) AND show_in_group_feeds`, pq.Int64Array(userIDs))
+	if err != nil {
+		dao.l.Printf("Error querying group feed visibility: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			dao.l.Printf("Error scanning user id: %v", err)
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"log"
 	"run-goals/models"
+	"sort"
 	"time"
 
 	"github.com/lib/pq"
@@ -17,6 +18,11 @@ type UserPeaksDaoInterface interface {
 	ClearUserPeaks() error
 	GetUserSummitsInDateRange(userID int64, peakIDs []int64, startDate time.Time, endDate time.Time) ([]models.UserPeak, error)
 	GetUserSummitsInDateRangeAll(userID int64, startDate time.Time, endDate time.Time) ([]models.UserPeak, error)
+	GetUserSummitsWithPeaksInDateRange(userID int64, startDate time.Time, endDate time.Time) ([]models.SummitWithPeak, error)
+	GetPeakCommunityStats(peakID int64, topLimit int) (*models.PeakCommunityStats, error)
+	GetUserPeakHistory(userID int64, peakID int64) ([]models.PeakMySummit, error)
+	GetFastestAscents(peakID int64, limit int) ([]models.FastestTimeEntry, error)
+	GetFastestTraverses(fromPeakID int64, toPeakID int64, limit int) ([]models.FastestTimeEntry, error)
 }
 
 type UserPeaksDao struct {
@@ -163,11 +169,22 @@ func (dao *UserPeaksDao) UpsertUserPeak(userPeak *models.UserPeak) error {
             user_id,
             peak_id,
             activity_id,
-            summited_at
+            summited_at,
+            summit_time,
+            elapsed_seconds,
+            split_seconds,
+            previous_peak_id,
+            timing_source
         ) VALUES (
-            $1, $2, $3, $4
+            $1, $2, $3, $4, $5, $6, $7, $8, $9
         ) ON CONFLICT (user_id, peak_id, activity_id) 
-        DO UPDATE SET summited_at = EXCLUDED.summited_at;
+        DO UPDATE SET
+            summited_at = EXCLUDED.summited_at,
+            summit_time = EXCLUDED.summit_time,
+            elapsed_seconds = EXCLUDED.elapsed_seconds,
+            split_seconds = EXCLUDED.split_seconds,
+            previous_peak_id = EXCLUDED.previous_peak_id,
+            timing_source = EXCLUDED.timing_source;
     `
 	_, err := dao.db.Exec(
 		sql,
@@ -175,6 +192,11 @@ func (dao *UserPeaksDao) UpsertUserPeak(userPeak *models.UserPeak) error {
 		userPeak.PeakID,
 		userPeak.ActivityID,
 		userPeak.SummitedAt,
+		userPeak.SummitTime,
+		userPeak.ElapsedSeconds,
+		userPeak.SplitSeconds,
+		userPeak.PreviousPeakID,
+		userPeak.TimingSource,
 	)
 	if err != nil {
 		dao.l.Printf("Error upserting userPeak: %v", err)
@@ -183,9 +205,11 @@ func (dao *UserPeaksDao) UpsertUserPeak(userPeak *models.UserPeak) error {
 	return nil
 }
 
+// ClearUserPeaks removes every detected summit ahead of a recalculation.
+// Approved claims are kept since detection won't find them again.
 func (dao *UserPeaksDao) ClearUserPeaks() error {
 	sql := `
-		DELETE FROM user_peaks;
+		DELETE FROM user_peaks WHERE source = 'detected';
 	`
 	_, err := dao.db.Exec(sql)
 	if err != nil {
@@ -209,7 +233,12 @@ func (dao *UserPeaksDao) GetUserSummitsInDateRange(userID int64, peakIDs []int64
             user_id,
             peak_id,
             activity_id,
-            summited_at
+            summited_at,
+            summit_time,
+            elapsed_seconds,
+            split_seconds,
+            previous_peak_id,
+            timing_source
         FROM user_peaks
         WHERE 
             user_id = $1
@@ -234,6 +263,11 @@ func (dao *UserPeaksDao) GetUserSummitsInDateRange(userID int64, peakIDs []int64
 			&userPeak.PeakID,
 			&userPeak.ActivityID,
 			&userPeak.SummitedAt,
+			&userPeak.SummitTime,
+			&userPeak.ElapsedSeconds,
+			&userPeak.SplitSeconds,
+			&userPeak.PreviousPeakID,
+			&userPeak.TimingSource,
 		)
 		if err != nil {
 			dao.l.Printf("Error parsing user summit result: %v", err)
@@ -300,3 +334,510 @@ func (dao *UserPeaksDao) GetUserSummitsInDateRangeAll(userID int64, startDate ti
 
 	return userPeaks, nil
 }
+
+// GetUserSummitsWithPeaksInDateRange returns a user's summits in a date range
+// along with each peak's elevation and region
+func (dao *UserPeaksDao) GetUserSummitsWithPeaksInDateRange(userID int64, startDate time.Time, endDate time.Time) ([]models.SummitWithPeak, error) {
+	summits := []models.SummitWithPeak{}
+
+	sql := `
+        SELECT
+            up.id, up.user_id, up.peak_id, up.activity_id, up.summited_at,
+            COALESCE(p.elevation_meters, 0), COALESCE(p.region, '')
+        FROM user_peaks up
+        JOIN peaks p ON up.peak_id = p.id
+        WHERE
+            up.user_id = $1
+            AND up.summited_at >= $2
+            AND up.summited_at <= $3
+        ORDER BY up.summited_at
+    `
+
+	rows, err := dao.db.Query(sql, userID, startDate, endDate)
+	if err != nil {
+		dao.l.Printf("Error querying user summits with peaks: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		summit := models.SummitWithPeak{}
+		err = rows.Scan(
+			&summit.ID,
+			&summit.UserID,
+			&summit.PeakID,
+			&summit.ActivityID,
+			&summit.SummitedAt,
+			&summit.Elevation,
+			&summit.Region,
+		)
+		if err != nil {
+			dao.l.Printf("Error parsing user summit with peak result: %v", err)
+			return nil, err
+		}
+		summits = append(summits, summit)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		dao.l.Printf("Error during user summits with peaks iteration: %v", err)
+		return nil, err
+	}
+
+	return summits, nil
+}
+
+// GetPeakCommunityStats aggregates every summit of a peak: totals, the first
+// and latest summit, summits per calendar month, and the users who have
+// summited it most. Users who opted out of community stats are counted but
+// never named.
+func (dao *UserPeaksDao) GetPeakCommunityStats(peakID int64, topLimit int) (*models.PeakCommunityStats, error) {
+	stats := &models.PeakCommunityStats{
+		TopSummiteers: []models.PeakSummiteer{},
+		ComputedAt:    time.Now(),
+	}
+
+	sql := `
+        SELECT COUNT(*), COUNT(DISTINCT user_id)
+        FROM user_peaks
+        WHERE peak_id = $1
+    `
+	err := dao.db.QueryRow(sql, peakID).Scan(&stats.TotalSummits, &stats.UniqueSummiteers)
+	if err != nil {
+		dao.l.Printf("Error counting peak summits: %v", err)
+		return nil, err
+	}
+	if stats.TotalSummits == 0 {
+		return stats, nil
+	}
+
+	sql = `
+        SELECT EXTRACT(MONTH FROM summited_at)::INT, COUNT(*)
+        FROM user_peaks
+        WHERE peak_id = $1
+        GROUP BY 1
+    `
+	rows, err := dao.db.Query(sql, peakID)
+	if err != nil {
+		dao.l.Printf("Error querying peak summits by month: %v", err)
+		return nil, err
+	}
+	for rows.Next() {
+		var month, count int
+		if err := rows.Scan(&month, &count); err != nil {
+			rows.Close()
+			dao.l.Printf("Error parsing peak summits by month: %v", err)
+			return nil, err
+		}
+		if month >= 1 && month <= 12 {
+			stats.SummitsByMonth[month-1] = count
+		}
+	}
+	rows.Close()
+
+	for _, order := range []string{"ASC", "DESC"} {
+		sql = `
+            SELECT COALESCE(up.summit_time, up.summited_at), up.user_id, COALESCE(u.username, ''), u.show_in_leaderboards
+            FROM user_peaks up
+            JOIN users u ON u.id = up.user_id
+            WHERE up.peak_id = $1
+            ORDER BY COALESCE(up.summit_time, up.summited_at) ` + order + `, up.id ` + order + `
+            LIMIT 1
+        `
+		var event models.PeakSummitEvent
+		var userID int64
+		var userName string
+		var visible bool
+		err := dao.db.QueryRow(sql, peakID).Scan(&event.SummitedAt, &userID, &userName, &visible)
+		if err != nil {
+			dao.l.Printf("Error querying first/latest peak summit: %v", err)
+			return nil, err
+		}
+		if visible {
+			event.UserID = &userID
+			event.UserName = &userName
+		}
+		if order == "ASC" {
+			stats.FirstSummit = &event
+		} else {
+			stats.LatestSummit = &event
+		}
+	}
+
+	sql = `
+        SELECT
+            up.user_id,
+            COALESCE(u.username, ''),
+            u.strava_athlete_id,
+            COUNT(*) AS summit_count,
+            MAX(up.summited_at) AS last_summited_at
+        FROM user_peaks up
+        JOIN users u ON u.id = up.user_id
+        WHERE up.peak_id = $1
+            AND u.show_in_leaderboards
+        GROUP BY up.user_id, u.username, u.strava_athlete_id
+        ORDER BY summit_count DESC, last_summited_at DESC
+        LIMIT $2
+    `
+	rows, err = dao.db.Query(sql, peakID, topLimit)
+	if err != nil {
+		dao.l.Printf("Error querying top summiteers: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var summiteer models.PeakSummiteer
+		err := rows.Scan(
+			&summiteer.UserID,
+			&summiteer.UserName,
+			&summiteer.StravaAthleteID,
+			&summiteer.SummitCount,
+			&summiteer.LastSummitedAt,
+		)
+		if err != nil {
+			dao.l.Printf("Error parsing top summiteer: %v", err)
+			return nil, err
+		}
+		stats.TopSummiteers = append(stats.TopSummiteers, summiteer)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		dao.l.Printf("Error during top summiteers iteration: %v", err)
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// GetUserPeakHistory returns every time the user has summited a peak, newest first
+func (dao *UserPeaksDao) GetUserPeakHistory(userID int64, peakID int64) ([]models.PeakMySummit, error) {
+	summits := []models.PeakMySummit{}
+
+	sql := `
+        SELECT
+            up.activity_id,
+            COALESCE(a.name, ''),
+            COALESCE(up.summit_time, up.summited_at),
+            up.elapsed_seconds
+        FROM user_peaks up
+        LEFT JOIN activity a ON a.id = up.activity_id
+        WHERE up.user_id = $1 AND up.peak_id = $2
+        ORDER BY COALESCE(up.summit_time, up.summited_at) DESC
+    `
+	rows, err := dao.db.Query(sql, userID, peakID)
+	if err != nil {
+		dao.l.Printf("Error querying user peak history: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		summit := models.PeakMySummit{}
+		err = rows.Scan(
+			&summit.ActivityID,
+			&summit.ActivityName,
+			&summit.SummitedAt,
+			&summit.ElapsedSeconds,
+		)
+		if err != nil {
+			dao.l.Printf("Error parsing user peak history: %v", err)
+			return nil, err
+		}
+		summits = append(summits, summit)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		dao.l.Printf("Error during user peak history iteration: %v", err)
+		return nil, err
+	}
+
+	return summits, nil
+}
+
+// GetFastestAscents returns each user's quickest time from activity start to
+// the summit. Only stream timings on activities anyone may see are ranked,
+// estimates aren't accurate enough to compare. Users hidden from leaderboards
+// keep their place but not their name, unless they're the viewer.
+func (dao *UserPeaksDao) GetFastestAscents(peakID int64, viewerID int64, limit int) ([]models.FastestTimeEntry, error) {
+	sql := `
+        SELECT DISTINCT ON (up.user_id)
+            up.user_id,
+            COALESCE(u.username, '') AS user_name,
+            u.strava_athlete_id,
+            up.activity_id,
+            COALESCE(a.name, '') AS activity_name,
+            up.elapsed_seconds,
+            COALESCE(up.summit_time, up.summited_at) AS achieved_at,
+            COALESCE(up.timing_source, ''),
+            (u.show_in_leaderboards OR up.user_id = $2) AS visible
+        FROM user_peaks up
+        JOIN users u ON up.user_id = u.id
+        JOIN activity a ON up.activity_id = a.id
+        WHERE
+            up.peak_id = $1
+            AND up.elapsed_seconds IS NOT NULL
+            AND up.timing_source = 'stream'
+            AND NOT a.private AND a.visibility <> 'only_me'
+        ORDER BY up.user_id, up.elapsed_seconds ASC, up.summited_at ASC
+    `
+	return dao.queryFastestTimes(sql, limit, peakID, viewerID)
+}
+
+// GetFastestTraverses returns each user's quickest time between two summits in
+// the same activity. As with ascents, both summits need stream timings, the
+// activity must be visible to others and hidden users aren't named.
+func (dao *UserPeaksDao) GetFastestTraverses(fromPeakID int64, toPeakID int64, viewerID int64, limit int) ([]models.FastestTimeEntry, error) {
+	sql := `
+        SELECT DISTINCT ON (t.user_id)
+            t.user_id,
+            COALESCE(u.username, '') AS user_name,
+            u.strava_athlete_id,
+            t.activity_id,
+            COALESCE(a.name, '') AS activity_name,
+            t.elapsed_seconds,
+            t.achieved_at,
+            t.timing_source,
+            (u.show_in_leaderboards OR t.user_id = $3) AS visible
+        FROM (
+            SELECT
+                dest.user_id,
+                dest.activity_id,
+                dest.elapsed_seconds - origin.elapsed_seconds AS elapsed_seconds,
+                COALESCE(dest.summit_time, dest.summited_at) AS achieved_at,
+                COALESCE(dest.timing_source, '') AS timing_source,
+                dest.summited_at
+            FROM user_peaks origin
+            JOIN user_peaks dest
+                ON dest.activity_id = origin.activity_id
+                AND dest.user_id = origin.user_id
+                AND dest.peak_id = $2
+            WHERE
+                origin.peak_id = $1
+                AND origin.elapsed_seconds IS NOT NULL
+                AND origin.timing_source = 'stream'
+                AND dest.timing_source = 'stream'
+                AND dest.elapsed_seconds > origin.elapsed_seconds
+        ) t
+        JOIN users u ON t.user_id = u.id
+        JOIN activity a ON t.activity_id = a.id
+        WHERE NOT a.private AND a.visibility <> 'only_me'
+        ORDER BY t.user_id, t.elapsed_seconds ASC, t.summited_at ASC
+    `
+	return dao.queryFastestTimes(sql, limit, fromPeakID, toPeakID, viewerID)
+}
+
+// queryFastestTimes ranks the per-user best times returned by query
+func (dao *UserPeaksDao) queryFastestTimes(query string, limit int, args ...interface{}) ([]models.FastestTimeEntry, error) {
+	entries := []models.FastestTimeEntry{}
+
+	rows, err := dao.db.Query(query, args...)
+	if err != nil {
+		dao.l.Printf("Error querying fastest times: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry := models.FastestTimeEntry{}
+		var visible bool
+		err = rows.Scan(
+			&entry.UserID,
+			&entry.UserName,
+			&entry.StravaAthleteID,
+			&entry.ActivityID,
+			&entry.ActivityName,
+			&entry.ElapsedSeconds,
+			&entry.AchievedAt,
+			&entry.TimingSource,
+			&visible,
+		)
+		if err != nil {
+			dao.l.Printf("Error parsing fastest time result: %v", err)
+			return nil, err
+		}
+		if !visible {
+			entry.UserID = 0
+			entry.UserName = ""
+			entry.StravaAthleteID = 0
+			entry.ActivityID = 0
+			entry.ActivityName = ""
+		}
+		entries = append(entries, entry)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		dao.l.Printf("Error during fastest times iteration: %v", err)
+		return nil, err
+	}
+
+	// DISTINCT ON needs user order, so rank here
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].ElapsedSeconds != entries[j].ElapsedSeconds {
+			return entries[i].ElapsedSeconds < entries[j].ElapsedSeconds
+		}
+		return entries[i].AchievedAt.Before(entries[j].AchievedAt)
+	})
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	for i := range entries {
+		entries[i].Rank = i + 1
+		if i > 0 && entries[i].ElapsedSeconds == entries[i-1].ElapsedSeconds {
+			entries[i].Rank = entries[i-1].Rank
+		}
+	}
+
+	return entries, nil
+}
+
+// GetUserSummitsInBounds returns each peak inside the box the user has
+// summited, with how often and when
+func (dao *UserPeaksDao) GetUserSummitsInBounds(userID int64, bounds models.BoundingBox) ([]models.MapSummit, error) {
+	query := `
+		SELECT
+			p.id,
+			COALESCE(p.name, ''),
+			p.latitude,
+			p.longitude,
+			COALESCE(p.elevation_meters, 0),
+			COUNT(*),
+			MIN(up.summited_at),
+			MAX(up.summited_at)
+		FROM user_peaks up
+		JOIN peaks p ON p.id = up.peak_id
+		WHERE
+			up.user_id = $1
+			AND p.latitude BETWEEN $2 AND $3
+			AND p.longitude BETWEEN $4 AND $5
+		GROUP BY p.id
+		ORDER BY MAX(up.summited_at) DESC
+	`
+	rows, err := dao.db.Query(query, userID, bounds.MinLat, bounds.MaxLat, bounds.MinLon, bounds.MaxLon)
+	if err != nil {
+		dao.l.Printf("Error querying user summits in bounds: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	summits := []models.MapSummit{}
+	for rows.Next() {
+		s := models.MapSummit{}
+		err := rows.Scan(
+			&s.PeakID, &s.Name, &s.Latitude, &s.Longitude, &s.ElevationMeters,
+			&s.SummitCount, &s.FirstSummitedAt, &s.LastSummitedAt,
+		)
+		if err != nil {
+			dao.l.Printf("Error scanning user summit: %v", err)
+			return nil, err
+		}
+		summits = append(summits, s)
+	}
+	return summits, rows.Err()
+}
+
+// GetActivitySummits returns the peaks summited during an activity, in the
+// order they were reached
+func (dao *UserPeaksDao) GetActivitySummits(activityID int64) ([]models.ActivitySummit, error) {
+	query := `
+		SELECT
+			p.id,
+			COALESCE(p.name, ''),
+			p.latitude,
+			p.longitude,
+			COALESCE(p.elevation_meters, 0),
+			COALESCE(p.region, ''),
+			up.summited_at,
+			up.summit_time,
+			up.elapsed_seconds
+		FROM user_peaks up
+		JOIN peaks p ON p.id = up.peak_id
+		WHERE up.activity_id = $1
+		ORDER BY up.summit_time NULLS LAST, up.summited_at, p.id
+	`
+	rows, err := dao.db.Query(query, activityID)
+	if err != nil {
+		dao.l.Printf("Error querying activity summits: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	summits := []models.ActivitySummit{}
+	for rows.Next() {
+		s := models.ActivitySummit{}
+		var summitTime sql.NullTime
+		var elapsed sql.NullInt64
+		err := rows.Scan(
+			&s.PeakID, &s.Name, &s.Latitude, &s.Longitude, &s.ElevationMeters, &s.Region,
+			&s.SummitedAt, &summitTime, &elapsed,
+		)
+		if err != nil {
+			dao.l.Printf("Error scanning activity summit: %v", err)
+			return nil, err
+		}
+		if summitTime.Valid {
+			s.SummitTime = &summitTime.Time
+		}
+		if elapsed.Valid {
+			v := int(elapsed.Int64)
+			s.ElapsedSeconds = &v
+		}
+		summits = append(summits, s)
+	}
+	return summits, rows.Err()
+}
+
+// GetUserSummits returns every summit of the user with its peak, oldest first
+func (dao *UserPeaksDao) GetUserSummits(userID int64) ([]models.UserSummit, error) {
+	query := `
+		SELECT
+			up.activity_id,
+			up.source,
+			p.id,
+			COALESCE(p.name, ''),
+			p.latitude,
+			p.longitude,
+			COALESCE(p.elevation_meters, 0),
+			COALESCE(p.region, ''),
+			up.summited_at,
+			up.summit_time,
+			up.elapsed_seconds
+		FROM user_peaks up
+		JOIN peaks p ON p.id = up.peak_id
+		WHERE up.user_id = $1
+		ORDER BY COALESCE(up.summit_time, up.summited_at), up.id
+	`
+	rows, err := dao.db.Query(query, userID)
+	if err != nil {
+		dao.l.Printf("Error querying user summits: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	summits := []models.UserSummit{}
+	for rows.Next() {
+		s := models.UserSummit{}
+		var summitTime sql.NullTime
+		var elapsed sql.NullInt64
+		err := rows.Scan(
+			&s.ActivityID, &s.Source,
+			&s.PeakID, &s.Name, &s.Latitude, &s.Longitude, &s.ElevationMeters, &s.Region,
+			&s.SummitedAt, &summitTime, &elapsed,
+		)
+		if err != nil {
+			dao.l.Printf("Error scanning user summit: %v", err)
+			return nil, err
+		}
+		if summitTime.Valid {
+			s.SummitTime = &summitTime.Time
+		}
+		if elapsed.Valid {
+			v := int(elapsed.Int64)
+			s.ElapsedSeconds = &v
+		}
+		summits = append(summits, s)
+	}
+	return summits, rows.Err()
+}
@@ -0,0 +1,180 @@
+// Package dem reads elevations from local digital elevation model tiles:
+// SRTM-style .hgt files and single-band GeoTIFFs such as Copernicus DEM.
+package dem
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// maxCachedTiles is how many decoded tiles are kept in memory. A 1 arc-second
+// tile is about 50 MB once decoded.
+const maxCachedTiles = 4
+
+var ErrUnsupportedTile = errors.New("unsupported DEM tile")
+
+// raster is a decoded grid of samples. Row 0 is the northernmost row and
+// originLat/originLon is the centre of the first sample.
+type raster struct {
+	width, height int
+	originLat     float64
+	originLon     float64
+	dLat, dLon    float64
+	data          []float32
+	nodata        float32
+	hasNodata     bool
+}
+
+type tileInfo struct {
+	path                           string
+	minLat, maxLat, minLon, maxLon float64
+	load                           func(path string) (*raster, error)
+}
+
+// Provider looks up elevations from the tiles in a directory. It's safe for
+// concurrent use.
+type Provider struct {
+	tiles []tileInfo
+
+	mu    sync.Mutex
+	cache map[string]*raster
+	order []string // least recently used first
+}
+
+// NewProvider indexes every .hgt, .tif and .tiff file in dir. Files it can't
+// read are skipped and reported in the returned error alongside the provider.
+func NewProvider(dir string) (*Provider, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Provider{cache: map[string]*raster{}}
+	var skipped []error
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		var tile *tileInfo
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".hgt":
+			tile, err = indexHGT(path)
+		case ".tif", ".tiff":
+			tile, err = indexGeoTIFF(path)
+		default:
+			continue
+		}
+		if err != nil {
+			skipped = append(skipped, fmt.Errorf("%s: %w", entry.Name(), err))
+			continue
+		}
+		p.tiles = append(p.tiles, *tile)
+	}
+	return p, errors.Join(skipped...)
+}
+
+// TileCount is the number of tiles the provider found
+func (p *Provider) TileCount() int {
+	return len(p.tiles)
+}
+
+// Elevation returns the bilinearly interpolated elevation in meters at a
+// point, or false when no tile covers it or the samples around it are voids.
+func (p *Provider) Elevation(lat, lon float64) (float64, bool) {
+	for _, tile := range p.tiles {
+		if lat < tile.minLat || lat > tile.maxLat || lon < tile.minLon || lon > tile.maxLon {
+			continue
+		}
+		r, err := p.raster(tile)
+		if err != nil {
+			continue
+		}
+		if elevation, ok := r.elevation(lat, lon); ok {
+			return elevation, true
+		}
+	}
+	return 0, false
+}
+
+func (p *Provider) raster(tile tileInfo) (*raster, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if r, ok := p.cache[tile.path]; ok {
+		p.touch(tile.path)
+		return r, nil
+	}
+
+	r, err := tile.load(tile.path)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.order) >= maxCachedTiles {
+		delete(p.cache, p.order[0])
+		p.order = p.order[1:]
+	}
+	p.cache[tile.path] = r
+	p.order = append(p.order, tile.path)
+	return r, nil
+}
+
+func (p *Provider) touch(path string) {
+	for i, cached := range p.order {
+		if cached == path {
+			p.order = append(append(p.order[:i:i], p.order[i+1:]...), path)
+			return
+		}
+	}
+}
+
+func (r *raster) sample(row, col int) (float64, bool) {
+	v := r.data[row*r.width+col]
+	if math.IsNaN(float64(v)) || (r.hasNodata && v == r.nodata) {
+		return 0, false
+	}
+	return float64(v), true
+}
+
+func (r *raster) elevation(lat, lon float64) (float64, bool) {
+	fr := (r.originLat - lat) / r.dLat
+	fc := (lon - r.originLon) / r.dLon
+	if fr < -0.5 || fc < -0.5 || fr > float64(r.height)-0.5 || fc > float64(r.width)-0.5 {
+		return 0, false
+	}
+	// Points within half a sample of the edge snap to the edge
+	fr = math.Max(0, math.Min(fr, float64(r.height-1)))
+	fc = math.Max(0, math.Min(fc, float64(r.width-1)))
+
+	r0, c0 := int(fr), int(fc)
+	r1, c1 := min(r0+1, r.height-1), min(c0+1, r.width-1)
+	ty, tx := fr-float64(r0), fc-float64(c0)
+
+	corners := [4]struct {
+		row, col int
+		weight   float64
+	}{
+		{r0, c0, (1 - ty) * (1 - tx)},
+		{r0, c1, (1 - ty) * tx},
+		{r1, c0, ty * (1 - tx)},
+		{r1, c1, ty * tx},
+	}
+
+	// Voids are left out and the remaining weights renormalised
+	sum, weights := 0.0, 0.0
+	for _, corner := range corners {
+		if v, ok := r.sample(corner.row, corner.col); ok && corner.weight > 0 {
+			sum += v * corner.weight
+			weights += corner.weight
+		}
+	}
+	if weights == 0 {
+		return 0, false
+	}
+	return sum / weights, true
+}
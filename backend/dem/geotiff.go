@@ -0,0 +1,419 @@
+package dem
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// TIFF tags used by single-band elevation GeoTIFFs
+const (
+	tagImageWidth      = 256
+	tagImageLength     = 257
+	tagBitsPerSample   = 258
+	tagCompression     = 259
+	tagStripOffsets    = 273
+	tagSamplesPerPixel = 277
+	tagRowsPerStrip    = 278
+	tagStripByteCounts = 279
+	tagPredictor       = 317
+	tagTileWidth       = 322
+	tagTileLength      = 323
+	tagTileOffsets     = 324
+	tagTileByteCounts  = 325
+	tagSampleFormat    = 339
+	tagModelPixelScale = 33550
+	tagModelTiepoint   = 33922
+	tagGeoKeyDirectory = 34735
+	tagGDALNoData      = 42113
+)
+
+const (
+	compressionNone        = 1
+	compressionDeflate     = 8
+	compressionDeflateOld  = 32946
+	predictorNone          = 1
+	predictorHorizontal    = 2
+	predictorFloatingPoint = 3
+	sampleFormatUint       = 1
+	sampleFormatInt        = 2
+	sampleFormatFloat      = 3
+	geoKeyRasterType       = 1025
+	rasterPixelIsPoint     = 2
+)
+
+// geoTIFF is the first image of a GeoTIFF, as far as reading elevations needs
+type geoTIFF struct {
+	order                binary.ByteOrder
+	width, height        int
+	bitsPerSample        int
+	sampleFormat         int
+	compression          int
+	predictor            int
+	blockWidth           int // Image width for strips
+	blockHeight          int // Rows per strip for strips
+	offsets, byteCounts  []uint64
+	originLat, originLon float64
+	dLat, dLon           float64
+	nodata               float32
+	hasNodata            bool
+}
+
+func indexGeoTIFF(path string) (*tileInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	t, err := readGeoTIFF(f)
+	if err != nil {
+		return nil, err
+	}
+	return &tileInfo{
+		path:   path,
+		minLat: t.originLat - (float64(t.height)-0.5)*t.dLat,
+		maxLat: t.originLat + 0.5*t.dLat,
+		minLon: t.originLon - 0.5*t.dLon,
+		maxLon: t.originLon + (float64(t.width)-0.5)*t.dLon,
+		load:   loadGeoTIFF,
+	}, nil
+}
+
+func loadGeoTIFF(path string) (*raster, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	t, err := readGeoTIFF(f)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]float32, t.width*t.height)
+	blocksAcross := (t.width + t.blockWidth - 1) / t.blockWidth
+	for i := range t.offsets {
+		block, err := t.readBlock(f, i)
+		if err != nil {
+			return nil, err
+		}
+		top := (i / blocksAcross) * t.blockHeight
+		left := (i % blocksAcross) * t.blockWidth
+		for y := 0; y < t.blockHeight && top+y < t.height; y++ {
+			for x := 0; x < t.blockWidth && left+x < t.width; x++ {
+				data[(top+y)*t.width+left+x] = block[y*t.blockWidth+x]
+			}
+		}
+	}
+
+	return &raster{
+		width:     t.width,
+		height:    t.height,
+		originLat: t.originLat,
+		originLon: t.originLon,
+		dLat:      t.dLat,
+		dLon:      t.dLon,
+		data:      data,
+		nodata:    t.nodata,
+		hasNodata: t.hasNodata,
+	}, nil
+}
+
+// readGeoTIFF parses the first IFD of a classic (not Big) TIFF
+func readGeoTIFF(r io.ReadSeeker) (*geoTIFF, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	t := &geoTIFF{}
+	switch string(header[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("%w: not a TIFF", ErrUnsupportedTile)
+	}
+	if t.order.Uint16(header[2:]) != 42 {
+		return nil, fmt.Errorf("%w: only classic TIFF is supported", ErrUnsupportedTile)
+	}
+
+	tags, err := readIFD(r, t.order, int64(t.order.Uint32(header[4:])))
+	if err != nil {
+		return nil, err
+	}
+	number := func(tag int, fallback float64) float64 {
+		if values := tags[tag]; len(values.numbers) > 0 {
+			return values.numbers[0]
+		}
+		return fallback
+	}
+
+	t.width = int(number(tagImageWidth, 0))
+	t.height = int(number(tagImageLength, 0))
+	t.bitsPerSample = int(number(tagBitsPerSample, 0))
+	t.sampleFormat = int(number(tagSampleFormat, sampleFormatUint))
+	t.compression = int(number(tagCompression, compressionNone))
+	t.predictor = int(number(tagPredictor, predictorNone))
+	if number(tagSamplesPerPixel, 1) != 1 {
+		return nil, fmt.Errorf("%w: only single-band images are supported", ErrUnsupportedTile)
+	}
+	if t.width == 0 || t.height == 0 {
+		return nil, fmt.Errorf("%w: missing image size", ErrUnsupportedTile)
+	}
+	if _, err := t.decodeSample(make([]byte, 8)); err != nil {
+		return nil, err
+	}
+	switch t.compression {
+	case compressionNone, compressionDeflate, compressionDeflateOld:
+	default:
+		return nil, fmt.Errorf("%w: compression %d", ErrUnsupportedTile, t.compression)
+	}
+
+	if _, tiled := tags[tagTileOffsets]; tiled {
+		t.blockWidth = int(number(tagTileWidth, 0))
+		t.blockHeight = int(number(tagTileLength, 0))
+		t.offsets = tags[tagTileOffsets].uints()
+		t.byteCounts = tags[tagTileByteCounts].uints()
+	} else {
+		t.blockWidth = t.width
+		t.blockHeight = int(number(tagRowsPerStrip, float64(t.height)))
+		t.offsets = tags[tagStripOffsets].uints()
+		t.byteCounts = tags[tagStripByteCounts].uints()
+	}
+	if t.blockWidth == 0 || t.blockHeight == 0 || len(t.offsets) == 0 || len(t.offsets) != len(t.byteCounts) {
+		return nil, fmt.Errorf("%w: missing strip or tile layout", ErrUnsupportedTile)
+	}
+
+	scale, tiepoint := tags[tagModelPixelScale].numbers, tags[tagModelTiepoint].numbers
+	if len(scale) < 2 || len(tiepoint) < 6 {
+		return nil, fmt.Errorf("%w: missing georeferencing", ErrUnsupportedTile)
+	}
+	t.dLon, t.dLat = scale[0], scale[1]
+	// The tiepoint maps raster (i, j) to (x, y). With pixel-is-area it's the
+	// corner of the pixel, so the first sample centre is half a pixel in.
+	half := 0.5
+	if keys := tags[tagGeoKeyDirectory].numbers; pixelIsPoint(keys) {
+		half = 0
+	}
+	t.originLon = tiepoint[3] + (half-tiepoint[0])*t.dLon
+	t.originLat = tiepoint[4] - (half-tiepoint[1])*t.dLat
+
+	if noData := strings.TrimSpace(strings.TrimRight(tags[tagGDALNoData].text, "\x00")); noData != "" {
+		if v, err := strconv.ParseFloat(noData, 64); err == nil {
+			t.nodata = float32(v)
+			t.hasNodata = true
+		}
+	}
+	return t, nil
+}
+
+func pixelIsPoint(keys []float64) bool {
+	// Header of four shorts, then four shorts per key: id, location, count, value
+	for i := 4; i+3 < len(keys); i += 4 {
+		if keys[i] == geoKeyRasterType && keys[i+1] == 0 {
+			return keys[i+3] == rasterPixelIsPoint
+		}
+	}
+	return false
+}
+
+// readBlock returns one strip or tile as samples, blockWidth by blockHeight
+func (t *geoTIFF) readBlock(r io.ReadSeeker, i int) ([]float32, error) {
+	raw := make([]byte, t.byteCounts[i])
+	if _, err := r.Seek(int64(t.offsets[i]), io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return nil, err
+	}
+
+	if t.compression != compressionNone {
+		zr, err := zlib.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, err
+		}
+		raw, err = io.ReadAll(zr)
+		zr.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	bytesPerSample := t.bitsPerSample / 8
+	rowBytes := t.blockWidth * bytesPerSample
+	samples := make([]float32, t.blockWidth*t.blockHeight)
+	// The last strip can be short
+	rows := min(t.blockHeight, len(raw)/rowBytes)
+	for y := 0; y < rows; y++ {
+		row := raw[y*rowBytes : (y+1)*rowBytes]
+		switch t.predictor {
+		case predictorHorizontal:
+			t.undoHorizontalPredictor(row, bytesPerSample)
+		case predictorFloatingPoint:
+			row = undoFloatingPointPredictor(row, bytesPerSample, t.order)
+		}
+		for x := 0; x < t.blockWidth; x++ {
+			v, err := t.decodeSample(row[x*bytesPerSample:])
+			if err != nil {
+				return nil, err
+			}
+			samples[y*t.blockWidth+x] = v
+		}
+	}
+	return samples, nil
+}
+
+func (t *geoTIFF) decodeSample(b []byte) (float32, error) {
+	switch {
+	case t.sampleFormat == sampleFormatInt && t.bitsPerSample == 16:
+		return float32(int16(t.order.Uint16(b))), nil
+	case t.sampleFormat == sampleFormatUint && t.bitsPerSample == 16:
+		return float32(t.order.Uint16(b)), nil
+	case t.sampleFormat == sampleFormatInt && t.bitsPerSample == 32:
+		return float32(int32(t.order.Uint32(b))), nil
+	case t.sampleFormat == sampleFormatFloat && t.bitsPerSample == 32:
+		return math.Float32frombits(t.order.Uint32(b)), nil
+	case t.sampleFormat == sampleFormatFloat && t.bitsPerSample == 64:
+		return float32(math.Float64frombits(t.order.Uint64(b))), nil
+	}
+	return 0, fmt.Errorf("%w: %d-bit sample format %d", ErrUnsupportedTile, t.bitsPerSample, t.sampleFormat)
+}
+
+// undoHorizontalPredictor turns per-sample differences back into values
+func (t *geoTIFF) undoHorizontalPredictor(row []byte, bytesPerSample int) {
+	for i := bytesPerSample; i+bytesPerSample <= len(row); i += bytesPerSample {
+		prev, curr := row[i-bytesPerSample:i], row[i:i+bytesPerSample]
+		switch bytesPerSample {
+		case 2:
+			t.order.PutUint16(curr, t.order.Uint16(prev)+t.order.Uint16(curr))
+		case 4:
+			t.order.PutUint32(curr, t.order.Uint32(prev)+t.order.Uint32(curr))
+		case 8:
+			t.order.PutUint64(curr, t.order.Uint64(prev)+t.order.Uint64(curr))
+		}
+	}
+}
+
+// undoFloatingPointPredictor reverses the byte-wise differencing of the
+// floating point predictor, which also splits each row into byte planes with
+// the most significant byte first
+func undoFloatingPointPredictor(row []byte, bytesPerSample int, order binary.ByteOrder) []byte {
+	for i := 1; i < len(row); i++ {
+		row[i] += row[i-1]
+	}
+	width := len(row) / bytesPerSample
+	out := make([]byte, len(row))
+	for x := 0; x < width; x++ {
+		for b := 0; b < bytesPerSample; b++ {
+			// Plane 0 holds the most significant bytes
+			plane := row[b*width+x]
+			if order == binary.ByteOrder(binary.BigEndian) {
+				out[x*bytesPerSample+b] = plane
+			} else {
+				out[x*bytesPerSample+bytesPerSample-1-b] = plane
+			}
+		}
+	}
+	return out
+}
+
+type tiffTag struct {
+	numbers []float64
+	text    string
+}
+
+func (t tiffTag) uints() []uint64 {
+	values := make([]uint64, len(t.numbers))
+	for i, n := range t.numbers {
+		values[i] = uint64(n)
+	}
+	return values
+}
+
+// TIFF field types and their sizes in bytes
+var tiffTypeSizes = map[uint16]int{
+	1:  1, // BYTE
+	2:  1, // ASCII
+	3:  2, // SHORT
+	4:  4, // LONG
+	6:  1, // SBYTE
+	8:  2, // SSHORT
+	9:  4, // SLONG
+	11: 4, // FLOAT
+	12: 8, // DOUBLE
+}
+
+func readIFD(r io.ReadSeeker, order binary.ByteOrder, offset int64) (map[int]tiffTag, error) {
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var count uint16
+	if err := binary.Read(r, order, &count); err != nil {
+		return nil, err
+	}
+	entries := make([]byte, int(count)*12)
+	if _, err := io.ReadFull(r, entries); err != nil {
+		return nil, err
+	}
+
+	tags := map[int]tiffTag{}
+	for i := 0; i < int(count); i++ {
+		entry := entries[i*12 : (i+1)*12]
+		tag := int(order.Uint16(entry[0:]))
+		fieldType := order.Uint16(entry[2:])
+		n := int(order.Uint32(entry[4:]))
+		size, ok := tiffTypeSizes[fieldType]
+		if !ok {
+			continue
+		}
+
+		value := entry[8:12]
+		if n*size > 4 {
+			value = make([]byte, n*size)
+			if _, err := r.Seek(int64(order.Uint32(entry[8:])), io.SeekStart); err != nil {
+				return nil, err
+			}
+			if _, err := io.ReadFull(r, value); err != nil {
+				return nil, err
+			}
+		}
+
+		if fieldType == 2 {
+			tags[tag] = tiffTag{text: string(value[:n])}
+			continue
+		}
+		numbers := make([]float64, n)
+		for j := range numbers {
+			b := value[j*size:]
+			switch fieldType {
+			case 1:
+				numbers[j] = float64(b[0])
+			case 6:
+				numbers[j] = float64(int8(b[0]))
+			case 3:
+				numbers[j] = float64(order.Uint16(b))
+			case 8:
+				numbers[j] = float64(int16(order.Uint16(b)))
+			case 4:
+				numbers[j] = float64(order.Uint32(b))
+			case 9:
+				numbers[j] = float64(int32(order.Uint32(b)))
+			case 11:
+				numbers[j] = float64(math.Float32frombits(order.Uint32(b)))
+			case 12:
+				numbers[j] = math.Float64frombits(order.Uint64(b))
+			}
+		}
+		tags[tag] = tiffTag{numbers: numbers}
+	}
+	return tags, nil
+}
@@ -0,0 +1,92 @@
+package dem
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// hgtVoid marks a missing sample in SRTM tiles
+const hgtVoid = -32768
+
+// indexHGT reads a tile's extent from its name, e.g. S34E018.hgt covers
+// 34°S–33°S and 18°E–19°E
+func indexHGT(path string) (*tileInfo, error) {
+	south, west, err := parseHGTName(filepath.Base(path))
+	if err != nil {
+		return nil, err
+	}
+	return &tileInfo{
+		path:   path,
+		minLat: south,
+		maxLat: south + 1,
+		minLon: west,
+		maxLon: west + 1,
+		load:   loadHGT,
+	}, nil
+}
+
+func parseHGTName(name string) (float64, float64, error) {
+	name = strings.ToUpper(strings.TrimSuffix(name, filepath.Ext(name)))
+	if len(name) != 7 {
+		return 0, 0, fmt.Errorf("%w: HGT name should look like N33W117", ErrUnsupportedTile)
+	}
+	lat, errLat := strconv.Atoi(name[1:3])
+	lon, errLon := strconv.Atoi(name[4:7])
+	if errLat != nil || errLon != nil {
+		return 0, 0, fmt.Errorf("%w: HGT name should look like N33W117", ErrUnsupportedTile)
+	}
+	switch name[0] {
+	case 'N':
+	case 'S':
+		lat = -lat
+	default:
+		return 0, 0, fmt.Errorf("%w: bad HGT latitude hemisphere", ErrUnsupportedTile)
+	}
+	switch name[3] {
+	case 'E':
+	case 'W':
+		lon = -lon
+	default:
+		return 0, 0, fmt.Errorf("%w: bad HGT longitude hemisphere", ErrUnsupportedTile)
+	}
+	return float64(lat), float64(lon), nil
+}
+
+// loadHGT decodes a square grid of big-endian int16 samples. 1201 samples a
+// side is 3 arc-seconds, 3601 is 1 arc-second.
+func loadHGT(path string) (*raster, error) {
+	south, west, err := parseHGTName(filepath.Base(path))
+	if err != nil {
+		return nil, err
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	size := int(math.Sqrt(float64(len(raw) / 2)))
+	if size < 2 || size*size*2 != len(raw) {
+		return nil, fmt.Errorf("%w: HGT file is not a square grid", ErrUnsupportedTile)
+	}
+
+	data := make([]float32, size*size)
+	for i := range data {
+		data[i] = float32(int16(binary.BigEndian.Uint16(raw[i*2:])))
+	}
+	step := 1 / float64(size-1)
+	return &raster{
+		width:     size,
+		height:    size,
+		originLat: south + 1,
+		originLon: west,
+		dLat:      step,
+		dLon:      step,
+		data:      data,
+		nodata:    hgtVoid,
+		hasNodata: true,
+	}, nil
+}
@@ -0,0 +1,20 @@
+package dto
+
+import "run-goals/models"
+
+// UserAchievementsResponse lists a user's awards alongside every badge available
+type UserAchievementsResponse struct {
+	Achievements []models.UserAchievementWithDetails `json:"achievements"`
+	Available    []models.AchievementDefinition      `json:"available"`
+	Total        int                                 `json:"total"`
+}
+
+// GroupAchievementsResponse is the recent achievements feed for a group
+type GroupAchievementsResponse struct {
+	Achievements []models.UserAchievementWithDetails `json:"achievements"`
+}
+
+// BackfillAchievementsResponse reports the result of an achievements backfill
+type BackfillAchievementsResponse struct {
+	Awarded int `json:"awarded"`
+}
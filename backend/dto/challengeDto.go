@@ -20,6 +20,9 @@ type CreateChallengeRequest struct {
 	TargetSummitCount *int                    `json:"targetSummitCount"`
 	Region            *string                 `json:"region"`
 	Difficulty        *string                 `json:"difficulty"`
+	CompletionRule    models.CompletionRule   `json:"completionRule"`
+	MaxWindowHours    *float64                `json:"maxWindowHours"`
+	StreakType        *models.StreakType      `json:"streakType"`
 	PeakIDs           []int64                 `json:"peakIds"`
 }
 
@@ -37,6 +40,9 @@ type UpdateChallengeRequest struct {
 	TargetSummitCount *int                    `json:"targetSummitCount"`
 	Region            *string                 `json:"region"`
 	Difficulty        *string                 `json:"difficulty"`
+	CompletionRule    models.CompletionRule   `json:"completionRule"`
+	MaxWindowHours    *float64                `json:"maxWindowHours"`
+	StreakType        *models.StreakType      `json:"streakType"`
 }
 
 type SetChallengePeaksRequest struct {
@@ -48,6 +54,9 @@ type AddGroupToChallengeRequest struct {
 	DeadlineOverride *time.Time `json:"deadlineOverride"`
 }
 
+// RecordSummitRequest credits one of the user's recorded summits to a
+// challenge. The summit time comes from the recorded summit, so SummitedAt is
+// ignored.
 type RecordSummitRequest struct {
 	PeakID     int64     `json:"peakId"`
 	ActivityID *int64    `json:"activityId"`
@@ -0,0 +1,61 @@
+package dto
+
+import (
+	"run-goals/models"
+	"time"
+)
+
+// ==================== Challenge Series Requests ====================
+
+type CreateChallengeSeriesRequest struct {
+	Name                  string                 `json:"name"`
+	Description           *string                `json:"description"`
+	CreatedByGroupID      *int64                 `json:"createdByGroupId"`
+	GoalType              models.GoalType        `json:"goalType"`
+	CompetitionMode       models.CompetitionMode `json:"competitionMode"`
+	Visibility            models.Visibility      `json:"visibility"`
+	TargetValue           *float64               `json:"targetValue"`
+	TargetSummitCount     *int                   `json:"targetSummitCount"`
+	PeakIDs               []int64                `json:"peakIds"`
+	Region                *string                `json:"region"`
+	Difficulty            *string                `json:"difficulty"`
+	CompletionRule        models.CompletionRule  `json:"completionRule"`
+	MaxWindowHours        *float64               `json:"maxWindowHours"`
+	StreakType            *models.StreakType     `json:"streakType"`
+	Recurrence            models.Recurrence      `json:"recurrence"`
+	IntervalDays          *int                   `json:"intervalDays"`
+	StartsAt              time.Time              `json:"startsAt"`
+	EndsAt                *time.Time             `json:"endsAt"`
+	CarryOverParticipants bool                   `json:"carryOverParticipants"`
+}
+
+type UpdateChallengeSeriesRequest struct {
+	ID                    int64                  `json:"id"`
+	Name                  string                 `json:"name"`
+	Description           *string                `json:"description"`
+	GoalType              models.GoalType        `json:"goalType"`
+	CompetitionMode       models.CompetitionMode `json:"competitionMode"`
+	Visibility            models.Visibility      `json:"visibility"`
+	TargetValue           *float64               `json:"targetValue"`
+	TargetSummitCount     *int                   `json:"targetSummitCount"`
+	PeakIDs               []int64                `json:"peakIds"`
+	Region                *string                `json:"region"`
+	Difficulty            *string                `json:"difficulty"`
+	CompletionRule        models.CompletionRule  `json:"completionRule"`
+	MaxWindowHours        *float64               `json:"maxWindowHours"`
+	StreakType            *models.StreakType     `json:"streakType"`
+	EndsAt                *time.Time             `json:"endsAt"`
+	CarryOverParticipants bool                   `json:"carryOverParticipants"`
+	IsActive              *bool                  `json:"isActive"` // Left as it is when omitted
+}
+
+// ==================== Challenge Series Responses ====================
+
+type CreateChallengeSeriesResponse struct {
+	ID int64 `json:"id"`
+}
+
+type ChallengeSeriesListResponse struct {
+	Series []models.ChallengeSeries `json:"series"`
+	Total  int                      `json:"total"`
+}
@@ -0,0 +1,47 @@
+package dto
+
+import (
+	"run-goals/models"
+	"time"
+)
+
+// ==================== Peak List Requests ====================
+
+type CreatePeakListRequest struct {
+	Name        string            `json:"name"`
+	Description *string           `json:"description"`
+	Region      *string           `json:"region"`
+	Visibility  models.Visibility `json:"visibility"`
+	PeakIDs     []int64           `json:"peakIds"`
+}
+
+type UpdatePeakListRequest struct {
+	Name        string            `json:"name"`
+	Description *string           `json:"description"`
+	Region      *string           `json:"region"`
+	Visibility  models.Visibility `json:"visibility"`
+}
+
+type SetPeakListPeaksRequest struct {
+	PeakIDs []int64 `json:"peakIds"`
+}
+
+// CreateChallengeFromListRequest creates a specific_summits challenge from a
+// list's peaks. Name and description default to the list's.
+type CreateChallengeFromListRequest struct {
+	Name            *string                `json:"name"`
+	Description     *string                `json:"description"`
+	CompetitionMode models.CompetitionMode `json:"competitionMode"`
+	Visibility      models.Visibility      `json:"visibility"`
+	StartDate       *time.Time             `json:"startDate"`
+	Deadline        *time.Time             `json:"deadline"`
+	Difficulty      *string                `json:"difficulty"`
+	CompletionRule  models.CompletionRule  `json:"completionRule"`
+	MaxWindowHours  *float64               `json:"maxWindowHours"`
+}
+
+// ==================== Peak List Responses ====================
+
+type CreatePeakListResponse struct {
+	ID int64 `json:"id"`
+}
@@ -0,0 +1,25 @@
+package dto
+
+import "run-goals/models"
+
+type CreatePeakSubmissionRequest struct {
+	Kind            models.PeakSubmissionKind `json:"kind"`
+	PeakID          *int64                    `json:"peak_id"`
+	Name            *string                   `json:"name"`
+	Latitude        *float64                  `json:"latitude"`
+	Longitude       *float64                  `json:"longitude"`
+	ElevationMeters *float64                  `json:"elevation_meters"`
+	Note            *string                   `json:"note"`
+}
+
+type CreatePeakSubmissionResponse struct {
+	ID int64 `json:"id"`
+}
+
+type ReviewPeakSubmissionRequest struct {
+	ReviewerNote *string `json:"reviewer_note"`
+}
+
+type ApprovePeakSubmissionResponse struct {
+	PeakID int64 `json:"peak_id"`
+}
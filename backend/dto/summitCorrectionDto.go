@@ -0,0 +1,22 @@
+package dto
+
+type DismissSummitRequest struct {
+	PeakID     int64   `json:"peak_id"`
+	ActivityID int64   `json:"activity_id"`
+	Reason     *string `json:"reason"`
+}
+
+type CreateSummitClaimRequest struct {
+	PeakID     int64   `json:"peak_id"`
+	ActivityID int64   `json:"activity_id"`
+	Note       *string `json:"note"`
+}
+
+type CreateSummitClaimResponse struct {
+	ID     int64  `json:"id"`
+	Status string `json:"status"`
+}
+
+type ReviewSummitClaimRequest struct {
+	ReviewerNote *string `json:"reviewer_note"`
+}
@@ -0,0 +1,154 @@
+package geo
+
+import "math"
+
+const earthRadiusMeters = 6371000.0
+
+// HaversineMeters returns the great-circle distance between two lat/lon points in meters
+func HaversineMeters(lat1, lon1, lat2, lon2 float64) float64 {
+	dLat := toRadians(lat2 - lat1)
+	dLon := toRadians(lon2 - lon1)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+
+	return earthRadiusMeters * c
+}
+
+// CumulativeDistances returns the distance along the track to each point, in meters.
+// coords are [lat, lon] pairs as returned by polyline.DecodeCoords.
+func CumulativeDistances(coords [][]float64) []float64 {
+	distances := make([]float64, len(coords))
+	for i := 1; i < len(coords); i++ {
+		distances[i] = distances[i-1] + HaversineMeters(coords[i-1][0], coords[i-1][1], coords[i][0], coords[i][1])
+	}
+	return distances
+}
+
+// BoundingBox returns the lat/lon box that contains every point within
+// radiusMeters of the given point. Use it to prefilter before HaversineMeters.
+func BoundingBox(lat, lon, radiusMeters float64) (minLat, maxLat, minLon, maxLon float64) {
+	latDelta := radiusMeters / earthRadiusMeters * 180 / math.Pi
+	lonDelta := 180.0
+	if cos := math.Cos(toRadians(lat)); cos > 1e-6 {
+		lonDelta = math.Min(latDelta/cos, 180)
+	}
+	return lat - latDelta, lat + latDelta, lon - lonDelta, lon + lonDelta
+}
+
+// DistanceToRouteMeters returns how close a route of [lat, lon] points comes
+// to a point, in meters. The route is projected flat around the point, which
+// is accurate enough over the few kilometres that matter. Returns +Inf for an
+// empty route.
+func DistanceToRouteMeters(coords [][]float64, lat, lon float64) float64 {
+	metersPerDegLat := earthRadiusMeters * math.Pi / 180
+	metersPerDegLon := metersPerDegLat * math.Cos(toRadians(lat))
+	project := func(c []float64) []float64 {
+		return []float64{(c[0] - lat) * metersPerDegLat, (c[1] - lon) * metersPerDegLon}
+	}
+
+	if len(coords) == 1 {
+		p := project(coords[0])
+		return math.Hypot(p[0], p[1])
+	}
+	origin := []float64{0, 0}
+	closest := math.Inf(1)
+	for i := 1; i < len(coords); i++ {
+		closest = math.Min(closest, segmentDistance(origin, project(coords[i-1]), project(coords[i])))
+	}
+	return closest
+}
+
+// OffsetMeters returns the point the given distances north and east of a
+// point. It assumes a flat earth, which is fine over a few kilometres.
+func OffsetMeters(lat, lon, northMeters, eastMeters float64) (float64, float64) {
+	metersPerDegLat := earthRadiusMeters * math.Pi / 180
+	metersPerDegLon := metersPerDegLat * math.Max(math.Cos(toRadians(lat)), 1e-6)
+	return lat + northMeters/metersPerDegLat, lon + eastMeters/metersPerDegLon
+}
+
+// SegmentInCircle returns the fractions along the segment from a to b, each
+// between 0 and 1, between which it's within radiusMeters of a point. ok is
+// false if it never is. a and b are [lat, lon] pairs and are projected flat
+// around the point, as in DistanceToRouteMeters.
+func SegmentInCircle(a, b []float64, lat, lon, radiusMeters float64) (from float64, to float64, ok bool) {
+	metersPerDegLat := earthRadiusMeters * math.Pi / 180
+	metersPerDegLon := metersPerDegLat * math.Cos(toRadians(lat))
+	ax, ay := (a[0]-lat)*metersPerDegLat, (a[1]-lon)*metersPerDegLon
+	dx, dy := (b[0]-a[0])*metersPerDegLat, (b[1]-a[1])*metersPerDegLon
+
+	// Solve |a + t*d| = radius for t
+	qa := dx*dx + dy*dy
+	qb := 2 * (ax*dx + ay*dy)
+	qc := ax*ax + ay*ay - radiusMeters*radiusMeters
+	if qa == 0 {
+		return 0, 1, qc <= 0
+	}
+	disc := qb*qb - 4*qa*qc
+	if disc < 0 {
+		return 0, 0, false
+	}
+	sqrtDisc := math.Sqrt(disc)
+	from = (-qb - sqrtDisc) / (2 * qa)
+	to = (-qb + sqrtDisc) / (2 * qa)
+	if to < 0 || from > 1 {
+		return 0, 0, false
+	}
+	return math.Max(from, 0), math.Min(to, 1), true
+}
+
+func toRadians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+// SimplifyCoords drops points that lie within tolerance of the line through
+// their neighbours (Douglas-Peucker). tolerance is in the same units as the
+// coordinates, so degrees for [lat, lon] pairs. The first and last points are
+// always kept.
+func SimplifyCoords(coords [][]float64, tolerance float64) [][]float64 {
+	if len(coords) < 3 || tolerance <= 0 {
+		return coords
+	}
+
+	keep := make([]bool, len(coords))
+	keep[0], keep[len(coords)-1] = true, true
+
+	// Explicit stack, GPS tracks can be long enough to make recursion deep
+	stack := [][2]int{{0, len(coords) - 1}}
+	for len(stack) > 0 {
+		span := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		first, last := span[0], span[1]
+
+		maxDist, index := 0.0, -1
+		for i := first + 1; i < last; i++ {
+			if d := segmentDistance(coords[i], coords[first], coords[last]); d > maxDist {
+				maxDist, index = d, i
+			}
+		}
+		if index != -1 && maxDist > tolerance {
+			keep[index] = true
+			stack = append(stack, [2]int{first, index}, [2]int{index, last})
+		}
+	}
+
+	simplified := make([][]float64, 0, len(coords))
+	for i, k := range keep {
+		if k {
+			simplified = append(simplified, coords[i])
+		}
+	}
+	return simplified
+}
+
+// segmentDistance is the planar distance from p to the segment a-b
+func segmentDistance(p, a, b []float64) float64 {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	if dx == 0 && dy == 0 {
+		return math.Hypot(p[0]-a[0], p[1]-a[1])
+	}
+	t := ((p[0]-a[0])*dx + (p[1]-a[1])*dy) / (dx*dx + dy*dy)
+	t = math.Max(0, math.Min(1, t))
+	return math.Hypot(p[0]-(a[0]+t*dx), p[1]-(a[1]+t*dy))
+}
@@ -7,10 +7,20 @@ import (
 )
 
 type ApiHandler struct {
-	l                    *log.Logger
-	apiController        *controllers.ApiController
-	groupsController     *controllers.GroupsController
-	challengesController *controllers.ChallengesController
+	l                           *log.Logger
+	apiController               *controllers.ApiController
+	groupsController            *controllers.GroupsController
+	challengesController        *controllers.ChallengesController
+	seriesController            *controllers.ChallengeSeriesController
+	achievementsController      *controllers.AchievementsController
+	peakListsController         *controllers.PeakListsController
+	peakSubmissionsController   *controllers.PeakSubmissionsController
+	mapController               *controllers.MapController
+	privacyController           *controllers.PrivacyController
+	summitCorrectionsController *controllers.SummitCorrectionsController
+	notificationsController     *controllers.NotificationsController
+	emailController             *controllers.EmailController
+	groupWebhooksController     *controllers.GroupWebhooksController
 }
 
 func NewApiHandler(
@@ -18,12 +28,32 @@ func NewApiHandler(
 	apiController *controllers.ApiController,
 	groupsController *controllers.GroupsController,
 	challengesController *controllers.ChallengesController,
+	seriesController *controllers.ChallengeSeriesController,
+	achievementsController *controllers.AchievementsController,
+	peakListsController *controllers.PeakListsController,
+	peakSubmissionsController *controllers.PeakSubmissionsController,
+	mapController *controllers.MapController,
+	privacyController *controllers.PrivacyController,
+	summitCorrectionsController *controllers.SummitCorrectionsController,
+	notificationsController *controllers.NotificationsController,
+	emailController *controllers.EmailController,
+	groupWebhooksController *controllers.GroupWebhooksController,
 ) *ApiHandler {
 	return &ApiHandler{
 		l,
 		apiController,
 		groupsController,
 		challengesController,
+		seriesController,
+		achievementsController,
+		peakListsController,
+		peakSubmissionsController,
+		mapController,
+		privacyController,
+		summitCorrectionsController,
+		notificationsController,
+		emailController,
+		groupWebhooksController,
 	}
 }
 
@@ -32,11 +62,48 @@ func (handler *ApiHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	// handle request to get activities
 	switch r.URL.Path {
 	case "/api/activities":
-		handler.apiController.ListActivities(rw, r)
-		return
+		if r.Method == http.MethodGet {
+			handler.apiController.ListActivities(rw, r)
+			return
+		}
+	case "/api/activity":
+		if r.Method == http.MethodGet {
+			handler.apiController.GetActivityDetail(rw, r)
+			return
+		}
+	case "/api/activities/elevation-profile":
+		if r.Method == http.MethodGet {
+			handler.apiController.GetActivityElevationProfile(rw, r)
+			return
+		}
 	case "/api/peaks":
 		handler.apiController.ListPeaks(rw, r)
 		return
+	case "/api/geojson/peaks":
+		if r.Method == http.MethodGet {
+			handler.mapController.GetPeaksGeoJSON(rw, r)
+			return
+		}
+	case "/api/geojson/summits":
+		if r.Method == http.MethodGet {
+			handler.mapController.GetSummitsGeoJSON(rw, r)
+			return
+		}
+	case "/api/geojson/routes":
+		if r.Method == http.MethodGet {
+			handler.mapController.GetRoutesGeoJSON(rw, r)
+			return
+		}
+	case "/api/peak":
+		if r.Method == http.MethodGet {
+			handler.apiController.GetPeakDetail(rw, r)
+			return
+		}
+	case "/api/peaks/search":
+		if r.Method == http.MethodGet {
+			handler.apiController.SearchPeaks(rw, r)
+			return
+		}
 	case "/api/progress":
 		handler.apiController.GetProgress(rw, r)
 		return
@@ -50,6 +117,15 @@ func (handler *ApiHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	case "/api/peak-summaries":
 		handler.apiController.GetPeakSummaries(rw, r)
 		return
+	case "/api/streaks":
+		handler.apiController.GetStreaks(rw, r)
+		return
+	case "/api/peak-fastest-ascents":
+		handler.apiController.GetFastestAscents(rw, r)
+		return
+	case "/api/fastest-traverses":
+		handler.apiController.GetFastestTraverses(rw, r)
+		return
 	case "/api/groups":
 		if r.Method == http.MethodPost {
 			handler.groupsController.CreateGroup(rw, r)
@@ -113,6 +189,29 @@ func (handler *ApiHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 			handler.groupsController.GetGroupGoalProgress(rw, r)
 			return
 		}
+	case "/api/group-webhook":
+		if r.Method == http.MethodPost {
+			handler.groupWebhooksController.CreateGroupWebhook(rw, r)
+			return
+		}
+		if r.Method == http.MethodPut {
+			handler.groupWebhooksController.UpdateGroupWebhook(rw, r)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			handler.groupWebhooksController.DeleteGroupWebhook(rw, r)
+			return
+		}
+	case "/api/group-webhooks":
+		if r.Method == http.MethodGet {
+			handler.groupWebhooksController.GetGroupWebhooks(rw, r)
+			return
+		}
+	case "/api/group-webhook-deliveries":
+		if r.Method == http.MethodGet {
+			handler.groupWebhooksController.GetGroupWebhookDeliveries(rw, r)
+			return
+		}
 	case "/api/personal-goals":
 		if r.Method == http.MethodGet {
 			handler.apiController.GetPersonalGoals(rw, r)
@@ -127,6 +226,33 @@ func (handler *ApiHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 			handler.apiController.GetAllPersonalGoals(rw, r)
 			return
 		}
+	case "/api/goals":
+		if r.Method == http.MethodGet {
+			handler.apiController.ListGoals(rw, r)
+			return
+		}
+		if r.Method == http.MethodPost {
+			handler.apiController.CreateGoal(rw, r)
+			return
+		}
+		if r.Method == http.MethodPut {
+			handler.apiController.UpdateGoal(rw, r)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			handler.apiController.DeleteGoal(rw, r)
+			return
+		}
+	case "/api/personal-goals/progress":
+		if r.Method == http.MethodGet {
+			handler.apiController.GetPersonalGoalProgress(rw, r)
+			return
+		}
+	case "/api/personal-goals/progress/all":
+		if r.Method == http.MethodGet {
+			handler.apiController.GetAllPersonalGoalProgress(rw, r)
+			return
+		}
 	case "/api/summit-favourites":
 		if r.Method == http.MethodGet {
 			handler.apiController.GetSummitFavourites(rw, r)
@@ -140,6 +266,188 @@ func (handler *ApiHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 			handler.apiController.RemoveSummitFavourite(rw, r)
 			return
 		}
+	case "/api/wishlist":
+		if r.Method == http.MethodGet {
+			handler.apiController.GetWishlist(rw, r)
+			return
+		}
+		if r.Method == http.MethodPut {
+			handler.apiController.UpdateWishlistPlan(rw, r)
+			return
+		}
+	case "/api/wishlist/order":
+		if r.Method == http.MethodPut {
+			handler.apiController.ReorderWishlist(rw, r)
+			return
+		}
+	case "/api/wishlist/suggestions":
+		if r.Method == http.MethodGet {
+			handler.apiController.GetWishlistSuggestions(rw, r)
+			return
+		}
+	case "/api/wishlist/clusters":
+		if r.Method == http.MethodGet {
+			handler.apiController.GetWishlistClusters(rw, r)
+			return
+		}
+
+	// ==================== Peak Submission Routes ====================
+	case "/api/privacy-zones":
+		if r.Method == http.MethodGet {
+			handler.privacyController.GetPrivacyZones(rw, r)
+			return
+		}
+		if r.Method == http.MethodPost {
+			handler.privacyController.CreatePrivacyZone(rw, r)
+			return
+		}
+	case "/api/privacy-zone":
+		if r.Method == http.MethodDelete {
+			handler.privacyController.DeletePrivacyZone(rw, r)
+			return
+		}
+	case "/api/peak-submissions":
+		if r.Method == http.MethodGet {
+			handler.peakSubmissionsController.GetMySubmissions(rw, r)
+			return
+		}
+		if r.Method == http.MethodPost {
+			handler.peakSubmissionsController.SubmitPeak(rw, r)
+			return
+		}
+	case "/api/peak-submissions/queue":
+		if r.Method == http.MethodGet {
+			handler.peakSubmissionsController.GetQueue(rw, r)
+			return
+		}
+	case "/api/peak-submissions/approve":
+		if r.Method == http.MethodPost {
+			handler.peakSubmissionsController.ApproveSubmission(rw, r)
+			return
+		}
+	case "/api/peak-submissions/reject":
+		if r.Method == http.MethodPost {
+			handler.peakSubmissionsController.RejectSubmission(rw, r)
+			return
+		}
+
+	// ==================== Notification Routes ====================
+	case "/api/notifications":
+		if r.Method == http.MethodGet {
+			handler.notificationsController.GetNotifications(rw, r)
+			return
+		}
+	case "/api/notifications/read":
+		if r.Method == http.MethodPost {
+			handler.notificationsController.MarkNotificationsRead(rw, r)
+			return
+		}
+	case "/api/notifications/read-all":
+		if r.Method == http.MethodPost {
+			handler.notificationsController.MarkAllNotificationsRead(rw, r)
+			return
+		}
+	case "/api/notifications/preferences":
+		if r.Method == http.MethodGet {
+			handler.notificationsController.GetNotificationPreferences(rw, r)
+			return
+		}
+		if r.Method == http.MethodPut {
+			handler.notificationsController.UpdateNotificationPreferences(rw, r)
+			return
+		}
+
+	// ==================== Email Routes ====================
+	case "/api/email":
+		if r.Method == http.MethodGet {
+			handler.emailController.GetEmailSettings(rw, r)
+			return
+		}
+		if r.Method == http.MethodPut {
+			handler.emailController.UpdateEmail(rw, r)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			handler.emailController.DeleteEmail(rw, r)
+			return
+		}
+	case "/api/email/preferences":
+		if r.Method == http.MethodPut {
+			handler.emailController.UpdateEmailPreferences(rw, r)
+			return
+		}
+	case "/api/email/history":
+		if r.Method == http.MethodGet {
+			handler.emailController.GetEmailHistory(rw, r)
+			return
+		}
+
+	// ==================== Peak List Routes ====================
+	case "/api/summit-dismissals":
+		if r.Method == http.MethodGet {
+			handler.summitCorrectionsController.GetDismissals(rw, r)
+			return
+		}
+		if r.Method == http.MethodPost {
+			handler.summitCorrectionsController.DismissSummit(rw, r)
+			return
+		}
+	case "/api/summit-claims":
+		if r.Method == http.MethodGet {
+			handler.summitCorrectionsController.GetMyClaims(rw, r)
+			return
+		}
+		if r.Method == http.MethodPost {
+			handler.summitCorrectionsController.ClaimSummit(rw, r)
+			return
+		}
+	case "/api/summit-claims/queue":
+		if r.Method == http.MethodGet {
+			handler.summitCorrectionsController.GetQueue(rw, r)
+			return
+		}
+	case "/api/summit-claims/approve":
+		if r.Method == http.MethodPost {
+			handler.summitCorrectionsController.ApproveClaim(rw, r)
+			return
+		}
+	case "/api/summit-claims/reject":
+		if r.Method == http.MethodPost {
+			handler.summitCorrectionsController.RejectClaim(rw, r)
+			return
+		}
+	case "/api/peak-lists":
+		if r.Method == http.MethodGet {
+			handler.peakListsController.GetPeakLists(rw, r)
+			return
+		}
+		if r.Method == http.MethodPost {
+			handler.peakListsController.CreatePeakList(rw, r)
+			return
+		}
+	case "/api/peak-list":
+		if r.Method == http.MethodGet {
+			handler.peakListsController.GetPeakList(rw, r)
+			return
+		}
+		if r.Method == http.MethodPut {
+			handler.peakListsController.UpdatePeakList(rw, r)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			handler.peakListsController.DeletePeakList(rw, r)
+			return
+		}
+	case "/api/peak-list-peaks":
+		if r.Method == http.MethodPut {
+			handler.peakListsController.SetPeakListPeaks(rw, r)
+			return
+		}
+	case "/api/peak-list-challenge":
+		if r.Method == http.MethodPost {
+			handler.peakListsController.CreateChallengeFromList(rw, r)
+			return
+		}
 
 	// ==================== Challenge Routes ====================
 	case "/api/challenges":
@@ -247,5 +555,44 @@ func (handler *ApiHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 			handler.challengesController.GetGroupChallenges(rw, r)
 			return
 		}
+
+	// ==================== Challenge Series Routes ====================
+	case "/api/challenge-series":
+		if r.Method == http.MethodPost {
+			handler.seriesController.CreateSeries(rw, r)
+			return
+		}
+		if r.Method == http.MethodPut {
+			handler.seriesController.UpdateSeries(rw, r)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			handler.seriesController.DeleteSeries(rw, r)
+			return
+		}
+		if r.Method == http.MethodGet {
+			handler.seriesController.GetSeries(rw, r)
+			return
+		}
+	case "/api/challenge-series-history":
+		if r.Method == http.MethodGet {
+			handler.seriesController.GetSeriesHistory(rw, r)
+			return
+		}
+	case "/api/challenge-series-leaderboard":
+		if r.Method == http.MethodGet {
+			handler.seriesController.GetSeriesLeaderboard(rw, r)
+			return
+		}
+	case "/api/achievements":
+		if r.Method == http.MethodGet {
+			handler.achievementsController.GetAchievements(rw, r)
+			return
+		}
+	case "/api/group-achievements":
+		if r.Method == http.MethodGet {
+			handler.achievementsController.GetGroupAchievements(rw, r)
+			return
+		}
 	}
 }
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"run-goals/controllers"
+	"strings"
+)
+
+type TilesHandler struct {
+	l             *log.Logger
+	mapController *controllers.MapController
+}
+
+func NewTilesHandler(
+	l *log.Logger,
+	mapController *controllers.MapController,
+) *TilesHandler {
+	return &TilesHandler{
+		l:             l,
+		mapController: mapController,
+	}
+}
+
+// ServeHTTP serves vector tiles at /tiles/{z}/{x}/{y} and heatmap tiles at
+// /tiles/heatmap/{z}/{x}/{y}.png
+func (h *TilesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/tiles/heatmap/") {
+		h.mapController.GetHeatmapTile(w, r)
+		return
+	}
+	h.mapController.GetTile(w, r)
+}
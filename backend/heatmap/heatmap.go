@@ -0,0 +1,144 @@
+// Package heatmap rasterises routes into PNG heatmap tiles. Each pixel counts
+// how many routes pass through it, and the count is coloured on a log scale.
+package heatmap
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+)
+
+// Saturation is the number of routes through a pixel at which it reaches the
+// hottest colour. The scale is fixed, not relative to the busiest pixel, so
+// neighbouring tiles always match.
+const Saturation = 25
+
+// stops is the colour ramp from a single route to Saturation routes
+var stops = []struct {
+	at    float64
+	color color.NRGBA
+}{
+	{0.0, color.NRGBA{R: 150, G: 20, B: 40, A: 150}},
+	{0.4, color.NRGBA{R: 235, G: 60, B: 20, A: 210}},
+	{0.75, color.NRGBA{R: 255, G: 170, B: 20, A: 240}},
+	{1.0, color.NRGBA{R: 255, G: 255, B: 210, A: 255}},
+}
+
+// Canvas accumulates routes for one square tile
+type Canvas struct {
+	size   int
+	width  int
+	counts []uint16
+	// stamp records the last route to touch each pixel, so a route that
+	// crosses a pixel many times still only counts once
+	stamp []uint32
+	route uint32
+}
+
+// NewCanvas returns an empty canvas size pixels square. lineWidth is the
+// width routes are drawn with, in pixels.
+func NewCanvas(size int, lineWidth int) *Canvas {
+	if lineWidth < 1 {
+		lineWidth = 1
+	}
+	return &Canvas{
+		size:   size,
+		width:  lineWidth,
+		counts: make([]uint16, size*size),
+		stamp:  make([]uint32, size*size),
+	}
+}
+
+// AddRoute draws one route, given as lines of pixel coordinates. Coordinates
+// may lie outside the canvas; those parts aren't drawn.
+func (c *Canvas) AddRoute(lines [][][2]float64) {
+	c.route++
+	for _, line := range lines {
+		if len(line) == 1 {
+			c.plot(line[0][0], line[0][1])
+		}
+		for i := 1; i < len(line); i++ {
+			c.segment(line[i-1], line[i])
+		}
+	}
+}
+
+// segment steps along a-b at most half a pixel at a time
+func (c *Canvas) segment(a, b [2]float64) {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	steps := int(math.Ceil(math.Max(math.Abs(dx), math.Abs(dy)) * 2))
+	if steps == 0 {
+		c.plot(a[0], a[1])
+		return
+	}
+	for i := 0; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		c.plot(a[0]+t*dx, a[1]+t*dy)
+	}
+}
+
+func (c *Canvas) plot(x, y float64) {
+	px, py := int(math.Floor(x)), int(math.Floor(y))
+	offset := (c.width - 1) / 2
+	for oy := 0; oy < c.width; oy++ {
+		for ox := 0; ox < c.width; ox++ {
+			c.touch(px+ox-offset, py+oy-offset)
+		}
+	}
+}
+
+func (c *Canvas) touch(x, y int) {
+	if x < 0 || y < 0 || x >= c.size || y >= c.size {
+		return
+	}
+	i := y*c.size + x
+	if c.stamp[i] == c.route {
+		return
+	}
+	c.stamp[i] = c.route
+	if c.counts[i] < math.MaxUint16 {
+		c.counts[i]++
+	}
+}
+
+// PNG renders the canvas. Pixels no route passes through are transparent.
+func (c *Canvas) PNG() ([]byte, error) {
+	img := image.NewNRGBA(image.Rect(0, 0, c.size, c.size))
+	scale := math.Log(Saturation)
+	for i, n := range c.counts {
+		if n == 0 {
+			continue
+		}
+		t := math.Min(1, math.Log(float64(n))/scale)
+		img.SetNRGBA(i%c.size, i/c.size, ramp(t))
+	}
+
+	var buf bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestSpeed}
+	if err := encoder.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func ramp(t float64) color.NRGBA {
+	for i := 1; i < len(stops); i++ {
+		if t > stops[i].at {
+			continue
+		}
+		lo, hi := stops[i-1], stops[i]
+		f := (t - lo.at) / (hi.at - lo.at)
+		mix := func(a, b uint8) uint8 {
+			return uint8(math.Round(float64(a) + f*(float64(b)-float64(a))))
+		}
+		return color.NRGBA{
+			R: mix(lo.color.R, hi.color.R),
+			G: mix(lo.color.G, hi.color.G),
+			B: mix(lo.color.B, hi.color.B),
+			A: mix(lo.color.A, hi.color.A),
+		}
+	}
+	return stops[len(stops)-1].color
+}
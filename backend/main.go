@@ -36,7 +36,8 @@ func main() {
 	log.Println("Received terminate, graceful shutdown", sig)
 
 	// create context used to allow server time to finish processing ongoing requests before shutting down
-	ctx, _ := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
 
 	// shutdown server with created context - gracefully shutting down
 	server.Shutdown(ctx)
@@ -24,27 +24,45 @@ func JWT(jwtService *services.JWTService, next http.Handler) http.Handler {
 			return
 		}
 
-		tokenStr := parts[1]
-		token, err := jwtService.ValidateToken(tokenStr)
-		if err != nil || !token.Valid {
-			http.Error(rw, "token validation failed", http.StatusUnauthorized)
-			return
-		}
+		authenticate(jwtService, parts[1], next, rw, r)
+	})
+}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			http.Error(rw, "Unauthorized claims", http.StatusUnauthorized)
+// JWTStream also takes the token from the access_token query parameter, as
+// browsers' EventSource can't set headers. Only use it for event streams,
+// tokens in URLs end up in logs.
+func JWTStream(jwtService *services.JWTService, next http.Handler) http.Handler {
+	header := JWT(jwtService, next)
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		tokenStr := r.URL.Query().Get("access_token")
+		if tokenStr == "" || r.Header.Get("Authorization") != "" {
+			header.ServeHTTP(rw, r)
 			return
 		}
+		authenticate(jwtService, tokenStr, next, rw, r)
+	})
+}
 
-		userID, ok := claims["sub"].(float64) // watch out for type
-		if !ok {
-			http.Error(rw, "Unauthorized user", http.StatusUnauthorized)
-			return
-		}
+func authenticate(jwtService *services.JWTService, tokenStr string, next http.Handler, rw http.ResponseWriter, r *http.Request) {
+	token, err := jwtService.ValidateToken(tokenStr)
+	if err != nil || !token.Valid {
+		http.Error(rw, "token validation failed", http.StatusUnauthorized)
+		return
+	}
 
-		// Attach userID to context
-		ctx := context.WithValue(r.Context(), meta.ContextKeyUserID, int64(userID))
-		next.ServeHTTP(rw, r.WithContext(ctx))
-	})
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		http.Error(rw, "Unauthorized claims", http.StatusUnauthorized)
+		return
+	}
+
+	userID, ok := claims["sub"].(float64) // watch out for type
+	if !ok {
+		http.Error(rw, "Unauthorized user", http.StatusUnauthorized)
+		return
+	}
+
+	// Attach userID to context
+	ctx := context.WithValue(r.Context(), meta.ContextKeyUserID, int64(userID))
+	next.ServeHTTP(rw, r.WithContext(ctx))
 }
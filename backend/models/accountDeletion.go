@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+type AccountDeletionStatus string
+
+const (
+	AccountDeletionStatusPending   AccountDeletionStatus = "pending"
+	AccountDeletionStatusRunning   AccountDeletionStatus = "running"
+	AccountDeletionStatusCompleted AccountDeletionStatus = "completed"
+	AccountDeletionStatusFailed    AccountDeletionStatus = "failed"
+)
+
+// AccountDeletion is a user's request to delete their account, kept after
+// the account is gone as proof of what was deleted
+type AccountDeletion struct {
+	ID          int64                   `json:"id"`
+	UserID      int64                   `json:"user_id"`
+	Status      AccountDeletionStatus   `json:"status"`
+	ReceiptCode string                  `json:"-"`
+	Receipt     *AccountDeletionReceipt `json:"receipt,omitempty"`
+	Error       *string                 `json:"error,omitempty"`
+	Attempts    int                     `json:"attempts"`
+	RequestedAt time.Time               `json:"requested_at"`
+	StartedAt   *time.Time              `json:"started_at,omitempty"`
+	CompletedAt *time.Time              `json:"completed_at,omitempty"`
+}
+
+// AccountDeletionReceipt records what a deletion did. It holds counts only,
+// nothing that identifies the user.
+type AccountDeletionReceipt struct {
+	StravaDeauthorized    bool             `json:"strava_deauthorized"`
+	StravaError           string           `json:"strava_error,omitempty"`
+	GroupsTransferred     int              `json:"groups_transferred"`
+	GroupsClosed          int              `json:"groups_closed"`
+	ChallengesTransferred int              `json:"challenges_transferred"`
+	ChallengesClosed      int              `json:"challenges_closed"`
+	SeriesTransferred     int              `json:"series_transferred"`
+	LeaderboardAnonymised int              `json:"leaderboard_entries_anonymised"` // Finished challenges kept under a placeholder
+	ExportFilesDeleted    int              `json:"export_files_deleted"`
+	RowsDeleted           map[string]int64 `json:"rows_deleted"` // By table
+	Verified              bool             `json:"verified"`     // No rows referencing the user remain
+	CompletedAt           time.Time        `json:"completed_at"`
+}
+
+// AccountOwnershipTransfer counts what happened to the groups, challenges
+// and series a deleted user owned
+type AccountOwnershipTransfer struct {
+	GroupsTransferred     int
+	GroupsClosed          int
+	ChallengesTransferred int
+	ChallengesClosed      int
+	SeriesTransferred     int
+}
@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+// AchievementRuleType determines how an achievement definition is evaluated
+type AchievementRuleType string
+
+const (
+	AchievementRuleUniquePeaks    AchievementRuleType = "unique_peaks"    // MinCount distinct peaks
+	AchievementRulePeaksAbove     AchievementRuleType = "peaks_above"     // MinCount distinct peaks at or above Threshold meters
+	AchievementRuleRegionComplete AchievementRuleType = "region_complete" // Every peak in Region (or in each region)
+	AchievementRuleHighestPeak    AchievementRuleType = "highest_peak"    // Highest peak in Region (or in each region)
+	AchievementRuleElevationTotal AchievementRuleType = "elevation_total" // Threshold meters of total elevation gain
+	AchievementRuleStreak         AchievementRuleType = "streak"          // MinCount consecutive days with an activity
+	AchievementRuleEarlyBird      AchievementRuleType = "early_bird"      // MinCount summits before Threshold o'clock
+	AchievementRuleChallengeWin   AchievementRuleType = "challenge_win"   // MinCount competitive challenge wins
+)
+
+// AchievementDefinition is a badge and the rule for earning it
+type AchievementDefinition struct {
+	ID          int64               `json:"id" db:"id"`
+	Key         string              `json:"key" db:"key"`
+	Name        string              `json:"name" db:"name"`
+	Description string              `json:"description" db:"description"`
+	Category    string              `json:"category" db:"category"`
+	RuleType    AchievementRuleType `json:"ruleType" db:"rule_type"`
+	Threshold   *float64            `json:"threshold" db:"threshold"`
+	MinCount    int                 `json:"minCount" db:"min_count"`
+	Region      *string             `json:"region" db:"region"`
+	SortOrder   int                 `json:"sortOrder" db:"sort_order"`
+}
+
+// UserAchievement is an achievement awarded to a user
+type UserAchievement struct {
+	ID            int64     `json:"id" db:"id"`
+	UserID        int64     `json:"userId" db:"user_id"`
+	AchievementID int64     `json:"achievementId" db:"achievement_id"`
+	Scope         string    `json:"scope" db:"scope"` // e.g. the region for per-region awards
+	ActivityID    *int64    `json:"activityId" db:"activity_id"`
+	PeakID        *int64    `json:"peakId" db:"peak_id"`
+	ChallengeID   *int64    `json:"challengeId" db:"challenge_id"`
+	AwardedAt     time.Time `json:"awardedAt" db:"awarded_at"`
+}
+
+// UserAchievementWithDetails includes the badge and user information
+type UserAchievementWithDetails struct {
+	UserAchievement
+	Key          string  `json:"key" db:"key"`
+	Name         string  `json:"name" db:"name"`
+	Description  string  `json:"description" db:"description"`
+	Category     string  `json:"category" db:"category"`
+	UserName     string  `json:"userName" db:"user_name"`
+	ActivityName *string `json:"activityName" db:"activity_name"`
+	PeakName     *string `json:"peakName" db:"peak_name"`
+}
+
+// SummitWithPeak is a summit with the peak details achievements look at
+type SummitWithPeak struct {
+	UserPeak
+	Elevation float64 `json:"elevation"`
+	Region    string  `json:"region"`
+}
+
+// RegionPeakStats summarises the peaks in a region
+type RegionPeakStats struct {
+	Region        string `json:"region"`
+	PeakCount     int    `json:"peakCount"`
+	HighestPeakID int64  `json:"highestPeakId"`
+}
@@ -19,7 +19,10 @@ type Activity struct {
 	MovingTime       float64   `json:"moving_time"`
 	StartDate        time.Time `json:"start_date"`
 	MapPolyline      string    `json:"map_polyline"`
+	MapPolylines     []string  `json:"map_polylines,omitempty"` // The pieces left after trimming to the owner's privacy zones
 	PhotoURL         string    `json:"photo_url"`
+	Private          bool      `json:"private"`    // Strava "private" flag
+	Visibility       string    `json:"visibility"` // Strava visibility: everyone, followers_only, only_me
 
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
@@ -29,6 +32,18 @@ type Activity struct {
 	SummitsCalculated  bool `json:"summits_calculated"` // Whether summit detection has been run for this activity
 }
 
+// Strava activity visibility values
+const (
+	VisibilityEveryone      = "everyone"
+	VisibilityFollowersOnly = "followers_only"
+	VisibilityOnlyMe        = "only_me"
+)
+
+// IsHiddenFromOthers reports whether the owner has made the activity private on Strava
+func (a *Activity) IsHiddenFromOthers() bool {
+	return a.Private || a.Visibility == VisibilityOnlyMe
+}
+
 func (a *Activity) IsHG() bool {
 	return strings.Contains(strings.ToLower(a.Name), "#hg")
 }
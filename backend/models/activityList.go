@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+type ActivityListSort string
+
+const (
+	ActivityListSortDate       ActivityListSort = "date" // Default
+	ActivityListSortDistance   ActivityListSort = "distance"
+	ActivityListSortElevation  ActivityListSort = "elevation"
+	ActivityListSortMovingTime ActivityListSort = "moving_time"
+)
+
+// ActivityCursor is where a page ends: the sort value and id of its last
+// activity. The next page starts after it.
+type ActivityCursor struct {
+	Sort  ActivityListSort
+	Date  time.Time // For date sorts
+	Value float64   // For the other sorts
+	ID    int64
+}
+
+// ActivityListFilter holds the listing options. Nil and empty fields are not
+// filtered on.
+type ActivityListFilter struct {
+	UserID       int64
+	StartDate    *time.Time // Inclusive
+	EndDate      *time.Time // Exclusive
+	Types        []string
+	SportTypes   []string
+	HasSummit    *bool
+	PeakID       *int64 // Only activities that summited this peak
+	MinDistance  *float64
+	MinElevation *float64
+	Tags         []string // Hashtags in the name or description, e.g. "hg" for #hg
+	Query        string   // Full-text search on name and description
+	Sort         ActivityListSort
+	Descending   bool
+	Limit        int
+	Cursor       *ActivityCursor
+}
+
+type ActivityListPage struct {
+	Activities []Activity `json:"activities"`
+	NextCursor string     `json:"next_cursor,omitempty"` // Empty on the last page
+	Limit      int        `json:"limit"`
+}
+
+// ActivitySummit is a peak summited during an activity
+type ActivitySummit struct {
+	PeakID          int64      `json:"peak_id"`
+	Name            string     `json:"name"`
+	Latitude        float64    `json:"latitude"`
+	Longitude       float64    `json:"longitude"`
+	ElevationMeters float64    `json:"elevation_meters"`
+	Region          string     `json:"region"`
+	SummitedAt      time.Time  `json:"summited_at"`
+	SummitTime      *time.Time `json:"summit_time,omitempty"`
+	ElapsedSeconds  *int       `json:"elapsed_seconds,omitempty"`
+}
+
+// ActivityDetail is one activity with its summits and decoded route
+type ActivityDetail struct {
+	Activity
+	Summits []ActivitySummit `json:"summits"`
+	Route   [][2]float64     `json:"route"` // [lat, lon] points, empty when there's no route
+}
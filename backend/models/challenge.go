@@ -36,6 +36,18 @@ const (
 	GoalTypeElevation       GoalType = "elevation"        // Total elevation gain (m)
 	GoalTypeSummitCount     GoalType = "summit_count"     // Number of summits
 	GoalTypeSpecificSummits GoalType = "specific_summits" // Specific list of peaks
+	GoalTypeFastestTime     GoalType = "fastest_time"     // Quickest time over the challenge peaks
+	GoalTypeStreak          GoalType = "streak"           // Consecutive days/weeks of activity or summits
+)
+
+// CompletionRule adds constraints on how specific_summits peaks must be bagged
+type CompletionRule string
+
+const (
+	CompletionRuleAny            CompletionRule = "any"             // Any summit of a listed peak counts
+	CompletionRuleOrdered        CompletionRule = "ordered"         // Peaks must be summited in sort order
+	CompletionRuleSingleActivity CompletionRule = "single_activity" // All peaks within one activity
+	CompletionRuleTimeWindow     CompletionRule = "time_window"     // All peaks within MaxWindowHours
 )
 
 // Challenge represents a summit challenge
@@ -51,13 +63,16 @@ type Challenge struct {
 	Deadline           *time.Time      `json:"deadline" db:"deadline"`
 	CreatedByUserID    *int64          `json:"createdByUserId" db:"created_by_user_id"`
 	CreatedByGroupID   *int64          `json:"createdByGroupId" db:"created_by_group_id"`
-	TargetValue        *float64        `json:"targetValue" db:"target_value"`             // For distance/elevation (in meters)
+	TargetValue        *float64        `json:"targetValue" db:"target_value"`             // For distance/elevation (in meters), fastest_time (optional cut-off in seconds), streak (periods)
 	TargetSummitCount  *int            `json:"targetSummitCount" db:"target_summit_count"` // For summit_count
 	Region             *string         `json:"region" db:"region"`
 	Difficulty         *string         `json:"difficulty" db:"difficulty"`
 	IsFeatured         bool            `json:"isFeatured" db:"is_featured"`
 	JoinCode           string          `json:"joinCode" db:"join_code"`
 	IsLocked           bool            `json:"isLocked" db:"is_locked"`
+	CompletionRule     CompletionRule  `json:"completionRule" db:"completion_rule"`   // For specific_summits
+	MaxWindowHours     *float64        `json:"maxWindowHours" db:"max_window_hours"` // For time_window rule
+	StreakType         *StreakType     `json:"streakType" db:"streak_type"`          // For streak
 	CreatedAt          time.Time       `json:"createdAt" db:"created_at"`
 	UpdatedAt          time.Time       `json:"updatedAt" db:"updated_at"`
 }
@@ -74,6 +89,11 @@ type ChallengeWithProgress struct {
 	CurrentElevation float64 `json:"currentElevation"`
 	// For summit_count goal type
 	CurrentSummitCount int `json:"currentSummitCount"`
+	// For fastest_time goal type (seconds)
+	BestTimeSeconds *int `json:"bestTimeSeconds"`
+	// For streak goal type
+	CurrentStreak int `json:"currentStreak"`
+	LongestStreak int `json:"longestStreak"`
 	// Metadata
 	IsJoined    bool `json:"isJoined"`
 	IsCompleted bool `json:"isCompleted"`
@@ -112,6 +132,15 @@ type ChallengeParticipant struct {
 	TotalDistance    float64    `json:"totalDistance" db:"total_distance"`       // For distance (in meters)
 	TotalElevation   float64    `json:"totalElevation" db:"total_elevation"`     // For elevation (in meters)
 	TotalSummitCount int        `json:"totalSummitCount" db:"total_summit_count"` // For summit_count
+	// Set on completion: the activity (single_activity) or window (ordered/time_window) that satisfied the challenge
+	SatisfiedByActivityID *int64     `json:"satisfiedByActivityId" db:"satisfied_by_activity_id"`
+	SatisfiedWindowStart  *time.Time `json:"satisfiedWindowStart" db:"satisfied_window_start"`
+	SatisfiedWindowEnd    *time.Time `json:"satisfiedWindowEnd" db:"satisfied_window_end"`
+	// For fastest_time: the quickest qualifying time (seconds)
+	BestTimeSeconds *int `json:"bestTimeSeconds" db:"best_time_seconds"`
+	// For streak: consecutive periods within the challenge dates
+	CurrentStreak int `json:"currentStreak" db:"current_streak"`
+	LongestStreak int `json:"longestStreak" db:"longest_streak"`
 }
 
 // ChallengeParticipantWithUser includes user information
@@ -169,11 +198,22 @@ type LeaderboardEntry struct {
 	TotalDistance   float64    `json:"totalDistance"`
 	TotalElevation  float64    `json:"totalElevation"`
 	TotalSummitCount int       `json:"totalSummitCount"`
+	BestTimeSeconds *int       `json:"bestTimeSeconds"` // For fastest_time
+	CurrentStreak   int        `json:"currentStreak"`   // For streak
+	LongestStreak   int        `json:"longestStreak"`   // For streak
 	Progress        float64    `json:"progress"` // Percentage 0-100
 	JoinedAt        time.Time  `json:"joinedAt"`
 	CompletedAt     *time.Time `json:"completedAt"`
 }
 
+// ChallengeStanding is a participant's place on a challenge leaderboard.
+// Unlike Rank, Position has no ties.
+type ChallengeStanding struct {
+	Position int
+	UserID   int64
+	UserName string // Empty when hidden from leaderboards
+}
+
 // ProposalStatus represents the review status of a challenge proposal
 type ProposalStatus string
 
@@ -191,7 +231,7 @@ type ChallengeProposal struct {
 	Description        *string         `json:"description" db:"description"`
 	GoalType           GoalType        `json:"goalType" db:"goal_type"`
 	CompetitionMode    CompetitionMode `json:"competitionMode" db:"competition_mode"`
-	TargetValue        *float64        `json:"targetValue" db:"target_value"`             // For distance/elevation (in meters)
+	TargetValue        *float64        `json:"targetValue" db:"target_value"`             // For distance/elevation (in meters), fastest_time (optional cut-off in seconds)
 	TargetSummitCount  *int            `json:"targetSummitCount" db:"target_summit_count"` // For summit_count
 	PeakIDs            []int64         `json:"peakIds" db:"peak_ids"`                      // For specific_summits
 	Region             *string         `json:"region" db:"region"`
@@ -0,0 +1,75 @@
+package models
+
+import "time"
+
+// Recurrence determines how often a challenge series creates a new instance
+type Recurrence string
+
+const (
+	RecurrenceWeekly  Recurrence = "weekly"
+	RecurrenceMonthly Recurrence = "monthly"
+	RecurrenceYearly  Recurrence = "yearly"
+	RecurrenceCustom  Recurrence = "custom" // Every IntervalDays days
+)
+
+// ChallengeSeries is a challenge template plus a recurrence rule
+type ChallengeSeries struct {
+	ID                    int64           `json:"id" db:"id"`
+	Name                  string          `json:"name" db:"name"`
+	Description           *string         `json:"description" db:"description"`
+	CreatedByUserID       *int64          `json:"createdByUserId" db:"created_by_user_id"`
+	CreatedByGroupID      *int64          `json:"createdByGroupId" db:"created_by_group_id"`
+	GoalType              GoalType        `json:"goalType" db:"goal_type"`
+	CompetitionMode       CompetitionMode `json:"competitionMode" db:"competition_mode"`
+	Visibility            Visibility      `json:"visibility" db:"visibility"`
+	TargetValue           *float64        `json:"targetValue" db:"target_value"`              // For distance/elevation (in meters)
+	TargetSummitCount     *int            `json:"targetSummitCount" db:"target_summit_count"` // For summit_count
+	PeakIDs               []int64         `json:"peakIds" db:"peak_ids"`                      // For specific_summits
+	Region                *string         `json:"region" db:"region"`
+	Difficulty            *string         `json:"difficulty" db:"difficulty"`
+	CompletionRule        CompletionRule  `json:"completionRule" db:"completion_rule"`
+	MaxWindowHours        *float64        `json:"maxWindowHours" db:"max_window_hours"`
+	StreakType            *StreakType     `json:"streakType" db:"streak_type"` // For streak
+	Recurrence            Recurrence      `json:"recurrence" db:"recurrence"`
+	IntervalDays          *int            `json:"intervalDays" db:"interval_days"` // For custom recurrence
+	StartsAt              time.Time       `json:"startsAt" db:"starts_at"`
+	EndsAt                *time.Time      `json:"endsAt" db:"ends_at"`
+	NextPeriodStart       time.Time       `json:"nextPeriodStart" db:"next_period_start"`
+	CarryOverParticipants bool            `json:"carryOverParticipants" db:"carry_over_participants"`
+	IsActive              bool            `json:"isActive" db:"is_active"`
+	CreatedAt             time.Time       `json:"createdAt" db:"created_at"`
+	UpdatedAt             time.Time       `json:"updatedAt" db:"updated_at"`
+}
+
+// ChallengeSeriesInstance links a challenge to the series period it was created for
+type ChallengeSeriesInstance struct {
+	ID             int64     `json:"id" db:"id"`
+	SeriesID       int64     `json:"seriesId" db:"series_id"`
+	ChallengeID    int64     `json:"challengeId" db:"challenge_id"`
+	InstanceNumber int       `json:"instanceNumber" db:"instance_number"`
+	PeriodStart    time.Time `json:"periodStart" db:"period_start"`
+	PeriodEnd      time.Time `json:"periodEnd" db:"period_end"`
+	CreatedAt      time.Time `json:"createdAt" db:"created_at"`
+}
+
+// ChallengeSeriesInstanceWithStats is a series history row
+type ChallengeSeriesInstanceWithStats struct {
+	ChallengeSeriesInstance
+	ChallengeName    string `json:"challengeName" db:"challenge_name"`
+	ParticipantCount int    `json:"participantCount" db:"participant_count"`
+	CompletedCount   int    `json:"completedCount" db:"completed_count"`
+}
+
+// SeriesLeaderboardEntry aggregates a user's results across every instance of a series
+type SeriesLeaderboardEntry struct {
+	Rank               int     `json:"rank"`
+	UserID             int64   `json:"userId"`
+	UserName           string  `json:"userName"`
+	StravaAthleteID    int64   `json:"stravaAthleteId"`
+	InstancesJoined    int     `json:"instancesJoined"`
+	InstancesCompleted int     `json:"instancesCompleted"`
+	PeaksCompleted     int     `json:"peaksCompleted"`
+	TotalDistance      float64 `json:"totalDistance"`
+	TotalElevation     float64 `json:"totalElevation"`
+	TotalSummitCount   int     `json:"totalSummitCount"`
+}
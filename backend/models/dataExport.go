@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+type DataExportStatus string
+
+const (
+	DataExportStatusPending DataExportStatus = "pending"
+	DataExportStatusRunning DataExportStatus = "running"
+	DataExportStatusReady   DataExportStatus = "ready"
+	DataExportStatusFailed  DataExportStatus = "failed"
+	DataExportStatusExpired DataExportStatus = "expired" // The file has been deleted
+)
+
+// DataExport is a request for a ZIP of everything we hold on a user
+type DataExport struct {
+	ID          int64            `json:"id"`
+	UserID      int64            `json:"user_id"`
+	Status      DataExportStatus `json:"status"`
+	Token       string           `json:"-"`
+	FilePath    string           `json:"-"`
+	SizeBytes   *int64           `json:"size_bytes,omitempty"`
+	Error       *string          `json:"error,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+	StartedAt   *time.Time       `json:"started_at,omitempty"`
+	CompletedAt *time.Time       `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time       `json:"expires_at,omitempty"`
+	DownloadURL string           `json:"download_url,omitempty"` // Set while the export is ready
+}
+
+// DataExportProfile is the user's profile as exported, without Strava tokens
+type DataExportProfile struct {
+	ID                   int64     `json:"id"`
+	StravaAthleteID      int64     `json:"strava_athlete_id"`
+	Username             string    `json:"username,omitempty"`
+	Email                string    `json:"email,omitempty"`
+	IsAdmin              bool      `json:"is_admin"`
+	Timezone             string    `json:"timezone"`
+	WeekStart            int       `json:"week_start"`
+	ShowInLeaderboards   bool      `json:"show_in_leaderboards"`
+	ShowInGroupFeeds     bool      `json:"show_in_group_feeds"`
+	ShowInChallengeFeeds bool      `json:"show_in_challenge_feeds"`
+	CreatedAt            time.Time `json:"created_at"`
+}
+
+// UserSummit is one of the user's summits with the peak and activity it
+// was on
+type UserSummit struct {
+	ActivitySummit
+	ActivityID int64  `json:"activity_id"`
+	Source     string `json:"source"` // detected or claimed
+}
@@ -0,0 +1,31 @@
+package models
+
+// ElevationProfilePoint is one point of an activity's route with its DEM
+// elevation
+type ElevationProfilePoint struct {
+	DistanceMeters  float64 `json:"distance_meters"`
+	Latitude        float64 `json:"latitude"`
+	Longitude       float64 `json:"longitude"`
+	ElevationMeters float64 `json:"elevation_meters"`
+}
+
+// ElevationProfile is an activity's elevation along its polyline, computed
+// from local DEM tiles. Points outside tile coverage are left out.
+type ElevationProfile struct {
+	ActivityID     int64                   `json:"activity_id"`
+	Source         string                  `json:"source"`
+	DistanceMeters float64                 `json:"distance_meters"`
+	TotalAscent    float64                 `json:"total_ascent"`
+	TotalDescent   float64                 `json:"total_descent"`
+	MinElevation   float64                 `json:"min_elevation"`
+	MaxElevation   float64                 `json:"max_elevation"`
+	Coverage       float64                 `json:"coverage"` // Share of route points with an elevation, 0-1
+	Points         []ElevationProfilePoint `json:"points"`
+}
+
+type PeakElevationFillResult struct {
+	PeaksChecked int `json:"peaks_checked"`
+	Filled       int `json:"filled"`    // Had no elevation
+	Corrected    int `json:"corrected"` // Had an implausible elevation
+	NoCoverage   int `json:"no_coverage"`
+}
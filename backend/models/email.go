@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+type EmailKind string
+
+const (
+	EmailKindVerification       EmailKind = "verification"
+	EmailKindWeeklyDigest       EmailKind = "weekly_digest"
+	EmailKindChallengeEnding    EmailKind = "challenge_ending"
+	EmailKindChallengeCompleted EmailKind = "challenge_completed"
+	EmailKindGroupInvite        EmailKind = "group_invite"
+)
+
+// EmailKinds lists the emails a user can opt in to. Verification emails
+// are always sent.
+var EmailKinds = []EmailKind{
+	EmailKindWeeklyDigest,
+	EmailKindChallengeEnding,
+	EmailKindChallengeCompleted,
+	EmailKindGroupInvite,
+}
+
+// EmailPreferences turns kinds of email on or off. Kinds left out are off.
+type EmailPreferences map[EmailKind]bool
+
+func (p EmailPreferences) Enabled(kind EmailKind) bool {
+	return kind == EmailKindVerification || p[kind]
+}
+
+// EmailSettings is the user's address and which emails they get
+type EmailSettings struct {
+	UserID           int64            `json:"-"`
+	Name             string           `json:"-"`
+	Email            string           `json:"email"`
+	Verified         bool             `json:"verified"`
+	VerifiedAt       *time.Time       `json:"verified_at,omitempty"`
+	Preferences      EmailPreferences `json:"preferences"`
+	UnsubscribeToken string           `json:"-"`
+	Timezone         string           `json:"-"`
+	WeekStart        int              `json:"-"`
+}
+
+type EmailSendStatus string
+
+const (
+	EmailSendStatusPending EmailSendStatus = "pending"
+	EmailSendStatusSending EmailSendStatus = "sending"
+	EmailSendStatusSent    EmailSendStatus = "sent"
+	EmailSendStatusFailed  EmailSendStatus = "failed"
+)
+
+// EmailSend is a queued or sent email, kept as send history
+type EmailSend struct {
+	ID        int64           `json:"id"`
+	UserID    int64           `json:"user_id"`
+	Kind      EmailKind       `json:"kind"`
+	Recipient string          `json:"recipient"`
+	Subject   string          `json:"subject"`
+	TextBody  string          `json:"-"`
+	HTMLBody  string          `json:"-"`
+	Status    EmailSendStatus `json:"status"`
+	Error     *string         `json:"error,omitempty"`
+	Attempts  int             `json:"attempts"`
+	DedupeKey string          `json:"-"`
+	CreatedAt time.Time       `json:"created_at"`
+	SentAt    *time.Time      `json:"sent_at,omitempty"`
+}
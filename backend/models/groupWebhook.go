@@ -0,0 +1,91 @@
+package models
+
+import "time"
+
+type GroupWebhookEvent string
+
+const (
+	GroupWebhookEventSummitDetected     GroupWebhookEvent = "summit_detected"
+	GroupWebhookEventChallengeJoined    GroupWebhookEvent = "challenge_joined"
+	GroupWebhookEventChallengeCompleted GroupWebhookEvent = "challenge_completed"
+	GroupWebhookEventLeaderboardChanged GroupWebhookEvent = "leaderboard_changed"
+)
+
+// GroupWebhookEvents lists every event a webhook can subscribe to
+var GroupWebhookEvents = []GroupWebhookEvent{
+	GroupWebhookEventSummitDetected,
+	GroupWebhookEventChallengeJoined,
+	GroupWebhookEventChallengeCompleted,
+	GroupWebhookEventLeaderboardChanged,
+}
+
+// GroupWebhookFormat is the shape of the request body
+type GroupWebhookFormat string
+
+const (
+	GroupWebhookFormatGeneric GroupWebhookFormat = "generic" // GroupWebhookPayload as JSON
+	GroupWebhookFormatSlack   GroupWebhookFormat = "slack"   // Slack incoming webhook message
+	GroupWebhookFormatDiscord GroupWebhookFormat = "discord" // Discord webhook message
+)
+
+// GroupWebhook posts a group's events to a URL, e.g. a chat app's incoming
+// webhook
+type GroupWebhook struct {
+	ID        int64               `json:"id"`
+	GroupID   int64               `json:"group_id"`
+	GroupName string              `json:"group_name,omitempty"`
+	URL       string              `json:"url"`
+	Format    GroupWebhookFormat  `json:"format"`
+	Events    []GroupWebhookEvent `json:"events"`
+	Secret    string              `json:"secret"` // Signs every request, only shown to group admins
+	Enabled   bool                `json:"enabled"`
+	CreatedBy *int64              `json:"created_by,omitempty"`
+	CreatedAt time.Time           `json:"created_at"`
+	UpdatedAt time.Time           `json:"updated_at"`
+}
+
+// GroupWebhookPayload is the body of generic webhooks. Only the fields that
+// apply to the event are set.
+type GroupWebhookPayload struct {
+	Event               GroupWebhookEvent `json:"event"`
+	OccurredAt          time.Time         `json:"occurred_at"`
+	GroupID             int64             `json:"group_id"`
+	GroupName           string            `json:"group_name"`
+	Text                string            `json:"text"` // The message the chat formats post
+	UserID              int64             `json:"user_id"`
+	UserName            string            `json:"user_name"`
+	PeakID              *int64            `json:"peak_id,omitempty"`
+	PeakName            string            `json:"peak_name,omitempty"`
+	PeakElevationMeters *float64          `json:"peak_elevation_meters,omitempty"`
+	ActivityID          *int64            `json:"activity_id,omitempty"`
+	ChallengeID         *int64            `json:"challenge_id,omitempty"`
+	ChallengeName       string            `json:"challenge_name,omitempty"`
+	Position            *int              `json:"position,omitempty"`
+	PreviousPosition    *int              `json:"previous_position,omitempty"`
+}
+
+type GroupWebhookDeliveryStatus string
+
+const (
+	GroupWebhookDeliveryStatusPending   GroupWebhookDeliveryStatus = "pending"
+	GroupWebhookDeliveryStatusSending   GroupWebhookDeliveryStatus = "sending"
+	GroupWebhookDeliveryStatusDelivered GroupWebhookDeliveryStatus = "delivered"
+	GroupWebhookDeliveryStatusFailed    GroupWebhookDeliveryStatus = "failed"
+)
+
+// GroupWebhookDelivery is a request queued or sent to a webhook, kept as its
+// delivery log
+type GroupWebhookDelivery struct {
+	ID             int64                      `json:"id"`
+	WebhookID      int64                      `json:"webhook_id"`
+	Event          GroupWebhookEvent          `json:"event"`
+	Payload        string                     `json:"payload"`
+	Status         GroupWebhookDeliveryStatus `json:"status"`
+	Attempts       int                        `json:"attempts"`
+	ResponseStatus *int                       `json:"response_status,omitempty"`
+	Error          *string                    `json:"error,omitempty"`
+	DedupeKey      string                     `json:"-"`
+	NextAttemptAt  *time.Time                 `json:"next_attempt_at,omitempty"` // Set while a retry is pending
+	CreatedAt      time.Time                  `json:"created_at"`
+	DeliveredAt    *time.Time                 `json:"delivered_at,omitempty"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// HeatmapFilter selects which routes a heatmap tile draws. With no GroupID the
+// heatmap is the user's own; with one it covers every member of the group.
+type HeatmapFilter struct {
+	UserID    int64
+	GroupID   *int64
+	StartDate *time.Time
+	EndDate   *time.Time
+	Types     []string // activity or sport types, any of which match
+}
@@ -0,0 +1,54 @@
+package models
+
+import "fmt"
+
+type LiveEventType string
+
+const (
+	LiveEventProgress     LiveEventType = "progress"     // Someone's progress changed, e.g. a new activity
+	LiveEventSummit       LiveEventType = "summit"       // A summit was logged or taken back, so the feed changed
+	LiveEventParticipants LiveEventType = "participants" // Someone joined or left
+)
+
+// LiveEventTypes is the order a batch of events is sent to subscribers in
+var LiveEventTypes = []LiveEventType{
+	LiveEventParticipants,
+	LiveEventSummit,
+	LiveEventProgress,
+}
+
+// LiveEvent says something on a challenge or group page changed. Only one of
+// the ids is set. It carries no user data, so subscribers only see what
+// they're allowed to when their update is built.
+type LiveEvent struct {
+	Type        LiveEventType `json:"type"`
+	ChallengeID *int64        `json:"challenge_id,omitempty"`
+	GroupID     *int64        `json:"group_id,omitempty"`
+}
+
+// Topic is what subscribers to the event's challenge or group listen on
+func (e LiveEvent) Topic() string {
+	if e.ChallengeID != nil {
+		return ChallengeTopic(*e.ChallengeID)
+	}
+	if e.GroupID != nil {
+		return GroupTopic(*e.GroupID)
+	}
+	return ""
+}
+
+func ChallengeTopic(challengeID int64) string {
+	return fmt.Sprintf("challenge:%d", challengeID)
+}
+
+func GroupTopic(groupID int64) string {
+	return fmt.Sprintf("group:%d", groupID)
+}
+
+// LiveUpdate is an event as sent to a subscriber. Challenge progress and
+// participant updates include the leaderboard as the subscriber sees it,
+// the rest tell the client what to refetch.
+type LiveUpdate struct {
+	LiveEvent
+	Leaderboard []LeaderboardEntry `json:"leaderboard,omitempty"`
+}
@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// BoundingBox is a lat/lon rectangle used to query map features
+type BoundingBox struct {
+	MinLat float64
+	MaxLat float64
+	MinLon float64
+	MaxLon float64
+}
+
+// Intersects reports whether two boxes overlap
+func (b BoundingBox) Intersects(other BoundingBox) bool {
+	return b.MinLat <= other.MaxLat && b.MaxLat >= other.MinLat &&
+		b.MinLon <= other.MaxLon && b.MaxLon >= other.MinLon
+}
+
+// MapPeak is a peak on the map, flagged with whether the current user has summited it
+type MapPeak struct {
+	ID              int64
+	Name            string
+	Latitude        float64
+	Longitude       float64
+	ElevationMeters float64
+	Summited        bool
+}
+
+// MapSummit is a peak the user has summited, with their summit history
+type MapSummit struct {
+	PeakID          int64
+	Name            string
+	Latitude        float64
+	Longitude       float64
+	ElevationMeters float64
+	SummitCount     int
+	FirstSummitedAt time.Time
+	LastSummitedAt  time.Time
+}
+
+// GeoJSONFeatureCollection is a GeoJSON (RFC 7946) FeatureCollection
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+}
+
+type GeoJSONFeature struct {
+	Type       string                 `json:"type"`
+	ID         int64                  `json:"id"`
+	Geometry   GeoJSONGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// GeoJSONGeometry holds a Point ([lon, lat]) or LineString ([[lon, lat], ...])
+type GeoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+func NewGeoJSONFeatureCollection() GeoJSONFeatureCollection {
+	return GeoJSONFeatureCollection{Type: "FeatureCollection", Features: []GeoJSONFeature{}}
+}
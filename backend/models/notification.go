@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+type NotificationType string
+
+const (
+	NotificationTypeSummitDetected     NotificationType = "summit_detected"
+	NotificationTypeChallengeEnding    NotificationType = "challenge_ending"
+	NotificationTypeChallengeCompleted NotificationType = "challenge_completed"
+	NotificationTypeChallengeOvertaken NotificationType = "challenge_overtaken"
+	NotificationTypeGroupAdded         NotificationType = "group_added"
+)
+
+// NotificationTypes lists every type a user can turn on or off
+var NotificationTypes = []NotificationType{
+	NotificationTypeSummitDetected,
+	NotificationTypeChallengeEnding,
+	NotificationTypeChallengeCompleted,
+	NotificationTypeChallengeOvertaken,
+	NotificationTypeGroupAdded,
+}
+
+// Notification is an entry in a user's inbox. Only the ids that apply to
+// its type are set.
+type Notification struct {
+	ID          int64            `json:"id"`
+	UserID      int64            `json:"user_id"`
+	Type        NotificationType `json:"type"`
+	Title       string           `json:"title"`
+	Body        string           `json:"body,omitempty"`
+	PeakID      *int64           `json:"peak_id,omitempty"`
+	ActivityID  *int64           `json:"activity_id,omitempty"`
+	ChallengeID *int64           `json:"challenge_id,omitempty"`
+	GroupID     *int64           `json:"group_id,omitempty"`
+	DedupeKey   string           `json:"-"` // Empty if the event can happen more than once
+	Read        bool             `json:"read"`
+	ReadAt      *time.Time       `json:"read_at,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+}
+
+type NotificationPage struct {
+	Notifications []Notification `json:"notifications"`
+	UnreadCount   int            `json:"unread_count"`
+	NextBefore    *int64         `json:"next_before,omitempty"` // Pass as before for the next page, unset on the last
+	Limit         int            `json:"limit"`
+}
+
+// NotificationPreferences turns notification types on or off. Types left
+// out are on.
+type NotificationPreferences map[NotificationType]bool
+
+func (p NotificationPreferences) Enabled(t NotificationType) bool {
+	enabled, ok := p[t]
+	return !ok || enabled
+}
@@ -1,5 +1,16 @@
 package models
 
+const (
+	ElevationSourceOSM  = "osm"  // The peak's ele tag
+	ElevationSourceDEM  = "dem"  // Filled or corrected from local DEM tiles
+	ElevationSourceUser = "user" // From an approved peak submission
+)
+
+const (
+	PeakSourceOSM   = "osm"   // Imported from OpenStreetMap
+	PeakSourceLocal = "local" // Added through an approved peak submission
+)
+
 type Peak struct {
 	ID              int64   `json:"id"`
 	OsmID           int64   `json:"osm_id"`
@@ -15,4 +26,10 @@ type Peak struct {
 	Wikidata    string `json:"wikidata"`      // Wikidata ID for more info
 	Description string `json:"description"`   // From description tag
 	Prominence  float64 `json:"prominence"`   // From prominence tag if available
+	// "osm", "dem" or "user", empty when the elevation is unknown
+	ElevationSource string `json:"elevation_source,omitempty"`
+	// "osm" or "local"
+	Source string `json:"source"`
+	// Set when this peak was merged into another as a duplicate
+	MergedIntoID *int64 `json:"merged_into_id,omitempty"`
 }
@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// PeakSummitEvent is one summit in the app. The user is left out when they
+// have opted out of community stats.
+type PeakSummitEvent struct {
+	SummitedAt time.Time `json:"summited_at"`
+	UserID     *int64    `json:"user_id,omitempty"`
+	UserName   *string   `json:"user_name,omitempty"`
+}
+
+// PeakSummiteer is a user ranked by how often they've summited a peak
+type PeakSummiteer struct {
+	UserID          int64     `json:"user_id"`
+	UserName        string    `json:"user_name"`
+	StravaAthleteID int64     `json:"strava_athlete_id"`
+	SummitCount     int       `json:"summit_count"`
+	LastSummitedAt  time.Time `json:"last_summited_at"`
+}
+
+// PeakChallengeRef is a challenge that includes a peak
+type PeakChallengeRef struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	GoalType   GoalType   `json:"goal_type"`
+	Visibility Visibility `json:"visibility"`
+	StartDate  time.Time  `json:"start_date"`
+	Deadline   *time.Time `json:"deadline,omitempty"`
+}
+
+// PeakMySummit is one of the requesting user's summits of a peak
+type PeakMySummit struct {
+	ActivityID     int64     `json:"activity_id"`
+	ActivityName   string    `json:"activity_name"`
+	SummitedAt     time.Time `json:"summited_at"`
+	ElapsedSeconds *int      `json:"elapsed_seconds,omitempty"`
+}
+
+// PeakCommunityStats are the aggregates shared by every viewer of a peak
+type PeakCommunityStats struct {
+	TotalSummits     int              `json:"total_summits"`
+	UniqueSummiteers int              `json:"unique_summiteers"`
+	FirstSummit      *PeakSummitEvent `json:"first_summit,omitempty"`
+	LatestSummit     *PeakSummitEvent `json:"latest_summit,omitempty"`
+	SummitsByMonth   [12]int          `json:"summits_by_month"` // January first
+	TopSummiteers    []PeakSummiteer  `json:"top_summiteers"`
+	ComputedAt       time.Time        `json:"computed_at"`
+}
+
+type PeakDetail struct {
+	Peak
+	Stats      PeakCommunityStats `json:"stats"`
+	MySummits  []PeakMySummit     `json:"my_summits"`
+	Challenges []PeakChallengeRef `json:"challenges"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// PeakList is a reusable, ordered set of peaks. Curated lists are made by admins.
+type PeakList struct {
+	ID              int64      `json:"id"`
+	Name            string     `json:"name"`
+	Description     *string    `json:"description,omitempty"`
+	Region          *string    `json:"region,omitempty"`
+	Visibility      Visibility `json:"visibility"` // private or public
+	IsCurated       bool       `json:"isCurated"`
+	CreatedByUserID *int64     `json:"createdByUserId,omitempty"`
+	PeakCount       int        `json:"peakCount"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
+}
+
+// PeakListProgress is how much of a list a user has summited, all time
+type PeakListProgress struct {
+	PeaksCompleted int        `json:"peaksCompleted"`
+	TotalPeaks     int        `json:"totalPeaks"`
+	Percent        float64    `json:"percent"`
+	IsComplete     bool       `json:"isComplete"`
+	CompletedAt    *time.Time `json:"completedAt,omitempty"` // When the last peak was first summited
+}
+
+type PeakListWithProgress struct {
+	PeakList
+	Progress PeakListProgress `json:"progress"`
+}
+
+// PeakListPeak is a peak on a list with the user's summits of it
+type PeakListPeak struct {
+	Peak
+	SortOrder       int        `json:"sortOrder"`
+	IsSummited      bool       `json:"isSummited"`
+	FirstSummitedAt *time.Time `json:"firstSummitedAt,omitempty"`
+	SummitCount     int        `json:"summitCount"`
+}
+
+type PeakListDetail struct {
+	PeakList
+	Peaks    []PeakListPeak   `json:"peaks"`
+	Progress PeakListProgress `json:"progress"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+type PeakMergeStatus string
+
+const (
+	PeakMergeStatusPending  PeakMergeStatus = "pending"
+	PeakMergeStatusMerged   PeakMergeStatus = "merged"
+	PeakMergeStatusRejected PeakMergeStatus = "rejected"
+)
+
+// PeakMergeProposal is a cluster of peaks that look like the same summit,
+// waiting for an admin to confirm or reject the merge
+type PeakMergeProposal struct {
+	ID              int64           `json:"id"`
+	CanonicalPeakID int64           `json:"canonical_peak_id"`
+	PeakIDs         []int64         `json:"peak_ids"` // Every peak in the cluster, canonical included
+	SpanMeters      float64         `json:"span_meters"`
+	Status          PeakMergeStatus `json:"status"`
+	CreatedAt       time.Time       `json:"created_at"`
+	ResolvedAt      *time.Time      `json:"resolved_at,omitempty"`
+}
+
+type PeakMergeProposalWithPeaks struct {
+	PeakMergeProposal
+	Peaks []Peak `json:"peaks"`
+}
+
+type PeakMergeScanResult struct {
+	DistanceMeters float64 `json:"distance_meters"`
+	PeaksScanned   int     `json:"peaks_scanned"`
+	Proposals      int     `json:"proposals"`
+}
+
+type PeakMergeResult struct {
+	ProposalID          int64   `json:"proposal_id"`
+	CanonicalPeakID     int64   `json:"canonical_peak_id"`
+	MergedPeakIDs       []int64 `json:"merged_peak_ids"`
+	ChallengesRefreshed int     `json:"challenges_refreshed"`
+}
@@ -0,0 +1,52 @@
+package models
+
+type PeakSearchSort string
+
+const (
+	PeakSearchSortRelevance  PeakSearchSort = "relevance" // Default when there is a query
+	PeakSearchSortName       PeakSearchSort = "name"      // Default otherwise
+	PeakSearchSortElevation  PeakSearchSort = "elevation"
+	PeakSearchSortProminence PeakSearchSort = "prominence"
+	PeakSearchSortDistance   PeakSearchSort = "distance" // Needs a near location
+)
+
+type PeakSummitedBy string
+
+const (
+	PeakSummitedByMe    PeakSummitedBy = "me"
+	PeakSummitedByGroup PeakSummitedBy = "group"
+)
+
+// PeakSearchFilter holds the search options. Nil fields are not filtered on.
+type PeakSearchFilter struct {
+	UserID        int64
+	Query         string
+	Region        *string
+	MinElevation  *float64
+	MaxElevation  *float64
+	MinProminence *float64
+	Summited      *bool          // Only peaks that have (true) or haven't (false) been summited by SummitedBy
+	SummitedBy    PeakSummitedBy // me (default) or group
+	GroupID       *int64         // Required when SummitedBy is group
+	NearLat       *float64
+	NearLon       *float64
+	RadiusMeters  float64 // Used with NearLat/NearLon
+	Sort          PeakSearchSort
+	Descending    bool
+	Limit         int
+	Offset        int
+}
+
+type PeakSearchResult struct {
+	Peak
+	IsSummited     bool     `json:"is_summited"` // By the searching user
+	Score          float64  `json:"score"`       // Name match, 0-1
+	DistanceMeters *float64 `json:"distance_meters,omitempty"`
+}
+
+type PeakSearchPage struct {
+	Results []PeakSearchResult `json:"results"`
+	Total   int                `json:"total"`
+	Limit   int                `json:"limit"`
+	Offset  int                `json:"offset"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+type PeakSubmissionKind string
+
+const (
+	PeakSubmissionKindNew        PeakSubmissionKind = "new"
+	PeakSubmissionKindCorrection PeakSubmissionKind = "correction"
+)
+
+type PeakSubmissionStatus string
+
+const (
+	PeakSubmissionStatusPending  PeakSubmissionStatus = "pending"
+	PeakSubmissionStatusApproved PeakSubmissionStatus = "approved"
+	PeakSubmissionStatusRejected PeakSubmissionStatus = "rejected"
+)
+
+// Peak fields an approved correction locks against the Overpass refresh
+const (
+	PeakLockedName      = "name"
+	PeakLockedPosition  = "position"
+	PeakLockedElevation = "elevation"
+)
+
+// PeakSubmission is a user's proposed new peak, or a correction to an
+// existing one. For corrections nil values are left unchanged.
+type PeakSubmission struct {
+	ID               int64                `json:"id"`
+	UserID           *int64               `json:"user_id,omitempty"`
+	SubmitterName    string               `json:"submitter_name,omitempty"`
+	Kind             PeakSubmissionKind   `json:"kind"`
+	PeakID           *int64               `json:"peak_id,omitempty"` // Peak being corrected, or the peak created on approval
+	Name             *string              `json:"name,omitempty"`
+	Latitude         *float64             `json:"latitude,omitempty"`
+	Longitude        *float64             `json:"longitude,omitempty"`
+	ElevationMeters  *float64             `json:"elevation_meters,omitempty"`
+	Note             *string              `json:"note,omitempty"`
+	Status           PeakSubmissionStatus `json:"status"`
+	ReviewerNote     *string              `json:"reviewer_note,omitempty"`
+	ReviewedByUserID *int64               `json:"reviewed_by_user_id,omitempty"`
+	CreatedAt        time.Time            `json:"created_at"`
+	ReviewedAt       *time.Time           `json:"reviewed_at,omitempty"`
+}
+
+// PeakSubmissionForReview is a queued submission with what a moderator needs
+// to judge it: the peak as it is now, and existing peaks close to the
+// proposed position that it might duplicate
+type PeakSubmissionForReview struct {
+	PeakSubmission
+	CurrentPeak *Peak  `json:"current_peak,omitempty"`
+	NearbyPeaks []Peak `json:"nearby_peaks"`
+}
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+type PersonalGoalMetric string
+
+const (
+	PersonalGoalMetricDistance        PersonalGoalMetric = "distance"         // km
+	PersonalGoalMetricElevation       PersonalGoalMetric = "elevation"        // meters
+	PersonalGoalMetricMovingTime      PersonalGoalMetric = "moving_time"      // hours
+	PersonalGoalMetricActivityCount   PersonalGoalMetric = "activity_count"   // activities
+	PersonalGoalMetricSummitCount     PersonalGoalMetric = "summit_count"     // summits
+	PersonalGoalMetricUniquePeaks     PersonalGoalMetric = "unique_peaks"     // distinct peaks
+	PersonalGoalMetricRegionPeaks     PersonalGoalMetric = "region_peaks"     // distinct peaks in Region
+	PersonalGoalMetricSpecificSummits PersonalGoalMetric = "specific_summits" // distinct peaks from PeakIDs
+)
+
+type PersonalGoalPeriod string
+
+const (
+	PersonalGoalPeriodWeek    PersonalGoalPeriod = "week"
+	PersonalGoalPeriodMonth   PersonalGoalPeriod = "month"
+	PersonalGoalPeriodQuarter PersonalGoalPeriod = "quarter"
+	PersonalGoalPeriodYear    PersonalGoalPeriod = "year"
+	PersonalGoalPeriodCustom  PersonalGoalPeriod = "custom"
+)
+
+type PersonalGoal struct {
+	ID            int64              `json:"id"`
+	UserID        int64              `json:"user_id"`
+	Name          string             `json:"name"`
+	Metric        PersonalGoalMetric `json:"metric"`
+	PeriodType    PersonalGoalPeriod `json:"period_type"`
+	StartDate     time.Time          `json:"start_date"`
+	EndDate       time.Time          `json:"end_date"` // Inclusive
+	TargetValue   float64            `json:"target_value"`
+	ActivityTypes []string           `json:"activity_types"` // Empty means every type
+	Region        *string            `json:"region,omitempty"`
+	PeakIDs       []int64            `json:"peak_ids,omitempty"`
+	IsYearlyGoal  bool               `json:"is_yearly_goal"` // Backs the PersonalYearlyGoal for its year
+	CreatedAt     time.Time          `json:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at"`
+}
+
+// PersonalGoalWithProgress is a goal with its pacing as of AsOf
+type PersonalGoalWithProgress struct {
+	PersonalGoal
+	AsOf     time.Time          `json:"as_of"`
+	Progress GoalMetricProgress `json:"progress"`
+}
@@ -12,3 +12,36 @@ type PersonalYearlyGoal struct {
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
 }
+
+type GoalPaceStatus string
+
+const (
+	GoalPaceStatusNoGoal    GoalPaceStatus = "no_goal"
+	GoalPaceStatusBehind    GoalPaceStatus = "behind"
+	GoalPaceStatusOnTrack   GoalPaceStatus = "on_track"
+	GoalPaceStatusAhead     GoalPaceStatus = "ahead"
+	GoalPaceStatusCompleted GoalPaceStatus = "completed"
+)
+
+// GoalMetricProgress is the pacing for one metric of a goal, in the goal's units
+type GoalMetricProgress struct {
+	Goal               float64        `json:"goal"`
+	Actual             float64        `json:"actual"`
+	ExpectedToDate     float64        `json:"expected_to_date"`     // Where an even pace would be today
+	PercentComplete    float64        `json:"percent_complete"`     // Actual as a percentage of the goal
+	Status             GoalPaceStatus `json:"status"`               // Actual compared with the expected pace
+	ProjectedTotal     float64        `json:"projected_total"`      // End-of-period total if the recent trend continues
+	RequiredWeeklyRate float64        `json:"required_weekly_rate"` // Per week needed to finish, 0 once met or the period is over
+}
+
+// PersonalGoalProgress is how far through a yearly goal the user is
+type PersonalGoalProgress struct {
+	Year        int                `json:"year"`
+	AsOf        time.Time          `json:"as_of"` // Progress is measured up to this moment
+	DaysElapsed int                `json:"days_elapsed"`
+	DaysInYear  int                `json:"days_in_year"`
+	Goal        PersonalYearlyGoal `json:"goal"`
+	Distance    GoalMetricProgress `json:"distance"`
+	Elevation   GoalMetricProgress `json:"elevation"`
+	Summits     GoalMetricProgress `json:"summits"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// PrivacyZone is a circle, usually around home, that the user's routes are
+// trimmed to before other users see them. Routes are cut at the trim circle,
+// a larger circle around the zone that's randomly offset from it, so the
+// zone's centre can't be worked out from where routes stop. It's never sent
+// to clients.
+type PrivacyZone struct {
+	ID               int64     `json:"id"`
+	UserID           int64     `json:"user_id"`
+	Name             string    `json:"name"`
+	Latitude         float64   `json:"latitude"`
+	Longitude        float64   `json:"longitude"`
+	RadiusMeters     float64   `json:"radius_meters"`
+	TrimLatitude     float64   `json:"-"`
+	TrimLongitude    float64   `json:"-"`
+	TrimRadiusMeters float64   `json:"-"`
+	CreatedAt        time.Time `json:"created_at"`
+}
@@ -11,6 +11,8 @@ type StravaActivity struct {
 	MovingTime  int     `json:"moving_time"`          // in seconds
 	Description string  `json:"description"`
 	StartDate   string  `json:"start_date_local"`
+	Private     bool    `json:"private"`
+	Visibility  string  `json:"visibility"` // everyone, followers_only or only_me
 	Map         struct {
 		SummaryPolyline string `json:"summary_polyline"`
 	} `json:"map"`
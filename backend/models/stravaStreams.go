@@ -0,0 +1,11 @@
+package models
+
+// StravaStreams is the key_by_type response from the activity streams endpoint
+type StravaStreams struct {
+	LatLng struct {
+		Data [][]float64 `json:"data"` // [lat, lon] per sample
+	} `json:"latlng"`
+	Time struct {
+		Data []int `json:"data"` // seconds since activity start per sample
+	} `json:"time"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// StreakType is what a streak counts
+type StreakType string
+
+const (
+	StreakTypeDailyActivity  StreakType = "daily_activity"  // Consecutive days with an activity
+	StreakTypeWeeklyActivity StreakType = "weekly_activity" // Consecutive weeks with an activity
+	StreakTypeWeeklySummit   StreakType = "weekly_summit"   // Consecutive weeks with at least one summit
+)
+
+// Streak is the current and longest run of consecutive periods.
+// Periods are days or weeks depending on the streak type.
+type Streak struct {
+	Type         StreakType `json:"type"`
+	Current      int        `json:"current"`
+	Longest      int        `json:"longest"`
+	CurrentStart *time.Time `json:"currentStart"` // First period of the current streak
+	LongestStart *time.Time `json:"longestStart"`
+	LongestEnd   *time.Time `json:"longestEnd"` // Last period of the longest streak
+	LastPeriod   *time.Time `json:"lastPeriod"` // Most recent period with a qualifying activity
+}
+
+// UserStreaks are all of a user's streaks
+type UserStreaks struct {
+	UserID         int64  `json:"userId"`
+	Timezone       string `json:"timezone"`
+	WeekStart      int    `json:"weekStart"`
+	DailyActivity  Streak `json:"dailyActivity"`
+	WeeklyActivity Streak `json:"weeklyActivity"`
+	WeeklySummit   Streak `json:"weeklySummit"`
+}
@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// user_peaks sources
+const (
+	SummitSourceDetected = "detected" // Found on the activity's route
+	SummitSourceClaimed  = "claimed"  // Claimed by the user and approved
+)
+
+type SummitClaimStatus string
+
+const (
+	SummitClaimStatusPending  SummitClaimStatus = "pending"
+	SummitClaimStatusApproved SummitClaimStatus = "approved"
+	SummitClaimStatusRejected SummitClaimStatus = "rejected"
+)
+
+// SummitDismissal is a detected summit the user said didn't happen. It stays
+// as a tombstone so detection doesn't add the summit back.
+type SummitDismissal struct {
+	ID           int64     `json:"id"`
+	UserID       int64     `json:"user_id"`
+	PeakID       int64     `json:"peak_id"`
+	PeakName     string    `json:"peak_name"`
+	ActivityID   int64     `json:"activity_id"`
+	ActivityName string    `json:"activity_name"`
+	Reason       *string   `json:"reason,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// SummitClaim is a summit detection missed, claimed against one of the
+// user's activities
+type SummitClaim struct {
+	ID               int64             `json:"id"`
+	UserID           int64             `json:"user_id"`
+	PeakID           int64             `json:"peak_id"`
+	PeakName         string            `json:"peak_name"`
+	ActivityID       int64             `json:"activity_id"`
+	ActivityName     string            `json:"activity_name"`
+	Note             *string           `json:"note,omitempty"`
+	DistanceMeters   *float64          `json:"distance_meters,omitempty"` // Closest the route came to the peak
+	Status           SummitClaimStatus `json:"status"`
+	AutoApproved     bool              `json:"auto_approved"`
+	ReviewerNote     *string           `json:"reviewer_note,omitempty"`
+	ReviewedByUserID *int64            `json:"reviewed_by_user_id,omitempty"`
+	CreatedAt        time.Time         `json:"created_at"`
+	ReviewedAt       *time.Time        `json:"reviewed_at,omitempty"`
+}
+
+// SummitClaimForReview is a pending claim with what a reviewer needs to
+// judge it: who made it, where the peak is and the activity's route
+type SummitClaimForReview struct {
+	SummitClaim
+	UserName      string  `json:"user_name"`
+	PeakLatitude  float64 `json:"peak_latitude"`
+	PeakLongitude float64 `json:"peak_longitude"`
+	MapPolyline   string  `json:"map_polyline"`
+}
@@ -4,8 +4,45 @@ import "time"
 
 // SummitFavourite represents a user's favourite/wishlist peak
 type SummitFavourite struct {
-	ID        int64     `json:"id" db:"id"`
-	UserID    int64     `json:"user_id" db:"user_id"`
-	PeakID    int64     `json:"peak_id" db:"peak_id"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	ID         int64      `json:"id" db:"id"`
+	UserID     int64      `json:"user_id" db:"user_id"`
+	PeakID     int64      `json:"peak_id" db:"peak_id"`
+	Notes      *string    `json:"notes" db:"notes"`
+	TargetDate *time.Time `json:"target_date" db:"target_date"`
+	SortOrder  int        `json:"sort_order" db:"sort_order"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+type WishlistStatus string
+
+const (
+	WishlistStatusToDo   WishlistStatus = "to_do"
+	WishlistStatusBagged WishlistStatus = "bagged" // Summited since it was favourited
+)
+
+// WishlistItem is a favourite with its peak and whether it has been bagged
+type WishlistItem struct {
+	SummitFavourite
+	Peak              Peak           `json:"peak"`
+	Status            WishlistStatus `json:"status"`
+	BaggedAt          *time.Time     `json:"bagged_at,omitempty"`
+	BaggedActivityID  *int64         `json:"bagged_activity_id,omitempty"`
+	ClimbedBeforehand bool           `json:"climbed_beforehand"` // Summited before it was favourited
+}
+
+// WishlistSuggestion is an unclimbed peak near a location
+type WishlistSuggestion struct {
+	Peak           Peak    `json:"peak"`
+	DistanceMeters float64 `json:"distance_meters"`
+	OnWishlist     bool    `json:"on_wishlist"`
+}
+
+// WishlistCluster is a group of still-to-do wishlist peaks close enough
+// together to be done in a single outing
+type WishlistCluster struct {
+	Items           []WishlistItem `json:"items"`
+	CenterLatitude  float64        `json:"center_latitude"`
+	CenterLongitude float64        `json:"center_longitude"`
+	SpanMeters      float64        `json:"span_meters"` // Furthest distance between two peaks in the cluster
 }
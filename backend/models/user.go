@@ -36,15 +36,21 @@ func (ns *NullableString) UnmarshalJSON(data []byte) error {
 }
 
 type User struct {
-	ID              int64          `json:"id"`
-	StravaAthleteID int64          `json:"strava_athelete_id"` // Strava athlete ID, unique
-	Username        NullableString `json:"username"`            // User-chosen display name
-	IsAdmin         bool           `json:"is_admin"`            // Whether user has admin privileges
-	AccessToken     string         `json:"access_token"`
-	RefreshToken    string         `json:"refresh_token"`
-	ExpiresAt       time.Time      `json:"expires_at"`
-	LastDistance    float64        `json:"last_distance"` // Cached distance in km
-	LastUpdated     time.Time      `json:"last_updated"`  // When we last fetched from Strava
-	CreatedAt       time.Time      `json:"created_at"`
-	UpdatedAt       time.Time      `json:"updated_at"`
+	ID                 int64          `json:"id"`
+	StravaAthleteID    int64          `json:"strava_athelete_id"` // Strava athlete ID, unique
+	Username           NullableString `json:"username"`           // User-chosen display name
+	IsAdmin            bool           `json:"is_admin"`           // Whether user has admin privileges
+	AccessToken        string         `json:"access_token"`
+	RefreshToken       string         `json:"refresh_token"`
+	ExpiresAt          time.Time      `json:"expires_at"`
+	LastDistance       float64        `json:"last_distance"` // Cached distance in km
+	LastUpdated        time.Time      `json:"last_updated"`  // When we last fetched from Strava
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	Timezone           string         `json:"timezone"`             // IANA name, e.g. "Africa/Johannesburg"
+	WeekStart          int            `json:"week_start"`           // 0 = Sunday, 1 = Monday, ...
+	ShowInLeaderboards bool           `json:"show_in_leaderboards"` // Privacy: name shown in community stats and challenge leaderboards
+
+	ShowInGroupFeeds     bool `json:"show_in_group_feeds"`     // Privacy: activities shown in group feeds and heatmaps
+	ShowInChallengeFeeds bool `json:"show_in_challenge_feeds"` // Privacy: activities shown in challenge feeds
 }
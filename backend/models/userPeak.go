@@ -9,4 +9,37 @@ type UserPeak struct {
 	ActivityID int64     `json:"activity_id"` // the activity that triggered the "bag"
 	SummitedAt time.Time `json:"summited_at"` // when we detected the visit
 	// optional: distance threshold or actual min distance for reference
+
+	// Timing within the activity (nil when it couldn't be derived)
+	SummitTime     *time.Time `json:"summit_time"`      // moment the summit was reached
+	ElapsedSeconds *int       `json:"elapsed_seconds"`  // from activity start to summit
+	SplitSeconds   *int       `json:"split_seconds"`    // from the previous summit in the same activity
+	PreviousPeakID *int64     `json:"previous_peak_id"` // the previous summit in the same activity
+	TimingSource   *string    `json:"timing_source"`    // "stream" or "estimated"
+}
+
+const (
+	SummitTimingSourceStream    = "stream"    // From the Strava time/latlng streams
+	SummitTimingSourceEstimated = "estimated" // Interpolated along the summary polyline
+)
+
+// SummitMoment returns the precise summit time when known, otherwise the activity start
+func (u *UserPeak) SummitMoment() time.Time {
+	if u.SummitTime != nil {
+		return *u.SummitTime
+	}
+	return u.SummitedAt
+}
+
+// FastestTimeEntry is a ranked row on a fastest-ascent or fastest-traverse leaderboard
+type FastestTimeEntry struct {
+	Rank            int       `json:"rank"`
+	UserID          int64     `json:"userId"`
+	UserName        string    `json:"userName"`
+	StravaAthleteID int64     `json:"stravaAthleteId"`
+	ActivityID      int64     `json:"activityId"`
+	ActivityName    string    `json:"activityName"`
+	ElapsedSeconds  int       `json:"elapsedSeconds"`
+	AchievedAt      time.Time `json:"achievedAt"`
+	TimingSource    string    `json:"timingSource"`
 }
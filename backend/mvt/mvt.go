@@ -0,0 +1,389 @@
+// Package mvt encodes Mapbox Vector Tiles (version 2.1) with points and
+// linestrings, and converts lat/lon to Web Mercator tile coordinates.
+package mvt
+
+import (
+	"math"
+	"sort"
+)
+
+// Extent is the size of a tile in its own integer coordinates
+const Extent = 4096
+
+// Geometry types from the vector tile spec
+const (
+	geomPoint      = 1
+	geomLineString = 2
+)
+
+// Geometry commands
+const (
+	cmdMoveTo = 1
+	cmdLineTo = 2
+)
+
+// Tile is a vector tile being built. Layers are written in the order they
+// were added.
+type Tile struct {
+	layers []*Layer
+}
+
+// Layer adds a named layer to the tile, or returns the existing one
+func (t *Tile) Layer(name string) *Layer {
+	for _, l := range t.layers {
+		if l.name == name {
+			return l
+		}
+	}
+	l := &Layer{name: name, keyIndex: map[string]uint32{}, valueIndex: map[interface{}]uint32{}}
+	t.layers = append(t.layers, l)
+	return l
+}
+
+// Layer holds features sharing one key and value table
+type Layer struct {
+	name       string
+	keys       []string
+	keyIndex   map[string]uint32
+	values     []interface{}
+	valueIndex map[interface{}]uint32
+	features   [][]byte
+}
+
+// Len is the number of features in the layer
+func (l *Layer) Len() int {
+	return len(l.features)
+}
+
+// AddPoint adds a point at tile coordinates x, y. Property values may be
+// string, bool, int, int64 or float64; others are skipped.
+func (l *Layer) AddPoint(id uint64, x, y int, properties map[string]interface{}) {
+	geometry := []uint32{command(cmdMoveTo, 1), zigzag(x), zigzag(y)}
+	l.addFeature(id, geomPoint, geometry, properties)
+}
+
+// AddLineString adds a line, or several lines as one feature, through points
+// in tile coordinates. Repeated points are dropped and lines left with fewer
+// than two points are skipped.
+func (l *Layer) AddLineString(id uint64, lines [][][2]int, properties map[string]interface{}) {
+	geometry := []uint32{}
+	var cursor [2]int
+	for _, line := range lines {
+		deduped := make([][2]int, 0, len(line))
+		for _, p := range line {
+			if len(deduped) == 0 || deduped[len(deduped)-1] != p {
+				deduped = append(deduped, p)
+			}
+		}
+		if len(deduped) < 2 {
+			continue
+		}
+
+		// Coordinates are relative to the previous point, across lines too
+		geometry = append(geometry, command(cmdMoveTo, 1),
+			zigzag(deduped[0][0]-cursor[0]), zigzag(deduped[0][1]-cursor[1]))
+		geometry = append(geometry, command(cmdLineTo, len(deduped)-1))
+		for i := 1; i < len(deduped); i++ {
+			geometry = append(geometry,
+				zigzag(deduped[i][0]-deduped[i-1][0]),
+				zigzag(deduped[i][1]-deduped[i-1][1]))
+		}
+		cursor = deduped[len(deduped)-1]
+	}
+	if len(geometry) == 0 {
+		return
+	}
+	l.addFeature(id, geomLineString, geometry, properties)
+}
+
+func (l *Layer) addFeature(id uint64, geomType uint64, geometry []uint32, properties map[string]interface{}) {
+	// Keys sorted so the same features always encode to the same bytes
+	names := make([]string, 0, len(properties))
+	for k := range properties {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	tags := make([]uint32, 0, len(properties)*2)
+	for _, k := range names {
+		v := normaliseValue(properties[k])
+		if v == nil {
+			continue
+		}
+		tags = append(tags, l.key(k), l.value(v))
+	}
+
+	var f buffer
+	f.uint(1, id)
+	f.packed(2, tags)
+	f.uint(3, geomType)
+	f.packed(4, geometry)
+	l.features = append(l.features, f.bytes)
+}
+
+func (l *Layer) key(k string) uint32 {
+	if i, ok := l.keyIndex[k]; ok {
+		return i
+	}
+	i := uint32(len(l.keys))
+	l.keys = append(l.keys, k)
+	l.keyIndex[k] = i
+	return i
+}
+
+func (l *Layer) value(v interface{}) uint32 {
+	if i, ok := l.valueIndex[v]; ok {
+		return i
+	}
+	i := uint32(len(l.values))
+	l.values = append(l.values, v)
+	l.valueIndex[v] = i
+	return i
+}
+
+func normaliseValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case string, bool, int64, float64:
+		return t
+	case int:
+		return int64(t)
+	case float32:
+		return float64(t)
+	}
+	return nil
+}
+
+// Marshal encodes the tile as protobuf. Empty layers are left out.
+func (t *Tile) Marshal() []byte {
+	var tile buffer
+	for _, l := range t.layers {
+		if len(l.features) == 0 {
+			continue
+		}
+		var layer buffer
+		layer.uint(15, 2)
+		layer.string(1, l.name)
+		for _, f := range l.features {
+			layer.message(2, f)
+		}
+		for _, k := range l.keys {
+			layer.string(3, k)
+		}
+		for _, v := range l.values {
+			var value buffer
+			switch t := v.(type) {
+			case string:
+				value.string(1, t)
+			case float64:
+				value.double(3, t)
+			case int64:
+				value.sint(6, t)
+			case bool:
+				b := uint64(0)
+				if t {
+					b = 1
+				}
+				value.uint(7, b)
+			}
+			layer.message(4, value.bytes)
+		}
+		layer.uint(5, Extent)
+		tile.message(3, layer.bytes)
+	}
+	return tile.bytes
+}
+
+// ==================== Tile coordinates ====================
+
+// Bounds returns the lat/lon box a Web Mercator tile covers
+func Bounds(z, x, y int) (minLat, maxLat, minLon, maxLon float64) {
+	n := math.Exp2(float64(z))
+	minLon = float64(x)/n*360 - 180
+	maxLon = float64(x+1)/n*360 - 180
+	maxLat = tileLat(float64(y), n)
+	minLat = tileLat(float64(y+1), n)
+	return minLat, maxLat, minLon, maxLon
+}
+
+func tileLat(y float64, n float64) float64 {
+	return math.Atan(math.Sinh(math.Pi*(1-2*y/n))) * 180 / math.Pi
+}
+
+// Project converts lat/lon to coordinates within tile z/x/y, where 0..Extent
+// is inside the tile. Points outside the tile get coordinates outside that range.
+func Project(z, x, y int, lat, lon float64) (float64, float64) {
+	n := math.Exp2(float64(z))
+	lat = math.Max(-85.0511, math.Min(85.0511, lat))
+	worldX := (lon + 180) / 360 * n
+	latRad := lat * math.Pi / 180
+	worldY := (1 - math.Log(math.Tan(latRad)+1/math.Cos(latRad))/math.Pi) / 2 * n
+	return (worldX - float64(x)) * Extent, (worldY - float64(y)) * Extent
+}
+
+// ClipLine cuts a line in tile coordinates to the tile plus buffer units on
+// each side, returning the pieces that fall inside, rounded to integers.
+// Without clipping, long routes overflow tile coordinates at high zooms.
+func ClipLine(points [][2]float64, buffer float64) [][][2]int {
+	lo, hi := -buffer, Extent+buffer
+	lines := [][][2]int{}
+	current := [][2]int{}
+
+	for i := 1; i < len(points); i++ {
+		a, b, ok := clipSegment(points[i-1], points[i], lo, hi)
+		if !ok {
+			continue
+		}
+		start, end := round(a), round(b)
+		if len(current) > 0 && current[len(current)-1] != start {
+			lines = append(lines, current)
+			current = [][2]int{}
+		}
+		if len(current) == 0 {
+			current = append(current, start)
+		}
+		current = append(current, end)
+		// The segment left the box, so the next visible one starts a new line
+		if b != points[i] {
+			lines = append(lines, current)
+			current = [][2]int{}
+		}
+	}
+	if len(current) > 0 {
+		lines = append(lines, current)
+	}
+	return lines
+}
+
+// clipSegment clips a-b to the square lo..hi (Liang-Barsky)
+func clipSegment(a, b [2]float64, lo, hi float64) ([2]float64, [2]float64, bool) {
+	t0, t1 := 0.0, 1.0
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	edges := [4][2]float64{
+		{-dx, a[0] - lo},
+		{dx, hi - a[0]},
+		{-dy, a[1] - lo},
+		{dy, hi - a[1]},
+	}
+	for _, e := range edges {
+		p, q := e[0], e[1]
+		if p == 0 {
+			if q < 0 {
+				return a, b, false
+			}
+			continue
+		}
+		t := q / p
+		if p < 0 {
+			if t > t1 {
+				return a, b, false
+			}
+			t0 = math.Max(t0, t)
+		} else {
+			if t < t0 {
+				return a, b, false
+			}
+			t1 = math.Min(t1, t)
+		}
+	}
+	clippedA, clippedB := a, b
+	if t0 > 0 {
+		clippedA = [2]float64{a[0] + t0*dx, a[1] + t0*dy}
+	}
+	if t1 < 1 {
+		clippedB = [2]float64{a[0] + t1*dx, a[1] + t1*dy}
+	}
+	return clippedA, clippedB, true
+}
+
+func round(p [2]float64) [2]int {
+	return [2]int{int(math.Round(p[0])), int(math.Round(p[1]))}
+}
+
+// TileAt returns the tile at zoom z containing lat/lon
+func TileAt(z int, lat, lon float64) (int, int) {
+	px, py := Project(z, 0, 0, lat, lon)
+	n := 1<<z - 1
+	x := int(math.Floor(px / Extent))
+	y := int(math.Floor(py / Extent))
+	return max(0, min(n, x)), max(0, min(n, y))
+}
+
+// ValidTile reports whether z/x/y is a tile that exists
+func ValidTile(z, x, y int) bool {
+	if z < 0 || z > 22 {
+		return false
+	}
+	n := 1 << z
+	return x >= 0 && x < n && y >= 0 && y < n
+}
+
+// ==================== Protobuf encoding ====================
+
+const (
+	wireVarint = 0
+	wire64Bit  = 1
+	wireBytes  = 2
+)
+
+func command(id int, count int) uint32 {
+	return uint32(id&0x7) | uint32(count)<<3
+}
+
+func zigzag(n int) uint32 {
+	return uint32((int32(n) << 1) ^ (int32(n) >> 31))
+}
+
+type buffer struct {
+	bytes []byte
+}
+
+func (b *buffer) varint(v uint64) {
+	for v >= 0x80 {
+		b.bytes = append(b.bytes, byte(v)|0x80)
+		v >>= 7
+	}
+	b.bytes = append(b.bytes, byte(v))
+}
+
+func (b *buffer) tag(field int, wireType int) {
+	b.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (b *buffer) uint(field int, v uint64) {
+	b.tag(field, wireVarint)
+	b.varint(v)
+}
+
+func (b *buffer) sint(field int, v int64) {
+	b.tag(field, wireVarint)
+	b.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (b *buffer) double(field int, v float64) {
+	b.tag(field, wire64Bit)
+	bits := math.Float64bits(v)
+	for i := 0; i < 8; i++ {
+		b.bytes = append(b.bytes, byte(bits>>(8*i)))
+	}
+}
+
+func (b *buffer) string(field int, s string) {
+	b.message(field, []byte(s))
+}
+
+func (b *buffer) message(field int, m []byte) {
+	b.tag(field, wireBytes)
+	b.varint(uint64(len(m)))
+	b.bytes = append(b.bytes, m...)
+}
+
+func (b *buffer) packed(field int, values []uint32) {
+	if len(values) == 0 {
+		return
+	}
+	var inner buffer
+	for _, v := range values {
+		inner.varint(uint64(v))
+	}
+	b.message(field, inner.bytes)
+}
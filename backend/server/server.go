@@ -36,25 +36,64 @@ func NewServer() *http.Server {
 	userPeaksDao := daos.NewUserPeaksDao(logger, db)
 	groupsDao := daos.NewGroupsDao(logger, db)
 	personalYearlyGoalDao := daos.NewPersonalYearlyGoalDao(logger, db)
+	personalGoalDao := daos.NewPersonalGoalDao(logger, db)
 	summitFavouritesDao := daos.NewSummitFavouritesDao(logger, db)
 	challengeDao := daos.NewChallengeDao(logger, db)
+	challengeSeriesDao := daos.NewChallengeSeriesDao(logger, db)
+	achievementDao := daos.NewAchievementDao(logger, db)
+	peakListDao := daos.NewPeakListDao(logger, db)
+	peakMergeDao := daos.NewPeakMergeDao(logger, db)
+	privacyZoneDao := daos.NewPrivacyZoneDao(logger, db)
+	peakSubmissionDao := daos.NewPeakSubmissionDao(logger, db)
+	summitCorrectionDao := daos.NewSummitCorrectionDao(logger, db)
+	dataExportDao := daos.NewDataExportDao(logger, db)
+	accountDeletionDao := daos.NewAccountDeletionDao(logger, db)
+	notificationDao := daos.NewNotificationDao(logger, db)
+	emailDao := daos.NewEmailDao(logger, db)
+	groupWebhookDao := daos.NewGroupWebhookDao(logger, db)
 
 	// initialise services
 	jwtService := services.NewJWTService(logger, config)
 	stravaService := services.NewStravaService(logger, config, userDao, activityDao)
-	activityService := services.NewActivityService(logger, activityDao)
-	peakService := services.NewPeakService(logger, peaksDao, userPeaksDao)
+	activityService := services.NewActivityService(logger, activityDao, userPeaksDao)
+	elevationService := services.NewElevationService(logger, config, peaksDao, activityDao)
+	peakService := services.NewPeakService(logger, peaksDao, userPeaksDao, groupsDao, challengeDao, elevationService)
 	summariesService := services.NewSummariesService(logger, peaksDao, userPeaksDao, activityDao)
 	progressService := services.NewProgressService(logger, userDao, stravaService)
 	goalProgressService := services.NewGoalProgressService(logger, groupsDao, activityDao, userPeaksDao)
-	groupsService := services.NewGroupsService(logger, groupsDao)
 	userService := services.NewUserService(logger, userDao)
-	personalGoalsService := services.NewPersonalGoalsService(logger, personalYearlyGoalDao)
-	summitFavouritesService := services.NewSummitFavouritesService(logger, summitFavouritesDao)
-	challengeService := services.NewChallengeService(logger, challengeDao, activityDao)
+	personalGoalsService := services.NewPersonalGoalsService(logger, personalYearlyGoalDao, personalGoalDao, activityDao, userPeaksDao, userDao)
+	emailService := services.NewEmailService(logger, config, emailDao, userDao, activityDao, userPeaksDao, peaksDao, challengeDao, personalGoalsService)
+	notificationService := services.NewNotificationService(logger, notificationDao, userDao, emailService)
+	// Live updates reach other replicas through Postgres when configured
+	var liveBroker services.LiveBroker = services.NewMemoryLiveBroker(logger)
+	if config.Live.Broker == "postgres" {
+		broker, err := services.NewPostgresLiveBroker(logger, db, database.String(config.Database))
+		if err != nil {
+			log.Fatal("failed to listen for live events:", err)
+		}
+		liveBroker = broker
+	}
+	liveService := services.NewLiveService(logger, liveBroker, challengeDao, groupsDao)
+	groupsService := services.NewGroupsService(logger, groupsDao, notificationService, liveService)
+	groupWebhookService := services.NewGroupWebhookService(logger, groupWebhookDao, groupsDao, userDao)
+	summitFavouritesService := services.NewSummitFavouritesService(logger, summitFavouritesDao, peaksDao, userPeaksDao)
+	streakService := services.NewStreakService(logger, userDao, activityDao, userPeaksDao)
+	privacyService := services.NewPrivacyService(logger, privacyZoneDao, userDao)
+	challengeService := services.NewChallengeService(logger, challengeDao, activityDao, userPeaksDao, streakService, privacyService, notificationService, groupWebhookService, liveService)
+	challengeSeriesService := services.NewChallengeSeriesService(logger, challengeSeriesDao, challengeDao, groupsDao, challengeService)
+	achievementService := services.NewAchievementService(logger, achievementDao, activityDao, userDao)
+	peakListService := services.NewPeakListService(logger, peakListDao, userDao, challengeService)
+	peakSubmissionService := services.NewPeakSubmissionService(logger, peakSubmissionDao, peaksDao, userDao, elevationService, peakService)
+	mapService := services.NewMapService(logger, peaksDao, userPeaksDao, activityDao)
+	heatmapService := services.NewHeatmapService(logger, activityDao, groupsDao, mapService, privacyService)
+	peakMergeService := services.NewPeakMergeService(logger, config, peakMergeDao, peaksDao, challengeDao, challengeService, peakService)
+	summitCorrectionService := services.NewSummitCorrectionService(logger, config, summitCorrectionDao, userPeaksDao, peaksDao, activityDao, userDao, challengeService, achievementService, groupWebhookService, liveService)
+	dataExportService := services.NewDataExportService(logger, config, dataExportDao, userDao, activityDao, userPeaksDao, personalGoalDao, personalYearlyGoalDao, summitFavouritesDao, challengeDao, groupsDao)
+	accountDeletionService := services.NewAccountDeletionService(logger, accountDeletionDao, userDao, stravaService, dataExportService)
 
 	// Services for background jobs
-	summitService := services.NewSummitService(logger, config, peaksDao, userPeaksDao, activityDao, challengeService)
+	summitService := services.NewSummitService(logger, config, peaksDao, userPeaksDao, activityDao, userDao, stravaService, challengeService, achievementService, summitCorrectionDao, notificationService, groupWebhookService, liveService)
 	overpassService := services.NewOverpassService(logger, peaksDao)
 
 	// One-time peak data fetch on startup (peaks don't change often)
@@ -79,25 +118,56 @@ func NewServer() *http.Server {
 		userService,
 		personalGoalsService,
 		summitFavouritesService,
+		streakService,
+		elevationService,
 	)
 	authController := controllers.NewAuthController(logger, jwtService)
 	groupsController := controllers.NewGroupsController(logger, groupsService, goalProgressService)
 	challengesController := controllers.NewChallengesController(logger, challengeService)
+	challengeSeriesController := controllers.NewChallengeSeriesController(logger, challengeSeriesService)
+	achievementsController := controllers.NewAchievementsController(logger, achievementService)
+	peakListsController := controllers.NewPeakListsController(logger, peakListService)
+	peakMergeController := controllers.NewPeakMergeController(logger, peakMergeService)
+	peakSubmissionsController := controllers.NewPeakSubmissionsController(logger, peakSubmissionService)
+	mapController := controllers.NewMapController(logger, mapService, heatmapService)
+	privacyController := controllers.NewPrivacyController(logger, privacyService)
+	summitCorrectionsController := controllers.NewSummitCorrectionsController(logger, summitCorrectionService)
+	notificationsController := controllers.NewNotificationsController(logger, notificationService)
+	emailController := controllers.NewEmailController(logger, emailService)
+	groupWebhooksController := controllers.NewGroupWebhooksController(logger, groupWebhookService)
+	liveController := controllers.NewLiveController(logger, liveService)
 
 	// background jobs
 	// TODO(cian): Move out of server.
-	fetcher := workflows.NewStravaActivityFetcher(stravaService, summitService, challengeService, userDao, activityDao, logger)
+	fetcher := workflows.NewStravaActivityFetcher(stravaService, summitService, challengeService, achievementService, userDao, activityDao, logger)
 
-	hgController := controllers.NewHgController(logger, activityService, userDao, fetcher)
+	hgController := controllers.NewHgController(logger, activityService, userDao, fetcher, privacyService)
 	stravaController := controllers.NewStravaController(logger, jwtService, stravaService, summitService, activityDao)
-	supportController := controllers.NewSupportController(logger, userService, peakService, overpassService, activityDao, userPeaksDao)
+	supportController := controllers.NewSupportController(logger, userService, peakService, overpassService, elevationService, activityDao, userPeaksDao, dataExportService, accountDeletionService)
 
 	// initialise handlers
-	apiHandler := handlers.NewApiHandler(logger, apiController, groupsController, challengesController)
+	apiHandler := handlers.NewApiHandler(logger, apiController, groupsController, challengesController, challengeSeriesController, achievementsController, peakListsController, peakSubmissionsController, mapController, privacyController, summitCorrectionsController, notificationsController, emailController, groupWebhooksController)
 	authHandler := handlers.NewAuthHandler(logger, authController, stravaController)
 	hgHandler := handlers.NewHgHandler(logger, hgController)
 	stravaHandler := handlers.NewStravaHandler(logger, stravaController)
 	supportHandler := handlers.NewSupportHandler(logger, supportController)
+	tilesHandler := handlers.NewTilesHandler(logger, mapController)
+
+	// Data exports, account deletions, emails and group webhooks are user
+	// requested, so they run even with the sync job disabled. Expired
+	// download files are deleted hourly.
+	go dataExportService.Run()
+	go accountDeletionService.Run()
+	go emailService.Run()
+	go groupWebhookService.Run()
+	go func() {
+		for {
+			if err := dataExportService.CleanupExpired(); err != nil {
+				logger.Printf("Data export cleanup failed: %v", err)
+			}
+			time.Sleep(time.Hour)
+		}
+	}()
 
 	// background sync job - disabled via DISABLE_SYNC_JOB=true for local development
 	if os.Getenv("DISABLE_SYNC_JOB") != "true" {
@@ -130,6 +200,40 @@ func NewServer() *http.Server {
 				logger.Println("Weekly full sync complete.")
 			}
 		}()
+
+		// Challenge series scheduler - creates the next instance once a period starts
+		go func() {
+			for {
+				if err := challengeSeriesService.InstantiateDueSeries(); err != nil {
+					logger.Printf("Challenge series scheduler failed: %v", err)
+				}
+				time.Sleep(time.Hour)
+			}
+		}()
+
+		// Challenge reminders and weekly email digests - each goes out once, so
+		// checking hourly only decides how soon after they're due
+		go func() {
+			for {
+				if err := challengeService.NotifyEndingChallenges(); err != nil {
+					logger.Printf("Challenge ending reminders failed: %v", err)
+				}
+				if err := emailService.QueueWeeklyDigests(); err != nil {
+					logger.Printf("Weekly email digests failed: %v", err)
+				}
+				time.Sleep(time.Hour)
+			}
+		}()
+
+		// Heatmap pre-render - renders low zoom tiles for users with new activities
+		go func() {
+			for {
+				if err := heatmapService.PrerenderChanged(); err != nil {
+					logger.Printf("Heatmap pre-render failed: %v", err)
+				}
+				time.Sleep(15 * time.Minute)
+			}
+		}()
 	} else {
 		logger.Println("Sync job disabled via DISABLE_SYNC_JOB environment variable")
 	}
@@ -141,11 +245,32 @@ func NewServer() *http.Server {
 	mux.Handle("/auth/", authHandler)
 	mux.Handle("/hikegang/", hgHandler)
 	mux.Handle("/support/", middleware.JWT(jwtService, supportHandler))
+	mux.Handle("/tiles/", middleware.JWT(jwtService, tilesHandler))
+	// Live updates - Server-Sent Events, the JWT may also be in the access_token param
+	mux.Handle("/live", middleware.JWTStream(jwtService, http.HandlerFunc(liveController.Stream)))
+	// Export downloads - no JWT, the link's token is the credential
+	mux.HandleFunc("/exports/download", supportController.DownloadDataExport)
+	// Deletion receipts - no JWT, the account is gone so the receipt code is the credential
+	mux.HandleFunc("/account-deletions/receipt", supportController.GetDeletionReceipt)
+	// Email links - no JWT, the token in the link is the credential
+	mux.HandleFunc("/email/verify", emailController.VerifyEmail)
+	mux.HandleFunc("/email/unsubscribe", emailController.Unsubscribe)
 	// Admin endpoints - no JWT, uses admin_key query param
 	mux.HandleFunc("/admin/refresh-peaks", supportController.RefreshPeaks)
+	mux.HandleFunc("/admin/backfill-achievements", achievementsController.BackfillAchievements)
+	mux.HandleFunc("/admin/fill-peak-elevations", supportController.FillPeakElevations)
+	mux.HandleFunc("/admin/peak-merges", peakMergeController.GetProposals)
+	mux.HandleFunc("/admin/peak-merges/scan", peakMergeController.ScanDuplicates)
+	mux.HandleFunc("/admin/peak-merges/confirm", peakMergeController.ConfirmProposal)
+	mux.HandleFunc("/admin/peak-merges/reject", peakMergeController.RejectProposal)
+	mux.HandleFunc("/admin/account-deletions", supportController.GetAccountDeletions)
+	mux.HandleFunc("/admin/account-deletions/retry", supportController.RetryAccountDeletion)
 
-	return &http.Server{
+	server := &http.Server{
 		Addr:    ":8080",
 		Handler: mux,
 	}
+	// Open live streams would otherwise hold up a graceful shutdown
+	server.RegisterOnShutdown(liveService.Close)
+	return server
 }
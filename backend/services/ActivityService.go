@@ -1,9 +1,29 @@
 package services
 
 import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"log"
 	"run-goals/daos"
 	"run-goals/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/twpayne/go-polyline"
+)
+
+var (
+	ErrActivityListSortInvalid  = errors.New("sort must be date, distance, elevation or moving_time")
+	ErrActivityCursorInvalid    = errors.New("cursor is invalid or doesn't match the sort")
+	ErrActivityDateRangeInvalid = errors.New("end_date must not be before start_date")
+)
+
+const (
+	defaultActivityPageSize = 50
+	maxActivityPageSize     = 200
 )
 
 type ActivityServiceInterface interface {
@@ -12,17 +32,20 @@ type ActivityServiceInterface interface {
 }
 
 type ActivityService struct {
-	l           *log.Logger
-	activityDao *daos.ActivityDao
+	l            *log.Logger
+	activityDao  *daos.ActivityDao
+	userPeaksDao *daos.UserPeaksDao
 }
 
 func NewActivityService(
 	l *log.Logger,
 	activityDao *daos.ActivityDao,
+	userPeaksDao *daos.UserPeaksDao,
 ) *ActivityService {
 	return &ActivityService{
-		l:           l,
-		activityDao: activityDao,
+		l:            l,
+		activityDao:  activityDao,
+		userPeaksDao: userPeaksDao,
 	}
 }
 
@@ -43,3 +66,122 @@ func (s *ActivityService) UpsertActivitiesByUserId(id int, activity *models.Acti
 	}
 	return nil
 }
+
+// ListActivities returns one page of the user's activities matching the
+// filter. Pages are keyed on the sort value rather than an offset, so
+// activities synced while paging don't shift or repeat entries.
+func (s *ActivityService) ListActivities(filter models.ActivityListFilter) (*models.ActivityListPage, error) {
+	switch filter.Sort {
+	case "":
+		filter.Sort = models.ActivityListSortDate
+	case models.ActivityListSortDate, models.ActivityListSortDistance,
+		models.ActivityListSortElevation, models.ActivityListSortMovingTime:
+	default:
+		return nil, ErrActivityListSortInvalid
+	}
+	if filter.Cursor != nil && filter.Cursor.Sort != filter.Sort {
+		return nil, ErrActivityCursorInvalid
+	}
+	if filter.StartDate != nil && filter.EndDate != nil && !filter.EndDate.After(*filter.StartDate) {
+		return nil, ErrActivityDateRangeInvalid
+	}
+	if filter.Limit <= 0 || filter.Limit > maxActivityPageSize {
+		filter.Limit = defaultActivityPageSize
+	}
+
+	activities, err := s.activityDao.ListActivities(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.ActivityListPage{Activities: activities, Limit: filter.Limit}
+	if len(activities) > filter.Limit {
+		page.Activities = activities[:filter.Limit]
+		page.NextCursor = EncodeActivityCursor(filter.Sort, page.Activities[filter.Limit-1])
+	}
+	return page, nil
+}
+
+// GetActivityDetail returns one of the user's activities with the peaks
+// summited on it and its route decoded
+func (s *ActivityService) GetActivityDetail(activityID int64, userID int64) (*models.ActivityDetail, error) {
+	activity, err := s.activityDao.GetActivityByID(activityID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrActivityNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if activity.UserID != userID {
+		return nil, ErrActivityNotFound
+	}
+
+	summits, err := s.userPeaksDao.GetActivitySummits(activityID)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &models.ActivityDetail{Activity: activity, Summits: summits, Route: [][2]float64{}}
+	if activity.MapPolyline != "" {
+		coords, _, err := polyline.DecodeCoords([]byte(activity.MapPolyline))
+		if err != nil {
+			// Still worth returning the activity without its route
+			s.l.Printf("Failed to decode polyline for activity %d: %v", activityID, err)
+		}
+		for _, c := range coords {
+			detail.Route = append(detail.Route, [2]float64{c[0], c[1]})
+		}
+	}
+	return detail, nil
+}
+
+// EncodeActivityCursor returns the cursor for the page after activity
+func EncodeActivityCursor(sort models.ActivityListSort, activity models.Activity) string {
+	var value string
+	switch sort {
+	case models.ActivityListSortDistance:
+		value = strconv.FormatFloat(activity.Distance, 'g', -1, 64)
+	case models.ActivityListSortElevation:
+		value = strconv.FormatFloat(activity.Elevation, 'g', -1, 64)
+	case models.ActivityListSortMovingTime:
+		value = strconv.FormatFloat(activity.MovingTime, 'g', -1, 64)
+	default:
+		value = strconv.FormatInt(activity.StartDate.UnixNano(), 10)
+	}
+	raw := fmt.Sprintf("%s|%s|%d", sort, value, activity.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseActivityCursor reads a cursor from EncodeActivityCursor
+func ParseActivityCursor(value string) (*models.ActivityCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrActivityCursorInvalid
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 {
+		return nil, ErrActivityCursorInvalid
+	}
+	id, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return nil, ErrActivityCursorInvalid
+	}
+
+	cursor := &models.ActivityCursor{Sort: models.ActivityListSort(parts[0]), ID: id}
+	switch cursor.Sort {
+	case models.ActivityListSortDate:
+		nanos, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return nil, ErrActivityCursorInvalid
+		}
+		cursor.Date = time.Unix(0, nanos).UTC()
+	case models.ActivityListSortDistance, models.ActivityListSortElevation, models.ActivityListSortMovingTime:
+		cursor.Value, err = strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return nil, ErrActivityCursorInvalid
+		}
+	default:
+		return nil, ErrActivityCursorInvalid
+	}
+	return cursor, nil
+}
@@ -115,6 +115,8 @@ func (service *StravaService) FetchAndStoreUserActivitiesSince(user *models.User
 				StartDate:        t,
 				MapPolyline:      stravaActivity.Map.SummaryPolyline,
 				PhotoURL:         photoURL,
+				Private:          stravaActivity.Private,
+				Visibility:       stravaVisibility(stravaActivity),
 				CreatedAt:        time.Now(),
 				UpdatedAt:        time.Now(),
 			}
@@ -175,6 +177,8 @@ func (service *StravaService) FetchAndStoreDetailedActivity(user *models.User, a
 		PhotoURL:         photoURL,
 		StartDate:        t,
 		MapPolyline:      detailedActivity.Map.SummaryPolyline,
+		Private:          detailedActivity.Private,
+		Visibility:       stravaVisibility(*detailedActivity),
 	}
 	if err := service.activityDao.UpsertActivity(&activity); err != nil {
 		return fmt.Errorf("failed to upsert activity: %w", err)
@@ -183,6 +187,15 @@ func (service *StravaService) FetchAndStoreDetailedActivity(user *models.User, a
 	return nil
 }
 
+// stravaVisibility returns the activity's visibility, falling back to
+// everyone when Strava leaves it out
+func stravaVisibility(activity models.StravaActivity) string {
+	if activity.Visibility == "" {
+		return models.VisibilityEveryone
+	}
+	return activity.Visibility
+}
+
 func (service *StravaService) EnsureValidToken(u *models.User) error {
 	// 1. Check if token is still valid
 	if time.Now().Before(u.ExpiresAt) {
@@ -231,6 +244,32 @@ func (service *StravaService) EnsureValidToken(u *models.User) error {
 	return nil
 }
 
+// Deauthorize revokes our access to the user's Strava account. A token
+// Strava has already revoked counts as done.
+func (service *StravaService) Deauthorize(u *models.User) error {
+	if err := service.EnsureValidToken(u); err != nil {
+		return err
+	}
+
+	formData := url.Values{}
+	formData.Set("access_token", u.AccessToken)
+
+	resp, err := http.Post(
+		"https://www.strava.com/oauth/deauthorize",
+		"application/x-www-form-urlencoded",
+		strings.NewReader(formData.Encode()),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to deauthorize: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusUnauthorized {
+		return fmt.Errorf("deauthorize request failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
 func (service *StravaService) GetUserDistance(u *models.User) (*float64, error) {
 	// 1. Check if we have a recent value
 	distanceCacheTTL, err := strconv.ParseInt(service.config.Strava.DistanceCacheTTL, 10, 64)
@@ -372,6 +411,35 @@ func (service *StravaService) FetchDetailedActivity(accessToken string, activity
 	return &detailedActivity, nil
 }
 
+// FetchActivityStreams fetches the latlng and time streams for an activity,
+// used to work out when each summit was reached
+func (service *StravaService) FetchActivityStreams(accessToken string, activityID int64) (*models.StravaStreams, error) {
+	url := fmt.Sprintf("https://www.strava.com/api/v3/activities/%d/streams?keys=latlng,time&key_by_type=true", activityID)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch activity streams: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch activity streams status %d", resp.StatusCode)
+	}
+
+	var streams models.StravaStreams
+	if err := json.NewDecoder(resp.Body).Decode(&streams); err != nil {
+		return nil, fmt.Errorf("failed to decode activity streams response: %w", err)
+	}
+
+	return &streams, nil
+}
+
 func (s *StravaService) ProcessWebhookEvent(payload models.StravaWebhookPayload) {
 	// Find the user in DB
 	user, err := s.userDao.GetUserByStravaAthleteID(payload.OwnerID)
@@ -0,0 +1,242 @@
+package services
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"run-goals/daos"
+	"run-goals/models"
+	"time"
+)
+
+var (
+	ErrAccountDeletionNotFound   = errors.New("account deletion not found")
+	ErrAccountDeletionInProgress = errors.New("account deletion is already in progress")
+	ErrAccountDeletionNotFailed  = errors.New("only failed deletions can be retried")
+)
+
+// deletionPollInterval is how often the worker looks for deletions it
+// wasn't woken for
+const deletionPollInterval = time.Minute
+
+type AccountDeletionService struct {
+	l                  *log.Logger
+	accountDeletionDao *daos.AccountDeletionDao
+	userDao            *daos.UserDao
+	stravaService      *StravaService
+	dataExportService  *DataExportService
+	wake               chan struct{}
+}
+
+func NewAccountDeletionService(
+	l *log.Logger,
+	accountDeletionDao *daos.AccountDeletionDao,
+	userDao *daos.UserDao,
+	stravaService *StravaService,
+	dataExportService *DataExportService,
+) *AccountDeletionService {
+	return &AccountDeletionService{
+		l:                  l,
+		accountDeletionDao: accountDeletionDao,
+		userDao:            userDao,
+		stravaService:      stravaService,
+		dataExportService:  dataExportService,
+		wake:               make(chan struct{}, 1),
+	}
+}
+
+// ReceiptURL is where the deletion's receipt can be looked up once the
+// account is gone
+func (s *AccountDeletionService) ReceiptURL(deletion *models.AccountDeletion) string {
+	return "/account-deletions/receipt?code=" + url.QueryEscape(deletion.ReceiptCode)
+}
+
+// RequestDeletion queues the user's account for deletion. The returned
+// deletion's receipt code is the only way to look it up afterwards.
+func (s *AccountDeletionService) RequestDeletion(userID int64) (*models.AccountDeletion, error) {
+	code, err := generateReceiptCode()
+	if err != nil {
+		return nil, err
+	}
+	id, err := s.accountDeletionDao.CreateDeletion(userID, code)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAccountDeletionInProgress
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.l.Printf("Account deletion %d queued for user %d", *id, userID)
+	s.notify()
+
+	return s.accountDeletionDao.GetDeletionByID(*id)
+}
+
+// GetReceipt returns the deletion with the receipt code. Errors are left out
+// since the lookup needs no login.
+func (s *AccountDeletionService) GetReceipt(code string) (*models.AccountDeletion, error) {
+	if code == "" {
+		return nil, ErrAccountDeletionNotFound
+	}
+	deletion, err := s.accountDeletionDao.GetDeletionByReceiptCode(code)
+	if err != nil {
+		return nil, err
+	}
+	if deletion == nil {
+		return nil, ErrAccountDeletionNotFound
+	}
+	deletion.Error = nil
+	return deletion, nil
+}
+
+// GetOutstanding lists deletions that haven't completed, for admins
+func (s *AccountDeletionService) GetOutstanding() ([]models.AccountDeletion, error) {
+	return s.accountDeletionDao.GetDeletionsByStatus([]models.AccountDeletionStatus{
+		models.AccountDeletionStatusPending,
+		models.AccountDeletionStatusRunning,
+		models.AccountDeletionStatusFailed,
+	})
+}
+
+// RetryDeletion puts a failed deletion back in the queue
+func (s *AccountDeletionService) RetryDeletion(id int64) error {
+	retried, err := s.accountDeletionDao.RetryDeletion(id)
+	if err != nil {
+		return err
+	}
+	if !retried {
+		deletion, err := s.accountDeletionDao.GetDeletionByID(id)
+		if err != nil {
+			return err
+		}
+		if deletion == nil {
+			return ErrAccountDeletionNotFound
+		}
+		return ErrAccountDeletionNotFailed
+	}
+	s.notify()
+	return nil
+}
+
+// Run deletes queued accounts one at a time. It never returns. Deletions
+// left running by a replica that stopped sending heartbeats are picked up
+// again.
+func (s *AccountDeletionService) Run() {
+	for {
+		if n, err := s.accountDeletionDao.RequeueStaleDeletions(jobStaleAfter); err != nil {
+			s.l.Printf("Failed to requeue interrupted account deletions: %v", err)
+		} else if n > 0 {
+			s.l.Printf("Requeued %d interrupted account deletions", n)
+		}
+
+		for {
+			deletion, err := s.accountDeletionDao.StartNextDeletion()
+			if err != nil {
+				s.l.Printf("Failed to start account deletion: %v", err)
+				break
+			}
+			if deletion == nil {
+				break
+			}
+			if err := s.process(*deletion); err != nil {
+				s.l.Printf("Account deletion %d failed: %v", deletion.ID, err)
+				s.accountDeletionDao.FailDeletion(deletion.ID, err.Error())
+			}
+		}
+
+		select {
+		case <-s.wake:
+		case <-time.After(deletionPollInterval):
+		}
+	}
+}
+
+// process runs every step of a deletion. Each step can be run again, so a
+// failed deletion is retried from the top.
+func (s *AccountDeletionService) process(deletion models.AccountDeletion) error {
+	s.l.Printf("Deleting account of user %d (deletion %d, attempt %d)", deletion.UserID, deletion.ID, deletion.Attempts)
+	stopHeartbeat := startHeartbeat(func() { s.accountDeletionDao.HeartbeatDeletion(deletion.ID) })
+	defer stopHeartbeat()
+
+	receipt := models.AccountDeletionReceipt{RowsDeleted: map[string]int64{}}
+
+	user, err := s.userDao.GetUserByID(deletion.UserID)
+	if errors.Is(err, daos.ErrUserNotFound) {
+		// The purge committed but completing the job didn't
+		receipt.Verified = true
+		receipt.CompletedAt = time.Now().UTC()
+		return s.accountDeletionDao.CompleteDeletion(deletion.ID, receipt)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Losing Strava access doesn't stop the deletion, the tokens go with the
+	// user either way. Empty tokens mean an earlier attempt revoked them.
+	if user.AccessToken == "" && user.RefreshToken == "" {
+		receipt.StravaDeauthorized = true
+	} else if err := s.stravaService.Deauthorize(user); err != nil {
+		s.l.Printf("Failed to deauthorize Strava for user %d: %v", user.ID, err)
+		receipt.StravaError = "Strava access could not be revoked, it can be removed from Strava's settings"
+	} else {
+		receipt.StravaDeauthorized = true
+		if err := s.userDao.ClearStravaTokens(user.ID); err != nil {
+			return err
+		}
+	}
+
+	transfer, err := s.accountDeletionDao.TransferOwnership(user.ID)
+	if err != nil {
+		return fmt.Errorf("transferring ownership: %w", err)
+	}
+	receipt.GroupsTransferred = transfer.GroupsTransferred
+	receipt.GroupsClosed = transfer.GroupsClosed
+	receipt.ChallengesTransferred = transfer.ChallengesTransferred
+	receipt.ChallengesClosed = transfer.ChallengesClosed
+	receipt.SeriesTransferred = transfer.SeriesTransferred
+
+	anonymised, err := s.accountDeletionDao.AnonymiseLeaderboardHistory(user.ID, deletion.ID)
+	if err != nil {
+		return fmt.Errorf("anonymising leaderboards: %w", err)
+	}
+	receipt.LeaderboardAnonymised = anonymised
+
+	files, err := s.dataExportService.DeleteUserExports(user.ID)
+	if err != nil {
+		return fmt.Errorf("deleting export files: %w", err)
+	}
+	receipt.ExportFilesDeleted = files
+
+	rows, err := s.accountDeletionDao.PurgeUser(user.ID)
+	if err != nil {
+		return fmt.Errorf("purging user: %w", err)
+	}
+	receipt.RowsDeleted = rows
+	receipt.Verified = true
+	receipt.CompletedAt = time.Now().UTC()
+
+	if err := s.accountDeletionDao.CompleteDeletion(deletion.ID, receipt); err != nil {
+		return err
+	}
+	s.l.Printf("Account deletion %d complete", deletion.ID)
+	return nil
+}
+
+// notify wakes the worker, unless it's already due to look
+func (s *AccountDeletionService) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func generateReceiptCode() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
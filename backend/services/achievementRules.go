@@ -0,0 +1,234 @@
+package services
+
+import (
+	"run-goals/models"
+	"sort"
+	"time"
+)
+
+// achievementHistory is everything the achievement rules look at for one user
+type achievementHistory struct {
+	summits    []models.SummitWithPeak // Oldest first
+	activities []models.Activity       // Oldest first
+	regions    map[string]models.RegionPeakStats
+	wins       []models.ChallengeParticipant // Oldest first
+}
+
+// achievementAward is the moment a rule was first satisfied
+type achievementAward struct {
+	Scope       string
+	ActivityID  *int64
+	PeakID      *int64
+	ChallengeID *int64
+	AwardedAt   time.Time
+}
+
+// evaluateAchievement returns every award the definition has earned over the
+// history. Rules walk the history in time order so the award records the
+// activity that crossed the line, which also makes backfills accurate.
+func evaluateAchievement(definition models.AchievementDefinition, history achievementHistory) []achievementAward {
+	minCount := definition.MinCount
+	if minCount < 1 {
+		minCount = 1
+	}
+
+	switch definition.RuleType {
+	case models.AchievementRuleUniquePeaks:
+		return nthDistinctSummit(history.summits, minCount, func(models.SummitWithPeak) bool { return true })
+	case models.AchievementRulePeaksAbove:
+		if definition.Threshold == nil {
+			return nil
+		}
+		return nthDistinctSummit(history.summits, minCount, func(s models.SummitWithPeak) bool {
+			return s.Elevation >= *definition.Threshold
+		})
+	case models.AchievementRuleRegionComplete:
+		return evaluateRegionComplete(definition, history)
+	case models.AchievementRuleHighestPeak:
+		return evaluateHighestPeak(definition, history)
+	case models.AchievementRuleElevationTotal:
+		return evaluateElevationTotal(definition, history)
+	case models.AchievementRuleStreak:
+		return evaluateStreak(minCount, history)
+	case models.AchievementRuleEarlyBird:
+		return evaluateEarlyBird(definition, minCount, history)
+	case models.AchievementRuleChallengeWin:
+		return evaluateChallengeWins(minCount, history)
+	}
+	return nil
+}
+
+// nthDistinctSummit awards on the summit of the nth distinct peak matching the filter
+func nthDistinctSummit(summits []models.SummitWithPeak, n int, matches func(models.SummitWithPeak) bool) []achievementAward {
+	seen := map[int64]bool{}
+	for _, summit := range summits {
+		if seen[summit.PeakID] || !matches(summit) {
+			continue
+		}
+		seen[summit.PeakID] = true
+		if len(seen) == n {
+			return []achievementAward{summitAward("", summit)}
+		}
+	}
+	return nil
+}
+
+// evaluateRegionComplete awards once every peak in a region has been summited
+func evaluateRegionComplete(definition models.AchievementDefinition, history achievementHistory) []achievementAward {
+	var awards []achievementAward
+	seen := map[string]map[int64]bool{}
+	for _, summit := range history.summits {
+		if !inDefinitionRegion(definition, summit.Region) {
+			continue
+		}
+		stats, ok := history.regions[summit.Region]
+		if !ok {
+			continue
+		}
+		if seen[summit.Region] == nil {
+			seen[summit.Region] = map[int64]bool{}
+		}
+		if seen[summit.Region][summit.PeakID] {
+			continue
+		}
+		seen[summit.Region][summit.PeakID] = true
+		if len(seen[summit.Region]) == stats.PeakCount {
+			awards = append(awards, summitAward(summit.Region, summit))
+		}
+	}
+	return awards
+}
+
+// evaluateHighestPeak awards on the first summit of a region's highest peak
+func evaluateHighestPeak(definition models.AchievementDefinition, history achievementHistory) []achievementAward {
+	var awards []achievementAward
+	awarded := map[string]bool{}
+	for _, summit := range history.summits {
+		if awarded[summit.Region] || !inDefinitionRegion(definition, summit.Region) {
+			continue
+		}
+		stats, ok := history.regions[summit.Region]
+		if !ok || stats.HighestPeakID != summit.PeakID {
+			continue
+		}
+		awarded[summit.Region] = true
+		awards = append(awards, summitAward(summit.Region, summit))
+	}
+	return awards
+}
+
+// evaluateElevationTotal awards on the activity that takes total gain past the threshold
+func evaluateElevationTotal(definition models.AchievementDefinition, history achievementHistory) []achievementAward {
+	if definition.Threshold == nil {
+		return nil
+	}
+	var total float64
+	for _, activity := range history.activities {
+		total += activity.Elevation
+		if total >= *definition.Threshold {
+			return []achievementAward{activityAward(activity)}
+		}
+	}
+	return nil
+}
+
+// evaluateStreak awards on the activity that makes n consecutive days
+func evaluateStreak(n int, history achievementHistory) []achievementAward {
+	var lastDay time.Time
+	streak := 0
+	for _, activity := range history.activities {
+		day := activityDay(activity.StartDate)
+		switch {
+		case streak > 0 && day.Equal(lastDay):
+			continue
+		case streak > 0 && day.Equal(lastDay.AddDate(0, 0, 1)):
+			streak++
+		default:
+			streak = 1
+		}
+		lastDay = day
+		if streak == n {
+			return []achievementAward{activityAward(activity)}
+		}
+	}
+	return nil
+}
+
+// evaluateEarlyBird awards on the nth summit reached before the threshold hour.
+// Only summits with a known summit time count; the activity start says nothing
+// about when the top was reached.
+func evaluateEarlyBird(definition models.AchievementDefinition, n int, history achievementHistory) []achievementAward {
+	if definition.Threshold == nil {
+		return nil
+	}
+	count := 0
+	for _, summit := range history.summits {
+		if summit.SummitTime == nil {
+			continue
+		}
+		// Activity times are local wall-clock times
+		t := *summit.SummitTime
+		hour := float64(t.Hour()) + float64(t.Minute())/60
+		if hour >= *definition.Threshold {
+			continue
+		}
+		count++
+		if count == n {
+			return []achievementAward{summitAward("", summit)}
+		}
+	}
+	return nil
+}
+
+// evaluateChallengeWins awards on the nth competitive challenge win
+func evaluateChallengeWins(n int, history achievementHistory) []achievementAward {
+	if len(history.wins) < n {
+		return nil
+	}
+	win := history.wins[n-1]
+	challengeID := win.ChallengeID
+	awardedAt := time.Now()
+	if win.CompletedAt != nil {
+		awardedAt = *win.CompletedAt
+	}
+	return []achievementAward{{
+		ActivityID:  win.SatisfiedByActivityID,
+		ChallengeID: &challengeID,
+		AwardedAt:   awardedAt,
+	}}
+}
+
+func inDefinitionRegion(definition models.AchievementDefinition, region string) bool {
+	if region == "" {
+		return false
+	}
+	return definition.Region == nil || *definition.Region == region
+}
+
+func summitAward(scope string, summit models.SummitWithPeak) achievementAward {
+	activityID, peakID := summit.ActivityID, summit.PeakID
+	return achievementAward{
+		Scope:      scope,
+		ActivityID: &activityID,
+		PeakID:     &peakID,
+		AwardedAt:  summit.SummitMoment(),
+	}
+}
+
+func activityAward(activity models.Activity) achievementAward {
+	activityID := activity.ID
+	return achievementAward{
+		ActivityID: &activityID,
+		AwardedAt:  activity.StartDate,
+	}
+}
+
+func activityDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func sortActivitiesByStart(activities []models.Activity) {
+	sort.SliceStable(activities, func(i, j int) bool {
+		return activities[i].StartDate.Before(activities[j].StartDate)
+	})
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"log"
+	"run-goals/daos"
+	"run-goals/models"
+)
+
+type AchievementServiceInterface interface {
+	GetDefinitions() ([]models.AchievementDefinition, error)
+	GetUserAchievements(userID int64) ([]models.UserAchievementWithDetails, error)
+	GetGroupAchievements(groupID int64, limit int) ([]models.UserAchievementWithDetails, error)
+	EvaluateUser(userID int64) ([]models.UserAchievement, error)
+	EvaluateAllUsers() (int, error)
+}
+
+type AchievementService struct {
+	l              *log.Logger
+	achievementDao *daos.AchievementDao
+	activityDao    *daos.ActivityDao
+	userDao        *daos.UserDao
+}
+
+func NewAchievementService(
+	l *log.Logger,
+	achievementDao *daos.AchievementDao,
+	activityDao *daos.ActivityDao,
+	userDao *daos.UserDao,
+) *AchievementService {
+	return &AchievementService{
+		l:              l,
+		achievementDao: achievementDao,
+		activityDao:    activityDao,
+		userDao:        userDao,
+	}
+}
+
+func (s *AchievementService) GetDefinitions() ([]models.AchievementDefinition, error) {
+	return s.achievementDao.GetActiveDefinitions()
+}
+
+func (s *AchievementService) GetUserAchievements(userID int64) ([]models.UserAchievementWithDetails, error) {
+	return s.achievementDao.GetUserAchievements(userID)
+}
+
+func (s *AchievementService) GetGroupAchievements(groupID int64, limit int) ([]models.UserAchievementWithDetails, error) {
+	return s.achievementDao.GetGroupAchievements(groupID, limit)
+}
+
+// ==================== Evaluation ====================
+
+// EvaluateUser checks every achievement rule against the user's history and
+// stores any new awards. This is called after activity import and summit
+// detection, and by the backfill.
+func (s *AchievementService) EvaluateUser(userID int64) ([]models.UserAchievement, error) {
+	definitions, err := s.achievementDao.GetActiveDefinitions()
+	if err != nil {
+		return nil, err
+	}
+	regions, err := s.regionStats()
+	if err != nil {
+		return nil, err
+	}
+	return s.evaluateUser(userID, definitions, regions)
+}
+
+// EvaluateAllUsers evaluates every user, backfilling awards for existing history.
+// Returns the number of new awards.
+func (s *AchievementService) EvaluateAllUsers() (int, error) {
+	users, err := s.userDao.GetUsers()
+	if err != nil {
+		s.l.Printf("Error getting users for achievement evaluation: %v", err)
+		return 0, err
+	}
+	definitions, err := s.achievementDao.GetActiveDefinitions()
+	if err != nil {
+		return 0, err
+	}
+	regions, err := s.regionStats()
+	if err != nil {
+		return 0, err
+	}
+
+	awarded := 0
+	for _, user := range users {
+		awards, err := s.evaluateUser(user.ID, definitions, regions)
+		if err != nil {
+			s.l.Printf("Error evaluating achievements for user %d: %v", user.ID, err)
+			// Continue with other users even if one fails
+			continue
+		}
+		awarded += len(awards)
+	}
+
+	s.l.Printf("Achievement evaluation complete: %d new awards for %d users", awarded, len(users))
+	return awarded, nil
+}
+
+func (s *AchievementService) evaluateUser(userID int64, definitions []models.AchievementDefinition, regions map[string]models.RegionPeakStats) ([]models.UserAchievement, error) {
+	history, err := s.loadHistory(userID, regions)
+	if err != nil {
+		return nil, err
+	}
+
+	var awarded []models.UserAchievement
+	for _, definition := range definitions {
+		for _, award := range evaluateAchievement(definition, history) {
+			userAchievement := models.UserAchievement{
+				UserID:        userID,
+				AchievementID: definition.ID,
+				Scope:         award.Scope,
+				ActivityID:    award.ActivityID,
+				PeakID:        award.PeakID,
+				ChallengeID:   award.ChallengeID,
+				AwardedAt:     award.AwardedAt,
+			}
+			isNew, err := s.achievementDao.AwardAchievement(userAchievement)
+			if err != nil {
+				return awarded, err
+			}
+			if isNew {
+				s.l.Printf("Achievement unlocked! user=%d achievement=%s scope=%q", userID, definition.Key, award.Scope)
+				awarded = append(awarded, userAchievement)
+			}
+		}
+	}
+
+	return awarded, nil
+}
+
+func (s *AchievementService) loadHistory(userID int64, regions map[string]models.RegionPeakStats) (achievementHistory, error) {
+	history := achievementHistory{regions: regions}
+
+	summits, err := s.achievementDao.GetSummitHistory(userID)
+	if err != nil {
+		return history, err
+	}
+	history.summits = summits
+
+	activities, err := s.activityDao.GetActivitiesByUserID(userID)
+	if err != nil {
+		return history, err
+	}
+	sortActivitiesByStart(activities)
+	history.activities = activities
+
+	wins, err := s.achievementDao.GetChallengeWins(userID)
+	if err != nil {
+		return history, err
+	}
+	history.wins = wins
+
+	return history, nil
+}
+
+func (s *AchievementService) regionStats() (map[string]models.RegionPeakStats, error) {
+	stats, err := s.achievementDao.GetRegionPeakStats()
+	if err != nil {
+		return nil, err
+	}
+	regions := make(map[string]models.RegionPeakStats, len(stats))
+	for _, region := range stats {
+		regions[region.Region] = region
+	}
+	return regions, nil
+}
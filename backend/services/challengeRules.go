@@ -0,0 +1,264 @@
+package services
+
+import (
+	"run-goals/models"
+	"sort"
+	"time"
+)
+
+// ruleResult is a participant's progress under a challenge completion rule
+type ruleResult struct {
+	PeaksCompleted int
+	Completed      bool
+	ActivityID     *int64     // Activity that satisfied (or completed) the challenge
+	WindowStart    *time.Time // First summit counted towards the best attempt
+	WindowEnd      *time.Time // Last summit counted towards the best attempt
+}
+
+// usesSummitHistory reports whether the rule needs every summit of the user
+// rather than just the first credited summit per peak.
+func usesSummitHistory(rule models.CompletionRule) bool {
+	return rule == models.CompletionRuleOrdered ||
+		rule == models.CompletionRuleSingleActivity ||
+		rule == models.CompletionRuleTimeWindow
+}
+
+// evaluateCompletionRule works out how many challenge peaks count under the
+// challenge's completion rule. peaks must be in sort order; summits may be in
+// any order and may include repeat summits of the same peak.
+func evaluateCompletionRule(challenge models.Challenge, peaks []models.ChallengePeakWithDetails, summits []models.UserPeak) ruleResult {
+	sorted := make([]models.UserPeak, len(summits))
+	copy(sorted, summits)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i].SummitMoment(), sorted[j].SummitMoment()
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	switch challenge.CompletionRule {
+	case models.CompletionRuleOrdered:
+		return evaluateOrdered(peaks, sorted)
+	case models.CompletionRuleSingleActivity:
+		return evaluateSingleActivity(peaks, sorted)
+	case models.CompletionRuleTimeWindow:
+		var window time.Duration
+		if challenge.MaxWindowHours != nil {
+			window = time.Duration(*challenge.MaxWindowHours * float64(time.Hour))
+		}
+		return evaluateTimeWindow(peaks, sorted, window)
+	}
+	return ruleResult{}
+}
+
+// evaluateOrdered walks the summits in time order, advancing through the
+// challenge peaks only when the next expected peak is bagged.
+func evaluateOrdered(peaks []models.ChallengePeakWithDetails, summits []models.UserPeak) ruleResult {
+	result := ruleResult{}
+	if len(peaks) == 0 {
+		return result
+	}
+
+	next := 0
+	for i := range summits {
+		summit := summits[i]
+		if summit.PeakID != peaks[next].PeakID {
+			continue
+		}
+		moment := summit.SummitMoment()
+		if next == 0 {
+			result.WindowStart = &moment
+		}
+		result.WindowEnd = &moment
+		result.ActivityID = &summit.ActivityID
+		next++
+		if next == len(peaks) {
+			break
+		}
+	}
+
+	result.PeaksCompleted = next
+	result.Completed = next == len(peaks)
+	return result
+}
+
+// evaluateSingleActivity finds the activity that bagged the most challenge peaks
+func evaluateSingleActivity(peaks []models.ChallengePeakWithDetails, summits []models.UserPeak) ruleResult {
+	result := ruleResult{}
+	if len(peaks) == 0 {
+		return result
+	}
+	inChallenge := challengePeakSet(peaks)
+
+	type attempt struct {
+		peaks map[int64]bool
+		first time.Time
+		last  time.Time
+	}
+	attempts := map[int64]*attempt{}
+	var order []int64
+
+	for _, summit := range summits {
+		if !inChallenge[summit.PeakID] {
+			continue
+		}
+		a, ok := attempts[summit.ActivityID]
+		if !ok {
+			a = &attempt{peaks: map[int64]bool{}, first: summit.SummitMoment()}
+			attempts[summit.ActivityID] = a
+			order = append(order, summit.ActivityID)
+		}
+		a.peaks[summit.PeakID] = true
+		a.last = summit.SummitMoment()
+	}
+
+	// Earliest activity wins ties
+	for _, activityID := range order {
+		a := attempts[activityID]
+		if len(a.peaks) > result.PeaksCompleted {
+			id := activityID
+			first, last := a.first, a.last
+			result.PeaksCompleted = len(a.peaks)
+			result.ActivityID = &id
+			result.WindowStart = &first
+			result.WindowEnd = &last
+		}
+	}
+
+	result.Completed = result.PeaksCompleted == len(inChallenge)
+	return result
+}
+
+// evaluateTimeWindow slides a window of the given length over the summits and
+// keeps the window containing the most distinct challenge peaks.
+func evaluateTimeWindow(peaks []models.ChallengePeakWithDetails, summits []models.UserPeak, window time.Duration) ruleResult {
+	result := ruleResult{}
+	if len(peaks) == 0 || window <= 0 {
+		return result
+	}
+	inChallenge := challengePeakSet(peaks)
+
+	var relevant []models.UserPeak
+	for _, summit := range summits {
+		if inChallenge[summit.PeakID] {
+			relevant = append(relevant, summit)
+		}
+	}
+
+	counts := map[int64]int{}
+	start := 0
+	for end := range relevant {
+		counts[relevant[end].PeakID]++
+		for relevant[end].SummitMoment().Sub(relevant[start].SummitMoment()) > window {
+			counts[relevant[start].PeakID]--
+			if counts[relevant[start].PeakID] == 0 {
+				delete(counts, relevant[start].PeakID)
+			}
+			start++
+		}
+
+		if len(counts) > result.PeaksCompleted {
+			first, last := relevant[start].SummitMoment(), relevant[end].SummitMoment()
+			activityID := relevant[end].ActivityID
+			result.PeaksCompleted = len(counts)
+			result.WindowStart = &first
+			result.WindowEnd = &last
+			result.ActivityID = &activityID
+		}
+	}
+
+	result.Completed = result.PeaksCompleted == len(inChallenge)
+	return result
+}
+
+// evaluateFastestTime finds the quickest activity that reached every challenge
+// peak. With one peak the time is the ascent from the activity start; with
+// several it is the time from the first challenge summit to the last.
+// Summits without stream timings can't be ranked fairly and are ignored, as on
+// the fastest time leaderboards.
+func evaluateFastestTime(peaks []models.ChallengePeakWithDetails, summits []models.UserPeak) (*int, ruleResult) {
+	result := ruleResult{}
+	if len(peaks) == 0 {
+		return nil, result
+	}
+	inChallenge := challengePeakSet(peaks)
+
+	type attempt struct {
+		elapsed map[int64]int // first reach of each peak, seconds from activity start
+		first   time.Time
+		last    time.Time
+	}
+	attempts := map[int64]*attempt{}
+	var order []int64
+
+	for _, summit := range summits {
+		if !inChallenge[summit.PeakID] || summit.ElapsedSeconds == nil {
+			continue
+		}
+		if summit.TimingSource == nil || *summit.TimingSource != models.SummitTimingSourceStream {
+			continue
+		}
+		a, ok := attempts[summit.ActivityID]
+		if !ok {
+			a = &attempt{elapsed: map[int64]int{}, first: summit.SummitMoment(), last: summit.SummitMoment()}
+			attempts[summit.ActivityID] = a
+			order = append(order, summit.ActivityID)
+		}
+		if prev, seen := a.elapsed[summit.PeakID]; !seen || *summit.ElapsedSeconds < prev {
+			a.elapsed[summit.PeakID] = *summit.ElapsedSeconds
+		}
+		if summit.SummitMoment().Before(a.first) {
+			a.first = summit.SummitMoment()
+		}
+		if summit.SummitMoment().After(a.last) {
+			a.last = summit.SummitMoment()
+		}
+		if len(a.elapsed) > result.PeaksCompleted {
+			result.PeaksCompleted = len(a.elapsed)
+		}
+	}
+
+	var best *int
+	for _, activityID := range order {
+		a := attempts[activityID]
+		if len(a.elapsed) < len(inChallenge) {
+			continue
+		}
+
+		minElapsed, maxElapsed := -1, 0
+		for _, elapsed := range a.elapsed {
+			if minElapsed < 0 || elapsed < minElapsed {
+				minElapsed = elapsed
+			}
+			if elapsed > maxElapsed {
+				maxElapsed = elapsed
+			}
+		}
+		seconds := maxElapsed - minElapsed
+		if len(inChallenge) == 1 {
+			seconds = maxElapsed
+		}
+
+		// Earliest activity wins ties
+		if best == nil || seconds < *best {
+			id := activityID
+			first, last := a.first, a.last
+			best = &seconds
+			result.ActivityID = &id
+			result.WindowStart = &first
+			result.WindowEnd = &last
+		}
+	}
+
+	result.Completed = best != nil
+	return best, result
+}
+
+func challengePeakSet(peaks []models.ChallengePeakWithDetails) map[int64]bool {
+	set := make(map[int64]bool, len(peaks))
+	for _, peak := range peaks {
+		set[peak.PeakID] = true
+	}
+	return set
+}
@@ -0,0 +1,394 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"run-goals/daos"
+	"run-goals/models"
+	"time"
+)
+
+var (
+	ErrSeriesNotFound      = errors.New("challenge series not found")
+	ErrNotSeriesOwner      = errors.New("user is not the series owner")
+	ErrRecurrenceInvalid   = errors.New("invalid recurrence rule")
+	ErrSeriesStartRequired = errors.New("series start date is required")
+	ErrNotSeriesGroupAdmin = errors.New("only group admins can create series for the group")
+)
+
+type ChallengeSeriesServiceInterface interface {
+	CreateSeries(userID int64, series models.ChallengeSeries) (*models.ChallengeSeries, error)
+	GetSeries(id int64) (*models.ChallengeSeries, error)
+	GetSeriesForUser(userID int64) ([]models.ChallengeSeries, error)
+	UpdateSeries(id int64, userID int64, series models.ChallengeSeries, isActive *bool) error
+	DeleteSeries(id int64, userID int64) error
+	GetSeriesHistory(id int64) ([]models.ChallengeSeriesInstanceWithStats, error)
+	GetSeriesLeaderboard(id int64, viewerID int64) ([]models.SeriesLeaderboardEntry, error)
+	InstantiateDueSeries() error
+}
+
+type ChallengeSeriesService struct {
+	l                *log.Logger
+	seriesDao        *daos.ChallengeSeriesDao
+	challengeDao     *daos.ChallengeDao
+	groupsDao        *daos.GroupsDao
+	challengeService *ChallengeService
+}
+
+func NewChallengeSeriesService(
+	l *log.Logger,
+	seriesDao *daos.ChallengeSeriesDao,
+	challengeDao *daos.ChallengeDao,
+	groupsDao *daos.GroupsDao,
+	challengeService *ChallengeService,
+) *ChallengeSeriesService {
+	return &ChallengeSeriesService{
+		l:                l,
+		seriesDao:        seriesDao,
+		challengeDao:     challengeDao,
+		groupsDao:        groupsDao,
+		challengeService: challengeService,
+	}
+}
+
+// ==================== Series CRUD ====================
+
+func (s *ChallengeSeriesService) CreateSeries(userID int64, series models.ChallengeSeries) (*models.ChallengeSeries, error) {
+	if err := validateRecurrence(series); err != nil {
+		return nil, err
+	}
+	if series.StartsAt.IsZero() {
+		return nil, ErrSeriesStartRequired
+	}
+	// Each instance is shared with the group, so only its admins can set one up
+	if series.CreatedByGroupID != nil {
+		role, err := s.groupsDao.GetGroupMemberRole(*series.CreatedByGroupID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil || *role != "admin" {
+			return nil, ErrNotSeriesGroupAdmin
+		}
+	}
+
+	series.CreatedByUserID = &userID
+	// Match what Postgres stores, instances are only created from the stored
+	// next period start
+	series.StartsAt = series.StartsAt.Truncate(time.Microsecond)
+	series.NextPeriodStart = series.StartsAt
+	series.IsActive = true
+	if err := prepareSeriesTemplate(&series); err != nil {
+		return nil, err
+	}
+
+	id, err := s.seriesDao.CreateSeries(series)
+	if err != nil {
+		s.l.Printf("Error creating challenge series: %v", err)
+		return nil, err
+	}
+	series.ID = *id
+
+	// Create the first instance straight away if the series has already started
+	if !series.StartsAt.After(time.Now()) {
+		if err := s.instantiateSeries(series, time.Now()); err != nil {
+			s.l.Printf("Error creating first instance for series %d: %v", series.ID, err)
+		}
+	}
+
+	return &series, nil
+}
+
+func (s *ChallengeSeriesService) GetSeries(id int64) (*models.ChallengeSeries, error) {
+	series, err := s.seriesDao.GetSeriesByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if series == nil {
+		return nil, ErrSeriesNotFound
+	}
+	return series, nil
+}
+
+func (s *ChallengeSeriesService) GetSeriesForUser(userID int64) ([]models.ChallengeSeries, error) {
+	return s.seriesDao.GetSeriesForUser(userID)
+}
+
+// UpdateSeries changes the template for future instances. The recurrence rule
+// and start date are fixed once a series exists; instances already created are
+// left untouched. A nil isActive keeps the series as it is.
+func (s *ChallengeSeriesService) UpdateSeries(id int64, userID int64, series models.ChallengeSeries, isActive *bool) error {
+	existing, err := s.getOwnedSeries(id, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := prepareSeriesTemplate(&series); err != nil {
+		return err
+	}
+	series.IsActive = existing.IsActive
+	if isActive != nil {
+		series.IsActive = *isActive
+	}
+
+	series.ID = existing.ID
+	series.Recurrence = existing.Recurrence
+	series.IntervalDays = existing.IntervalDays
+	return s.seriesDao.UpdateSeries(series)
+}
+
+func (s *ChallengeSeriesService) DeleteSeries(id int64, userID int64) error {
+	if _, err := s.getOwnedSeries(id, userID); err != nil {
+		return err
+	}
+	return s.seriesDao.DeleteSeries(id)
+}
+
+func (s *ChallengeSeriesService) GetSeriesHistory(id int64) ([]models.ChallengeSeriesInstanceWithStats, error) {
+	if _, err := s.GetSeries(id); err != nil {
+		return nil, err
+	}
+	return s.seriesDao.GetSeriesHistory(id)
+}
+
+func (s *ChallengeSeriesService) GetSeriesLeaderboard(id int64, viewerID int64) ([]models.SeriesLeaderboardEntry, error) {
+	if _, err := s.GetSeries(id); err != nil {
+		return nil, err
+	}
+	return s.seriesDao.GetSeriesLeaderboard(id, viewerID)
+}
+
+func (s *ChallengeSeriesService) getOwnedSeries(id int64, userID int64) (*models.ChallengeSeries, error) {
+	existing, err := s.GetSeries(id)
+	if err != nil {
+		return nil, err
+	}
+	if existing.CreatedByUserID == nil || *existing.CreatedByUserID != userID {
+		return nil, ErrNotSeriesOwner
+	}
+	return existing, nil
+}
+
+// ==================== Scheduling ====================
+
+// InstantiateDueSeries creates the current period's challenge for every series
+// whose next period has started. This is called periodically by the scheduler.
+func (s *ChallengeSeriesService) InstantiateDueSeries() error {
+	now := time.Now()
+	due, err := s.seriesDao.GetDueSeries(now)
+	if err != nil {
+		s.l.Printf("Error getting due challenge series: %v", err)
+		return err
+	}
+
+	for _, series := range due {
+		if err := s.instantiateSeries(series, now); err != nil {
+			s.l.Printf("Error instantiating challenge series %d: %v", series.ID, err)
+			// Continue with other series even if one fails
+		}
+	}
+
+	return nil
+}
+
+// instantiateSeries creates the challenge for the period containing now and
+// advances the series. Periods missed while the scheduler wasn't running are
+// skipped rather than back-filled, as their challenges would already be over,
+// but are logged. The challenge, its instance and the series' next period are
+// written together, so a failure leaves the series to be retried next run
+// rather than creating the period twice.
+func (s *ChallengeSeriesService) instantiateSeries(series models.ChallengeSeries, now time.Time) error {
+	periodStart := series.NextPeriodStart
+	periodEnd := nextPeriodStart(series, periodStart)
+	missed := 0
+	for !periodEnd.After(now) {
+		periodStart = periodEnd
+		periodEnd = nextPeriodStart(series, periodStart)
+		missed++
+	}
+	if missed > 0 {
+		s.l.Printf("Series %d skipped %d missed periods from %s", series.ID, missed, series.NextPeriodStart.Format(time.RFC3339))
+	}
+
+	// Series has run its course
+	if series.EndsAt != nil && !periodStart.Before(*series.EndsAt) {
+		return s.seriesDao.AdvanceSeries(series.ID, periodStart, false)
+	}
+
+	previous, err := s.seriesDao.GetLatestInstance(series.ID)
+	if err != nil {
+		return err
+	}
+	instanceNumber := 1
+	if previous != nil {
+		instanceNumber = previous.InstanceNumber + 1
+	}
+
+	// Deadline is a date, so the last day of the period is inclusive
+	deadline := periodEnd.AddDate(0, 0, -1)
+	challenge := seriesTemplate(series)
+	challenge.Name = fmt.Sprintf("%s (%s)", series.Name, periodLabel(series, periodStart, deadline))
+	challenge.StartDate = &periodStart
+	challenge.Deadline = &deadline
+	challenge.CreatedByUserID = series.CreatedByUserID
+	if err := s.challengeService.prepareChallenge(&challenge); err != nil {
+		return err
+	}
+
+	isActive := series.EndsAt == nil || periodEnd.Before(*series.EndsAt)
+	challengeID, err := s.seriesDao.CreateInstanceChallenge(challenge, series.PeakIDs, models.ChallengeSeriesInstance{
+		SeriesID:       series.ID,
+		InstanceNumber: instanceNumber,
+		PeriodStart:    periodStart,
+		PeriodEnd:      periodEnd,
+	}, series.NextPeriodStart, isActive)
+	if err != nil {
+		return err
+	}
+	if challengeID == nil {
+		s.l.Printf("Series %d was already advanced past %s, skipping", series.ID, series.NextPeriodStart.Format(time.RFC3339))
+		return nil
+	}
+
+	if series.CreatedByUserID != nil {
+		s.challengeService.joinCreator(*challengeID, *series.CreatedByUserID)
+	}
+
+	// Group series are shared with the group each period
+	if series.CreatedByGroupID != nil {
+		err = s.challengeService.AddGroupToChallenge(*challengeID, *series.CreatedByGroupID, nil)
+		if err != nil {
+			s.l.Printf("Error adding group %d to series instance %d: %v", *series.CreatedByGroupID, *challengeID, err)
+		}
+	}
+
+	if series.CarryOverParticipants && previous != nil {
+		s.carryOverParticipants(previous.ChallengeID, *challengeID)
+	}
+
+	s.l.Printf("Created instance %d of series %d as challenge %d", instanceNumber, series.ID, *challengeID)
+	return nil
+}
+
+// carryOverParticipants joins everyone from the previous instance to the new one
+func (s *ChallengeSeriesService) carryOverParticipants(fromChallengeID int64, toChallengeID int64) {
+	participants, err := s.challengeDao.GetChallengeParticipants(fromChallengeID)
+	if err != nil {
+		s.l.Printf("Error getting participants to carry over from challenge %d: %v", fromChallengeID, err)
+		return
+	}
+
+	for _, p := range participants {
+		err := s.challengeService.JoinChallenge(toChallengeID, p.UserID)
+		if err != nil && !errors.Is(err, ErrAlreadyParticipant) {
+			s.l.Printf("Error carrying over user %d to challenge %d: %v", p.UserID, toChallengeID, err)
+		}
+	}
+}
+
+// ==================== Recurrence helpers ====================
+
+// prepareSeriesTemplate fills in the defaults of a series being created or
+// updated, the same as one-off challenges get, and validates it
+func prepareSeriesTemplate(series *models.ChallengeSeries) error {
+	if series.GoalType == "" {
+		series.GoalType = models.GoalTypeSpecificSummits
+	}
+	if series.CompetitionMode == "" {
+		series.CompetitionMode = models.CompetitionModeCollaborative
+	}
+	if series.Visibility == "" {
+		series.Visibility = models.VisibilityPrivate
+	}
+	if series.CompletionRule == "" {
+		series.CompletionRule = models.CompletionRuleAny
+	}
+	if err := validateCompletionRule(seriesTemplate(*series)); err != nil {
+		return err
+	}
+	return validateStreakGoal(seriesTemplate(*series))
+}
+
+// seriesTemplate builds the challenge that each instance of the series starts from
+func seriesTemplate(series models.ChallengeSeries) models.Challenge {
+	return models.Challenge{
+		Name:              series.Name,
+		Description:       series.Description,
+		ChallengeType:     models.ChallengeTypeCustom,
+		GoalType:          series.GoalType,
+		CompetitionMode:   series.CompetitionMode,
+		Visibility:        series.Visibility,
+		CreatedByGroupID:  series.CreatedByGroupID,
+		TargetValue:       series.TargetValue,
+		TargetSummitCount: series.TargetSummitCount,
+		Region:            series.Region,
+		Difficulty:        series.Difficulty,
+		CompletionRule:    series.CompletionRule,
+		MaxWindowHours:    series.MaxWindowHours,
+		StreakType:        series.StreakType,
+	}
+}
+
+func validateRecurrence(series models.ChallengeSeries) error {
+	switch series.Recurrence {
+	case models.RecurrenceWeekly, models.RecurrenceMonthly, models.RecurrenceYearly:
+		return nil
+	case models.RecurrenceCustom:
+		if series.IntervalDays == nil || *series.IntervalDays <= 0 {
+			return ErrRecurrenceInvalid
+		}
+		return nil
+	}
+	return ErrRecurrenceInvalid
+}
+
+// nextPeriodStart returns the start of the period following the one starting at start.
+// Monthly and yearly periods are counted from the series start rather than
+// the previous period, so a series starting on the 31st runs on the last day
+// of shorter months and goes back to the 31st after them.
+func nextPeriodStart(series models.ChallengeSeries, start time.Time) time.Time {
+	first := series.StartsAt
+	start = start.In(first.Location())
+	switch series.Recurrence {
+	case models.RecurrenceWeekly:
+		return start.AddDate(0, 0, 7)
+	case models.RecurrenceMonthly:
+		months := (start.Year()-first.Year())*12 + int(start.Month()-first.Month())
+		return addMonthsClamped(first, months+1)
+	case models.RecurrenceYearly:
+		return addMonthsClamped(first, (start.Year()-first.Year()+1)*12)
+	default:
+		days := 1
+		if series.IntervalDays != nil && *series.IntervalDays > 0 {
+			days = *series.IntervalDays
+		}
+		return start.AddDate(0, 0, days)
+	}
+}
+
+// addMonthsClamped adds months to t, moving to the last day of the month
+// when t's day doesn't exist in it, e.g. 31 January plus one month is 28 or
+// 29 February
+func addMonthsClamped(t time.Time, months int) time.Time {
+	year, month, day := t.Date()
+	// Day 0 of the month after is the last day of the target month
+	lastDay := time.Date(year, month+time.Month(months)+1, 0, 0, 0, 0, 0, t.Location()).Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return time.Date(year, month+time.Month(months), day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
+
+// periodLabel gives each instance a readable name, e.g. "March 2025"
+func periodLabel(series models.ChallengeSeries, start time.Time, lastDay time.Time) string {
+	switch series.Recurrence {
+	case models.RecurrenceWeekly:
+		return "Week of " + start.Format("2 Jan 2006")
+	case models.RecurrenceMonthly:
+		return start.Format("January 2006")
+	case models.RecurrenceYearly:
+		return start.Format("2006")
+	default:
+		return start.Format("2 Jan") + " - " + lastDay.Format("2 Jan 2006")
+	}
+}
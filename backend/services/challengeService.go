@@ -12,12 +12,15 @@ import (
 )
 
 var (
-	ErrChallengeNotFound    = errors.New("challenge not found")
-	ErrNotChallengeOwner    = errors.New("user is not the challenge owner")
-	ErrAlreadyParticipant   = errors.New("user is already a participant")
-	ErrNotParticipant       = errors.New("user is not a participant")
-	ErrChallengeTypeInvalid = errors.New("invalid challenge type")
-	ErrChallengeNotPublic   = errors.New("challenge is not public")
+	ErrChallengeNotFound     = errors.New("challenge not found")
+	ErrNotChallengeOwner     = errors.New("user is not the challenge owner")
+	ErrAlreadyParticipant    = errors.New("user is already a participant")
+	ErrNotParticipant        = errors.New("user is not a participant")
+	ErrChallengeTypeInvalid  = errors.New("invalid challenge type")
+	ErrChallengeNotPublic    = errors.New("challenge is not public")
+	ErrCompletionRuleInvalid = errors.New("invalid completion rule")
+	ErrStreakGoalInvalid     = errors.New("streak challenges need a valid streak type and a positive target")
+	ErrSummitNotRecorded     = errors.New("no recorded summit of this peak, claim it first")
 )
 
 type ChallengeServiceInterface interface {
@@ -43,16 +46,18 @@ type ChallengeServiceInterface interface {
 	LeaveChallenge(challengeID int64, userID int64) error
 	LockChallenge(challengeID int64, userID int64) error
 	GetParticipants(challengeID int64) ([]models.ChallengeParticipantWithUser, error)
-	GetLeaderboard(challengeID int64) ([]models.LeaderboardEntry, error)
+	GetLeaderboard(challengeID int64, viewerID int64) ([]models.LeaderboardEntry, error)
 
 	// Progress tracking
 	RecordSummit(challengeID int64, userID int64, peakID int64, activityID *int64, summitedAt time.Time) error
+	CreditRecordedSummit(challengeID int64, userID int64, peakID int64, activityID *int64) error
+	RevokeSummit(userID int64, peakID int64, activityID int64) error
 	GetSummitLog(challengeID int64, userID *int64) ([]models.ChallengeSummitLogWithDetails, error)
 	RefreshParticipantProgress(challengeID int64, userID int64) error
 	RefreshAllChallengeProgress() error
 
 	// Activities
-	GetChallengeActivities(challengeID int64) ([]models.ActivityWithUser, error)
+	GetChallengeActivities(challengeID int64, viewerID int64) ([]models.ActivityWithUser, error)
 
 	// Group challenges
 	AddGroupToChallenge(challengeID int64, groupID int64, deadlineOverride *time.Time) error
@@ -61,20 +66,38 @@ type ChallengeServiceInterface interface {
 }
 
 type ChallengeService struct {
-	l            *log.Logger
-	challengeDao *daos.ChallengeDao
-	activityDao  *daos.ActivityDao
+	l                   *log.Logger
+	challengeDao        *daos.ChallengeDao
+	activityDao         *daos.ActivityDao
+	userPeaksDao        *daos.UserPeaksDao
+	streakService       *StreakService
+	privacyService      *PrivacyService
+	notificationService *NotificationService
+	groupWebhookService *GroupWebhookService
+	liveService         *LiveService
 }
 
 func NewChallengeService(
 	l *log.Logger,
 	challengeDao *daos.ChallengeDao,
 	activityDao *daos.ActivityDao,
+	userPeaksDao *daos.UserPeaksDao,
+	streakService *StreakService,
+	privacyService *PrivacyService,
+	notificationService *NotificationService,
+	groupWebhookService *GroupWebhookService,
+	liveService *LiveService,
 ) *ChallengeService {
 	return &ChallengeService{
-		l:            l,
-		challengeDao: challengeDao,
-		activityDao:  activityDao,
+		l:                   l,
+		challengeDao:        challengeDao,
+		activityDao:         activityDao,
+		userPeaksDao:        userPeaksDao,
+		streakService:       streakService,
+		privacyService:      privacyService,
+		notificationService: notificationService,
+		groupWebhookService: groupWebhookService,
+		liveService:         liveService,
 	}
 }
 
@@ -92,6 +115,34 @@ func (s *ChallengeService) generateJoinCode() (string, error) {
 func (s *ChallengeService) CreateChallenge(userID int64, challenge models.Challenge, peakIDs []int64) (*models.Challenge, error) {
 	// Set creator
 	challenge.CreatedByUserID = &userID
+	if err := s.prepareChallenge(&challenge); err != nil {
+		return nil, err
+	}
+
+	// Create challenge
+	id, err := s.challengeDao.CreateChallenge(challenge)
+	if err != nil {
+		s.l.Printf("Error creating challenge: %v", err)
+		return nil, err
+	}
+	challenge.ID = *id
+
+	// Add peaks if provided
+	if len(peakIDs) > 0 {
+		err = s.challengeDao.SetChallengePeaks(*id, peakIDs)
+		if err != nil {
+			s.l.Printf("Error setting challenge peaks: %v", err)
+			// Don't fail the challenge creation, but log the error
+		}
+	}
+
+	s.joinCreator(*id, userID)
+	return &challenge, nil
+}
+
+// prepareChallenge fills in the defaults and join code of a challenge about
+// to be created and validates it
+func (s *ChallengeService) prepareChallenge(challenge *models.Challenge) error {
 	challenge.CreatedAt = time.Now()
 	challenge.UpdatedAt = time.Now()
 
@@ -108,48 +159,41 @@ func (s *ChallengeService) CreateChallenge(userID int64, challenge models.Challe
 	if challenge.Visibility == "" {
 		challenge.Visibility = models.VisibilityPrivate
 	}
+	if challenge.CompletionRule == "" {
+		challenge.CompletionRule = models.CompletionRuleAny
+	}
+	if err := validateCompletionRule(*challenge); err != nil {
+		return err
+	}
+	if err := validateStreakGoal(*challenge); err != nil {
+		return err
+	}
 
 	// Generate join code if not provided
 	if challenge.JoinCode == "" {
 		joinCode, err := s.generateJoinCode()
 		if err != nil {
 			s.l.Printf("Error generating join code: %v", err)
-			return nil, err
+			return err
 		}
 		challenge.JoinCode = joinCode
 	}
+	return nil
+}
 
-	// Create challenge
-	id, err := s.challengeDao.CreateChallenge(challenge)
+// joinCreator auto-joins the creator to a new challenge. Failures are only
+// logged, the challenge exists either way.
+func (s *ChallengeService) joinCreator(challengeID int64, userID int64) {
+	err := s.challengeDao.JoinChallenge(challengeID, userID)
 	if err != nil {
-		s.l.Printf("Error creating challenge: %v", err)
-		return nil, err
-	}
-	challenge.ID = *id
-
-	// Add peaks if provided
-	if len(peakIDs) > 0 {
-		err = s.challengeDao.SetChallengePeaks(*id, peakIDs)
-		if err != nil {
-			s.l.Printf("Error setting challenge peaks: %v", err)
-			// Don't fail the challenge creation, but log the error
-		}
+		s.l.Printf("Error auto-joining creator to challenge: %v", err)
+		return
 	}
-
-	// Auto-join the creator
-	err = s.challengeDao.JoinChallenge(*id, userID)
+	// Calculate initial progress for the creator
+	err = s.RefreshParticipantProgress(challengeID, userID)
 	if err != nil {
-		s.l.Printf("Error auto-joining creator to challenge: %v", err)
-		// Don't fail the challenge creation
-	} else {
-		// Calculate initial progress for the creator
-		err = s.RefreshParticipantProgress(*id, userID)
-		if err != nil {
-			s.l.Printf("Warning: Failed to refresh creator's progress: %v", err)
-		}
+		s.l.Printf("Warning: Failed to refresh creator's progress: %v", err)
 	}
-
-	return &challenge, nil
 }
 
 func (s *ChallengeService) GetChallenge(id int64, userID *int64) (*models.ChallengeWithProgress, error) {
@@ -183,6 +227,17 @@ func (s *ChallengeService) GetChallenge(id int64, userID *int64) (*models.Challe
 				result.CurrentDistance += p.TotalDistance
 				result.CurrentElevation += p.TotalElevation
 				result.CurrentSummitCount += p.TotalSummitCount
+				// The team's best time is the quickest of anyone's
+				if p.BestTimeSeconds != nil && (result.BestTimeSeconds == nil || *p.BestTimeSeconds < *result.BestTimeSeconds) {
+					result.BestTimeSeconds = p.BestTimeSeconds
+				}
+				// Likewise the longest streaks
+				if p.CurrentStreak > result.CurrentStreak {
+					result.CurrentStreak = p.CurrentStreak
+				}
+				if p.LongestStreak > result.LongestStreak {
+					result.LongestStreak = p.LongestStreak
+				}
 			}
 		}
 	} else {
@@ -194,13 +249,16 @@ func (s *ChallengeService) GetChallenge(id int64, userID *int64) (*models.Challe
 				result.CurrentDistance = participant.TotalDistance
 				result.CurrentElevation = participant.TotalElevation
 				result.CurrentSummitCount = participant.TotalSummitCount
+				result.BestTimeSeconds = participant.BestTimeSeconds
+				result.CurrentStreak = participant.CurrentStreak
+				result.LongestStreak = participant.LongestStreak
 				result.IsCompleted = participant.CompletedAt != nil
 			}
 		}
 	}
 
-	// For specific_summits, get peak count (target)
-	if challenge.GoalType == models.GoalTypeSpecificSummits {
+	// For specific_summits and fastest_time, get peak count (target)
+	if challenge.GoalType == models.GoalTypeSpecificSummits || challenge.GoalType == models.GoalTypeFastestTime {
 		peaks, err := s.challengeDao.GetChallengePeaks(id)
 		if err == nil {
 			result.TotalPeaks = len(peaks)
@@ -223,11 +281,49 @@ func (s *ChallengeService) UpdateChallenge(id int64, userID int64, challenge mod
 		return ErrNotChallengeOwner
 	}
 
+	if challenge.CompletionRule == "" {
+		challenge.CompletionRule = models.CompletionRuleAny
+	}
+	if err := validateCompletionRule(challenge); err != nil {
+		return err
+	}
+	if err := validateStreakGoal(challenge); err != nil {
+		return err
+	}
+
 	challenge.ID = id
 	challenge.UpdatedAt = time.Now()
 	return s.challengeDao.UpdateChallenge(challenge)
 }
 
+// validateCompletionRule checks the rule is known and has what it needs
+func validateCompletionRule(challenge models.Challenge) error {
+	switch challenge.CompletionRule {
+	case models.CompletionRuleAny, models.CompletionRuleOrdered, models.CompletionRuleSingleActivity:
+		return nil
+	case models.CompletionRuleTimeWindow:
+		if challenge.MaxWindowHours == nil || *challenge.MaxWindowHours <= 0 {
+			return ErrCompletionRuleInvalid
+		}
+		return nil
+	}
+	return ErrCompletionRuleInvalid
+}
+
+// validateStreakGoal checks streak challenges say what to count and how long a streak to reach
+func validateStreakGoal(challenge models.Challenge) error {
+	if challenge.GoalType != models.GoalTypeStreak {
+		return nil
+	}
+	if challenge.StreakType == nil || !validStreakType(*challenge.StreakType) {
+		return ErrStreakGoalInvalid
+	}
+	if challenge.TargetValue != nil && *challenge.TargetValue <= 0 {
+		return ErrStreakGoalInvalid
+	}
+	return nil
+}
+
 func (s *ChallengeService) DeleteChallenge(id int64, userID int64) error {
 	// Check ownership
 	existing, err := s.challengeDao.GetChallengeByID(id)
@@ -332,6 +428,7 @@ func (s *ChallengeService) JoinChallenge(challengeID int64, userID int64) error
 	if err != nil {
 		return err
 	}
+	s.publishJoined(*challenge, userID)
 
 	// Calculate initial progress
 	err = s.RefreshParticipantProgress(challengeID, userID)
@@ -367,6 +464,7 @@ func (s *ChallengeService) JoinChallengeByCode(joinCode string, userID int64) (*
 	if err != nil {
 		return nil, err
 	}
+	s.publishJoined(*challenge, userID)
 
 	// Calculate initial progress
 	err = s.RefreshParticipantProgress(challenge.ID, userID)
@@ -388,7 +486,11 @@ func (s *ChallengeService) LeaveChallenge(challengeID int64, userID int64) error
 		return ErrNotParticipant
 	}
 
-	return s.challengeDao.LeaveChallenge(challengeID, userID)
+	if err := s.challengeDao.LeaveChallenge(challengeID, userID); err != nil {
+		return err
+	}
+	s.liveService.PublishChallenge(challengeID, models.LiveEventParticipants)
+	return nil
 }
 
 func (s *ChallengeService) LockChallenge(challengeID int64, userID int64) error {
@@ -414,29 +516,36 @@ func (s *ChallengeService) GetParticipants(challengeID int64) ([]models.Challeng
 	return s.challengeDao.GetChallengeParticipants(challengeID)
 }
 
-func (s *ChallengeService) GetLeaderboard(challengeID int64) ([]models.LeaderboardEntry, error) {
-	return s.challengeDao.GetChallengeLeaderboard(challengeID)
+func (s *ChallengeService) GetLeaderboard(challengeID int64, viewerID int64) ([]models.LeaderboardEntry, error) {
+	return s.challengeDao.GetChallengeLeaderboard(challengeID, viewerID)
 }
 
 // ==================== Progress Tracking ====================
 
 func (s *ChallengeService) RecordSummit(challengeID int64, userID int64, peakID int64, activityID *int64, summitedAt time.Time) error {
+	_, err := s.recordSummit(challengeID, userID, peakID, activityID, summitedAt)
+	return err
+}
+
+// recordSummit logs the summit and refreshes progress, reporting whether it
+// was logged. A peak already logged for the challenge is left as it is.
+func (s *ChallengeService) recordSummit(challengeID int64, userID int64, peakID int64, activityID *int64, summitedAt time.Time) (bool, error) {
 	// Check if user is participant
 	isParticipant, err := s.challengeDao.IsUserParticipant(challengeID, userID)
 	if err != nil {
-		return err
+		return false, err
 	}
 	if !isParticipant {
-		return ErrNotParticipant
+		return false, ErrNotParticipant
 	}
 
 	// Check if already summited this peak for this challenge
 	hasSummited, err := s.challengeDao.HasUserSummitedPeakForChallenge(challengeID, userID, peakID)
 	if err != nil {
-		return err
+		return false, err
 	}
 	if hasSummited {
-		return nil // Already logged, no error
+		return false, nil // Already logged, no error
 	}
 
 	// Log the summit
@@ -449,11 +558,112 @@ func (s *ChallengeService) RecordSummit(challengeID int64, userID int64, peakID
 	}
 	err = s.challengeDao.LogSummit(logEntry)
 	if err != nil {
-		return err
+		return false, err
 	}
+	s.liveService.PublishChallenge(challengeID, models.LiveEventSummit)
 
 	// Refresh progress
-	return s.RefreshParticipantProgress(challengeID, userID)
+	return true, s.RefreshParticipantProgress(challengeID, userID)
+}
+
+// CreditRecordedSummit credits one of the user's recorded summits to a
+// challenge. Only summits in user_peaks count, so the user must claim a
+// summit detection missed before it can be credited. With no activity the
+// earliest summit of the peak in the challenge's dates is used.
+func (s *ChallengeService) CreditRecordedSummit(challengeID int64, userID int64, peakID int64, activityID *int64) error {
+	challenge, err := s.challengeDao.GetChallengeByID(challengeID)
+	if err != nil {
+		return err
+	}
+	if challenge == nil {
+		return ErrChallengeNotFound
+	}
+
+	summit, err := s.findRecordedSummit(*challenge, userID, peakID, activityID)
+	if err != nil {
+		return err
+	}
+	if summit == nil {
+		return ErrSummitNotRecorded
+	}
+	return s.RecordSummit(challengeID, userID, peakID, &summit.ActivityID, summit.SummitedAt)
+}
+
+// RevokeSummit takes back the challenge credit a dismissed summit earned.
+// Where another summit of the same peak still counts, it's credited instead.
+// Progress is refreshed for every challenge the user is in that summits
+// count towards.
+func (s *ChallengeService) RevokeSummit(userID int64, peakID int64, activityID int64) error {
+	revoked, err := s.challengeDao.DeleteSummitLogsForActivity(userID, peakID, activityID)
+	if err != nil {
+		return err
+	}
+	wasCredited := map[int64]bool{}
+	for _, id := range revoked {
+		wasCredited[id] = true
+		s.liveService.PublishChallenge(id, models.LiveEventSummit)
+	}
+
+	challenges, err := s.challengeDao.GetChallengesByUser(userID)
+	if err != nil {
+		return err
+	}
+	for _, challenge := range challenges {
+		switch challenge.GoalType {
+		case models.GoalTypeSpecificSummits, models.GoalTypeFastestTime, models.GoalTypeSummitCount:
+		default:
+			continue
+		}
+
+		if wasCredited[challenge.ID] {
+			summit, err := s.findRecordedSummit(challenge.Challenge, userID, peakID, nil)
+			if err != nil {
+				s.l.Printf("Error finding replacement summit for challenge %d: %v", challenge.ID, err)
+			} else if summit != nil {
+				err = s.challengeDao.LogSummit(models.ChallengeSummitLog{
+					ChallengeID: challenge.ID,
+					UserID:      userID,
+					PeakID:      &peakID,
+					ActivityID:  &summit.ActivityID,
+					SummitedAt:  summit.SummitedAt,
+				})
+				if err != nil {
+					s.l.Printf("Error re-crediting summit for challenge %d: %v", challenge.ID, err)
+				}
+			}
+		}
+
+		if err := s.RefreshParticipantProgress(challenge.ID, userID); err != nil {
+			s.l.Printf("Error refreshing progress for challenge %d: %v", challenge.ID, err)
+		}
+	}
+	return nil
+}
+
+// findRecordedSummit returns the user's earliest summit of the peak within
+// the challenge's dates, on the given activity if one is set, or nil
+func (s *ChallengeService) findRecordedSummit(challenge models.Challenge, userID int64, peakID int64, activityID *int64) (*models.PeakMySummit, error) {
+	history, err := s.userPeaksDao.GetUserPeakHistory(userID, peakID)
+	if err != nil {
+		return nil, err
+	}
+
+	// History is newest first, so the last match is the earliest
+	var found *models.PeakMySummit
+	for i := range history {
+		summit := history[i]
+		if activityID != nil && summit.ActivityID != *activityID {
+			continue
+		}
+		if challenge.StartDate != nil && summit.SummitedAt.Before(*challenge.StartDate) {
+			continue
+		}
+		if challenge.Deadline != nil && summit.SummitedAt.After(*challenge.Deadline) {
+			continue
+		}
+		found = &summit
+	}
+	return found, nil
 }
 
 func (s *ChallengeService) GetSummitLog(challengeID int64, userID *int64) ([]models.ChallengeSummitLogWithDetails, error) {
@@ -470,35 +680,198 @@ func (s *ChallengeService) RefreshParticipantProgress(challengeID int64, userID
 		return ErrChallengeNotFound
 	}
 
+	// Competitive leaderboards are compared before and after to find who
+	// the user overtook
+	var standings []models.ChallengeStanding
+	if challenge.CompetitionMode == models.CompetitionModeCompetitive {
+		standings, err = s.challengeDao.GetChallengeStandings(challengeID)
+		if err != nil {
+			return err
+		}
+	}
+
+	completed, err := s.updateParticipantProgress(*challenge, userID)
+	if err != nil {
+		return err
+	}
+
+	if completed {
+		if err := s.notificationService.NotifyChallengeCompleted(userID, *challenge); err != nil {
+			s.l.Printf("Failed to notify user %d of completing challenge %d: %v", userID, challengeID, err)
+		}
+		if err := s.groupWebhookService.PublishChallengeCompleted(userID, *challenge); err != nil {
+			s.l.Printf("Failed to publish user %d completing challenge %d to group webhooks: %v", userID, challengeID, err)
+		}
+	}
+	if standings != nil {
+		s.notifyLeaderboardChange(*challenge, userID, standings)
+	}
+	s.liveService.PublishChallenge(challengeID, models.LiveEventProgress)
+	return nil
+}
+
+// publishJoined posts the user joining the challenge to their groups and
+// the challenge's live subscribers
+func (s *ChallengeService) publishJoined(challenge models.Challenge, userID int64) {
+	s.liveService.PublishChallenge(challenge.ID, models.LiveEventParticipants)
+	if err := s.groupWebhookService.PublishChallengeJoined(userID, challenge); err != nil {
+		s.l.Printf("Failed to publish user %d joining challenge %d to group webhooks: %v", userID, challenge.ID, err)
+	}
+}
+
+// notifyLeaderboardChange posts the user moving up the leaderboard to their
+// groups and tells everyone they moved from behind to ahead of since the
+// standings were taken
+func (s *ChallengeService) notifyLeaderboardChange(challenge models.Challenge, userID int64, before []models.ChallengeStanding) {
+	after, err := s.challengeDao.GetChallengeStandings(challenge.ID)
+	if err != nil {
+		s.l.Printf("Failed to get standings for challenge %d: %v", challenge.ID, err)
+		return
+	}
+
+	positionsBefore := make(map[int64]int, len(before))
+	for _, standing := range before {
+		positionsBefore[standing.UserID] = standing.Position
+	}
+	var user *models.ChallengeStanding
+	for i := range after {
+		if after[i].UserID == userID {
+			user = &after[i]
+			break
+		}
+	}
+	userBefore, ok := positionsBefore[userID]
+	if user == nil || !ok || user.Position >= userBefore {
+		return
+	}
+
+	// Users hidden from leaderboards aren't posted about moving up them
+	if user.UserName != "" {
+		if err := s.groupWebhookService.PublishLeaderboardChanged(userID, challenge, user.Position, userBefore); err != nil {
+			s.l.Printf("Failed to publish user %d moving up challenge %d to group webhooks: %v", userID, challenge.ID, err)
+		}
+	}
+
+	for _, other := range after {
+		otherBefore, ok := positionsBefore[other.UserID]
+		if other.UserID == userID || !ok {
+			continue
+		}
+		if otherBefore < userBefore && other.Position > user.Position {
+			if err := s.notificationService.NotifyOvertaken(other.UserID, challenge, user.UserName); err != nil {
+				s.l.Printf("Failed to notify user %d of being overtaken in challenge %d: %v", other.UserID, challenge.ID, err)
+			}
+		}
+	}
+}
+
+// updateParticipantProgress recalculates the user's progress in the
+// challenge. It returns true if this completed the challenge for them.
+func (s *ChallengeService) updateParticipantProgress(challenge models.Challenge, userID int64) (bool, error) {
+	challengeID := challenge.ID
+
 	var peaksCompleted int
 	var totalPeaks int
 	var totalDistance float64
 	var totalElevation float64
 	var totalSummitCount int
 	var isCompleted bool
+	var satisfaction ruleResult
+	var bestTimeSeconds *int
 
 	switch challenge.GoalType {
 	case models.GoalTypeSpecificSummits:
 		// Get total peaks
 		peaks, err := s.challengeDao.GetChallengePeaks(challengeID)
 		if err != nil {
-			return err
+			return false, err
 		}
 		totalPeaks = len(peaks)
 
+		if usesSummitHistory(challenge.CompletionRule) {
+			// Ordered/single-activity/window rules look at every summit, not just the first per peak
+			summits, err := s.getUserSummitsForChallenge(challenge, userID, peaks)
+			if err != nil {
+				return false, err
+			}
+			satisfaction = evaluateCompletionRule(challenge, peaks, summits)
+			peaksCompleted = satisfaction.PeaksCompleted
+			isCompleted = satisfaction.Completed
+			break
+		}
+
 		// Get completed peaks
 		summitLog, err := s.challengeDao.GetChallengeSummitLog(challengeID, &userID)
 		if err != nil {
-			return err
+			return false, err
 		}
 		peaksCompleted = len(summitLog)
 		isCompleted = peaksCompleted >= totalPeaks && totalPeaks > 0
 
+		// The latest credited summit is the one that completed the challenge
+		for _, entry := range summitLog {
+			if satisfaction.WindowEnd == nil || entry.SummitedAt.After(*satisfaction.WindowEnd) {
+				summitedAt := entry.SummitedAt
+				satisfaction.WindowEnd = &summitedAt
+				satisfaction.ActivityID = entry.ActivityID
+			}
+		}
+
+	case models.GoalTypeFastestTime:
+		peaks, err := s.challengeDao.GetChallengePeaks(challengeID)
+		if err != nil {
+			return false, err
+		}
+		totalPeaks = len(peaks)
+
+		summits, err := s.getUserSummitsForChallenge(challenge, userID, peaks)
+		if err != nil {
+			return false, err
+		}
+		bestTimeSeconds, satisfaction = evaluateFastestTime(peaks, summits)
+		peaksCompleted = satisfaction.PeaksCompleted
+		isCompleted = satisfaction.Completed
+		// An optional cut-off time must be beaten to complete the challenge
+		if bestTimeSeconds != nil && challenge.TargetValue != nil {
+			isCompleted = float64(*bestTimeSeconds) <= *challenge.TargetValue
+		}
+
+		// Best time keeps improving after completion, so always store it
+		err = s.challengeDao.SetParticipantBestTime(challengeID, userID, bestTimeSeconds)
+		if err != nil {
+			return false, err
+		}
+		if bestTimeSeconds != nil {
+			err = s.challengeDao.SetParticipantSatisfaction(
+				challengeID, userID,
+				satisfaction.ActivityID, satisfaction.WindowStart, satisfaction.WindowEnd,
+			)
+			if err != nil {
+				return false, err
+			}
+		}
+
+	case models.GoalTypeStreak:
+		if challenge.StreakType == nil {
+			break
+		}
+		streak, err := s.streakService.GetStreakInRange(userID, *challenge.StreakType, challenge.StartDate, challenge.Deadline)
+		if err != nil {
+			return false, err
+		}
+		err = s.challengeDao.UpdateParticipantStreak(challengeID, userID, streak.Current, streak.Longest)
+		if err != nil {
+			return false, err
+		}
+		if target := streakTarget(challenge); target > 0 {
+			isCompleted = streak.Longest >= target
+		}
+
 	case models.GoalTypeDistance:
 		// Get activities within challenge date range and sum distance
 		activities, err := s.activityDao.GetActivitiesByUserIDAndDateRange(userID, challenge.StartDate, challenge.Deadline)
 		if err != nil {
-			return err
+			return false, err
 		}
 		for _, activity := range activities {
 			totalDistance += activity.Distance
@@ -512,7 +885,7 @@ func (s *ChallengeService) RefreshParticipantProgress(challengeID int64, userID
 		// Get activities within challenge date range and sum elevation
 		activities, err := s.activityDao.GetActivitiesByUserIDAndDateRange(userID, challenge.StartDate, challenge.Deadline)
 		if err != nil {
-			return err
+			return false, err
 		}
 		for _, activity := range activities {
 			totalElevation += activity.Elevation
@@ -526,7 +899,7 @@ func (s *ChallengeService) RefreshParticipantProgress(challengeID int64, userID
 		// Get summit log within challenge date range
 		summitLog, err := s.challengeDao.GetChallengeSummitLog(challengeID, &userID)
 		if err != nil {
-			return err
+			return false, err
 		}
 		totalSummitCount = len(summitLog)
 		// Check completion
@@ -536,21 +909,63 @@ func (s *ChallengeService) RefreshParticipantProgress(challengeID int64, userID
 	}
 
 	// Update progress with all fields
-	err = s.challengeDao.UpdateParticipantProgressFull(
+	err := s.challengeDao.UpdateParticipantProgressFull(
 		challengeID, userID,
 		peaksCompleted, totalPeaks,
 		totalDistance, totalElevation, totalSummitCount,
 	)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	// Mark as completed if applicable
 	if isCompleted {
+		if challenge.GoalType == models.GoalTypeSpecificSummits {
+			err = s.challengeDao.SetParticipantSatisfaction(
+				challengeID, userID,
+				satisfaction.ActivityID, satisfaction.WindowStart, satisfaction.WindowEnd,
+			)
+			if err != nil {
+				return false, err
+			}
+		}
 		return s.challengeDao.MarkParticipantCompleted(challengeID, userID)
 	}
 
-	return nil
+	return false, nil
+}
+
+// streakTarget is the streak length a streak challenge needs: the target if
+// set, otherwise every day or week between the start date and deadline.
+// Returns 0 when neither is known.
+func streakTarget(challenge models.Challenge) int {
+	if challenge.TargetValue != nil {
+		return int(*challenge.TargetValue)
+	}
+	if challenge.StartDate == nil || challenge.Deadline == nil || challenge.StreakType == nil {
+		return 0
+	}
+	// Every period must be covered, so the week start doesn't matter as long as it's consistent
+	return streakPeriodsBetween(*challenge.StartDate, *challenge.Deadline, streakIsWeekly(*challenge.StreakType), time.Monday)
+}
+
+// getUserSummitsForChallenge returns every summit of the challenge peaks within the challenge dates
+func (s *ChallengeService) getUserSummitsForChallenge(challenge models.Challenge, userID int64, peaks []models.ChallengePeakWithDetails) ([]models.UserPeak, error) {
+	peakIDs := make([]int64, 0, len(peaks))
+	for _, peak := range peaks {
+		peakIDs = append(peakIDs, peak.PeakID)
+	}
+
+	start := time.Time{}
+	if challenge.StartDate != nil {
+		start = *challenge.StartDate
+	}
+	end := time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+	if challenge.Deadline != nil {
+		end = *challenge.Deadline
+	}
+
+	return s.userPeaksDao.GetUserSummitsInDateRange(userID, peakIDs, start, end)
 }
 
 // RefreshAllChallengeProgress refreshes progress for all active challenges and their participants
@@ -577,6 +992,37 @@ func (s *ChallengeService) RefreshAllChallengeProgress() error {
 	return nil
 }
 
+// challengeEndingWindow is how long before its deadline participants are
+// reminded of a challenge they haven't completed
+const challengeEndingWindow = 48 * time.Hour
+
+// NotifyEndingChallenges reminds participants who haven't completed a
+// challenge that it ends soon. Each participant is reminded once per
+// challenge, so it's safe to run often.
+func (s *ChallengeService) NotifyEndingChallenges() error {
+	challenges, err := s.challengeDao.GetChallengesEndingWithin(challengeEndingWindow)
+	if err != nil {
+		return err
+	}
+
+	for _, challenge := range challenges {
+		participants, err := s.challengeDao.GetChallengeParticipants(challenge.ID)
+		if err != nil {
+			s.l.Printf("Error getting participants for challenge %d: %v", challenge.ID, err)
+			continue
+		}
+		for _, participant := range participants {
+			if participant.CompletedAt != nil {
+				continue
+			}
+			if err := s.notificationService.NotifyChallengeEnding(participant.UserID, challenge); err != nil {
+				s.l.Printf("Failed to remind user %d of challenge %d ending: %v", participant.UserID, challenge.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
 // ==================== Group Challenges ====================
 
 func (s *ChallengeService) AddGroupToChallenge(challengeID int64, groupID int64, deadlineOverride *time.Time) error {
@@ -624,8 +1070,8 @@ func (s *ChallengeService) ProcessActivityForChallenges(userID int64, peakID int
 
 		// Handle based on goal type
 		switch challenge.GoalType {
-		case models.GoalTypeSpecificSummits:
-			// For specific_summits, only credit if peak is in the challenge list
+		case models.GoalTypeSpecificSummits, models.GoalTypeFastestTime:
+			// For specific_summits and fastest_time, only credit if peak is in the challenge list
 			peaks, err := s.challengeDao.GetChallengePeaks(challenge.ID)
 			if err != nil {
 				s.l.Printf("Error getting challenge %d peaks: %v", challenge.ID, err)
@@ -635,10 +1081,19 @@ func (s *ChallengeService) ProcessActivityForChallenges(userID int64, peakID int
 			for _, peak := range peaks {
 				if peak.PeakID == peakID {
 					// This summit counts for this challenge
-					err = s.RecordSummit(challenge.ID, userID, peakID, &activityID, summitedAt)
+					logged, err := s.recordSummit(challenge.ID, userID, peakID, &activityID, summitedAt)
 					if err != nil {
 						s.l.Printf("Error recording summit for challenge %d: %v", challenge.ID, err)
 					}
+					// A repeat summit isn't logged again but can still satisfy ordered/window rules
+					// or set a faster time. A logged summit has already refreshed.
+					rerank := usesSummitHistory(challenge.CompletionRule) || challenge.GoalType == models.GoalTypeFastestTime
+					if rerank && !logged && err == nil {
+						err = s.RefreshParticipantProgress(challenge.ID, userID)
+						if err != nil {
+							s.l.Printf("Error refreshing progress for challenge %d: %v", challenge.ID, err)
+						}
+					}
 					break
 				}
 			}
@@ -649,6 +1104,13 @@ func (s *ChallengeService) ProcessActivityForChallenges(userID int64, peakID int
 			if err != nil {
 				s.l.Printf("Error recording summit for challenge %d: %v", challenge.ID, err)
 			}
+
+		case models.GoalTypeStreak:
+			// Any new activity or summit can extend the streak
+			err = s.RefreshParticipantProgress(challenge.ID, userID)
+			if err != nil {
+				s.l.Printf("Error refreshing progress for challenge %d: %v", challenge.ID, err)
+			}
 		}
 	}
 
@@ -657,6 +1119,15 @@ func (s *ChallengeService) ProcessActivityForChallenges(userID int64, peakID int
 
 // ==================== Activities ====================
 
-func (s *ChallengeService) GetChallengeActivities(challengeID int64) ([]models.ActivityWithUser, error) {
-	return s.challengeDao.GetChallengeActivities(challengeID)
+// GetChallengeActivities returns the challenge feed as the viewer may see it,
+// with other users' routes trimmed to their privacy zones
+func (s *ChallengeService) GetChallengeActivities(challengeID int64, viewerID int64) ([]models.ActivityWithUser, error) {
+	activities, err := s.challengeDao.GetChallengeActivities(challengeID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.privacyService.RedactChallengeActivities(viewerID, activities); err != nil {
+		return nil, err
+	}
+	return activities, nil
 }
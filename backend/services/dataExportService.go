@@ -0,0 +1,463 @@
+package services
+
+import (
+	"archive/zip"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"run-goals/config"
+	"run-goals/daos"
+	"run-goals/models"
+	"strconv"
+	"time"
+
+	"github.com/twpayne/go-polyline"
+)
+
+var (
+	ErrDataExportNotFound   = errors.New("data export not found")
+	ErrDataExportInProgress = errors.New("an export is already in progress")
+	ErrDataExportTooLarge   = errors.New("export is larger than the size limit")
+)
+
+const (
+	defaultExportMaxSizeMB = 200
+	defaultExportLinkHours = 24
+	// exportActivityBatch is how many activities are read per query
+	exportActivityBatch = 500
+	// exportPollInterval is how often the worker looks for exports it
+	// wasn't woken for, e.g. ones queued before a restart or left by a
+	// replica that died
+	exportPollInterval = time.Minute
+)
+
+type DataExportService struct {
+	l                     *log.Logger
+	config                *config.Config
+	dataExportDao         *daos.DataExportDao
+	userDao               *daos.UserDao
+	activityDao           *daos.ActivityDao
+	userPeaksDao          *daos.UserPeaksDao
+	personalGoalDao       *daos.PersonalGoalDao
+	personalYearlyGoalDao *daos.PersonalYearlyGoalDao
+	summitFavouritesDao   *daos.SummitFavouritesDao
+	challengeDao          *daos.ChallengeDao
+	groupsDao             *daos.GroupsDao
+	wake                  chan struct{}
+}
+
+func NewDataExportService(
+	l *log.Logger,
+	config *config.Config,
+	dataExportDao *daos.DataExportDao,
+	userDao *daos.UserDao,
+	activityDao *daos.ActivityDao,
+	userPeaksDao *daos.UserPeaksDao,
+	personalGoalDao *daos.PersonalGoalDao,
+	personalYearlyGoalDao *daos.PersonalYearlyGoalDao,
+	summitFavouritesDao *daos.SummitFavouritesDao,
+	challengeDao *daos.ChallengeDao,
+	groupsDao *daos.GroupsDao,
+) *DataExportService {
+	return &DataExportService{
+		l:                     l,
+		config:                config,
+		dataExportDao:         dataExportDao,
+		userDao:               userDao,
+		activityDao:           activityDao,
+		userPeaksDao:          userPeaksDao,
+		personalGoalDao:       personalGoalDao,
+		personalYearlyGoalDao: personalYearlyGoalDao,
+		summitFavouritesDao:   summitFavouritesDao,
+		challengeDao:          challengeDao,
+		groupsDao:             groupsDao,
+		wake:                  make(chan struct{}, 1),
+	}
+}
+
+// ExportDir is where export ZIPs are written
+func (s *DataExportService) ExportDir() string {
+	if s.config.Export.Dir != "" {
+		return s.config.Export.Dir
+	}
+	return filepath.Join(os.TempDir(), "run-goals-exports")
+}
+
+// MaxSizeBytes is the largest ZIP an export may produce
+func (s *DataExportService) MaxSizeBytes() int64 {
+	mb, err := strconv.ParseInt(s.config.Export.MaxSizeMB, 10, 64)
+	if err != nil || mb <= 0 {
+		mb = defaultExportMaxSizeMB
+	}
+	return mb << 20
+}
+
+// LinkTTL is how long a finished export can be downloaded for
+func (s *DataExportService) LinkTTL() time.Duration {
+	hours, err := strconv.Atoi(s.config.Export.LinkHours)
+	if err != nil || hours <= 0 {
+		hours = defaultExportLinkHours
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// RequestExport queues an export of everything we hold on the user. Only
+// one can be in progress at a time.
+func (s *DataExportService) RequestExport(userID int64) (*models.DataExport, error) {
+	id, err := s.dataExportDao.CreateExport(userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDataExportInProgress
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.l.Printf("Data export %d queued for user %d", *id, userID)
+
+	// Wake the worker, unless it's already due to look
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+
+	return s.dataExportDao.GetExportByID(*id)
+}
+
+// GetLatestExport returns the status of the user's most recent export, with
+// its download link while it's ready
+func (s *DataExportService) GetLatestExport(userID int64) (*models.DataExport, error) {
+	export, err := s.dataExportDao.GetLatestExport(userID)
+	if err != nil {
+		return nil, err
+	}
+	if export == nil {
+		return nil, ErrDataExportNotFound
+	}
+	if export.Status == models.DataExportStatusReady && export.ExpiresAt != nil && export.ExpiresAt.After(time.Now()) {
+		export.DownloadURL = "/exports/download?token=" + url.QueryEscape(export.Token)
+	}
+	return export, nil
+}
+
+// OpenDownload returns the ready export with the token and its open file.
+// The caller closes the file.
+func (s *DataExportService) OpenDownload(token string) (*models.DataExport, *os.File, error) {
+	if token == "" {
+		return nil, nil, ErrDataExportNotFound
+	}
+	export, err := s.dataExportDao.GetReadyExportByToken(token)
+	if err != nil {
+		return nil, nil, err
+	}
+	if export == nil {
+		return nil, nil, ErrDataExportNotFound
+	}
+	file, err := os.Open(export.FilePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, ErrDataExportNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return export, file, nil
+}
+
+// Run builds queued exports one at a time. It never returns. Exports left
+// running by a replica that stopped sending heartbeats are picked up again.
+func (s *DataExportService) Run() {
+	for {
+		if n, err := s.dataExportDao.RequeueStaleExports(jobStaleAfter); err != nil {
+			s.l.Printf("Failed to requeue interrupted data exports: %v", err)
+		} else if n > 0 {
+			s.l.Printf("Requeued %d interrupted data exports", n)
+		}
+
+		for {
+			export, err := s.dataExportDao.StartNextExport()
+			if err != nil {
+				s.l.Printf("Failed to start data export: %v", err)
+				break
+			}
+			if export == nil {
+				break
+			}
+			s.build(*export)
+		}
+
+		select {
+		case <-s.wake:
+		case <-time.After(exportPollInterval):
+		}
+	}
+}
+
+// CleanupExpired deletes the files of exports whose links have expired
+func (s *DataExportService) CleanupExpired() error {
+	exports, err := s.dataExportDao.GetExpiredExports()
+	if err != nil {
+		return err
+	}
+	for _, export := range exports {
+		if err := os.Remove(export.FilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			s.l.Printf("Failed to delete data export file %s: %v", export.FilePath, err)
+			continue
+		}
+		if err := s.dataExportDao.MarkExportExpired(export.ID); err != nil {
+			return err
+		}
+	}
+	if len(exports) > 0 {
+		s.l.Printf("Expired %d data exports", len(exports))
+	}
+	return nil
+}
+
+// DeleteUserExports deletes the user's export files ahead of their account
+// being deleted, returning how many there were. The rows go with the account.
+func (s *DataExportService) DeleteUserExports(userID int64) (int, error) {
+	exports, err := s.dataExportDao.GetExportsByUser(userID)
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, export := range exports {
+		if export.FilePath == "" {
+			continue
+		}
+		err := os.Remove(export.FilePath)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// build writes the export's ZIP and marks it ready, or failed with a
+// message fit to show the user
+func (s *DataExportService) build(export models.DataExport) {
+	s.l.Printf("Building data export %d for user %d", export.ID, export.UserID)
+	stopHeartbeat := startHeartbeat(func() { s.dataExportDao.HeartbeatExport(export.ID) })
+	defer stopHeartbeat()
+
+	path, size, err := s.writeArchive(export)
+	if err != nil {
+		s.l.Printf("Data export %d failed: %v", export.ID, err)
+		message := "export failed, please try again later"
+		if errors.Is(err, ErrDataExportTooLarge) {
+			message = fmt.Sprintf("export is larger than the %d MB limit", s.MaxSizeBytes()>>20)
+		}
+		s.dataExportDao.FailExport(export.ID, message)
+		return
+	}
+
+	token, err := generateExportToken()
+	if err == nil {
+		err = s.dataExportDao.CompleteExport(export.ID, token, path, size, time.Now().Add(s.LinkTTL()))
+	}
+	if err != nil {
+		s.l.Printf("Data export %d failed: %v", export.ID, err)
+		os.Remove(path)
+		s.dataExportDao.FailExport(export.ID, "export failed, please try again later")
+		return
+	}
+	s.l.Printf("Data export %d ready (%d bytes)", export.ID, size)
+}
+
+// writeArchive writes the ZIP to the export directory, removing it again if
+// anything goes wrong
+func (s *DataExportService) writeArchive(export models.DataExport) (string, int64, error) {
+	if err := os.MkdirAll(s.ExportDir(), 0o700); err != nil {
+		return "", 0, err
+	}
+	path := filepath.Join(s.ExportDir(), fmt.Sprintf("export-%d-%d.zip", export.UserID, export.ID))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", 0, err
+	}
+
+	out := &limitedWriter{w: file, remaining: s.MaxSizeBytes()}
+	zw := zip.NewWriter(out)
+	err = s.writeContents(zw, export.UserID)
+	if err == nil {
+		err = zw.Close()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", 0, err
+	}
+	return path, s.MaxSizeBytes() - out.remaining, nil
+}
+
+// writeContents adds each part of the user's data to the ZIP
+func (s *DataExportService) writeContents(zw *zip.Writer, userID int64) error {
+	user, err := s.userDao.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	profile := models.DataExportProfile{
+		ID:                   user.ID,
+		StravaAthleteID:      user.StravaAthleteID,
+		Username:             user.Username.String,
+		IsAdmin:              user.IsAdmin,
+		Timezone:             user.Timezone,
+		WeekStart:            user.WeekStart,
+		ShowInLeaderboards:   user.ShowInLeaderboards,
+		ShowInGroupFeeds:     user.ShowInGroupFeeds,
+		ShowInChallengeFeeds: user.ShowInChallengeFeeds,
+		CreatedAt:            user.CreatedAt,
+	}
+	email, err := s.userDao.GetEmailSettings(user.ID)
+	if err != nil {
+		return err
+	}
+	profile.Email = email.Email
+	if err := writeJSONEntry(zw, "profile.json", profile); err != nil {
+		return err
+	}
+
+	activities, err := s.allActivities(userID)
+	if err != nil {
+		return err
+	}
+	if err := writeJSONEntry(zw, "activities.json", activities); err != nil {
+		return err
+	}
+	for _, activity := range activities {
+		if activity.MapPolyline == "" {
+			continue
+		}
+		coords, _, err := polyline.DecodeCoords([]byte(activity.MapPolyline))
+		if err != nil || len(coords) == 0 {
+			s.l.Printf("Skipping GPX for activity %d: %v", activity.ID, err)
+			continue
+		}
+		entry, err := zw.Create(fmt.Sprintf("gpx/%d.gpx", activity.ID))
+		if err != nil {
+			return err
+		}
+		if err := writeGPX(entry, activity, coords); err != nil {
+			return err
+		}
+	}
+
+	summits, err := s.userPeaksDao.GetUserSummits(userID)
+	if err != nil {
+		return err
+	}
+	if err := writeJSONEntry(zw, "summits.json", summits); err != nil {
+		return err
+	}
+
+	goals, err := s.personalGoalDao.GetByUser(userID)
+	if err != nil {
+		return err
+	}
+	yearlyGoals, err := s.personalYearlyGoalDao.GetByUser(userID)
+	if err != nil {
+		return err
+	}
+	personalGoals := map[string]interface{}{
+		"goals":        goals,
+		"yearly_goals": yearlyGoals,
+	}
+	if err := writeJSONEntry(zw, "personal_goals.json", personalGoals); err != nil {
+		return err
+	}
+
+	favourites, err := s.summitFavouritesDao.GetWishlist(userID)
+	if err != nil {
+		return err
+	}
+	if err := writeJSONEntry(zw, "favourites.json", favourites); err != nil {
+		return err
+	}
+
+	challenges, err := s.challengeDao.GetChallengesByUser(userID)
+	if err != nil {
+		return err
+	}
+	if err := writeJSONEntry(zw, "challenges.json", challenges); err != nil {
+		return err
+	}
+
+	groups, err := s.groupsDao.GetUserGroups(userID)
+	if err != nil {
+		return err
+	}
+	return writeJSONEntry(zw, "groups.json", groups)
+}
+
+// allActivities reads every activity of the user, oldest first, a batch at
+// a time
+func (s *DataExportService) allActivities(userID int64) ([]models.Activity, error) {
+	filter := models.ActivityListFilter{
+		UserID: userID,
+		Sort:   models.ActivityListSortDate,
+		Limit:  exportActivityBatch,
+	}
+	activities := []models.Activity{}
+	for {
+		batch, err := s.activityDao.ListActivities(filter)
+		if err != nil {
+			return nil, err
+		}
+		more := len(batch) > filter.Limit
+		if more {
+			batch = batch[:filter.Limit]
+		}
+		activities = append(activities, batch...)
+		if !more {
+			return activities, nil
+		}
+		last := batch[len(batch)-1]
+		filter.Cursor = &models.ActivityCursor{Sort: filter.Sort, Date: last.StartDate, ID: last.ID}
+	}
+}
+
+func writeJSONEntry(zw *zip.Writer, name string, v interface{}) error {
+	entry, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(entry)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func generateExportToken() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// limitedWriter fails with ErrDataExportTooLarge once more than remaining
+// bytes have been written
+type limitedWriter struct {
+	w         io.Writer
+	remaining int64
+}
+
+func (lw *limitedWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > lw.remaining {
+		return 0, ErrDataExportTooLarge
+	}
+	n, err := lw.w.Write(p)
+	lw.remaining -= int64(n)
+	return n, err
+}
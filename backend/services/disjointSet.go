@@ -0,0 +1,26 @@
+package services
+
+// disjointSet is a union-find over the indexes 0..n-1, used to build
+// single-linkage clusters
+type disjointSet []int
+
+func newDisjointSet(n int) disjointSet {
+	parent := make(disjointSet, n)
+	for i := range parent {
+		parent[i] = i
+	}
+	return parent
+}
+
+// find returns the root of i's set, compressing the path as it goes
+func (s disjointSet) find(i int) int {
+	if s[i] != i {
+		s[i] = s.find(s[i])
+	}
+	return s[i]
+}
+
+// union merges j's set into i's
+func (s disjointSet) union(i, j int) {
+	s[s.find(j)] = s.find(i)
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"math"
+	"run-goals/config"
+	"run-goals/daos"
+	"run-goals/dem"
+	"run-goals/geo"
+	"run-goals/models"
+	"sort"
+
+	"github.com/twpayne/go-polyline"
+)
+
+var (
+	ErrElevationUnavailable = errors.New("no elevation tiles are configured")
+	ErrActivityNotFound     = errors.New("activity not found")
+	ErrActivityHasNoRoute   = errors.New("activity has no route")
+)
+
+const (
+	// Elevations outside this range can't be real. The low end is the Dead Sea shore.
+	minPlausibleElevation = -430.0
+	maxPlausibleElevation = 8849.0
+
+	// An OSM elevation this far from the DEM is treated as wrong, usually a
+	// value in feet. DEMs under-read sharp summits by far less than this.
+	maxElevationDisagreementMeters = 300.0
+
+	// Climbs and drops smaller than this don't count towards profile totals,
+	// so DEM noise on flat ground doesn't add up
+	profileHysteresisMeters = 5.0
+)
+
+type ElevationService struct {
+	l           *log.Logger
+	provider    *dem.Provider
+	peaksDao    *daos.PeaksDao
+	activityDao *daos.ActivityDao
+}
+
+func NewElevationService(
+	l *log.Logger,
+	config *config.Config,
+	peaksDao *daos.PeaksDao,
+	activityDao *daos.ActivityDao,
+) *ElevationService {
+	s := &ElevationService{
+		l:           l,
+		peaksDao:    peaksDao,
+		activityDao: activityDao,
+	}
+
+	if config.DEM.TileDir == "" {
+		l.Println("DEM_TILE_DIR not set, peak elevations won't be filled from DEM tiles")
+		return s
+	}
+	provider, err := dem.NewProvider(config.DEM.TileDir)
+	if err != nil {
+		l.Printf("Some DEM tiles couldn't be read: %v", err)
+	}
+	if provider != nil {
+		l.Printf("Loaded %d DEM tiles from %s", provider.TileCount(), config.DEM.TileDir)
+		s.provider = provider
+	}
+	return s
+}
+
+// Available reports whether DEM tiles are configured
+func (s *ElevationService) Available() bool {
+	return s.provider != nil && s.provider.TileCount() > 0
+}
+
+// FillPeakElevation replaces a missing or implausible elevation with the DEM
+// value and records where the elevation came from. It returns true when the
+// elevation was changed.
+func (s *ElevationService) FillPeakElevation(peak *models.Peak) bool {
+	known := peak.ElevationMeters != 0 &&
+		peak.ElevationMeters >= minPlausibleElevation && peak.ElevationMeters <= maxPlausibleElevation
+	if known && peak.ElevationSource == "" {
+		peak.ElevationSource = models.ElevationSourceOSM
+	}
+	// Already filled, or set by hand
+	if peak.ElevationSource == models.ElevationSourceDEM || peak.ElevationSource == models.ElevationSourceUser || !s.Available() {
+		return false
+	}
+
+	elevation, ok := s.provider.Elevation(peak.Latitude, peak.Longitude)
+	if !ok {
+		return false
+	}
+	if known && math.Abs(peak.ElevationMeters-elevation) <= maxElevationDisagreementMeters {
+		return false
+	}
+
+	peak.ElevationMeters = math.Round(elevation)
+	peak.ElevationSource = models.ElevationSourceDEM
+	return true
+}
+
+// FillPeakElevations runs FillPeakElevation over every peak and saves the
+// ones that changed
+func (s *ElevationService) FillPeakElevations() (*models.PeakElevationFillResult, error) {
+	if !s.Available() {
+		return nil, ErrElevationUnavailable
+	}
+
+	peaks, err := s.peaksDao.GetPeaks()
+	if err != nil {
+		return nil, err
+	}
+	// Peaks in the same tile go together so tiles aren't loaded twice
+	sort.Slice(peaks, func(i, j int) bool {
+		li, lj := math.Floor(peaks[i].Latitude), math.Floor(peaks[j].Latitude)
+		if li != lj {
+			return li < lj
+		}
+		return peaks[i].Longitude < peaks[j].Longitude
+	})
+
+	result := &models.PeakElevationFillResult{PeaksChecked: len(peaks)}
+	for i := range peaks {
+		peak := &peaks[i]
+		hadElevation := peak.ElevationMeters != 0
+		if !s.FillPeakElevation(peak) {
+			if peak.ElevationMeters == 0 {
+				result.NoCoverage++
+			}
+			continue
+		}
+		if err := s.peaksDao.UpdatePeakElevation(peak.ID, peak.ElevationMeters, peak.ElevationSource); err != nil {
+			return nil, err
+		}
+		if hadElevation {
+			result.Corrected++
+		} else {
+			result.Filled++
+		}
+	}
+
+	s.l.Printf("Peak elevation fill: checked %d, filled %d, corrected %d, no coverage %d",
+		result.PeaksChecked, result.Filled, result.Corrected, result.NoCoverage)
+	return result, nil
+}
+
+// GetActivityElevationProfile computes an elevation profile along one of the
+// user's activities from its polyline
+func (s *ElevationService) GetActivityElevationProfile(activityID int64, userID int64) (*models.ElevationProfile, error) {
+	activity, err := s.activityDao.GetActivityByID(activityID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrActivityNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if activity.UserID != userID {
+		return nil, ErrActivityNotFound
+	}
+	if activity.MapPolyline == "" {
+		return nil, ErrActivityHasNoRoute
+	}
+	if !s.Available() {
+		return nil, ErrElevationUnavailable
+	}
+
+	coords, _, err := polyline.DecodeCoords([]byte(activity.MapPolyline))
+	if err != nil {
+		s.l.Printf("Failed to decode polyline for activity %d: %v", activityID, err)
+		return nil, ErrActivityHasNoRoute
+	}
+	distances := geo.CumulativeDistances(coords)
+
+	profile := &models.ElevationProfile{
+		ActivityID: activityID,
+		Source:     models.ElevationSourceDEM,
+		Points:     []models.ElevationProfilePoint{},
+	}
+	if len(distances) > 0 {
+		profile.DistanceMeters = round2(distances[len(distances)-1])
+	}
+
+	var anchor float64
+	for i, coord := range coords {
+		elevation, ok := s.provider.Elevation(coord[0], coord[1])
+		if !ok {
+			continue
+		}
+		elevation = round2(elevation)
+
+		if len(profile.Points) == 0 {
+			anchor = elevation
+			profile.MinElevation, profile.MaxElevation = elevation, elevation
+		} else if delta := elevation - anchor; math.Abs(delta) >= profileHysteresisMeters {
+			if delta > 0 {
+				profile.TotalAscent += delta
+			} else {
+				profile.TotalDescent -= delta
+			}
+			anchor = elevation
+		}
+		profile.MinElevation = math.Min(profile.MinElevation, elevation)
+		profile.MaxElevation = math.Max(profile.MaxElevation, elevation)
+
+		profile.Points = append(profile.Points, models.ElevationProfilePoint{
+			DistanceMeters:  round2(distances[i]),
+			Latitude:        coord[0],
+			Longitude:       coord[1],
+			ElevationMeters: elevation,
+		})
+	}
+
+	profile.TotalAscent = round2(profile.TotalAscent)
+	profile.TotalDescent = round2(profile.TotalDescent)
+	if len(coords) > 0 {
+		profile.Coverage = round2(float64(len(profile.Points)) / float64(len(coords)))
+	}
+	return profile, nil
+}
@@ -0,0 +1,632 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"net/mail"
+	"net/url"
+	"run-goals/config"
+	"run-goals/daos"
+	"run-goals/models"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+var (
+	ErrEmailDisabled     = errors.New("email is not configured on this server")
+	ErrEmailInvalid      = errors.New("invalid email address")
+	ErrEmailKindInvalid  = errors.New("unknown email kind")
+	ErrEmailTokenInvalid = errors.New("link is invalid or has already been used")
+)
+
+const (
+	// emailPollInterval is how often the worker looks for emails it wasn't
+	// woken for, e.g. retries coming due
+	emailPollInterval = time.Minute
+	maxEmailAttempts  = 3
+	emailRetryDelay   = 5 * time.Minute
+	emailHistoryLimit = 50
+	// digestSendHour is the local hour on the first day of the week after
+	// which the digest for the week just ended goes out
+	digestSendHour = 7
+)
+
+//go:embed emails
+var emailTemplates embed.FS
+
+type EmailService struct {
+	l                    *log.Logger
+	config               *config.Config
+	emailDao             *daos.EmailDao
+	userDao              *daos.UserDao
+	activityDao          *daos.ActivityDao
+	userPeaksDao         *daos.UserPeaksDao
+	peaksDao             *daos.PeaksDao
+	challengeDao         *daos.ChallengeDao
+	personalGoalsService *PersonalGoalsService
+	mailer               smtpMailer
+	html                 *htmltemplate.Template
+	text                 *texttemplate.Template
+	wake                 chan struct{}
+}
+
+func NewEmailService(
+	l *log.Logger,
+	config *config.Config,
+	emailDao *daos.EmailDao,
+	userDao *daos.UserDao,
+	activityDao *daos.ActivityDao,
+	userPeaksDao *daos.UserPeaksDao,
+	peaksDao *daos.PeaksDao,
+	challengeDao *daos.ChallengeDao,
+	personalGoalsService *PersonalGoalsService,
+) *EmailService {
+	return &EmailService{
+		l:                    l,
+		config:               config,
+		emailDao:             emailDao,
+		userDao:              userDao,
+		activityDao:          activityDao,
+		userPeaksDao:         userPeaksDao,
+		peaksDao:             peaksDao,
+		challengeDao:         challengeDao,
+		personalGoalsService: personalGoalsService,
+		mailer:               smtpMailer{config: config.Email},
+		html:                 htmltemplate.Must(htmltemplate.ParseFS(emailTemplates, "emails/*.html")),
+		text:                 texttemplate.Must(texttemplate.ParseFS(emailTemplates, "emails/*.txt")),
+		wake:                 make(chan struct{}, 1),
+	}
+}
+
+// Enabled is whether an SMTP server is configured
+func (s *EmailService) Enabled() bool {
+	return s.config.Email.SMTPHost != ""
+}
+
+// ==================== Settings ====================
+
+// GetSettings returns the user's address and whether each kind of email is
+// on
+func (s *EmailService) GetSettings(userID int64) (*models.EmailSettings, error) {
+	settings, err := s.userDao.GetEmailSettings(userID)
+	if err != nil {
+		return nil, err
+	}
+	preferences := models.EmailPreferences{}
+	for _, kind := range models.EmailKinds {
+		preferences[kind] = settings.Preferences.Enabled(kind)
+	}
+	settings.Preferences = preferences
+	return settings, nil
+}
+
+// SetEmail changes the user's address and sends it a verification link.
+// Nothing else is sent to it until the link is followed.
+func (s *EmailService) SetEmail(userID int64, email string) (*models.EmailSettings, error) {
+	if !s.Enabled() {
+		return nil, ErrEmailDisabled
+	}
+	email = strings.TrimSpace(email)
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return nil, ErrEmailInvalid
+	}
+
+	verificationToken, err := generateEmailToken()
+	if err != nil {
+		return nil, err
+	}
+	unsubscribeToken, err := generateEmailToken()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.userDao.SetEmail(userID, email, verificationToken, unsubscribeToken); err != nil {
+		return nil, err
+	}
+
+	settings, err := s.GetSettings(userID)
+	if err != nil {
+		return nil, err
+	}
+	data := struct {
+		emailContext
+		VerifyURL string
+	}{
+		emailContext: s.context(*settings, ""),
+		VerifyURL:    s.link("/email/verify", url.Values{"token": {verificationToken}}),
+	}
+	if err := s.queue(*settings, models.EmailKindVerification, "Confirm your email address", "verification", data, ""); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+func (s *EmailService) VerifyEmail(token string) error {
+	if token == "" {
+		return ErrEmailTokenInvalid
+	}
+	verified, err := s.userDao.VerifyEmail(token)
+	if err != nil {
+		return err
+	}
+	if !verified {
+		return ErrEmailTokenInvalid
+	}
+	return nil
+}
+
+func (s *EmailService) RemoveEmail(userID int64) error {
+	return s.userDao.ClearEmail(userID)
+}
+
+// UpdatePreferences changes the kinds given and leaves the rest as they were
+func (s *EmailService) UpdatePreferences(userID int64, changes models.EmailPreferences) (*models.EmailSettings, error) {
+	for kind := range changes {
+		if !isEmailKind(kind) {
+			return nil, ErrEmailKindInvalid
+		}
+	}
+
+	settings, err := s.GetSettings(userID)
+	if err != nil {
+		return nil, err
+	}
+	for kind, enabled := range changes {
+		settings.Preferences[kind] = enabled
+	}
+	if err := s.userDao.UpdateEmailPreferences(userID, settings.Preferences); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// Unsubscribe turns off one kind of email, or all of them when kind is
+// empty, for the user with the unsubscribe token
+func (s *EmailService) Unsubscribe(token string, kind models.EmailKind) error {
+	if kind != "" && !isEmailKind(kind) {
+		return ErrEmailKindInvalid
+	}
+	if token == "" {
+		return ErrEmailTokenInvalid
+	}
+	settings, err := s.userDao.GetEmailSettingsByUnsubscribeToken(token)
+	if err != nil {
+		return err
+	}
+	if settings == nil {
+		return ErrEmailTokenInvalid
+	}
+
+	for _, k := range models.EmailKinds {
+		if kind == "" || k == kind {
+			settings.Preferences[k] = false
+		}
+	}
+	s.l.Printf("User %d unsubscribed from %q emails", settings.UserID, kind)
+	return s.userDao.UpdateEmailPreferences(settings.UserID, settings.Preferences)
+}
+
+// GetHistory returns the emails most recently sent or queued for the user
+func (s *EmailService) GetHistory(userID int64) ([]models.EmailSend, error) {
+	return s.emailDao.GetEmailsByUser(userID, emailHistoryLimit)
+}
+
+// ==================== Alerts ====================
+
+// alertKinds maps the notifications that can also be emailed to their kind
+// of email
+var alertKinds = map[models.NotificationType]models.EmailKind{
+	models.NotificationTypeChallengeEnding:    models.EmailKindChallengeEnding,
+	models.NotificationTypeChallengeCompleted: models.EmailKindChallengeCompleted,
+	models.NotificationTypeGroupAdded:         models.EmailKindGroupInvite,
+}
+
+// QueueAlert emails the notification if it's one that can be emailed and
+// the user has opted in. Each event is emailed once.
+func (s *EmailService) QueueAlert(notification models.Notification) error {
+	kind, ok := alertKinds[notification.Type]
+	if !ok || !s.Enabled() || notification.DedupeKey == "" {
+		return nil
+	}
+	settings, err := s.userDao.GetEmailSettings(notification.UserID)
+	if err != nil {
+		return err
+	}
+	if settings.Email == "" || !settings.Verified || !settings.Preferences.Enabled(kind) {
+		return nil
+	}
+
+	data := struct {
+		emailContext
+		Title string
+		Body  string
+	}{
+		emailContext: s.context(*settings, kind),
+		Title:        notification.Title,
+		Body:         notification.Body,
+	}
+	return s.queue(*settings, kind, notification.Title, "alert", data, "alert:"+notification.DedupeKey)
+}
+
+// ==================== Digests ====================
+
+type digestSummit struct {
+	Name       string
+	Elevation  float64
+	SummitedAt time.Time
+}
+
+type digestGoal struct {
+	Name    string
+	Unit    string
+	Actual  float64
+	Goal    float64
+	Percent float64
+	Status  string
+}
+
+type digestChallenge struct {
+	Name     string
+	Standing string
+}
+
+// QueueWeeklyDigests queues last week's digest for every opted in user
+// whose week has started, unless it's already gone out. Safe to run often.
+func (s *EmailService) QueueWeeklyDigests() error {
+	if !s.Enabled() {
+		return nil
+	}
+	recipients, err := s.userDao.GetEmailRecipients(models.EmailKindWeeklyDigest)
+	if err != nil {
+		return err
+	}
+	queued := 0
+	for _, settings := range recipients {
+		ok, err := s.queueDigest(settings)
+		if err != nil {
+			s.l.Printf("Failed to queue weekly digest for user %d: %v", settings.UserID, err)
+			continue
+		}
+		if ok {
+			queued++
+		}
+	}
+	if queued > 0 {
+		s.l.Printf("Queued %d weekly digests", queued)
+	}
+	return nil
+}
+
+func (s *EmailService) queueDigest(settings models.EmailSettings) (bool, error) {
+	// Activity times are local wall-clock times stored as UTC
+	now := localToday(settings.Timezone)
+	to := streakPeriodStart(now, true, time.Weekday(settings.WeekStart))
+	if now.Sub(to) < digestSendHour*time.Hour {
+		return false, nil
+	}
+	from := to.AddDate(0, 0, -7)
+	last := to.Add(-time.Microsecond)
+	dedupeKey := "weekly_digest:" + from.Format("2006-01-02")
+
+	sent, err := s.emailDao.HasEmail(settings.UserID, dedupeKey)
+	if err != nil || sent {
+		return false, err
+	}
+
+	data := struct {
+		emailContext
+		From       time.Time
+		To         time.Time
+		Activities int
+		DistanceKm float64
+		ElevationM float64
+		Summits    []digestSummit
+		Year       int
+		Goals      []digestGoal
+		Challenges []digestChallenge
+	}{
+		emailContext: s.context(settings, models.EmailKindWeeklyDigest),
+		From:         from,
+		To:           last,
+		Year:         last.Year(),
+	}
+
+	activities, err := s.activityDao.GetActivitiesByUserIDAndDateRange(settings.UserID, &from, &last)
+	if err != nil {
+		return false, err
+	}
+	for _, activity := range activities {
+		data.Activities++
+		data.DistanceKm += activity.Distance / 1000
+		data.ElevationM += activity.Elevation
+	}
+
+	data.Summits, err = s.digestSummits(settings.UserID, from, last)
+	if err != nil {
+		return false, err
+	}
+	data.Goals, err = s.digestGoals(settings.UserID, data.Year)
+	if err != nil {
+		return false, err
+	}
+	data.Challenges, err = s.digestChallenges(settings.UserID, from)
+	if err != nil {
+		return false, err
+	}
+
+	subject := fmt.Sprintf("Your week: %d %s", data.Activities, pluralise(data.Activities, "activity", "activities"))
+	if len(data.Summits) > 0 {
+		subject += fmt.Sprintf(", %d %s", len(data.Summits), pluralise(len(data.Summits), "summit", "summits"))
+	}
+	if err := s.queue(settings, models.EmailKindWeeklyDigest, subject, "weekly_digest", data, dedupeKey); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *EmailService) digestSummits(userID int64, from time.Time, to time.Time) ([]digestSummit, error) {
+	summits, err := s.userPeaksDao.GetUserSummitsWithPeaksInDateRange(userID, from, to)
+	if err != nil || len(summits) == 0 {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(summits))
+	for _, summit := range summits {
+		ids = append(ids, summit.PeakID)
+	}
+	peaks, err := s.peaksDao.GetPeaksByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[int64]string, len(peaks))
+	for _, peak := range peaks {
+		names[peak.ID] = peak.Name
+	}
+
+	result := []digestSummit{}
+	for _, summit := range summits {
+		name := names[summit.PeakID]
+		if name == "" {
+			name = "Unnamed peak"
+		}
+		result = append(result, digestSummit{Name: name, Elevation: summit.Elevation, SummitedAt: summit.SummitedAt})
+	}
+	return result, nil
+}
+
+func (s *EmailService) digestGoals(userID int64, year int) ([]digestGoal, error) {
+	progress, err := s.personalGoalsService.GetGoalProgress(userID, year)
+	if err != nil {
+		return nil, err
+	}
+	metrics := []struct {
+		name, unit string
+		metric     models.GoalMetricProgress
+	}{
+		{"Distance", "km", progress.Distance},
+		{"Elevation", "m", progress.Elevation},
+		{"Summits", "summits", progress.Summits},
+	}
+
+	goals := []digestGoal{}
+	for _, m := range metrics {
+		if m.metric.Goal <= 0 {
+			continue
+		}
+		goals = append(goals, digestGoal{
+			Name:    m.name,
+			Unit:    m.unit,
+			Actual:  m.metric.Actual,
+			Goal:    m.metric.Goal,
+			Percent: m.metric.PercentComplete,
+			Status:  strings.ReplaceAll(string(m.metric.Status), "_", " "),
+		})
+	}
+	return goals, nil
+}
+
+// digestChallenges describes where the user stands in challenges they're
+// in that were running during the week
+func (s *EmailService) digestChallenges(userID int64, from time.Time) ([]digestChallenge, error) {
+	challenges, err := s.challengeDao.GetChallengesByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []digestChallenge{}
+	for _, challenge := range challenges {
+		if challenge.Deadline != nil && challenge.Deadline.Before(from) {
+			continue
+		}
+		leaderboard, err := s.challengeDao.GetChallengeLeaderboard(challenge.ID, userID)
+		if err != nil {
+			return nil, err
+		}
+		var entry *models.LeaderboardEntry
+		for i := range leaderboard {
+			if leaderboard[i].UserID == userID {
+				entry = &leaderboard[i]
+				break
+			}
+		}
+		if entry == nil {
+			// Created the challenge but isn't taking part
+			continue
+		}
+
+		var standing string
+		switch {
+		case challenge.IsCompleted:
+			standing = "completed"
+		case challenge.CompetitionMode == models.CompetitionModeCompetitive:
+			standing = fmt.Sprintf("%s of %d", ordinal(entry.Rank), len(leaderboard))
+		case challenge.GoalType == models.GoalTypeDistance:
+			standing = fmt.Sprintf("%.1f km so far", entry.TotalDistance/1000)
+		case challenge.GoalType == models.GoalTypeElevation:
+			standing = fmt.Sprintf("%.0f m so far", entry.TotalElevation)
+		case challenge.GoalType == models.GoalTypeSummitCount:
+			standing = fmt.Sprintf("%d %s so far", entry.TotalSummitCount, pluralise(entry.TotalSummitCount, "summit", "summits"))
+		case challenge.GoalType == models.GoalTypeStreak:
+			standing = fmt.Sprintf("longest streak %d", entry.LongestStreak)
+		default:
+			standing = fmt.Sprintf("%d of %d peaks", entry.PeaksCompleted, entry.TotalPeaks)
+		}
+		result = append(result, digestChallenge{Name: challenge.Name, Standing: standing})
+	}
+	return result, nil
+}
+
+// ==================== Sending ====================
+
+// Run sends queued emails one at a time. It never returns, unless email is
+// disabled.
+func (s *EmailService) Run() {
+	if !s.Enabled() {
+		s.l.Println("Email disabled, SMTP_HOST is not set")
+		return
+	}
+	if n, err := s.emailDao.RequeueSendingEmails(); err != nil {
+		s.l.Printf("Failed to requeue interrupted emails: %v", err)
+	} else if n > 0 {
+		s.l.Printf("Requeued %d interrupted emails", n)
+	}
+
+	for {
+		for {
+			email, err := s.emailDao.StartNextEmail()
+			if err != nil {
+				s.l.Printf("Failed to start email: %v", err)
+				break
+			}
+			if email == nil {
+				break
+			}
+			s.send(*email)
+		}
+
+		select {
+		case <-s.wake:
+		case <-time.After(emailPollInterval):
+		}
+	}
+}
+
+func (s *EmailService) send(email models.EmailSend) {
+	err := s.mailer.send(email.Recipient, email.Subject, email.TextBody, email.HTMLBody)
+	if err == nil {
+		s.emailDao.MarkEmailSent(email.ID)
+		return
+	}
+
+	s.l.Printf("Failed to send email %d (attempt %d): %v", email.ID, email.Attempts, err)
+	var retryAt *time.Time
+	if email.Attempts < maxEmailAttempts {
+		at := time.Now().Add(time.Duration(email.Attempts) * emailRetryDelay)
+		retryAt = &at
+	}
+	s.emailDao.MarkEmailFailed(email.ID, err.Error(), retryAt)
+}
+
+// emailContext is what every template's footer needs
+type emailContext struct {
+	Name              string
+	UnsubscribeURL    string // Empty for emails that can't be turned off
+	UnsubscribeAllURL string
+}
+
+func (s *EmailService) context(settings models.EmailSettings, kind models.EmailKind) emailContext {
+	ctx := emailContext{Name: settings.Name}
+	if ctx.Name == "" {
+		ctx.Name = "there"
+	}
+	if settings.UnsubscribeToken != "" && kind != "" {
+		ctx.UnsubscribeURL = s.link("/email/unsubscribe", url.Values{"token": {settings.UnsubscribeToken}, "kind": {string(kind)}})
+		ctx.UnsubscribeAllURL = s.link("/email/unsubscribe", url.Values{"token": {settings.UnsubscribeToken}})
+	}
+	return ctx
+}
+
+func (s *EmailService) link(path string, query url.Values) string {
+	return strings.TrimRight(s.config.Email.BaseURL, "/") + path + "?" + query.Encode()
+}
+
+// queue renders the template's text and HTML versions and queues the email
+// for the worker
+func (s *EmailService) queue(settings models.EmailSettings, kind models.EmailKind, subject string, template string, data interface{}, dedupeKey string) error {
+	var text, html bytes.Buffer
+	if err := s.text.ExecuteTemplate(&text, template+".txt", data); err != nil {
+		return fmt.Errorf("rendering %s text: %w", template, err)
+	}
+	if err := s.html.ExecuteTemplate(&html, template+".html", data); err != nil {
+		return fmt.Errorf("rendering %s html: %w", template, err)
+	}
+
+	queued, err := s.emailDao.QueueEmail(models.EmailSend{
+		UserID:    settings.UserID,
+		Kind:      kind,
+		Recipient: settings.Email,
+		Subject:   subject,
+		TextBody:  text.String(),
+		HTMLBody:  html.String(),
+		DedupeKey: dedupeKey,
+	})
+	if err != nil {
+		return err
+	}
+	if queued {
+		s.notify()
+	}
+	return nil
+}
+
+// notify wakes the worker, unless it's already due to look
+func (s *EmailService) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func generateEmailToken() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+func isEmailKind(kind models.EmailKind) bool {
+	for _, known := range models.EmailKinds {
+		if kind == known {
+			return true
+		}
+	}
+	return false
+}
+
+func pluralise(n int, singular string, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
+
+func ordinal(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"math"
+	"run-goals/models"
+	"time"
+)
+
+// goalTrendDays is the window used to project the end-of-period total. Early in
+// a period, when fewer days have elapsed, the period-to-date rate is used instead.
+const goalTrendDays = 28
+
+// goalPaceTolerance is how far (as a fraction of the expected value) the actual
+// total can drift from an even pace and still count as on track
+const goalPaceTolerance = 0.05
+
+// yearBounds returns the first instant of the year and the first instant of
+// the following one
+func yearBounds(year int) (time.Time, time.Time) {
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(1, 0, 0)
+}
+
+// goalAsOf clamps now into the period [start, end), so past periods are
+// measured at their end and future ones at their start
+func goalAsOf(start time.Time, end time.Time, now time.Time) time.Time {
+	if now.Before(start) {
+		return start
+	}
+	if !now.Before(end) {
+		return end
+	}
+	return now
+}
+
+// goalMetric works out the pace for one metric over the period [start, end).
+// actual is the period-to-date total and recent is the total over the trend
+// window ending at asOf.
+func goalMetric(goal float64, actual float64, recent float64, start time.Time, end time.Time, asOf time.Time) models.GoalMetricProgress {
+	periodDays := end.Sub(start).Hours() / 24
+	elapsedDays := asOf.Sub(start).Hours() / 24
+	remainingDays := periodDays - elapsedDays
+
+	progress := models.GoalMetricProgress{
+		Goal:   goal,
+		Actual: round2(actual),
+	}
+
+	// Projection from the recent trend, falling back to the period-to-date rate
+	projected := actual
+	if remainingDays > 0 {
+		rate := 0.0
+		if elapsedDays >= goalTrendDays {
+			rate = recent / goalTrendDays
+		} else if elapsedDays > 0 {
+			rate = actual / elapsedDays
+		}
+		projected = actual + rate*remainingDays
+	}
+	progress.ProjectedTotal = round2(projected)
+
+	if goal <= 0 {
+		progress.Status = models.GoalPaceStatusNoGoal
+		return progress
+	}
+
+	expected := goal * elapsedDays / periodDays
+	progress.ExpectedToDate = round2(expected)
+	progress.PercentComplete = round2(actual / goal * 100)
+
+	if remaining := goal - actual; remaining > 0 && remainingDays > 0 {
+		progress.RequiredWeeklyRate = round2(remaining / (remainingDays / 7))
+	}
+
+	switch {
+	case actual >= goal:
+		progress.Status = models.GoalPaceStatusCompleted
+	case actual < expected*(1-goalPaceTolerance):
+		progress.Status = models.GoalPaceStatusBehind
+	case actual > expected*(1+goalPaceTolerance):
+		progress.Status = models.GoalPaceStatusAhead
+	default:
+		progress.Status = models.GoalPaceStatusOnTrack
+	}
+	return progress
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"encoding/xml"
+	"io"
+	"run-goals/models"
+	"time"
+)
+
+// GPX 1.1 document, just the parts we can fill from a Strava summary
+type gpxFile struct {
+	XMLName  xml.Name    `xml:"gpx"`
+	Version  string      `xml:"version,attr"`
+	Creator  string      `xml:"creator,attr"`
+	XMLNS    string      `xml:"xmlns,attr"`
+	Metadata gpxMetadata `xml:"metadata"`
+	Track    gpxTrack    `xml:"trk"`
+}
+
+type gpxMetadata struct {
+	Name string `xml:"name"`
+	Time string `xml:"time"`
+}
+
+type gpxTrack struct {
+	Name    string     `xml:"name"`
+	Type    string     `xml:"type,omitempty"`
+	Segment gpxSegment `xml:"trkseg"`
+}
+
+type gpxSegment struct {
+	Points []gpxPoint `xml:"trkpt"`
+}
+
+type gpxPoint struct {
+	Lat float64 `xml:"lat,attr"`
+	Lon float64 `xml:"lon,attr"`
+}
+
+// writeGPX writes the activity's route as a GPX track. The summary polyline
+// has no timestamps or elevations, so the points are bare positions.
+func writeGPX(w io.Writer, activity models.Activity, coords [][]float64) error {
+	trackType := activity.SportType
+	if trackType == "" {
+		trackType = activity.Type
+	}
+	doc := gpxFile{
+		Version: "1.1",
+		Creator: "run-goals",
+		XMLNS:   "http://www.topografix.com/GPX/1/1",
+		Metadata: gpxMetadata{
+			Name: activity.Name,
+			Time: activity.StartDate.UTC().Format(time.RFC3339),
+		},
+		Track: gpxTrack{
+			Name: activity.Name,
+			Type: trackType,
+		},
+	}
+	for _, c := range coords {
+		doc.Track.Segment.Points = append(doc.Track.Segment.Points, gpxPoint{Lat: c[0], Lon: c[1]})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(doc)
+}
@@ -0,0 +1,536 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"run-goals/daos"
+	"run-goals/models"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrGroupWebhookNotFound       = errors.New("webhook not found")
+	ErrNotGroupAdmin              = errors.New("only group admins can manage webhooks")
+	ErrGroupWebhookURLInvalid     = errors.New("webhook url must be an https url")
+	ErrGroupWebhookFormatInvalid  = errors.New("unknown webhook format")
+	ErrGroupWebhookEventInvalid   = errors.New("unknown webhook event")
+	ErrGroupWebhookEventsRequired = errors.New("at least one event is required")
+	ErrGroupWebhookLimitReached   = errors.New("the group already has the maximum number of webhooks")
+)
+
+const (
+	maxGroupWebhooks = 5
+
+	// webhookPollInterval is how often the worker looks for deliveries it
+	// wasn't woken for, e.g. retries coming due
+	webhookPollInterval = time.Minute
+	webhookTimeout      = 10 * time.Second
+	// Retries back off from webhookRetryDelay, doubling each time
+	maxWebhookAttempts   = 6
+	webhookRetryDelay    = 30 * time.Second
+	maxWebhookRetryAfter = time.Hour
+
+	defaultWebhookDeliveryPageSize = 50
+	maxWebhookDeliveryPageSize     = 200
+	// webhookResponseSnippet is how much of a failed response's body is kept
+	// in the delivery log
+	webhookResponseSnippet = 500
+)
+
+type GroupWebhookService struct {
+	l               *log.Logger
+	groupWebhookDao *daos.GroupWebhookDao
+	groupsDao       *daos.GroupsDao
+	userDao         *daos.UserDao
+	client          *http.Client
+	wake            chan struct{}
+}
+
+func NewGroupWebhookService(
+	l *log.Logger,
+	groupWebhookDao *daos.GroupWebhookDao,
+	groupsDao *daos.GroupsDao,
+	userDao *daos.UserDao,
+) *GroupWebhookService {
+	return &GroupWebhookService{
+		l:               l,
+		groupWebhookDao: groupWebhookDao,
+		groupsDao:       groupsDao,
+		userDao:         userDao,
+		client:          newWebhookClient(),
+		wake:            make(chan struct{}, 1),
+	}
+}
+
+// ==================== Webhooks ====================
+
+func (s *GroupWebhookService) GetWebhooks(userID int64, groupID int64) ([]models.GroupWebhook, error) {
+	if err := s.requireAdmin(groupID, userID); err != nil {
+		return nil, err
+	}
+	return s.groupWebhookDao.GetWebhooksByGroup(groupID)
+}
+
+// CreateWebhook registers a webhook for the group with a new signing secret
+func (s *GroupWebhookService) CreateWebhook(userID int64, webhook models.GroupWebhook) (*models.GroupWebhook, error) {
+	if err := s.requireAdmin(webhook.GroupID, userID); err != nil {
+		return nil, err
+	}
+	if err := validateWebhook(&webhook); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.groupWebhookDao.GetWebhooksByGroup(webhook.GroupID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxGroupWebhooks {
+		return nil, ErrGroupWebhookLimitReached
+	}
+
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+	webhook.Secret = secret
+	webhook.Enabled = true
+	webhook.CreatedBy = &userID
+
+	created, err := s.groupWebhookDao.CreateWebhook(webhook)
+	if err != nil {
+		return nil, err
+	}
+	s.l.Printf("Group %d webhook %d created by user %d", created.GroupID, created.ID, userID)
+	return created, nil
+}
+
+// UpdateWebhook changes the webhook's URL, format, events and whether it's
+// enabled
+func (s *GroupWebhookService) UpdateWebhook(userID int64, changes models.GroupWebhook) (*models.GroupWebhook, error) {
+	webhook, err := s.getManagedWebhook(changes.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateWebhook(&changes); err != nil {
+		return nil, err
+	}
+
+	webhook.URL = changes.URL
+	webhook.Format = changes.Format
+	webhook.Events = changes.Events
+	webhook.Enabled = changes.Enabled
+	if err := s.groupWebhookDao.UpdateWebhook(*webhook); err != nil {
+		return nil, err
+	}
+	return s.groupWebhookDao.GetWebhook(webhook.ID)
+}
+
+func (s *GroupWebhookService) DeleteWebhook(userID int64, webhookID int64) error {
+	if _, err := s.getManagedWebhook(webhookID, userID); err != nil {
+		return err
+	}
+	return s.groupWebhookDao.DeleteWebhook(webhookID)
+}
+
+// GetDeliveries returns the webhook's delivery log, newest first
+func (s *GroupWebhookService) GetDeliveries(userID int64, webhookID int64, limit int) ([]models.GroupWebhookDelivery, error) {
+	if _, err := s.getManagedWebhook(webhookID, userID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > maxWebhookDeliveryPageSize {
+		limit = defaultWebhookDeliveryPageSize
+	}
+	return s.groupWebhookDao.GetDeliveries(webhookID, limit)
+}
+
+// getManagedWebhook returns the webhook if the user is an admin of its group
+func (s *GroupWebhookService) getManagedWebhook(webhookID int64, userID int64) (*models.GroupWebhook, error) {
+	webhook, err := s.groupWebhookDao.GetWebhook(webhookID)
+	if err != nil {
+		return nil, err
+	}
+	if webhook == nil {
+		return nil, ErrGroupWebhookNotFound
+	}
+	if err := s.requireAdmin(webhook.GroupID, userID); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func (s *GroupWebhookService) requireAdmin(groupID int64, userID int64) error {
+	role, err := s.groupsDao.GetGroupMemberRole(groupID, userID)
+	if err != nil {
+		return err
+	}
+	if role == nil || *role != "admin" {
+		return ErrNotGroupAdmin
+	}
+	return nil
+}
+
+// validateWebhook checks the URL, format and events, defaulting the format
+// to generic and dropping repeated events
+func validateWebhook(webhook *models.GroupWebhook) error {
+	webhook.URL = strings.TrimSpace(webhook.URL)
+	parsed, err := url.Parse(webhook.URL)
+	if err != nil || parsed.Scheme != "https" || parsed.Hostname() == "" || parsed.User != nil {
+		return ErrGroupWebhookURLInvalid
+	}
+
+	switch webhook.Format {
+	case "":
+		webhook.Format = models.GroupWebhookFormatGeneric
+	case models.GroupWebhookFormatGeneric, models.GroupWebhookFormatSlack, models.GroupWebhookFormatDiscord:
+	default:
+		return ErrGroupWebhookFormatInvalid
+	}
+
+	if len(webhook.Events) == 0 {
+		return ErrGroupWebhookEventsRequired
+	}
+	seen := map[models.GroupWebhookEvent]bool{}
+	events := []models.GroupWebhookEvent{}
+	for _, event := range webhook.Events {
+		if !isGroupWebhookEvent(event) {
+			return ErrGroupWebhookEventInvalid
+		}
+		if !seen[event] {
+			seen[event] = true
+			events = append(events, event)
+		}
+	}
+	webhook.Events = events
+	return nil
+}
+
+// ==================== Events ====================
+
+// PublishSummit posts a summit to the user's groups. Each summit is only
+// posted once, however often it's detected, and old summits found by a
+// first sync or resync aren't posted at all.
+func (s *GroupWebhookService) PublishSummit(userID int64, peak models.Peak, activity *models.Activity, summitedAt time.Time) error {
+	if time.Since(summitedAt) > summitNotificationMaxAge {
+		return nil
+	}
+	// Group chats are outside the app, so only activities anyone may see are
+	// posted, as with the public feed
+	if activity.IsHiddenFromOthers() || activity.Visibility == models.VisibilityFollowersOnly {
+		return nil
+	}
+	activityID := activity.ID
+	name := peak.Name
+	if name == "" {
+		name = "an unnamed peak"
+	}
+	payload := models.GroupWebhookPayload{
+		Event:      models.GroupWebhookEventSummitDetected,
+		OccurredAt: summitedAt,
+		PeakID:     &peak.ID,
+		PeakName:   peak.Name,
+		ActivityID: &activityID,
+	}
+	if peak.ElevationMeters > 0 {
+		elevation := peak.ElevationMeters
+		payload.PeakElevationMeters = &elevation
+		name += fmt.Sprintf(" (%.0f m)", elevation)
+	}
+	message := func(user string) string {
+		return user + " just bagged " + name
+	}
+	return s.publish(userID, payload, message, nil, fmt.Sprintf("summit:%d:%d", activityID, peak.ID))
+}
+
+func (s *GroupWebhookService) PublishChallengeJoined(userID int64, challenge models.Challenge) error {
+	payload := models.GroupWebhookPayload{
+		Event:         models.GroupWebhookEventChallengeJoined,
+		ChallengeID:   &challenge.ID,
+		ChallengeName: challenge.Name,
+	}
+	message := func(user string) string {
+		return user + " joined " + challenge.Name
+	}
+	return s.publish(userID, payload, message, &challenge.ID, fmt.Sprintf("challenge_joined:%d:%d", challenge.ID, userID))
+}
+
+func (s *GroupWebhookService) PublishChallengeCompleted(userID int64, challenge models.Challenge) error {
+	payload := models.GroupWebhookPayload{
+		Event:         models.GroupWebhookEventChallengeCompleted,
+		ChallengeID:   &challenge.ID,
+		ChallengeName: challenge.Name,
+	}
+	message := func(user string) string {
+		return user + " completed " + challenge.Name
+	}
+	return s.publish(userID, payload, message, &challenge.ID, fmt.Sprintf("challenge_completed:%d:%d", challenge.ID, userID))
+}
+
+// PublishLeaderboardChanged posts the user moving up a challenge leaderboard
+func (s *GroupWebhookService) PublishLeaderboardChanged(userID int64, challenge models.Challenge, position int, previousPosition int) error {
+	payload := models.GroupWebhookPayload{
+		Event:            models.GroupWebhookEventLeaderboardChanged,
+		ChallengeID:      &challenge.ID,
+		ChallengeName:    challenge.Name,
+		Position:         &position,
+		PreviousPosition: &previousPosition,
+	}
+	message := func(user string) string {
+		return fmt.Sprintf("%s moved up to %s place in %s", user, ordinal(position), challenge.Name)
+	}
+	return s.publish(userID, payload, message, &challenge.ID, "")
+}
+
+// publish queues the event for every webhook that wants it. message writes
+// the chat message given the user's name.
+func (s *GroupWebhookService) publish(userID int64, payload models.GroupWebhookPayload, message func(string) string, challengeID *int64, dedupeKey string) error {
+	webhooks, err := s.groupWebhookDao.GetWebhooksForEvent(userID, payload.Event, challengeID)
+	if err != nil || len(webhooks) == 0 {
+		return err
+	}
+
+	user, err := s.userDao.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	payload.UserID = userID
+	payload.UserName = user.Username.String
+	name := payload.UserName
+	if name == "" {
+		name = "A group member"
+	}
+	payload.Text = message(name)
+	if payload.OccurredAt.IsZero() {
+		payload.OccurredAt = time.Now()
+	}
+
+	queued := false
+	for _, webhook := range webhooks {
+		payload.GroupID = webhook.GroupID
+		payload.GroupName = webhook.GroupName
+		body, err := renderWebhookBody(webhook.Format, payload)
+		if err != nil {
+			return err
+		}
+		created, err := s.groupWebhookDao.QueueDelivery(models.GroupWebhookDelivery{
+			WebhookID: webhook.ID,
+			Event:     payload.Event,
+			Payload:   string(body),
+			DedupeKey: dedupeKey,
+		})
+		if err != nil {
+			return err
+		}
+		queued = queued || created
+	}
+	if queued {
+		s.notify()
+	}
+	return nil
+}
+
+// renderWebhookBody shapes the payload for the webhook's format. The chat
+// formats only get the text, escaped so names can't ping everyone.
+func renderWebhookBody(format models.GroupWebhookFormat, payload models.GroupWebhookPayload) ([]byte, error) {
+	switch format {
+	case models.GroupWebhookFormatSlack:
+		escaper := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+		return json.Marshal(map[string]string{"text": escaper.Replace(payload.Text)})
+	case models.GroupWebhookFormatDiscord:
+		return json.Marshal(map[string]interface{}{
+			"content":          payload.Text,
+			"allowed_mentions": map[string][]string{"parse": {}},
+		})
+	default:
+		return json.Marshal(payload)
+	}
+}
+
+// ==================== Worker ====================
+
+// Run delivers queued requests until the process exits. Failures are retried
+// with backoff, except for client errors a retry won't fix.
+func (s *GroupWebhookService) Run() {
+	if n, err := s.groupWebhookDao.RequeueSendingDeliveries(); err != nil {
+		s.l.Printf("Failed to requeue interrupted webhook deliveries: %v", err)
+	} else if n > 0 {
+		s.l.Printf("Requeued %d interrupted webhook deliveries", n)
+	}
+
+	for {
+		for {
+			delivery, err := s.groupWebhookDao.StartNextDelivery()
+			if err != nil {
+				s.l.Printf("Failed to start webhook delivery: %v", err)
+				break
+			}
+			if delivery == nil {
+				break
+			}
+			s.deliver(*delivery)
+		}
+
+		select {
+		case <-s.wake:
+		case <-time.After(webhookPollInterval):
+		}
+	}
+}
+
+func (s *GroupWebhookService) deliver(delivery models.GroupWebhookDelivery) {
+	webhook, err := s.groupWebhookDao.GetWebhook(delivery.WebhookID)
+	if err != nil {
+		retryAt := time.Now().Add(webhookRetryDelay)
+		s.groupWebhookDao.MarkDeliveryFailed(delivery.ID, nil, err.Error(), &retryAt)
+		return
+	}
+	if webhook == nil {
+		return
+	}
+	if !webhook.Enabled {
+		s.groupWebhookDao.MarkDeliveryFailed(delivery.ID, nil, "webhook is disabled", nil)
+		return
+	}
+
+	status, retryAfter, err := s.post(*webhook, delivery)
+	if err == nil {
+		s.groupWebhookDao.MarkDeliveryDelivered(delivery.ID, status)
+		return
+	}
+
+	s.l.Printf("Failed to deliver webhook %d delivery %d (attempt %d): %v", webhook.ID, delivery.ID, delivery.Attempts, err)
+	var responseStatus *int
+	if status != 0 {
+		responseStatus = &status
+	}
+	var retryAt *time.Time
+	retryable := status == 0 || status >= 500 || status == http.StatusRequestTimeout || status == http.StatusTooManyRequests
+	if retryable && delivery.Attempts < maxWebhookAttempts {
+		delay := webhookRetryDelay * time.Duration(math.Pow(2, float64(delivery.Attempts-1)))
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		at := time.Now().Add(delay)
+		retryAt = &at
+	}
+	s.groupWebhookDao.MarkDeliveryFailed(delivery.ID, responseStatus, err.Error(), retryAt)
+}
+
+// post sends the delivery, signed with the webhook's secret. It returns the
+// response status, 0 if there was no response, and how long a 429 asked us
+// to wait.
+func (s *GroupWebhookService) post(webhook models.GroupWebhook, delivery models.GroupWebhookDelivery) (int, time.Duration, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "RunGoals-Webhooks/1.0")
+	req.Header.Set("X-RunGoals-Event", string(delivery.Event))
+	req.Header.Set("X-RunGoals-Delivery", strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set("X-RunGoals-Timestamp", timestamp)
+	req.Header.Set("X-RunGoals-Signature", "sha256="+signWebhookBody(webhook.Secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseSnippet))
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, 0, nil
+	}
+	var retryAfter time.Duration
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		retryAfter = min(time.Duration(seconds)*time.Second, maxWebhookRetryAfter)
+	}
+	message := fmt.Sprintf("HTTP %d", resp.StatusCode)
+	if text := strings.TrimSpace(string(snippet)); text != "" {
+		message += ": " + text
+	}
+	return resp.StatusCode, retryAfter, errors.New(message)
+}
+
+// signWebhookBody is the hex HMAC-SHA256 of "timestamp.body". Receivers
+// recompute it with their secret and reject stale timestamps to stop
+// replays.
+func signWebhookBody(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// newWebhookClient returns a client that won't connect to private, loopback
+// or link-local addresses, so a webhook can't be pointed at our own network.
+// Redirects aren't followed for the same reason.
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: webhookTimeout,
+		Control: func(network string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+				ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+				return fmt.Errorf("webhook address %s is not allowed", host)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: webhookTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: webhookTimeout,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// notify wakes the worker, unless it's already due to look
+func (s *GroupWebhookService) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func generateWebhookSecret() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+func isGroupWebhookEvent(event models.GroupWebhookEvent) bool {
+	for _, known := range models.GroupWebhookEvents {
+		if event == known {
+			return true
+		}
+	}
+	return false
+}
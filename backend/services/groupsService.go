@@ -32,17 +32,23 @@ type GroupServiceInterface interface {
 }
 
 type GroupsService struct {
-	l         *log.Logger
-	groupsDao *daos.GroupsDao
+	l                   *log.Logger
+	groupsDao           *daos.GroupsDao
+	notificationService *NotificationService
+	liveService         *LiveService
 }
 
 func NewGroupsService(
 	l *log.Logger,
 	groupsDao *daos.GroupsDao,
+	notificationService *NotificationService,
+	liveService *LiveService,
 ) *GroupsService {
 	return &GroupsService{
-		l:         l,
-		groupsDao: groupsDao,
+		l:                   l,
+		groupsDao:           groupsDao,
+		notificationService: notificationService,
+		liveService:         liveService,
 	}
 }
 
@@ -115,6 +121,16 @@ func (s *GroupsService) CreateGroupMember(groupCode string, userID int64, role s
 		s.l.Printf("Error calling groupsDao.CreateMember: %v", err)
 		return err
 	}
+	s.liveService.PublishGroup(*id, models.LiveEventParticipants)
+
+	group, err := s.groupsDao.GetGroupByID(*id)
+	if err != nil {
+		s.l.Printf("Error calling groupsDao.GetGroupByID: %v", err)
+		return nil
+	}
+	if err := s.notificationService.NotifyGroupAdded(userID, *group); err != nil {
+		s.l.Printf("Failed to notify user %d of joining group %d: %v", userID, *id, err)
+	}
 	return nil
 }
 
@@ -133,6 +149,7 @@ func (s *GroupsService) DeleteGroupMember(userID int64, groupID int64) error {
 		s.l.Printf("Error calling groupsDao.DeleteGroupMember: %v", err)
 		return err
 	}
+	s.liveService.PublishGroup(groupID, models.LiveEventParticipants)
 	return nil
 }
 
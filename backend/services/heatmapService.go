@@ -0,0 +1,392 @@
+package services
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"run-goals/daos"
+	"run-goals/geo"
+	"run-goals/heatmap"
+	"run-goals/models"
+	"run-goals/mvt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var ErrHeatmapDateRangeInvalid = errors.New("start_date must be before end_date")
+
+const (
+	heatmapTileSize = 256
+
+	// heatmapCacheBytes bounds the rendered tiles kept in memory. The least
+	// recently used tiles are dropped first.
+	heatmapCacheBytes = 64 << 20
+
+	// Users' own heatmaps are pre-rendered up to this zoom whenever their
+	// routes change. Deeper tiles are rendered when first requested.
+	heatmapPrerenderMaxZoom = 10
+
+	// heatmapPrerenderMaxTiles caps pre-rendering per user, lowest zooms first,
+	// so someone who has been everywhere doesn't hold up everyone else
+	heatmapPrerenderMaxTiles = 500
+)
+
+type heatmapCacheEntry struct {
+	key string
+	png []byte
+}
+
+type HeatmapService struct {
+	l              *log.Logger
+	activityDao    *daos.ActivityDao
+	groupsDao      *daos.GroupsDao
+	mapService     *MapService
+	privacyService *PrivacyService
+
+	mu sync.Mutex
+	// fingerprints is the last seen fingerprint of each user's routes, used
+	// to notice new activities
+	fingerprints map[int64]string
+	// prerendered is the fingerprint each user's tiles were last pre-rendered at
+	prerendered map[int64]string
+	cache       map[string]*list.Element
+	lru         *list.List // most recently used at the front
+	cacheBytes  int
+}
+
+func NewHeatmapService(
+	l *log.Logger,
+	activityDao *daos.ActivityDao,
+	groupsDao *daos.GroupsDao,
+	mapService *MapService,
+	privacyService *PrivacyService,
+) *HeatmapService {
+	return &HeatmapService{
+		l:              l,
+		activityDao:    activityDao,
+		groupsDao:      groupsDao,
+		mapService:     mapService,
+		privacyService: privacyService,
+		fingerprints:   map[int64]string{},
+		prerendered:    map[int64]string{},
+		cache:          map[string]*list.Element{},
+		lru:            list.New(),
+	}
+}
+
+// Tile returns a PNG heatmap tile of the routes the filter selects. Tiles are
+// cached under the fingerprints of the routes they were drawn from, so new
+// activities are picked up on the next request.
+func (s *HeatmapService) Tile(filter models.HeatmapFilter, z int, x int, y int) ([]byte, error) {
+	if !mvt.ValidTile(z, x, y) {
+		return nil, ErrMapTileInvalid
+	}
+	if filter.StartDate != nil && filter.EndDate != nil && !filter.StartDate.Before(*filter.EndDate) {
+		return nil, ErrHeatmapDateRangeInvalid
+	}
+
+	userIDs, err := s.scopeUserIDs(filter)
+	if err != nil {
+		return nil, err
+	}
+	fingerprints, err := s.activityDao.GetRouteFingerprints(userIDs)
+	if err != nil {
+		return nil, err
+	}
+	s.noticeChanges(fingerprints)
+
+	// Other users' routes are trimmed to their privacy zones
+	others := []int64{}
+	for userID := range fingerprints {
+		if userID != filter.UserID {
+			others = append(others, userID)
+		}
+	}
+	zones, err := s.privacyService.ZonesByUser(others)
+	if err != nil {
+		return nil, err
+	}
+	return s.tile(filter, fingerprints, zones, z, x, y)
+}
+
+func (s *HeatmapService) tile(filter models.HeatmapFilter, fingerprints map[int64]string, zones map[int64][]models.PrivacyZone, z int, x int, y int) ([]byte, error) {
+	key := heatmapCacheKey(filter, fingerprints, zones, z, x, y)
+	if png, ok := s.cached(key); ok {
+		return png, nil
+	}
+
+	png, err := s.render(filter, fingerprints, zones, z, x, y)
+	if err != nil {
+		return nil, err
+	}
+	s.store(key, png)
+	return png, nil
+}
+
+// scopeUserIDs returns whose routes the filter covers, checking the user
+// belongs to the group when one is given. Members who opted out of group
+// feeds are left out.
+func (s *HeatmapService) scopeUserIDs(filter models.HeatmapFilter) ([]int64, error) {
+	if filter.GroupID == nil {
+		return []int64{filter.UserID}, nil
+	}
+
+	members, err := s.groupsDao.GetGroupMembers(*filter.GroupID)
+	if err != nil {
+		return nil, err
+	}
+	userIDs := make([]int64, 0, len(members))
+	isMember := false
+	for _, member := range members {
+		userIDs = append(userIDs, member.UserID)
+		if member.UserID == filter.UserID {
+			isMember = true
+		}
+	}
+	if !isMember {
+		return nil, ErrNotGroupMember
+	}
+	return s.privacyService.GroupFeedUserIDs(filter.UserID, userIDs)
+}
+
+// noticeChanges drops cached routes for users whose fingerprint changed, or
+// who haven't been seen before and may have routes cached by the map. Their
+// old tiles are left to age out of the cache, as no key matches them.
+func (s *HeatmapService) noticeChanges(fingerprints map[int64]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for userID, fingerprint := range fingerprints {
+		if previous, ok := s.fingerprints[userID]; !ok || previous != fingerprint {
+			s.mapService.InvalidateRoutes(userID)
+		}
+		s.fingerprints[userID] = fingerprint
+	}
+}
+
+func (s *HeatmapService) render(filter models.HeatmapFilter, fingerprints map[int64]string, zones map[int64][]models.PrivacyZone, z int, x int, y int) ([]byte, error) {
+	minLat, maxLat, minLon, maxLon := mvt.Bounds(z, x, y)
+	// A pixel either side, so lines crossing the edge join up with the next tile
+	padLat := (maxLat - minLat) / heatmapTileSize
+	padLon := (maxLon - minLon) / heatmapTileSize
+	bounds := models.BoundingBox{
+		MinLat: minLat - padLat,
+		MaxLat: maxLat + padLat,
+		MinLon: minLon - padLon,
+		MaxLon: maxLon + padLon,
+	}
+
+	lineWidth := 1
+	if z >= 14 {
+		lineWidth = 2
+	}
+	canvas := heatmap.NewCanvas(heatmapTileSize, lineWidth)
+	// Half a pixel, in degrees of longitude
+	tolerance := degreesPerPixel(z) / 2
+	pixelsPerUnit := float64(heatmapTileSize) / mvt.Extent
+	bufferUnits := float64(mvt.Extent) / heatmapTileSize * 2
+
+	userIDs := make([]int64, 0, len(fingerprints))
+	for userID := range fingerprints {
+		userIDs = append(userIDs, userID)
+	}
+	sort.Slice(userIDs, func(i, j int) bool { return userIDs[i] < userIDs[j] })
+
+	for _, userID := range userIDs {
+		routes, err := s.mapService.getRoutes(userID)
+		if err != nil {
+			return nil, err
+		}
+		own := userID == filter.UserID
+		for _, route := range routes {
+			if !route.bounds.Intersects(bounds) || !matchesHeatmapFilter(route.activity, filter) {
+				continue
+			}
+			pieces := [][][]float64{route.coords}
+			if !own {
+				if route.activity.IsHiddenFromOthers() {
+					continue
+				}
+				pieces = TrimCoords(route.coords, zones[userID])
+			}
+
+			lines := [][][2]float64{}
+			for _, piece := range pieces {
+				simplified := geo.SimplifyCoords(piece, tolerance)
+				points := make([][2]float64, len(simplified))
+				for i, c := range simplified {
+					px, py := mvt.Project(z, x, y, c[0], c[1])
+					points[i] = [2]float64{px, py}
+				}
+				for _, line := range mvt.ClipLine(points, bufferUnits) {
+					pixels := make([][2]float64, len(line))
+					for j, p := range line {
+						pixels[j] = [2]float64{float64(p[0]) * pixelsPerUnit, float64(p[1]) * pixelsPerUnit}
+					}
+					lines = append(lines, pixels)
+				}
+			}
+			canvas.AddRoute(lines)
+		}
+	}
+	return canvas.PNG()
+}
+
+func matchesHeatmapFilter(activity models.Activity, filter models.HeatmapFilter) bool {
+	if filter.StartDate != nil && activity.StartDate.Before(*filter.StartDate) {
+		return false
+	}
+	if filter.EndDate != nil && !activity.StartDate.Before(*filter.EndDate) {
+		return false
+	}
+	if len(filter.Types) == 0 {
+		return true
+	}
+	for _, t := range filter.Types {
+		if strings.EqualFold(t, activity.Type) || strings.EqualFold(t, activity.SportType) {
+			return true
+		}
+	}
+	return false
+}
+
+func heatmapCacheKey(filter models.HeatmapFilter, fingerprints map[int64]string, zones map[int64][]models.PrivacyZone, z int, x int, y int) string {
+	var b strings.Builder
+	if filter.GroupID != nil {
+		// The viewer's own routes are drawn untrimmed, so group tiles differ
+		// per viewer
+		fmt.Fprintf(&b, "group:%d|viewer:%d|", *filter.GroupID, filter.UserID)
+	} else {
+		fmt.Fprintf(&b, "user:%d|", filter.UserID)
+	}
+	if filter.StartDate != nil {
+		fmt.Fprintf(&b, "from:%d|", filter.StartDate.Unix())
+	}
+	if filter.EndDate != nil {
+		fmt.Fprintf(&b, "to:%d|", filter.EndDate.Unix())
+	}
+	types := make([]string, len(filter.Types))
+	for i, t := range filter.Types {
+		types[i] = strings.ToLower(t)
+	}
+	sort.Strings(types)
+	fmt.Fprintf(&b, "types:%s|", strings.Join(types, ","))
+
+	userIDs := make([]int64, 0, len(fingerprints))
+	for userID := range fingerprints {
+		userIDs = append(userIDs, userID)
+	}
+	sort.Slice(userIDs, func(i, j int) bool { return userIDs[i] < userIDs[j] })
+	for _, userID := range userIDs {
+		fmt.Fprintf(&b, "%d=%s", userID, fingerprints[userID])
+		for _, zone := range zones[userID] {
+			fmt.Fprintf(&b, ";%d:%f:%f:%f", zone.ID, zone.TrimLatitude, zone.TrimLongitude, zone.TrimRadiusMeters)
+		}
+		b.WriteString(",")
+	}
+	fmt.Fprintf(&b, "|%d/%d/%d", z, x, y)
+
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// ==================== Tile cache ====================
+
+func (s *HeatmapService) cached(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	element, ok := s.cache[key]
+	if !ok {
+		return nil, false
+	}
+	s.lru.MoveToFront(element)
+	return element.Value.(*heatmapCacheEntry).png, true
+}
+
+func (s *HeatmapService) store(key string, png []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.cache[key]; ok {
+		return
+	}
+	s.cache[key] = s.lru.PushFront(&heatmapCacheEntry{key: key, png: png})
+	s.cacheBytes += len(png)
+
+	for s.cacheBytes > heatmapCacheBytes && s.lru.Len() > 1 {
+		oldest := s.lru.Back()
+		entry := oldest.Value.(*heatmapCacheEntry)
+		s.lru.Remove(oldest)
+		delete(s.cache, entry.key)
+		s.cacheBytes -= len(entry.png)
+	}
+}
+
+// ==================== Pre-rendering ====================
+
+// PrerenderChanged renders the low zoom tiles of every user's own heatmap
+// whose routes changed since it last ran
+func (s *HeatmapService) PrerenderChanged() error {
+	fingerprints, err := s.activityDao.GetRouteFingerprints(nil)
+	if err != nil {
+		return err
+	}
+	s.noticeChanges(fingerprints)
+
+	for userID, fingerprint := range fingerprints {
+		s.mu.Lock()
+		done := s.prerendered[userID] == fingerprint
+		s.mu.Unlock()
+		if done {
+			continue
+		}
+
+		rendered, err := s.prerender(userID, fingerprint)
+		if err != nil {
+			s.l.Printf("Failed to pre-render heatmap for user %d: %v", userID, err)
+			continue
+		}
+		s.l.Printf("Pre-rendered %d heatmap tiles for user %d", rendered, userID)
+
+		s.mu.Lock()
+		s.prerendered[userID] = fingerprint
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+func (s *HeatmapService) prerender(userID int64, fingerprint string) (int, error) {
+	routes, err := s.mapService.getRoutes(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	filter := models.HeatmapFilter{UserID: userID}
+	fingerprints := map[int64]string{userID: fingerprint}
+	zones := map[int64][]models.PrivacyZone{}
+	rendered := 0
+	for z := 0; z <= heatmapPrerenderMaxZoom; z++ {
+		tiles := map[[2]int]bool{}
+		for _, route := range routes {
+			minX, maxY := mvt.TileAt(z, route.bounds.MinLat, route.bounds.MinLon)
+			maxX, minY := mvt.TileAt(z, route.bounds.MaxLat, route.bounds.MaxLon)
+			for x := minX; x <= maxX; x++ {
+				for y := minY; y <= maxY; y++ {
+					tiles[[2]int{x, y}] = true
+				}
+			}
+		}
+		if rendered+len(tiles) > heatmapPrerenderMaxTiles {
+			break
+		}
+		for tile := range tiles {
+			if _, err := s.tile(filter, fingerprints, zones, z, tile[0], tile[1]); err != nil {
+				return rendered, err
+			}
+			rendered++
+		}
+	}
+	return rendered, nil
+}
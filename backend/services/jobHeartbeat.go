@@ -0,0 +1,31 @@
+package services
+
+import "time"
+
+const (
+	// jobHeartbeatInterval is how often a worker marks the job it's running
+	// as still alive
+	jobHeartbeatInterval = 30 * time.Second
+	// jobStaleAfter is how long a running job can go without a heartbeat
+	// before another worker assumes its replica died and requeues it
+	jobStaleAfter = 5 * time.Minute
+)
+
+// startHeartbeat calls beat every jobHeartbeatInterval until the returned
+// function is called
+func startHeartbeat(beat func()) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(jobHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				beat()
+			}
+		}
+	}()
+	return func() { close(done) }
+}
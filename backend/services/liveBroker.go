@@ -0,0 +1,169 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"run-goals/models"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// liveSubscriberBuffer is how many events a subscriber can fall behind
+	// by. Events past it are dropped, which is fine as each only says
+	// something changed and one is already waiting.
+	liveSubscriberBuffer = 16
+	// liveEventsChannel is the Postgres NOTIFY channel events go through
+	liveEventsChannel = "live_events"
+)
+
+// LiveBroker fans live events out to subscribers by topic
+type LiveBroker interface {
+	Publish(event models.LiveEvent) error
+	// Subscribe returns the topic's events and a function that stops them.
+	// The channel is closed when the subscription stops or the broker
+	// closes.
+	Subscribe(topic string) (<-chan models.LiveEvent, func())
+	Close()
+}
+
+// MemoryLiveBroker only reaches subscribers in this process, so it's for a
+// single replica
+type MemoryLiveBroker struct {
+	l           *log.Logger
+	mu          sync.Mutex
+	subscribers map[string]map[chan models.LiveEvent]struct{}
+	closed      bool
+}
+
+func NewMemoryLiveBroker(l *log.Logger) *MemoryLiveBroker {
+	return &MemoryLiveBroker{
+		l:           l,
+		subscribers: map[string]map[chan models.LiveEvent]struct{}{},
+	}
+}
+
+func (b *MemoryLiveBroker) Publish(event models.LiveEvent) error {
+	b.deliver(event)
+	return nil
+}
+
+// deliver hands the event to the topic's subscribers without waiting on
+// any of them
+func (b *MemoryLiveBroker) deliver(event models.LiveEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers[event.Topic()] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+func (b *MemoryLiveBroker) Subscribe(topic string) (<-chan models.LiveEvent, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan models.LiveEvent, liveSubscriberBuffer)
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if b.subscribers[topic] == nil {
+		b.subscribers[topic] = map[chan models.LiveEvent]struct{}{}
+	}
+	b.subscribers[topic][ch] = struct{}{}
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[topic][ch]; !ok {
+			return
+		}
+		delete(b.subscribers[topic], ch)
+		if len(b.subscribers[topic]) == 0 {
+			delete(b.subscribers, topic)
+		}
+		close(ch)
+	}
+	return ch, unsubscribe
+}
+
+// Close ends every subscription, e.g. so open streams finish on shutdown
+func (b *MemoryLiveBroker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for topic, channels := range b.subscribers {
+		for ch := range channels {
+			close(ch)
+		}
+		delete(b.subscribers, topic)
+	}
+}
+
+// PostgresLiveBroker publishes with NOTIFY and delivers what it hears with
+// LISTEN, so every replica's subscribers get events published on any of
+// them
+type PostgresLiveBroker struct {
+	*MemoryLiveBroker
+	db       *sql.DB
+	listener *pq.Listener
+}
+
+// NewPostgresLiveBroker listens on its own connection, which reconnects by
+// itself. Events published while it's down are missed.
+func NewPostgresLiveBroker(l *log.Logger, db *sql.DB, connString string) (*PostgresLiveBroker, error) {
+	listener := pq.NewListener(connString, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			l.Printf("Live events listener: %v", err)
+		}
+	})
+	if err := listener.Listen(liveEventsChannel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	b := &PostgresLiveBroker{
+		MemoryLiveBroker: NewMemoryLiveBroker(l),
+		db:               db,
+		listener:         listener,
+	}
+	go b.listen()
+	return b, nil
+}
+
+func (b *PostgresLiveBroker) Publish(event models.LiveEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = b.db.Exec(`SELECT pg_notify($1, $2)`, liveEventsChannel, string(payload))
+	return err
+}
+
+func (b *PostgresLiveBroker) listen() {
+	for notification := range b.listener.Notify {
+		// nil after a reconnect
+		if notification == nil {
+			continue
+		}
+		var event models.LiveEvent
+		if err := json.Unmarshal([]byte(notification.Extra), &event); err != nil {
+			b.l.Printf("Failed to decode live event: %v", err)
+			continue
+		}
+		b.deliver(event)
+	}
+}
+
+func (b *PostgresLiveBroker) Close() {
+	b.listener.Close()
+	b.MemoryLiveBroker.Close()
+}
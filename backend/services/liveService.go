@@ -0,0 +1,106 @@
+package services
+
+import (
+	"log"
+	"run-goals/daos"
+	"run-goals/models"
+)
+
+// LiveService publishes changes to challenge and group pages and lets their
+// viewers subscribe to them
+type LiveService struct {
+	l            *log.Logger
+	broker       LiveBroker
+	challengeDao *daos.ChallengeDao
+	groupsDao    *daos.GroupsDao
+}
+
+func NewLiveService(
+	l *log.Logger,
+	broker LiveBroker,
+	challengeDao *daos.ChallengeDao,
+	groupsDao *daos.GroupsDao,
+) *LiveService {
+	return &LiveService{
+		l:            l,
+		broker:       broker,
+		challengeDao: challengeDao,
+		groupsDao:    groupsDao,
+	}
+}
+
+// ==================== Publishing ====================
+
+func (s *LiveService) PublishChallenge(challengeID int64, eventType models.LiveEventType) {
+	s.publish(models.LiveEvent{Type: eventType, ChallengeID: &challengeID})
+}
+
+func (s *LiveService) PublishGroup(groupID int64, eventType models.LiveEventType) {
+	s.publish(models.LiveEvent{Type: eventType, GroupID: &groupID})
+}
+
+// PublishMemberGroups publishes to every group the user is a member of
+func (s *LiveService) PublishMemberGroups(userID int64, eventType models.LiveEventType) {
+	groups, err := s.groupsDao.GetUserGroups(userID)
+	if err != nil {
+		s.l.Printf("Failed to get groups of user %d for live events: %v", userID, err)
+		return
+	}
+	for _, group := range groups {
+		s.PublishGroup(group.ID, eventType)
+	}
+}
+
+// publish is best effort, pages still load without live updates
+func (s *LiveService) publish(event models.LiveEvent) {
+	if err := s.broker.Publish(event); err != nil {
+		s.l.Printf("Failed to publish live event %s to %s: %v", event.Type, event.Topic(), err)
+	}
+}
+
+// ==================== Subscribing ====================
+
+// SubscribeChallenge returns the challenge's events. Anyone can follow a
+// challenge they can look up, as with its leaderboard.
+func (s *LiveService) SubscribeChallenge(challengeID int64) (<-chan models.LiveEvent, func(), error) {
+	challenge, err := s.challengeDao.GetChallengeByID(challengeID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if challenge == nil {
+		return nil, nil, ErrChallengeNotFound
+	}
+	events, unsubscribe := s.broker.Subscribe(models.ChallengeTopic(challengeID))
+	return events, unsubscribe, nil
+}
+
+// SubscribeGroup returns the group's events if the user is a member
+func (s *LiveService) SubscribeGroup(userID int64, groupID int64) (<-chan models.LiveEvent, func(), error) {
+	role, err := s.groupsDao.GetGroupMemberRole(groupID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if role == nil {
+		return nil, nil, ErrNotGroupMember
+	}
+	events, unsubscribe := s.broker.Subscribe(models.GroupTopic(groupID))
+	return events, unsubscribe, nil
+}
+
+// Update builds the event as the viewer should see it
+func (s *LiveService) Update(viewerID int64, event models.LiveEvent) (*models.LiveUpdate, error) {
+	update := &models.LiveUpdate{LiveEvent: event}
+	if event.ChallengeID != nil && event.Type != models.LiveEventSummit {
+		leaderboard, err := s.challengeDao.GetChallengeLeaderboard(*event.ChallengeID, viewerID)
+		if err != nil {
+			return nil, err
+		}
+		update.Leaderboard = leaderboard
+	}
+	return update, nil
+}
+
+// Close ends every subscription so open streams finish
+func (s *LiveService) Close() {
+	s.broker.Close()
+}
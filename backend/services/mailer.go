@@ -0,0 +1,86 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"run-goals/config"
+	"time"
+)
+
+const defaultSMTPPort = "587"
+
+// smtpMailer sends multipart text and HTML emails through the configured
+// SMTP server
+type smtpMailer struct {
+	config config.Email
+}
+
+func (m smtpMailer) send(to string, subject string, textBody string, htmlBody string) error {
+	from, err := mail.ParseAddress(m.config.From)
+	if err != nil {
+		return fmt.Errorf("invalid EMAIL_FROM: %w", err)
+	}
+
+	message, err := buildMessage(from.String(), to, subject, textBody, htmlBody)
+	if err != nil {
+		return err
+	}
+
+	port := m.config.SMTPPort
+	if port == "" {
+		port = defaultSMTPPort
+	}
+	// Local catchers take mail without logging in
+	var auth smtp.Auth
+	if m.config.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", m.config.SMTPUsername, m.config.SMTPPassword, m.config.SMTPHost)
+	}
+	return smtp.SendMail(net.JoinHostPort(m.config.SMTPHost, port), auth, from.Address, []string{to}, message)
+}
+
+// buildMessage writes a multipart/alternative message, so clients without
+// HTML show the text part
+func buildMessage(from string, to string, subject string, textBody string, htmlBody string) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	parts := []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", textBody},
+		{"text/html; charset=utf-8", htmlBody},
+	}
+	for _, p := range parts {
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(part)
+		if _, err := qp.Write([]byte(p.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", from)
+	fmt.Fprintf(&message, "To: %s\r\n", to)
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", writer.Boundary())
+	message.Write(body.Bytes())
+	return message.Bytes(), nil
+}
@@ -0,0 +1,350 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"math"
+	"run-goals/daos"
+	"run-goals/geo"
+	"run-goals/models"
+	"run-goals/mvt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/twpayne/go-polyline"
+)
+
+var (
+	ErrMapBoundsInvalid = errors.New("bbox must be minLon,minLat,maxLon,maxLat")
+	ErrMapZoomInvalid   = errors.New("zoom must be between 0 and 22")
+	ErrMapTileInvalid   = errors.New("tile does not exist")
+)
+
+const (
+	// Decoded routes are cached per user for this long, so panning the map
+	// doesn't decode every polyline again for each tile. A new activity can
+	// take up to this long to show up.
+	mapRouteCacheTTL = 5 * time.Minute
+
+	// defaultMapZoom is used for GeoJSON requests that don't give a zoom
+	defaultMapZoom = 10
+
+	// tileBufferUnits lets features just outside a tile be drawn by it, so
+	// peak icons and lines aren't cut at tile edges
+	tileBufferUnits = 64
+
+	// Tile layer names
+	layerPeaksSummited   = "peaks_summited"
+	layerPeaksUnsummited = "peaks_unsummited"
+	layerRoutes          = "routes"
+)
+
+// mapPeakLimit caps how many peaks a request returns. Zoomed out, only the
+// highest peaks are kept so the map stays readable and responses stay small.
+func mapPeakLimit(zoom int) int {
+	switch {
+	case zoom < 6:
+		return 100
+	case zoom < 9:
+		return 300
+	case zoom < 12:
+		return 1000
+	default:
+		return 5000
+	}
+}
+
+// degreesPerPixel is how many degrees of longitude one 256px tile pixel spans
+// at a zoom, used as the tolerance when simplifying routes
+func degreesPerPixel(zoom int) float64 {
+	return 360 / (256 * math.Exp2(float64(zoom)))
+}
+
+type mapRoute struct {
+	activity models.Activity
+	coords   [][]float64
+	bounds   models.BoundingBox
+}
+
+type cachedMapRoutes struct {
+	routes    []mapRoute
+	expiresAt time.Time
+}
+
+type MapService struct {
+	l            *log.Logger
+	peaksDao     *daos.PeaksDao
+	userPeaksDao *daos.UserPeaksDao
+	activityDao  *daos.ActivityDao
+
+	routesMu    sync.Mutex
+	routesCache map[int64]cachedMapRoutes
+}
+
+func NewMapService(
+	l *log.Logger,
+	peaksDao *daos.PeaksDao,
+	userPeaksDao *daos.UserPeaksDao,
+	activityDao *daos.ActivityDao,
+) *MapService {
+	return &MapService{
+		l:            l,
+		peaksDao:     peaksDao,
+		userPeaksDao: userPeaksDao,
+		activityDao:  activityDao,
+		routesCache:  map[int64]cachedMapRoutes{},
+	}
+}
+
+// ParseBoundingBox reads a bbox query value in GeoJSON order:
+// minLon,minLat,maxLon,maxLat
+func ParseBoundingBox(value string) (models.BoundingBox, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return models.BoundingBox{}, ErrMapBoundsInvalid
+	}
+	values := make([]float64, 4)
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || math.IsNaN(v) {
+			return models.BoundingBox{}, ErrMapBoundsInvalid
+		}
+		values[i] = v
+	}
+	bounds := models.BoundingBox{MinLon: values[0], MinLat: values[1], MaxLon: values[2], MaxLat: values[3]}
+	if bounds.MinLon > bounds.MaxLon || bounds.MinLat > bounds.MaxLat ||
+		bounds.MinLat < -90 || bounds.MaxLat > 90 || bounds.MinLon < -180 || bounds.MaxLon > 180 {
+		return models.BoundingBox{}, ErrMapBoundsInvalid
+	}
+	return bounds, nil
+}
+
+// ParseZoom reads an optional zoom query value
+func ParseZoom(value string) (int, error) {
+	if value == "" {
+		return defaultMapZoom, nil
+	}
+	zoom, err := strconv.Atoi(value)
+	if err != nil || zoom < 0 || zoom > 22 {
+		return 0, ErrMapZoomInvalid
+	}
+	return zoom, nil
+}
+
+// ==================== GeoJSON ====================
+
+// PeaksGeoJSON returns the peaks in the box, highest first and capped by zoom,
+// each marked with whether the user has summited it
+func (s *MapService) PeaksGeoJSON(userID int64, bounds models.BoundingBox, zoom int) (*models.GeoJSONFeatureCollection, error) {
+	peaks, err := s.peaksDao.GetMapPeaks(userID, bounds, mapPeakLimit(zoom))
+	if err != nil {
+		return nil, err
+	}
+
+	collection := models.NewGeoJSONFeatureCollection()
+	for _, p := range peaks {
+		collection.Features = append(collection.Features, pointFeature(p.ID, p.Latitude, p.Longitude, map[string]interface{}{
+			"name":             p.Name,
+			"elevation_meters": p.ElevationMeters,
+			"summited":         p.Summited,
+		}))
+	}
+	return &collection, nil
+}
+
+// SummitsGeoJSON returns the peaks in the box the user has summited
+func (s *MapService) SummitsGeoJSON(userID int64, bounds models.BoundingBox) (*models.GeoJSONFeatureCollection, error) {
+	summits, err := s.userPeaksDao.GetUserSummitsInBounds(userID, bounds)
+	if err != nil {
+		return nil, err
+	}
+
+	collection := models.NewGeoJSONFeatureCollection()
+	for _, summit := range summits {
+		collection.Features = append(collection.Features, pointFeature(summit.PeakID, summit.Latitude, summit.Longitude, map[string]interface{}{
+			"name":              summit.Name,
+			"elevation_meters":  summit.ElevationMeters,
+			"summit_count":      summit.SummitCount,
+			"first_summited_at": summit.FirstSummitedAt,
+			"last_summited_at":  summit.LastSummitedAt,
+		}))
+	}
+	return &collection, nil
+}
+
+// RoutesGeoJSON returns the user's routes that cross the box, simplified to
+// roughly one point per pixel at the zoom
+func (s *MapService) RoutesGeoJSON(userID int64, bounds models.BoundingBox, zoom int) (*models.GeoJSONFeatureCollection, error) {
+	routes, err := s.getRoutes(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	collection := models.NewGeoJSONFeatureCollection()
+	tolerance := degreesPerPixel(zoom)
+	for _, route := range routes {
+		if !route.bounds.Intersects(bounds) {
+			continue
+		}
+		simplified := geo.SimplifyCoords(route.coords, tolerance)
+		coordinates := make([][2]float64, len(simplified))
+		for i, c := range simplified {
+			coordinates[i] = [2]float64{c[1], c[0]}
+		}
+		collection.Features = append(collection.Features, models.GeoJSONFeature{
+			Type:       "Feature",
+			ID:         route.activity.ID,
+			Geometry:   models.GeoJSONGeometry{Type: "LineString", Coordinates: coordinates},
+			Properties: routeProperties(route.activity),
+		})
+	}
+	return &collection, nil
+}
+
+func pointFeature(id int64, lat float64, lon float64, properties map[string]interface{}) models.GeoJSONFeature {
+	return models.GeoJSONFeature{
+		Type:       "Feature",
+		ID:         id,
+		Geometry:   models.GeoJSONGeometry{Type: "Point", Coordinates: [2]float64{lon, lat}},
+		Properties: properties,
+	}
+}
+
+func routeProperties(activity models.Activity) map[string]interface{} {
+	return map[string]interface{}{
+		"name":       activity.Name,
+		"type":       activity.Type,
+		"sport_type": activity.SportType,
+		"distance":   activity.Distance,
+		"start_date": activity.StartDate.UTC().Format(time.RFC3339),
+		"has_summit": activity.HasSummit,
+	}
+}
+
+// ==================== Vector tiles ====================
+
+// Tile renders the user's map as a Mapbox Vector Tile, with summited and
+// unsummited peaks in separate layers and their routes in a third
+func (s *MapService) Tile(userID int64, z int, x int, y int) ([]byte, error) {
+	if !mvt.ValidTile(z, x, y) {
+		return nil, ErrMapTileInvalid
+	}
+
+	minLat, maxLat, minLon, maxLon := mvt.Bounds(z, x, y)
+	// Widen by the buffer so features just over the edge are included
+	padLat := (maxLat - minLat) * tileBufferUnits / mvt.Extent
+	padLon := (maxLon - minLon) * tileBufferUnits / mvt.Extent
+	bounds := models.BoundingBox{
+		MinLat: minLat - padLat,
+		MaxLat: maxLat + padLat,
+		MinLon: minLon - padLon,
+		MaxLon: maxLon + padLon,
+	}
+
+	tile := &mvt.Tile{}
+	summited := tile.Layer(layerPeaksSummited)
+	unsummited := tile.Layer(layerPeaksUnsummited)
+	routesLayer := tile.Layer(layerRoutes)
+
+	peaks, err := s.peaksDao.GetMapPeaks(userID, bounds, mapPeakLimit(z))
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range peaks {
+		px, py := mvt.Project(z, x, y, p.Latitude, p.Longitude)
+		layer := unsummited
+		if p.Summited {
+			layer = summited
+		}
+		layer.AddPoint(uint64(p.ID), int(math.Round(px)), int(math.Round(py)), map[string]interface{}{
+			"name":             p.Name,
+			"elevation_meters": p.ElevationMeters,
+		})
+	}
+
+	routes, err := s.getRoutes(userID)
+	if err != nil {
+		return nil, err
+	}
+	// One tile unit, in degrees of longitude
+	tolerance := 360 / (mvt.Extent * math.Exp2(float64(z)))
+	for _, route := range routes {
+		if !route.bounds.Intersects(bounds) {
+			continue
+		}
+		simplified := geo.SimplifyCoords(route.coords, tolerance)
+		points := make([][2]float64, len(simplified))
+		for i, c := range simplified {
+			px, py := mvt.Project(z, x, y, c[0], c[1])
+			points[i] = [2]float64{px, py}
+		}
+		routesLayer.AddLineString(uint64(route.activity.ID), mvt.ClipLine(points, tileBufferUnits), routeProperties(route.activity))
+	}
+
+	return tile.Marshal(), nil
+}
+
+// InvalidateRoutes drops the user's cached routes, so the next request
+// decodes them again
+func (s *MapService) InvalidateRoutes(userID int64) {
+	s.routesMu.Lock()
+	defer s.routesMu.Unlock()
+	delete(s.routesCache, userID)
+}
+
+// getRoutes returns the user's decoded routes, from the cache when it's fresh
+func (s *MapService) getRoutes(userID int64) ([]mapRoute, error) {
+	now := time.Now()
+
+	s.routesMu.Lock()
+	cached, ok := s.routesCache[userID]
+	s.routesMu.Unlock()
+	if ok && now.Before(cached.expiresAt) {
+		return cached.routes, nil
+	}
+
+	activities, err := s.activityDao.GetUserRoutes(userID)
+	if err != nil {
+		return nil, err
+	}
+	routes := make([]mapRoute, 0, len(activities))
+	for _, activity := range activities {
+		coords, _, err := polyline.DecodeCoords([]byte(activity.MapPolyline))
+		if err != nil {
+			s.l.Printf("Skipping route for activity %d, polyline can't be decoded: %v", activity.ID, err)
+			continue
+		}
+		if len(coords) < 2 {
+			continue
+		}
+		route := mapRoute{
+			activity: activity,
+			coords:   coords,
+			bounds:   models.BoundingBox{MinLat: coords[0][0], MaxLat: coords[0][0], MinLon: coords[0][1], MaxLon: coords[0][1]},
+		}
+		for _, c := range coords[1:] {
+			route.bounds.MinLat = math.Min(route.bounds.MinLat, c[0])
+			route.bounds.MaxLat = math.Max(route.bounds.MaxLat, c[0])
+			route.bounds.MinLon = math.Min(route.bounds.MinLon, c[1])
+			route.bounds.MaxLon = math.Max(route.bounds.MaxLon, c[1])
+		}
+		// The polyline isn't needed once decoded
+		route.activity.MapPolyline = ""
+		routes = append(routes, route)
+	}
+
+	s.routesMu.Lock()
+	// Drop expired entries while we hold the lock so the cache doesn't grow
+	// with every user who ever opened the map
+	for id, entry := range s.routesCache {
+		if now.After(entry.expiresAt) {
+			delete(s.routesCache, id)
+		}
+	}
+	s.routesCache[userID] = cachedMapRoutes{routes: routes, expiresAt: now.Add(mapRouteCacheTTL)}
+	s.routesMu.Unlock()
+
+	return routes, nil
+}
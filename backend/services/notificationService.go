@@ -0,0 +1,233 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"run-goals/daos"
+	"run-goals/models"
+	"time"
+)
+
+var (
+	ErrNotificationIDsRequired = errors.New("ids are required")
+	ErrNotificationTypeInvalid = errors.New("unknown notification type")
+)
+
+const (
+	defaultNotificationPageSize = 50
+	maxNotificationPageSize     = 100
+
+	// Summits older than this aren't notified, so a first sync or full
+	// resync doesn't flood the inbox with old hikes
+	summitNotificationMaxAge = 7 * 24 * time.Hour
+)
+
+type NotificationService struct {
+	l               *log.Logger
+	notificationDao *daos.NotificationDao
+	userDao         *daos.UserDao
+	emailService    *EmailService
+}
+
+func NewNotificationService(
+	l *log.Logger,
+	notificationDao *daos.NotificationDao,
+	userDao *daos.UserDao,
+	emailService *EmailService,
+) *NotificationService {
+	return &NotificationService{
+		l:               l,
+		notificationDao: notificationDao,
+		userDao:         userDao,
+		emailService:    emailService,
+	}
+}
+
+// ==================== Events ====================
+
+// Notify adds the notification to the user's inbox unless they've turned its
+// type off, and emails it if it's an alert they've opted in to. The inbox
+// and email preferences are separate.
+func (s *NotificationService) Notify(notification models.Notification) error {
+	preferences, err := s.userDao.GetNotificationPreferences(notification.UserID)
+	if err != nil {
+		return err
+	}
+	if preferences.Enabled(notification.Type) {
+		created, err := s.notificationDao.CreateNotification(notification)
+		if err != nil {
+			return err
+		}
+		if created {
+			s.l.Printf("Notified user %d: %s", notification.UserID, notification.Type)
+		}
+	}
+
+	if s.emailService != nil {
+		return s.emailService.QueueAlert(notification)
+	}
+	return nil
+}
+
+// NotifySummitDetected tells the user a summit was found on their activity.
+// Each summit is only notified once, however often it's detected.
+func (s *NotificationService) NotifySummitDetected(userID int64, peakID int64, peakName string, activityID int64, summitedAt time.Time) error {
+	if time.Since(summitedAt) > summitNotificationMaxAge {
+		return nil
+	}
+	if peakName == "" {
+		peakName = "an unnamed peak"
+	}
+	return s.Notify(models.Notification{
+		UserID:     userID,
+		Type:       models.NotificationTypeSummitDetected,
+		Title:      "Summit detected: " + peakName,
+		Body:       fmt.Sprintf("You summited %s on %s.", peakName, summitedAt.Format("2 January 2006")),
+		PeakID:     &peakID,
+		ActivityID: &activityID,
+		DedupeKey:  fmt.Sprintf("summit:%d:%d", activityID, peakID),
+	})
+}
+
+// NotifyChallengeEnding reminds the user a challenge they haven't
+// completed ends soon
+func (s *NotificationService) NotifyChallengeEnding(userID int64, challenge models.Challenge) error {
+	body := fmt.Sprintf("%s ends soon and you haven't completed it yet.", challenge.Name)
+	if challenge.Deadline != nil {
+		body = fmt.Sprintf("%s ends on %s and you haven't completed it yet.", challenge.Name, challenge.Deadline.Format("Mon 2 January"))
+	}
+	return s.Notify(models.Notification{
+		UserID:      userID,
+		Type:        models.NotificationTypeChallengeEnding,
+		Title:       "Ending soon: " + challenge.Name,
+		Body:        body,
+		ChallengeID: &challenge.ID,
+		DedupeKey:   fmt.Sprintf("challenge_ending:%d", challenge.ID),
+	})
+}
+
+func (s *NotificationService) NotifyChallengeCompleted(userID int64, challenge models.Challenge) error {
+	return s.Notify(models.Notification{
+		UserID:      userID,
+		Type:        models.NotificationTypeChallengeCompleted,
+		Title:       "Challenge completed: " + challenge.Name,
+		Body:        fmt.Sprintf("You completed %s.", challenge.Name),
+		ChallengeID: &challenge.ID,
+		DedupeKey:   fmt.Sprintf("challenge_completed:%d", challenge.ID),
+	})
+}
+
+// NotifyOvertaken tells the user someone moved ahead of them on a challenge
+// leaderboard. overtakerName is empty when the overtaker hides their name
+// from leaderboards.
+func (s *NotificationService) NotifyOvertaken(userID int64, challenge models.Challenge, overtakerName string) error {
+	if overtakerName == "" {
+		overtakerName = "Another participant"
+	}
+	return s.Notify(models.Notification{
+		UserID:      userID,
+		Type:        models.NotificationTypeChallengeOvertaken,
+		Title:       "You've been overtaken in " + challenge.Name,
+		Body:        fmt.Sprintf("%s moved ahead of you on the %s leaderboard.", overtakerName, challenge.Name),
+		ChallengeID: &challenge.ID,
+	})
+}
+
+func (s *NotificationService) NotifyGroupAdded(userID int64, group models.Group) error {
+	return s.Notify(models.Notification{
+		UserID:    userID,
+		Type:      models.NotificationTypeGroupAdded,
+		Title:     "Added to " + group.Name,
+		Body:      fmt.Sprintf("You're now a member of %s.", group.Name),
+		GroupID:   &group.ID,
+		DedupeKey: fmt.Sprintf("group_added:%d", group.ID),
+	})
+}
+
+// ==================== Inbox ====================
+
+// GetNotifications returns a page of the user's inbox, newest first
+func (s *NotificationService) GetNotifications(userID int64, unreadOnly bool, before *int64, limit int) (*models.NotificationPage, error) {
+	if limit <= 0 || limit > maxNotificationPageSize {
+		limit = defaultNotificationPageSize
+	}
+
+	// One extra row tells us whether there's another page
+	notifications, err := s.notificationDao.GetNotifications(userID, unreadOnly, before, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	unread, err := s.notificationDao.CountUnread(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.NotificationPage{
+		Notifications: notifications,
+		UnreadCount:   unread,
+		Limit:         limit,
+	}
+	if len(notifications) > limit {
+		page.Notifications = notifications[:limit]
+		next := page.Notifications[limit-1].ID
+		page.NextBefore = &next
+	}
+	return page, nil
+}
+
+func (s *NotificationService) MarkRead(userID int64, ids []int64) (int64, error) {
+	if len(ids) == 0 {
+		return 0, ErrNotificationIDsRequired
+	}
+	return s.notificationDao.MarkRead(userID, ids)
+}
+
+func (s *NotificationService) MarkAllRead(userID int64) (int64, error) {
+	return s.notificationDao.MarkAllRead(userID)
+}
+
+// ==================== Preferences ====================
+
+// GetPreferences returns whether each notification type is on for the user
+func (s *NotificationService) GetPreferences(userID int64) (models.NotificationPreferences, error) {
+	stored, err := s.userDao.GetNotificationPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+	preferences := models.NotificationPreferences{}
+	for _, t := range models.NotificationTypes {
+		preferences[t] = stored.Enabled(t)
+	}
+	return preferences, nil
+}
+
+// UpdatePreferences changes the types given and leaves the rest as they were
+func (s *NotificationService) UpdatePreferences(userID int64, changes models.NotificationPreferences) (models.NotificationPreferences, error) {
+	for t := range changes {
+		if !isNotificationType(t) {
+			return nil, ErrNotificationTypeInvalid
+		}
+	}
+
+	preferences, err := s.GetPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+	for t, enabled := range changes {
+		preferences[t] = enabled
+	}
+	if err := s.userDao.UpdateNotificationPreferences(userID, preferences); err != nil {
+		return nil, err
+	}
+	return preferences, nil
+}
+
+func isNotificationType(t models.NotificationType) bool {
+	for _, known := range models.NotificationTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
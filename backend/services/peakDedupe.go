@@ -0,0 +1,111 @@
+package services
+
+import (
+	"math"
+	"run-goals/geo"
+	"run-goals/models"
+	"sort"
+)
+
+// defaultDuplicatePeakMeters is how close two peaks must be to be proposed as
+// duplicates when DUPLICATE_PEAK_METERS isn't set
+const defaultDuplicatePeakMeters = 50.0
+
+// clusterDuplicatePeaks groups peaks that are within maxMeters of another peak
+// in the group (single-linkage) and returns a proposal for every group of two
+// or more. Peaks are bucketed into a grid so only nearby pairs are compared.
+func clusterDuplicatePeaks(peaks []models.Peak, maxMeters float64) []models.PeakMergeProposal {
+	cellDegrees := maxMeters / 111320.0
+	type cellKey struct{ lat, lon int }
+	cellOf := func(lat, lon float64) cellKey {
+		return cellKey{int(math.Floor(lat / cellDegrees)), int(math.Floor(lon / cellDegrees))}
+	}
+	cells := map[cellKey][]int{}
+	for i, p := range peaks {
+		key := cellOf(p.Latitude, p.Longitude)
+		cells[key] = append(cells[key], i)
+	}
+
+	sets := newDisjointSet(len(peaks))
+
+	for i, p := range peaks {
+		minLat, maxLat, minLon, maxLon := geo.BoundingBox(p.Latitude, p.Longitude, maxMeters)
+		from, to := cellOf(minLat, minLon), cellOf(maxLat, maxLon)
+		for la := from.lat; la <= to.lat; la++ {
+			for lo := from.lon; lo <= to.lon; lo++ {
+				for _, j := range cells[cellKey{la, lo}] {
+					if j <= i {
+						continue
+					}
+					q := peaks[j]
+					if geo.HaversineMeters(p.Latitude, p.Longitude, q.Latitude, q.Longitude) <= maxMeters {
+						sets.union(i, j)
+					}
+				}
+			}
+		}
+	}
+
+	groups := map[int][]models.Peak{}
+	for i, p := range peaks {
+		root := sets.find(i)
+		groups[root] = append(groups[root], p)
+	}
+
+	proposals := []models.PeakMergeProposal{}
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+		proposals = append(proposals, peakMergeProposalFor(group))
+	}
+	sort.Slice(proposals, func(i, j int) bool {
+		return proposals[i].PeakIDs[0] < proposals[j].PeakIDs[0]
+	})
+	return proposals
+}
+
+func peakMergeProposalFor(group []models.Peak) models.PeakMergeProposal {
+	proposal := models.PeakMergeProposal{
+		CanonicalPeakID: canonicalPeak(group).ID,
+		PeakIDs:         make([]int64, 0, len(group)),
+		Status:          models.PeakMergeStatusPending,
+	}
+	for i, a := range group {
+		proposal.PeakIDs = append(proposal.PeakIDs, a.ID)
+		for _, b := range group[i+1:] {
+			if d := geo.HaversineMeters(a.Latitude, a.Longitude, b.Latitude, b.Longitude); d > proposal.SpanMeters {
+				proposal.SpanMeters = d
+			}
+		}
+	}
+	// Sorted so a re-scan finds the same cluster an admin already rejected
+	sort.Slice(proposal.PeakIDs, func(i, j int) bool { return proposal.PeakIDs[i] < proposal.PeakIDs[j] })
+	proposal.SpanMeters = round2(proposal.SpanMeters)
+	return proposal
+}
+
+// canonicalPeak picks the peak to keep: named over unnamed, then one with a
+// wikidata ID, then the highest, then the oldest
+func canonicalPeak(group []models.Peak) models.Peak {
+	best := group[0]
+	for _, p := range group[1:] {
+		if betterCanonicalPeak(p, best) {
+			best = p
+		}
+	}
+	return best
+}
+
+func betterCanonicalPeak(a models.Peak, b models.Peak) bool {
+	if (a.Name != "") != (b.Name != "") {
+		return a.Name != ""
+	}
+	if (a.Wikidata != "") != (b.Wikidata != "") {
+		return a.Wikidata != ""
+	}
+	if a.ElevationMeters != b.ElevationMeters {
+		return a.ElevationMeters > b.ElevationMeters
+	}
+	return a.ID < b.ID
+}
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"math"
+	"run-goals/daos"
+	"run-goals/models"
+	"strings"
+)
+
+var (
+	ErrPeakListNotFound          = errors.New("peak list not found")
+	ErrNotPeakListOwner          = errors.New("user cannot edit this peak list")
+	ErrPeakListNameRequired      = errors.New("peak list name is required")
+	ErrPeakListVisibilityInvalid = errors.New("peak list visibility must be private or public")
+	ErrPeakListScopeInvalid      = errors.New("scope must be curated, public or mine")
+	ErrPeakListEmpty             = errors.New("peak list has no peaks")
+)
+
+type PeakListServiceInterface interface {
+	GetLists(userID int64, scope string) ([]models.PeakListWithProgress, error)
+	GetList(listID int64, userID int64) (*models.PeakListDetail, error)
+	CreateList(userID int64, list models.PeakList, peakIDs []int64) (*models.PeakList, error)
+	UpdateList(listID int64, userID int64, updates models.PeakList) error
+	DeleteList(listID int64, userID int64) error
+	SetListPeaks(listID int64, userID int64, peakIDs []int64) error
+	CreateChallengeFromList(listID int64, userID int64, challenge models.Challenge) (*models.Challenge, error)
+}
+
+type PeakListService struct {
+	l                *log.Logger
+	peakListDao      *daos.PeakListDao
+	userDao          *daos.UserDao
+	challengeService *ChallengeService
+}
+
+func NewPeakListService(
+	l *log.Logger,
+	peakListDao *daos.PeakListDao,
+	userDao *daos.UserDao,
+	challengeService *ChallengeService,
+) *PeakListService {
+	return &PeakListService{
+		l:                l,
+		peakListDao:      peakListDao,
+		userDao:          userDao,
+		challengeService: challengeService,
+	}
+}
+
+// GetLists returns the curated, public and own lists visible to the user with
+// their progress on each
+func (s *PeakListService) GetLists(userID int64, scope string) ([]models.PeakListWithProgress, error) {
+	switch scope {
+	case "", "curated", "public", "mine":
+	default:
+		return nil, ErrPeakListScopeInvalid
+	}
+
+	lists, err := s.peakListDao.GetListsWithProgress(userID, scope)
+	if err != nil {
+		return nil, err
+	}
+	for i := range lists {
+		finishPeakListProgress(&lists[i].Progress)
+	}
+	return lists, nil
+}
+
+// GetList returns a list with its peaks and the user's progress
+func (s *PeakListService) GetList(listID int64, userID int64) (*models.PeakListDetail, error) {
+	list, err := s.getVisibleList(listID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	peaks, err := s.peakListDao.GetListPeaks(listID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &models.PeakListDetail{
+		PeakList: *list,
+		Peaks:    peaks,
+	}
+	detail.Progress.TotalPeaks = len(peaks)
+	for _, peak := range peaks {
+		if !peak.IsSummited {
+			continue
+		}
+		detail.Progress.PeaksCompleted++
+		if detail.Progress.CompletedAt == nil || peak.FirstSummitedAt.After(*detail.Progress.CompletedAt) {
+			detail.Progress.CompletedAt = peak.FirstSummitedAt
+		}
+	}
+	finishPeakListProgress(&detail.Progress)
+	return detail, nil
+}
+
+// CreateList creates a list owned by the user. Lists made by admins are curated.
+func (s *PeakListService) CreateList(userID int64, list models.PeakList, peakIDs []int64) (*models.PeakList, error) {
+	if err := validatePeakList(&list); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userDao.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	list.IsCurated = user.IsAdmin
+	list.CreatedByUserID = &userID
+
+	id, err := s.peakListDao.CreateList(list)
+	if err != nil {
+		return nil, err
+	}
+	list.ID = *id
+
+	if len(peakIDs) > 0 {
+		if err := s.peakListDao.SetListPeaks(list.ID, peakIDs); err != nil {
+			return nil, err
+		}
+		list.PeakCount = len(peakIDs)
+	}
+	return &list, nil
+}
+
+// UpdateList changes a list's name, description, region and visibility
+func (s *PeakListService) UpdateList(listID int64, userID int64, updates models.PeakList) error {
+	list, err := s.getEditableList(listID, userID)
+	if err != nil {
+		return err
+	}
+	if err := validatePeakList(&updates); err != nil {
+		return err
+	}
+
+	list.Name = updates.Name
+	list.Description = updates.Description
+	list.Region = updates.Region
+	list.Visibility = updates.Visibility
+	return s.peakListDao.UpdateList(*list)
+}
+
+func (s *PeakListService) DeleteList(listID int64, userID int64) error {
+	if _, err := s.getEditableList(listID, userID); err != nil {
+		return err
+	}
+	return s.peakListDao.DeleteList(listID)
+}
+
+// SetListPeaks replaces a list's peaks, keeping the given order
+func (s *PeakListService) SetListPeaks(listID int64, userID int64, peakIDs []int64) error {
+	if _, err := s.getEditableList(listID, userID); err != nil {
+		return err
+	}
+	return s.peakListDao.SetListPeaks(listID, peakIDs)
+}
+
+// CreateChallengeFromList creates a specific_summits challenge with the list's
+// peaks, defaulting its name, description and region to the list's
+func (s *PeakListService) CreateChallengeFromList(listID int64, userID int64, challenge models.Challenge) (*models.Challenge, error) {
+	list, err := s.getVisibleList(listID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	peaks, err := s.peakListDao.GetListPeaks(listID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(peaks) == 0 {
+		return nil, ErrPeakListEmpty
+	}
+	peakIDs := make([]int64, len(peaks))
+	for i, peak := range peaks {
+		peakIDs[i] = peak.ID
+	}
+
+	if challenge.Name == "" {
+		challenge.Name = list.Name
+	}
+	if challenge.Description == nil {
+		challenge.Description = list.Description
+	}
+	if challenge.Region == nil {
+		challenge.Region = list.Region
+	}
+	challenge.ChallengeType = models.ChallengeTypeCustom
+	challenge.GoalType = models.GoalTypeSpecificSummits
+
+	return s.challengeService.CreateChallenge(userID, challenge, peakIDs)
+}
+
+// getVisibleList loads a list the user is allowed to see. Private lists are
+// reported as not found to everyone but their owner.
+func (s *PeakListService) getVisibleList(listID int64, userID int64) (*models.PeakList, error) {
+	list, err := s.peakListDao.GetListByID(listID)
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		return nil, ErrPeakListNotFound
+	}
+	if list.IsCurated || list.Visibility == models.VisibilityPublic {
+		return list, nil
+	}
+	if list.CreatedByUserID == nil || *list.CreatedByUserID != userID {
+		return nil, ErrPeakListNotFound
+	}
+	return list, nil
+}
+
+// getEditableList loads a list the user may change: their own, or any curated
+// list if they are an admin
+func (s *PeakListService) getEditableList(listID int64, userID int64) (*models.PeakList, error) {
+	list, err := s.getVisibleList(listID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if list.CreatedByUserID != nil && *list.CreatedByUserID == userID {
+		return list, nil
+	}
+	if list.IsCurated {
+		user, err := s.userDao.GetUserByID(userID)
+		if err != nil {
+			return nil, err
+		}
+		if user.IsAdmin {
+			return list, nil
+		}
+	}
+	return nil, ErrNotPeakListOwner
+}
+
+func validatePeakList(list *models.PeakList) error {
+	list.Name = strings.TrimSpace(list.Name)
+	if list.Name == "" {
+		return ErrPeakListNameRequired
+	}
+	if list.Visibility == "" {
+		list.Visibility = models.VisibilityPrivate
+	}
+	if list.Visibility != models.VisibilityPrivate && list.Visibility != models.VisibilityPublic {
+		return ErrPeakListVisibilityInvalid
+	}
+	return nil
+}
+
+// finishPeakListProgress fills in the percentage and completion, and only
+// keeps CompletedAt when every peak has been summited
+func finishPeakListProgress(progress *models.PeakListProgress) {
+	if progress.TotalPeaks > 0 {
+		progress.Percent = math.Round(float64(progress.PeaksCompleted)/float64(progress.TotalPeaks)*1000) / 10
+	}
+	progress.IsComplete = progress.TotalPeaks > 0 && progress.PeaksCompleted >= progress.TotalPeaks
+	if !progress.IsComplete {
+		progress.CompletedAt = nil
+	}
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"run-goals/config"
+	"run-goals/daos"
+	"run-goals/models"
+	"strconv"
+)
+
+var (
+	ErrPeakMergeProposalNotFound = errors.New("peak merge proposal not found")
+	ErrPeakMergeProposalResolved = errors.New("peak merge proposal is already resolved")
+	ErrPeakMergeProposalStale    = errors.New("peaks in the proposal have already been merged")
+	ErrPeakMergeCanonicalInvalid = errors.New("canonical peak must be one of the proposal's peaks")
+	ErrPeakMergeStatusInvalid    = errors.New("invalid status")
+	ErrPeakMergeDistanceInvalid  = errors.New("distance must be positive")
+)
+
+type PeakMergeService struct {
+	l                *log.Logger
+	config           *config.Config
+	peakMergeDao     *daos.PeakMergeDao
+	peaksDao         *daos.PeaksDao
+	challengeDao     *daos.ChallengeDao
+	challengeService *ChallengeService
+	peakService      *PeakService
+}
+
+func NewPeakMergeService(
+	l *log.Logger,
+	config *config.Config,
+	peakMergeDao *daos.PeakMergeDao,
+	peaksDao *daos.PeaksDao,
+	challengeDao *daos.ChallengeDao,
+	challengeService *ChallengeService,
+	peakService *PeakService,
+) *PeakMergeService {
+	return &PeakMergeService{
+		l:                l,
+		config:           config,
+		peakMergeDao:     peakMergeDao,
+		peaksDao:         peaksDao,
+		challengeDao:     challengeDao,
+		challengeService: challengeService,
+		peakService:      peakService,
+	}
+}
+
+// DuplicateDistance is the configured duplicate distance in meters
+func (s *PeakMergeService) DuplicateDistance() float64 {
+	meters, err := strconv.ParseFloat(s.config.Summit.DuplicatePeakMeters, 64)
+	if err != nil || meters <= 0 {
+		return defaultDuplicatePeakMeters
+	}
+	return meters
+}
+
+// Scan clusters every active peak within distanceMeters of another and
+// replaces the pending proposals with the result
+func (s *PeakMergeService) Scan(distanceMeters float64) (*models.PeakMergeScanResult, error) {
+	if distanceMeters <= 0 {
+		return nil, ErrPeakMergeDistanceInvalid
+	}
+
+	peaks, err := s.peaksDao.GetPeaks()
+	if err != nil {
+		return nil, err
+	}
+
+	proposals := clusterDuplicatePeaks(peaks, distanceMeters)
+	inserted, err := s.peakMergeDao.ReplacePendingProposals(proposals)
+	if err != nil {
+		return nil, err
+	}
+
+	s.l.Printf("Peak dedupe scan: %d peaks, %d clusters, %d proposals within %.0fm",
+		len(peaks), len(proposals), inserted, distanceMeters)
+
+	return &models.PeakMergeScanResult{
+		DistanceMeters: distanceMeters,
+		PeaksScanned:   len(peaks),
+		Proposals:      inserted,
+	}, nil
+}
+
+// GetProposals returns the proposals with the given status and their peaks
+func (s *PeakMergeService) GetProposals(status models.PeakMergeStatus) ([]models.PeakMergeProposalWithPeaks, error) {
+	switch status {
+	case models.PeakMergeStatusPending, models.PeakMergeStatusMerged, models.PeakMergeStatusRejected:
+	default:
+		return nil, ErrPeakMergeStatusInvalid
+	}
+
+	proposals, err := s.peakMergeDao.GetProposals(status)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []int64{}
+	for _, p := range proposals {
+		ids = append(ids, p.PeakIDs...)
+	}
+	peaks, err := s.peaksDao.GetPeaksByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	peaksByID := map[int64]models.Peak{}
+	for _, p := range peaks {
+		peaksByID[p.ID] = p
+	}
+
+	result := make([]models.PeakMergeProposalWithPeaks, 0, len(proposals))
+	for _, p := range proposals {
+		withPeaks := models.PeakMergeProposalWithPeaks{PeakMergeProposal: p, Peaks: []models.Peak{}}
+		for _, id := range p.PeakIDs {
+			if peak, ok := peaksByID[id]; ok {
+				withPeaks.Peaks = append(withPeaks.Peaks, peak)
+			}
+		}
+		result = append(result, withPeaks)
+	}
+	return result, nil
+}
+
+// ConfirmProposal merges the proposal's peaks into its canonical peak, or into
+// canonicalPeakID when given, and refreshes progress on affected challenges
+func (s *PeakMergeService) ConfirmProposal(proposalID int64, canonicalPeakID *int64) (*models.PeakMergeResult, error) {
+	proposal, err := s.getPendingProposal(proposalID)
+	if err != nil {
+		return nil, err
+	}
+
+	canonicalID := proposal.CanonicalPeakID
+	if canonicalPeakID != nil {
+		canonicalID = *canonicalPeakID
+	}
+
+	peaks, err := s.peaksDao.GetPeaksByIDs(proposal.PeakIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	found := false
+	duplicateIDs := []int64{}
+	for _, p := range peaks {
+		if p.ID == canonicalID {
+			found = true
+			if p.MergedIntoID != nil {
+				return nil, ErrPeakMergeProposalStale
+			}
+			continue
+		}
+		// Already folded into the canonical peak by an earlier merge
+		if p.MergedIntoID != nil && *p.MergedIntoID == canonicalID {
+			continue
+		}
+		duplicateIDs = append(duplicateIDs, p.ID)
+	}
+	if !found {
+		return nil, ErrPeakMergeCanonicalInvalid
+	}
+
+	challengeIDs, err := s.peakMergeDao.MergePeaks(proposal.ID, canonicalID, duplicateIDs)
+	if err != nil {
+		return nil, err
+	}
+	s.l.Printf("Merged peaks %v into peak %d (proposal %d)", duplicateIDs, canonicalID, proposal.ID)
+
+	s.peakService.InvalidateCommunityStats(append(duplicateIDs, canonicalID)...)
+	s.refreshChallenges(challengeIDs)
+
+	return &models.PeakMergeResult{
+		ProposalID:          proposal.ID,
+		CanonicalPeakID:     canonicalID,
+		MergedPeakIDs:       duplicateIDs,
+		ChallengesRefreshed: len(challengeIDs),
+	}, nil
+}
+
+func (s *PeakMergeService) RejectProposal(proposalID int64) error {
+	if _, err := s.getPendingProposal(proposalID); err != nil {
+		return err
+	}
+	return s.peakMergeDao.RejectProposal(proposalID)
+}
+
+func (s *PeakMergeService) getPendingProposal(proposalID int64) (*models.PeakMergeProposal, error) {
+	proposal, err := s.peakMergeDao.GetProposalByID(proposalID)
+	if err != nil {
+		return nil, err
+	}
+	if proposal == nil {
+		return nil, ErrPeakMergeProposalNotFound
+	}
+	if proposal.Status != models.PeakMergeStatusPending {
+		return nil, ErrPeakMergeProposalResolved
+	}
+	return proposal, nil
+}
+
+// refreshChallenges recomputes cached progress for every participant, since
+// merged summits can change both peak counts and totals
+func (s *PeakMergeService) refreshChallenges(challengeIDs []int64) {
+	for _, challengeID := range challengeIDs {
+		participants, err := s.challengeDao.GetChallengeParticipants(challengeID)
+		if err != nil {
+			s.l.Printf("Error getting participants for challenge %d: %v", challengeID, err)
+			continue
+		}
+		for _, p := range participants {
+			if err := s.challengeService.RefreshParticipantProgress(challengeID, p.UserID); err != nil {
+				s.l.Printf("Error refreshing progress for challenge %d user %d: %v", challengeID, p.UserID, err)
+			}
+		}
+	}
+}
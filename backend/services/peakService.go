@@ -1,10 +1,13 @@
 package services
 
 import (
+	"errors"
 	"log"
 	"run-goals/daos"
 	"run-goals/models"
 	"strconv"
+	"sync"
+	"time"
 )
 
 type PeakServiceInterface interface {
@@ -12,21 +15,54 @@ type PeakServiceInterface interface {
 	StorePeaks() (resp *models.OverpassResponse)
 }
 
+var (
+	ErrPeakSearchSortInvalid = errors.New("invalid sort")
+	ErrPeakSearchNeedsGroup  = errors.New("group_id is required to filter by group summits")
+	ErrPeakSearchNeedsNear   = errors.New("sorting by distance needs lat and lon")
+	ErrNotGroupMember        = errors.New("user is not a member of the group")
+	ErrPeakNotFound          = errors.New("peak not found")
+)
+
+// Community stats for a peak are cached for this long. A new summit can take
+// up to this long to show up in them.
+const peakStatsCacheTTL = 10 * time.Minute
+
+// peakTopSummiteersLimit is how many of a peak's most frequent summiteers to list
+const peakTopSummiteersLimit = 10
+
+type cachedPeakStats struct {
+	stats     models.PeakCommunityStats
+	expiresAt time.Time
+}
+
 type PeakService struct {
-	l            *log.Logger
-	peaksDao     *daos.PeaksDao
-	userPeaksDao *daos.UserPeaksDao
+	l                *log.Logger
+	peaksDao         *daos.PeaksDao
+	userPeaksDao     *daos.UserPeaksDao
+	groupsDao        *daos.GroupsDao
+	challengeDao     *daos.ChallengeDao
+	elevationService *ElevationService
+
+	statsMu    sync.Mutex
+	statsCache map[int64]cachedPeakStats
 }
 
 func NewPeakService(
 	l *log.Logger,
 	peaksDao *daos.PeaksDao,
 	userPeaksDao *daos.UserPeaksDao,
+	groupsDao *daos.GroupsDao,
+	challengeDao *daos.ChallengeDao,
+	elevationService *ElevationService,
 ) *PeakService {
 	return &PeakService{
-		l:            l,
-		peaksDao:     peaksDao,
-		userPeaksDao: userPeaksDao,
+		l:                l,
+		peaksDao:         peaksDao,
+		userPeaksDao:     userPeaksDao,
+		groupsDao:        groupsDao,
+		challengeDao:     challengeDao,
+		elevationService: elevationService,
+		statsCache:       map[int64]cachedPeakStats{},
 	}
 }
 
@@ -65,6 +101,137 @@ func (s *PeakService) ListPeaks(userID int64) ([]models.PeakSummited, error) {
 	return peaksSummited, nil
 }
 
+// GetPeakDetail returns a peak with its community stats, the user's own
+// summits of it and the challenges that include it
+func (s *PeakService) GetPeakDetail(peakID int64, userID int64) (*models.PeakDetail, error) {
+	peak, err := s.peaksDao.GetPeakByID(peakID)
+	if err != nil {
+		return nil, err
+	}
+	if peak == nil {
+		return nil, ErrPeakNotFound
+	}
+	// A merged duplicate shows its canonical peak
+	if peak.MergedIntoID != nil {
+		return s.GetPeakDetail(*peak.MergedIntoID, userID)
+	}
+
+	stats, err := s.getCommunityStats(peakID)
+	if err != nil {
+		return nil, err
+	}
+	mySummits, err := s.userPeaksDao.GetUserPeakHistory(userID, peakID)
+	if err != nil {
+		return nil, err
+	}
+	challenges, err := s.challengeDao.GetChallengesForPeak(peakID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.PeakDetail{
+		Peak:       *peak,
+		Stats:      *stats,
+		MySummits:  mySummits,
+		Challenges: challenges,
+	}, nil
+}
+
+// getCommunityStats returns the cached stats for a peak, recomputing them once
+// they are older than peakStatsCacheTTL
+func (s *PeakService) getCommunityStats(peakID int64) (*models.PeakCommunityStats, error) {
+	now := time.Now()
+
+	s.statsMu.Lock()
+	cached, ok := s.statsCache[peakID]
+	s.statsMu.Unlock()
+	if ok && now.Before(cached.expiresAt) {
+		return &cached.stats, nil
+	}
+
+	stats, err := s.userPeaksDao.GetPeakCommunityStats(peakID, peakTopSummiteersLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	s.statsMu.Lock()
+	// Drop expired entries while we hold the lock so the cache doesn't grow
+	// with every peak ever viewed
+	for id, entry := range s.statsCache {
+		if now.After(entry.expiresAt) {
+			delete(s.statsCache, id)
+		}
+	}
+	s.statsCache[peakID] = cachedPeakStats{stats: *stats, expiresAt: now.Add(peakStatsCacheTTL)}
+	s.statsMu.Unlock()
+
+	return stats, nil
+}
+
+// InvalidateCommunityStats drops the cached stats for the given peaks
+func (s *PeakService) InvalidateCommunityStats(peakIDs ...int64) {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	for _, id := range peakIDs {
+		delete(s.statsCache, id)
+	}
+}
+
+// SearchPeaks returns one page of peaks matching the filter. Filtering on a
+// group's summits is only allowed for members of that group.
+func (s *PeakService) SearchPeaks(filter models.PeakSearchFilter) (*models.PeakSearchPage, error) {
+	if filter.Sort == "" {
+		filter.Sort = models.PeakSearchSortName
+		if filter.Query != "" {
+			filter.Sort = models.PeakSearchSortRelevance
+		}
+	}
+	switch filter.Sort {
+	case models.PeakSearchSortRelevance, models.PeakSearchSortName,
+		models.PeakSearchSortElevation, models.PeakSearchSortProminence:
+	case models.PeakSearchSortDistance:
+		if filter.NearLat == nil || filter.NearLon == nil {
+			return nil, ErrPeakSearchNeedsNear
+		}
+	default:
+		return nil, ErrPeakSearchSortInvalid
+	}
+
+	if filter.SummitedBy == "" {
+		filter.SummitedBy = models.PeakSummitedByMe
+	}
+	if filter.SummitedBy == models.PeakSummitedByGroup {
+		if filter.GroupID == nil {
+			return nil, ErrPeakSearchNeedsGroup
+		}
+		groups, err := s.groupsDao.GetUserGroups(filter.UserID)
+		if err != nil {
+			return nil, err
+		}
+		isMember := false
+		for _, group := range groups {
+			if group.ID == *filter.GroupID {
+				isMember = true
+				break
+			}
+		}
+		if !isMember {
+			return nil, ErrNotGroupMember
+		}
+	}
+
+	results, total, err := s.peaksDao.SearchPeaks(filter)
+	if err != nil {
+		return nil, err
+	}
+	return &models.PeakSearchPage{
+		Results: results,
+		Total:   total,
+		Limit:   filter.Limit,
+		Offset:  filter.Offset,
+	}, nil
+}
+
 func (s *PeakService) StorePeaks(resp *models.OverpassResponse) error {
 	if resp == nil {
 		return nil
@@ -132,6 +299,8 @@ func (s *PeakService) StorePeaks(resp *models.OverpassResponse) error {
 			Description:     description,
 			Prominence:      prominence,
 		}
+		// OSM often has no ele tag, or one in the wrong units
+		s.elevationService.FillPeakElevation(peak)
 
 		err := s.peaksDao.UpsertPeak(peak)
 		if err != nil {
@@ -141,3 +310,23 @@ func (s *PeakService) StorePeaks(resp *models.OverpassResponse) error {
 	}
 	return nil
 }
+
+// GetFastestAscents returns the fastest-ascent leaderboard for a peak
+func (s *PeakService) GetFastestAscents(peakID int64, viewerID int64, limit int) ([]models.FastestTimeEntry, error) {
+	entries, err := s.userPeaksDao.GetFastestAscents(peakID, viewerID, limit)
+	if err != nil {
+		s.l.Printf("Error calling UserPeaksDao: %v", err)
+		return nil, err
+	}
+	return entries, nil
+}
+
+// GetFastestTraverses returns the fastest-traverse leaderboard between two peaks
+func (s *PeakService) GetFastestTraverses(fromPeakID int64, toPeakID int64, viewerID int64, limit int) ([]models.FastestTimeEntry, error) {
+	entries, err := s.userPeaksDao.GetFastestTraverses(fromPeakID, toPeakID, viewerID, limit)
+	if err != nil {
+		s.l.Printf("Error calling UserPeaksDao: %v", err)
+		return nil, err
+	}
+	return entries, nil
+}
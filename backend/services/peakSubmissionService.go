@@ -0,0 +1,280 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"run-goals/daos"
+	"run-goals/geo"
+	"run-goals/models"
+	"strings"
+)
+
+var (
+	ErrPeakSubmissionNotFound         = errors.New("peak submission not found")
+	ErrPeakSubmissionReviewed         = errors.New("peak submission has already been reviewed")
+	ErrPeakSubmissionKindInvalid      = errors.New("kind must be new or correction")
+	ErrPeakSubmissionNameRequired     = errors.New("name is required for a new peak")
+	ErrPeakSubmissionPositionInvalid  = errors.New("latitude and longitude must be given together and be valid")
+	ErrPeakSubmissionElevationInvalid = errors.New("elevation is out of range")
+	ErrPeakSubmissionNoChanges        = errors.New("a correction must change the name, position or elevation")
+	ErrPeakSubmissionStatusInvalid    = errors.New("invalid status")
+	ErrNotAdmin                       = errors.New("only admins can do this")
+)
+
+// peakSubmissionNearbyMeters is how close an existing peak must be to a
+// submission's position to be shown to moderators as a possible duplicate
+const peakSubmissionNearbyMeters = 200.0
+
+type PeakSubmissionService struct {
+	l                 *log.Logger
+	peakSubmissionDao *daos.PeakSubmissionDao
+	peaksDao          *daos.PeaksDao
+	userDao           *daos.UserDao
+	elevationService  *ElevationService
+	peakService       *PeakService
+}
+
+func NewPeakSubmissionService(
+	l *log.Logger,
+	peakSubmissionDao *daos.PeakSubmissionDao,
+	peaksDao *daos.PeaksDao,
+	userDao *daos.UserDao,
+	elevationService *ElevationService,
+	peakService *PeakService,
+) *PeakSubmissionService {
+	return &PeakSubmissionService{
+		l:                 l,
+		peakSubmissionDao: peakSubmissionDao,
+		peaksDao:          peaksDao,
+		userDao:           userDao,
+		elevationService:  elevationService,
+		peakService:       peakService,
+	}
+}
+
+// Submit queues a new peak or a correction for moderation
+func (s *PeakSubmissionService) Submit(userID int64, submission models.PeakSubmission) (*int64, error) {
+	if err := s.validateSubmission(&submission); err != nil {
+		return nil, err
+	}
+	submission.UserID = &userID
+	return s.peakSubmissionDao.CreateSubmission(submission)
+}
+
+func (s *PeakSubmissionService) GetMySubmissions(userID int64) ([]models.PeakSubmission, error) {
+	return s.peakSubmissionDao.GetSubmissionsByUser(userID)
+}
+
+// GetQueue returns submissions with the given status for an admin, each with
+// the peak as it is now and possible duplicates near the proposed position
+func (s *PeakSubmissionService) GetQueue(userID int64, status models.PeakSubmissionStatus) ([]models.PeakSubmissionForReview, error) {
+	if err := s.requireAdmin(userID); err != nil {
+		return nil, err
+	}
+	switch status {
+	case models.PeakSubmissionStatusPending, models.PeakSubmissionStatusApproved, models.PeakSubmissionStatusRejected:
+	default:
+		return nil, ErrPeakSubmissionStatusInvalid
+	}
+
+	submissions, err := s.peakSubmissionDao.GetSubmissionsByStatus(status)
+	if err != nil {
+		return nil, err
+	}
+
+	queue := make([]models.PeakSubmissionForReview, 0, len(submissions))
+	for _, submission := range submissions {
+		item := models.PeakSubmissionForReview{PeakSubmission: submission, NearbyPeaks: []models.Peak{}}
+
+		if submission.Kind == models.PeakSubmissionKindCorrection && submission.PeakID != nil {
+			item.CurrentPeak, err = s.peaksDao.GetPeakByID(*submission.PeakID)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if submission.Latitude != nil && submission.Longitude != nil {
+			item.NearbyPeaks, err = s.nearbyPeaks(*submission.Latitude, *submission.Longitude, submission.PeakID)
+			if err != nil {
+				return nil, err
+			}
+		}
+		queue = append(queue, item)
+	}
+	return queue, nil
+}
+
+// Approve applies a pending submission. New peaks are added as local peaks,
+// with the elevation filled from DEM tiles if none was given. Corrections
+// update the peak and lock the corrected fields. Returns the peak's ID.
+func (s *PeakSubmissionService) Approve(submissionID int64, reviewerID int64, reviewerNote *string) (int64, error) {
+	if err := s.requireAdmin(reviewerID); err != nil {
+		return 0, err
+	}
+	submission, err := s.getPendingSubmission(submissionID)
+	if err != nil {
+		return 0, err
+	}
+
+	var peakID int64
+	if submission.Kind == models.PeakSubmissionKindNew {
+		peak := models.Peak{
+			Name:      *submission.Name,
+			Latitude:  *submission.Latitude,
+			Longitude: *submission.Longitude,
+		}
+		if submission.ElevationMeters != nil {
+			peak.ElevationMeters = *submission.ElevationMeters
+			peak.ElevationSource = models.ElevationSourceUser
+		} else {
+			s.elevationService.FillPeakElevation(&peak)
+		}
+		peakID, err = s.peakSubmissionDao.ApproveNewPeak(submissionID, reviewerID, reviewerNote, peak)
+	} else {
+		peak, lookupErr := s.peaksDao.GetPeakByID(*submission.PeakID)
+		if lookupErr != nil {
+			return 0, lookupErr
+		}
+		if peak == nil {
+			return 0, ErrPeakNotFound
+		}
+		// Corrections to a merged duplicate apply to the peak it was merged into
+		peakID = peak.ID
+		if peak.MergedIntoID != nil {
+			peakID = *peak.MergedIntoID
+		}
+		err = s.peakSubmissionDao.ApproveCorrection(submissionID, reviewerID, reviewerNote, peakID, *submission, lockedFieldsFor(*submission))
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrPeakSubmissionReviewed
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	s.l.Printf("Peak submission %d (%s) approved by user %d, peak %d", submissionID, submission.Kind, reviewerID, peakID)
+	s.peakService.InvalidateCommunityStats(peakID)
+	return peakID, nil
+}
+
+func (s *PeakSubmissionService) Reject(submissionID int64, reviewerID int64, reviewerNote *string) error {
+	if err := s.requireAdmin(reviewerID); err != nil {
+		return err
+	}
+	if _, err := s.getPendingSubmission(submissionID); err != nil {
+		return err
+	}
+	err := s.peakSubmissionDao.RejectSubmission(submissionID, reviewerID, reviewerNote)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrPeakSubmissionReviewed
+	}
+	return err
+}
+
+func (s *PeakSubmissionService) getPendingSubmission(submissionID int64) (*models.PeakSubmission, error) {
+	submission, err := s.peakSubmissionDao.GetSubmissionByID(submissionID)
+	if err != nil {
+		return nil, err
+	}
+	if submission == nil {
+		return nil, ErrPeakSubmissionNotFound
+	}
+	if submission.Status != models.PeakSubmissionStatusPending {
+		return nil, ErrPeakSubmissionReviewed
+	}
+	return submission, nil
+}
+
+func (s *PeakSubmissionService) requireAdmin(userID int64) error {
+	user, err := s.userDao.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if !user.IsAdmin {
+		return ErrNotAdmin
+	}
+	return nil
+}
+
+func (s *PeakSubmissionService) validateSubmission(submission *models.PeakSubmission) error {
+	if submission.Name != nil {
+		name := strings.TrimSpace(*submission.Name)
+		submission.Name = &name
+		if name == "" {
+			submission.Name = nil
+		}
+	}
+	if (submission.Latitude == nil) != (submission.Longitude == nil) {
+		return ErrPeakSubmissionPositionInvalid
+	}
+	if submission.Latitude != nil &&
+		(*submission.Latitude < -90 || *submission.Latitude > 90 || *submission.Longitude < -180 || *submission.Longitude > 180) {
+		return ErrPeakSubmissionPositionInvalid
+	}
+	if e := submission.ElevationMeters; e != nil && (*e < minPlausibleElevation || *e > maxPlausibleElevation) {
+		return ErrPeakSubmissionElevationInvalid
+	}
+
+	switch submission.Kind {
+	case models.PeakSubmissionKindNew:
+		if submission.Name == nil {
+			return ErrPeakSubmissionNameRequired
+		}
+		if submission.Latitude == nil {
+			return ErrPeakSubmissionPositionInvalid
+		}
+		submission.PeakID = nil
+	case models.PeakSubmissionKindCorrection:
+		if submission.PeakID == nil {
+			return ErrPeakNotFound
+		}
+		peak, err := s.peaksDao.GetPeakByID(*submission.PeakID)
+		if err != nil {
+			return err
+		}
+		if peak == nil {
+			return ErrPeakNotFound
+		}
+		if len(lockedFieldsFor(*submission)) == 0 {
+			return ErrPeakSubmissionNoChanges
+		}
+	default:
+		return ErrPeakSubmissionKindInvalid
+	}
+	return nil
+}
+
+// nearbyPeaks returns active peaks within peakSubmissionNearbyMeters of a
+// point, leaving out the peak being corrected
+func (s *PeakSubmissionService) nearbyPeaks(lat float64, lon float64, excludeID *int64) ([]models.Peak, error) {
+	minLat, maxLat, minLon, maxLon := geo.BoundingBox(lat, lon, peakSubmissionNearbyMeters)
+	candidates, err := s.peaksDao.GetPeaksBetweenLatLon(minLat, maxLat, minLon, maxLon)
+	if err != nil {
+		return nil, err
+	}
+	nearby := []models.Peak{}
+	for _, p := range candidates {
+		if excludeID != nil && p.ID == *excludeID {
+			continue
+		}
+		if geo.HaversineMeters(lat, lon, p.Latitude, p.Longitude) <= peakSubmissionNearbyMeters {
+			nearby = append(nearby, p)
+		}
+	}
+	return nearby, nil
+}
+
+// lockedFieldsFor lists the peak fields a correction changes
+func lockedFieldsFor(submission models.PeakSubmission) []string {
+	fields := []string{}
+	if submission.Name != nil {
+		fields = append(fields, models.PeakLockedName)
+	}
+	if submission.Latitude != nil {
+		fields = append(fields, models.PeakLockedPosition)
+	}
+	if submission.ElevationMeters != nil {
+		fields = append(fields, models.PeakLockedElevation)
+	}
+	return fields
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"run-goals/models"
+	"strings"
+	"time"
+)
+
+func validPersonalGoalMetric(metric models.PersonalGoalMetric) bool {
+	switch metric {
+	case models.PersonalGoalMetricDistance, models.PersonalGoalMetricElevation,
+		models.PersonalGoalMetricMovingTime, models.PersonalGoalMetricActivityCount,
+		models.PersonalGoalMetricSummitCount, models.PersonalGoalMetricUniquePeaks,
+		models.PersonalGoalMetricRegionPeaks, models.PersonalGoalMetricSpecificSummits:
+		return true
+	}
+	return false
+}
+
+// personalGoalPeriod snaps the goal's start date to the beginning of its week,
+// month, quarter or year and sets the matching (inclusive) end date. Custom
+// periods keep the dates they were given.
+func personalGoalPeriod(goal *models.PersonalGoal, weekStart time.Weekday) error {
+	start := time.Date(goal.StartDate.Year(), goal.StartDate.Month(), goal.StartDate.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch goal.PeriodType {
+	case models.PersonalGoalPeriodWeek:
+		start = streakPeriodStart(start, true, weekStart)
+		goal.EndDate = start.AddDate(0, 0, 6)
+	case models.PersonalGoalPeriodMonth:
+		start = time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC)
+		goal.EndDate = start.AddDate(0, 1, -1)
+	case models.PersonalGoalPeriodQuarter:
+		month := time.Month((int(start.Month())-1)/3*3 + 1)
+		start = time.Date(start.Year(), month, 1, 0, 0, 0, 0, time.UTC)
+		goal.EndDate = start.AddDate(0, 3, -1)
+	case models.PersonalGoalPeriodYear:
+		start = time.Date(start.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+		goal.EndDate = start.AddDate(1, 0, -1)
+	case models.PersonalGoalPeriodCustom:
+		end := time.Date(goal.EndDate.Year(), goal.EndDate.Month(), goal.EndDate.Day(), 0, 0, 0, 0, time.UTC)
+		if goal.EndDate.IsZero() || end.Before(start) {
+			return ErrPersonalGoalPeriodInvalid
+		}
+		goal.EndDate = end
+	default:
+		return ErrPersonalGoalPeriodInvalid
+	}
+
+	goal.StartDate = start
+	return nil
+}
+
+// validatePersonalGoal checks the goal and fills in defaults: the period end
+// date, and a specific_summits target of every listed peak
+func validatePersonalGoal(goal *models.PersonalGoal, weekStart time.Weekday) error {
+	if strings.TrimSpace(goal.Name) == "" {
+		return ErrPersonalGoalNameRequired
+	}
+	if !validPersonalGoalMetric(goal.Metric) {
+		return ErrPersonalGoalMetricInvalid
+	}
+	if goal.StartDate.IsZero() {
+		return ErrPersonalGoalPeriodInvalid
+	}
+	if err := personalGoalPeriod(goal, weekStart); err != nil {
+		return err
+	}
+
+	switch goal.Metric {
+	case models.PersonalGoalMetricRegionPeaks:
+		if goal.Region == nil || *goal.Region == "" {
+			return ErrPersonalGoalRegionRequired
+		}
+	case models.PersonalGoalMetricSpecificSummits:
+		if len(goal.PeakIDs) == 0 {
+			return ErrPersonalGoalPeaksRequired
+		}
+		if goal.TargetValue == 0 {
+			goal.TargetValue = float64(len(goal.PeakIDs))
+		}
+	}
+
+	if goal.TargetValue <= 0 {
+		return ErrPersonalGoalTargetInvalid
+	}
+	return nil
+}
+
+// personalGoalActivityMatches reports whether the activity passes the goal's
+// activity type filter. Either the type or the sport type can match.
+func personalGoalActivityMatches(goal models.PersonalGoal, activity models.Activity) bool {
+	if len(goal.ActivityTypes) == 0 {
+		return true
+	}
+	for _, t := range goal.ActivityTypes {
+		if strings.EqualFold(t, activity.Type) || strings.EqualFold(t, activity.SportType) {
+			return true
+		}
+	}
+	return false
+}
+
+// measurePersonalGoal totals the goal's metric up to asOf, and separately from
+// trendStart to asOf. activities and summits should already be limited to the
+// goal's period.
+func measurePersonalGoal(
+	goal models.PersonalGoal,
+	activities []models.Activity,
+	summits []models.SummitWithPeak,
+	asOf time.Time,
+	trendStart time.Time,
+) (float64, float64) {
+	var actual, recent float64
+
+	matched := map[int64]bool{}
+	for _, activity := range activities {
+		if activity.StartDate.After(asOf) || !personalGoalActivityMatches(goal, activity) {
+			continue
+		}
+		matched[activity.ID] = true
+
+		var value float64
+		switch goal.Metric {
+		case models.PersonalGoalMetricDistance:
+			value = activity.Distance / 1000 // Convert meters to km
+		case models.PersonalGoalMetricElevation:
+			value = activity.Elevation
+		case models.PersonalGoalMetricMovingTime:
+			value = activity.MovingTime / 3600 // Convert seconds to hours
+		case models.PersonalGoalMetricActivityCount:
+			value = 1
+		default:
+			continue
+		}
+		actual += value
+		if !activity.StartDate.Before(trendStart) {
+			recent += value
+		}
+	}
+
+	wanted := map[int64]bool{}
+	for _, peakID := range goal.PeakIDs {
+		wanted[peakID] = true
+	}
+
+	// Peak metrics count distinct peaks, so each peak is only counted the
+	// first time it is summited in the period
+	seen := map[int64]bool{}
+	for _, summit := range summits {
+		if summit.SummitedAt.After(asOf) || !matched[summit.ActivityID] {
+			continue
+		}
+
+		counts := false
+		switch goal.Metric {
+		case models.PersonalGoalMetricSummitCount:
+			counts = true
+		case models.PersonalGoalMetricUniquePeaks:
+			counts = !seen[summit.PeakID]
+		case models.PersonalGoalMetricRegionPeaks:
+			counts = !seen[summit.PeakID] && goal.Region != nil && strings.EqualFold(summit.Region, *goal.Region)
+		case models.PersonalGoalMetricSpecificSummits:
+			counts = !seen[summit.PeakID] && wanted[summit.PeakID]
+		}
+		seen[summit.PeakID] = true
+
+		if counts {
+			actual++
+			if !summit.SummitedAt.Before(trendStart) {
+				recent++
+			}
+		}
+	}
+
+	return actual, recent
+}
@@ -1,21 +1,48 @@
 package services
 
 import (
+	"errors"
 	"log"
 	"run-goals/daos"
 	"run-goals/models"
 	"time"
 )
 
+var (
+	ErrPersonalGoalNotFound       = errors.New("personal goal not found")
+	ErrPersonalGoalNameRequired   = errors.New("personal goal name is required")
+	ErrPersonalGoalMetricInvalid  = errors.New("invalid personal goal metric")
+	ErrPersonalGoalPeriodInvalid  = errors.New("invalid personal goal period")
+	ErrPersonalGoalTargetInvalid  = errors.New("personal goal target must be positive")
+	ErrPersonalGoalRegionRequired = errors.New("region goals need a region")
+	ErrPersonalGoalPeaksRequired  = errors.New("specific summit goals need at least one peak")
+	ErrYearlyGoalFixed            = errors.New("the metric and period of a yearly goal can't be changed")
+)
+
 type PersonalGoalsService struct {
-	l   *log.Logger
-	dao *daos.PersonalYearlyGoalDao
+	l            *log.Logger
+	dao          *daos.PersonalYearlyGoalDao
+	goalDao      *daos.PersonalGoalDao
+	activityDao  *daos.ActivityDao
+	userPeaksDao *daos.UserPeaksDao
+	userDao      *daos.UserDao
 }
 
-func NewPersonalGoalsService(l *log.Logger, dao *daos.PersonalYearlyGoalDao) *PersonalGoalsService {
+func NewPersonalGoalsService(
+	l *log.Logger,
+	dao *daos.PersonalYearlyGoalDao,
+	goalDao *daos.PersonalGoalDao,
+	activityDao *daos.ActivityDao,
+	userPeaksDao *daos.UserPeaksDao,
+	userDao *daos.UserDao,
+) *PersonalGoalsService {
 	return &PersonalGoalsService{
-		l:   l,
-		dao: dao,
+		l:            l,
+		dao:          dao,
+		goalDao:      goalDao,
+		activityDao:  activityDao,
+		userPeaksDao: userPeaksDao,
+		userDao:      userDao,
 	}
 }
 
@@ -61,3 +88,236 @@ func (s *PersonalGoalsService) SaveGoal(goal *models.PersonalYearlyGoal) error {
 func (s *PersonalGoalsService) DeleteGoal(userID int64, year int) error {
 	return s.dao.Delete(userID, year)
 }
+
+// GetGoalProgress computes how far through their goal for a year the user is,
+// with the expected pace, status, projection and required weekly rate
+func (s *PersonalGoalsService) GetGoalProgress(userID int64, year int) (*models.PersonalGoalProgress, error) {
+	goal, err := s.GetGoalForYear(userID, year)
+	if err != nil {
+		return nil, err
+	}
+	now, err := s.userNow(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.calculateProgress(goal, now)
+}
+
+// GetAllGoalProgress computes progress for every yearly goal the user has set
+// (history), newest year first
+func (s *PersonalGoalsService) GetAllGoalProgress(userID int64) ([]models.PersonalGoalProgress, error) {
+	goals, err := s.GetAllGoals(userID)
+	if err != nil {
+		return nil, err
+	}
+	now, err := s.userNow(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	progress := []models.PersonalGoalProgress{}
+	for i := range goals {
+		p, err := s.calculateProgress(&goals[i], now)
+		if err != nil {
+			return nil, err
+		}
+		progress = append(progress, *p)
+	}
+	return progress, nil
+}
+
+// userNow returns the current wall-clock time in the user's timezone, matching
+// how activity start dates are stored
+func (s *PersonalGoalsService) userNow(userID int64) (time.Time, error) {
+	user, err := s.userDao.GetUserByID(userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	timezone := "UTC"
+	if user != nil {
+		timezone = user.Timezone
+	}
+	return localToday(timezone), nil
+}
+
+func (s *PersonalGoalsService) userWeekStart(userID int64) (time.Weekday, error) {
+	user, err := s.userDao.GetUserByID(userID)
+	if err != nil {
+		return time.Monday, err
+	}
+	if user == nil {
+		return time.Monday, nil
+	}
+	return time.Weekday(user.WeekStart), nil
+}
+
+func (s *PersonalGoalsService) calculateProgress(goal *models.PersonalYearlyGoal, now time.Time) (*models.PersonalGoalProgress, error) {
+	start, end := yearBounds(goal.Year)
+	asOf := goalAsOf(start, end, now)
+	trendStart := asOf.AddDate(0, 0, -goalTrendDays)
+	last := end.Add(-time.Microsecond)
+
+	activities, err := s.activityDao.GetActivitiesByUserIDAndDateRange(goal.UserID, &start, &last)
+	if err != nil {
+		return nil, err
+	}
+	summits, err := s.userPeaksDao.GetUserSummitsInDateRangeAll(goal.UserID, start, last)
+	if err != nil {
+		return nil, err
+	}
+
+	var distance, elevation, recentDistance, recentElevation float64
+	for _, activity := range activities {
+		if activity.StartDate.After(asOf) {
+			continue
+		}
+		distance += activity.Distance / 1000 // Convert meters to km
+		elevation += activity.Elevation
+		if !activity.StartDate.Before(trendStart) {
+			recentDistance += activity.Distance / 1000
+			recentElevation += activity.Elevation
+		}
+	}
+
+	var summitCount, recentSummits float64
+	for _, summit := range summits {
+		if summit.SummitedAt.After(asOf) {
+			continue
+		}
+		summitCount++
+		if !summit.SummitedAt.Before(trendStart) {
+			recentSummits++
+		}
+	}
+
+	return &models.PersonalGoalProgress{
+		Year:        goal.Year,
+		AsOf:        asOf,
+		DaysElapsed: int(asOf.Sub(start).Hours() / 24),
+		DaysInYear:  int(end.Sub(start).Hours() / 24),
+		Goal:        *goal,
+		Distance:    goalMetric(goal.DistanceGoal, distance, recentDistance, start, end, asOf),
+		Elevation:   goalMetric(goal.ElevationGoal, elevation, recentElevation, start, end, asOf),
+		Summits:     goalMetric(float64(goal.SummitGoal), summitCount, recentSummits, start, end, asOf),
+	}, nil
+}
+
+// ListPersonalGoals returns all of the user's goals, including the ones backing
+// their yearly goals, each with its current progress
+func (s *PersonalGoalsService) ListPersonalGoals(userID int64) ([]models.PersonalGoalWithProgress, error) {
+	goals, err := s.goalDao.GetByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	now, err := s.userNow(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []models.PersonalGoalWithProgress{}
+	for _, goal := range goals {
+		progress, err := s.calculatePersonalGoalProgress(goal, now)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *progress)
+	}
+	return result, nil
+}
+
+// GetPersonalGoal returns one of the user's goals with its current progress
+func (s *PersonalGoalsService) GetPersonalGoal(goalID int64, userID int64) (*models.PersonalGoalWithProgress, error) {
+	goal, err := s.getOwnedGoal(goalID, userID)
+	if err != nil {
+		return nil, err
+	}
+	now, err := s.userNow(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.calculatePersonalGoalProgress(*goal, now)
+}
+
+// CreatePersonalGoal validates and saves a new goal for the user
+func (s *PersonalGoalsService) CreatePersonalGoal(goal *models.PersonalGoal) error {
+	weekStart, err := s.userWeekStart(goal.UserID)
+	if err != nil {
+		return err
+	}
+	if err := validatePersonalGoal(goal, weekStart); err != nil {
+		return err
+	}
+	// Yearly goals are only created through SaveGoal
+	goal.IsYearlyGoal = false
+	return s.goalDao.Create(goal)
+}
+
+// UpdatePersonalGoal validates and saves changes to one of the user's goals.
+// Goals backing a yearly goal can only have their name, target and filters changed.
+func (s *PersonalGoalsService) UpdatePersonalGoal(goal *models.PersonalGoal, userID int64) error {
+	existing, err := s.getOwnedGoal(goal.ID, userID)
+	if err != nil {
+		return err
+	}
+	weekStart, err := s.userWeekStart(userID)
+	if err != nil {
+		return err
+	}
+
+	goal.UserID = existing.UserID
+	goal.IsYearlyGoal = existing.IsYearlyGoal
+	goal.CreatedAt = existing.CreatedAt
+	if err := validatePersonalGoal(goal, weekStart); err != nil {
+		return err
+	}
+	if existing.IsYearlyGoal && (goal.Metric != existing.Metric ||
+		goal.PeriodType != existing.PeriodType ||
+		!goal.StartDate.Equal(existing.StartDate)) {
+		return ErrYearlyGoalFixed
+	}
+	return s.goalDao.Update(goal)
+}
+
+// DeletePersonalGoal removes one of the user's goals
+func (s *PersonalGoalsService) DeletePersonalGoal(goalID int64, userID int64) error {
+	if _, err := s.getOwnedGoal(goalID, userID); err != nil {
+		return err
+	}
+	return s.goalDao.Delete(goalID)
+}
+
+// getOwnedGoal loads a goal, treating other users' goals as not found
+func (s *PersonalGoalsService) getOwnedGoal(goalID int64, userID int64) (*models.PersonalGoal, error) {
+	goal, err := s.goalDao.GetByID(goalID)
+	if err != nil {
+		return nil, err
+	}
+	if goal == nil || goal.UserID != userID {
+		return nil, ErrPersonalGoalNotFound
+	}
+	return goal, nil
+}
+
+func (s *PersonalGoalsService) calculatePersonalGoalProgress(goal models.PersonalGoal, now time.Time) (*models.PersonalGoalWithProgress, error) {
+	start := goal.StartDate
+	end := goal.EndDate.AddDate(0, 0, 1)
+	asOf := goalAsOf(start, end, now)
+	trendStart := asOf.AddDate(0, 0, -goalTrendDays)
+	last := end.Add(-time.Microsecond)
+
+	activities, err := s.activityDao.GetActivitiesByUserIDAndDateRange(goal.UserID, &start, &last)
+	if err != nil {
+		return nil, err
+	}
+	summits, err := s.userPeaksDao.GetUserSummitsWithPeaksInDateRange(goal.UserID, start, last)
+	if err != nil {
+		return nil, err
+	}
+
+	actual, recent := measurePersonalGoal(goal, activities, summits, asOf, trendStart)
+	return &models.PersonalGoalWithProgress{
+		PersonalGoal: goal,
+		AsOf:         asOf,
+		Progress:     goalMetric(goal.TargetValue, actual, recent, start, end, asOf),
+	}, nil
+}
@@ -0,0 +1,313 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"math"
+	"math/rand"
+	"run-goals/daos"
+	"run-goals/geo"
+	"run-goals/models"
+	"sort"
+	"strings"
+
+	"github.com/twpayne/go-polyline"
+)
+
+var (
+	ErrPrivacyZoneNotFound        = errors.New("privacy zone not found")
+	ErrPrivacyZonePositionInvalid = errors.New("latitude and longitude must be valid")
+	ErrPrivacyZoneRadiusInvalid   = errors.New("radius_meters must be between 100 and 5000")
+	ErrPrivacyZoneLimitReached    = errors.New("too many privacy zones")
+)
+
+const (
+	minPrivacyZoneRadius = 100.0
+	maxPrivacyZoneRadius = 5000.0
+	maxPrivacyZones      = 10
+	// A zone's trim circle is larger than it by a random fraction of its
+	// radius between these
+	minPrivacyZoneMargin = 0.25
+	maxPrivacyZoneMargin = 0.75
+)
+
+type PrivacyService struct {
+	l              *log.Logger
+	privacyZoneDao *daos.PrivacyZoneDao
+	userDao        *daos.UserDao
+}
+
+func NewPrivacyService(
+	l *log.Logger,
+	privacyZoneDao *daos.PrivacyZoneDao,
+	userDao *daos.UserDao,
+) *PrivacyService {
+	return &PrivacyService{
+		l:              l,
+		privacyZoneDao: privacyZoneDao,
+		userDao:        userDao,
+	}
+}
+
+// ==================== Zones ====================
+
+func (s *PrivacyService) GetZones(userID int64) ([]models.PrivacyZone, error) {
+	return s.privacyZoneDao.GetZonesByUser(userID)
+}
+
+func (s *PrivacyService) CreateZone(userID int64, zone models.PrivacyZone) (*int64, error) {
+	if zone.Latitude < -90 || zone.Latitude > 90 || zone.Longitude < -180 || zone.Longitude > 180 {
+		return nil, ErrPrivacyZonePositionInvalid
+	}
+	if zone.RadiusMeters < minPrivacyZoneRadius || zone.RadiusMeters > maxPrivacyZoneRadius {
+		return nil, ErrPrivacyZoneRadiusInvalid
+	}
+	existing, err := s.privacyZoneDao.GetZonesByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxPrivacyZones {
+		return nil, ErrPrivacyZoneLimitReached
+	}
+
+	zone.UserID = userID
+	zone.Name = strings.TrimSpace(zone.Name)
+	setTrimCircle(&zone)
+	return s.privacyZoneDao.CreateZone(zone)
+}
+
+// setTrimCircle picks the circle the zone's routes are cut at. It's larger
+// than the zone and shifted by less than the difference, so it always covers
+// the whole zone. The 99p migration does the same for existing zones.
+func setTrimCircle(zone *models.PrivacyZone) {
+	margin := zone.RadiusMeters * (minPrivacyZoneMargin + rand.Float64()*(maxPrivacyZoneMargin-minPrivacyZoneMargin))
+	shift := margin * rand.Float64()
+	bearing := rand.Float64() * 2 * math.Pi
+	zone.TrimLatitude, zone.TrimLongitude = geo.OffsetMeters(zone.Latitude, zone.Longitude, shift*math.Cos(bearing), shift*math.Sin(bearing))
+	zone.TrimRadiusMeters = zone.RadiusMeters + margin
+}
+
+func (s *PrivacyService) DeleteZone(userID int64, zoneID int64) error {
+	deleted, err := s.privacyZoneDao.DeleteZone(zoneID, userID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrPrivacyZoneNotFound
+	}
+	return nil
+}
+
+// ZonesByUser returns the zones of each of the given users
+func (s *PrivacyService) ZonesByUser(userIDs []int64) (map[int64][]models.PrivacyZone, error) {
+	byUser := map[int64][]models.PrivacyZone{}
+	if len(userIDs) == 0 {
+		return byUser, nil
+	}
+	zones, err := s.privacyZoneDao.GetZonesByUsers(userIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, zone := range zones {
+		byUser[zone.UserID] = append(byUser[zone.UserID], zone)
+	}
+	return byUser, nil
+}
+
+// ==================== Trimming ====================
+
+// TrimCoords cuts the parts of a route inside any of the zones' trim circles
+// out and returns the pieces left, so no part of a route inside a zone is
+// drawn. Segments are cut where they cross a circle, so a straight line
+// between two points outside a zone can't be drawn through it either. coords
+// are [lat, lon] pairs.
+func TrimCoords(coords [][]float64, zones []models.PrivacyZone) [][][]float64 {
+	if len(zones) == 0 {
+		return [][][]float64{coords}
+	}
+	pieces := [][][]float64{}
+	if len(coords) == 1 {
+		if len(outsideZones(coords[0], coords[0], zones)) > 0 {
+			pieces = append(pieces, coords)
+		}
+		return pieces
+	}
+
+	current := [][]float64{}
+	for i := 1; i < len(coords); i++ {
+		a, b := coords[i-1], coords[i]
+		outside := outsideZones(a, b, zones)
+		for _, span := range outside {
+			start := interpolate(a, b, span[0])
+			// Entering the segment from inside a zone starts a new piece
+			if len(current) > 0 && span[0] > 0 {
+				pieces = append(pieces, current)
+				current = [][]float64{}
+			}
+			if len(current) == 0 {
+				current = append(current, start)
+			}
+			current = append(current, interpolate(a, b, span[1]))
+			// Leaving it into a zone ends the piece
+			if span[1] < 1 {
+				pieces = append(pieces, current)
+				current = [][]float64{}
+			}
+		}
+		// The whole segment is inside a zone
+		if len(current) > 0 && len(outside) == 0 {
+			pieces = append(pieces, current)
+			current = [][]float64{}
+		}
+	}
+	if len(current) > 0 {
+		pieces = append(pieces, current)
+	}
+	return pieces
+}
+
+// TrimPolyline removes the parts of an encoded route inside the zones and
+// returns each piece left as its own polyline, so nothing is drawn across a
+// zone. Pieces too short to draw are dropped, so routes entirely inside
+// zones come back with none.
+func TrimPolyline(encoded string, zones []models.PrivacyZone) []string {
+	if encoded == "" {
+		return []string{}
+	}
+	if len(zones) == 0 {
+		return []string{encoded}
+	}
+	coords, _, err := polyline.DecodeCoords([]byte(encoded))
+	if err != nil {
+		// A route we can't read can't be trimmed, so it isn't shown at all
+		return []string{}
+	}
+
+	pieces := []string{}
+	for _, piece := range TrimCoords(coords, zones) {
+		if len(piece) < 2 {
+			continue
+		}
+		pieces = append(pieces, string(polyline.EncodeCoords(piece)))
+	}
+	return pieces
+}
+
+// trimActivityRoute trims an activity's route to its owner's zones. The
+// pieces go in MapPolylines, and MapPolyline is only kept when the route
+// comes out in one piece.
+func trimActivityRoute(activity *models.Activity, zones []models.PrivacyZone) {
+	if activity.MapPolyline == "" || len(zones) == 0 {
+		return
+	}
+	activity.MapPolylines = TrimPolyline(activity.MapPolyline, zones)
+	activity.MapPolyline = ""
+	if len(activity.MapPolylines) == 1 {
+		activity.MapPolyline = activity.MapPolylines[0]
+	}
+}
+
+// outsideZones returns the spans of the segment from a to b, as fractions
+// along it in order, that are outside every zone's trim circle
+func outsideZones(a []float64, b []float64, zones []models.PrivacyZone) [][2]float64 {
+	inside := [][2]float64{}
+	for _, zone := range zones {
+		if from, to, ok := geo.SegmentInCircle(a, b, zone.TrimLatitude, zone.TrimLongitude, zone.TrimRadiusMeters); ok {
+			inside = append(inside, [2]float64{from, to})
+		}
+	}
+	sort.Slice(inside, func(i, j int) bool { return inside[i][0] < inside[j][0] })
+
+	outside := [][2]float64{}
+	position := 0.0
+	for _, span := range inside {
+		if span[0] > position {
+			outside = append(outside, [2]float64{position, span[0]})
+		}
+		position = math.Max(position, span[1])
+	}
+	if position < 1 {
+		outside = append(outside, [2]float64{position, 1})
+	}
+	return outside
+}
+
+// interpolate returns the point the given fraction of the way from a to b
+func interpolate(a []float64, b []float64, fraction float64) []float64 {
+	if fraction == 0 {
+		return a
+	}
+	if fraction == 1 {
+		return b
+	}
+	return []float64{a[0] + (b[0]-a[0])*fraction, a[1] + (b[1]-a[1])*fraction}
+}
+
+// ==================== Feeds ====================
+
+// RedactChallengeActivities trims the routes of other users' activities to
+// their privacy zones. The viewer's own activities are left whole.
+func (s *PrivacyService) RedactChallengeActivities(viewerID int64, activities []models.ActivityWithUser) error {
+	userIDs := []int64{}
+	seen := map[int64]bool{}
+	for _, a := range activities {
+		if a.UserID != viewerID && !seen[a.UserID] {
+			seen[a.UserID] = true
+			userIDs = append(userIDs, a.UserID)
+		}
+	}
+	zones, err := s.ZonesByUser(userIDs)
+	if err != nil {
+		return err
+	}
+	for i := range activities {
+		if activities[i].UserID == viewerID {
+			continue
+		}
+		trimActivityRoute(&activities[i].Activity, zones[activities[i].UserID])
+	}
+	return nil
+}
+
+// PublicFeedActivities filters a user's activities down to the ones anyone
+// may see, with routes trimmed to their privacy zones. Nothing is returned if
+// the user has opted out of group feeds.
+func (s *PrivacyService) PublicFeedActivities(owner *models.User, activities []models.Activity) ([]models.Activity, error) {
+	visible := []models.Activity{}
+	if !owner.ShowInGroupFeeds {
+		return visible, nil
+	}
+	zones, err := s.privacyZoneDao.GetZonesByUser(owner.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, activity := range activities {
+		// The feed is public, so followers-only activities stay out too
+		if activity.IsHiddenFromOthers() || activity.Visibility == models.VisibilityFollowersOnly {
+			continue
+		}
+		trimActivityRoute(&activity, zones)
+		visible = append(visible, activity)
+	}
+	return visible, nil
+}
+
+// GroupFeedUserIDs returns which of the users allow their activities to be
+// shown in group feeds. The viewer is always included.
+func (s *PrivacyService) GroupFeedUserIDs(viewerID int64, userIDs []int64) ([]int64, error) {
+	shown, err := s.userDao.GetUserIDsShowingInGroupFeeds(userIDs)
+	if err != nil {
+		return nil, err
+	}
+	allowed := map[int64]bool{viewerID: true}
+	for _, id := range shown {
+		allowed[id] = true
+	}
+	filtered := []int64{}
+	for _, id := range userIDs {
+		if allowed[id] {
+			filtered = append(filtered, id)
+		}
+	}
+	return filtered, nil
+}
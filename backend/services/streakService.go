@@ -0,0 +1,146 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"run-goals/daos"
+	"run-goals/models"
+	"time"
+)
+
+var (
+	ErrTimezoneInvalid  = errors.New("invalid timezone")
+	ErrWeekStartInvalid = errors.New("week start must be between 0 (Sunday) and 6 (Saturday)")
+)
+
+type StreakServiceInterface interface {
+	GetUserStreaks(userID int64) (*models.UserStreaks, error)
+	GetStreakInRange(userID int64, streakType models.StreakType, start *time.Time, end *time.Time) (*models.Streak, error)
+	UpdatePreferences(userID int64, timezone string, weekStart int) error
+}
+
+type StreakService struct {
+	l            *log.Logger
+	userDao      *daos.UserDao
+	activityDao  *daos.ActivityDao
+	userPeaksDao *daos.UserPeaksDao
+}
+
+func NewStreakService(
+	l *log.Logger,
+	userDao *daos.UserDao,
+	activityDao *daos.ActivityDao,
+	userPeaksDao *daos.UserPeaksDao,
+) *StreakService {
+	return &StreakService{
+		l:            l,
+		userDao:      userDao,
+		activityDao:  activityDao,
+		userPeaksDao: userPeaksDao,
+	}
+}
+
+// GetUserStreaks returns the user's activity and summit streaks
+func (s *StreakService) GetUserStreaks(userID int64) (*models.UserStreaks, error) {
+	user, err := s.userDao.GetUserByID(userID)
+	if err != nil {
+		s.l.Printf("Error getting user %d for streaks: %v", userID, err)
+		return nil, err
+	}
+
+	activityTimes, err := s.activityTimes(userID, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	summitTimes, err := s.summitTimes(userID, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	today := localToday(user.Timezone)
+	weekStart := time.Weekday(user.WeekStart)
+
+	return &models.UserStreaks{
+		UserID:         userID,
+		Timezone:       user.Timezone,
+		WeekStart:      user.WeekStart,
+		DailyActivity:  computeStreak(models.StreakTypeDailyActivity, activityTimes, weekStart, today),
+		WeeklyActivity: computeStreak(models.StreakTypeWeeklyActivity, activityTimes, weekStart, today),
+		WeeklySummit:   computeStreak(models.StreakTypeWeeklySummit, summitTimes, weekStart, today),
+	}, nil
+}
+
+// GetStreakInRange computes one streak using only activity between start and
+// end (either may be nil), e.g. for a streak challenge
+func (s *StreakService) GetStreakInRange(userID int64, streakType models.StreakType, start *time.Time, end *time.Time) (*models.Streak, error) {
+	user, err := s.userDao.GetUserByID(userID)
+	if err != nil {
+		s.l.Printf("Error getting user %d for streaks: %v", userID, err)
+		return nil, err
+	}
+
+	var times []time.Time
+	if streakType == models.StreakTypeWeeklySummit {
+		times, err = s.summitTimes(userID, start, end)
+	} else {
+		times, err = s.activityTimes(userID, start, end)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	today := localToday(user.Timezone)
+	if end != nil && end.Before(today) {
+		// A finished range is judged as of its last day
+		today = *end
+	}
+
+	streak := computeStreak(streakType, times, time.Weekday(user.WeekStart), today)
+	return &streak, nil
+}
+
+// UpdatePreferences sets the timezone and week start used for streaks
+func (s *StreakService) UpdatePreferences(userID int64, timezone string, weekStart int) error {
+	if _, err := time.LoadLocation(timezone); err != nil || timezone == "" {
+		return ErrTimezoneInvalid
+	}
+	if weekStart < 0 || weekStart > 6 {
+		return ErrWeekStartInvalid
+	}
+	return s.userDao.UpdatePreferences(userID, timezone, weekStart)
+}
+
+func (s *StreakService) activityTimes(userID int64, start *time.Time, end *time.Time) ([]time.Time, error) {
+	activities, err := s.activityDao.GetActivitiesByUserIDAndDateRange(userID, start, end)
+	if err != nil {
+		s.l.Printf("Error getting activities for streaks: %v", err)
+		return nil, err
+	}
+	times := make([]time.Time, 0, len(activities))
+	for _, activity := range activities {
+		times = append(times, activity.StartDate)
+	}
+	return times, nil
+}
+
+func (s *StreakService) summitTimes(userID int64, start *time.Time, end *time.Time) ([]time.Time, error) {
+	from := time.Time{}
+	if start != nil {
+		from = *start
+	}
+	to := time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+	if end != nil {
+		to = *end
+	}
+
+	summits, err := s.userPeaksDao.GetUserSummitsInDateRangeAll(userID, from, to)
+	if err != nil {
+		s.l.Printf("Error getting summits for streaks: %v", err)
+		return nil, err
+	}
+	times := make([]time.Time, 0, len(summits))
+	for _, summit := range summits {
+		times = append(times, summit.SummitedAt)
+	}
+	return times, nil
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"run-goals/models"
+	"sort"
+	"time"
+)
+
+// streakIsWeekly reports whether the streak counts weeks rather than days
+func streakIsWeekly(streakType models.StreakType) bool {
+	return streakType == models.StreakTypeWeeklyActivity || streakType == models.StreakTypeWeeklySummit
+}
+
+func validStreakType(streakType models.StreakType) bool {
+	switch streakType {
+	case models.StreakTypeDailyActivity, models.StreakTypeWeeklyActivity, models.StreakTypeWeeklySummit:
+		return true
+	}
+	return false
+}
+
+// streakPeriodStart returns the start of the day or week containing t.
+// Times are local wall-clock times, so the date is taken as-is.
+func streakPeriodStart(t time.Time, weekly bool, weekStart time.Weekday) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if !weekly {
+		return day
+	}
+	offset := (int(day.Weekday()) - int(weekStart) + 7) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+func nextStreakPeriod(period time.Time, weekly bool) time.Time {
+	if weekly {
+		return period.AddDate(0, 0, 7)
+	}
+	return period.AddDate(0, 0, 1)
+}
+
+// localToday returns the current wall-clock time in the user's timezone
+func localToday(timezone string) time.Time {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	now := time.Now().In(loc)
+	return time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), now.Minute(), now.Second(), 0, time.UTC)
+}
+
+// computeStreak works out the current and longest runs of consecutive periods
+// containing at least one of the given times. The current streak is still
+// alive if the latest period is this one or the one before, since the current
+// period isn't over yet.
+func computeStreak(streakType models.StreakType, times []time.Time, weekStart time.Weekday, today time.Time) models.Streak {
+	streak := models.Streak{Type: streakType}
+	weekly := streakIsWeekly(streakType)
+
+	seen := map[time.Time]bool{}
+	var periods []time.Time
+	for _, t := range times {
+		period := streakPeriodStart(t, weekly, weekStart)
+		if !seen[period] {
+			seen[period] = true
+			periods = append(periods, period)
+		}
+	}
+	if len(periods) == 0 {
+		return streak
+	}
+	sort.Slice(periods, func(i, j int) bool { return periods[i].Before(periods[j]) })
+
+	runStart := periods[0]
+	runLength := 0
+	for i, period := range periods {
+		if i > 0 && period.Equal(nextStreakPeriod(periods[i-1], weekly)) {
+			runLength++
+		} else {
+			runStart = period
+			runLength = 1
+		}
+		if runLength > streak.Longest {
+			start, end := runStart, period
+			streak.Longest = runLength
+			streak.LongestStart = &start
+			streak.LongestEnd = &end
+		}
+	}
+
+	last := periods[len(periods)-1]
+	streak.LastPeriod = &last
+
+	currentPeriod := streakPeriodStart(today, weekly, weekStart)
+	if last.Equal(currentPeriod) || nextStreakPeriod(last, weekly).Equal(currentPeriod) {
+		start := runStart
+		streak.Current = runLength
+		streak.CurrentStart = &start
+	}
+
+	return streak
+}
+
+// streakPeriodsBetween counts the days or weeks from start to end inclusive
+func streakPeriodsBetween(start time.Time, end time.Time, weekly bool, weekStart time.Weekday) int {
+	count := 0
+	last := streakPeriodStart(end, weekly, weekStart)
+	for period := streakPeriodStart(start, weekly, weekStart); !period.After(last); period = nextStreakPeriod(period, weekly) {
+		count++
+	}
+	return count
+}
@@ -0,0 +1,324 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"math"
+	"run-goals/config"
+	"run-goals/daos"
+	"run-goals/geo"
+	"run-goals/models"
+	"strconv"
+	"time"
+
+	"github.com/twpayne/go-polyline"
+)
+
+var (
+	ErrSummitNotFound        = errors.New("summit not found")
+	ErrSummitAlreadyRecorded = errors.New("this summit is already recorded")
+	ErrSummitClaimNotFound   = errors.New("summit claim not found")
+	ErrSummitClaimPending    = errors.New("a claim for this summit is already pending")
+	ErrSummitClaimReviewed   = errors.New("summit claim has already been reviewed")
+	ErrNotClaimReviewer      = errors.New("only admins of the user's groups or owners of their challenges can review this claim")
+)
+
+// defaultClaimToleranceMeters is how close a route must come to a claimed
+// peak for the claim to be approved without review. It's wider than
+// detection's threshold since the claim is about summits detection missed.
+const defaultClaimToleranceMeters = 250.0
+
+type SummitCorrectionService struct {
+	l                   *log.Logger
+	config              *config.Config
+	summitCorrectionDao *daos.SummitCorrectionDao
+	userPeaksDao        *daos.UserPeaksDao
+	peaksDao            *daos.PeaksDao
+	activityDao         *daos.ActivityDao
+	userDao             *daos.UserDao
+	challengeService    *ChallengeService
+	achievementService  *AchievementService
+	groupWebhookService *GroupWebhookService
+	liveService         *LiveService
+}
+
+func NewSummitCorrectionService(
+	l *log.Logger,
+	config *config.Config,
+	summitCorrectionDao *daos.SummitCorrectionDao,
+	userPeaksDao *daos.UserPeaksDao,
+	peaksDao *daos.PeaksDao,
+	activityDao *daos.ActivityDao,
+	userDao *daos.UserDao,
+	challengeService *ChallengeService,
+	achievementService *AchievementService,
+	groupWebhookService *GroupWebhookService,
+	liveService *LiveService,
+) *SummitCorrectionService {
+	return &SummitCorrectionService{
+		l:                   l,
+		config:              config,
+		summitCorrectionDao: summitCorrectionDao,
+		userPeaksDao:        userPeaksDao,
+		peaksDao:            peaksDao,
+		activityDao:         activityDao,
+		userDao:             userDao,
+		challengeService:    challengeService,
+		achievementService:  achievementService,
+		groupWebhookService: groupWebhookService,
+		liveService:         liveService,
+	}
+}
+
+// ClaimTolerance is the configured auto-approval distance in meters
+func (s *SummitCorrectionService) ClaimTolerance() float64 {
+	meters, err := strconv.ParseFloat(s.config.Summit.ClaimToleranceMeters, 64)
+	if err != nil || meters <= 0 {
+		return defaultClaimToleranceMeters
+	}
+	return meters
+}
+
+// ==================== Dismissals ====================
+
+// DismissSummit removes a summit the user says didn't happen and takes back
+// any challenge credit it earned. Detection won't add it back.
+func (s *SummitCorrectionService) DismissSummit(userID int64, peakID int64, activityID int64, reason *string) error {
+	dismissed, err := s.summitCorrectionDao.DismissSummit(userID, peakID, activityID, reason)
+	if err != nil {
+		return err
+	}
+	if !dismissed {
+		return ErrSummitNotFound
+	}
+	s.l.Printf("Summit dismissed: user=%d peak=%d activity=%d", userID, peakID, activityID)
+	s.liveService.PublishMemberGroups(userID, models.LiveEventSummit)
+
+	if err := s.challengeService.RevokeSummit(userID, peakID, activityID); err != nil {
+		s.l.Printf("Failed to revoke challenge credit for dismissed summit: %v", err)
+	}
+	return nil
+}
+
+func (s *SummitCorrectionService) GetDismissals(userID int64) ([]models.SummitDismissal, error) {
+	return s.summitCorrectionDao.GetDismissalsByUser(userID)
+}
+
+// ==================== Claims ====================
+
+// Claim records a summit detection missed on one of the user's activities.
+// If the route passes within the claim tolerance of the peak the claim is
+// approved straight away, otherwise it waits for review.
+func (s *SummitCorrectionService) Claim(userID int64, peakID int64, activityID int64, note *string) (*models.SummitClaim, error) {
+	activity, err := s.activityDao.GetActivityByID(activityID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrActivityNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if activity.UserID != userID {
+		return nil, ErrActivityNotFound
+	}
+
+	peak, err := s.peaksDao.GetPeakByID(peakID)
+	if err != nil {
+		return nil, err
+	}
+	if peak == nil {
+		return nil, ErrPeakNotFound
+	}
+	// Claims on a merged duplicate go to the peak it was merged into
+	if peak.MergedIntoID != nil {
+		if peak, err = s.peaksDao.GetPeakByID(*peak.MergedIntoID); err != nil {
+			return nil, err
+		}
+		if peak == nil {
+			return nil, ErrPeakNotFound
+		}
+	}
+
+	recorded, err := s.userPeaksDao.GetActivitySummits(activityID)
+	if err != nil {
+		return nil, err
+	}
+	for _, summit := range recorded {
+		if summit.PeakID == peak.ID {
+			return nil, ErrSummitAlreadyRecorded
+		}
+	}
+
+	claim := models.SummitClaim{
+		UserID:     userID,
+		PeakID:     peak.ID,
+		ActivityID: activityID,
+		Note:       note,
+	}
+	var coords [][]float64
+	if activity.MapPolyline != "" {
+		coords, _, err = polyline.DecodeCoords([]byte(activity.MapPolyline))
+		if err != nil {
+			coords = nil
+		}
+	}
+	if len(coords) > 0 {
+		distance := math.Round(geo.DistanceToRouteMeters(coords, peak.Latitude, peak.Longitude))
+		claim.DistanceMeters = &distance
+	}
+
+	id, err := s.summitCorrectionDao.CreateClaim(claim)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSummitClaimPending
+	}
+	if err != nil {
+		return nil, err
+	}
+	claim.ID = *id
+	claim.Status = models.SummitClaimStatusPending
+
+	if claim.DistanceMeters != nil && *claim.DistanceMeters <= s.ClaimTolerance() {
+		if err := s.approve(claim, activity, *peak, coords, nil, nil); err != nil {
+			return nil, err
+		}
+		claim.Status = models.SummitClaimStatusApproved
+		claim.AutoApproved = true
+	}
+	return &claim, nil
+}
+
+func (s *SummitCorrectionService) GetMyClaims(userID int64) ([]models.SummitClaim, error) {
+	return s.summitCorrectionDao.GetClaimsByUser(userID)
+}
+
+// GetQueue returns the pending claims the reviewer may act on
+func (s *SummitCorrectionService) GetQueue(reviewerID int64) ([]models.SummitClaimForReview, error) {
+	user, err := s.userDao.GetUserByID(reviewerID)
+	if err != nil {
+		return nil, err
+	}
+	return s.summitCorrectionDao.GetPendingClaimsForReviewer(reviewerID, user.IsAdmin)
+}
+
+// ApproveClaim adds a pending claim's summit and credits it to the user's
+// challenges
+func (s *SummitCorrectionService) ApproveClaim(claimID int64, reviewerID int64, reviewerNote *string) error {
+	claim, err := s.getReviewableClaim(claimID, reviewerID)
+	if err != nil {
+		return err
+	}
+
+	activity, err := s.activityDao.GetActivityByID(claim.ActivityID)
+	if err != nil {
+		return err
+	}
+	peak, err := s.peaksDao.GetPeakByID(claim.PeakID)
+	if err != nil {
+		return err
+	}
+	if peak == nil {
+		return ErrPeakNotFound
+	}
+	var coords [][]float64
+	if activity.MapPolyline != "" {
+		coords, _, _ = polyline.DecodeCoords([]byte(activity.MapPolyline))
+	}
+
+	return s.approve(*claim, activity, *peak, coords, &reviewerID, reviewerNote)
+}
+
+func (s *SummitCorrectionService) RejectClaim(claimID int64, reviewerID int64, reviewerNote *string) error {
+	if _, err := s.getReviewableClaim(claimID, reviewerID); err != nil {
+		return err
+	}
+	err := s.summitCorrectionDao.RejectClaim(claimID, reviewerID, reviewerNote)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrSummitClaimReviewed
+	}
+	return err
+}
+
+// approve records the claimed summit, timed by where the route passes
+// closest to the peak when it passes close enough, then credits challenges
+// and checks achievements as detection would
+func (s *SummitCorrectionService) approve(
+	claim models.SummitClaim,
+	activity models.Activity,
+	peak models.Peak,
+	coords [][]float64,
+	reviewerID *int64,
+	reviewerNote *string,
+) error {
+	userPeak := models.UserPeak{
+		UserID:     claim.UserID,
+		PeakID:     claim.PeakID,
+		ActivityID: claim.ActivityID,
+		SummitedAt: activity.StartDate,
+	}
+	// Timing works in degrees like detection does
+	thresholdDegrees := s.ClaimTolerance() / 111320
+	if offset, ok := summitOffsetEstimated(coords, activity.MovingTime, peak, thresholdDegrees); ok {
+		summitTime := activity.StartDate.Add(time.Duration(offset) * time.Second)
+		elapsed := offset
+		source := models.SummitTimingSourceEstimated
+		userPeak.SummitTime = &summitTime
+		userPeak.ElapsedSeconds = &elapsed
+		userPeak.TimingSource = &source
+	}
+
+	err := s.summitCorrectionDao.ApproveClaim(claim.ID, reviewerID, reviewerNote, userPeak)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrSummitClaimReviewed
+	}
+	if err != nil {
+		return err
+	}
+	s.l.Printf("Summit claim %d approved: user=%d peak=%d activity=%d", claim.ID, claim.UserID, claim.PeakID, claim.ActivityID)
+
+	s.liveService.PublishMemberGroups(claim.UserID, models.LiveEventSummit)
+	err = s.groupWebhookService.PublishSummit(claim.UserID, peak, &activity, userPeak.SummitMoment())
+	if err != nil {
+		s.l.Printf("Failed to publish claimed summit to group webhooks: %v", err)
+	}
+	err = s.challengeService.ProcessActivityForChallenges(claim.UserID, claim.PeakID, claim.ActivityID, userPeak.SummitMoment())
+	if err != nil {
+		s.l.Printf("Failed to process challenges for claimed summit: %v", err)
+	}
+	if _, err := s.achievementService.EvaluateUser(claim.UserID); err != nil {
+		s.l.Printf("Failed to evaluate achievements for user %d: %v", claim.UserID, err)
+	}
+	return nil
+}
+
+// getReviewableClaim returns a pending claim the reviewer is allowed to act on
+func (s *SummitCorrectionService) getReviewableClaim(claimID int64, reviewerID int64) (*models.SummitClaim, error) {
+	claim, err := s.summitCorrectionDao.GetClaimByID(claimID)
+	if err != nil {
+		return nil, err
+	}
+	if claim == nil {
+		return nil, ErrSummitClaimNotFound
+	}
+	if claim.Status != models.SummitClaimStatusPending {
+		return nil, ErrSummitClaimReviewed
+	}
+	if claim.UserID == reviewerID {
+		return nil, ErrNotClaimReviewer
+	}
+
+	user, err := s.userDao.GetUserByID(reviewerID)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsAdmin {
+		return claim, nil
+	}
+	allowed, err := s.summitCorrectionDao.CanReviewClaims(reviewerID, claim.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, ErrNotClaimReviewer
+	}
+	return claim, nil
+}
@@ -1,19 +1,38 @@
 package services
 
 import (
+	"errors"
 	"log"
 	"run-goals/daos"
+	"run-goals/geo"
+	"run-goals/models"
+	"sort"
+	"time"
+)
+
+var (
+	ErrFavouriteNotFound    = errors.New("peak is not in the wishlist")
+	ErrWishlistOrderInvalid = errors.New("order must list every wishlist peak exactly once")
 )
 
 type SummitFavouritesService struct {
-	logger *log.Logger
-	dao    *daos.SummitFavouritesDao
+	logger       *log.Logger
+	dao          *daos.SummitFavouritesDao
+	peaksDao     *daos.PeaksDao
+	userPeaksDao *daos.UserPeaksDao
 }
 
-func NewSummitFavouritesService(logger *log.Logger, dao *daos.SummitFavouritesDao) *SummitFavouritesService {
+func NewSummitFavouritesService(
+	logger *log.Logger,
+	dao *daos.SummitFavouritesDao,
+	peaksDao *daos.PeaksDao,
+	userPeaksDao *daos.UserPeaksDao,
+) *SummitFavouritesService {
 	return &SummitFavouritesService{
-		logger: logger,
-		dao:    dao,
+		logger:       logger,
+		dao:          dao,
+		peaksDao:     peaksDao,
+		userPeaksDao: userPeaksDao,
 	}
 }
 
@@ -36,3 +55,127 @@ func (s *SummitFavouritesService) RemoveFavourite(userID int64, peakID int64) er
 func (s *SummitFavouritesService) IsFavourite(userID int64, peakID int64) (bool, error) {
 	return s.dao.IsFavourite(userID, peakID)
 }
+
+// GetWishlist returns the user's favourites in order with their peaks and
+// whether each has been bagged since it was favourited
+func (s *SummitFavouritesService) GetWishlist(userID int64) ([]models.WishlistItem, error) {
+	return s.dao.GetWishlist(userID)
+}
+
+// UpdatePlan sets the notes and target date for a wishlist peak
+func (s *SummitFavouritesService) UpdatePlan(userID int64, peakID int64, notes *string, targetDate *time.Time) error {
+	if notes != nil && *notes == "" {
+		notes = nil
+	}
+	if targetDate != nil {
+		date := time.Date(targetDate.Year(), targetDate.Month(), targetDate.Day(), 0, 0, 0, 0, time.UTC)
+		targetDate = &date
+	}
+
+	updated, err := s.dao.UpdatePlan(userID, peakID, notes, targetDate)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return ErrFavouriteNotFound
+	}
+	return nil
+}
+
+// Reorder sets the wishlist order. peakIDs must contain every favourite once.
+func (s *SummitFavouritesService) Reorder(userID int64, peakIDs []int64) error {
+	current, err := s.dao.GetAllByUser(userID)
+	if err != nil {
+		return err
+	}
+	if len(current) != len(peakIDs) {
+		return ErrWishlistOrderInvalid
+	}
+
+	remaining := map[int64]bool{}
+	for _, peakID := range current {
+		remaining[peakID] = true
+	}
+	for _, peakID := range peakIDs {
+		if !remaining[peakID] {
+			return ErrWishlistOrderInvalid
+		}
+		delete(remaining, peakID)
+	}
+
+	return s.dao.Reorder(userID, peakIDs)
+}
+
+// GetSuggestions returns the nearest peaks the user has never summited within
+// radiusMeters of a location, closest first
+func (s *SummitFavouritesService) GetSuggestions(userID int64, lat float64, lon float64, radiusMeters float64, limit int) ([]models.WishlistSuggestion, error) {
+	minLat, maxLat, minLon, maxLon := geo.BoundingBox(lat, lon, radiusMeters)
+	peaks, err := s.peaksDao.GetPeaksBetweenLatLon(minLat, maxLat, minLon, maxLon)
+	if err != nil {
+		return nil, err
+	}
+
+	summited, err := s.userPeaksDao.GetUserPeaksJoinByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	climbed := map[int64]bool{}
+	for _, summit := range summited {
+		climbed[summit.PeakID] = true
+	}
+
+	favourites, err := s.dao.GetAllByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	wishlist := map[int64]bool{}
+	for _, peakID := range favourites {
+		wishlist[peakID] = true
+	}
+
+	suggestions := []models.WishlistSuggestion{}
+	for _, peak := range peaks {
+		if climbed[peak.ID] {
+			continue
+		}
+		distance := geo.HaversineMeters(lat, lon, peak.Latitude, peak.Longitude)
+		if distance > radiusMeters {
+			continue
+		}
+		suggestions = append(suggestions, models.WishlistSuggestion{
+			Peak:           peak,
+			DistanceMeters: round2(distance),
+			OnWishlist:     wishlist[peak.ID],
+		})
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		return suggestions[i].DistanceMeters < suggestions[j].DistanceMeters
+	})
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions, nil
+}
+
+// GetClusters groups the still-to-do wishlist peaks into outings, where each
+// peak is within maxMeters of another in the same group. Largest groups first.
+func (s *SummitFavouritesService) GetClusters(userID int64, maxMeters float64) ([]models.WishlistCluster, error) {
+	items, err := s.dao.GetWishlist(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	toDo := []models.WishlistItem{}
+	for _, item := range items {
+		if item.Status == models.WishlistStatusToDo {
+			toDo = append(toDo, item)
+		}
+	}
+
+	clusters := clusterWishlist(toDo, maxMeters)
+	sort.SliceStable(clusters, func(i, j int) bool {
+		return len(clusters[i].Items) > len(clusters[j].Items)
+	})
+	return clusters, nil
+}
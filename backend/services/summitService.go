@@ -8,7 +8,9 @@ import (
 	"run-goals/config"
 	"run-goals/daos"
 	"run-goals/models"
+	"sort"
 	"strconv"
+	"time"
 
 	"github.com/twpayne/go-polyline"
 )
@@ -26,7 +28,14 @@ type SummitService struct {
 	peaksDao        *daos.PeaksDao
 	userPeaksDao    *daos.UserPeaksDao
 	activityDao     *daos.ActivityDao
+	userDao         *daos.UserDao
+	stravaService   *StravaService
 	challengeService *ChallengeService
+	achievementService *AchievementService
+	summitCorrectionDao *daos.SummitCorrectionDao
+	notificationService *NotificationService
+	groupWebhookService *GroupWebhookService
+	liveService *LiveService
 }
 
 func NewSummitService(
@@ -35,7 +44,14 @@ func NewSummitService(
 	peaksDao *daos.PeaksDao,
 	userPeaksDao *daos.UserPeaksDao,
 	activityDao *daos.ActivityDao,
+	userDao *daos.UserDao,
+	stravaService *StravaService,
 	challengeService *ChallengeService,
+	achievementService *AchievementService,
+	summitCorrectionDao *daos.SummitCorrectionDao,
+	notificationService *NotificationService,
+	groupWebhookService *GroupWebhookService,
+	liveService *LiveService,
 ) *SummitService {
 	return &SummitService{
 		l:               l,
@@ -43,7 +59,14 @@ func NewSummitService(
 		peaksDao:        peaksDao,
 		userPeaksDao:    userPeaksDao,
 		activityDao:     activityDao,
+		userDao:         userDao,
+		stravaService:   stravaService,
 		challengeService: challengeService,
+		achievementService: achievementService,
+		summitCorrectionDao: summitCorrectionDao,
+		notificationService: notificationService,
+		groupWebhookService: groupWebhookService,
+		liveService: liveService,
 	}
 }
 
@@ -181,18 +204,45 @@ func (s *SummitService) PopulateSummitedPeaks() error {
 
 	s.l.Printf("Processing summit detection for %d activities", len(activities))
 
+	// Achievements aren't evaluated here, the sync evaluates every user once
+	// it's done. Live updates go out once per user rather than per activity.
+	updatedUsers := map[int64]bool{}
 	for _, activity := range activities {
-		if err := s.CalculateSummitsForActivity(&activity); err != nil {
+		if err := s.calculateSummits(&activity); err != nil {
 			s.l.Printf("Failed to calculate summits for activity %d: %v", activity.ID, err)
 			// Continue with other activities even if one fails
+			continue
+		}
+		updatedUsers[activity.UserID] = true
+	}
+	if s.liveService != nil {
+		for userID := range updatedUsers {
+			s.liveService.PublishMemberGroups(userID, models.LiveEventProgress)
 		}
 	}
 
 	return nil
 }
 
-// CalculateSummitsForActivity processes a single activity for summit detection
+// CalculateSummitsForActivity processes a single activity for summit detection,
+// then checks whether the activity earned the user any achievements
 func (s *SummitService) CalculateSummitsForActivity(activity *models.Activity) error {
+	if err := s.calculateSummits(activity); err != nil {
+		return err
+	}
+	if s.liveService != nil {
+		s.liveService.PublishMemberGroups(activity.UserID, models.LiveEventProgress)
+	}
+
+	if s.achievementService != nil {
+		if _, err := s.achievementService.EvaluateUser(activity.UserID); err != nil {
+			s.l.Printf("Failed to evaluate achievements for user %d: %v", activity.UserID, err)
+		}
+	}
+	return nil
+}
+
+func (s *SummitService) calculateSummits(activity *models.Activity) error {
 	summitThresholdMeters, err := strconv.ParseFloat(s.config.Summit.SummitThresholdMeters, 64)
 	if err != nil {
 		return fmt.Errorf("invalid summit threshold config: %w", err)
@@ -215,34 +265,161 @@ func (s *SummitService) CalculateSummitsForActivity(activity *models.Activity) e
 		return s.activityDao.UpsertActivity(activity)
 	}
 
-	var hasSummit bool
+	// Summits the user dismissed on this activity stay dismissed
+	dismissed, err := s.summitCorrectionDao.GetDismissedPeakIDs(activity.ID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch dismissed summits: %w", err)
+	}
+
+	var visited []models.Peak
+	visitedByID := map[int64]models.Peak{}
 	for _, peak := range peaks {
+		if dismissed[peak.ID] {
+			continue
+		}
 		if s.IsPeakVisited(activity.MapPolyline, peak.Latitude, peak.Longitude, summitThresholdMeters) {
-			userPeak := models.UserPeak{
-				UserID:     activity.UserID,
-				PeakID:     peak.ID,
-				ActivityID: activity.ID,
-				SummitedAt: activity.StartDate,
+			visited = append(visited, peak)
+			visitedByID[peak.ID] = peak
+		}
+	}
+
+	summits := s.timeSummits(activity, visited, summitThresholdMeters)
+	for i := range summits {
+		userPeak := summits[i]
+		err = s.userPeaksDao.UpsertUserPeak(&userPeak)
+		if err != nil {
+			s.l.Printf("Failed to mark summit for user=%d peak=%d: %v", activity.UserID, userPeak.PeakID, err)
+			continue
+		}
+		peak := visitedByID[userPeak.PeakID]
+		s.l.Printf("Summit detected! user=%d peak=%d (%s) activity=%d", activity.UserID, userPeak.PeakID, peak.Name, activity.ID)
+
+		if s.notificationService != nil {
+			err = s.notificationService.NotifySummitDetected(activity.UserID, userPeak.PeakID, peak.Name, activity.ID, userPeak.SummitMoment())
+			if err != nil {
+				s.l.Printf("Failed to notify summit: %v", err)
 			}
-			err = s.userPeaksDao.UpsertUserPeak(&userPeak)
+		}
+
+		if s.groupWebhookService != nil {
+			err = s.groupWebhookService.PublishSummit(activity.UserID, peak, activity, userPeak.SummitMoment())
 			if err != nil {
-				s.l.Printf("Failed to mark summit for user=%d peak=%d: %v", activity.UserID, peak.ID, err)
-			} else {
-				s.l.Printf("Summit detected! user=%d peak=%d (%s) activity=%d", activity.UserID, peak.ID, peak.Name, activity.ID)
-
-				// Also credit this summit to any challenges
-				if s.challengeService != nil {
-					err = s.challengeService.ProcessActivityForChallenges(activity.UserID, peak.ID, activity.ID, activity.StartDate)
-					if err != nil {
-						s.l.Printf("Failed to process challenges for summit: %v", err)
-					}
-				}
+				s.l.Printf("Failed to publish summit to group webhooks: %v", err)
+			}
+		}
+
+		// Also credit this summit to any challenges
+		if s.challengeService != nil {
+			err = s.challengeService.ProcessActivityForChallenges(activity.UserID, userPeak.PeakID, activity.ID, userPeak.SummitMoment())
+			if err != nil {
+				s.l.Printf("Failed to process challenges for summit: %v", err)
 			}
-			hasSummit = true
 		}
 	}
+	hasSummit := len(summits) > 0
+	if hasSummit && s.liveService != nil {
+		s.liveService.PublishMemberGroups(activity.UserID, models.LiveEventSummit)
+	}
+	if !hasSummit {
+		// Approved claims on the activity aren't found by detection
+		recorded, err := s.userPeaksDao.GetActivitySummits(activity.ID)
+		if err != nil {
+			return fmt.Errorf("failed to fetch recorded summits: %w", err)
+		}
+		hasSummit = len(recorded) > 0
+	}
 
 	activity.HasSummit = hasSummit
 	activity.SummitsCalculated = true
 	return s.activityDao.UpsertActivity(activity)
 }
+
+// timeSummits builds the summits for the visited peaks in the order they were
+// reached, with elapsed and split times. Timings come from the activity's
+// time stream when available, otherwise they're estimated from the polyline.
+func (s *SummitService) timeSummits(activity *models.Activity, visited []models.Peak, thresholdMeters float64) []models.UserPeak {
+	summits := make([]models.UserPeak, 0, len(visited))
+	if len(visited) == 0 {
+		return summits
+	}
+
+	streams := s.fetchStreams(activity)
+	coords, _, err := polyline.DecodeCoords([]byte(activity.MapPolyline))
+	if err != nil {
+		coords = nil
+	}
+
+	for _, peak := range visited {
+		userPeak := models.UserPeak{
+			UserID:     activity.UserID,
+			PeakID:     peak.ID,
+			ActivityID: activity.ID,
+			SummitedAt: activity.StartDate,
+		}
+
+		source := models.SummitTimingSourceStream
+		offset, ok := 0, false
+		if streams != nil {
+			offset, ok = summitOffsetFromStreams(streams, peak, thresholdMeters)
+		}
+		if !ok {
+			source = models.SummitTimingSourceEstimated
+			offset, ok = summitOffsetEstimated(coords, activity.MovingTime, peak, thresholdMeters)
+		}
+		if ok {
+			summitTime := activity.StartDate.Add(time.Duration(offset) * time.Second)
+			elapsed := offset
+			timingSource := source
+			userPeak.SummitTime = &summitTime
+			userPeak.ElapsedSeconds = &elapsed
+			userPeak.TimingSource = &timingSource
+		}
+		summits = append(summits, userPeak)
+	}
+
+	// Untimed summits keep their place at the end
+	sort.SliceStable(summits, func(i, j int) bool {
+		if summits[i].ElapsedSeconds == nil || summits[j].ElapsedSeconds == nil {
+			return summits[i].ElapsedSeconds != nil && summits[j].ElapsedSeconds == nil
+		}
+		return *summits[i].ElapsedSeconds < *summits[j].ElapsedSeconds
+	})
+
+	for i := 1; i < len(summits); i++ {
+		prev, curr := summits[i-1], &summits[i]
+		if prev.ElapsedSeconds == nil || curr.ElapsedSeconds == nil {
+			break
+		}
+		split := *curr.ElapsedSeconds - *prev.ElapsedSeconds
+		previousPeakID := prev.PeakID
+		curr.SplitSeconds = &split
+		curr.PreviousPeakID = &previousPeakID
+	}
+
+	return summits
+}
+
+// fetchStreams gets the activity's time stream from Strava, or nil if
+// disabled or unavailable
+func (s *SummitService) fetchStreams(activity *models.Activity) *models.StravaStreams {
+	if s.config.Summit.UseActivityStreams == "false" || s.stravaService == nil || activity.StravaActivityId == 0 {
+		return nil
+	}
+
+	user, err := s.userDao.GetUserByID(activity.UserID)
+	if err != nil {
+		s.l.Printf("Failed to get user %d for activity streams: %v", activity.UserID, err)
+		return nil
+	}
+	if err := s.stravaService.EnsureValidToken(user); err != nil {
+		s.l.Printf("Failed to refresh token for user %d: %v", activity.UserID, err)
+		return nil
+	}
+
+	streams, err := s.stravaService.FetchActivityStreams(user.AccessToken, activity.StravaActivityId)
+	if err != nil {
+		s.l.Printf("Failed to fetch streams for activity %d, estimating summit times: %v", activity.ID, err)
+		return nil
+	}
+	return streams
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"math"
+	"run-goals/geo"
+	"run-goals/models"
+)
+
+// trackPosition is a point along a track: segment index plus fraction through it
+type trackPosition struct {
+	segment  int
+	fraction float64
+}
+
+// firstPassPosition finds where the track came closest to the peak on its first
+// pass within the threshold. Uses the same degree-space distance as IsPeakVisited.
+func firstPassPosition(coords [][]float64, peakLat float64, peakLon float64, thresholdMeters float64) (trackPosition, bool) {
+	best := trackPosition{}
+	bestDist := math.MaxFloat64
+	inPass := false
+
+	for i := 0; i < len(coords)-1; i++ {
+		dist, t := projectOntoSegment(peakLat, peakLon, coords[i][0], coords[i][1], coords[i+1][0], coords[i+1][1])
+		if dist < thresholdMeters {
+			inPass = true
+			if dist < bestDist {
+				bestDist = dist
+				best = trackPosition{segment: i, fraction: t}
+			}
+		} else if inPass {
+			// Left the summit area, later passes are repeat visits
+			break
+		}
+	}
+
+	return best, inPass
+}
+
+// projectOntoSegment returns the distance from P to segment AB and how far along AB
+// the closest point lies (0 at A, 1 at B)
+func projectOntoSegment(px, py, ax, ay, bx, by float64) (float64, float64) {
+	ABx := bx - ax
+	ABy := by - ay
+
+	lenABsq := ABx*ABx + ABy*ABy
+	if lenABsq == 0 {
+		return distance(px, py, ax, ay), 0
+	}
+
+	t := ((px-ax)*ABx + (py-ay)*ABy) / lenABsq
+	if t < 0 {
+		t = 0
+	} else if t > 1 {
+		t = 1
+	}
+
+	return distance(px, py, ax+t*ABx, ay+t*ABy), t
+}
+
+// summitOffsetFromStreams works out seconds from activity start to the summit
+// using the recorded time stream
+func summitOffsetFromStreams(streams *models.StravaStreams, peak models.Peak, thresholdMeters float64) (int, bool) {
+	coords := streams.LatLng.Data
+	times := streams.Time.Data
+	if len(coords) < 2 || len(coords) != len(times) {
+		return 0, false
+	}
+
+	pos, ok := firstPassPosition(coords, peak.Latitude, peak.Longitude, thresholdMeters)
+	if !ok {
+		return 0, false
+	}
+
+	start := float64(times[pos.segment])
+	end := float64(times[pos.segment+1])
+	return int(math.Round(start + pos.fraction*(end-start))), true
+}
+
+// summitOffsetEstimated estimates seconds from activity start to the summit by
+// assuming constant pace along the summary polyline
+func summitOffsetEstimated(coords [][]float64, movingTime float64, peak models.Peak, thresholdMeters float64) (int, bool) {
+	if len(coords) < 2 || movingTime <= 0 {
+		return 0, false
+	}
+
+	pos, ok := firstPassPosition(coords, peak.Latitude, peak.Longitude, thresholdMeters)
+	if !ok {
+		return 0, false
+	}
+
+	cumulative := geo.CumulativeDistances(coords)
+	total := cumulative[len(cumulative)-1]
+	if total == 0 {
+		return 0, false
+	}
+
+	along := cumulative[pos.segment] + pos.fraction*(cumulative[pos.segment+1]-cumulative[pos.segment])
+	return int(math.Round(along / total * movingTime)), true
+}
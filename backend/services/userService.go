@@ -9,7 +9,6 @@ import (
 type UserServiceInterface interface {
 	GetUserByID(userID int64) (*models.User, error)
 	GetUserProfile(userID int64) (*models.User, error)
-	DeleteUserAccount(stravaAthleteID int64) error
 }
 
 type UserService struct {
@@ -60,12 +59,20 @@ func (s *UserService) UpdateUsername(userID int64, username string) error {
 	return nil
 }
 
-func (s *UserService) DeleteUserAccount(stravaAthleteID int64) error {
-	err := s.userDao.DeleteUserByStravaAthleteID(stravaAthleteID)
+func (s *UserService) UpdateShowInLeaderboards(userID int64, show bool) error {
+	err := s.userDao.UpdateShowInLeaderboards(userID, show)
 	if err != nil {
-		s.l.Printf("Error deleting user account for strava_athlete_id %d: %v", stravaAthleteID, err)
+		s.l.Printf("Error updating show_in_leaderboards for user %d: %v", userID, err)
+		return err
+	}
+	return nil
+}
+
+func (s *UserService) UpdateFeedVisibility(userID int64, showInGroupFeeds bool, showInChallengeFeeds bool) error {
+	err := s.userDao.UpdateFeedVisibility(userID, showInGroupFeeds, showInChallengeFeeds)
+	if err != nil {
+		s.l.Printf("Error updating feed visibility for user %d: %v", userID, err)
 		return err
 	}
-	s.l.Printf("Successfully deleted user account for strava_athlete_id %d", stravaAthleteID)
 	return nil
 }
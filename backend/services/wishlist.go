@@ -0,0 +1,55 @@
+package services
+
+import (
+	"run-goals/geo"
+	"run-goals/models"
+)
+
+// clusterWishlist groups items so that every peak is within maxMeters of at
+// least one other peak in its group (single-linkage). Groups come back in the
+// order of their first item, and items keep their wishlist order.
+func clusterWishlist(items []models.WishlistItem, maxMeters float64) []models.WishlistCluster {
+	sets := newDisjointSet(len(items))
+
+	for i := range items {
+		for j := i + 1; j < len(items); j++ {
+			if wishlistDistance(items[i], items[j]) <= maxMeters {
+				sets.union(i, j)
+			}
+		}
+	}
+
+	clusterIndex := map[int]int{}
+	clusters := []models.WishlistCluster{}
+	for i, item := range items {
+		root := sets.find(i)
+		idx, ok := clusterIndex[root]
+		if !ok {
+			idx = len(clusters)
+			clusterIndex[root] = idx
+			clusters = append(clusters, models.WishlistCluster{Items: []models.WishlistItem{}})
+		}
+		clusters[idx].Items = append(clusters[idx].Items, item)
+	}
+
+	for i := range clusters {
+		c := &clusters[i]
+		for j, a := range c.Items {
+			c.CenterLatitude += a.Peak.Latitude
+			c.CenterLongitude += a.Peak.Longitude
+			for _, b := range c.Items[j+1:] {
+				if d := wishlistDistance(a, b); d > c.SpanMeters {
+					c.SpanMeters = d
+				}
+			}
+		}
+		c.CenterLatitude /= float64(len(c.Items))
+		c.CenterLongitude /= float64(len(c.Items))
+		c.SpanMeters = round2(c.SpanMeters)
+	}
+	return clusters
+}
+
+func wishlistDistance(a models.WishlistItem, b models.WishlistItem) float64 {
+	return geo.HaversineMeters(a.Peak.Latitude, a.Peak.Longitude, b.Peak.Latitude, b.Peak.Longitude)
+}
@@ -9,12 +9,13 @@ import (
 )
 
 type StravaActivityFetcher struct {
-	stravaService    *services.StravaService
-	summitService    *services.SummitService
-	challengeService *services.ChallengeService
-	activitiesDao    *daos.ActivityDao
-	userDao          *daos.UserDao
-	logger           *log.Logger
+	stravaService      *services.StravaService
+	summitService      *services.SummitService
+	challengeService   *services.ChallengeService
+	achievementService *services.AchievementService
+	activitiesDao      *daos.ActivityDao
+	userDao            *daos.UserDao
+	logger             *log.Logger
 }
 
 // NewStravaActivityFetcher initializes the fetcher.
@@ -22,17 +23,19 @@ func NewStravaActivityFetcher(
 	stravaService *services.StravaService,
 	summitService *services.SummitService,
 	challengeService *services.ChallengeService,
+	achievementService *services.AchievementService,
 	usersDao *daos.UserDao,
 	activitiesDao *daos.ActivityDao,
 	logger *log.Logger,
 ) *StravaActivityFetcher {
 	return &StravaActivityFetcher{
-		stravaService:    stravaService,
-		summitService:    summitService,
-		challengeService: challengeService,
-		activitiesDao:    activitiesDao,
-		userDao:          usersDao,
-		logger:           logger,
+		stravaService:      stravaService,
+		summitService:      summitService,
+		challengeService:   challengeService,
+		achievementService: achievementService,
+		activitiesDao:      activitiesDao,
+		userDao:            usersDao,
+		logger:             logger,
 	}
 }
 
@@ -111,4 +114,12 @@ func (s *StravaActivityFetcher) fetchActivities(recentOnly bool) {
 	} else {
 		s.logger.Println("Challenge progress refresh complete")
 	}
+
+	// Re-check achievements for everyone - streaks and challenge wins can change without a new summit
+	s.logger.Println("Evaluating achievements for all users...")
+	if _, err := s.achievementService.EvaluateAllUsers(); err != nil {
+		s.logger.Printf("Error evaluating achievements: %v", err)
+	} else {
+		s.logger.Println("Achievement evaluation complete")
+	}
 }
@@ -0,0 +1,57 @@
+-- Recurring challenge series
+-- A series holds a challenge template plus a recurrence rule. The scheduler
+-- creates a new challenge for each period and links it back to the series.
+
+CREATE TABLE IF NOT EXISTS challenge_series (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+
+    -- Ownership
+    created_by_user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    created_by_group_id BIGINT REFERENCES groups(id) ON DELETE SET NULL,
+
+    -- Template copied onto every instance
+    goal_type VARCHAR(20) NOT NULL DEFAULT 'specific_summits',
+    competition_mode VARCHAR(20) NOT NULL DEFAULT 'collaborative',
+    visibility VARCHAR(20) NOT NULL DEFAULT 'private',
+    target_value NUMERIC,           -- For distance/elevation (in meters)
+    target_summit_count INTEGER,    -- For summit_count
+    peak_ids BIGINT[],              -- For specific_summits
+    region VARCHAR(100),
+    difficulty VARCHAR(20),
+
+    -- Recurrence rule
+    -- 'weekly' | 'monthly' | 'yearly' | 'custom' (every interval_days days)
+    recurrence VARCHAR(20) NOT NULL,
+    interval_days INTEGER,
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ,
+    next_period_start TIMESTAMPTZ NOT NULL,
+    carry_over_participants BOOLEAN DEFAULT FALSE,
+    is_active BOOLEAN DEFAULT TRUE,
+
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT check_series_recurrence CHECK (recurrence IN ('weekly', 'monthly', 'yearly', 'custom')),
+    CONSTRAINT check_series_interval CHECK (recurrence <> 'custom' OR interval_days > 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_challenge_series_next_period ON challenge_series(next_period_start) WHERE is_active = TRUE;
+CREATE INDEX IF NOT EXISTS idx_challenge_series_user ON challenge_series(created_by_user_id);
+
+-- One row per challenge created from a series
+CREATE TABLE IF NOT EXISTS challenge_series_instances (
+    id BIGSERIAL PRIMARY KEY,
+    series_id BIGINT NOT NULL REFERENCES challenge_series(id) ON DELETE CASCADE,
+    challenge_id BIGINT NOT NULL REFERENCES challenges(id) ON DELETE CASCADE,
+    instance_number INTEGER NOT NULL,
+    period_start TIMESTAMPTZ NOT NULL,
+    period_end TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (challenge_id),
+    UNIQUE (series_id, instance_number)
+);
+
+CREATE INDEX IF NOT EXISTS idx_challenge_series_instances_series ON challenge_series_instances(series_id);
@@ -0,0 +1,18 @@
+-- Completion rules for specific_summits challenges
+-- 'any'             = any summit of a listed peak counts (previous behaviour)
+-- 'ordered'         = peaks must be summited in challenge_peaks.sort_order
+-- 'single_activity' = every peak must be bagged within one activity
+-- 'time_window'     = every peak must be bagged within max_window_hours
+ALTER TABLE challenges ADD COLUMN IF NOT EXISTS completion_rule VARCHAR(20) NOT NULL DEFAULT 'any';
+ALTER TABLE challenges ADD COLUMN IF NOT EXISTS max_window_hours NUMERIC;
+ALTER TABLE challenges ADD CONSTRAINT check_completion_rule
+    CHECK (completion_rule IN ('any', 'ordered', 'single_activity', 'time_window'));
+
+-- Series templates carry the rule onto each instance
+ALTER TABLE challenge_series ADD COLUMN IF NOT EXISTS completion_rule VARCHAR(20) NOT NULL DEFAULT 'any';
+ALTER TABLE challenge_series ADD COLUMN IF NOT EXISTS max_window_hours NUMERIC;
+
+-- Record what satisfied the challenge for each participant
+ALTER TABLE challenge_participants ADD COLUMN IF NOT EXISTS satisfied_by_activity_id BIGINT REFERENCES activity(id) ON DELETE SET NULL;
+ALTER TABLE challenge_participants ADD COLUMN IF NOT EXISTS satisfied_window_start TIMESTAMPTZ;
+ALTER TABLE challenge_participants ADD COLUMN IF NOT EXISTS satisfied_window_end TIMESTAMPTZ;
@@ -0,0 +1,17 @@
+-- Summit timings derived from activity track streams
+-- summit_time is the moment the summit was reached (summited_at stays the activity start)
+ALTER TABLE user_peaks ADD COLUMN IF NOT EXISTS summit_time TIMESTAMPTZ;
+ALTER TABLE user_peaks ADD COLUMN IF NOT EXISTS elapsed_seconds INTEGER;
+ALTER TABLE user_peaks ADD COLUMN IF NOT EXISTS split_seconds INTEGER;
+ALTER TABLE user_peaks ADD COLUMN IF NOT EXISTS previous_peak_id BIGINT REFERENCES peaks(id) ON DELETE SET NULL;
+ALTER TABLE user_peaks ADD COLUMN IF NOT EXISTS timing_source VARCHAR(20);
+
+-- Fastest ascent lookups per peak
+CREATE INDEX IF NOT EXISTS idx_user_peaks_peak_elapsed ON user_peaks(peak_id, elapsed_seconds) WHERE elapsed_seconds IS NOT NULL;
+
+-- Fastest time challenges rank by the best time rather than cumulative totals
+ALTER TABLE challenges DROP CONSTRAINT IF EXISTS check_goal_type;
+ALTER TABLE challenges ADD CONSTRAINT check_goal_type
+    CHECK (goal_type IN ('distance', 'elevation', 'summit_count', 'specific_summits', 'fastest_time'));
+
+ALTER TABLE challenge_participants ADD COLUMN IF NOT EXISTS best_time_seconds INTEGER;
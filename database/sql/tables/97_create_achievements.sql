@@ -0,0 +1,69 @@
+-- Achievements and badges
+-- Definitions are rules evaluated against each user's summit and activity
+-- history. New badges can be added by inserting a row, no code change needed.
+--   rule_type        threshold                 min_count
+--   unique_peaks     -                         distinct peaks summited
+--   peaks_above      elevation (m)             distinct peaks at or above threshold
+--   region_complete  -                         - (every peak in region, or in each region when NULL)
+--   highest_peak     -                         - (highest peak in region, or in each region when NULL)
+--   elevation_total  total gain (m)            -
+--   streak           -                         consecutive days with an activity
+--   early_bird       hour of day (local)       summits reached before that hour
+--   challenge_win    -                         competitive challenges won
+CREATE TABLE IF NOT EXISTS achievement_definitions (
+    id BIGSERIAL PRIMARY KEY,
+    key VARCHAR(50) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL,
+    category VARCHAR(20) NOT NULL,
+    rule_type VARCHAR(20) NOT NULL,
+    threshold NUMERIC,
+    min_count INTEGER NOT NULL DEFAULT 1,
+    region VARCHAR(100),
+    sort_order INTEGER NOT NULL DEFAULT 0,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT check_achievement_rule_type CHECK (rule_type IN (
+        'unique_peaks', 'peaks_above', 'region_complete', 'highest_peak',
+        'elevation_total', 'streak', 'early_bird', 'challenge_win'
+    ))
+);
+
+-- Awards. scope distinguishes per-region awards of the same definition.
+CREATE TABLE IF NOT EXISTS user_achievements (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    achievement_id BIGINT NOT NULL REFERENCES achievement_definitions(id) ON DELETE CASCADE,
+    scope VARCHAR(100) NOT NULL DEFAULT '',
+    activity_id BIGINT REFERENCES activity(id) ON DELETE SET NULL,   -- Activity that triggered the award
+    peak_id BIGINT REFERENCES peaks(id) ON DELETE SET NULL,
+    challenge_id BIGINT REFERENCES challenges(id) ON DELETE SET NULL,
+    awarded_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    UNIQUE (user_id, achievement_id, scope)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_achievements_user ON user_achievements(user_id);
+CREATE INDEX IF NOT EXISTS idx_user_achievements_awarded ON user_achievements(awarded_at DESC);
+
+INSERT INTO achievement_definitions (key, name, description, category, rule_type, threshold, min_count, sort_order) VALUES
+    ('first_summit',     'First Summit',       'Summit your first peak',                          'summits',    'unique_peaks',    NULL,   1,   10),
+    ('unique_peaks_10',  'Peak Bagger',        'Summit 10 different peaks',                       'summits',    'unique_peaks',    NULL,   10,  20),
+    ('unique_peaks_25',  'Summit Collector',   'Summit 25 different peaks',                       'summits',    'unique_peaks',    NULL,   25,  30),
+    ('unique_peaks_50',  'Mountain Goat',      'Summit 50 different peaks',                       'summits',    'unique_peaks',    NULL,   50,  40),
+    ('unique_peaks_100', 'Centurion',          'Summit 100 different peaks',                      'summits',    'unique_peaks',    NULL,   100, 50),
+    ('peaks_above_1000', 'Thousander',         'Summit a peak of 1000 m or higher',               'summits',    'peaks_above',     1000,   1,   60),
+    ('peaks_above_2000', 'High Country',       'Summit a peak of 2000 m or higher',               'summits',    'peaks_above',     2000,   1,   70),
+    ('region_complete',  'Region Complete',    'Summit every peak in a region',                   'regions',    'region_complete', NULL,   1,   80),
+    ('highest_peak',     'Top of the Region',  'Summit the highest peak in a region',             'regions',    'highest_peak',    NULL,   1,   90),
+    ('elevation_10k',    'Climber',            'Gain 10,000 m of elevation',                      'elevation',  'elevation_total', 10000,  1,   100),
+    ('elevation_50k',    'Sky Walker',         'Gain 50,000 m of elevation',                      'elevation',  'elevation_total', 50000,  1,   110),
+    ('elevation_everest','Everest',            'Gain 8,849 m of elevation',                       'elevation',  'elevation_total', 8849,   1,   95),
+    ('streak_7',         'Week Streak',        'Record an activity 7 days in a row',              'streaks',    'streak',          NULL,   7,   120),
+    ('streak_30',        'Month Streak',       'Record an activity 30 days in a row',             'streaks',    'streak',          NULL,   30,  130),
+    ('early_bird',       'Early Bird',         'Reach a summit before 7am',                       'summits',    'early_bird',      7,      1,   140),
+    ('challenge_win',    'Champion',           'Win a competitive challenge',                     'challenges', 'challenge_win',   NULL,   1,   150),
+    ('challenge_win_5',  'Serial Winner',      'Win 5 competitive challenges',                    'challenges', 'challenge_win',   NULL,   5,   160)
+ON CONFLICT (key) DO NOTHING;
@@ -0,0 +1,21 @@
+-- Streak preferences
+-- Activity times are stored as local wall-clock times; the timezone decides
+-- what "today" is when working out whether a streak is still alive.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS week_start SMALLINT NOT NULL DEFAULT 1;  -- 0 = Sunday, 1 = Monday, ...
+ALTER TABLE users DROP CONSTRAINT IF EXISTS check_week_start;
+ALTER TABLE users ADD CONSTRAINT check_week_start CHECK (week_start BETWEEN 0 AND 6);
+
+-- Streak challenges, e.g. "summit every week in Q1"
+-- streak_type: 'daily_activity' | 'weekly_activity' | 'weekly_summit'
+-- target_value is the streak length to reach; when NULL every period between
+-- start_date and deadline is required
+ALTER TABLE challenges ADD COLUMN IF NOT EXISTS streak_type VARCHAR(20);
+ALTER TABLE challenges DROP CONSTRAINT IF EXISTS check_goal_type;
+ALTER TABLE challenges ADD CONSTRAINT check_goal_type
+    CHECK (goal_type IN ('distance', 'elevation', 'summit_count', 'specific_summits', 'fastest_time', 'streak'));
+
+ALTER TABLE challenge_series ADD COLUMN IF NOT EXISTS streak_type VARCHAR(20);
+
+ALTER TABLE challenge_participants ADD COLUMN IF NOT EXISTS current_streak INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE challenge_participants ADD COLUMN IF NOT EXISTS longest_streak INTEGER NOT NULL DEFAULT 0;
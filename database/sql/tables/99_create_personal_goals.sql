@@ -0,0 +1,115 @@
+-- Flexible personal goals
+-- A goal is one metric over one period. Several can run at once, and each can
+-- be limited to certain activity types. Yearly goals (one row per metric with
+-- is_yearly_goal set) back the original /api/personal-goals endpoints.
+--   metric            target_value unit
+--   distance          km
+--   elevation         meters
+--   moving_time       hours
+--   activity_count    activities
+--   summit_count      summits
+--   unique_peaks      distinct peaks
+--   region_peaks      distinct peaks in region
+--   specific_summits  distinct peaks from peak_ids
+CREATE TABLE IF NOT EXISTS personal_goals (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    metric VARCHAR(20) NOT NULL,
+    period_type VARCHAR(10) NOT NULL,
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,                    -- Inclusive
+    target_value NUMERIC NOT NULL DEFAULT 0,
+    activity_types TEXT[],                     -- NULL or empty means every type
+    region VARCHAR(100),                       -- For region_peaks
+    peak_ids BIGINT[],                         -- For specific_summits
+    is_yearly_goal BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_personal_goals_user_id FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT check_personal_goal_metric CHECK (metric IN (
+        'distance', 'elevation', 'moving_time', 'activity_count',
+        'summit_count', 'unique_peaks', 'region_peaks', 'specific_summits'
+    )),
+    CONSTRAINT check_personal_goal_period CHECK (period_type IN ('week', 'month', 'quarter', 'year', 'custom')),
+    CONSTRAINT check_personal_goal_dates CHECK (end_date >= start_date),
+    CONSTRAINT check_personal_goal_target CHECK (target_value >= 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_personal_goals_user_id ON personal_goals(user_id);
+CREATE INDEX IF NOT EXISTS idx_personal_goals_user_dates ON personal_goals(user_id, start_date, end_date);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_personal_goals_yearly
+    ON personal_goals(user_id, metric, start_date) WHERE is_yearly_goal;
+
+-- Migrate personal_yearly_goals, one row per metric. Zero targets are kept so
+-- the yearly endpoints return exactly what was stored before.
+INSERT INTO personal_goals (
+    user_id, name, metric, period_type, start_date, end_date,
+    target_value, is_yearly_goal, created_at, updated_at
+)
+SELECT
+    pyg.user_id,
+    pyg.year || ' ' || m.label,
+    m.metric,
+    'year',
+    make_date(pyg.year, 1, 1),
+    make_date(pyg.year, 12, 31),
+    CASE m.metric
+        WHEN 'distance' THEN COALESCE(pyg.distance_goal, 0)
+        WHEN 'elevation' THEN COALESCE(pyg.elevation_goal, 0)
+        ELSE COALESCE(pyg.summit_goal, 0)
+    END,
+    TRUE,
+    pyg.created_at,
+    pyg.updated_at
+FROM personal_yearly_goals pyg
+CROSS JOIN (VALUES
+    ('distance', 'distance goal'),
+    ('elevation', 'elevation goal'),
+    ('summit_count', 'summit goal')
+) AS m(metric, label)
+ON CONFLICT (user_id, metric, start_date) WHERE is_yearly_goal DO NOTHING;
+
+-- target_summits is normally gone already: 86_remove_target_summits.sql
+-- dropped it when those peaks moved to summit_favourites, so there is
+-- nothing left to carry over. On a database where 86 was never applied, keep
+-- each non-empty list as a specific_summits goal for its year. These aren't
+-- yearly goals because the yearly endpoints only read distance, elevation and
+-- summit_count, and would delete the row when the year's goal is removed.
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'personal_yearly_goals' AND column_name = 'target_summits'
+    ) THEN
+        EXECUTE $sql$
+            INSERT INTO personal_goals (
+                user_id, name, metric, period_type, start_date, end_date,
+                target_value, peak_ids, created_at, updated_at
+            )
+            SELECT
+                pyg.user_id,
+                pyg.year || ' target summits',
+                'specific_summits',
+                'year',
+                make_date(pyg.year, 1, 1),
+                make_date(pyg.year, 12, 31),
+                cardinality(pyg.target_summits),
+                pyg.target_summits,
+                pyg.created_at,
+                pyg.updated_at
+            FROM personal_yearly_goals pyg
+            WHERE cardinality(pyg.target_summits) > 0
+              AND NOT EXISTS (
+                  SELECT 1 FROM personal_goals pg
+                  WHERE pg.user_id = pyg.user_id
+                    AND pg.metric = 'specific_summits'
+                    AND pg.start_date = make_date(pyg.year, 1, 1)
+                    AND pg.peak_ids = pyg.target_summits
+              )
+        $sql$;
+    END IF;
+END $$;
+
+COMMENT ON TABLE personal_yearly_goals IS 'DEPRECATED: Migrated to personal_goals. Keep for rollback safety. Can be dropped in future version.';
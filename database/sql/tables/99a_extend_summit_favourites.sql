@@ -0,0 +1,17 @@
+-- Turn summit favourites into a planning wishlist: notes, a target date and a
+-- user-defined order. Existing favourites keep their newest-first order.
+ALTER TABLE summit_favourites ADD COLUMN IF NOT EXISTS notes TEXT;
+ALTER TABLE summit_favourites ADD COLUMN IF NOT EXISTS target_date DATE;
+ALTER TABLE summit_favourites ADD COLUMN IF NOT EXISTS sort_order INT NOT NULL DEFAULT 0;
+ALTER TABLE summit_favourites ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP;
+
+UPDATE summit_favourites sf
+SET sort_order = ordered.position
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY created_at DESC, id DESC) AS position
+    FROM summit_favourites
+) ordered
+WHERE sf.id = ordered.id
+  AND NOT EXISTS (SELECT 1 FROM summit_favourites WHERE sort_order <> 0);
+
+CREATE INDEX IF NOT EXISTS idx_summit_favourites_user_order ON summit_favourites(user_id, sort_order);
@@ -0,0 +1,13 @@
+-- Peak search: trigram indexes for fuzzy name matching plus indexes for the
+-- region, elevation, prominence and location filters
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_peaks_name_trgm ON peaks USING GIN (name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_peaks_alt_name_trgm ON peaks USING GIN (alt_name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_peaks_name_en_trgm ON peaks USING GIN (name_en gin_trgm_ops);
+
+CREATE INDEX IF NOT EXISTS idx_peaks_region ON peaks(region);
+CREATE INDEX IF NOT EXISTS idx_peaks_elevation ON peaks(elevation_meters);
+CREATE INDEX IF NOT EXISTS idx_peaks_prominence ON peaks(prominence);
+CREATE INDEX IF NOT EXISTS idx_peaks_lat_lon ON peaks(latitude, longitude);
+CREATE INDEX IF NOT EXISTS idx_user_peaks_peak_user ON user_peaks(peak_id, user_id);
@@ -0,0 +1,3 @@
+-- Privacy setting: whether the user's name appears in community stats such as
+-- a peak's most frequent summiteers. Their summits still count in totals.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS show_in_leaderboards BOOLEAN NOT NULL DEFAULT TRUE;
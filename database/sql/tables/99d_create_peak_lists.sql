@@ -0,0 +1,31 @@
+-- Peak lists: reusable, ordered sets of peaks ("Table Mountain 7 ascents",
+-- "Cape Peninsula peaks over 900 m") with no dates or participants. Lists made
+-- by admins are curated. Progress is computed from all-time user_peaks.
+CREATE TABLE IF NOT EXISTS peak_lists (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    region VARCHAR(100),
+    visibility VARCHAR(20) NOT NULL DEFAULT 'private',
+    is_curated BOOLEAN NOT NULL DEFAULT FALSE,
+    created_by_user_id BIGINT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_peak_lists_created_by FOREIGN KEY (created_by_user_id) REFERENCES users (id) ON DELETE SET NULL,
+    CONSTRAINT check_peak_list_visibility CHECK (visibility IN ('private', 'public'))
+);
+
+CREATE TABLE IF NOT EXISTS peak_list_peaks (
+    list_id BIGINT NOT NULL,
+    peak_id BIGINT NOT NULL,
+    sort_order INT NOT NULL DEFAULT 0,
+
+    PRIMARY KEY (list_id, peak_id),
+    CONSTRAINT fk_peak_list_peaks_list FOREIGN KEY (list_id) REFERENCES peak_lists (id) ON DELETE CASCADE,
+    CONSTRAINT fk_peak_list_peaks_peak FOREIGN KEY (peak_id) REFERENCES peaks (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_peak_lists_created_by ON peak_lists(created_by_user_id);
+CREATE INDEX IF NOT EXISTS idx_peak_lists_curated ON peak_lists(is_curated) WHERE is_curated;
+CREATE INDEX IF NOT EXISTS idx_peak_list_peaks_peak ON peak_list_peaks(peak_id);
@@ -0,0 +1,22 @@
+-- Duplicate peak merging. OSM often has several natural=peak nodes for one
+-- summit (a trig beacon plus a named node). A dedupe scan clusters nearby
+-- peaks into proposals; once an admin confirms one, every reference to the
+-- duplicates is re-pointed at the canonical peak and the duplicates are kept
+-- with merged_into_id set, so a peak refresh from OSM can't bring them back.
+ALTER TABLE peaks ADD COLUMN IF NOT EXISTS merged_into_id BIGINT REFERENCES peaks(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_peaks_active ON peaks(id) WHERE merged_into_id IS NULL;
+
+CREATE TABLE IF NOT EXISTS peak_merge_proposals (
+    id BIGSERIAL PRIMARY KEY,
+    canonical_peak_id BIGINT NOT NULL REFERENCES peaks(id) ON DELETE CASCADE,
+    peak_ids BIGINT[] NOT NULL,           -- Every peak in the cluster, canonical included, sorted
+    span_meters NUMERIC NOT NULL,         -- Largest distance between two peaks in the cluster
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    resolved_at TIMESTAMPTZ,
+
+    CONSTRAINT check_peak_merge_status CHECK (status IN ('pending', 'merged', 'rejected'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_peak_merge_proposals_status ON peak_merge_proposals(status);
@@ -0,0 +1,7 @@
+-- Where a peak's elevation came from: 'osm' for the ele tag, 'dem' when it
+-- was filled or corrected from local elevation model tiles. NULL means no
+-- elevation is known yet.
+ALTER TABLE peaks ADD COLUMN IF NOT EXISTS elevation_source VARCHAR(10);
+
+UPDATE peaks SET elevation_source = 'osm'
+WHERE elevation_source IS NULL AND elevation_meters IS NOT NULL AND elevation_meters <> 0;
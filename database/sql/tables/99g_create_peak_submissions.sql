@@ -0,0 +1,30 @@
+-- User-submitted peaks and corrections, reviewed by admins.
+-- Approved new peaks have source 'local' and no osm_id. Approved corrections
+-- add the corrected fields to locked_fields ('name', 'position', 'elevation')
+-- so the next Overpass refresh doesn't overwrite them.
+ALTER TABLE peaks ADD COLUMN IF NOT EXISTS source VARCHAR(10) NOT NULL DEFAULT 'osm';
+ALTER TABLE peaks ADD COLUMN IF NOT EXISTS locked_fields TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE TABLE IF NOT EXISTS peak_submissions (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    kind VARCHAR(20) NOT NULL,               -- new or correction
+    peak_id BIGINT REFERENCES peaks(id) ON DELETE CASCADE, -- The peak being corrected, or the peak created on approval
+    -- Proposed values. For corrections NULL means unchanged.
+    name VARCHAR(255),
+    latitude NUMERIC,
+    longitude NUMERIC,
+    elevation_meters NUMERIC,
+    note TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    reviewer_note TEXT,
+    reviewed_by_user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    reviewed_at TIMESTAMPTZ,
+
+    CONSTRAINT check_peak_submission_kind CHECK (kind IN ('new', 'correction')),
+    CONSTRAINT check_peak_submission_status CHECK (status IN ('pending', 'approved', 'rejected'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_peak_submissions_status ON peak_submissions(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_peak_submissions_user ON peak_submissions(user_id);
@@ -0,0 +1,27 @@
+-- Activity visibility imported from Strava. visibility is everyone,
+-- followers_only or only_me. Private and only_me activities are never shown
+-- to other users.
+ALTER TABLE activity ADD COLUMN IF NOT EXISTS private BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE activity ADD COLUMN IF NOT EXISTS visibility VARCHAR(20) NOT NULL DEFAULT 'everyone';
+
+-- Whether the user's activities appear to other users in group feeds (the
+-- Hike Gang feed, group heatmaps) and challenge activity feeds.
+-- show_in_leaderboards (99c) also covers challenge leaderboards.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS show_in_group_feeds BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS show_in_challenge_feeds BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- Circles, usually around home, that routes are trimmed to before anyone
+-- else sees them
+CREATE TABLE IF NOT EXISTS privacy_zones (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100),
+    latitude NUMERIC NOT NULL,
+    longitude NUMERIC NOT NULL,
+    radius_meters NUMERIC NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT check_privacy_zone_radius CHECK (radius_meters BETWEEN 100 AND 5000)
+);
+
+CREATE INDEX IF NOT EXISTS idx_privacy_zones_user ON privacy_zones(user_id);
@@ -0,0 +1,8 @@
+-- Activity listing: full-text search on name and description, plus indexes
+-- for the default newest-first ordering and the peak filter
+ALTER TABLE activity ADD COLUMN IF NOT EXISTS search_vector TSVECTOR
+    GENERATED ALWAYS AS (to_tsvector('simple', COALESCE(name, '') || ' ' || COALESCE(description, ''))) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_activity_search ON activity USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_activity_user_start_date ON activity(user_id, start_date DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_user_peaks_activity ON user_peaks(activity_id);
@@ -0,0 +1,43 @@
+-- User corrections to summit detection.
+-- source is 'detected' for summits found on the route, 'claimed' for ones a
+-- user claimed and had approved. Claimed summits survive a summit recalculation.
+ALTER TABLE user_peaks ADD COLUMN IF NOT EXISTS source VARCHAR(20) NOT NULL DEFAULT 'detected';
+
+-- Detected summits the user dismissed as false positives. Detection skips
+-- these so recalculating doesn't add them back.
+CREATE TABLE IF NOT EXISTS summit_dismissals (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    peak_id BIGINT NOT NULL REFERENCES peaks(id) ON DELETE CASCADE,
+    activity_id BIGINT NOT NULL REFERENCES activity(id) ON DELETE CASCADE,
+    reason TEXT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT summit_dismissals_unique UNIQUE (user_id, peak_id, activity_id)
+);
+
+-- Summits detection missed, claimed against one of the user's activities.
+-- Claims whose route passes near the peak are approved straight away, the
+-- rest wait for a site admin, an admin of one of the user's groups or the
+-- owner of one of their challenges.
+CREATE TABLE IF NOT EXISTS summit_claims (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    peak_id BIGINT NOT NULL REFERENCES peaks(id) ON DELETE CASCADE,
+    activity_id BIGINT NOT NULL REFERENCES activity(id) ON DELETE CASCADE,
+    note TEXT,
+    distance_meters NUMERIC,                 -- Closest the route came to the peak, NULL without a route
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    auto_approved BOOLEAN NOT NULL DEFAULT FALSE,
+    reviewer_note TEXT,
+    reviewed_by_user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    reviewed_at TIMESTAMPTZ,
+
+    CONSTRAINT check_summit_claim_status CHECK (status IN ('pending', 'approved', 'rejected'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_summit_claims_status ON summit_claims(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_summit_claims_user ON summit_claims(user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_summit_claims_pending
+    ON summit_claims(user_id, peak_id, activity_id) WHERE status = 'pending';
@@ -0,0 +1,24 @@
+-- Account data exports. A worker builds the ZIP in the background; once it's
+-- ready the user downloads it with the token until expires_at, after which
+-- the file is deleted.
+CREATE TABLE IF NOT EXISTS data_exports (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, running, ready, failed, expired
+    token VARCHAR(64),                             -- Download token, set when ready
+    file_path TEXT,
+    size_bytes BIGINT,
+    error TEXT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_data_exports_user ON data_exports(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_data_exports_status ON data_exports(status, created_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_data_exports_token ON data_exports(token) WHERE token IS NOT NULL;
+
+-- One export in progress per user
+CREATE UNIQUE INDEX IF NOT EXISTS idx_data_exports_active
+    ON data_exports(user_id) WHERE status IN ('pending', 'running');
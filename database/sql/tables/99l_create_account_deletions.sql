@@ -0,0 +1,42 @@
+-- Account deletion runs as a tracked job. Before the user row goes, the job
+-- revokes Strava access, hands owned groups and challenges to another member
+-- (or deletes them when nobody else is left), and moves results in finished
+-- challenges to an anonymous placeholder account so other people's
+-- leaderboards don't change. The row outlives the user so the receipt can
+-- still be looked up with its code.
+CREATE TABLE IF NOT EXISTS account_deletions (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,                       -- No FK, the user is gone once this completes
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, running, completed, failed
+    receipt_code VARCHAR(64) NOT NULL,
+    receipt JSONB,                                 -- What was done, set once completed
+    error TEXT,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    requested_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ,
+
+    CONSTRAINT check_account_deletion_status CHECK (status IN ('pending', 'running', 'completed', 'failed'))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_account_deletions_receipt_code ON account_deletions(receipt_code);
+CREATE INDEX IF NOT EXISTS idx_account_deletions_status ON account_deletions(status, requested_at);
+
+-- One deletion in progress per user
+CREATE UNIQUE INDEX IF NOT EXISTS idx_account_deletions_active
+    ON account_deletions(user_id) WHERE status IN ('pending', 'running');
+
+-- Set on the placeholder accounts holding anonymised leaderboard history.
+-- They have no Strava account and are skipped by syncs.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+-- Groups used to be deleted with their creator, taking everyone else's
+-- membership with them. Deletion now hands them on first.
+ALTER TABLE groups DROP CONSTRAINT IF EXISTS fk_groups_user;
+ALTER TABLE groups ADD CONSTRAINT fk_groups_user
+    FOREIGN KEY (created_by) REFERENCES users (id) ON DELETE SET NULL;
+
+-- Having reviewed a proposal blocked deleting the reviewer
+ALTER TABLE challenge_proposals DROP CONSTRAINT IF EXISTS challenge_proposals_reviewed_by_user_id_fkey;
+ALTER TABLE challenge_proposals ADD CONSTRAINT challenge_proposals_reviewed_by_user_id_fkey
+    FOREIGN KEY (reviewed_by_user_id) REFERENCES users (id) ON DELETE SET NULL;
@@ -0,0 +1,25 @@
+-- In-app notification inbox. Rows are written when something happens to the
+-- user (a summit detected, a challenge completed, being overtaken on a
+-- leaderboard, being added to a group). The linked ids are whichever apply
+-- to the type.
+CREATE TABLE IF NOT EXISTS notifications (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(50) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    body TEXT,
+    peak_id BIGINT REFERENCES peaks(id) ON DELETE CASCADE,
+    activity_id BIGINT REFERENCES activity(id) ON DELETE CASCADE,
+    challenge_id BIGINT REFERENCES challenges(id) ON DELETE CASCADE,
+    group_id BIGINT REFERENCES groups(id) ON DELETE CASCADE,
+    dedupe_key VARCHAR(255),                      -- Stops the same event notifying twice, e.g. a summit re-detected on resync
+    read_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notifications_dedupe ON notifications(user_id, dedupe_key);
+CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications(user_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_notifications_unread ON notifications(user_id) WHERE read_at IS NULL;
+
+-- Which notification types the user wants, by type. Types left out are on.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS notification_preferences JSONB NOT NULL DEFAULT '{}';
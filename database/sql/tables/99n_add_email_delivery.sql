@@ -0,0 +1,37 @@
+-- Optional email delivery. The address only receives mail once verified.
+-- email_preferences turns each kind of email on by kind, all are off until
+-- the user opts in. The unsubscribe token goes in every email's links so
+-- unsubscribing needs no login.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email VARCHAR(255);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMPTZ;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verification_token VARCHAR(64);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_unsubscribe_token VARCHAR(64);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_preferences JSONB NOT NULL DEFAULT '{}';
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_verification_token ON users(email_verification_token);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_unsubscribe_token ON users(email_unsubscribe_token);
+
+-- Every email queued, kept as send history. Emails are rendered when queued
+-- and sent by a worker, which retries failures a few times.
+CREATE TABLE IF NOT EXISTS email_sends (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(50) NOT NULL,                     -- verification, weekly_digest, challenge_ending, challenge_completed, group_invite
+    recipient VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    text_body TEXT NOT NULL,
+    html_body TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, sending, sent, failed
+    error TEXT,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    dedupe_key VARCHAR(255),                       -- Stops the same digest or alert going out twice
+    next_attempt_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMPTZ,
+
+    CONSTRAINT check_email_send_status CHECK (status IN ('pending', 'sending', 'sent', 'failed'))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_email_sends_dedupe ON email_sends(user_id, dedupe_key);
+CREATE INDEX IF NOT EXISTS idx_email_sends_user ON email_sends(user_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_email_sends_pending ON email_sends(next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1,44 @@
+-- Outbound webhooks group admins register to post group activity to chat
+-- apps. events lists the events the webhook wants, format is the payload
+-- shape: generic JSON, or Slack and Discord incoming webhook bodies. Every
+-- request is signed with the secret.
+CREATE TABLE IF NOT EXISTS group_webhooks (
+    id BIGSERIAL PRIMARY KEY,
+    group_id BIGINT NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    format VARCHAR(20) NOT NULL DEFAULT 'generic', -- generic, slack, discord
+    events TEXT[] NOT NULL,                        -- summit_detected, challenge_joined, challenge_completed, leaderboard_changed
+    secret VARCHAR(64) NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT check_group_webhook_format CHECK (format IN ('generic', 'slack', 'discord'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_group_webhooks_group ON group_webhooks(group_id);
+
+-- Every request queued for a webhook, kept as its delivery log. The body is
+-- rendered when queued and sent by a worker, which retries failures with
+-- backoff.
+CREATE TABLE IF NOT EXISTS group_webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id BIGINT NOT NULL REFERENCES group_webhooks(id) ON DELETE CASCADE,
+    event VARCHAR(50) NOT NULL,
+    payload TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, sending, delivered, failed
+    attempts INTEGER NOT NULL DEFAULT 0,
+    response_status INTEGER,                       -- HTTP status of the last attempt, unset if no response
+    error TEXT,
+    dedupe_key VARCHAR(255),                       -- Stops the same event posting twice, e.g. a summit re-detected on resync
+    next_attempt_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMPTZ,
+
+    CONSTRAINT check_group_webhook_delivery_status CHECK (status IN ('pending', 'sending', 'delivered', 'failed'))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_group_webhook_deliveries_dedupe ON group_webhook_deliveries(webhook_id, dedupe_key);
+CREATE INDEX IF NOT EXISTS idx_group_webhook_deliveries_webhook ON group_webhook_deliveries(webhook_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_group_webhook_deliveries_pending ON group_webhook_deliveries(next_attempt_at) WHERE status = 'pending';
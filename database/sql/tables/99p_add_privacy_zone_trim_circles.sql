@@ -0,0 +1,24 @@
+-- Routes are cut at a trim circle rather than the zone itself, so the zone's
+-- centre can't be recovered from where routes stop. The trim circle is
+-- 25-75% larger than the zone and its centre is moved a random distance that
+-- keeps the whole zone inside it. It's picked once per zone so repeated
+-- routes don't average out to the centre.
+ALTER TABLE privacy_zones ADD COLUMN IF NOT EXISTS trim_latitude NUMERIC;
+ALTER TABLE privacy_zones ADD COLUMN IF NOT EXISTS trim_longitude NUMERIC;
+ALTER TABLE privacy_zones ADD COLUMN IF NOT EXISTS trim_radius_meters NUMERIC;
+
+WITH margins AS (
+    SELECT id, radius_meters * (0.25 + random() * 0.5) AS margin, random() AS shift, random() * 2 * pi() AS bearing
+    FROM privacy_zones
+    WHERE trim_radius_meters IS NULL
+)
+UPDATE privacy_zones z
+SET trim_latitude = z.latitude + m.margin * m.shift * cos(m.bearing) / 111195,
+    trim_longitude = z.longitude + m.margin * m.shift * sin(m.bearing) / (111195 * GREATEST(cos(radians(z.latitude)), 0.000001)),
+    trim_radius_meters = z.radius_meters + m.margin
+FROM margins m
+WHERE z.id = m.id;
+
+ALTER TABLE privacy_zones ALTER COLUMN trim_latitude SET NOT NULL;
+ALTER TABLE privacy_zones ALTER COLUMN trim_longitude SET NOT NULL;
+ALTER TABLE privacy_zones ALTER COLUMN trim_radius_meters SET NOT NULL;
@@ -0,0 +1,7 @@
+-- The worker building an export bumps heartbeat_at while it runs. Only
+-- exports whose heartbeat has gone stale are requeued, so a replica starting
+-- up doesn't take over exports another replica is still building.
+ALTER TABLE data_exports ADD COLUMN IF NOT EXISTS heartbeat_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_data_exports_running_heartbeat
+    ON data_exports(heartbeat_at) WHERE status = 'running';
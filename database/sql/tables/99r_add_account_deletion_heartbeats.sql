@@ -0,0 +1,6 @@
+-- As with data exports (99q), the worker running a deletion bumps
+-- heartbeat_at and only deletions with a stale heartbeat are requeued.
+ALTER TABLE account_deletions ADD COLUMN IF NOT EXISTS heartbeat_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_account_deletions_running_heartbeat
+    ON account_deletions(heartbeat_at) WHERE status = 'running';
@@ -1,6 +1,7 @@
 import { Injectable } from '@angular/core';
 import { HttpClient } from '@angular/common/http';
-import { BehaviorSubject, Observable } from 'rxjs';
+import { BehaviorSubject, EMPTY, Observable } from 'rxjs';
+import { expand, reduce } from 'rxjs/operators';
 
 export interface Activity {
   id: number;
@@ -17,6 +18,16 @@ export interface Activity {
   photo_url?: string;
 }
 
+// One page of GET /api/activities. next_cursor is empty on the last page.
+interface ActivityPage {
+  activities: Activity[];
+  next_cursor?: string;
+  limit: number;
+}
+
+// The most activities the API returns per page
+const ACTIVITY_PAGE_SIZE = 200;
+
 export interface ActivityWithUser extends Activity {
   userName: string;
   stravaAthleteId: number;
@@ -43,16 +54,34 @@ export class ActivityService {
 
     this.loading = true;
 
-    this.http.get<Activity[]>('/api/activities').subscribe({
-      next: (acts) => {
-        this.activitiesSubject.next(acts);
-        this.loading = false;
-      },
-      error: (err) => {
-        console.error('Failed to load activities', err);
-        this.loading = false;
-      },
-    });
+    // The API is paginated, follow the cursors until every activity is loaded
+    this.fetchActivityPage()
+      .pipe(
+        expand((page) =>
+          page.next_cursor ? this.fetchActivityPage(page.next_cursor) : EMPTY
+        ),
+        reduce((all, page) => all.concat(page.activities), [] as Activity[])
+      )
+      .subscribe({
+        next: (acts) => {
+          this.activitiesSubject.next(acts);
+          this.loading = false;
+        },
+        error: (err) => {
+          console.error('Failed to load activities', err);
+          this.loading = false;
+        },
+      });
+  }
+
+  private fetchActivityPage(cursor?: string): Observable<ActivityPage> {
+    const params: Record<string, string | number> = {
+      limit: ACTIVITY_PAGE_SIZE,
+    };
+    if (cursor) {
+      params['cursor'] = cursor;
+    }
+    return this.http.get<ActivityPage>('/api/activities', { params });
   }
 
   refreshActivities(): void {